@@ -0,0 +1,174 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/diskbench"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+)
+
+var benchCmd = &cobra.Command{
+	Use:   "bench",
+	Short: "Measure how fast this host can take in snapshots",
+}
+
+var benchDiskCmd = &cobra.Command{
+	Use:   "disk",
+	Short: "Measure sequential write throughput of the download directory",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		sizeStr, _ := cmd.Flags().GetString("size")
+		dir, _ := cmd.Flags().GetString("dir")
+
+		size, err := config.ParseSize(sizeStr)
+		if err != nil {
+			return fmt.Errorf("--size: %w", err)
+		}
+		if dir == "" {
+			dir = cfg.Snapshots.DownloadDir()
+		}
+
+		log.Info(fmt.Sprintf("writing %s to %s", config.FormatSize(size), dir))
+		result, err := diskbench.Sequential(context.Background(), dir, size)
+		if err != nil {
+			return fmt.Errorf("benchmarking %s: %w", dir, err)
+		}
+		speed := result.BytesPerSecond()
+		fmt.Printf("%s/s sequential write (%s in %s)\n", config.FormatSize(speed), config.FormatSize(result.Bytes), result.Duration.Round(time.Millisecond))
+
+		dl := cfg.Snapshots.Download
+		if required := dl.RequiredWriteSpeed(); speed < required {
+			log.Warn(fmt.Sprintf("below the %s/s that min_speed (%s) on %d connections needs - downloads will be limited by the disk",
+				config.FormatSize(required), dl.MinSpeed, dl.Connections))
+		}
+		return nil
+	},
+}
+
+var benchNetworkCmd = &cobra.Command{
+	Use:   "network",
+	Short: "Measure download throughput from the top full snapshot candidates per connection count",
+	Long: `Measure download throughput from the top full snapshot candidates.
+
+Each of the --top candidates discovery ranks first is sampled for --duration
+at each --connections count, discarding what is read. The table shows the
+aggregate throughput per candidate and count, followed by suggested
+connections and min_speed values for this link. Per-source limits apply.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		top, _ := cmd.Flags().GetInt("top")
+		counts, _ := cmd.Flags().GetIntSlice("connections")
+		duration, _ := cmd.Flags().GetDuration("duration")
+		if top < 1 {
+			return fmt.Errorf("--top must be >= 1, got %d", top)
+		}
+		if len(counts) == 0 || slices.Min(counts) < 1 {
+			return fmt.Errorf("--connections must list counts >= 1")
+		}
+		slices.Sort(counts)
+		ctx := cmd.Context()
+
+		k := keeper.New(cfg)
+		defer k.Close()
+		nodes, err := k.Discover(ctx, discovery.SnapshotTypeFull)
+		if err != nil {
+			return err
+		}
+		if len(nodes) == 0 {
+			return fmt.Errorf("no full snapshot candidates found")
+		}
+		nodes = nodes[:min(top, len(nodes))]
+
+		// speeds[i][j] is the throughput of counts[i] connections to nodes[j]
+		speeds := make([][]int64, len(counts))
+		for i, c := range counts {
+			speeds[i] = make([]int64, len(nodes))
+			for j, n := range nodes {
+				log.Info(fmt.Sprintf("sampling with %d connections", c), "node", n.RPCURL)
+				result, err := k.SampleThroughput(ctx, n, c, duration)
+				if err != nil {
+					log.Warn("sample failed", "node", n.RPCURL, "connections", c, "error", err)
+					continue
+				}
+				speeds[i][j] = result.BytesPerSecond()
+			}
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		header := []string{"CONNECTIONS"}
+		for _, n := range nodes {
+			header = append(header, strings.TrimPrefix(strings.TrimPrefix(n.RPCURL, "http://"), "https://"))
+		}
+		fmt.Fprintln(tw, strings.Join(append(header, "BEST"), "\t"))
+		for i, c := range counts {
+			row := []string{fmt.Sprint(c)}
+			for _, speed := range speeds[i] {
+				row = append(row, formatSpeed(speed))
+			}
+			fmt.Fprintln(tw, strings.Join(append(row, formatSpeed(slices.Max(speeds[i]))), "\t"))
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+
+		connections, minSpeed := suggestDownloadSettings(counts, speeds)
+		if minSpeed == 0 {
+			return fmt.Errorf("no candidate could be sampled")
+		}
+		fmt.Printf("\nsuggested: connections: %d, min_speed: %dmb\n", connections, max(minSpeed>>20, 1))
+		return nil
+	},
+}
+
+func formatSpeed(bytesPerSec int64) string {
+	if bytesPerSec == 0 {
+		return "-"
+	}
+	return config.FormatSize(bytesPerSec) + "/s"
+}
+
+// suggestDownloadSettings picks the fewest connections that reach 90% of the
+// best throughput seen, and a min_speed of half what the median candidate
+// reaches with them, so typical sources pass and much slower ones don't.
+func suggestDownloadSettings(counts []int, speeds [][]int64) (int, int64) {
+	var best int64
+	for _, row := range speeds {
+		best = max(best, slices.Max(row))
+	}
+	if best == 0 {
+		return counts[0], 0
+	}
+	for i, c := range counts {
+		if slices.Max(speeds[i])*10 < best*9 {
+			continue
+		}
+		var sampled []int64
+		for _, speed := range speeds[i] {
+			if speed > 0 {
+				sampled = append(sampled, speed)
+			}
+		}
+		slices.Sort(sampled)
+		return c, sampled[len(sampled)/2] / 2
+	}
+	return counts[len(counts)-1], 0
+}
+
+func init() {
+	benchDiskCmd.Flags().String("size", "1gb", "how much to write, e.g. 4gb; more gets past write caches")
+	benchDiskCmd.Flags().String("dir", "", "directory to benchmark (default snapshots.download.tmp_directory, else snapshots.directory)")
+	benchNetworkCmd.Flags().Int("top", 3, "how many of the top full snapshot candidates to sample")
+	benchNetworkCmd.Flags().IntSlice("connections", []int{1, 2, 4, 8, 16}, "connection counts to sample")
+	benchNetworkCmd.Flags().Duration("duration", 10*time.Second, "how long each sample lasts")
+	benchCmd.AddCommand(benchDiskCmd, benchNetworkCmd)
+	rootCmd.AddCommand(benchCmd)
+}
@@ -0,0 +1,171 @@
+package cmd
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/mattn/go-isatty"
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/constants"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// ledgerDirectoryCandidates are where validators commonly keep their ledger.
+var ledgerDirectoryCandidates = []string{
+	"/mnt/ledger",
+	"/mnt/solana/ledger",
+	"/home/sol/ledger",
+	"/home/solana/ledger",
+	"/var/lib/solana/ledger",
+}
+
+var configCmd = &cobra.Command{
+	Use:   "config",
+	Short: "Manage the config file",
+	// Replaces the root's hook: there may not be a config to load yet
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		logLevel, _ := cmd.Flags().GetString("log-level")
+		logDisableTimestamps, _ := cmd.Flags().GetBool("log-disable-timestamps")
+		(&config.Log{Level: "info", Format: "text"}).ConfigureWithLevelString(logLevel, logDisableTimestamps)
+		return nil
+	},
+}
+
+var configInitCmd = &cobra.Command{
+	Use:   "init",
+	Short: "Write a commented config file with settings detected on this host",
+	Long: `Write a commented config file to --config.
+
+The validator's RPC is probed for its cluster, and common locations for the
+snapshot and ledger directories are checked. On a terminal each value is then
+asked for, with what was detected as the default; flags set values without
+asking. Use --non-interactive to take flags and detected values as they are.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		path, _ := cmd.Flags().GetString("config")
+		force, _ := cmd.Flags().GetBool("force")
+		nonInteractive, _ := cmd.Flags().GetBool("non-interactive")
+		if _, err := os.Stat(path); err == nil && !force {
+			return fmt.Errorf("%s already exists, use --force to replace it", path)
+		}
+
+		opts := detectInitOptions(cmd)
+		if !nonInteractive && isatty.IsTerminal(os.Stdin.Fd()) {
+			promptInitOptions(cmd, &opts, bufio.NewReader(os.Stdin), os.Stdout)
+		}
+		if opts.ActiveIdentityPubkey == "" {
+			return fmt.Errorf("the active identity pubkey is required, set it with --active-identity")
+		}
+		if !constants.IsValidCluster(opts.Cluster) {
+			return fmt.Errorf("invalid cluster name %q, must be one of: %v", opts.Cluster, constants.ValidClusters)
+		}
+
+		if err := config.WriteInitialFile(path, opts, force); err != nil {
+			return err
+		}
+		log.Info("config written", "path", path)
+		if _, err := os.Stat(opts.SnapshotsDirectory); err != nil {
+			log.Warn("snapshots directory doesn't exist yet - create it before the first run", "dir", opts.SnapshotsDirectory)
+		}
+		return nil
+	},
+}
+
+// detectInitOptions fills in values from flags, and from what is found on
+// this host for those the flags leave unset.
+func detectInitOptions(cmd *cobra.Command) config.InitOptions {
+	flag := func(name string) string {
+		v, _ := cmd.Flags().GetString(name)
+		return v
+	}
+	opts := config.InitOptions{
+		Client:               flag("client"),
+		ValidatorRPCURL:      flag("validator-rpc-url"),
+		ActiveIdentityPubkey: flag("active-identity"),
+		Cluster:              flag("cluster"),
+		SnapshotsDirectory:   flag("snapshots-dir"),
+		LedgerDirectory:      flag("ledger-dir"),
+		Notes:                map[string]string{},
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	client := rpc.NewClient(opts.ValidatorRPCURL)
+	if identity, err := client.GetIdentity(ctx); err != nil {
+		log.Warn("validator RPC not reachable - is the validator running?", "url", opts.ValidatorRPCURL, "error", err)
+		opts.Notes["validator.rpc_url"] = "not reachable when this file was written"
+	} else {
+		log.Info("validator RPC reachable", "url", opts.ValidatorRPCURL, "identity", identity)
+		opts.Notes["validator.rpc_url"] = "reachable when this file was written, identity " + identity
+		if !cmd.Flags().Changed("cluster") {
+			if hash, err := client.GetGenesisHash(ctx); err == nil {
+				if name, ok := constants.ClusterForGenesisHash(hash); ok {
+					opts.Cluster = name
+					opts.Notes["cluster.name"] = "detected from the validator's genesis hash"
+				}
+			}
+		}
+	}
+
+	if !cmd.Flags().Changed("snapshots-dir") {
+		if dir, ok := config.DetectSnapshotsDirectory(config.SnapshotDirectoryCandidates); ok {
+			opts.SnapshotsDirectory = dir
+			opts.Notes["snapshots.directory"] = "snapshot archives found here"
+		}
+	}
+	if !cmd.Flags().Changed("ledger-dir") {
+		for _, dir := range ledgerDirectoryCandidates {
+			if info, err := os.Stat(filepath.Join(dir, "rocksdb")); err == nil && info.IsDir() {
+				opts.LedgerDirectory = dir
+				break
+			}
+		}
+	}
+	return opts
+}
+
+// promptInitOptions asks for each value not set by a flag, offering the
+// detected one as the default.
+func promptInitOptions(cmd *cobra.Command, opts *config.InitOptions, in *bufio.Reader, out io.Writer) {
+	ask := func(flag, question string, value *string) {
+		if cmd.Flags().Changed(flag) {
+			return
+		}
+		if *value != "" {
+			fmt.Fprintf(out, "%s [%s]: ", question, *value)
+		} else {
+			fmt.Fprintf(out, "%s: ", question)
+		}
+		line, _ := in.ReadString('\n')
+		if line = strings.TrimSpace(line); line != "" {
+			*value = line
+		}
+	}
+	ask("cluster", "Cluster (mainnet-beta or testnet)", &opts.Cluster)
+	ask("client", "Validator client (agave or firedancer)", &opts.Client)
+	ask("validator-rpc-url", "Validator RPC URL", &opts.ValidatorRPCURL)
+	ask("active-identity", "Active identity pubkey (the identity the validator votes with)", &opts.ActiveIdentityPubkey)
+	ask("snapshots-dir", "Snapshots directory", &opts.SnapshotsDirectory)
+	ask("ledger-dir", "Ledger directory (empty to skip)", &opts.LedgerDirectory)
+}
+
+func init() {
+	configInitCmd.Flags().Bool("force", false, "replace an existing config file")
+	configInitCmd.Flags().Bool("non-interactive", false, "don't ask, take flags and detected values as they are")
+	configInitCmd.Flags().String("cluster", constants.ClusterMainnetBeta, "cluster name (detected from the validator's RPC when reachable)")
+	configInitCmd.Flags().String("client", config.ClientAgave, "validator client: agave or firedancer")
+	configInitCmd.Flags().String("validator-rpc-url", "http://127.0.0.1:8899", "the local validator's RPC URL")
+	configInitCmd.Flags().String("active-identity", "", "pubkey of the identity the validator votes with when active (required)")
+	configInitCmd.Flags().String("snapshots-dir", "/mnt/accounts/snapshots", "where snapshot archives are kept (detected from common locations)")
+	configInitCmd.Flags().String("ledger-dir", "", "the validator's ledger directory (detected from common locations)")
+	configCmd.AddCommand(configInitCmd)
+	rootCmd.AddCommand(configCmd)
+}
@@ -0,0 +1,69 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/delta"
+)
+
+var deltaSignCmd = &cobra.Command{
+	Use:   "delta-sign <archive>...",
+	Short: "Write delta signatures next to snapshot archives so keepers can fetch only changed blocks (run on a mirror)",
+	Args:  cobra.MinimumNArgs(1),
+	// Runs on mirrors, which have no keeper config
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error { return nil },
+	RunE: func(cmd *cobra.Command, args []string) error {
+		blockSizeStr, _ := cmd.Flags().GetString("block-size")
+		blockSize, err := config.ParseSize(blockSizeStr)
+		if err != nil || blockSize < 1 {
+			return fmt.Errorf("--block-size: invalid size %q", blockSizeStr)
+		}
+
+		for _, path := range args {
+			if err := writeSignature(path, int(blockSize)); err != nil {
+				return fmt.Errorf("signing %s: %w", path, err)
+			}
+		}
+		return nil
+	},
+}
+
+// writeSignature signs path and atomically writes <path>.delta.json.
+func writeSignature(path string, blockSize int) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	sig, err := delta.Sign(f, blockSize)
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(sig)
+	if err != nil {
+		return err
+	}
+
+	sigPath := path + delta.SignatureSuffix
+	if err := os.WriteFile(sigPath+".tmp", data, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(sigPath+".tmp", sigPath); err != nil {
+		os.Remove(sigPath + ".tmp")
+		return err
+	}
+	log.Info("wrote delta signature", "file", sigPath, "blocks", len(sig.Blocks))
+	return nil
+}
+
+func init() {
+	deltaSignCmd.Flags().String("block-size", "1mb", "block size; smaller finds more reuse but makes larger signatures")
+	rootCmd.AddCommand(deltaSignCmd)
+}
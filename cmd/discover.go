@@ -0,0 +1,243 @@
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+)
+
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Probe the cluster and list suitable snapshot nodes without downloading",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		typeFlag, _ := cmd.Flags().GetString("type")
+		sortFlag, _ := cmd.Flags().GetString("sort")
+		output, _ := cmd.Flags().GetString("output")
+		explain, _ := cmd.Flags().GetBool("explain")
+
+		var types []discovery.SnapshotType
+		switch typeFlag {
+		case "all":
+			types = []discovery.SnapshotType{discovery.SnapshotTypeFull, discovery.SnapshotTypeIncremental}
+		case "full":
+			types = []discovery.SnapshotType{discovery.SnapshotTypeFull}
+		case "incremental":
+			types = []discovery.SnapshotType{discovery.SnapshotTypeIncremental}
+		default:
+			return fmt.Errorf("--type must be full, incremental or all, got %q", typeFlag)
+		}
+		if sortFlag == "" {
+			sortFlag = cfg.Snapshots.Discovery.Candidates.SortOrder
+		}
+		less, ok := discoverSortOrders[sortFlag]
+		if sortFlag == discovery.SortScore {
+			// Discovery scores the nodes itself, with the configured weights
+			cfg.Snapshots.Discovery.Candidates.SortOrder = sortFlag
+		} else if !ok && sortFlag != discovery.SortAvailability {
+			return fmt.Errorf("--sort must be latency, slot_age, slot, region, score or availability, got %q", sortFlag)
+		}
+		write, ok := discoverWriters[output]
+		if !ok {
+			return fmt.Errorf("--output must be table, json or csv, got %q", output)
+		}
+
+		k := keeper.New(cfg)
+		defer k.Close()
+		var nodes []discovery.SnapshotNode
+		var rejected []discovery.RejectedNode
+		sources := map[discovery.SnapshotType]map[uint64]int{}
+		for _, t := range types {
+			report, err := k.DiscoverReport(cmd.Context(), t)
+			if err != nil {
+				return err
+			}
+			nodes = append(nodes, report.Nodes...)
+			rejected = append(rejected, report.Rejected...)
+			sources[t] = availabilitySources(t, report.Availability)
+		}
+		if sortFlag == discovery.SortAvailability {
+			less = func(a, b discovery.SnapshotNode) bool {
+				sa, sb := sources[a.SnapshotType][availabilitySlot(a)], sources[b.SnapshotType][availabilitySlot(b)]
+				if sa != sb {
+					return sa > sb
+				}
+				return a.Latency < b.Latency
+			}
+		}
+		if less != nil {
+			sort.SliceStable(nodes, func(i, j int) bool { return less(nodes[i], nodes[j]) })
+		}
+
+		if err := write(os.Stdout, nodes); err != nil {
+			return err
+		}
+		if explain {
+			// Explanations go to stderr so json and csv output stay parseable
+			return writeRejected(os.Stderr, rejected)
+		}
+		return nil
+	},
+}
+
+// availabilitySources maps each full slot, or each base slot for
+// incrementals, to how many nodes serve it.
+func availabilitySources(t discovery.SnapshotType, availability []discovery.FullAvailability) map[uint64]int {
+	out := make(map[uint64]int, len(availability))
+	for _, a := range availability {
+		if t == discovery.SnapshotTypeIncremental {
+			out[a.Slot] = a.IncrementalSources
+		} else {
+			out[a.Slot] = a.Sources
+		}
+	}
+	return out
+}
+
+// availabilitySlot is the slot n's availability is counted under.
+func availabilitySlot(n discovery.SnapshotNode) uint64 {
+	if n.SnapshotType == discovery.SnapshotTypeIncremental {
+		return n.BaseSlot
+	}
+	return n.Slot
+}
+
+// writeRejected lists why each rejected node failed, grouped by reason.
+func writeRejected(w io.Writer, rejected []discovery.RejectedNode) error {
+	sort.SliceStable(rejected, func(i, j int) bool {
+		if rejected[i].Reason != rejected[j].Reason {
+			return rejected[i].Reason < rejected[j].Reason
+		}
+		return rejected[i].Addr < rejected[j].Addr
+	})
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "REASON\tNODE\tERROR")
+	for _, n := range rejected {
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", n.Reason, n.Addr, n.Error)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d rejected nodes\n", len(rejected))
+	return err
+}
+
+var discoverSortOrders = map[string]func(a, b discovery.SnapshotNode) bool{
+	"latency":  func(a, b discovery.SnapshotNode) bool { return a.Latency < b.Latency },
+	"slot_age": func(a, b discovery.SnapshotNode) bool { return a.SlotAge < b.SlotAge },
+	"slot":     func(a, b discovery.SnapshotNode) bool { return a.Slot > b.Slot },
+	// region groups nodes by continent and country, fastest first within each
+	"region": func(a, b discovery.SnapshotNode) bool {
+		if a.Location.Continent != b.Location.Continent {
+			return a.Location.Continent < b.Location.Continent
+		}
+		if a.Location.Country != b.Location.Country {
+			return a.Location.Country < b.Location.Country
+		}
+		return a.Latency < b.Latency
+	},
+}
+
+var discoverWriters = map[string]func(w io.Writer, nodes []discovery.SnapshotNode) error{
+	"table": writeNodesTable,
+	"json":  writeNodesJSON,
+	"csv":   writeNodesCSV,
+}
+
+type discoveredNode struct {
+	Type      string  `json:"type"`
+	Slot      uint64  `json:"slot"`
+	BaseSlot  uint64  `json:"base_slot,omitempty"`
+	SlotAge   uint64  `json:"slot_age"`
+	LatencyMS float64 `json:"latency_ms"`
+	RPCURL    string  `json:"rpc_url"`
+	URL       string  `json:"url"`
+	Continent string  `json:"continent,omitempty"`
+	Country   string  `json:"country,omitempty"`
+	ASN       uint32  `json:"asn,omitempty"`
+	ASOrg     string  `json:"as_org,omitempty"`
+}
+
+func toDiscoveredNode(n discovery.SnapshotNode) discoveredNode {
+	return discoveredNode{
+		Type:      string(n.SnapshotType),
+		Slot:      n.Slot,
+		BaseSlot:  n.BaseSlot,
+		SlotAge:   n.SlotAge,
+		LatencyMS: float64(n.Latency.Microseconds()) / 1000,
+		RPCURL:    n.RPCURL,
+		URL:       n.SnapshotURL,
+		Continent: n.Location.Continent,
+		Country:   n.Location.Country,
+		ASN:       n.Location.ASN,
+		ASOrg:     n.Location.ASOrg,
+	}
+}
+
+func writeNodesTable(w io.Writer, nodes []discovery.SnapshotNode) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TYPE\tSLOT\tBASE SLOT\tSLOT AGE\tLATENCY\tLOCATION\tURL")
+	for _, n := range nodes {
+		base := "-"
+		if n.SnapshotType == discovery.SnapshotTypeIncremental {
+			base = strconv.FormatUint(n.BaseSlot, 10)
+		}
+		d := toDiscoveredNode(n)
+		fmt.Fprintf(tw, "%s\t%d\t%s\t%d\t%.1fms\t%s\t%s\n", d.Type, d.Slot, base, d.SlotAge, d.LatencyMS, n.Location, d.URL)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	_, err := fmt.Fprintf(w, "\n%d suitable nodes\n", len(nodes))
+	return err
+}
+
+func writeNodesJSON(w io.Writer, nodes []discovery.SnapshotNode) error {
+	out := make([]discoveredNode, 0, len(nodes))
+	for _, n := range nodes {
+		out = append(out, toDiscoveredNode(n))
+	}
+	enc := json.NewEncoder(w)
+	enc.SetIndent("", "  ")
+	return enc.Encode(out)
+}
+
+func writeNodesCSV(w io.Writer, nodes []discovery.SnapshotNode) error {
+	cw := csv.NewWriter(w)
+	cw.Write([]string{"type", "slot", "base_slot", "slot_age", "latency_ms", "rpc_url", "url", "continent", "country", "asn"})
+	for _, n := range nodes {
+		d := toDiscoveredNode(n)
+		cw.Write([]string{
+			d.Type,
+			strconv.FormatUint(d.Slot, 10),
+			strconv.FormatUint(d.BaseSlot, 10),
+			strconv.FormatUint(d.SlotAge, 10),
+			strconv.FormatFloat(d.LatencyMS, 'f', 1, 64),
+			d.RPCURL,
+			d.URL,
+			d.Continent,
+			d.Country,
+			strconv.FormatUint(uint64(d.ASN), 10),
+		})
+	}
+	cw.Flush()
+	return cw.Error()
+}
+
+func init() {
+	discoverCmd.Flags().String("type", "all", "snapshot type to list: full, incremental or all")
+	discoverCmd.Flags().String("sort", "", "sort by latency, slot_age, slot, region, score or availability (default snapshots.discovery.candidates.sort_order)")
+	discoverCmd.Flags().StringP("output", "o", "table", "output format: table, json or csv")
+	discoverCmd.Flags().Bool("explain", false, "also print why each rejected node failed (to stderr)")
+	rootCmd.AddCommand(discoverCmd)
+}
@@ -0,0 +1,86 @@
+package cmd
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/verify"
+)
+
+var downloadCmd = &cobra.Command{
+	Use:   "download <url|pubkey>",
+	Short: "Download a snapshot from a specific URL or node, skipping discovery and freshness checks",
+	Long: `Download a snapshot from a specific source into the snapshots directory.
+
+The source is a snapshot archive URL, a node's RPC URL, or a node's identity
+pubkey (looked up in the cluster's gossip). For a node, its latest full
+snapshot is downloaded. The configured download speed checks, limits and
+ownership apply; the validator's role and local freshness are not checked.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		withIncremental, _ := cmd.Flags().GetBool("incremental")
+		verifyArchives, _ := cmd.Flags().GetBool("verify")
+		ctx := cmd.Context()
+
+		k := keeper.New(cfg)
+		defer k.Close()
+		node, err := k.ResolveSource(ctx, args[0])
+		if err != nil {
+			return err
+		}
+		nodes := []discovery.SnapshotNode{node}
+		if withIncremental {
+			if node.SnapshotType != discovery.SnapshotTypeFull {
+				return fmt.Errorf("--incremental needs a full snapshot source, got an incremental")
+			}
+			inc, err := k.ResolveIncremental(ctx, node)
+			if err != nil {
+				return fmt.Errorf("finding matching incremental: %w", err)
+			}
+			nodes = append(nodes, inc)
+		}
+
+		for _, n := range nodes {
+			log.Info(fmt.Sprintf("downloading %s snapshot", n.SnapshotType), "slot", n.Slot, "url", n.SnapshotURL)
+			result, err := k.Download(ctx, n)
+			if err != nil {
+				return fmt.Errorf("downloading %s: %w", n.SnapshotURL, err)
+			}
+			if verifyArchives {
+				if err := verifyDownload(cmd, result, n); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	},
+}
+
+// verifyDownload checks the downloaded archive reads through and contains a
+// loadable bank, as `verify` does.
+func verifyDownload(cmd *cobra.Command, result *downloader.Result, node discovery.SnapshotNode) error {
+	log.Info("verifying snapshot", "file", result.FilePath, "slot", node.Slot)
+	v, err := verify.Archive(cmd.Context(), result.FilePath, node.Slot, verify.Options{})
+	if err != nil {
+		return fmt.Errorf("verifying %s: %w", result.FilePath, err)
+	}
+	log.Info("snapshot verified",
+		"file", result.FilePath,
+		"version", v.Version,
+		"account_files", v.AccountFiles,
+		"duration", v.Duration.Round(time.Second),
+	)
+	return nil
+}
+
+func init() {
+	downloadCmd.Flags().Bool("incremental", false, "also download the incremental the source serves for the full snapshot")
+	downloadCmd.Flags().Bool("verify", true, "verify downloaded archives as the verify command does")
+	rootCmd.AddCommand(downloadCmd)
+}
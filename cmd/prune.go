@@ -0,0 +1,55 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"text/tabwriter"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/manager"
+)
+
+var pruneCmd = &cobra.Command{
+	Use:   "prune",
+	Short: "Remove superseded snapshots and temp files now, or preview what would be removed",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dryRun, _ := cmd.Flags().GetBool("dry-run")
+		force, _ := cmd.Flags().GetBool("force")
+
+		m := manager.New(cfg)
+		defer m.Close()
+		plan, err := m.Prune(dryRun, force)
+		if err != nil {
+			return err
+		}
+
+		action, quarantine := "removed", "quarantined"
+		if dryRun {
+			action, quarantine = "would remove", "would quarantine"
+		}
+		quarantined := cfg.Snapshots.Retention.QuarantineDir != ""
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		for _, f := range plan.Keep {
+			fmt.Fprintf(tw, "keep\t%s\n", f.Path)
+		}
+		for _, r := range plan.Remove {
+			if r.Quarantine && quarantined {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", quarantine, r.Path, r.Reason)
+			} else {
+				fmt.Fprintf(tw, "%s\t%s\t%s\n", action, r.Path, r.Reason)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d kept, %d %s\n", len(plan.Keep), len(plan.Remove), action)
+		return nil
+	},
+}
+
+func init() {
+	pruneCmd.Flags().Bool("dry-run", false, "only list what would be removed, and why")
+	pruneCmd.Flags().Bool("force", false, "prune even while incident mode has pruning frozen")
+	rootCmd.AddCommand(pruneCmd)
+}
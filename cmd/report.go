@@ -0,0 +1,186 @@
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/cyclereport"
+)
+
+var reportCmd = &cobra.Command{
+	Use:   "report",
+	Short: "Review the cycle reports written to reports.directory",
+}
+
+var reportLastCmd = &cobra.Command{
+	Use:   "last",
+	Short: "Show the most recent cycle's report, of the validator picked with --validator when several are configured",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		output, _ := cmd.Flags().GetString("output")
+		dir, err := reportsDirectory()
+		if err != nil {
+			return err
+		}
+		r, err := cyclereport.Last(dir, cfg.Name)
+		if err != nil {
+			return err
+		}
+		switch output {
+		case "json":
+			enc := json.NewEncoder(os.Stdout)
+			enc.SetIndent("", "  ")
+			return enc.Encode(r)
+		case "text":
+			return writeCycleReport(os.Stdout, r)
+		default:
+			return fmt.Errorf("--output must be text or json, got %q", output)
+		}
+	},
+}
+
+var reportListCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List past cycles, newest first, of the validator picked with --validator when several are configured",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		limit, _ := cmd.Flags().GetInt("limit")
+		dir, err := reportsDirectory()
+		if err != nil {
+			return err
+		}
+		paths, err := cyclereport.Paths(dir, cfg.Name)
+		if err != nil {
+			return err
+		}
+		if limit > 0 && len(paths) > limit {
+			paths = paths[len(paths)-limit:]
+		}
+
+		tw := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "STARTED\tRESULT\tMODE\tSLOT\tDURATION\tDOWNLOADED\tPRUNED\tREASON")
+		for i := len(paths) - 1; i >= 0; i-- {
+			r, err := cyclereport.Read(paths[i])
+			if err != nil {
+				return err
+			}
+			var downloaded int64
+			for _, d := range r.Downloads {
+				downloaded += d.Bytes
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n",
+				r.Started.Local().Format(time.DateTime), r.Result, orDash(r.Mode), slotOrDash(r.SnapshotSlot),
+				reportDuration(r.DurationMs), config.FormatSize(downloaded), len(r.Pruned), r.Reason)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+		fmt.Printf("\n%d cycles\n", len(paths))
+		return nil
+	},
+}
+
+func reportsDirectory() (string, error) {
+	if cfg.Reports.Directory == "" {
+		return "", fmt.Errorf("reports.directory is not set, so no cycle reports are written")
+	}
+	return cfg.Reports.Directory, nil
+}
+
+// writeCycleReport prints r for reading at a terminal.
+func writeCycleReport(w io.Writer, r cyclereport.Report) error {
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(tw, "started\t%s\n", r.Started.Local().Format(time.DateTime))
+	fmt.Fprintf(tw, "duration\t%s\n", reportDuration(r.DurationMs))
+	if r.Validator != "" {
+		fmt.Fprintf(tw, "validator\t%s\n", r.Validator)
+	}
+	fmt.Fprintf(tw, "cluster\t%s\n", r.Cluster)
+	fmt.Fprintf(tw, "result\t%s\n", r.Result)
+	fmt.Fprintf(tw, "reason\t%s\n", r.Reason)
+	if r.Error != "" {
+		fmt.Fprintf(tw, "error\t%s (%s)\n", r.Error, r.ErrorKind)
+	}
+	fmt.Fprintf(tw, "role\t%s\n", orDash(r.Role))
+	fmt.Fprintf(tw, "mode\t%s\n", orDash(r.Mode))
+	fmt.Fprintf(tw, "current slot\t%s\n", slotOrDash(r.CurrentSlot))
+	fmt.Fprintf(tw, "local slot\t%s\n", slotOrDash(r.LocalSlot))
+	fmt.Fprintf(tw, "snapshot slot\t%s\n", slotOrDash(r.SnapshotSlot))
+	fmt.Fprintf(tw, "cluster nodes\t%d\n", r.Discovery.ClusterNodes)
+	fmt.Fprintf(tw, "nodes probed\t%d (%d suitable)\n", r.Discovery.Probed, r.Discovery.Suitable)
+	fmt.Fprintf(tw, "candidates tried\t%d\n", r.Discovery.Attempted)
+	if rej := r.Discovery.Rejections; rej != nil {
+		fmt.Fprintf(tw, "rejected\thttp_error=%d latency=%d status_code=%d parse_fail=%d too_old=%d unhealthy=%d version=%d\n",
+			rej.HTTPError, rej.Latency, rej.StatusCode, rej.ParseFail, rej.TooOld, rej.Unhealthy, rej.Version)
+	}
+	if c := r.Candidate; c != nil {
+		fmt.Fprintf(tw, "candidate\t%s %s slot %d, %dms, %d slots old\n", c.RPCURL, c.Type, c.Slot, c.LatencyMs, c.SlotAge)
+	}
+	if len(r.Phases) > 0 {
+		phases := make([]string, len(r.Phases))
+		for i, p := range r.Phases {
+			phases[i] = fmt.Sprintf("%s=%s", p.Name, time.Duration(p.DurationMs)*time.Millisecond)
+		}
+		fmt.Fprintf(tw, "phases\t%s\n", strings.Join(phases, " "))
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	if len(r.Downloads) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "DOWNLOADED\tSIZE\tDURATION\tSPEED\tSOURCE")
+		for _, d := range r.Downloads {
+			size := config.FormatSize(d.Bytes)
+			if d.Existing {
+				size = "existing"
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", d.Path, size, reportDuration(d.DurationMs), formatSpeed(d.SpeedBps), d.Source)
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+	if len(r.Pruned) > 0 {
+		fmt.Fprintln(w)
+		tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "PRUNED\tACTION\tREASON")
+		for _, p := range r.Pruned {
+			fmt.Fprintf(tw, "%s\t%s\t%s\n", p.Path, p.Action, p.Reason)
+		}
+		return tw.Flush()
+	}
+	return nil
+}
+
+func reportDuration(ms int64) string {
+	return (time.Duration(ms) * time.Millisecond).Round(time.Second).String()
+}
+
+func orDash(s string) string {
+	if s == "" {
+		return "-"
+	}
+	return s
+}
+
+func slotOrDash(slot uint64) string {
+	if slot == 0 {
+		return "-"
+	}
+	return fmt.Sprintf("%d", slot)
+}
+
+func init() {
+	reportLastCmd.Flags().StringP("output", "o", "text", "output format: text or json")
+	reportListCmd.Flags().Int("limit", 20, "how many of the newest cycles to list (0 = all)")
+	reportCmd.AddCommand(reportLastCmd, reportListCmd)
+	rootCmd.AddCommand(reportCmd)
+}
@@ -2,12 +2,15 @@ package cmd
 
 import (
 	_ "embed"
+	"fmt"
 	"strings"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/report"
 )
 
 //go:embed version.txt
@@ -23,16 +26,39 @@ var rootCmd = &cobra.Command{
 	SilenceErrors: true,
 	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
 		configPath, _ := cmd.Flags().GetString("config")
+		profile, _ := cmd.Flags().GetString("profile")
 		logLevel, _ := cmd.Flags().GetString("log-level")
 		logDisableTimestamps, _ := cmd.Flags().GetBool("log-disable-timestamps")
+		traceDir, _ := cmd.Flags().GetString("trace-http")
+		traceSampleRate, _ := cmd.Flags().GetFloat64("trace-http-sample-rate")
+		traceBodies, _ := cmd.Flags().GetBool("trace-http-bodies")
 
 		var err error
-		cfg, err = config.NewFromConfigFile(configPath)
+		cfg, err = config.NewFromConfigFileWithProfile(configPath, profile)
 		if err != nil {
 			return err
 		}
 
 		cfg.Log.ConfigureWithLevelString(logLevel, logDisableTimestamps)
+
+		if traceDir != "" {
+			if traceSampleRate <= 0 || traceSampleRate > 1 {
+				return fmt.Errorf("--trace-http-sample-rate must be > 0 and <= 1, got %g", traceSampleRate)
+			}
+			for _, c := range cfg.PerValidator() {
+				c.TraceHTTP = config.TraceHTTP{Directory: traceDir, SampleRate: traceSampleRate, RPCBodies: traceBodies}
+			}
+		}
+
+		// With several validators configured, `run` keeps them all unless
+		// --validator picks one; every other command needs it to
+		if name, _ := cmd.Flags().GetString("validator"); name != "" {
+			if cfg, err = cfg.ForValidator(name); err != nil {
+				return err
+			}
+		} else if len(cfg.Validators) > 0 && cmd != runCmd {
+			return fmt.Errorf("%d validators are configured, pick one with --validator", len(cfg.Validators))
+		}
 		return nil
 	},
 }
@@ -40,16 +66,31 @@ var rootCmd = &cobra.Command{
 func init() {
 	// Set logger defaults early so any errors before config loading are styled correctly.
 	config.SetLoggerDefaults()
+	report.Version = rootCmd.Version
 
 	rootCmd.PersistentFlags().StringP("config", "c", config.DefaultConfigPath(), "path to config file")
+	rootCmd.PersistentFlags().String("profile", "", "merge the settings under profiles.<name> in the config file over the rest, e.g. mainnet or testnet")
 	rootCmd.PersistentFlags().String("log-level", "", "override log level (debug, info, warn, error)")
 	rootCmd.PersistentFlags().Bool("log-disable-timestamps", false, "disable timestamps in log output (overrides log.disable_timestamps)")
+	rootCmd.PersistentFlags().String("validator", "", "with several validators configured, the name of the one to act on (run keeps them all without it)")
+	rootCmd.PersistentFlags().String("trace-http", "", "record sampled HTTP request/response transcripts to this directory (debugging)")
+	rootCmd.PersistentFlags().Float64("trace-http-sample-rate", 1, "fraction of HTTP requests to record with --trace-http (0-1]")
+	rootCmd.PersistentFlags().Bool("trace-http-bodies", false, "also record RPC request/response bodies with --trace-http")
 }
 
 func Execute() error {
 	if err := rootCmd.Execute(); err != nil {
-		log.Fatal("failed to execute", "error", err)
+		log.Error("failed to execute", "error", err)
 		return err
 	}
 	return nil
 }
+
+// ExitCode returns the process exit code for an error from Execute: a
+// distinct code per kind of failed cycle, 1 otherwise.
+func ExitCode(err error) int {
+	if err == nil {
+		return 0
+	}
+	return keeper.KindOf(err).ExitCode()
+}
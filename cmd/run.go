@@ -1,35 +1,95 @@
 package cmd
 
 import (
+	"errors"
+	"fmt"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/spf13/cobra"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/manager"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/schedule"
 )
 
 var runCmd = &cobra.Command{
 	Use:   "run",
-	Short: "Run the snapshot keeper (once or on an interval)",
+	Short: "Run the snapshot keeper (once, on an interval, on a cron schedule or following the tip)",
 	RunE: func(cmd *cobra.Command, args []string) error {
 		intervalStr, _ := cmd.Flags().GetString("on-interval")
+		crons, _ := cmd.Flags().GetStringArray("schedule")
+		once, _ := cmd.Flags().GetBool("once")
+		immediately, _ := cmd.Flags().GetBool("run-immediately")
+		follow, _ := cmd.Flags().GetBool("follow")
 
-		m := manager.New(cfg)
+		if intervalStr != "" && len(crons) > 0 {
+			return fmt.Errorf("--on-interval and --schedule are mutually exclusive")
+		}
+		if follow && (intervalStr != "" || len(crons) > 0 || once) {
+			return fmt.Errorf("--follow can't be combined with --on-interval, --schedule or --once")
+		}
 
-		if intervalStr != "" {
-			duration, err := time.ParseDuration(intervalStr)
-			if err != nil {
-				log.Fatal("invalid interval", "value", intervalStr, "error", err)
-			}
-			return m.RunOnInterval(duration)
+		configs := cfg.PerValidator()
+		if len(configs) == 1 {
+			return runManager(configs[0], intervalStr, crons, once, immediately, follow)
 		}
 
-		return m.RunOnce()
+		// One manager per validator, each with its own schedule and lock
+		log.Info(fmt.Sprintf("keeping snapshots for %d validators", len(configs)))
+		errs := make([]error, len(configs))
+		var wg sync.WaitGroup
+		for i, c := range configs {
+			wg.Go(func() {
+				if err := runManager(c, intervalStr, crons, once, immediately, follow); err != nil {
+					errs[i] = fmt.Errorf("validator %s: %w", c.Name, err)
+				}
+			})
+		}
+		wg.Wait()
+		return errors.Join(errs...)
 	},
 }
 
+// runManager runs the keeper for one validator's config as the flags ask.
+func runManager(c *config.Config, intervalStr string, crons []string, once, immediately, follow bool) error {
+	m := manager.New(c)
+	defer m.Close()
+
+	if follow {
+		return m.RunFollow()
+	}
+	loopOpts := manager.LoopOptions{RunImmediately: immediately}
+
+	if intervalStr != "" {
+		duration, err := time.ParseDuration(intervalStr)
+		if err != nil {
+			log.Fatal("invalid interval", "value", intervalStr, "error", err)
+		}
+		return m.RunOnInterval(duration, loopOpts)
+	}
+
+	if len(crons) > 0 {
+		s, err := schedule.ParseCrons(crons)
+		if err != nil {
+			return fmt.Errorf("--schedule: %w", err)
+		}
+		return m.RunOnSchedule(s, loopOpts)
+	}
+
+	if c.Schedule.Parsed != nil && !once {
+		return m.RunOnSchedule(c.Schedule.Parsed, loopOpts)
+	}
+
+	return m.RunOnce()
+}
+
 func init() {
 	runCmd.Flags().StringP("on-interval", "i", "", "run on an interval (e.g. 4h, 30m)")
+	runCmd.Flags().StringArray("schedule", nil, `run on a cron schedule (e.g. "0 */4 * * *"); repeat to combine schedules, overrides schedule.cron`)
+	runCmd.Flags().Bool("run-immediately", false, "with an interval or schedule, run a cycle at startup before waiting for the first scheduled time")
+	runCmd.Flags().Bool("once", false, "run a single cycle even if schedule.cron is configured")
+	runCmd.Flags().Bool("follow", false, "stay resident and download whenever local snapshots fall more than schedule.follow.max_slots_behind behind the cluster")
 	rootCmd.AddCommand(runCmd)
 }
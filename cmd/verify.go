@@ -0,0 +1,101 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+	"github.com/spf13/cobra"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/verify"
+)
+
+var verifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Check that the newest local snapshots decompress and contain a loadable bank",
+	RunE: func(cmd *cobra.Command, args []string) error {
+		restore, _ := cmd.Flags().GetBool("restore-dryrun")
+		scratchDir, _ := cmd.Flags().GetString("scratch-dir")
+		maxDiskStr, _ := cmd.Flags().GetString("max-disk")
+		maxTime, _ := cmd.Flags().GetDuration("max-time")
+
+		var maxDisk int64
+		if maxDiskStr != "" {
+			var err error
+			if maxDisk, err = config.ParseSize(maxDiskStr); err != nil {
+				return fmt.Errorf("--max-disk: %w", err)
+			}
+		}
+
+		snapshots, err := pruner.GetLocalSnapshots(cfg.Snapshots.Directory, cfg.Snapshots.IncrementalDir())
+		if err != nil {
+			return fmt.Errorf("reading snapshots directory: %w", err)
+		}
+		full := pruner.NewestFullSnapshot(snapshots)
+		if full == nil {
+			return fmt.Errorf("no full snapshot found in %s", cfg.Snapshots.Directory)
+		}
+		toVerify := []pruner.SnapshotFile{*full}
+		var newestInc *pruner.SnapshotFile
+		for i, s := range snapshots {
+			if !s.IsFull && s.BaseSlot == full.Slot && (newestInc == nil || s.Slot > newestInc.Slot) {
+				newestInc = &snapshots[i]
+			}
+		}
+		if newestInc != nil {
+			toVerify = append(toVerify, *newestInc)
+		}
+
+		opts := verify.Options{
+			ScratchDir: scratchDir,
+			Restore:    restore,
+			MaxBytes:   maxDisk,
+			Timeout:    maxTime,
+		}
+		deadline := time.Now().Add(maxTime)
+		for _, s := range toVerify {
+			// Budgets cover the whole run, not each archive
+			if maxTime > 0 {
+				opts.Timeout = time.Until(deadline)
+				if opts.Timeout <= 0 {
+					log.Warn("time budget exhausted, skipping remaining snapshots", "file", s.Path)
+					break
+				}
+			}
+			log.Info("verifying snapshot", "file", s.Path, "slot", s.Slot, "restore_dryrun", restore)
+			result, err := verify.Archive(context.Background(), s.Path, s.Slot, opts)
+			if err != nil {
+				return fmt.Errorf("verifying %s: %w", s.Path, err)
+			}
+			log.Info("snapshot verified",
+				"file", s.Path,
+				"version", result.Version,
+				"entries", result.Entries,
+				"account_files", result.AccountFiles,
+				"bytes", result.Bytes,
+				"complete", !result.BudgetExhausted,
+				"duration", result.Duration.Round(time.Second),
+			)
+			if restore && opts.MaxBytes > 0 {
+				opts.MaxBytes -= result.Bytes
+				if opts.MaxBytes <= 0 {
+					log.Warn("disk budget exhausted, skipping remaining snapshots")
+					break
+				}
+			}
+		}
+		return nil
+	},
+}
+
+func init() {
+	verifyCmd.Flags().Bool("restore-dryrun", false, "unpack snapshots into --scratch-dir to prove they are loadable (default only reads them through)")
+	verifyCmd.Flags().String("scratch-dir", os.TempDir(), "directory for the restore dry run, cleaned up afterwards")
+	verifyCmd.Flags().String("max-disk", "", "max bytes unpacked to --scratch-dir, e.g. 200gb (empty = unlimited)")
+	verifyCmd.Flags().Duration("max-time", time.Hour, "max time spent verifying (0 = unlimited)")
+	rootCmd.AddCommand(verifyCmd)
+}
@@ -0,0 +1,142 @@
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// maxManifestSize bounds how much of an endpoint's response is read.
+const maxManifestSize = 8 << 20
+
+// ErrMismatch is returned when an archive's hash differs from the one the
+// trust endpoint publishes for it.
+var ErrMismatch = errors.New("archive sha256 does not match the trust endpoint")
+
+// ErrUnavailable wraps failures to get a hash from the trust endpoint, as
+// opposed to the archive being wrong.
+var ErrUnavailable = errors.New("trust endpoint unavailable")
+
+// Options configures a Client.
+type Options struct {
+	Headers   http.Header       // added to every request, e.g. Authorization
+	Transport http.RoundTripper // nil uses http.DefaultTransport
+	Timeout   time.Duration     // per request (0 = none)
+}
+
+// Client looks up expected archive hashes from a trust endpoint: an
+// attestation service or a published manifest. The endpoint returns a JSON
+// object mapping slots or archive filenames to hex SHA-256 hashes. A
+// "{slot}" in the URL is replaced with the archive's slot, so services can
+// answer for one archive at a time.
+type Client struct {
+	url     string
+	headers http.Header
+	http    *http.Client
+}
+
+// New creates a Client for the endpoint at url.
+func New(url string, opts Options) *Client {
+	return &Client{
+		url:     url,
+		headers: opts.Headers,
+		http:    &http.Client{Transport: opts.Transport, Timeout: opts.Timeout},
+	}
+}
+
+// Expected returns the lowercase hex SHA-256 the endpoint publishes for the
+// archive, looked up by filename first and then by slot. ok is false when
+// the endpoint doesn't list it.
+func (c *Client) Expected(ctx context.Context, filename string, slot uint64) (sum string, ok bool, err error) {
+	slotKey := strconv.FormatUint(slot, 10)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, strings.ReplaceAll(c.url, "{slot}", slotKey), nil)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	for name, values := range c.headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return "", false, fmt.Errorf("%w: %w", ErrUnavailable, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusNotFound:
+		return "", false, nil
+	case resp.StatusCode != http.StatusOK:
+		return "", false, fmt.Errorf("%w: HTTP %d", ErrUnavailable, resp.StatusCode)
+	}
+
+	var manifest map[string]string
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&manifest); err != nil {
+		return "", false, fmt.Errorf("%w: decoding manifest: %w", ErrUnavailable, err)
+	}
+	for _, key := range []string{filename, slotKey} {
+		if v, found := manifest[key]; found {
+			sum = strings.TrimPrefix(strings.ToLower(strings.TrimSpace(v)), "sha256:")
+			if b, err := hex.DecodeString(sum); err != nil || len(b) != sha256.Size {
+				return "", false, fmt.Errorf("%w: entry %q is not a sha256 hash: %q", ErrUnavailable, key, v)
+			}
+			return sum, true, nil
+		}
+	}
+	return "", false, nil
+}
+
+// FileSHA256 returns the lowercase hex SHA-256 of the file at path.
+func FileSHA256(ctx context.Context, path string) (string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return "", err
+	}
+	defer f.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, ctxReader{ctx, f}); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Verify hashes the archive at path and compares it with the hash the
+// endpoint publishes. found is false when the endpoint doesn't list the
+// archive, in which case nothing was hashed.
+func (c *Client) Verify(ctx context.Context, path, filename string, slot uint64) (found bool, err error) {
+	want, ok, err := c.Expected(ctx, filename, slot)
+	if err != nil || !ok {
+		return false, err
+	}
+	got, err := FileSHA256(ctx, path)
+	if err != nil {
+		return true, fmt.Errorf("hashing %s: %w", path, err)
+	}
+	if got != want {
+		return true, fmt.Errorf("%w: %s has sha256 %s, expected %s", ErrMismatch, filename, got, want)
+	}
+	return true, nil
+}
+
+// ctxReader stops a long read once ctx is done.
+type ctxReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (r ctxReader) Read(p []byte) (int, error) {
+	if err := r.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return r.r.Read(p)
+}
@@ -0,0 +1,104 @@
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func writeArchive(t *testing.T, contents string) (string, string) {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "snapshot-100-Hash.tar.zst")
+	os.WriteFile(path, []byte(contents), 0644)
+	sum := sha256.Sum256([]byte(contents))
+	return path, hex.EncodeToString(sum[:])
+}
+
+func serveManifest(t *testing.T, manifest map[string]string) *httptest.Server {
+	t.Helper()
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		json.NewEncoder(w).Encode(manifest)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestVerify(t *testing.T) {
+	path, sum := writeArchive(t, "archive contents")
+	headers := http.Header{"Authorization": {"Bearer token"}}
+
+	tests := []struct {
+		name      string
+		manifest  map[string]string
+		wantFound bool
+		wantErr   error
+	}{
+		{"match by slot", map[string]string{"100": sum}, true, nil},
+		{"match by filename", map[string]string{"snapshot-100-Hash.tar.zst": "SHA256:" + sum}, true, nil},
+		{"filename takes precedence", map[string]string{"snapshot-100-Hash.tar.zst": sum, "100": "00" + sum[2:]}, true, nil},
+		{"mismatch", map[string]string{"100": "00" + sum[2:]}, true, ErrMismatch},
+		{"not listed", map[string]string{"200": sum}, false, nil},
+		{"malformed hash", map[string]string{"100": "abc"}, false, ErrUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveManifest(t, tt.manifest)
+			c := New(srv.URL, Options{Headers: headers})
+			found, err := c.Verify(context.Background(), path, filepath.Base(path), 100)
+			if found != tt.wantFound {
+				t.Errorf("found = %v, want %v", found, tt.wantFound)
+			}
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr == nil && err != nil) {
+				t.Errorf("err = %v, want %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestExpected_SlotPlaceholder(t *testing.T) {
+	var gotPath string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotPath = r.URL.Path
+		if r.URL.Path != "/attest/100" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Write([]byte(`{"100": "` + hex.EncodeToString(make([]byte, 32)) + `"}`))
+	}))
+	defer srv.Close()
+
+	_, ok, err := New(srv.URL+"/attest/{slot}", Options{}).Expected(context.Background(), "x", 100)
+	if err != nil || !ok {
+		t.Fatalf("expected a hash, got ok=%v err=%v", ok, err)
+	}
+	if gotPath != "/attest/100" {
+		t.Errorf("requested %s", gotPath)
+	}
+
+	_, ok, err = New(srv.URL+"/attest/{slot}", Options{}).Expected(context.Background(), "x", 200)
+	if err != nil || ok {
+		t.Errorf("expected 404 to mean not listed, got ok=%v err=%v", ok, err)
+	}
+}
+
+func TestExpected_Unavailable(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer srv.Close()
+
+	if _, _, err := New(srv.URL, Options{}).Expected(context.Background(), "x", 100); !errors.Is(err, ErrUnavailable) {
+		t.Errorf("expected ErrUnavailable, got %v", err)
+	}
+}
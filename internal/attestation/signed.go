@@ -0,0 +1,208 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"os"
+	"slices"
+	"strings"
+)
+
+// DefaultManifestPath is where sources publish their signed manifest.
+const DefaultManifestPath = "/snapshot-manifest.json"
+
+// ErrUnsigned is returned when a source has no manifest signed by a trusted
+// key that lists the archive, so it can't be downloaded from there.
+var ErrUnsigned = errors.New("no trusted signed manifest lists the archive")
+
+// SignedEnvelope is what a source publishes at its manifest path: a
+// manifest and the Ed25519 signatures over its exact bytes.
+type SignedEnvelope struct {
+	// Manifest is the base64 of a SignedManifest's JSON, as signed
+	Manifest   string      `json:"manifest"`
+	Signatures []Signature `json:"signatures"`
+}
+
+// Signature is one key's signature over a manifest.
+type Signature struct {
+	Pubkey    string `json:"pubkey"`    // base58, like Solana pubkeys
+	Signature string `json:"signature"` // base64
+}
+
+// SignedManifest lists the archives a source serves.
+type SignedManifest struct {
+	Snapshots []SignedEntry `json:"snapshots"`
+}
+
+// SignedEntry is one archive a signed manifest attests to.
+type SignedEntry struct {
+	Filename string `json:"filename"`
+	Slot     uint64 `json:"slot"`
+	SHA256   string `json:"sha256"`
+	Size     int64  `json:"size"`
+}
+
+// SignedClient fetches sources' signed manifests and checks them against a
+// set of trusted keys.
+type SignedClient struct {
+	path    string
+	trusted []ed25519.PublicKey
+	http    *http.Client
+}
+
+// NewSigned creates a SignedClient trusting manifests signed by any of
+// trusted, fetched from path on each source (empty = DefaultManifestPath).
+func NewSigned(path string, trusted []ed25519.PublicKey, opts Options) *SignedClient {
+	if path == "" {
+		path = DefaultManifestPath
+	}
+	return &SignedClient{
+		path:    path,
+		trusted: trusted,
+		http:    &http.Client{Transport: opts.Transport, Timeout: opts.Timeout},
+	}
+}
+
+// Entry fetches the manifest the source at origin publishes and returns its
+// entry for the archive. The manifest must carry a valid signature from a
+// trusted key, and the entry must match filename and slot; otherwise the
+// error wraps ErrUnsigned.
+func (c *SignedClient) Entry(ctx context.Context, origin, filename string, slot uint64) (SignedEntry, error) {
+	url := strings.TrimSuffix(origin, "/") + c.path
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return SignedEntry{}, fmt.Errorf("%w: %w", ErrUnsigned, err)
+	}
+	req.Header.Set("Accept", "application/json")
+	resp, err := c.http.Do(req)
+	if err != nil {
+		return SignedEntry{}, fmt.Errorf("%w: %w", ErrUnsigned, err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return SignedEntry{}, fmt.Errorf("%w: %s answered HTTP %d", ErrUnsigned, url, resp.StatusCode)
+	}
+
+	var envelope SignedEnvelope
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&envelope); err != nil {
+		return SignedEntry{}, fmt.Errorf("%w: decoding %s: %w", ErrUnsigned, url, err)
+	}
+	manifest, err := c.open(envelope)
+	if err != nil {
+		return SignedEntry{}, fmt.Errorf("%w: %s: %w", ErrUnsigned, url, err)
+	}
+	for _, e := range manifest.Snapshots {
+		if e.Filename == filename && e.Slot == slot {
+			if b, err := hex.DecodeString(e.SHA256); err != nil || len(b) != 32 || e.Size <= 0 {
+				return SignedEntry{}, fmt.Errorf("%w: entry for %s has no valid sha256 and size", ErrUnsigned, filename)
+			}
+			e.SHA256 = strings.ToLower(e.SHA256)
+			return e, nil
+		}
+	}
+	return SignedEntry{}, fmt.Errorf("%w: %s doesn't list %s", ErrUnsigned, url, filename)
+}
+
+// open returns the envelope's manifest if a trusted key signed it.
+func (c *SignedClient) open(envelope SignedEnvelope) (SignedManifest, error) {
+	payload, err := base64.StdEncoding.DecodeString(envelope.Manifest)
+	if err != nil {
+		return SignedManifest{}, fmt.Errorf("manifest is not base64: %w", err)
+	}
+	if !c.signedByTrusted(payload, envelope.Signatures) {
+		return SignedManifest{}, errors.New("no valid signature from a trusted key")
+	}
+	var manifest SignedManifest
+	if err := json.Unmarshal(payload, &manifest); err != nil {
+		return SignedManifest{}, fmt.Errorf("decoding signed manifest: %w", err)
+	}
+	return manifest, nil
+}
+
+func (c *SignedClient) signedByTrusted(payload []byte, signatures []Signature) bool {
+	for _, s := range signatures {
+		pub, err := ParsePubkey(s.Pubkey)
+		if err != nil {
+			continue
+		}
+		sig, err := base64.StdEncoding.DecodeString(s.Signature)
+		if err != nil {
+			continue
+		}
+		for _, t := range c.trusted {
+			if t.Equal(pub) && ed25519.Verify(pub, payload, sig) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// VerifyFile checks that the archive at path has the entry's size and
+// SHA-256, returning an error wrapping ErrMismatch if not.
+func (e SignedEntry) VerifyFile(ctx context.Context, path string) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	if info.Size() != e.Size {
+		return fmt.Errorf("%w: %s is %d bytes, signed manifest lists %d", ErrMismatch, e.Filename, info.Size(), e.Size)
+	}
+	got, err := FileSHA256(ctx, path)
+	if err != nil {
+		return fmt.Errorf("hashing %s: %w", path, err)
+	}
+	if got != e.SHA256 {
+		return fmt.Errorf("%w: %s has sha256 %s, signed manifest lists %s", ErrMismatch, e.Filename, got, e.SHA256)
+	}
+	return nil
+}
+
+const base58Alphabet = "123456789ABCDEFGHJKLMNPQRSTUVWXYZabcdefghijkmnopqrstuvwxyz"
+
+// ParsePubkey decodes a base58 Ed25519 public key.
+func ParsePubkey(s string) (ed25519.PublicKey, error) {
+	n := new(big.Int)
+	for _, r := range s {
+		i := strings.IndexRune(base58Alphabet, r)
+		if i < 0 {
+			return nil, fmt.Errorf("%q is not base58", s)
+		}
+		n.Mul(n, big.NewInt(58))
+		n.Add(n, big.NewInt(int64(i)))
+	}
+	// Leading '1's encode leading zero bytes
+	zeros := len(s) - len(strings.TrimLeft(s, "1"))
+	b := append(make([]byte, zeros), n.Bytes()...)
+	if len(b) != ed25519.PublicKeySize {
+		return nil, fmt.Errorf("%q decodes to %d bytes, not a %d-byte public key", s, len(b), ed25519.PublicKeySize)
+	}
+	return ed25519.PublicKey(b), nil
+}
+
+// FormatPubkey encodes an Ed25519 public key in base58.
+func FormatPubkey(pub ed25519.PublicKey) string {
+	n := new(big.Int).SetBytes(pub)
+	var out []byte
+	for n.Sign() > 0 {
+		mod := new(big.Int)
+		n.DivMod(n, big.NewInt(58), mod)
+		out = append(out, base58Alphabet[mod.Int64()])
+	}
+	for _, b := range pub {
+		if b != 0 {
+			break
+		}
+		out = append(out, '1')
+	}
+	slices.Reverse(out)
+	return string(out)
+}
@@ -0,0 +1,100 @@
+package attestation
+
+import (
+	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+// serveSigned publishes manifest at DefaultManifestPath, signed by key.
+func serveSigned(t *testing.T, manifest SignedManifest, key ed25519.PrivateKey, tamper bool) *httptest.Server {
+	t.Helper()
+	payload, _ := json.Marshal(manifest)
+	sig := ed25519.Sign(key, payload)
+	if tamper {
+		payload = append(payload, ' ')
+	}
+	envelope := SignedEnvelope{
+		Manifest:   base64.StdEncoding.EncodeToString(payload),
+		Signatures: []Signature{{Pubkey: FormatPubkey(key.Public().(ed25519.PublicKey)), Signature: base64.StdEncoding.EncodeToString(sig)}},
+	}
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != DefaultManifestPath {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		json.NewEncoder(w).Encode(envelope)
+	}))
+	t.Cleanup(srv.Close)
+	return srv
+}
+
+func TestSignedEntry(t *testing.T) {
+	path, sum := writeArchive(t, "archive contents")
+	entry := SignedEntry{Filename: filepath.Base(path), Slot: 100, SHA256: sum, Size: int64(len("archive contents"))}
+	trustedPub, trustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	tests := []struct {
+		name     string
+		key      ed25519.PrivateKey
+		tamper   bool
+		filename string
+		wantErr  error
+	}{
+		{"signed by trusted key", trustedKey, false, entry.Filename, nil},
+		{"signed by other key", otherKey, false, entry.Filename, ErrUnsigned},
+		{"manifest altered after signing", trustedKey, true, entry.Filename, ErrUnsigned},
+		{"archive not listed", trustedKey, false, "snapshot-100-Other.tar.zst", ErrUnsigned},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			srv := serveSigned(t, SignedManifest{Snapshots: []SignedEntry{entry}}, tt.key, tt.tamper)
+			c := NewSigned("", []ed25519.PublicKey{trustedPub}, Options{})
+			got, err := c.Entry(context.Background(), srv.URL, tt.filename, 100)
+			if !errors.Is(err, tt.wantErr) || (tt.wantErr != nil) != (err != nil) {
+				t.Fatalf("err = %v, want %v", err, tt.wantErr)
+			}
+			if err == nil && got != entry {
+				t.Errorf("entry = %+v, want %+v", got, entry)
+			}
+		})
+	}
+
+	if err := entry.VerifyFile(context.Background(), path); err != nil {
+		t.Errorf("VerifyFile: %v", err)
+	}
+	wrongSize := entry
+	wrongSize.Size++
+	if err := wrongSize.VerifyFile(context.Background(), path); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected ErrMismatch for the wrong size, got %v", err)
+	}
+	wrongHash := entry
+	wrongHash.SHA256 = "00" + sum[2:]
+	if err := wrongHash.VerifyFile(context.Background(), path); !errors.Is(err, ErrMismatch) {
+		t.Errorf("expected ErrMismatch for the wrong hash, got %v", err)
+	}
+}
+
+func TestParsePubkey(t *testing.T) {
+	// The system program's address is 32 zero bytes
+	if pub, err := ParsePubkey("11111111111111111111111111111111"); err != nil || !pub.Equal(ed25519.PublicKey(make([]byte, 32))) {
+		t.Errorf("ParsePubkey(system program) = %x, %v", pub, err)
+	}
+	pub, _, _ := ed25519.GenerateKey(rand.Reader)
+	if got, err := ParsePubkey(FormatPubkey(pub)); err != nil || !got.Equal(pub) {
+		t.Errorf("round trip = %x, %v; want %x", got, err, pub)
+	}
+	for _, bad := range []string{"0OIl", "abc", ""} {
+		if _, err := ParsePubkey(bad); err == nil {
+			t.Errorf("ParsePubkey(%q) should fail", bad)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+// Package audit appends a JSON line for each change the keeper makes to the
+// snapshot and ledger directories, and for each lock event, so what it did
+// can be reconstructed after the fact (e.g. when a validator failed to
+// restart from its snapshots).
+package audit
+
+import (
+	"encoding/json"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("audit") }
+
+// Actions
+const (
+	ActionDownload     = "download"      // a snapshot was downloaded and moved into place
+	ActionRename       = "rename"        // a snapshot was replaced by a file at a new path
+	ActionDelete       = "delete"        // a file was removed
+	ActionUnpack       = "unpack"        // a snapshot was unpacked into the ledger
+	ActionLockAcquire  = "lock_acquire"  // the lock was taken
+	ActionLockTakeover = "lock_takeover" // the lock was taken from a wedged holder
+	ActionLockRelease  = "lock_release"  // the lock was released
+	ActionLockLost     = "lock_lost"     // another instance took the lock over
+)
+
+// Event is one line of the audit log.
+type Event struct {
+	Time   time.Time `json:"time"`
+	PID    int       `json:"pid"`
+	Action string    `json:"action"`
+	Path   string    `json:"path,omitempty"`
+	// From is the previous path of a renamed file
+	From   string `json:"from,omitempty"`
+	Source string `json:"source,omitempty"`
+	Bytes  int64  `json:"bytes,omitempty"`
+	Reason string `json:"reason,omitempty"`
+}
+
+// Log appends events to a JSONL file. A nil Log records nothing.
+type Log struct {
+	mu   sync.Mutex
+	path string
+	now  func() time.Time
+}
+
+// Open returns a Log appending to path, or nil when path is empty. now
+// timestamps events (nil = time.Now).
+func Open(path string, now func() time.Time) *Log {
+	if path == "" {
+		return nil
+	}
+	if now == nil {
+		now = time.Now
+	}
+	return &Log{path: path, now: now}
+}
+
+// Record appends e, filling in its time and PID. The file is opened for each
+// event so it can be rotated from outside; failing to write is logged, not
+// returned, since the change being audited has already happened.
+func (l *Log) Record(e Event) {
+	if l == nil {
+		return
+	}
+	e.Time = l.now().UTC()
+	e.PID = os.Getpid()
+	line, err := json.Marshal(e)
+	if err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	f, err := os.OpenFile(l.path, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0644)
+	if err != nil {
+		logger().Error("failed to open audit log", "path", l.path, "error", err)
+		return
+	}
+	defer f.Close()
+	if _, err := f.Write(append(line, '\n')); err != nil {
+		logger().Error("failed to write audit log", "path", l.path, "error", err)
+	}
+}
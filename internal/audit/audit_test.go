@@ -0,0 +1,53 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestLog_AppendsEvents(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "audit.jsonl")
+	now := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	l := Open(path, func() time.Time { return now })
+
+	l.Record(Event{Action: ActionDownload, Path: "/snapshots/snapshot-100-abc.tar.zst", Source: "http://1.2.3.4:8899", Bytes: 42})
+	// A second Log on the same file appends rather than truncates
+	Open(path, nil).Record(Event{Action: ActionDelete, Path: "/snapshots/snapshot-90-def.tar.zst", Reason: "superseded"})
+
+	f, err := os.Open(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	var events []Event
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		var e Event
+		if err := json.Unmarshal(scanner.Bytes(), &e); err != nil {
+			t.Fatalf("line %q: %v", scanner.Text(), err)
+		}
+		events = append(events, e)
+	}
+
+	if len(events) != 2 {
+		t.Fatalf("got %d events, want 2", len(events))
+	}
+	if e := events[0]; e.Action != ActionDownload || e.Bytes != 42 || !e.Time.Equal(now) || e.PID != os.Getpid() {
+		t.Errorf("first event = %+v", e)
+	}
+	if e := events[1]; e.Action != ActionDelete || e.Reason != "superseded" {
+		t.Errorf("second event = %+v", e)
+	}
+}
+
+func TestLog_NilRecordsNothing(t *testing.T) {
+	l := Open("", nil)
+	if l != nil {
+		t.Fatal("Open(\"\") should return nil")
+	}
+	l.Record(Event{Action: ActionDelete})
+}
@@ -0,0 +1,76 @@
+package clock
+
+import (
+	"testing"
+	"time"
+)
+
+var epoch = time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+
+func TestFake_After(t *testing.T) {
+	c := NewFake(epoch)
+	ch := c.After(time.Hour)
+
+	c.Advance(59 * time.Minute)
+	select {
+	case <-ch:
+		t.Fatal("timer fired early")
+	default:
+	}
+
+	c.Advance(time.Minute)
+	select {
+	case got := <-ch:
+		if !got.Equal(epoch.Add(time.Hour)) {
+			t.Errorf("expected fire time %s, got %s", epoch.Add(time.Hour), got)
+		}
+	default:
+		t.Fatal("timer did not fire")
+	}
+	if !c.Now().Equal(epoch.Add(time.Hour)) {
+		t.Errorf("expected now %s, got %s", epoch.Add(time.Hour), c.Now())
+	}
+}
+
+func TestFake_Ticker(t *testing.T) {
+	c := NewFake(epoch)
+	tk := c.NewTicker(30 * time.Second)
+
+	var ticks int
+	for i := 0; i < 4; i++ {
+		c.Advance(30 * time.Second)
+		select {
+		case <-tk.C():
+			ticks++
+		default:
+		}
+	}
+	if ticks != 4 {
+		t.Errorf("expected 4 ticks, got %d", ticks)
+	}
+
+	tk.Stop()
+	c.Advance(time.Minute)
+	select {
+	case <-tk.C():
+		t.Error("stopped ticker fired")
+	default:
+	}
+}
+
+func TestFake_BlockUntil(t *testing.T) {
+	c := NewFake(epoch)
+	done := make(chan struct{})
+	go func() {
+		<-c.After(time.Minute)
+		close(done)
+	}()
+
+	c.BlockUntil(1)
+	c.Advance(time.Minute)
+	select {
+	case <-done:
+	case <-time.After(time.Second):
+		t.Fatal("waiter was not released")
+	}
+}
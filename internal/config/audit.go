@@ -0,0 +1,8 @@
+package config
+
+// Audit configures the audit log, a JSONL file recording each download,
+// rename, deletion and lock event, for postmortems.
+type Audit struct {
+	// File is where audit events are appended; empty disables the audit log
+	File string `koanf:"file"`
+}
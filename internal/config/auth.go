@@ -0,0 +1,43 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// EndpointAuth adds headers to every request sent to an endpoint, for
+// authenticated RPC providers that reject anonymous requests.
+type EndpointAuth struct {
+	Headers map[string]string `koanf:"headers"`
+	// BearerToken sets "Authorization: Bearer <token>"
+	BearerToken string `koanf:"bearer_token"`
+	// Parsed is nil when no headers are set
+	Parsed http.Header `koanf:"-"`
+}
+
+func (a *EndpointAuth) Validate(field string) error {
+	if len(a.Headers) == 0 && a.BearerToken == "" {
+		return nil
+	}
+
+	h := make(http.Header, len(a.Headers)+1)
+	for name, value := range a.Headers {
+		if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " \t\r\n:") {
+			return fmt.Errorf("%s.headers: invalid header name %q", field, name)
+		}
+		if strings.ContainsAny(value, "\r\n") {
+			return fmt.Errorf("%s.headers.%s: value must not contain newlines", field, name)
+		}
+		h.Set(name, value)
+	}
+	if a.BearerToken != "" {
+		if h.Get("Authorization") != "" {
+			return fmt.Errorf("%s: bearer_token and an Authorization header cannot both be set", field)
+		}
+		h.Set("Authorization", "Bearer "+a.BearerToken)
+	}
+
+	a.Parsed = h
+	return nil
+}
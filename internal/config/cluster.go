@@ -2,6 +2,8 @@ package config
 
 import (
 	"fmt"
+	"net/url"
+	"time"
 
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/constants"
 )
@@ -9,13 +11,96 @@ import (
 type Cluster struct {
 	Name   string `koanf:"name"`
 	RPCURL string `koanf:"rpc_url"`
+	// RPCURLs are additional cluster RPC endpoints, tried in order when
+	// rpc_url (or the cluster default) is unavailable
+	RPCURLs []string `koanf:"rpc_urls"`
+	// ProxyURL routes cluster RPC calls and slack/telegram hook posts through
+	// an HTTP(S) or SOCKS5 proxy, independently of snapshot traffic. Empty uses HTTP(S)_PROXY from the environment.
+	ProxyURL string       `koanf:"proxy_url"`
+	Auth     EndpointAuth `koanf:"auth"`
+	Retry    ClusterRetry `koanf:"retry"`
+	// SlotTime is how long a slot takes on the cluster, for converting
+	// between slots and wall-clock time; empty uses 400ms, and "auto"
+	// estimates it from the cluster's recent performance samples
+	SlotTime string `koanf:"slot_time"`
+	// Parsed
+	ProxyURLParsed *url.URL      `koanf:"-"`
+	SlotTimeDur    time.Duration `koanf:"-"`
+	SlotTimeAuto   bool          `koanf:"-"`
+}
+
+// ClusterRetry retries cluster RPC calls that fail with 429, 5xx or a
+// network error on every endpoint, since public RPCs rate-limit aggressively.
+type ClusterRetry struct {
+	// Attempts is the total number of tries per call (1 = no retries)
+	Attempts  int    `koanf:"attempts"`
+	BaseDelay string `koanf:"base_delay"`
+	MaxDelay  string `koanf:"max_delay"`
+	// Parsed
+	BaseDelayDur time.Duration `koanf:"-"`
+	MaxDelayDur  time.Duration `koanf:"-"`
+}
+
+func (r *ClusterRetry) Validate() error {
+	if r.Attempts < 1 {
+		return fmt.Errorf("cluster.retry.attempts must be >= 1")
+	}
+	if r.BaseDelay != "" {
+		d, err := time.ParseDuration(r.BaseDelay)
+		if err != nil {
+			return fmt.Errorf("cluster.retry.base_delay: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("cluster.retry.base_delay must be > 0")
+		}
+		r.BaseDelayDur = d
+	}
+	if r.MaxDelay != "" {
+		d, err := time.ParseDuration(r.MaxDelay)
+		if err != nil {
+			return fmt.Errorf("cluster.retry.max_delay: %w", err)
+		}
+		if d < r.BaseDelayDur {
+			return fmt.Errorf("cluster.retry.max_delay must be >= cluster.retry.base_delay")
+		}
+		r.MaxDelayDur = d
+	}
+	return nil
 }
 
 func (c *Cluster) Validate() error {
 	if !constants.IsValidCluster(c.Name) {
 		return fmt.Errorf("invalid cluster name %q, must be one of: %v", c.Name, constants.ValidClusters)
 	}
-	return nil
+	for i, u := range c.RPCURLs {
+		if u == "" {
+			return fmt.Errorf("cluster.rpc_urls[%d] must not be empty", i)
+		}
+	}
+	u, err := parseProxyURL("cluster.proxy_url", c.ProxyURL)
+	if err != nil {
+		return err
+	}
+	c.ProxyURLParsed = u
+	d, err := parseSlotTime(c.SlotTime)
+	if err != nil {
+		return err
+	}
+	c.SlotTimeDur = d
+	c.SlotTimeAuto = c.SlotTime == SlotTimeAuto
+	if err := c.Retry.Validate(); err != nil {
+		return err
+	}
+	return c.Auth.Validate("cluster.auth")
+}
+
+// SlotDuration returns cluster.slot_time, or the nominal slot time when it
+// isn't set (e.g. in a config that wasn't validated) or is estimated.
+func (c *Cluster) SlotDuration() time.Duration {
+	if c.SlotTimeDur > 0 {
+		return c.SlotTimeDur
+	}
+	return constants.DefaultSlotTime
 }
 
 func (c *Cluster) EffectiveRPCURL() string {
@@ -27,3 +112,18 @@ func (c *Cluster) EffectiveRPCURL() string {
 	}
 	return ""
 }
+
+// EffectiveRPCURLs returns the primary cluster RPC URL followed by any
+// configured fallbacks, without duplicates.
+func (c *Cluster) EffectiveRPCURLs() []string {
+	var urls []string
+	seen := map[string]bool{}
+	for _, u := range append([]string{c.EffectiveRPCURL()}, c.RPCURLs...) {
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		urls = append(urls, u)
+	}
+	return urls
+}
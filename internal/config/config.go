@@ -4,6 +4,8 @@ import (
 	"fmt"
 	"os"
 	"path/filepath"
+	"strings"
+	"time"
 
 	"github.com/charmbracelet/log"
 	"github.com/knadh/koanf/parsers/yaml"
@@ -12,12 +14,41 @@ import (
 )
 
 type Config struct {
-	Log       Log       `koanf:"log"`
-	Validator Validator `koanf:"validator"`
-	Cluster   Cluster   `koanf:"cluster"`
-	Snapshots Snapshots `koanf:"snapshots"`
-	Hooks     Hooks     `koanf:"hooks"`
-	File      string    `koanf:"-"`
+	Log         Log         `koanf:"log"`
+	Validator   Validator   `koanf:"validator"`
+	Cluster     Cluster     `koanf:"cluster"`
+	Snapshots   Snapshots   `koanf:"snapshots"`
+	Hooks       Hooks       `koanf:"hooks"`
+	Metrics     Metrics     `koanf:"metrics"`
+	Incident    Incident    `koanf:"incident"`
+	// IssueReport is generated after repeated failed cycles
+	IssueReport IssueReport `koanf:"issue_report"`
+	Status      Status      `koanf:"status"`
+	Peers       Peers       `koanf:"peers"`
+	Lock        Lock        `koanf:"lock"`
+	Schedule    Schedule    `koanf:"schedule"`
+	Keeper      Keeper      `koanf:"keeper"`
+	Audit       Audit       `koanf:"audit"`
+	Reports     Reports     `koanf:"reports"`
+	// Validators lists several validators to keep snapshots for, each
+	// with its own settings in place of the top-level ones
+	Validators  []ValidatorEntry `koanf:"validators"`
+	TraceHTTP   TraceHTTP   `koanf:"-"`
+	File        string      `koanf:"-"`
+	// Name is the name of the validators entry this config was expanded
+	// from (empty for a single validator)
+	Name        string      `koanf:"-"`
+	// Profile is the profile under profiles that was merged over the rest of
+	// the file (empty = none)
+	Profile     string      `koanf:"-"`
+	// Effective is the loaded config (defaults merged with the file) as a
+	// nested map keyed like the YAML, before validation
+	Effective map[string]any `koanf:"-"`
+	// Parsed
+	perValidator []*Config
+	// slotDurations are the slot settings given as durations, by key, see
+	// ApplySlotTime
+	slotDurations map[string]time.Duration
 }
 
 func DefaultConfigPath() string {
@@ -33,7 +64,15 @@ func New() *Config {
 }
 
 func NewFromConfigFile(path string) (*Config, error) {
+	return NewFromConfigFileWithProfile(path, "")
+}
+
+// NewFromConfigFileWithProfile loads a config file with the settings under
+// profiles.<profile> merged over the rest of it, e.g. to keep mainnet and
+// testnet in one file. An empty profile loads the file as it is.
+func NewFromConfigFileWithProfile(path, profile string) (*Config, error) {
 	c := New()
+	c.Profile = profile
 	if err := c.LoadFromFile(path); err != nil {
 		return nil, err
 	}
@@ -53,21 +92,118 @@ func (c *Config) LoadFromFile(path string) error {
 		"log.level":                             "info",
 		"log.format":                            "text",
 		"log.disable_timestamps":                false,
+		"log.progress":                          "auto",
+		"validator.client":                      "agave",
 		"validator.rpc_url":                     "http://127.0.0.1:8899",
+		"validator.role_monitor.interval":       "30s",
+		"validator.role_monitor.on_active":      "abort",
+		"validator.role_fallback.enabled":       false,
+		"validator.caught_up.max_slots_behind":  50,
+		"validator.caught_up.skip_downloads":    false,
+		"validator.ledger_directory":            "",
 		"cluster.name":                          "mainnet-beta",
 		"cluster.rpc_url":                       "",
+		"cluster.slot_time":                     "400ms",
+		"cluster.retry.attempts":                3,
+		"cluster.retry.base_delay":              "500ms",
+		"cluster.retry.max_delay":               "10s",
 		"snapshots.discovery.candidates.min_suitable_full":        3,
 		"snapshots.discovery.candidates.min_suitable_incremental": 5,
 		"snapshots.discovery.candidates.sort_order":   "latency",
+		"snapshots.discovery.candidates.ipv6":         "allow",
+		"snapshots.discovery.candidates.remember":     0,
+		"snapshots.discovery.candidates.score_weights.slot_age":   1.0,
+		"snapshots.discovery.candidates.score_weights.latency":    1.0,
+		"snapshots.discovery.candidates.score_weights.reputation": 1.0,
+		"snapshots.discovery.candidates.score_weights.geo":        1.0,
 		"snapshots.discovery.probe.concurrency":       500,
+		"snapshots.discovery.probe.auto_tune":         false,
 		"snapshots.discovery.probe.max_latency":       "100ms",
+		"snapshots.discovery.probe.max_duration":      "0s",
+		"snapshots.discovery.probe.background_concurrency": 20,
+		"snapshots.discovery.probe.samples":           1,
+		"snapshots.discovery.probe.latency_stat":      "median",
+		"snapshots.discovery.probe.health_check":      false,
+		"snapshots.discovery.probe.min_version":       "",
+		"snapshots.discovery.stream":                  false,
+		"snapshots.discovery.trust.min_stake":         0,
+		"snapshots.discovery.geo.country_database":    "",
+		"snapshots.discovery.geo.asn_database":        "",
+		"snapshots.discovery.exclude.self":            true,
 		"snapshots.directory":                      "/mnt/accounts/snapshots",
+		"snapshots.incremental_directory":          "",
 		"snapshots.download.min_speed":             "60mb",
 		"snapshots.download.min_speed_check_delay": "7s",
 		"snapshots.download.timeout":               "30m",
 		"snapshots.download.connections":           8,
+		"snapshots.download.failure_cooldown":      "10m",
+		"snapshots.download.min_slot_improvement":  0,
+		"snapshots.download.delta":                 false,
+		"snapshots.download.stall_timeout":         "30s",
+		"snapshots.download.stall_retries":         3,
+		"snapshots.download.chunk_retries":         3,
+		"snapshots.download.chunk_retry_delay":     "1s",
+		"snapshots.download.adaptive_connections.enabled":             false,
+		"snapshots.download.adaptive_connections.initial_connections": 2,
+		"snapshots.download.adaptive_connections.interval":            "5s",
+		"snapshots.download.adaptive_connections.min_gain":            0.1,
+		"snapshots.download.incremental_chain.enabled":                false,
+		"snapshots.download.incremental_chain.max_slots_behind":       300,
+		"snapshots.download.incremental_chain.max_downloads":          5,
+		"snapshots.download.switch_to_newer.enabled":                  false,
+		"snapshots.download.switch_to_newer.check_interval":           "5m",
+		"snapshots.download.switch_to_newer.min_slot_delta":           20000,
+		"snapshots.download.switch_to_newer.max_progress":             0.25,
+		"snapshots.download.transport.max_idle_conns_per_host":        0,
+		"snapshots.download.transport.idle_conn_timeout":              "90s",
+		"snapshots.download.transport.dial_timeout":                   "30s",
+		"snapshots.download.transport.keep_alive":                     "30s",
+		"snapshots.download.transport.tls_handshake_timeout":          "10s",
+		"snapshots.download.transport.disable_http2":                  false,
+		"snapshots.download.preallocate":                              true,
+		"snapshots.download.direct_io":                                false,
+		"snapshots.download.tmp_directory":                            "",
+		"snapshots.download.stale_tmp_age":                            "1h",
+		"snapshots.download.verify_ranges":                            0,
+		"snapshots.download.disk_check.enabled":                       false,
+		"snapshots.download.disk_check.size":                          "1gb",
 		"snapshots.age.remote.max_slots":            1300,
+		"snapshots.age.remote.near_miss_factor":     0,
 		"snapshots.age.local.max_incremental_slots": 1300,
+		"snapshots.age.local.max_full_slots":        0,
+		"snapshots.epoch.defer_full_slots":          0,
+		"snapshots.epoch.full_after_boundary":       false,
+		"snapshots.leader_schedule.window_slots":    0,
+		"snapshots.leader_schedule.abort_downloads": false,
+		"snapshots.recompress.enabled":              false,
+		"snapshots.recompress.level":                3,
+		"snapshots.unpack.enabled":                  false,
+		"snapshots.retention.quarantine_dir":        "",
+		"snapshots.retention.quarantine_ttl":        "24h",
+		"snapshots.retention.min_free_space":        "",
+		"snapshots.retention.max_total_size":        "",
+		"snapshots.attestation.required":            false,
+		"snapshots.attestation.timeout":             "30s",
+		"snapshots.attestation.manifest_path":       "/snapshot-manifest.json",
+		"metrics.backend":                           "",
+		"metrics.prefix":                            "snapshot_keeper",
+		"issue_report.after_failures":               0,
+		"incident.auto_detect":                      false,
+		"incident.sample_window":                    "10s",
+		"incident.min_slot_rate":                    0.25,
+		"status.listen_address":                     "",
+		"peers.listen_address":                      "",
+		"peers.token":                               "",
+		"lock.ttl":                                  "",
+		"audit.file":                                "",
+		"reports.directory":                         "",
+		"reports.keep":                              100,
+		"keeper.max_cycle_duration":                 "",
+		"schedule.jitter":                           "",
+		"schedule.follow.max_slots_behind":          500,
+		"schedule.follow.check_interval":            "15s",
+		"schedule.follow.min_interval":              "2m",
+		"schedule.follow.max_bandwidth_per_hour":    "",
 	}
 
 	for key, val := range defaults {
@@ -84,14 +220,35 @@ func (c *Config) LoadFromFile(path string) error {
 		}
 	}
 
+	if err := mergeProfile(k, c.Profile); err != nil {
+		return err
+	}
+
+	slotDurations, err := resolveSlotDurations(k)
+	if err != nil {
+		return err
+	}
+
 	if err := k.Unmarshal("", c); err != nil {
 		return fmt.Errorf("unmarshalling config: %w", err)
 	}
+	c.slotDurations = slotDurations
+	c.Effective = k.Raw()
 
 	return nil
 }
 
 func (c *Config) Validate() error {
+	if len(c.Validators) > 0 {
+		if err := c.Log.Validate(); err != nil {
+			return fmt.Errorf("log config: %w", err)
+		}
+		return c.validateValidators()
+	}
+	return c.validate()
+}
+
+func (c *Config) validate() error {
 	if err := c.Log.Validate(); err != nil {
 		return fmt.Errorf("log config: %w", err)
 	}
@@ -104,5 +261,50 @@ func (c *Config) Validate() error {
 	if err := c.Snapshots.Validate(); err != nil {
 		return fmt.Errorf("snapshots config: %w", err)
 	}
+	if err := c.Hooks.Validate(); err != nil {
+		return fmt.Errorf("hooks config: %w", err)
+	}
+	if err := c.Metrics.Validate(); err != nil {
+		return fmt.Errorf("metrics config: %w", err)
+	}
+	if err := c.Incident.Validate(); err != nil {
+		return fmt.Errorf("incident config: %w", err)
+	}
+	if err := c.IssueReport.Validate(); err != nil {
+		return fmt.Errorf("issue report config: %w", err)
+	}
+	if err := c.Status.Validate(); err != nil {
+		return fmt.Errorf("status config: %w", err)
+	}
+	if err := c.Lock.Validate(); err != nil {
+		return fmt.Errorf("lock config: %w", err)
+	}
+	if err := c.Schedule.Validate(); err != nil {
+		return fmt.Errorf("schedule config: %w", err)
+	}
+	if err := c.Keeper.Validate(); err != nil {
+		return fmt.Errorf("keeper config: %w", err)
+	}
+	if err := c.Reports.Validate(); err != nil {
+		return fmt.Errorf("reports config: %w", err)
+	}
+	return nil
+}
+
+// mergeProfile merges the settings under profiles.<name> over the rest of the
+// loaded config, then drops the profiles so they don't unmarshal or show in
+// the effective config.
+func mergeProfile(k *koanf.Koanf, name string) error {
+	defer k.Delete("profiles")
+	if name == "" {
+		return nil
+	}
+	key := "profiles." + name
+	if strings.Contains(name, ".") || !k.Exists(key) {
+		return fmt.Errorf("profile %q not found, must be one of: %v", name, k.MapKeys("profiles"))
+	}
+	if err := k.Merge(k.Cut(key)); err != nil {
+		return fmt.Errorf("merging profile %s: %w", name, err)
+	}
 	return nil
 }
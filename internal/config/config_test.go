@@ -1,9 +1,18 @@
 package config
 
 import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"net/netip"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
 )
 
 func TestLoadFromFile_WithDefaults(t *testing.T) {
@@ -69,6 +78,12 @@ snapshots:
       sort_order: slot_age
   download:
     connections: 16
+  age:
+    remote:
+      near_miss_factor: 1.5
+  epoch:
+    defer_full_slots: 20000
+    full_after_boundary: true
 `
 	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
 		t.Fatal(err)
@@ -94,6 +109,12 @@ snapshots:
 	if c.Snapshots.Download.Connections != 16 {
 		t.Errorf("expected download.connections=16, got %d", c.Snapshots.Download.Connections)
 	}
+	if c.Snapshots.Age.Remote.NearMissFactor != 1.5 {
+		t.Errorf("expected snapshots.age.remote.near_miss_factor=1.5, got %g", c.Snapshots.Age.Remote.NearMissFactor)
+	}
+	if c.Snapshots.Epoch.DeferFullSlots != 20000 || !c.Snapshots.Epoch.FullAfterBoundary {
+		t.Errorf("expected snapshots.epoch overrides, got %+v", c.Snapshots.Epoch)
+	}
 	if c.Snapshots.Discovery.Candidates.SortOrder != "slot_age" {
 		t.Errorf("expected snapshots.discovery.candidates.sort_order=slot_age, got %q", c.Snapshots.Discovery.Candidates.SortOrder)
 	}
@@ -120,10 +141,22 @@ func TestCluster_EffectiveRPCURL(t *testing.T) {
 	}
 }
 
+func TestCluster_EffectiveRPCURLs(t *testing.T) {
+	c := Cluster{
+		Name:    "mainnet-beta",
+		RPCURLs: []string{"https://backup-1.rpc", "https://api.mainnet-beta.solana.com", "https://backup-2.rpc"},
+	}
+	got := c.EffectiveRPCURLs()
+	want := []string{"https://api.mainnet-beta.solana.com", "https://backup-1.rpc", "https://backup-2.rpc"}
+	if strings.Join(got, ",") != strings.Join(want, ",") {
+		t.Errorf("EffectiveRPCURLs() = %v, want %v", got, want)
+	}
+}
+
 func TestValidation_InvalidCluster(t *testing.T) {
 	c := &Config{
-		Log:       Log{Level: "info", Format: "text"},
-		Validator: Validator{RPCURL: "http://localhost:8899", ActiveIdentityPubkey: "test"},
+		Log:       Log{Level: "info", Format: "text", Progress: ProgressAuto},
+		Validator: Validator{Client: ClientAgave, RPCURL: "http://localhost:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{Interval: "30s", OnActive: OnActiveAbort}},
 		Cluster:   Cluster{Name: "invalid-cluster"},
 		Snapshots: Snapshots{
 			Directory: "/tmp",
@@ -186,6 +219,512 @@ func TestValidation_DirectoryIsFile(t *testing.T) {
 	}
 }
 
+func TestValidation_MissingIncrementalDirectory(t *testing.T) {
+	s := &Snapshots{
+		Directory:            t.TempDir(),
+		IncrementalDirectory: "/nonexistent/incremental/path",
+		Discovery: Discovery{
+			Candidates: DiscoveryCandidates{SortOrder: "latency"},
+		},
+	}
+	err := s.Validate()
+	if err == nil || !strings.Contains(err.Error(), "snapshots.incremental_directory") {
+		t.Errorf("expected incremental_directory validation error, got %v", err)
+	}
+}
+
+func TestValidatorValidation(t *testing.T) {
+	roleMonitor := RoleMonitor{Interval: "30s", OnActive: OnActiveAbort}
+	tests := []struct {
+		name      string
+		validator Validator
+		wantErr   bool
+	}{
+		{"agave", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: roleMonitor}, false},
+		{"firedancer", Validator{Client: ClientFiredancer, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: roleMonitor}, false},
+		{"pause on active", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{Interval: "5s", OnActive: OnActivePause}}, false},
+		{"unknown on_active", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{Interval: "30s", OnActive: "wait"}}, true},
+		{"negative max_slots_behind", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: roleMonitor, CaughtUp: CaughtUp{MaxSlotsBehind: -1}}, true},
+		{"role monitor interval defaulted", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{OnActive: OnActiveAbort}}, false},
+		{"role monitor interval too short", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{Interval: "100ms", OnActive: OnActiveAbort}}, true},
+		{"client defaulted", Validator{RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: roleMonitor}, false},
+		{"unknown client", Validator{Client: "jito", RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test"}, true},
+		{"missing identity", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899"}, true},
+		{"admin rpc", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", AdminRPCPath: "/mnt/ledger/admin.rpc", RoleMonitor: roleMonitor}, false},
+		{"admin rpc with firedancer", Validator{Client: ClientFiredancer, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", AdminRPCPath: "/mnt/ledger/admin.rpc", RoleMonitor: roleMonitor}, true},
+		{"identity set only", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkeys: []string{"a", "b"}, RoleMonitor: roleMonitor}, false},
+		{"empty identity in set", Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkeys: []string{"a", ""}, RoleMonitor: roleMonitor}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.validator.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+// writeKeypair writes a solana-keygen style keypair for seed, returning its
+// path and pubkey.
+func writeKeypair(t *testing.T, seed byte) (string, string) {
+	t.Helper()
+	key := ed25519.NewKeyFromSeed(bytes.Repeat([]byte{seed}, ed25519.SeedSize))
+	nums := make([]int, len(key))
+	for i, b := range key {
+		nums[i] = int(b)
+	}
+	data, _ := json.Marshal(nums)
+	path := filepath.Join(t.TempDir(), "identity.json")
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	return path, attestation.FormatPubkey(key.Public().(ed25519.PublicKey))
+}
+
+func TestReadKeypairPubkey(t *testing.T) {
+	path, want := writeKeypair(t, 7)
+	got, err := ReadKeypairPubkey(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != want {
+		t.Errorf("got %s, want %s", got, want)
+	}
+
+	dir := t.TempDir()
+	bad := map[string]string{
+		"short":      "[1,2,3]",
+		"not json":   "not a keypair",
+		"mismatched": "[" + strings.Repeat("1,", 63) + "1]",
+	}
+	for name, content := range bad {
+		p := filepath.Join(dir, name)
+		os.WriteFile(p, []byte(content), 0600)
+		if _, err := ReadKeypairPubkey(p); err == nil {
+			t.Errorf("%s: expected an error", name)
+		}
+	}
+}
+
+func TestValidatorValidation_Defaults(t *testing.T) {
+	v := Validator{RPCURL: "http://127.0.0.1:8899", ActiveIdentityPubkey: "test", RoleMonitor: RoleMonitor{OnActive: OnActiveAbort}}
+	if err := v.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if v.Client != ClientAgave || v.RoleMonitor.IntervalDur != DefaultRoleMonitorInterval {
+		t.Errorf("got client %q and role monitor interval %s, want %q and %s", v.Client, v.RoleMonitor.IntervalDur, ClientAgave, DefaultRoleMonitorInterval)
+	}
+}
+
+func TestValidatorValidation_Keypair(t *testing.T) {
+	path, pubkey := writeKeypair(t, 7)
+	base := Validator{Client: ClientAgave, RPCURL: "http://127.0.0.1:8899", RoleMonitor: RoleMonitor{Interval: "30s", OnActive: OnActiveAbort}}
+
+	v := base
+	v.ActiveIdentityKeypairPath = path
+	if err := v.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if v.ActiveIdentityPubkey != pubkey {
+		t.Errorf("expected the pubkey %s read from the keypair, got %q", pubkey, v.ActiveIdentityPubkey)
+	}
+
+	v = base
+	v.ActiveIdentityKeypairPath = path
+	v.ActiveIdentityPubkey = "OtherPubkey"
+	if err := v.Validate(); err == nil || !strings.Contains(err.Error(), "doesn't match") {
+		t.Errorf("expected a mismatch error, got %v", err)
+	}
+
+	v = base
+	v.ActiveIdentityKeypairPath = filepath.Join(t.TempDir(), "missing.json")
+	if err := v.Validate(); err == nil {
+		t.Error("expected an error for a missing keypair")
+	}
+}
+
+func TestValidator_ActiveIdentities(t *testing.T) {
+	v := Validator{ActiveIdentityPubkey: "a", ActiveIdentityPubkeys: []string{"b", "a", "c"}}
+	if got, want := v.ActiveIdentities(), []string{"a", "b", "c"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestValidation_MaxFullSlots(t *testing.T) {
+	for maxFull, wantErr := range map[int]bool{0: false, 25000: false, 1300: true, 500: true} {
+		s := &Snapshots{
+			Directory: t.TempDir(),
+			Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+			Download:  SnapshotsDownload{Connections: 8},
+			Age: SnapshotsAge{
+				Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300, MaxFullSlots: maxFull},
+			},
+		}
+		if err := s.Validate(); (err != nil) != wantErr {
+			t.Errorf("max_full_slots %d: error = %v, wantErr %v", maxFull, err, wantErr)
+		}
+	}
+}
+
+func TestValidation_RecompressLevel(t *testing.T) {
+	for _, tt := range []struct {
+		recompress Recompress
+		wantErr    bool
+	}{
+		{Recompress{Enabled: true, Level: 3}, false},
+		{Recompress{Enabled: true, Level: 19}, false},
+		{Recompress{Enabled: true, Level: 0}, true},
+		{Recompress{Enabled: true, Level: 22}, true},
+		{Recompress{Enabled: false, Level: 0}, false},
+	} {
+		s := &Snapshots{
+			Directory: t.TempDir(),
+			Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+			Download:  SnapshotsDownload{Connections: 8},
+			Age: SnapshotsAge{
+				Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+			Recompress: tt.recompress,
+		}
+		if err := s.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("recompress %+v: error = %v, wantErr %v", tt.recompress, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidation_Unpack(t *testing.T) {
+	ledger := t.TempDir()
+	for _, tt := range []struct {
+		name      string
+		directory string
+		unpack    Unpack
+		wantErr   bool
+	}{
+		{"disabled", t.TempDir(), Unpack{}, false},
+		{"enabled", t.TempDir(), Unpack{Enabled: true, LedgerPath: ledger}, false},
+		{"archives in the ledger root", ledger, Unpack{Enabled: true, LedgerPath: ledger}, false},
+		{"missing ledger path", t.TempDir(), Unpack{Enabled: true}, true},
+		{"archives in the replaced bank snapshot dir", filepath.Join(ledger, "snapshots"), Unpack{Enabled: true, LedgerPath: ledger}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			os.MkdirAll(tt.directory, 0755)
+			s := &Snapshots{
+				Directory: tt.directory,
+				Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+				Download:  SnapshotsDownload{Connections: 8},
+				Age: SnapshotsAge{
+					Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+					Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+				Unpack: tt.unpack,
+			}
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidation_AdaptiveConnections(t *testing.T) {
+	for _, tt := range []struct {
+		name     string
+		adaptive SnapshotsDownloadAdaptive
+		wantErr  bool
+	}{
+		{"disabled", SnapshotsDownloadAdaptive{}, false},
+		{"enabled", SnapshotsDownloadAdaptive{Enabled: true, InitialConnections: 2, Interval: "5s", MinGain: 0.1}, false},
+		{"initial above connections", SnapshotsDownloadAdaptive{Enabled: true, InitialConnections: 16, Interval: "5s"}, true},
+		{"zero initial", SnapshotsDownloadAdaptive{Enabled: true, Interval: "5s"}, true},
+		{"interval too short", SnapshotsDownloadAdaptive{Enabled: true, InitialConnections: 2, Interval: "100ms"}, true},
+		{"negative gain", SnapshotsDownloadAdaptive{Enabled: true, InitialConnections: 2, Interval: "5s", MinGain: -0.1}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshots{
+				Directory: t.TempDir(),
+				Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+				Download:  SnapshotsDownload{Connections: 8, AdaptiveConnections: tt.adaptive},
+				Age: SnapshotsAge{
+					Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+					Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+			}
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidation_SwitchToNewer(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		sw      SnapshotsDownloadSwitch
+		wantErr bool
+	}{
+		{"disabled", SnapshotsDownloadSwitch{}, false},
+		{"enabled", SnapshotsDownloadSwitch{Enabled: true, CheckInterval: "5m", MinSlotDelta: 20000, MaxProgress: 0.25}, false},
+		{"interval too short", SnapshotsDownloadSwitch{Enabled: true, CheckInterval: "100ms", MinSlotDelta: 20000, MaxProgress: 0.25}, true},
+		{"zero delta", SnapshotsDownloadSwitch{Enabled: true, CheckInterval: "5m", MaxProgress: 0.25}, true},
+		{"zero progress", SnapshotsDownloadSwitch{Enabled: true, CheckInterval: "5m", MinSlotDelta: 20000}, true},
+		{"whole download", SnapshotsDownloadSwitch{Enabled: true, CheckInterval: "5m", MinSlotDelta: 20000, MaxProgress: 1}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshots{
+				Directory: t.TempDir(),
+				Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+				Download:  SnapshotsDownload{Connections: 8, SwitchToNewer: tt.sw},
+				Age: SnapshotsAge{
+					Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+					Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+			}
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestValidation_DiskCheck(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		check   SnapshotsDownloadDiskCheck
+		wantErr bool
+	}{
+		{"disabled", SnapshotsDownloadDiskCheck{}, false},
+		{"enabled", SnapshotsDownloadDiskCheck{Enabled: true, Size: "1gb"}, false},
+		{"no size", SnapshotsDownloadDiskCheck{Enabled: true}, true},
+		{"zero size", SnapshotsDownloadDiskCheck{Enabled: true, Size: "0mb"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshots{
+				Directory: t.TempDir(),
+				Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+				Download:  SnapshotsDownload{Connections: 8, DiskCheck: tt.check},
+				Age: SnapshotsAge{
+					Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+					Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+			}
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if !tt.wantErr && tt.check.Enabled && s.Download.DiskCheck.SizeBytes != 1<<30 {
+				t.Errorf("SizeBytes = %d, want %d", s.Download.DiskCheck.SizeBytes, 1<<30)
+			}
+		})
+	}
+}
+
+func TestValidation_Hooks(t *testing.T) {
+	for _, tt := range []struct {
+		name    string
+		hook    HookCommand
+		wantErr string
+	}{
+		{"command", HookCommand{Name: "a", Cmd: "/bin/true"}, ""},
+		{"command without cmd", HookCommand{Name: "a"}, "hooks.on_skip[0].cmd is required"},
+		{"slack", HookCommand{Type: HookTypeSlack, WebhookURL: "https://hooks.slack.com/services/T/B/X"}, ""},
+		{"slack without webhook", HookCommand{Type: HookTypeSlack}, "webhook_url must be an http(s) URL"},
+		{"telegram", HookCommand{Type: HookTypeTelegram, BotToken: "123:abc", ChatID: "-100"}, ""},
+		{"telegram without chat", HookCommand{Type: HookTypeTelegram, BotToken: "123:abc"}, "chat_id are required"},
+		{"unknown type", HookCommand{Type: "discord"}, `type must be "command", "slack" or "telegram"`},
+		{"when", HookCommand{Cmd: "/bin/true", When: `{{ eq .SnapshotType "full" }}`}, ""},
+		{"bad when", HookCommand{Cmd: "/bin/true", When: "{{ gt .DownloadTimeSec"}, "hooks.on_skip[0].when"},
+		{"secret env", HookCommand{Cmd: "/bin/true", Environment: map[string]string{"TOKEN": "cmd://vault kv get -field=token secret/slack"}}, ""},
+		{"empty secret env", HookCommand{Cmd: "/bin/true", Environment: map[string]string{"TOKEN": "env://"}}, "environment.TOKEN: env reference is missing what to read"},
+		{"secret webhook", HookCommand{Type: HookTypeSlack, WebhookURL: "file:///etc/keeper/slack-webhook"}, ""},
+		{"secret bot token", HookCommand{Type: HookTypeTelegram, BotToken: "file://", ChatID: "-100"}, "bot_token: file reference"},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			h := &Hooks{OnSkip: []HookCommand{tt.hook}}
+			err := h.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Errorf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestValidation_RequestInterval(t *testing.T) {
+	tests := []struct {
+		interval string
+		wantErr  bool
+	}{
+		{"", false},
+		{"250ms", false},
+		{"10s", false},
+		{"-1s", true},
+		{"1m", true},
+		{"soon", true},
+	}
+	for _, tt := range tests {
+		s := &Snapshots{
+			Directory: t.TempDir(),
+			Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+			Download:  SnapshotsDownload{Connections: 8, PerSource: SnapshotsDownloadPerSource{RequestInterval: tt.interval}},
+			Age: SnapshotsAge{
+				Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		}
+		err := s.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("request_interval %q: err = %v, wantErr %v", tt.interval, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidation_Retention(t *testing.T) {
+	snapshotDir := t.TempDir()
+	tests := []struct {
+		name      string
+		retention Retention
+		wantErr   bool
+	}{
+		{"disabled", Retention{}, false},
+		{"quarantine", Retention{QuarantineDir: t.TempDir(), QuarantineTTL: "24h"}, false},
+		{"snapshot directory", Retention{QuarantineDir: snapshotDir, QuarantineTTL: "24h"}, true},
+		{"missing directory", Retention{QuarantineDir: filepath.Join(t.TempDir(), "missing"), QuarantineTTL: "24h"}, true},
+		{"zero ttl", Retention{QuarantineDir: t.TempDir(), QuarantineTTL: "0s"}, true},
+		{"bad ttl", Retention{QuarantineDir: t.TempDir(), QuarantineTTL: "a day"}, true},
+		{"min free space", Retention{MinFreeSpace: "100gb"}, false},
+		{"bad min free space", Retention{MinFreeSpace: "lots"}, true},
+		{"max total size", Retention{MaxTotalSize: "500gb"}, false},
+		{"bad max total size", Retention{MaxTotalSize: "half the disk"}, true},
+	}
+	for _, tt := range tests {
+		s := &Snapshots{
+			Directory: snapshotDir,
+			Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+			Download:  SnapshotsDownload{Connections: 8},
+			Age: SnapshotsAge{
+				Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+			Retention: tt.retention,
+		}
+		err := s.Validate()
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: err = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestValidation_RetentionOtherFilesystem(t *testing.T) {
+	quarantineDir, err := os.MkdirTemp("/dev/shm", "quarantine")
+	if err != nil {
+		t.Skip("no tmpfs to put the quarantine directory on")
+	}
+	defer os.RemoveAll(quarantineDir)
+	snapshotDir := t.TempDir()
+	if checkRenamable(snapshotDir, quarantineDir) == nil {
+		t.Skip("/dev/shm is on the same filesystem as the temp directory")
+	}
+	r := Retention{QuarantineDir: quarantineDir, QuarantineTTL: "24h"}
+	if err := r.validate(snapshotDir); err == nil || !strings.Contains(err.Error(), "same filesystem") {
+		t.Errorf("expected a quarantine_dir on another filesystem to be rejected, got %v", err)
+	}
+	if entries, _ := os.ReadDir(snapshotDir); len(entries) != 0 {
+		t.Errorf("expected the probe file cleaned up, found %v", entries)
+	}
+}
+
+func TestValidation_ScoreSortOrder(t *testing.T) {
+	tests := []struct {
+		name    string
+		order   string
+		weights DiscoveryScoreWeights
+		wantErr bool
+	}{
+		{"score", "score", DiscoveryScoreWeights{SlotAge: 1, Latency: 1, Reputation: 1, Geo: 1}, false},
+		{"latency only", "score", DiscoveryScoreWeights{Latency: 1}, false},
+		{"no weights", "score", DiscoveryScoreWeights{}, true},
+		{"negative weight", "score", DiscoveryScoreWeights{SlotAge: 1, Geo: -1}, true},
+		{"weights ignored by latency", "latency", DiscoveryScoreWeights{}, false},
+		{"unknown order", "random", DiscoveryScoreWeights{}, true},
+	}
+	for _, tt := range tests {
+		d := &Discovery{Candidates: DiscoveryCandidates{SortOrder: tt.order, ScoreWeights: tt.weights}}
+		if err := d.Validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%s: Validate() error = %v, wantErr %v", tt.name, err, tt.wantErr)
+		}
+	}
+}
+
+func TestTransportValidation(t *testing.T) {
+	for _, tt := range []struct {
+		name      string
+		transport Transport
+		wantErr   bool
+	}{
+		{"empty", Transport{}, false},
+		{"tuned", Transport{MaxIdleConnsPerHost: 16, DialTimeout: "5s", KeepAlive: "15s", SocketReadBuffer: "4mb"}, false},
+		{"negative idle conns", Transport{MaxIdleConnsPerHost: -1}, true},
+		{"zero timeout", Transport{TLSHandshakeTimeout: "0s"}, true},
+		{"bad duration", Transport{IdleConnTimeout: "soon"}, true},
+		{"bad size", Transport{SocketWriteBuffer: "lots"}, true},
+		{"buffer too large", Transport{SocketReadBuffer: "4gb"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.transport.validate("snapshots.download.transport"); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	tr := Transport{DialTimeout: "5s", SocketReadBuffer: "4mb"}
+	if err := tr.validate("snapshots.download.transport"); err != nil {
+		t.Fatal(err)
+	}
+	if tr.DialTimeoutDur != 5*time.Second || tr.SocketReadBufferBytes != 4*1024*1024 {
+		t.Errorf("unexpected parsed values: %+v", tr)
+	}
+}
+
+func TestValidation_Attestation(t *testing.T) {
+	for _, tt := range []struct {
+		name        string
+		attestation Attestation
+		wantErr     bool
+	}{
+		{"disabled", Attestation{}, false},
+		{"manifest url", Attestation{URL: "https://attest.example.com/manifest.json", Timeout: "30s"}, false},
+		{"slot placeholder", Attestation{URL: "https://attest.example.com/v1/{slot}", Timeout: "30s"}, false},
+		{"not http", Attestation{URL: "ftp://attest.example.com/manifest.json"}, true},
+		{"bad timeout", Attestation{URL: "https://attest.example.com/manifest.json", Timeout: "0s"}, true},
+		{"bad auth", Attestation{URL: "https://attest.example.com/manifest.json", Auth: EndpointAuth{Headers: map[string]string{"X Token": "abc"}}}, true},
+		{"trusted pubkeys", Attestation{TrustedPubkeys: []string{"Vote111111111111111111111111111111111111111"}, ManifestPath: "/snapshot-manifest.json", Timeout: "30s"}, false},
+		{"not a pubkey", Attestation{TrustedPubkeys: []string{"not-a-pubkey"}, ManifestPath: "/snapshot-manifest.json"}, true},
+		{"relative manifest path", Attestation{TrustedPubkeys: []string{"Vote111111111111111111111111111111111111111"}, ManifestPath: "snapshot-manifest.json"}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			s := &Snapshots{
+				Directory: t.TempDir(),
+				Discovery: Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency"}},
+				Download:  SnapshotsDownload{Connections: 8},
+				Age: SnapshotsAge{
+					Remote: SnapshotsRemoteAge{MaxSlots: 1300},
+					Local:  SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+				Attestation: tt.attestation,
+			}
+			if err := s.Validate(); (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
 func TestValidation_InvalidSortOrder(t *testing.T) {
 	d := &Discovery{
 		Candidates: DiscoveryCandidates{SortOrder: "invalid"},
@@ -195,4 +734,942 @@ func TestValidation_InvalidSortOrder(t *testing.T) {
 	if err == nil {
 		t.Error("expected validation error for invalid sort_order")
 	}
+
+	d.Candidates.SortOrder = "availability"
+	if err := d.Validate(); err != nil {
+		t.Errorf("expected sort_order availability to be valid, got %v", err)
+	}
+}
+
+func TestValidation_IPv6(t *testing.T) {
+	for _, mode := range []string{"", "allow", "prefer", "require", "skip"} {
+		d := &Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency", IPv6: mode}}
+		if err := d.Validate(); err != nil {
+			t.Errorf("ipv6 %q: unexpected error %v", mode, err)
+		}
+	}
+	d := &Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency", IPv6: "only"}}
+	if err := d.Validate(); err == nil {
+		t.Error("expected validation error for invalid ipv6 mode")
+	}
+}
+
+func TestValidation_Remember(t *testing.T) {
+	d := &Discovery{Candidates: DiscoveryCandidates{SortOrder: "latency", Remember: -1}}
+	if err := d.Validate(); err == nil {
+		t.Error("expected validation error for negative remember")
+	}
+}
+
+func TestValidation_InvalidLatencyStat(t *testing.T) {
+	d := &Discovery{
+		Candidates: DiscoveryCandidates{SortOrder: "latency"},
+		Probe:      DiscoveryProbe{MaxLatency: "100ms", Samples: 3, LatencyStat: "p99"},
+	}
+	if err := d.Validate(); err == nil {
+		t.Error("expected validation error for invalid latency_stat")
+	}
+}
+
+func TestValidation_MinVersion(t *testing.T) {
+	for version, wantErr := range map[string]bool{"": false, "2.1.0": false, "v2": false, "2.1.x": true, "latest": true} {
+		d := &Discovery{
+			Candidates: DiscoveryCandidates{SortOrder: "latency"},
+			Probe:      DiscoveryProbe{MaxLatency: "100ms", MinVersion: version},
+		}
+		if err := d.Validate(); (err != nil) != wantErr {
+			t.Errorf("min_version %q: error = %v, wantErr %v", version, err, wantErr)
+		}
+	}
+}
+
+func TestValidation_MaxDuration(t *testing.T) {
+	for value, want := range map[string]time.Duration{"": 0, "0s": 0, "30s": 30 * time.Second} {
+		d := &Discovery{
+			Candidates: DiscoveryCandidates{SortOrder: "latency"},
+			Probe:      DiscoveryProbe{MaxDuration: value},
+		}
+		if err := d.Validate(); err != nil {
+			t.Errorf("max_duration %q: unexpected error %v", value, err)
+		} else if d.Probe.MaxDurationDur != want {
+			t.Errorf("max_duration %q: got %s, want %s", value, d.Probe.MaxDurationDur, want)
+		}
+	}
+	for _, value := range []string{"-1s", "soon"} {
+		d := &Discovery{
+			Candidates: DiscoveryCandidates{SortOrder: "latency"},
+			Probe:      DiscoveryProbe{MaxDuration: value},
+		}
+		if err := d.Validate(); err == nil {
+			t.Errorf("max_duration %q: expected validation error", value)
+		}
+	}
+}
+
+func TestSnapshotsDownloadChainValidation(t *testing.T) {
+	for _, tt := range []struct {
+		chain   SnapshotsDownloadChain
+		wantErr bool
+	}{
+		{SnapshotsDownloadChain{}, false},
+		{SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: 300, MaxDownloads: 5}, false},
+		{SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: -1, MaxDownloads: 5}, true},
+		{SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: 300}, true},
+	} {
+		if err := tt.chain.validate(); (err != nil) != tt.wantErr {
+			t.Errorf("%+v: error = %v, wantErr %v", tt.chain, err, tt.wantErr)
+		}
+	}
+}
+
+func TestDiscoveryTrustValidation(t *testing.T) {
+	valid := DiscoveryTrust{KnownValidators: []string{"7Np41oeYqPefeNQEHSv1UDhYrehxin3NStELsSKCT4K2"}, MinStake: 1.5}
+	if err := valid.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if valid.MinStakeLamports != 1_500_000_000 {
+		t.Errorf("expected 1.5 SOL = 1500000000 lamports, got %d", valid.MinStakeLamports)
+	}
+
+	badPubkey := DiscoveryTrust{KnownValidators: []string{"not-a-pubkey"}}
+	if err := badPubkey.Validate(); err == nil {
+		t.Error("expected error for invalid known validator pubkey")
+	}
+	negative := DiscoveryTrust{MinStake: -1}
+	if err := negative.Validate(); err == nil {
+		t.Error("expected error for negative min_stake")
+	}
+}
+
+func TestTLSValidation(t *testing.T) {
+	dir := t.TempDir()
+	notPEM := filepath.Join(dir, "ca.pem")
+	os.WriteFile(notPEM, []byte("not a certificate"), 0644)
+
+	tests := []struct {
+		name    string
+		tls     TLS
+		wantErr bool
+		parsed  bool
+	}{
+		{"empty", TLS{}, false, false},
+		{"insecure only", TLS{InsecureSkipVerify: true}, false, true},
+		{"missing ca file", TLS{CAFile: filepath.Join(dir, "missing.pem")}, true, false},
+		{"ca file without certs", TLS{CAFile: notPEM}, true, false},
+		{"cert without key", TLS{CertFile: notPEM}, true, false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.tls.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if (tt.tls.Parsed != nil) != tt.parsed {
+				t.Errorf("expected parsed=%v, got %v", tt.parsed, tt.tls.Parsed != nil)
+			}
+		})
+	}
+}
+
+func TestParseProxyURL(t *testing.T) {
+	tests := []struct {
+		raw     string
+		wantErr bool
+		wantNil bool
+	}{
+		{"", false, true},
+		{"http://proxy.internal:3128", false, false},
+		{"socks5://127.0.0.1:1080", false, false},
+		{"ftp://proxy.internal", true, true},
+		{"http://", true, true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.raw, func(t *testing.T) {
+			u, err := parseProxyURL("x.proxy_url", tt.raw)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("parseProxyURL(%q) error = %v, wantErr %v", tt.raw, err, tt.wantErr)
+			}
+			if (u == nil) != tt.wantNil {
+				t.Errorf("parseProxyURL(%q) = %v, wantNil %v", tt.raw, u, tt.wantNil)
+			}
+		})
+	}
+}
+
+func TestEndpointAuthValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		auth    EndpointAuth
+		wantErr bool
+		want    string // expected Authorization header
+	}{
+		{"empty", EndpointAuth{}, false, ""},
+		{"bearer token", EndpointAuth{BearerToken: "abc"}, false, "Bearer abc"},
+		{"custom authorization header", EndpointAuth{Headers: map[string]string{"authorization": "Basic xyz"}}, false, "Basic xyz"},
+		{"bearer and authorization header", EndpointAuth{BearerToken: "abc", Headers: map[string]string{"Authorization": "Basic xyz"}}, true, ""},
+		{"invalid header name", EndpointAuth{Headers: map[string]string{"X Token": "abc"}}, true, ""},
+		{"newline in value", EndpointAuth{Headers: map[string]string{"X-Token": "a\r\nb"}}, true, ""},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.auth.Validate("cluster.auth")
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if got := tt.auth.Parsed.Get("Authorization"); got != tt.want {
+				t.Errorf("expected Authorization %q, got %q", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestIssueReportValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		report  IssueReport
+		wantErr bool
+	}{
+		{"disabled", IssueReport{}, false},
+		{"threshold only", IssueReport{AfterFailures: 3}, false},
+		{"negative threshold", IssueReport{AfterFailures: -1}, true},
+		{"https post url", IssueReport{AfterFailures: 3, PostURL: "https://hooks.example.com/report"}, false},
+		{"invalid post url", IssueReport{AfterFailures: 3, PostURL: "ftp://example.com"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.report.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestClusterRetryValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		retry   ClusterRetry
+		wantErr bool
+	}{
+		{"defaults", ClusterRetry{Attempts: 3, BaseDelay: "500ms", MaxDelay: "10s"}, false},
+		{"no retries", ClusterRetry{Attempts: 1}, false},
+		{"zero attempts", ClusterRetry{Attempts: 0}, true},
+		{"invalid base delay", ClusterRetry{Attempts: 3, BaseDelay: "soon"}, true},
+		{"max below base", ClusterRetry{Attempts: 3, BaseDelay: "2s", MaxDelay: "1s"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.retry.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestIncidentValidation(t *testing.T) {
+	tests := []struct {
+		name     string
+		incident Incident
+		wantErr  bool
+	}{
+		{"disabled ignores settings", Incident{SampleWindow: "soon"}, false},
+		{"manual only", Incident{Manual: true}, false},
+		{"auto detect", Incident{AutoDetect: true, SampleWindow: "10s", MinSlotRate: 0.25}, false},
+		{"invalid window", Incident{AutoDetect: true, SampleWindow: "soon", MinSlotRate: 0.25}, true},
+		{"window too short", Incident{AutoDetect: true, SampleWindow: "500ms", MinSlotRate: 0.25}, true},
+		{"zero rate", Incident{AutoDetect: true, SampleWindow: "10s"}, true},
+		{"rate above nominal", Incident{AutoDetect: true, SampleWindow: "10s", MinSlotRate: 1.5}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.incident.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestOwnershipValidation(t *testing.T) {
+	tests := []struct {
+		name      string
+		ownership Ownership
+		wantErr   bool
+		wantChown bool
+	}{
+		{"unset", Ownership{}, false, false},
+		{"numeric ids", Ownership{User: "1001", Group: "1001"}, false, true},
+		{"user by name", Ownership{User: "root"}, false, true},
+		{"unknown user", Ownership{User: "no-such-user-snapshot-keeper"}, true, false},
+		{"mode", Ownership{Mode: "0640"}, false, false},
+		{"invalid mode", Ownership{Mode: "rw-r-----"}, true, false},
+		{"selinux context", Ownership{SELinuxContext: "system_u:object_r:solana_data_t:s0"}, false, false},
+		{"invalid selinux context", Ownership{SELinuxContext: "solana_data_t"}, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.ownership.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && tt.ownership.Parsed.Chown != tt.wantChown {
+				t.Errorf("expected chown=%v, got %+v", tt.wantChown, tt.ownership.Parsed)
+			}
+		})
+	}
+}
+
+func TestStatusValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		status  Status
+		wantErr bool
+	}{
+		{"disabled", Status{}, false},
+		{"host and port", Status{ListenAddress: "127.0.0.1:9090"}, false},
+		{"port only", Status{ListenAddress: ":9090"}, false},
+		{"missing port", Status{ListenAddress: "127.0.0.1"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.status.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestDiscoveryContentValidation(t *testing.T) {
+	c := DiscoveryContent{Manifests: []string{"https://operator.example/snapshots.json"}, IPFSGateways: []string{"https://ipfs.io"}}
+	if err := c.validate(); err != nil {
+		t.Fatal(err)
+	}
+	for _, c := range []DiscoveryContent{
+		{Manifests: []string{"operator.example/snapshots.json"}},
+		{IPFSGateways: []string{"ipfs://bafy"}},
+	} {
+		if err := c.validate(); err == nil {
+			t.Errorf("expected error for %+v", c)
+		}
+	}
+}
+
+func TestSnapshotsHTTPValidation(t *testing.T) {
+	h := SnapshotsHTTP{UserAgent: "keeper (validator Abc)", Headers: map[string]string{"x-contact": "ops@example.com"}}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if h.Parsed.Get("User-Agent") != "keeper (validator Abc)" || h.Parsed.Get("X-Contact") != "ops@example.com" {
+		t.Errorf("unexpected headers %v", h.Parsed)
+	}
+
+	for name, h := range map[string]SnapshotsHTTP{
+		"user agent twice":    {UserAgent: "a", Headers: map[string]string{"User-Agent": "b"}},
+		"newline":             {UserAgent: "a\r\nX-Injected: 1"},
+		"invalid header name": {Headers: map[string]string{"bad name": "x"}},
+	} {
+		if err := h.Validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+	h = SnapshotsHTTP{}
+	if err := h.Validate(); err != nil || h.Parsed != nil {
+		t.Errorf("expected nothing set by default, got %v, %v", h.Parsed, err)
+	}
+}
+
+func TestPeersValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		peers   Peers
+		wantErr bool
+	}{
+		{"disabled", Peers{}, false},
+		{"serve", Peers{ListenAddress: ":8901", Token: "secret"}, false},
+		{"pull", Peers{URLs: []string{"http://10.0.0.2:8901/"}, Token: "secret"}, false},
+		{"missing token", Peers{ListenAddress: ":8901"}, true},
+		{"missing port", Peers{ListenAddress: "10.0.0.1", Token: "secret"}, true},
+		{"bad scheme", Peers{URLs: []string{"ftp://10.0.0.2"}, Token: "secret"}, true},
+		{"path", Peers{URLs: []string{"http://10.0.0.2:8901/snapshots"}, Token: "secret"}, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := tt.peers.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Errorf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	p := Peers{URLs: []string{"http://10.0.0.2:8901/"}, Token: "secret"}
+	if err := p.Validate(); err != nil || p.URLs[0] != "http://10.0.0.2:8901" {
+		t.Errorf("expected the trailing slash trimmed, got %v, %v", p.URLs, err)
+	}
+}
+
+func TestLogProgressValidation(t *testing.T) {
+	for _, mode := range []string{ProgressAuto, ProgressTTY, ProgressLog, ProgressNone} {
+		l := Log{Level: "info", Format: "json", Progress: mode}
+		if err := l.Validate(); err != nil {
+			t.Errorf("progress %q: unexpected error: %v", mode, err)
+		}
+	}
+	l := Log{Level: "info", Format: "json", Progress: "bar"}
+	if err := l.Validate(); err == nil {
+		t.Error("expected error for unknown progress mode")
+	}
+}
+
+func TestLockValidation(t *testing.T) {
+	tests := []struct {
+		name    string
+		ttl     string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"disabled", "", 0, false},
+		{"zero", "0", 0, false},
+		{"valid", "30m", 30 * time.Minute, false},
+		{"too short", "10s", 0, true},
+		{"invalid", "soon", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			l := Lock{TTL: tt.ttl}
+			err := l.Validate()
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("Validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err == nil && l.TTLDur != tt.want {
+				t.Errorf("TTLDur = %v, want %v", l.TTLDur, tt.want)
+			}
+		})
+	}
+}
+
+func TestKeeperValidation(t *testing.T) {
+	for value, wantErr := range map[string]bool{"": false, "0": false, "45m": false, "30s": true, "-1h": true, "never": true} {
+		k := Keeper{MaxCycleDuration: value}
+		if err := k.Validate(); (err != nil) != wantErr {
+			t.Errorf("max_cycle_duration %q: Validate() error = %v, wantErr %v", value, err, wantErr)
+		}
+	}
+}
+
+func TestReportsValidation(t *testing.T) {
+	for keep, wantErr := range map[int]bool{0: false, 100: false, -1: true} {
+		r := Reports{Directory: "/var/lib/snapshot-keeper/reports", Keep: keep}
+		if err := r.Validate(); (err != nil) != wantErr {
+			t.Errorf("keep %d: Validate() error = %v, wantErr %v", keep, err, wantErr)
+		}
+	}
+}
+
+func TestScheduleValidation(t *testing.T) {
+	for name, content := range map[string]string{
+		"single": "schedule:\n  cron: \"0 */4 * * *\"\n",
+		"list":   "schedule:\n  cron:\n    - \"0 */4 * * 1-5\"\n    - \"0 */12 * * 0,6\"\n",
+	} {
+		t.Run(name, func(t *testing.T) {
+			cfgFile := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c := New()
+			if err := c.LoadFromFile(cfgFile); err != nil {
+				t.Fatal(err)
+			}
+			if err := c.Schedule.Validate(); err != nil {
+				t.Fatal(err)
+			}
+			if c.Schedule.Parsed == nil {
+				t.Error("expected a parsed schedule")
+			}
+		})
+	}
+
+	s := Schedule{Cron: []string{"0 25 * * *"}}
+	if err := s.Validate(); err == nil {
+		t.Error("expected error for invalid cron expression")
+	}
+	s = Schedule{Jitter: "5m"}
+	if err := s.Validate(); err != nil || s.JitterDur != 5*time.Minute {
+		t.Errorf("expected 5m jitter, got %v, %v", s.JitterDur, err)
+	}
+	for _, jitter := range []string{"-1m", "soon"} {
+		s = Schedule{Jitter: jitter}
+		if err := s.Validate(); err == nil {
+			t.Errorf("expected error for jitter %q", jitter)
+		}
+	}
+	s = Schedule{}
+	if err := s.Validate(); err != nil || s.Parsed != nil {
+		t.Errorf("expected no schedule by default, got %v, %v", s.Parsed, err)
+	}
+}
+
+func TestFollowValidation(t *testing.T) {
+	f := Follow{MaxSlotsBehind: 500, CheckInterval: "15s", MinInterval: "2m", MaxBandwidthPerHour: "50gb"}
+	if err := f.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if f.CheckIntervalDur != 15*time.Second || f.MinIntervalDur != 2*time.Minute || f.MaxBandwidthPerHourBytes != 50<<30 {
+		t.Errorf("unexpected parsed follow settings: %+v", f)
+	}
+
+	for name, f := range map[string]Follow{
+		"negative slots":       {MaxSlotsBehind: -1},
+		"short check interval": {CheckInterval: "500ms"},
+		"bad min interval":     {MinInterval: "soon"},
+		"negative min":         {MinInterval: "-1m"},
+		"bad bandwidth":        {MaxBandwidthPerHour: "lots"},
+	} {
+		if err := f.validate(); err == nil {
+			t.Errorf("%s: expected error", name)
+		}
+	}
+}
+
+func TestDiscoveryGeo(t *testing.T) {
+	db := filepath.Join(t.TempDir(), "GeoLite2-Country.mmdb")
+	os.WriteFile(db, nil, 0644)
+
+	for _, tt := range []struct {
+		name    string
+		geo     DiscoveryGeo
+		wantErr bool
+	}{
+		{"disabled", DiscoveryGeo{}, false},
+		{"filters and preferences", DiscoveryGeo{CountryDatabase: db, Continents: []string{"eu"}, PreferCountries: []string{"DE"}}, false},
+		{"missing database", DiscoveryGeo{CountryDatabase: db + ".missing"}, true},
+		{"region without country database", DiscoveryGeo{ASNDatabase: db, Continents: []string{"EU"}}, true},
+		{"asn without asn database", DiscoveryGeo{CountryDatabase: db, ExcludeASNs: []uint32{24940}}, true},
+		{"unknown continent", DiscoveryGeo{CountryDatabase: db, PreferContinents: []string{"XX"}}, true},
+		{"long country code", DiscoveryGeo{CountryDatabase: db, Countries: []string{"DEU"}}, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			if err := tt.geo.validate(); (err != nil) != tt.wantErr {
+				t.Errorf("validate() error = %v, wantErr %v", err, tt.wantErr)
+			}
+		})
+	}
+
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	content := `
+snapshots:
+  discovery:
+    geo:
+      country_database: ` + db + `
+      asn_database: ` + db + `
+      prefer_continents: [eu]
+      exclude_asns: [24940, 16276]
+`
+	os.WriteFile(cfgFile, []byte(content), 0644)
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	g := c.Snapshots.Discovery.Geo
+	if err := g.validate(); err != nil {
+		t.Fatal(err)
+	}
+	if !slices.Equal(g.PreferContinents, []string{"EU"}) || !slices.Equal(g.ExcludeASNs, []uint32{24940, 16276}) {
+		t.Errorf("unexpected geo config %+v", g)
+	}
+}
+
+func TestLoadFromFile_SlotDurations(t *testing.T) {
+	tests := []struct {
+		name       string
+		content    string
+		wantRemote int
+		wantChain  int
+		wantErr    bool
+	}{
+		{"slots", "snapshots:\n  age:\n    remote:\n      max_slots: 900\n", 900, 300, false},
+		{"duration", "snapshots:\n  age:\n    remote:\n      max_slots: 10m\n", 1500, 300, false},
+		{"slot time", "cluster:\n  slot_time: 500ms\nsnapshots:\n  age:\n    remote:\n      max_slots: 10m\n  download:\n    incremental_chain:\n      max_slots_behind: 1m\n", 1200, 120, false},
+		{"invalid", "snapshots:\n  age:\n    remote:\n      max_slots: soon\n", 0, 0, true},
+		{"invalid slot time", "cluster:\n  slot_time: 0s\n", 0, 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			cfgFile := filepath.Join(t.TempDir(), "config.yml")
+			if err := os.WriteFile(cfgFile, []byte(tt.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			c := New()
+			err := c.LoadFromFile(cfgFile)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("LoadFromFile() error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if err != nil {
+				return
+			}
+			if got := c.Snapshots.Age.Remote.MaxSlots; got != tt.wantRemote {
+				t.Errorf("max_slots = %d, want %d", got, tt.wantRemote)
+			}
+			if got := c.Snapshots.Download.IncrementalChain.MaxSlotsBehind; got != tt.wantChain {
+				t.Errorf("incremental_chain.max_slots_behind = %d, want %d", got, tt.wantChain)
+			}
+		})
+	}
+}
+
+func TestApplySlotTime(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	content := "cluster:\n  slot_time: auto\nsnapshots:\n  age:\n    remote:\n      max_slots: 10m\n    local:\n      max_incremental_slots: 1300\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := c.Cluster.Validate(); err != nil || !c.Cluster.SlotTimeAuto {
+		t.Fatalf("slot_time auto: SlotTimeAuto = %v, error = %v", c.Cluster.SlotTimeAuto, err)
+	}
+	if c.Snapshots.Age.Remote.MaxSlots != 1500 {
+		t.Fatalf("max_slots = %d, want 1500 at the nominal 400ms", c.Snapshots.Age.Remote.MaxSlots)
+	}
+
+	if !c.ApplySlotTime(500 * time.Millisecond) {
+		t.Error("ApplySlotTime() = false, want a setting to change")
+	}
+	if c.Snapshots.Age.Remote.MaxSlots != 1200 {
+		t.Errorf("max_slots = %d, want 1200 at 500ms", c.Snapshots.Age.Remote.MaxSlots)
+	}
+	// Settings given in slots are left alone
+	if c.Snapshots.Age.Local.MaxIncrementalSlots != 1300 {
+		t.Errorf("max_incremental_slots = %d, want 1300", c.Snapshots.Age.Local.MaxIncrementalSlots)
+	}
+	if c.ApplySlotTime(500 * time.Millisecond) {
+		t.Error("ApplySlotTime() = true for an unchanged slot time")
+	}
+}
+
+func TestApplySlotTime_KeepsMinimums(t *testing.T) {
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	content := "snapshots:\n  age:\n    remote:\n      max_slots: 1s\n    local:\n      max_incremental_slots: 1300\n      max_full_slots: 10m\n"
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+
+	c.ApplySlotTime(2 * time.Second)
+	if c.Snapshots.Age.Remote.MaxSlots != 1 {
+		t.Errorf("max_slots = %d, want at least 1", c.Snapshots.Age.Remote.MaxSlots)
+	}
+	// 10m is 300 slots at 2s, under max_incremental_slots
+	if c.Snapshots.Age.Local.MaxFullSlots != 1301 {
+		t.Errorf("max_full_slots = %d, want 1301, above max_incremental_slots", c.Snapshots.Age.Local.MaxFullSlots)
+	}
+}
+
+func TestLoadFromFile_Effective(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	content := `
+validator:
+  active_identity_pubkey: "TestPubkey123"
+`
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+
+	validator, ok := c.Effective["validator"].(map[string]any)
+	if !ok {
+		t.Fatalf("expected nested validator section, got %#v", c.Effective["validator"])
+	}
+	if validator["active_identity_pubkey"] != "TestPubkey123" {
+		t.Errorf("expected file value in effective config, got %v", validator["active_identity_pubkey"])
+	}
+	if validator["rpc_url"] != "http://127.0.0.1:8899" {
+		t.Errorf("expected default merged into effective config, got %v", validator["rpc_url"])
+	}
+}
+
+func TestDiscoveryExclude(t *testing.T) {
+	e := DiscoveryExclude{Hosts: []string{"203.0.113.7", "10.0.0.0/24", "::ffff:192.0.2.1", "RPC.example.com"}}
+	if err := e.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("203.0.113.7/32"),
+		netip.MustParsePrefix("10.0.0.0/24"),
+		netip.MustParsePrefix("192.0.2.1/32"),
+	}
+	if !slices.Equal(e.Prefixes, want) {
+		t.Errorf("Prefixes = %v, want %v", e.Prefixes, want)
+	}
+	if !slices.Equal(e.Hostnames, []string{"rpc.example.com"}) {
+		t.Errorf("Hostnames = %v", e.Hostnames)
+	}
+
+	e = DiscoveryExclude{Hosts: []string{"http://rpc.example.com"}}
+	if err := e.Validate(); err == nil {
+		t.Error("expected an error for a URL")
+	}
+
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	os.WriteFile(cfgFile, []byte("snapshots:\n  discovery:\n    exclude:\n      hosts: [203.0.113.7]\n"), 0644)
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if !c.Snapshots.Discovery.Exclude.Self {
+		t.Error("expected discovery.exclude.self to default to true")
+	}
+}
+
+func TestWriteInitialFile(t *testing.T) {
+	snapshots := t.TempDir()
+	path := filepath.Join(t.TempDir(), "keeper", "config.yml")
+	opts := InitOptions{
+		Client:               ClientAgave,
+		ValidatorRPCURL:      "http://127.0.0.1:8899",
+		ActiveIdentityPubkey: "ActivePubkey111",
+		Cluster:              "testnet",
+		SnapshotsDirectory:   snapshots,
+		Notes:                map[string]string{"cluster.name": "detected from the validator's genesis hash"},
+	}
+	if err := WriteInitialFile(path, opts, false); err != nil {
+		t.Fatalf("WriteInitialFile: %v", err)
+	}
+
+	// The generated file loads and validates as written
+	c, err := NewFromConfigFile(path)
+	if err != nil {
+		t.Fatalf("loading generated config: %v", err)
+	}
+	if c.Cluster.Name != "testnet" || c.Validator.ActiveIdentityPubkey != "ActivePubkey111" || c.Snapshots.Directory != snapshots {
+		t.Errorf("generated config doesn't carry the given values: %+v", c)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "# detected from the validator's genesis hash") {
+		t.Errorf("expected the note in the generated config:\n%s", data)
+	}
+
+	if err := WriteInitialFile(path, opts, false); err == nil {
+		t.Error("expected an existing config not to be replaced")
+	}
+	if err := WriteInitialFile(path, opts, true); err != nil {
+		t.Errorf("expected overwrite to replace the config, got %v", err)
+	}
+}
+
+func TestDetectSnapshotsDirectory(t *testing.T) {
+	empty, withArchives := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(withArchives, "snapshot-100-Hash.tar.zst"), nil, 0644)
+
+	if dir, ok := DetectSnapshotsDirectory([]string{"/nonexistent", empty, withArchives}); !ok || dir != withArchives {
+		t.Errorf("DetectSnapshotsDirectory = %q, %v; want %q", dir, ok, withArchives)
+	}
+	if _, ok := DetectSnapshotsDirectory([]string{empty}); ok {
+		t.Error("expected no directory without archives")
+	}
+}
+
+func TestValidators(t *testing.T) {
+	dir := t.TempDir()
+	primary, backup := filepath.Join(dir, "primary"), filepath.Join(dir, "backup")
+	for _, d := range []string{primary, backup} {
+		if err := os.Mkdir(d, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	cfgFile := filepath.Join(dir, "config.yml")
+	content := `
+metrics:
+  tags:
+    region: fra
+schedule:
+  cron: ["0 */4 * * *"]
+validators:
+  - name: primary
+    active_identity_pubkey: "PrimaryPubkey"
+    snapshots_directory: "` + primary + `"
+    status_listen_address: ":9101"
+  - name: backup
+    rpc_url: "http://10.0.0.2:8899"
+    active_identity_pubkey: "BackupPubkey"
+    snapshots_directory: "` + backup + `"
+    schedule: ["30 * * * *"]
+`
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewFromConfigFile(cfgFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	configs := c.PerValidator()
+	if len(configs) != 2 {
+		t.Fatalf("expected 2 validator configs, got %d", len(configs))
+	}
+	p, b := configs[0], configs[1]
+	if p.Name != "primary" || p.Validator.ActiveIdentityPubkey != "PrimaryPubkey" || p.Snapshots.Directory != primary {
+		t.Errorf("unexpected primary config: name %q, identity %q, directory %q", p.Name, p.Validator.ActiveIdentityPubkey, p.Snapshots.Directory)
+	}
+	if p.Validator.RPCURL != "http://127.0.0.1:8899" || b.Validator.RPCURL != "http://10.0.0.2:8899" {
+		t.Errorf("expected the top-level rpc_url unless replaced, got %q and %q", p.Validator.RPCURL, b.Validator.RPCURL)
+	}
+	if p.Status.ListenAddress != ":9101" || b.Status.ListenAddress != "" {
+		t.Errorf("expected per-validator status addresses, got %q and %q", p.Status.ListenAddress, b.Status.ListenAddress)
+	}
+	if !slices.Equal(p.Schedule.Cron, []string{"0 */4 * * *"}) || !slices.Equal(b.Schedule.Cron, []string{"30 * * * *"}) || b.Schedule.Parsed == nil {
+		t.Errorf("expected per-validator schedules, got %v and %v", p.Schedule.Cron, b.Schedule.Cron)
+	}
+	if p.Metrics.Tags["validator"] != "primary" || b.Metrics.Tags["validator"] != "backup" || b.Metrics.Tags["region"] != "fra" {
+		t.Errorf("expected a validator metrics tag per config, got %v and %v", p.Metrics.Tags, b.Metrics.Tags)
+	}
+	if _, ok := c.Metrics.Tags["validator"]; ok {
+		t.Error("expanding validators should not change the top-level metrics tags")
+	}
+
+	if got, err := c.ForValidator("backup"); err != nil || got != b {
+		t.Errorf("ForValidator(backup) = %v, %v", got, err)
+	}
+	if _, err := c.ForValidator("missing"); err == nil {
+		t.Error("expected an error for an unknown validator name")
+	}
+
+	// Without validators the config stands for the one validator
+	single := &Config{}
+	if got := single.PerValidator(); len(got) != 1 || got[0] != single {
+		t.Errorf("expected PerValidator to return the config itself, got %v", got)
+	}
+}
+
+func TestValidation_Validators(t *testing.T) {
+	base := func(t *testing.T) *Config {
+		c := New()
+		if err := c.LoadFromFile(filepath.Join(t.TempDir(), "missing.yml")); err != nil {
+			t.Fatal(err)
+		}
+		c.Validators = []ValidatorEntry{
+			{Name: "a", ActiveIdentityPubkey: "A", SnapshotsDirectory: t.TempDir()},
+			{Name: "b", ActiveIdentityPubkey: "B", SnapshotsDirectory: t.TempDir()},
+		}
+		return c
+	}
+
+	tests := []struct {
+		name    string
+		modify  func(c *Config)
+		wantErr string
+	}{
+		{"valid", func(c *Config) {}, ""},
+		{"missing name", func(c *Config) { c.Validators[1].Name = "" }, "validators[1].name is required"},
+		{"duplicate name", func(c *Config) { c.Validators[1].Name = "a" }, "used more than once"},
+		{"missing identity", func(c *Config) { c.Validators[0].ActiveIdentityPubkey = "" }, "validators[0] (a): validator config: validator.active_identity_pubkey is required"},
+		{"shared directory", func(c *Config) { c.Validators[1].SnapshotsDirectory = c.Validators[0].SnapshotsDirectory + "/" }, "share the directory"},
+		{"shared tmp directory", func(c *Config) { c.Snapshots.Download.TmpDirectory = c.Validators[0].SnapshotsDirectory }, "share the directory"},
+		{"shared listen address", func(c *Config) {
+			c.Validators[0].StatusListenAddress = ":9101"
+			c.Validators[1].PeersListenAddress = ":9101"
+		}, "share the listen address"},
+		{"own admin sockets", func(c *Config) {
+			c.Validator.AdminRPCPath = "/mnt/a/admin.rpc"
+			c.Validators[1].AdminRPCPath = "/mnt/b/admin.rpc"
+		}, ""},
+		{"shared admin socket", func(c *Config) { c.Validator.AdminRPCPath = "/mnt/ledger/admin.rpc" }, "share the admin RPC socket /mnt/ledger/admin.rpc"},
+		{"admin socket in a shared ledger", func(c *Config) {
+			c.Validators[0].LedgerDirectory = "/mnt/ledger"
+			c.Validators[1].AdminRPCPath = "/mnt/ledger/admin.rpc"
+		}, "share the admin RPC socket"},
+		{"top-level status address", func(c *Config) { c.Status.ListenAddress = ":9101" }, "status_listen_address per validator"},
+		{"top-level peers address", func(c *Config) { c.Peers.ListenAddress = ":9102" }, "peers_listen_address per validator"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := base(t)
+			tt.modify(c)
+			err := c.Validate()
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("expected error containing %q, got %v", tt.wantErr, err)
+			}
+		})
+	}
+}
+
+func TestLoadFromFile_Profile(t *testing.T) {
+	dir := t.TempDir()
+	cfgFile := filepath.Join(dir, "config.yml")
+	content := `
+validator:
+  active_identity_pubkey: "MainnetPubkey"
+snapshots:
+  directory: /mnt/mainnet/snapshots
+  age:
+    remote:
+      max_slots: 1300
+profiles:
+  testnet:
+    validator:
+      active_identity_pubkey: "TestnetPubkey"
+    cluster:
+      name: testnet
+    snapshots:
+      directory: /mnt/testnet/snapshots
+      age:
+        remote:
+          max_slots: 3000
+`
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	c := New()
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cluster.Name != "mainnet-beta" || c.Snapshots.Directory != "/mnt/mainnet/snapshots" {
+		t.Errorf("expected the file's settings without a profile, got cluster %q, directory %q", c.Cluster.Name, c.Snapshots.Directory)
+	}
+	if _, ok := c.Effective["profiles"]; ok {
+		t.Error("expected profiles to be left out of the effective config")
+	}
+
+	c = New()
+	c.Profile = "testnet"
+	if err := c.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if c.Cluster.Name != "testnet" || c.Validator.ActiveIdentityPubkey != "TestnetPubkey" || c.Snapshots.Directory != "/mnt/testnet/snapshots" {
+		t.Errorf("expected the profile's settings, got cluster %q, identity %q, directory %q", c.Cluster.Name, c.Validator.ActiveIdentityPubkey, c.Snapshots.Directory)
+	}
+	if c.Snapshots.Age.Remote.MaxSlots != 3000 {
+		t.Errorf("expected the profile's max_slots, got %d", c.Snapshots.Age.Remote.MaxSlots)
+	}
+	// Settings the profile leaves out keep the file's values and defaults
+	if c.Validator.RPCURL != "http://127.0.0.1:8899" || c.Snapshots.Download.Connections != 8 {
+		t.Errorf("expected unset settings to keep defaults, got rpc_url %q, connections %d", c.Validator.RPCURL, c.Snapshots.Download.Connections)
+	}
+
+	c = New()
+	c.Profile = "devnet"
+	if err := c.LoadFromFile(cfgFile); err == nil || !strings.Contains(err.Error(), `profile "devnet" not found, must be one of: [testnet]`) {
+		t.Errorf("expected an unknown profile error, got %v", err)
+	}
 }
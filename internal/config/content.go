@@ -0,0 +1,28 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// DiscoveryContent adds snapshots published by content address, fetched
+// through IPFS gateways or from web seeds, as sources ranked ahead of probed
+// nodes. Experimental.
+type DiscoveryContent struct {
+	// Manifests are URLs of JSON manifests trusted operators publish
+	Manifests []string `koanf:"manifests"`
+	// IPFSGateways fetch CIDs over HTTP, e.g. "https://ipfs.io"
+	IPFSGateways []string `koanf:"ipfs_gateways"`
+}
+
+func (c *DiscoveryContent) validate() error {
+	for field, urls := range map[string][]string{"manifests": c.Manifests, "ipfs_gateways": c.IPFSGateways} {
+		for i, raw := range urls {
+			u, err := url.Parse(raw)
+			if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+				return fmt.Errorf("discovery.content.%s[%d]: %q must be an http or https URL", field, i, raw)
+			}
+		}
+	}
+	return nil
+}
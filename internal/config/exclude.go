@@ -0,0 +1,45 @@
+package config
+
+import (
+	"fmt"
+	"net/netip"
+	"regexp"
+	"strings"
+)
+
+// DiscoveryExclude keeps nodes the operator runs out of the snapshot sources.
+type DiscoveryExclude struct {
+	// Self excludes the local validator: its identities, and nodes at any of
+	// this machine's interface addresses
+	Self bool `koanf:"self"`
+	// Hosts are addresses, CIDR ranges or hostnames of other machines never
+	// to download from, e.g. ones behind the same NAT
+	Hosts []string `koanf:"hosts"`
+	// Parsed
+	Prefixes  []netip.Prefix `koanf:"-"`
+	Hostnames []string       `koanf:"-"`
+}
+
+var hostnameRe = regexp.MustCompile(`^[a-z0-9]([a-z0-9-]*[a-z0-9])?(\.[a-z0-9]([a-z0-9-]*[a-z0-9])?)*$`)
+
+func (e *DiscoveryExclude) Validate() error {
+	e.Prefixes, e.Hostnames = nil, nil
+	for i, h := range e.Hosts {
+		h = strings.TrimSpace(h)
+		if p, err := netip.ParsePrefix(h); err == nil {
+			e.Prefixes = append(e.Prefixes, p.Masked())
+			continue
+		}
+		if ip, err := netip.ParseAddr(h); err == nil {
+			ip = ip.Unmap()
+			e.Prefixes = append(e.Prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+			continue
+		}
+		h = strings.ToLower(h)
+		if !hostnameRe.MatchString(h) {
+			return fmt.Errorf("discovery.exclude.hosts[%d]: %q is not an address, CIDR range or hostname", i, e.Hosts[i])
+		}
+		e.Hostnames = append(e.Hostnames, h)
+	}
+	return nil
+}
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"slices"
+	"strings"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
+)
+
+// DiscoveryGeo locates candidates with local MaxMind databases, to filter
+// and rank them by region and network. Continents and countries are matched
+// by their two-letter codes, e.g. "EU" and "DE".
+type DiscoveryGeo struct {
+	// CountryDatabase is a GeoLite2/GeoIP2 Country or City .mmdb file
+	CountryDatabase string `koanf:"country_database"`
+	// ASNDatabase is a GeoLite2/GeoIP2 ASN .mmdb file
+	ASNDatabase string `koanf:"asn_database"`
+	// Continents and Countries keep only nodes located in one of them
+	// (both empty = anywhere)
+	Continents []string `koanf:"continents"`
+	Countries  []string `koanf:"countries"`
+	// ExcludeASNs never probes nodes in these autonomous systems
+	ExcludeASNs []uint32 `koanf:"exclude_asns"`
+	// PreferContinents and PreferCountries rank nodes located in them ahead
+	// of the rest, before sort_order
+	PreferContinents []string `koanf:"prefer_continents"`
+	PreferCountries  []string `koanf:"prefer_countries"`
+}
+
+// Enabled reports whether any database is configured.
+func (g *DiscoveryGeo) Enabled() bool {
+	return g.CountryDatabase != "" || g.ASNDatabase != ""
+}
+
+func (g *DiscoveryGeo) validate() error {
+	for _, db := range []struct{ field, path string }{
+		{"country_database", g.CountryDatabase},
+		{"asn_database", g.ASNDatabase},
+	} {
+		if db.path == "" {
+			continue
+		}
+		info, err := os.Stat(db.path)
+		if err != nil {
+			return fmt.Errorf("discovery.geo.%s: %w", db.field, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("discovery.geo.%s: %s is a directory", db.field, db.path)
+		}
+	}
+
+	for _, list := range []struct {
+		field string
+		codes *[]string
+	}{
+		{"continents", &g.Continents},
+		{"prefer_continents", &g.PreferContinents},
+		{"countries", &g.Countries},
+		{"prefer_countries", &g.PreferCountries},
+	} {
+		if len(*list.codes) > 0 && g.CountryDatabase == "" {
+			return fmt.Errorf("discovery.geo.%s needs discovery.geo.country_database", list.field)
+		}
+		for i, code := range *list.codes {
+			code = strings.ToUpper(strings.TrimSpace(code))
+			if len(code) != 2 {
+				return fmt.Errorf("discovery.geo.%s[%d]: %q is not a two-letter code", list.field, i, code)
+			}
+			if strings.HasSuffix(list.field, "continents") && !slices.Contains(geoip.Continents, code) {
+				return fmt.Errorf("discovery.geo.%s[%d]: %q is not one of %s", list.field, i, code, strings.Join(geoip.Continents, ", "))
+			}
+			(*list.codes)[i] = code
+		}
+	}
+	if len(g.ExcludeASNs) > 0 && g.ASNDatabase == "" {
+		return fmt.Errorf("discovery.geo.exclude_asns needs discovery.geo.asn_database")
+	}
+	return nil
+}
@@ -0,0 +1,46 @@
+package config
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// SnapshotsHTTP identifies the keeper to snapshot sources, since some
+// operators rate-limit or block anonymous downloads and allow identified
+// ones.
+type SnapshotsHTTP struct {
+	// UserAgent replaces Go's default User-Agent on probes and downloads,
+	// e.g. "snapshot-keeper (validator <pubkey>; ops@example.com)"
+	UserAgent string `koanf:"user_agent"`
+	// Headers are added to every probe and download request
+	Headers map[string]string `koanf:"headers"`
+	// Parsed is nil when nothing is set
+	Parsed http.Header `koanf:"-"`
+}
+
+func (h *SnapshotsHTTP) Validate() error {
+	h.Parsed = nil
+	if h.UserAgent == "" && len(h.Headers) == 0 {
+		return nil
+	}
+	headers := EndpointAuth{Headers: h.Headers}
+	if err := headers.Validate("snapshots.http"); err != nil {
+		return err
+	}
+	parsed := headers.Parsed
+	if parsed == nil {
+		parsed = http.Header{}
+	}
+	if h.UserAgent != "" {
+		if strings.ContainsAny(h.UserAgent, "\r\n") {
+			return fmt.Errorf("snapshots.http.user_agent must not contain newlines")
+		}
+		if parsed.Get("User-Agent") != "" {
+			return fmt.Errorf("snapshots.http: user_agent and a User-Agent header cannot both be set")
+		}
+		parsed.Set("User-Agent", h.UserAgent)
+	}
+	h.Parsed = parsed
+	return nil
+}
@@ -1,16 +1,107 @@
 package config
 
+import (
+	"fmt"
+	"net/url"
+	"text/template"
+)
+
+// Hook types: a command to run, or a message posted to a chat service.
+const (
+	HookTypeCommand  = "command"
+	HookTypeSlack    = "slack"
+	HookTypeTelegram = "telegram"
+)
+
 type HookCommand struct {
 	Name         string            `koanf:"name"`
+	// Type is "command" (the default), "slack" or "telegram"
+	Type         string            `koanf:"type"`
+	// When is a template the hook only runs if it renders "true", e.g.
+	// '{{ eq .SnapshotType "full" }}' (empty = always)
+	When         string            `koanf:"when"`
 	Cmd          string            `koanf:"cmd"`
 	Args         []string          `koanf:"args"`
 	Environment  map[string]string `koanf:"environment"`
 	AllowFailure bool             `koanf:"allow_failure"`
 	StreamOutput bool             `koanf:"stream_output"`
 	Disabled     bool             `koanf:"disabled"`
+	// StdinJSON writes the hook's template data to its stdin as JSON
+	StdinJSON    bool             `koanf:"stdin_json"`
+	// Message is the template of the message slack and telegram hooks post
+	// (empty = a default for the event)
+	Message      string           `koanf:"message"`
+	// WebhookURL is a Slack incoming webhook; Channel optionally overrides
+	// the webhook's channel. WebhookURL, BotToken and Environment values
+	// may be secret references (file://, env:// or cmd://)
+	WebhookURL   string           `koanf:"webhook_url"`
+	Channel      string           `koanf:"channel"`
+	// BotToken and ChatID address a Telegram bot's chat
+	BotToken     string           `koanf:"bot_token"`
+	ChatID       string           `koanf:"chat_id"`
+}
+
+func (h *HookCommand) validate(field string) error {
+	if h.When != "" {
+		if _, err := template.New("").Parse(h.When); err != nil {
+			return fmt.Errorf("%s.when: %w", field, err)
+		}
+	}
+	for name, v := range h.Environment {
+		if err := validateSecretRef(fmt.Sprintf("%s.environment.%s", field, name), v); err != nil {
+			return err
+		}
+	}
+	switch h.Type {
+	case "", HookTypeCommand:
+		if h.Cmd == "" {
+			return fmt.Errorf("%s.cmd is required", field)
+		}
+	case HookTypeSlack:
+		if _, _, ok := ParseSecretRef(h.WebhookURL); ok {
+			return validateSecretRef(field+".webhook_url", h.WebhookURL)
+		}
+		u, err := url.Parse(h.WebhookURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s.webhook_url must be an http(s) URL or a secret reference for slack hooks", field)
+		}
+	case HookTypeTelegram:
+		if h.BotToken == "" || h.ChatID == "" {
+			return fmt.Errorf("%s.bot_token and %s.chat_id are required for telegram hooks", field, field)
+		}
+		return validateSecretRef(field+".bot_token", h.BotToken)
+	default:
+		return fmt.Errorf("%s.type must be %q, %q or %q, got %q", field, HookTypeCommand, HookTypeSlack, HookTypeTelegram, h.Type)
+	}
+	return nil
 }
 
 type Hooks struct {
-	OnSuccess []HookCommand `koanf:"on_success"`
-	OnFailure []HookCommand `koanf:"on_failure"`
+	OnSuccess       []HookCommand `koanf:"on_success"`
+	OnFailure       []HookCommand `koanf:"on_failure"`
+	OnIncidentEnter []HookCommand `koanf:"on_incident_enter"`
+	OnIncidentExit  []HookCommand `koanf:"on_incident_exit"`
+	// OnSkip runs when a cycle had nothing to do, e.g. the validator was
+	// active or the local snapshots were fresh
+	OnSkip          []HookCommand `koanf:"on_skip"`
+}
+
+func (h *Hooks) Validate() error {
+	for _, event := range []struct {
+		name  string
+		hooks []HookCommand
+	}{
+		{"on_success", h.OnSuccess},
+		{"on_failure", h.OnFailure},
+		{"on_incident_enter", h.OnIncidentEnter},
+		{"on_incident_exit", h.OnIncidentExit},
+		{"on_skip", h.OnSkip},
+	} {
+		for i := range event.hooks {
+			if err := event.hooks[i].validate(fmt.Sprintf("hooks.%s[%d]", event.name, i)); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
 }
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Incident configures cluster incident mode, which freezes downloads and
+// pruning so the local known-good snapshots are preserved until the cluster
+// stabilizes.
+type Incident struct {
+	// Manual forces incident mode on. It can also be entered without a config
+	// change by creating the incident marker file in snapshots.directory.
+	Manual bool `koanf:"manual"`
+	// AutoDetect enters incident mode when cluster slot progression stalls
+	AutoDetect bool `koanf:"auto_detect"`
+	// SampleWindow is how long slot progression is measured over
+	SampleWindow string `koanf:"sample_window"`
+	// MinSlotRate is the fraction of the nominal slot rate (one slot per
+	// cluster.slot_time) below which the cluster is considered stalled
+	MinSlotRate float64 `koanf:"min_slot_rate"`
+	// Parsed
+	SampleWindowDur time.Duration `koanf:"-"`
+}
+
+func (i *Incident) Validate() error {
+	if !i.AutoDetect {
+		return nil
+	}
+	d, err := time.ParseDuration(i.SampleWindow)
+	if err != nil {
+		return fmt.Errorf("incident.sample_window: %w", err)
+	}
+	if d < time.Second {
+		return fmt.Errorf("incident.sample_window must be >= 1s")
+	}
+	i.SampleWindowDur = d
+	if i.MinSlotRate <= 0 || i.MinSlotRate > 1 {
+		return fmt.Errorf("incident.min_slot_rate must be > 0 and <= 1, got %g", i.MinSlotRate)
+	}
+	return nil
+}
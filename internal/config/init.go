@@ -0,0 +1,137 @@
+package config
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"text/template"
+)
+
+// InitOptions are the values `config init` writes into a new config file.
+type InitOptions struct {
+	Client               string
+	ValidatorRPCURL      string
+	ActiveIdentityPubkey string
+	Cluster              string
+	SnapshotsDirectory   string
+	LedgerDirectory      string // empty = left commented out
+	// Notes are written as comments after the setting they explain, keyed
+	// by the setting's path, e.g. what was detected where
+	Notes map[string]string
+}
+
+// SnapshotDirectoryCandidates are where validators commonly keep snapshot
+// archives, in the order DetectSnapshotsDirectory checks them.
+var SnapshotDirectoryCandidates = []string{
+	"/mnt/accounts/snapshots",
+	"/mnt/snapshots",
+	"/mnt/ledger",
+	"/mnt/solana/ledger",
+	"/home/sol/ledger",
+	"/home/solana/ledger",
+	"/var/lib/solana/ledger",
+}
+
+// DetectSnapshotsDirectory returns the first of dirs holding snapshot
+// archives, and whether one did.
+func DetectSnapshotsDirectory(dirs []string) (string, bool) {
+	for _, dir := range dirs {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+		for _, e := range entries {
+			name := e.Name()
+			if (strings.HasPrefix(name, "snapshot-") || strings.HasPrefix(name, "incremental-snapshot-")) && strings.Contains(name, ".tar.") {
+				return dir, true
+			}
+		}
+	}
+	return "", false
+}
+
+var initTemplate = template.Must(template.New("config").Funcs(template.FuncMap{
+	"note": func(notes map[string]string, key string) string {
+		if n, ok := notes[key]; ok {
+			return "  # " + n
+		}
+		return ""
+	},
+}).Parse(`# Written by "solana-validator-snapshot-keeper config init". Only the most
+# common settings are listed; every other setting and its default is
+# described in the README's Configuration section.
+
+log:
+  level: info
+  format: text
+  progress: auto  # tty (progress bar), log (periodic lines) or none; auto picks tty on a terminal
+
+validator:
+  client: {{.Client}}  # or "{{if eq .Client "agave"}}firedancer{{else}}agave{{end}}"
+  rpc_url: "{{.ValidatorRPCURL}}"{{note .Notes "validator.rpc_url"}}
+  active_identity_pubkey: "{{.ActiveIdentityPubkey}}"  # the identity the validator votes with when active
+{{- if .LedgerDirectory}}
+  ledger_directory: {{.LedgerDirectory}}  # count the validator's own snapshots there towards freshness
+{{- else}}
+  # ledger_directory: /mnt/ledger  # count the validator's own snapshots there towards freshness
+{{- end}}
+
+cluster:
+  name: "{{.Cluster}}"{{note .Notes "cluster.name"}}
+
+snapshots:
+  directory: "{{.SnapshotsDirectory}}"{{note .Notes "snapshots.directory"}}
+  discovery:
+    candidates:
+      min_suitable_full: 3
+      min_suitable_incremental: 5
+      sort_order: "latency"     # or "slot_age", "score" or "availability"
+    probe:
+      max_latency: 100ms
+  download:
+    min_speed: 60mb             # see "bench network" for a value that suits this link
+    connections: 8
+  age:
+    remote:
+      max_slots: 1300
+    local:
+      max_incremental_slots: 1300
+
+# hooks:
+#   on_success:
+#     - name: notify-slack
+#       cmd: /usr/local/bin/slack-notify.sh
+#       args: ["success", "Downloaded snapshot slot {{"{{"}} .SnapshotSlot {{"}}"}} from {{"{{"}} .SourceNode {{"}}"}}"]
+#       allow_failure: true
+`))
+
+// WriteInitial writes a commented config file with the given values.
+func WriteInitial(w io.Writer, o InitOptions) error {
+	return initTemplate.Execute(w, o)
+}
+
+// WriteInitialFile writes a commented config file to path, creating its
+// directory. An existing file is only replaced with overwrite.
+func WriteInitialFile(path string, o InitOptions, overwrite bool) error {
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return err
+	}
+	flags := os.O_WRONLY | os.O_CREATE | os.O_EXCL
+	if overwrite {
+		flags = os.O_WRONLY | os.O_CREATE | os.O_TRUNC
+	}
+	f, err := os.OpenFile(path, flags, 0644)
+	if os.IsExist(err) {
+		return fmt.Errorf("%s already exists", path)
+	}
+	if err != nil {
+		return err
+	}
+	if err := WriteInitial(f, o); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Keeper configures the snapshot cycle as a whole.
+type Keeper struct {
+	// MaxCycleDuration bounds a whole cycle - discovery, downloads and
+	// hooks - so a pathological one can't run into the next interval
+	// boundary; the cycle then fails and on_failure hooks run (empty or 0 =
+	// unbounded)
+	MaxCycleDuration string `koanf:"max_cycle_duration"`
+	// Parsed
+	MaxCycleDurationDur time.Duration `koanf:"-"`
+}
+
+func (k *Keeper) Validate() error {
+	k.MaxCycleDurationDur = 0
+	if k.MaxCycleDuration == "" {
+		return nil
+	}
+	d, err := time.ParseDuration(k.MaxCycleDuration)
+	if err != nil {
+		return fmt.Errorf("keeper.max_cycle_duration: %w", err)
+	}
+	if d != 0 && d < time.Minute {
+		return fmt.Errorf("keeper.max_cycle_duration must be 0 or >= 1m, got %s", k.MaxCycleDuration)
+	}
+	k.MaxCycleDurationDur = d
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"bytes"
+	"crypto/ed25519"
+	"encoding/json"
+	"fmt"
+	"os"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
+)
+
+// ReadKeypairPubkey returns the base58 pubkey of a Solana keypair file: a
+// JSON array of the 64 bytes of an Ed25519 private key, as written by
+// solana-keygen. The private key isn't kept.
+func ReadKeypairPubkey(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", err
+	}
+	defer clear(data)
+	var key []byte
+	if err := json.Unmarshal(data, &key); err != nil {
+		return "", fmt.Errorf("%s is not a JSON keypair: %w", path, err)
+	}
+	defer clear(key)
+	if len(key) != ed25519.PrivateKeySize {
+		return "", fmt.Errorf("%s holds %d bytes, not a %d-byte keypair", path, len(key), ed25519.PrivateKeySize)
+	}
+	pub := ed25519.NewKeyFromSeed(key[:ed25519.SeedSize]).Public().(ed25519.PublicKey)
+	if !bytes.Equal(pub, key[ed25519.SeedSize:]) {
+		return "", fmt.Errorf("%s is not a valid keypair: its public half doesn't match its secret", path)
+	}
+	return attestation.FormatPubkey(pub), nil
+}
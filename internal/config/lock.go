@@ -0,0 +1,33 @@
+package config
+
+import (
+	"fmt"
+	"time"
+)
+
+// Lock configures the lock file that keeps instances from running cycles
+// concurrently.
+type Lock struct {
+	// TTL is how long the holder may go without progress (its heartbeat
+	// going stale) before another instance takes the lock over; empty or 0
+	// never takes over a live holder
+	TTL string `koanf:"ttl"`
+	// Parsed
+	TTLDur time.Duration `koanf:"-"`
+}
+
+func (l *Lock) Validate() error {
+	if l.TTL == "" {
+		l.TTLDur = 0
+		return nil
+	}
+	d, err := time.ParseDuration(l.TTL)
+	if err != nil {
+		return fmt.Errorf("lock.ttl: %w", err)
+	}
+	if d != 0 && d < time.Minute {
+		return fmt.Errorf("lock.ttl must be 0 or >= 1m, got %s", l.TTL)
+	}
+	l.TTLDur = d
+	return nil
+}
@@ -7,6 +7,14 @@ import (
 	"github.com/charmbracelet/log"
 )
 
+// Download progress modes
+const (
+	ProgressAuto = "auto"
+	ProgressTTY  = "tty"
+	ProgressLog  = "log"
+	ProgressNone = "none"
+)
+
 var (
 	logFormatters = map[string]log.Formatter{
 		"text":   log.TextFormatter,
@@ -23,6 +31,9 @@ type Log struct {
 	Format string `koanf:"format"`
 	// DisableTimestamps turns off timestamps in log output; default false, overridden by --log-disable-timestamps
 	DisableTimestamps bool `koanf:"disable_timestamps"`
+	// Progress is how download progress is shown - one of "auto", "tty", "log" or "none", defaults to auto:
+	// a progress bar when stderr is a terminal and the format is text, periodic log lines otherwise
+	Progress string `koanf:"progress"`
 	// ParsedLevel is the parsed log level
 	ParsedLevel log.Level `koanf:"-"`
 	// ParsedFormat is the parsed log format
@@ -37,6 +48,9 @@ func (l *Log) SetDefaults() {
 	if l.Format == "" {
 		l.Format = "text"
 	}
+	if l.Progress == "" {
+		l.Progress = ProgressAuto
+	}
 }
 
 // Validate validates the log configuration
@@ -52,6 +66,12 @@ func (l *Log) Validate() (err error) {
 		return fmt.Errorf("log.format must be one of text, json, logfmt - got: %s", l.Format)
 	}
 
+	switch l.Progress {
+	case ProgressAuto, ProgressTTY, ProgressLog, ProgressNone:
+	default:
+		return fmt.Errorf("log.progress must be one of auto, tty, log, none - got: %s", l.Progress)
+	}
+
 	return nil
 }
 
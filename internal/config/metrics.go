@@ -0,0 +1,37 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// Metrics configures where cycle and download metrics are sent.
+type Metrics struct {
+	// Backend is one of "" (disabled), "statsd" or "influxdb"
+	Backend string `koanf:"backend"`
+	// Address is host:port for statsd, or a udp:// or http(s):// write URL for influxdb
+	Address string `koanf:"address"`
+	// Prefix is prepended to every metric name
+	Prefix string `koanf:"prefix"`
+	// Tags are added to every metric
+	Tags map[string]string `koanf:"tags"`
+}
+
+func (m *Metrics) Validate() error {
+	switch m.Backend {
+	case "":
+		return nil
+	case "statsd":
+		if m.Address == "" {
+			return fmt.Errorf("metrics.address is required for the statsd backend")
+		}
+	case "influxdb":
+		u, err := url.Parse(m.Address)
+		if err != nil || (u.Scheme != "udp" && u.Scheme != "http" && u.Scheme != "https") {
+			return fmt.Errorf("metrics.address must be a udp://, http:// or https:// URL for the influxdb backend, got %q", m.Address)
+		}
+	default:
+		return fmt.Errorf("metrics.backend must be \"statsd\" or \"influxdb\", got %q", m.Backend)
+	}
+	return nil
+}
@@ -0,0 +1,86 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"strconv"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/ownership"
+)
+
+// Ownership sets the owner, permissions and SELinux label of downloaded
+// snapshot files, for validators running as another user or under a
+// confined SELinux policy.
+type Ownership struct {
+	// User and Group are names or numeric ids (empty = unchanged)
+	User  string `koanf:"user"`
+	Group string `koanf:"group"`
+	// Mode is an octal permission string, e.g. "0640" (empty = unchanged)
+	Mode string `koanf:"mode"`
+	// SELinuxContext is set as the security.selinux xattr (empty = unchanged)
+	SELinuxContext string `koanf:"selinux_context"`
+	// Parsed
+	Parsed ownership.Options `koanf:"-"`
+}
+
+// selinuxContextRe matches user:role:type with an optional MLS/MCS range.
+var selinuxContextRe = regexp.MustCompile(`^[A-Za-z0-9_.]+:[A-Za-z0-9_.]+:[A-Za-z0-9_.]+(:[A-Za-z0-9_.,:\-]+)?$`)
+
+func (o *Ownership) Validate() error {
+	o.Parsed = ownership.Options{UID: -1, GID: -1, SELinuxContext: o.SELinuxContext}
+
+	if o.User != "" {
+		uid, err := lookupID(o.User, func(name string) (string, error) {
+			u, err := user.Lookup(name)
+			if err != nil {
+				return "", err
+			}
+			return u.Uid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("snapshots.ownership.user: %w", err)
+		}
+		o.Parsed.UID, o.Parsed.Chown = uid, true
+	}
+	if o.Group != "" {
+		gid, err := lookupID(o.Group, func(name string) (string, error) {
+			g, err := user.LookupGroup(name)
+			if err != nil {
+				return "", err
+			}
+			return g.Gid, nil
+		})
+		if err != nil {
+			return fmt.Errorf("snapshots.ownership.group: %w", err)
+		}
+		o.Parsed.GID, o.Parsed.Chown = gid, true
+	}
+	if o.Mode != "" {
+		mode, err := strconv.ParseUint(o.Mode, 8, 32)
+		if err != nil || mode == 0 || mode > 0777 {
+			return fmt.Errorf("snapshots.ownership.mode must be octal permissions like \"0640\", got %q", o.Mode)
+		}
+		o.Parsed.Mode = os.FileMode(mode)
+	}
+	if o.SELinuxContext != "" && !selinuxContextRe.MatchString(o.SELinuxContext) {
+		return fmt.Errorf("snapshots.ownership.selinux_context must look like \"system_u:object_r:type_t:s0\", got %q", o.SELinuxContext)
+	}
+	return nil
+}
+
+// lookupID accepts a numeric id as is, otherwise resolves the name.
+func lookupID(nameOrID string, lookup func(string) (string, error)) (int, error) {
+	if id, err := strconv.Atoi(nameOrID); err == nil {
+		if id < 0 {
+			return 0, fmt.Errorf("invalid id %d", id)
+		}
+		return id, nil
+	}
+	idStr, err := lookup(nameOrID)
+	if err != nil {
+		return 0, err
+	}
+	return strconv.Atoi(idStr)
+}
@@ -0,0 +1,54 @@
+package config
+
+import (
+	"fmt"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// Peers shares snapshots between the operator's own keepers: each serves its
+// local archives on ListenAddress, and the keepers at URLs are probed before
+// the cluster and preferred as sources.
+type Peers struct {
+	// ListenAddress is host:port to serve local snapshots to peers on
+	// (empty = disabled)
+	ListenAddress string `koanf:"listen_address"`
+	// URLs are other keepers' peer addresses, e.g. "http://10.0.0.2:8901"
+	URLs []string `koanf:"urls"`
+	// Token is the shared secret peers send as a bearer token
+	Token string `koanf:"token"`
+}
+
+// Enabled reports whether this keeper serves or pulls from peers.
+func (p *Peers) Enabled() bool {
+	return p.ListenAddress != "" || len(p.URLs) > 0
+}
+
+func (p *Peers) Validate() error {
+	if !p.Enabled() {
+		return nil
+	}
+	if p.Token == "" {
+		return fmt.Errorf("peers.token is required with peers.listen_address or peers.urls")
+	}
+	if p.ListenAddress != "" {
+		if _, _, err := net.SplitHostPort(p.ListenAddress); err != nil {
+			return fmt.Errorf("peers.listen_address: %w", err)
+		}
+	}
+	for i, raw := range p.URLs {
+		u, err := url.Parse(raw)
+		if err != nil {
+			return fmt.Errorf("peers.urls[%d]: %w", i, err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("peers.urls[%d]: %q must be an http or https URL", i, raw)
+		}
+		if u.Path != "" && u.Path != "/" {
+			return fmt.Errorf("peers.urls[%d]: %q must not have a path", i, raw)
+		}
+		p.URLs[i] = strings.TrimSuffix(raw, "/")
+	}
+	return nil
+}
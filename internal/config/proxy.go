@@ -0,0 +1,27 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// parseProxyURL validates an explicit proxy URL. An empty value returns nil so
+// that callers fall back to the HTTP_PROXY/HTTPS_PROXY/NO_PROXY environment.
+func parseProxyURL(field, raw string) (*url.URL, error) {
+	if raw == "" {
+		return nil, nil
+	}
+	u, err := url.Parse(raw)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", field, err)
+	}
+	switch u.Scheme {
+	case "http", "https", "socks5", "socks5h":
+	default:
+		return nil, fmt.Errorf("%s must be an http://, https:// or socks5:// URL, got %q", field, raw)
+	}
+	if u.Host == "" {
+		return nil, fmt.Errorf("%s: missing host in %q", field, raw)
+	}
+	return u, nil
+}
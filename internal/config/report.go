@@ -0,0 +1,32 @@
+package config
+
+import (
+	"fmt"
+	"net/url"
+)
+
+// IssueReport configures the diagnostic report generated after repeated
+// failed cycles, ready to paste into a GitHub issue.
+type IssueReport struct {
+	// AfterFailures generates a report once this many consecutive cycles have
+	// failed (0 = disabled)
+	AfterFailures int `koanf:"after_failures"`
+	// Directory the report is written to (empty = snapshots.directory)
+	Directory string `koanf:"directory"`
+	// PostURL optionally receives the report as a JSON POST, e.g. a webhook
+	PostURL string       `koanf:"post_url"`
+	Auth    EndpointAuth `koanf:"auth"`
+}
+
+func (r *IssueReport) Validate() error {
+	if r.AfterFailures < 0 {
+		return fmt.Errorf("issue_report.after_failures must be >= 0, got %d", r.AfterFailures)
+	}
+	if r.PostURL != "" {
+		u, err := url.Parse(r.PostURL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("issue_report.post_url must be an http:// or https:// URL, got %q", r.PostURL)
+		}
+	}
+	return r.Auth.Validate("issue_report.auth")
+}
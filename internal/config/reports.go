@@ -0,0 +1,20 @@
+package config
+
+import "fmt"
+
+// Reports configures the per-cycle report files reviewed with the report
+// command.
+type Reports struct {
+	// Directory is where a JSON report is written after each cycle; empty
+	// disables cycle reports
+	Directory string `koanf:"directory"`
+	// Keep is how many of the newest reports are kept; 0 keeps all
+	Keep int `koanf:"keep"`
+}
+
+func (r *Reports) Validate() error {
+	if r.Keep < 0 {
+		return fmt.Errorf("reports.keep must be >= 0, got %d", r.Keep)
+	}
+	return nil
+}
@@ -0,0 +1,107 @@
+package config
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/schedule"
+)
+
+// Schedule configures when `run` cycles in interval mode.
+type Schedule struct {
+	// Cron lists cron expressions; `run` without --on-interval or --schedule
+	// cycles at the earliest next time of any of them (empty = run once)
+	Cron []string `koanf:"cron"`
+	// Jitter delays each interval or scheduled run by a random duration up
+	// to this long, so a fleet of keepers doesn't hit the same snapshot
+	// sources at the same moment (empty or 0 = none)
+	Jitter string `koanf:"jitter"`
+	// Follow tunes `run --follow`
+	Follow Follow `koanf:"follow"`
+	// Parsed
+	Parsed    schedule.Schedule `koanf:"-"`
+	JitterDur time.Duration     `koanf:"-"`
+}
+
+func (s *Schedule) Validate() error {
+	s.JitterDur = 0
+	if s.Jitter != "" {
+		d, err := time.ParseDuration(s.Jitter)
+		if err != nil {
+			return fmt.Errorf("schedule.jitter: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("schedule.jitter must be >= 0, got %s", s.Jitter)
+		}
+		s.JitterDur = d
+	}
+
+	if err := s.Follow.validate(); err != nil {
+		return err
+	}
+
+	s.Parsed = nil
+	if len(s.Cron) == 0 {
+		return nil
+	}
+	parsed, err := schedule.ParseCrons(s.Cron)
+	if err != nil {
+		return fmt.Errorf("schedule.cron: %w", err)
+	}
+	s.Parsed = parsed
+	return nil
+}
+
+// Follow keeps a standby's snapshots near the tip with `run --follow`: the
+// local snapshots' freshness is checked every CheckInterval, and a cycle runs
+// whenever they are more than MaxSlotsBehind slots behind the cluster.
+type Follow struct {
+	MaxSlotsBehind int    `koanf:"max_slots_behind"`
+	CheckInterval  string `koanf:"check_interval"`
+	// MinInterval is the least time between the starts of two cycles
+	MinInterval string `koanf:"min_interval"`
+	// MaxBandwidthPerHour holds cycles back once this much was downloaded in
+	// the last hour, e.g. "50gb" (empty = no cap)
+	MaxBandwidthPerHour string `koanf:"max_bandwidth_per_hour"`
+	// Parsed
+	CheckIntervalDur         time.Duration `koanf:"-"`
+	MinIntervalDur           time.Duration `koanf:"-"`
+	MaxBandwidthPerHourBytes int64         `koanf:"-"`
+}
+
+func (f *Follow) validate() error {
+	if f.MaxSlotsBehind < 0 {
+		return fmt.Errorf("schedule.follow.max_slots_behind must be >= 0, got %d", f.MaxSlotsBehind)
+	}
+	f.CheckIntervalDur = 0
+	if f.CheckInterval != "" {
+		d, err := time.ParseDuration(f.CheckInterval)
+		if err != nil {
+			return fmt.Errorf("schedule.follow.check_interval: %w", err)
+		}
+		if d < time.Second {
+			return fmt.Errorf("schedule.follow.check_interval must be >= 1s, got %s", f.CheckInterval)
+		}
+		f.CheckIntervalDur = d
+	}
+	f.MinIntervalDur = 0
+	if f.MinInterval != "" {
+		d, err := time.ParseDuration(f.MinInterval)
+		if err != nil {
+			return fmt.Errorf("schedule.follow.min_interval: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("schedule.follow.min_interval must be >= 0, got %s", f.MinInterval)
+		}
+		f.MinIntervalDur = d
+	}
+	f.MaxBandwidthPerHourBytes = 0
+	if f.MaxBandwidthPerHour != "" {
+		n, err := ParseSize(f.MaxBandwidthPerHour)
+		if err != nil {
+			return fmt.Errorf("schedule.follow.max_bandwidth_per_hour: %w", err)
+		}
+		f.MaxBandwidthPerHourBytes = n
+	}
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Schemes of secret references, which hook environment values, Slack webhook
+// URLs and Telegram bot tokens may hold in place of the secret itself. They
+// are resolved each time the hook runs.
+const (
+	SecretSchemeFile = "file://" // the contents of a file
+	SecretSchemeEnv  = "env://"  // an environment variable of the keeper
+	SecretSchemeCmd  = "cmd://"  // the output of a command, e.g. "cmd://vault kv get -field=token secret/slack"
+)
+
+// ParseSecretRef splits a secret reference into its scheme and target; ok is
+// false for a plain value.
+func ParseSecretRef(v string) (scheme, target string, ok bool) {
+	for _, scheme := range []string{SecretSchemeFile, SecretSchemeEnv, SecretSchemeCmd} {
+		if target, found := strings.CutPrefix(v, scheme); found {
+			return scheme, target, true
+		}
+	}
+	return "", "", false
+}
+
+// validateSecretRef checks that a secret reference names what to read.
+func validateSecretRef(field, v string) error {
+	scheme, target, ok := ParseSecretRef(v)
+	if ok && strings.TrimSpace(target) == "" {
+		return fmt.Errorf("%s: %s reference is missing what to read", field, strings.TrimSuffix(scheme, "://"))
+	}
+	return nil
+}
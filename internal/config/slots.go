@@ -0,0 +1,134 @@
+package config
+
+import (
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/knadh/koanf/v2"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/constants"
+)
+
+// SlotTimeAuto as cluster.slot_time estimates the slot time from the
+// cluster's recent performance samples.
+const SlotTimeAuto = "auto"
+
+// slotSettings are counted in slots but may instead be given as a duration,
+// e.g. "10m", which is converted with cluster.slot_time.
+var slotSettings = []string{
+	"validator.caught_up.max_slots_behind",
+	"snapshots.download.incremental_chain.max_slots_behind",
+	"snapshots.age.remote.max_slots",
+	"snapshots.age.local.max_incremental_slots",
+	"snapshots.age.local.max_full_slots",
+	"snapshots.epoch.defer_full_slots",
+	"snapshots.leader_schedule.window_slots",
+	"schedule.follow.max_slots_behind",
+}
+
+// slotSetting returns the field holding the slot setting key.
+func (c *Config) slotSetting(key string) *int {
+	switch key {
+	case "validator.caught_up.max_slots_behind":
+		return &c.Validator.CaughtUp.MaxSlotsBehind
+	case "snapshots.download.incremental_chain.max_slots_behind":
+		return &c.Snapshots.Download.IncrementalChain.MaxSlotsBehind
+	case "snapshots.age.remote.max_slots":
+		return &c.Snapshots.Age.Remote.MaxSlots
+	case "snapshots.age.local.max_incremental_slots":
+		return &c.Snapshots.Age.Local.MaxIncrementalSlots
+	case "snapshots.age.local.max_full_slots":
+		return &c.Snapshots.Age.Local.MaxFullSlots
+	case "snapshots.epoch.defer_full_slots":
+		return &c.Snapshots.Epoch.DeferFullSlots
+	case "snapshots.leader_schedule.window_slots":
+		return &c.Snapshots.LeaderSchedule.WindowSlots
+	case "schedule.follow.max_slots_behind":
+		return &c.Schedule.Follow.MaxSlotsBehind
+	}
+	return nil
+}
+
+func parseSlotTime(s string) (time.Duration, error) {
+	if s == "" || s == SlotTimeAuto {
+		return constants.DefaultSlotTime, nil
+	}
+	d, err := time.ParseDuration(s)
+	if err != nil {
+		return 0, fmt.Errorf("cluster.slot_time must be a duration or %q: %w", SlotTimeAuto, err)
+	}
+	if d <= 0 {
+		return 0, fmt.Errorf("cluster.slot_time must be > 0, got %s", s)
+	}
+	return d, nil
+}
+
+// resolveSlotDurations replaces slot settings given as durations with the
+// number of slots they span, before the config is unmarshalled, and returns
+// the durations by key.
+func resolveSlotDurations(k *koanf.Koanf) (map[string]time.Duration, error) {
+	slotTime, err := parseSlotTime(k.String("cluster.slot_time"))
+	if err != nil {
+		return nil, err
+	}
+	durations := map[string]time.Duration{}
+	for _, key := range slotSettings {
+		s, ok := k.Get(key).(string)
+		if !ok || s == "" {
+			continue
+		}
+		if _, err := strconv.Atoi(s); err == nil {
+			continue
+		}
+		d, err := time.ParseDuration(s)
+		if err == nil && d < 0 {
+			err = fmt.Errorf("%s is negative", s)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("%s must be a number of slots or a duration: %w", key, err)
+		}
+		durations[key] = d
+		if err := k.Set(key, durationSlots(d, slotTime)); err != nil {
+			return nil, fmt.Errorf("setting %s: %w", key, err)
+		}
+	}
+	return durations, nil
+}
+
+// durationSlots is how many slots d spans at slotTime. A positive duration
+// spans at least one slot, so a short one or a slow slot time can't turn a
+// setting off or below its minimum.
+func durationSlots(d, slotTime time.Duration) int {
+	slots := int(d / slotTime)
+	if d > 0 {
+		slots = max(slots, 1)
+	}
+	return slots
+}
+
+// ApplySlotTime converts the slot settings given as durations again with
+// slotTime, e.g. an estimate of the cluster's current slot time, keeping
+// them within what Validate accepts. It reports whether any setting
+// changed.
+func (c *Config) ApplySlotTime(slotTime time.Duration) bool {
+	if slotTime <= 0 {
+		return false
+	}
+	changed := false
+	for key, d := range c.slotDurations {
+		field := c.slotSetting(key)
+		if slots := durationSlots(d, slotTime); field != nil && *field != slots {
+			*field = slots
+			changed = true
+		}
+	}
+	// max_full_slots must stay above max_incremental_slots when only one of
+	// them scales with the slot time
+	local := &c.Snapshots.Age.Local
+	if _, ok := c.slotDurations["snapshots.age.local.max_full_slots"]; ok && local.MaxFullSlots > 0 && local.MaxFullSlots <= local.MaxIncrementalSlots {
+		local.MaxFullSlots = local.MaxIncrementalSlots + 1
+		changed = true
+	}
+	return changed
+}
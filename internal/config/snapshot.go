@@ -1,46 +1,333 @@
 package config
 
 import (
+	"crypto/ed25519"
 	"fmt"
+	"net/url"
 	"os"
 	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
 	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
 )
 
 type Discovery struct {
 	Candidates DiscoveryCandidates `koanf:"candidates"`
 	Probe      DiscoveryProbe      `koanf:"probe"`
+	Trust      DiscoveryTrust      `koanf:"trust"`
+	Geo        DiscoveryGeo        `koanf:"geo"`
+	Exclude    DiscoveryExclude    `koanf:"exclude"`
+	Content    DiscoveryContent    `koanf:"content"`
+	// Stream starts downloading from the first suitable node while probing continues
+	Stream bool `koanf:"stream"`
 }
 
 type DiscoveryCandidates struct {
 	MinSuitableFull        int    `koanf:"min_suitable_full"`
 	MinSuitableIncremental int    `koanf:"min_suitable_incremental"`
 	SortOrder              string `koanf:"sort_order"`
+	// IPv6 is "allow" (any family), "prefer" (rank IPv6 nodes first),
+	// "require" (IPv6 nodes only) or "skip" (no IPv6 nodes)
+	IPv6 string `koanf:"ipv6"`
+	// Remember keeps this many of the best suitable candidates across runs
+	// and probes them first; when min_suitable of them still are, the rest
+	// of the cluster isn't probed (0 = disabled)
+	Remember int `koanf:"remember"`
+	// ScoreWeights weighs the criteria of sort_order "score"
+	ScoreWeights DiscoveryScoreWeights `koanf:"score_weights"`
+}
+
+// DiscoveryScoreWeights weighs slot age, latency, reputation (how reliably a
+// source served snapshots before) and geo proximity (being in a preferred
+// region) in sort_order "score".
+type DiscoveryScoreWeights struct {
+	SlotAge    float64 `koanf:"slot_age"`
+	Latency    float64 `koanf:"latency"`
+	Reputation float64 `koanf:"reputation"`
+	Geo        float64 `koanf:"geo"`
 }
 
 type DiscoveryProbe struct {
 	Concurrency int    `koanf:"concurrency"`
 	MaxLatency  string `koanf:"max_latency"`
+	Samples     int    `koanf:"samples"`
+	LatencyStat string `koanf:"latency_stat"`
+	ProxyURL    string `koanf:"proxy_url"`
+	// HealthCheck calls getHealth on suitable nodes and rejects unhealthy ones
+	HealthCheck bool `koanf:"health_check"`
+	// MinVersion rejects nodes whose getVersion is older, e.g. "2.1.0" (empty = any)
+	MinVersion string `koanf:"min_version"`
+	// MaxDuration bounds the whole probe sweep; once it passes, discovery
+	// continues with the suitable nodes found so far (0 = no limit)
+	MaxDuration string `koanf:"max_duration"`
+	// BackgroundConcurrency keeps probing at this concurrency once
+	// min_suitable nodes are found, for fallback candidates should they all
+	// fail to download (0 = stop probing at min_suitable)
+	BackgroundConcurrency int `koanf:"background_concurrency"`
+	// AutoTune lowers Concurrency while probe round trips inflate, as they
+	// do when the host or its link can't keep up, and raises it back once
+	// they settle
+	AutoTune bool `koanf:"auto_tune"`
 	// Parsed
 	MaxLatencyDuration time.Duration `koanf:"-"`
+	MaxDurationDur     time.Duration `koanf:"-"`
+	ProxyURLParsed     *url.URL      `koanf:"-"`
+}
+
+// DiscoveryTrust restricts snapshot sources to known validators and/or nodes
+// with at least MinStake activated stake. A node matching either is allowed.
+type DiscoveryTrust struct {
+	KnownValidators []string `koanf:"known_validators"`
+	// MinStake is in SOL (0 = no stake rule)
+	MinStake float64 `koanf:"min_stake"`
+	// Parsed
+	MinStakeLamports uint64 `koanf:"-"`
+}
+
+const lamportsPerSOL = 1_000_000_000
+
+var pubkeyRe = regexp.MustCompile(`^[1-9A-HJ-NP-Za-km-z]{32,44}$`)
+
+func (t *DiscoveryTrust) Validate() error {
+	for i, pk := range t.KnownValidators {
+		if !pubkeyRe.MatchString(pk) {
+			return fmt.Errorf("discovery.trust.known_validators[%d]: %q is not a base58 pubkey", i, pk)
+		}
+	}
+	if t.MinStake < 0 {
+		return fmt.Errorf("discovery.trust.min_stake must be >= 0")
+	}
+	t.MinStakeLamports = uint64(t.MinStake * lamportsPerSOL)
+	return nil
 }
 
 type Snapshots struct {
-	Directory string            `koanf:"directory"`
-	Discovery Discovery         `koanf:"discovery"`
-	Download  SnapshotsDownload `koanf:"download"`
-	Age       SnapshotsAge      `koanf:"age"`
+	Directory string `koanf:"directory"`
+	// IncrementalDirectory is where incrementals are kept when the validator
+	// stores them apart from fulls (empty = Directory)
+	IncrementalDirectory string            `koanf:"incremental_directory"`
+	Discovery            Discovery         `koanf:"discovery"`
+	Download             SnapshotsDownload `koanf:"download"`
+	Age                  SnapshotsAge      `koanf:"age"`
+	Epoch                SnapshotsEpoch    `koanf:"epoch"`
+	LeaderSchedule       LeaderSchedule    `koanf:"leader_schedule"`
+	TLS                  TLS               `koanf:"tls"`
+	Ownership            Ownership         `koanf:"ownership"`
+	Recompress           Recompress        `koanf:"recompress"`
+	Unpack               Unpack            `koanf:"unpack"`
+	Attestation          Attestation       `koanf:"attestation"`
+	HTTP                 SnapshotsHTTP     `koanf:"http"`
+	Retention            Retention         `koanf:"retention"`
 }
 
 type SnapshotsDownload struct {
-	MinSpeed           string `koanf:"min_speed"`
-	MinSpeedCheckDelay string `koanf:"min_speed_check_delay"`
-	Timeout            string `koanf:"timeout"`
-	Connections        int    `koanf:"connections"`
+	MinSpeed           string                     `koanf:"min_speed"`
+	MinSpeedCheckDelay string                     `koanf:"min_speed_check_delay"`
+	Timeout            string                     `koanf:"timeout"`
+	Connections        int                        `koanf:"connections"`
+	PerSource          SnapshotsDownloadPerSource `koanf:"per_source"`
+	ProxyURL           string                     `koanf:"proxy_url"`
+	// MinSlotImprovement skips downloads that would advance the newest local
+	// snapshot by fewer slots than this (0 = disabled)
+	MinSlotImprovement int `koanf:"min_slot_improvement"`
+	// FailureCooldown skips a source that failed mid-download for this long,
+	// across cycles (0 = disabled)
+	FailureCooldown string `koanf:"failure_cooldown"`
+	// Delta fetches incrementals as a delta against the newest local
+	// incremental when the source publishes a signature
+	Delta bool `koanf:"delta"`
+	// StallTimeout re-requests the rest of a parallel chunk whose connection
+	// goes this long without data, up to StallRetries times (0 = disabled)
+	StallTimeout string `koanf:"stall_timeout"`
+	StallRetries int    `koanf:"stall_retries"`
+	// ChunkRetries re-requests the rest of a failed parallel chunk, after
+	// ChunkRetryDelay doubling each time, from other sources of the same
+	// snapshot when there are any (0 = the download fails)
+	ChunkRetries    int    `koanf:"chunk_retries"`
+	ChunkRetryDelay string `koanf:"chunk_retry_delay"`
+	// AdaptiveConnections tunes the connection count during each download,
+	// with Connections as the maximum
+	AdaptiveConnections SnapshotsDownloadAdaptive `koanf:"adaptive_connections"`
+	Transport           Transport                 `koanf:"transport"`
+	// IncrementalChain keeps fetching newer incrementals after a download
+	// until the local state is close to the tip
+	IncrementalChain SnapshotsDownloadChain `koanf:"incremental_chain"`
+	// SwitchToNewer abandons a full download that is still early when a
+	// substantially newer full appears
+	SwitchToNewer SnapshotsDownloadSwitch `koanf:"switch_to_newer"`
+	// Preallocate reserves each download's full size with fallocate up
+	// front, so it isn't fragmented as parallel writes land
+	Preallocate bool `koanf:"preallocate"`
+	// DirectIO writes downloads with O_DIRECT, so they don't evict the
+	// validator's accounts from the page cache (Linux only)
+	DirectIO bool `koanf:"direct_io"`
+	// TmpDirectory holds in-progress downloads, e.g. on a scratch disk apart
+	// from the accounts disk (empty = the snapshot directory)
+	TmpDirectory string `koanf:"tmp_directory"`
+	// StaleTmpAge is how long a temp file must go unmodified before pruning
+	// removes it, so another process's download in progress is left alone
+	// (0 = remove all)
+	StaleTmpAge string `koanf:"stale_tmp_age"`
+	// VerifyRanges re-requests this many random ranges of each finished
+	// parallel download and compares them with the file, guarding against
+	// sources that mis-serve Range requests (0 = disabled)
+	VerifyRanges int `koanf:"verify_ranges"`
+	// DiskCheck benchmarks the download directory before the first download
+	// and warns if it can't keep up with the network
+	DiskCheck SnapshotsDownloadDiskCheck `koanf:"disk_check"`
 	// Parsed
 	MinSpeedBytes         int64         `koanf:"-"`
 	MinSpeedCheckDelayDur time.Duration `koanf:"-"`
 	TimeoutDur            time.Duration `koanf:"-"`
+	FailureCooldownDur    time.Duration `koanf:"-"`
+	StallTimeoutDur       time.Duration `koanf:"-"`
+	ChunkRetryDelayDur    time.Duration `koanf:"-"`
+	StaleTmpAgeDur        time.Duration `koanf:"-"`
+	ProxyURLParsed        *url.URL      `koanf:"-"`
+}
+
+// SnapshotsDownloadPerSource caps how hard any single source is pulled from.
+type SnapshotsDownloadPerSource struct {
+	MaxConnections int    `koanf:"max_connections"`
+	MaxBandwidth   string `koanf:"max_bandwidth"`
+	// RequestInterval spaces the starts of successive requests to a source,
+	// e.g. a parallel download's range requests (empty or 0 = no spacing)
+	RequestInterval string `koanf:"request_interval"`
+	// Parsed
+	MaxBandwidthBytes  int64         `koanf:"-"`
+	RequestIntervalDur time.Duration `koanf:"-"`
+}
+
+// SnapshotsDownloadAdaptive starts downloads with a few connections and adds
+// or retires them by how much each one adds to throughput.
+type SnapshotsDownloadAdaptive struct {
+	Enabled bool `koanf:"enabled"`
+	// InitialConnections is how many connections each download starts with
+	InitialConnections int    `koanf:"initial_connections"`
+	Interval           string `koanf:"interval"`
+	// MinGain is the fraction by which an added connection must raise
+	// throughput to be kept, e.g. 0.1 for 10%
+	MinGain float64 `koanf:"min_gain"`
+	// Parsed
+	IntervalDur time.Duration `koanf:"-"`
+}
+
+func (a *SnapshotsDownloadAdaptive) validate(connections int) error {
+	if !a.Enabled {
+		return nil
+	}
+	if a.InitialConnections < 1 || a.InitialConnections > connections {
+		return fmt.Errorf("snapshots.download.adaptive_connections.initial_connections must be between 1 and connections (%d), got %d", connections, a.InitialConnections)
+	}
+	d, err := time.ParseDuration(a.Interval)
+	if err != nil {
+		return fmt.Errorf("snapshots.download.adaptive_connections.interval: %w", err)
+	}
+	if d < time.Second {
+		return fmt.Errorf("snapshots.download.adaptive_connections.interval must be >= 1s")
+	}
+	a.IntervalDur = d
+	if a.MinGain < 0 {
+		return fmt.Errorf("snapshots.download.adaptive_connections.min_gain must be >= 0")
+	}
+	return nil
+}
+
+// SnapshotsDownloadChain fetches newer incrementals for the local full after
+// each download, since a large download can leave the state well behind the
+// tip by the time it finishes.
+type SnapshotsDownloadChain struct {
+	Enabled bool `koanf:"enabled"`
+	// MaxSlotsBehind stops the chain once the newest local snapshot is
+	// within this many slots of the cluster
+	MaxSlotsBehind int `koanf:"max_slots_behind"`
+	// MaxDownloads caps the extra incrementals fetched per cycle
+	MaxDownloads int `koanf:"max_downloads"`
+}
+
+func (c *SnapshotsDownloadChain) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	if c.MaxSlotsBehind < 0 {
+		return fmt.Errorf("snapshots.download.incremental_chain.max_slots_behind must be >= 0")
+	}
+	if c.MaxDownloads < 1 {
+		return fmt.Errorf("snapshots.download.incremental_chain.max_downloads must be >= 1")
+	}
+	return nil
+}
+
+// SnapshotsDownloadSwitch re-probes the top full candidates while a full
+// downloads, since a transfer over a slow link can take long enough for the
+// cluster to move on to a newer full.
+type SnapshotsDownloadSwitch struct {
+	Enabled       bool   `koanf:"enabled"`
+	CheckInterval string `koanf:"check_interval"`
+	// MinSlotDelta is how many slots newer than the one downloading a full
+	// must be to switch to it
+	MinSlotDelta int `koanf:"min_slot_delta"`
+	// MaxProgress is the completed fraction past which the download is
+	// finished rather than switched, e.g. 0.25 for 25%
+	MaxProgress float64 `koanf:"max_progress"`
+	// Parsed
+	CheckIntervalDur time.Duration `koanf:"-"`
+}
+
+func (w *SnapshotsDownloadSwitch) validate() error {
+	if !w.Enabled {
+		return nil
+	}
+	d, err := time.ParseDuration(w.CheckInterval)
+	if err != nil {
+		return fmt.Errorf("snapshots.download.switch_to_newer.check_interval: %w", err)
+	}
+	if d < time.Second {
+		return fmt.Errorf("snapshots.download.switch_to_newer.check_interval must be >= 1s")
+	}
+	w.CheckIntervalDur = d
+	if w.MinSlotDelta < 1 {
+		return fmt.Errorf("snapshots.download.switch_to_newer.min_slot_delta must be >= 1")
+	}
+	if w.MaxProgress <= 0 || w.MaxProgress >= 1 {
+		return fmt.Errorf("snapshots.download.switch_to_newer.max_progress must be between 0 and 1, got %g", w.MaxProgress)
+	}
+	return nil
+}
+
+// SnapshotsDownloadDiskCheck measures sequential write throughput of the
+// download directory, since a disk slower than the network throttles every
+// download and looks like slow sources.
+type SnapshotsDownloadDiskCheck struct {
+	Enabled bool `koanf:"enabled"`
+	// Size is how much is written, e.g. 1gb; more gets past write caches
+	Size string `koanf:"size"`
+	// Parsed
+	SizeBytes int64 `koanf:"-"`
+}
+
+func (c *SnapshotsDownloadDiskCheck) validate() error {
+	if !c.Enabled {
+		return nil
+	}
+	bytes, err := ParseSize(c.Size)
+	if err != nil {
+		return fmt.Errorf("snapshots.download.disk_check.size: %w", err)
+	}
+	if bytes <= 0 {
+		return fmt.Errorf("snapshots.download.disk_check.size must be > 0")
+	}
+	c.SizeBytes = bytes
+	return nil
+}
+
+// RequiredWriteSpeed is the throughput a download reaches with every
+// connection at min_speed, which the download directory must absorb.
+func (d *SnapshotsDownload) RequiredWriteSpeed() int64 {
+	return d.MinSpeedBytes * int64(max(d.Connections, 1))
 }
 
 type SnapshotsAge struct {
@@ -50,15 +337,251 @@ type SnapshotsAge struct {
 
 type SnapshotsRemoteAge struct {
 	MaxSlots int `koanf:"max_slots"`
+	// NearMissFactor relaxes MaxSlots by this factor for one retry when every
+	// candidate was rejected as too old by a small margin (0 = disabled)
+	NearMissFactor float64 `koanf:"near_miss_factor"`
 }
 
 type SnapshotsLocalAge struct {
 	MaxIncrementalSlots int `koanf:"max_incremental_slots"`
+	// MaxFullSlots forces a new full download once the local full is older
+	// than this, however fresh its incrementals (0 = disabled)
+	MaxFullSlots int `koanf:"max_full_slots"`
+}
+
+// SnapshotsEpoch schedules downloads around epoch boundaries, when many
+// nodes regenerate their full snapshots and incremental chains reset.
+type SnapshotsEpoch struct {
+	// DeferFullSlots skips starting a full download when fewer than this many
+	// slots remain in the epoch and a local full exists (0 = disabled)
+	DeferFullSlots int `koanf:"defer_full_slots"`
+	// FullAfterBoundary replaces incremental top-ups with a full download once
+	// a full from the current epoch is available, if the local full predates it
+	FullAfterBoundary bool `koanf:"full_after_boundary"`
+}
+
+// LeaderSchedule keeps downloads clear of the active identity's leader slots,
+// so a standby promoted mid-download isn't competing with it for bandwidth
+// while producing blocks.
+type LeaderSchedule struct {
+	// WindowSlots is how many slots either side of each leader slot downloads
+	// avoid (0 = disabled)
+	WindowSlots int `koanf:"window_slots"`
+	// AbortDownloads cancels a running download when a window starts and
+	// retries it once the window has passed; otherwise only starting a
+	// download waits for the window to pass
+	AbortDownloads bool `koanf:"abort_downloads"`
+}
+
+// Recompress converts downloaded .tar.bz2 and .tar.gz archives to zstd,
+// which Agave unpacks much faster.
+type Recompress struct {
+	Enabled bool `koanf:"enabled"`
+	// Level is the zstd compression level, 1-19
+	Level int `koanf:"level"`
+}
+
+// Unpack extracts each downloaded full snapshot into the validator's ledger
+// and accounts paths, to bootstrap a cold host.
+type Unpack struct {
+	Enabled bool `koanf:"enabled"`
+	// LedgerPath receives the version file and the bank snapshot (snapshots/)
+	LedgerPath string `koanf:"ledger_path"`
+	// AccountsPath receives the account storage files (empty =
+	// <ledger_path>/accounts)
+	AccountsPath string `koanf:"accounts_path"`
+}
+
+// Retention configures what pruning does with superseded snapshots.
+type Retention struct {
+	// QuarantineDir receives superseded snapshots instead of them being
+	// deleted, in case the validator is still loading one (empty = delete).
+	// It must be on the same filesystem as the snapshot directories.
+	QuarantineDir string `koanf:"quarantine_dir"`
+	// QuarantineTTL is how long a snapshot stays quarantined before it is
+	// deleted
+	QuarantineTTL string `koanf:"quarantine_ttl"`
+	// MinFreeSpace prunes, oldest eligible file first, whenever free space on
+	// the snapshots directory's filesystem drops below this (empty = off)
+	MinFreeSpace string `koanf:"min_free_space"`
+	// MaxTotalSize prunes, oldest eligible file first, once the snapshot
+	// and quarantine directories hold more than this (empty = off)
+	MaxTotalSize string `koanf:"max_total_size"`
+	// Parsed
+	QuarantineTTLDur  time.Duration `koanf:"-"`
+	MinFreeSpaceBytes int64         `koanf:"-"`
+	MaxTotalSizeBytes int64         `koanf:"-"`
+}
+
+func (r *Retention) validate(archiveDirs ...string) error {
+	r.MinFreeSpaceBytes = 0
+	if r.MinFreeSpace != "" {
+		bytes, err := ParseSize(r.MinFreeSpace)
+		if err != nil {
+			return fmt.Errorf("snapshots.retention.min_free_space: %w", err)
+		}
+		if bytes < 0 {
+			return fmt.Errorf("snapshots.retention.min_free_space must be >= 0")
+		}
+		r.MinFreeSpaceBytes = bytes
+	}
+	r.MaxTotalSizeBytes = 0
+	if r.MaxTotalSize != "" {
+		bytes, err := ParseSize(r.MaxTotalSize)
+		if err != nil {
+			return fmt.Errorf("snapshots.retention.max_total_size: %w", err)
+		}
+		if bytes < 0 {
+			return fmt.Errorf("snapshots.retention.max_total_size must be >= 0")
+		}
+		r.MaxTotalSizeBytes = bytes
+	}
+	if r.QuarantineDir == "" {
+		return nil
+	}
+	for _, dir := range archiveDirs {
+		if filepath.Clean(dir) == filepath.Clean(r.QuarantineDir) {
+			return fmt.Errorf("snapshots.retention.quarantine_dir must differ from the snapshot directories, got %s", r.QuarantineDir)
+		}
+	}
+	if err := checkWritableDir("snapshots.retention.quarantine_dir", r.QuarantineDir); err != nil {
+		return err
+	}
+	for _, dir := range archiveDirs {
+		if err := checkRenamable(dir, r.QuarantineDir); err != nil {
+			return fmt.Errorf("snapshots.retention.quarantine_dir must be on the same filesystem as %s: %w", dir, err)
+		}
+	}
+	d, err := time.ParseDuration(r.QuarantineTTL)
+	if err != nil {
+		return fmt.Errorf("snapshots.retention.quarantine_ttl: %w", err)
+	}
+	if d <= 0 {
+		return fmt.Errorf("snapshots.retention.quarantine_ttl must be > 0")
+	}
+	r.QuarantineTTLDur = d
+	return nil
+}
+
+// AccountsDir returns where account storage files are unpacked.
+func (u Unpack) AccountsDir() string {
+	if u.AccountsPath != "" {
+		return u.AccountsPath
+	}
+	return filepath.Join(u.LedgerPath, "accounts")
+}
+
+func (u *Unpack) validate(archiveDirs ...string) error {
+	if !u.Enabled {
+		return nil
+	}
+	if u.LedgerPath == "" {
+		return fmt.Errorf("snapshots.unpack.ledger_path is required when unpacking is enabled")
+	}
+	// Unpacking replaces <ledger_path>/snapshots wholesale
+	replaced := filepath.Join(u.LedgerPath, "snapshots")
+	for _, dir := range archiveDirs {
+		if rel, err := filepath.Rel(replaced, dir); err == nil && filepath.IsLocal(rel) {
+			return fmt.Errorf("snapshots.unpack.ledger_path: unpacking would replace %s, which holds the snapshot archives", replaced)
+		}
+	}
+	return nil
+}
+
+// Attestation verifies each downloaded archive against the SHA-256 a
+// trusted endpoint publishes for it.
+type Attestation struct {
+	// URL returns a JSON object mapping slots or archive filenames to hashes;
+	// "{slot}" is replaced with the archive's slot (empty = disabled)
+	URL string `koanf:"url"`
+	// Required fails downloads the endpoint has no hash for, or can't be
+	// reached to check; otherwise those are accepted with a warning
+	Required bool         `koanf:"required"`
+	Timeout  string       `koanf:"timeout"`
+	Auth     EndpointAuth `koanf:"auth"`
+	// TrustedPubkeys only downloads archives listed in a manifest signed by
+	// one of these base58 Ed25519 keys, which each source publishes at
+	// ManifestPath (empty = signed manifests aren't checked)
+	TrustedPubkeys []string `koanf:"trusted_pubkeys"`
+	ManifestPath   string   `koanf:"manifest_path"`
+	// Parsed
+	TimeoutDur  time.Duration       `koanf:"-"`
+	TrustedKeys []ed25519.PublicKey `koanf:"-"`
+}
+
+// Enabled reports whether downloads are verified.
+func (a Attestation) Enabled() bool {
+	return a.URL != ""
+}
+
+// Signed reports whether sources must publish a trusted signed manifest.
+func (a Attestation) Signed() bool {
+	return len(a.TrustedPubkeys) > 0
+}
+
+func (a *Attestation) validate() error {
+	a.TrustedKeys = nil
+	for i, pk := range a.TrustedPubkeys {
+		key, err := attestation.ParsePubkey(pk)
+		if err != nil {
+			return fmt.Errorf("snapshots.attestation.trusted_pubkeys[%d]: %w", i, err)
+		}
+		a.TrustedKeys = append(a.TrustedKeys, key)
+	}
+	if a.Signed() && !strings.HasPrefix(a.ManifestPath, "/") {
+		return fmt.Errorf("snapshots.attestation.manifest_path must start with /, got %q", a.ManifestPath)
+	}
+	if !a.Enabled() && !a.Signed() {
+		return nil
+	}
+	if a.Enabled() {
+		u, err := url.Parse(strings.ReplaceAll(a.URL, "{slot}", "0"))
+		if err != nil {
+			return fmt.Errorf("snapshots.attestation.url: %w", err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return fmt.Errorf("snapshots.attestation.url must be http or https, got %q", a.URL)
+		}
+	}
+	if a.Timeout != "" {
+		d, err := time.ParseDuration(a.Timeout)
+		if err != nil {
+			return fmt.Errorf("snapshots.attestation.timeout: %w", err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("snapshots.attestation.timeout must be > 0")
+		}
+		a.TimeoutDur = d
+	}
+	return a.Auth.Validate("snapshots.attestation.auth")
 }
 
+var versionRe = regexp.MustCompile(`^v?\d+(\.\d+){0,2}$`)
+
 func (d *Discovery) Validate() error {
-	if d.Candidates.SortOrder != "latency" && d.Candidates.SortOrder != "slot_age" {
-		return fmt.Errorf("discovery.candidates.sort_order must be \"latency\" or \"slot_age\", got %q", d.Candidates.SortOrder)
+	switch d.Candidates.SortOrder {
+	case "latency", "slot_age", "availability":
+	case "score":
+		w := d.Candidates.ScoreWeights
+		if w.SlotAge < 0 || w.Latency < 0 || w.Reputation < 0 || w.Geo < 0 {
+			return fmt.Errorf("discovery.candidates.score_weights must be >= 0")
+		}
+		if w.SlotAge+w.Latency+w.Reputation+w.Geo == 0 {
+			return fmt.Errorf("discovery.candidates.score_weights needs a weight > 0 with sort_order \"score\"")
+		}
+	default:
+		return fmt.Errorf("discovery.candidates.sort_order must be \"latency\", \"slot_age\", \"score\" or \"availability\", got %q", d.Candidates.SortOrder)
+	}
+	switch d.Candidates.IPv6 {
+	case "", "allow", "prefer", "require", "skip":
+	default:
+		return fmt.Errorf("discovery.candidates.ipv6 must be \"allow\", \"prefer\", \"require\" or \"skip\", got %q", d.Candidates.IPv6)
+	}
+	if d.Candidates.Remember < 0 {
+		return fmt.Errorf("discovery.candidates.remember must be >= 0")
+	}
+	if d.Probe.BackgroundConcurrency < 0 {
+		return fmt.Errorf("discovery.probe.background_concurrency must be >= 0")
 	}
 	if d.Probe.MaxLatency != "" {
 		dur, err := time.ParseDuration(d.Probe.MaxLatency)
@@ -70,7 +593,42 @@ func (d *Discovery) Validate() error {
 		}
 		d.Probe.MaxLatencyDuration = dur
 	}
-	return nil
+	if d.Probe.MaxDuration != "" {
+		dur, err := time.ParseDuration(d.Probe.MaxDuration)
+		if err != nil {
+			return fmt.Errorf("discovery.probe.max_duration: %w", err)
+		}
+		if dur < 0 {
+			return fmt.Errorf("discovery.probe.max_duration must be >= 0")
+		}
+		d.Probe.MaxDurationDur = dur
+	}
+	if d.Probe.Samples < 0 {
+		return fmt.Errorf("discovery.probe.samples must be >= 0")
+	}
+	switch d.Probe.LatencyStat {
+	case "", "min", "median", "p90":
+	default:
+		return fmt.Errorf("discovery.probe.latency_stat must be \"min\", \"median\" or \"p90\", got %q", d.Probe.LatencyStat)
+	}
+	if d.Probe.MinVersion != "" && !versionRe.MatchString(d.Probe.MinVersion) {
+		return fmt.Errorf("discovery.probe.min_version must look like \"2.1.0\", got %q", d.Probe.MinVersion)
+	}
+	u, err := parseProxyURL("discovery.probe.proxy_url", d.Probe.ProxyURL)
+	if err != nil {
+		return err
+	}
+	d.Probe.ProxyURLParsed = u
+	if err := d.Geo.validate(); err != nil {
+		return err
+	}
+	if err := d.Exclude.Validate(); err != nil {
+		return err
+	}
+	if err := d.Content.validate(); err != nil {
+		return err
+	}
+	return d.Trust.Validate()
 }
 
 func (s *Snapshots) Validate() error {
@@ -80,18 +638,19 @@ func (s *Snapshots) Validate() error {
 	if s.Directory == "" {
 		return fmt.Errorf("snapshots.directory is required")
 	}
-	info, err := os.Stat(s.Directory)
-	if err != nil {
-		return fmt.Errorf("snapshots.directory: %w", err)
+	if err := checkWritableDir("snapshots.directory", s.Directory); err != nil {
+		return err
 	}
-	if !info.IsDir() {
-		return fmt.Errorf("snapshots.directory: %s is not a directory", s.Directory)
+	if s.IncrementalDirectory != "" {
+		if err := checkWritableDir("snapshots.incremental_directory", s.IncrementalDirectory); err != nil {
+			return err
+		}
 	}
-	probe := filepath.Join(s.Directory, ".snapshot-keeper-probe")
-	if err := os.WriteFile(probe, nil, 0644); err != nil {
-		return fmt.Errorf("snapshots.directory: not writable: %w", err)
+	if s.Download.TmpDirectory != "" {
+		if err := checkWritableDir("snapshots.download.tmp_directory", s.Download.TmpDirectory); err != nil {
+			return err
+		}
 	}
-	os.Remove(probe)
 	if s.Download.MinSpeed != "" {
 		bytes, err := ParseSize(s.Download.MinSpeed)
 		if err != nil {
@@ -122,14 +681,190 @@ func (s *Snapshots) Validate() error {
 		}
 		s.Download.TimeoutDur = d
 	}
+	if s.Download.MinSlotImprovement < 0 {
+		return fmt.Errorf("snapshots.download.min_slot_improvement must be >= 0")
+	}
+	if s.Download.FailureCooldown != "" {
+		d, err := time.ParseDuration(s.Download.FailureCooldown)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.failure_cooldown: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("snapshots.download.failure_cooldown must be >= 0")
+		}
+		s.Download.FailureCooldownDur = d
+	}
+	if s.Download.StallTimeout != "" {
+		d, err := time.ParseDuration(s.Download.StallTimeout)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.stall_timeout: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("snapshots.download.stall_timeout must be >= 0")
+		}
+		s.Download.StallTimeoutDur = d
+	}
+	if s.Download.StaleTmpAge != "" {
+		d, err := time.ParseDuration(s.Download.StaleTmpAge)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.stale_tmp_age: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("snapshots.download.stale_tmp_age must be >= 0")
+		}
+		s.Download.StaleTmpAgeDur = d
+	}
+	if s.Download.VerifyRanges < 0 {
+		return fmt.Errorf("snapshots.download.verify_ranges must be >= 0")
+	}
+	if s.Download.StallRetries < 0 {
+		return fmt.Errorf("snapshots.download.stall_retries must be >= 0")
+	}
+	if s.Download.ChunkRetries < 0 {
+		return fmt.Errorf("snapshots.download.chunk_retries must be >= 0")
+	}
+	if s.Download.ChunkRetryDelay != "" {
+		d, err := time.ParseDuration(s.Download.ChunkRetryDelay)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.chunk_retry_delay: %w", err)
+		}
+		if d < 0 {
+			return fmt.Errorf("snapshots.download.chunk_retry_delay must be >= 0")
+		}
+		s.Download.ChunkRetryDelayDur = d
+	}
 	if s.Age.Remote.MaxSlots < 1 {
 		return fmt.Errorf("snapshots.age.remote.max_slots must be >= 1")
 	}
+	if f := s.Age.Remote.NearMissFactor; f != 0 && f <= 1 {
+		return fmt.Errorf("snapshots.age.remote.near_miss_factor must be 0 (disabled) or > 1, got %g", f)
+	}
 	if s.Age.Local.MaxIncrementalSlots < 1 {
 		return fmt.Errorf("snapshots.age.local.max_incremental_slots must be >= 1")
 	}
+	if s.Epoch.DeferFullSlots < 0 {
+		return fmt.Errorf("snapshots.epoch.defer_full_slots must be >= 0")
+	}
+	if s.LeaderSchedule.WindowSlots < 0 {
+		return fmt.Errorf("snapshots.leader_schedule.window_slots must be >= 0")
+	}
+	if s.Recompress.Enabled && (s.Recompress.Level < 1 || s.Recompress.Level > 19) {
+		return fmt.Errorf("snapshots.recompress.level must be between 1 and 19, got %d", s.Recompress.Level)
+	}
+	if err := s.Unpack.validate(s.Directory, s.IncrementalDir()); err != nil {
+		return err
+	}
+	if err := s.Attestation.validate(); err != nil {
+		return err
+	}
+	if err := s.Retention.validate(s.Directory, s.IncrementalDir()); err != nil {
+		return err
+	}
+	if f := s.Age.Local.MaxFullSlots; f != 0 && f <= s.Age.Local.MaxIncrementalSlots {
+		return fmt.Errorf("snapshots.age.local.max_full_slots must be 0 (disabled) or > max_incremental_slots (%d), got %d", s.Age.Local.MaxIncrementalSlots, f)
+	}
 	if s.Download.Connections < 1 {
 		return fmt.Errorf("snapshots.download.connections must be >= 1")
 	}
+	if err := s.Download.AdaptiveConnections.validate(s.Download.Connections); err != nil {
+		return err
+	}
+	if err := s.Download.IncrementalChain.validate(); err != nil {
+		return err
+	}
+	if err := s.Download.SwitchToNewer.validate(); err != nil {
+		return err
+	}
+	if err := s.Download.DiskCheck.validate(); err != nil {
+		return err
+	}
+	if err := s.Download.Transport.validate("snapshots.download.transport"); err != nil {
+		return err
+	}
+	if s.Download.DirectIO && runtime.GOOS != "linux" {
+		return fmt.Errorf("snapshots.download.direct_io is only supported on Linux")
+	}
+	if s.Download.PerSource.MaxConnections < 0 {
+		return fmt.Errorf("snapshots.download.per_source.max_connections must be >= 0")
+	}
+	if s.Download.PerSource.MaxBandwidth != "" {
+		bytes, err := ParseSize(s.Download.PerSource.MaxBandwidth)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.per_source.max_bandwidth: %w", err)
+		}
+		if bytes < s.Download.MinSpeedBytes {
+			return fmt.Errorf("snapshots.download.per_source.max_bandwidth (%s) must be >= snapshots.download.min_speed (%s)", s.Download.PerSource.MaxBandwidth, s.Download.MinSpeed)
+		}
+		s.Download.PerSource.MaxBandwidthBytes = bytes
+	}
+	s.Download.PerSource.RequestIntervalDur = 0
+	if ri := s.Download.PerSource.RequestInterval; ri != "" {
+		d, err := time.ParseDuration(ri)
+		if err != nil {
+			return fmt.Errorf("snapshots.download.per_source.request_interval: %w", err)
+		}
+		if d < 0 || d > 10*time.Second {
+			return fmt.Errorf("snapshots.download.per_source.request_interval must be between 0 and 10s, got %s", ri)
+		}
+		s.Download.PerSource.RequestIntervalDur = d
+	}
+	proxyURL, err := parseProxyURL("snapshots.download.proxy_url", s.Download.ProxyURL)
+	if err != nil {
+		return err
+	}
+	s.Download.ProxyURLParsed = proxyURL
+	if err := s.TLS.Validate(); err != nil {
+		return err
+	}
+	if err := s.HTTP.Validate(); err != nil {
+		return err
+	}
+	return s.Ownership.Validate()
+}
+
+// DownloadDir returns where in-progress downloads are written.
+func (s *Snapshots) DownloadDir() string {
+	if s.Download.TmpDirectory != "" {
+		return s.Download.TmpDirectory
+	}
+	return s.Directory
+}
+
+// IncrementalDir returns where incremental snapshots are kept.
+func (s *Snapshots) IncrementalDir() string {
+	if s.IncrementalDirectory != "" {
+		return s.IncrementalDirectory
+	}
+	return s.Directory
+}
+
+// checkRenamable checks that a file in dir can be renamed into to, which a
+// directory on another filesystem fails.
+func checkRenamable(dir, to string) error {
+	probe := filepath.Join(dir, ".snapshot-keeper-rename-probe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		return err
+	}
+	defer os.Remove(probe)
+	moved := filepath.Join(to, filepath.Base(probe))
+	if err := os.Rename(probe, moved); err != nil {
+		return err
+	}
+	return os.Remove(moved)
+}
+
+func checkWritableDir(name, dir string) error {
+	info, err := os.Stat(dir)
+	if err != nil {
+		return fmt.Errorf("%s: %w", name, err)
+	}
+	if !info.IsDir() {
+		return fmt.Errorf("%s: %s is not a directory", name, dir)
+	}
+	probe := filepath.Join(dir, ".snapshot-keeper-probe")
+	if err := os.WriteFile(probe, nil, 0644); err != nil {
+		return fmt.Errorf("%s: not writable: %w", name, err)
+	}
+	os.Remove(probe)
 	return nil
 }
@@ -0,0 +1,81 @@
+package config
+
+import (
+	"fmt"
+	"net"
+)
+
+// Status configures the HTTP status endpoint served while running on an
+// interval.
+type Status struct {
+	// ListenAddress is host:port to serve /status on (empty = disabled)
+	ListenAddress string `koanf:"listen_address"`
+}
+
+func (s *Status) Validate() error {
+	if s.ListenAddress == "" {
+		return nil
+	}
+	if _, _, err := net.SplitHostPort(s.ListenAddress); err != nil {
+		return fmt.Errorf("status.listen_address: %w", err)
+	}
+	return nil
+}
+
+// Features reports which optional behaviours the config turns on, keyed by
+// the config path that controls each one.
+func (c *Config) Features() map[string]bool {
+	d := c.Snapshots.Discovery
+	dl := c.Snapshots.Download
+	return map[string]bool{
+		"validator.role_monitor.pause":            c.Validator.RoleMonitor.OnActive == OnActivePause,
+		"validator.caught_up.skip_downloads":      c.Validator.CaughtUp.SkipDownloads,
+		"validator.ledger_directory":              c.Validator.LedgerDirectory != "",
+		"snapshots.discovery.stream":              d.Stream,
+		"snapshots.discovery.probe.health_check":  d.Probe.HealthCheck,
+		"snapshots.discovery.probe.min_version":   d.Probe.MinVersion != "",
+		"snapshots.discovery.probe.max_duration":  d.Probe.MaxDurationDur > 0,
+		"snapshots.discovery.candidates.ipv6":     d.Candidates.IPv6 != "" && d.Candidates.IPv6 != "allow",
+		"snapshots.discovery.candidates.remember": d.Candidates.Remember > 0,
+		"snapshots.discovery.geo":                 d.Geo.Enabled(),
+		"snapshots.discovery.content":             len(d.Content.Manifests) > 0,
+		"snapshots.discovery.exclude.self":        d.Exclude.Self,
+		"snapshots.discovery.exclude.hosts":       len(d.Exclude.Hosts) > 0,
+		"snapshots.discovery.trust":               len(d.Trust.KnownValidators) > 0 || d.Trust.MinStakeLamports > 0,
+		"snapshots.download.delta":                dl.Delta,
+		"snapshots.download.per_source":           dl.PerSource.MaxConnections > 0 || dl.PerSource.MaxBandwidthBytes > 0 || dl.PerSource.RequestIntervalDur > 0,
+		"snapshots.download.proxy_url":            dl.ProxyURL != "",
+		"snapshots.download.failure_cooldown":     dl.FailureCooldownDur > 0,
+		"snapshots.download.min_slot_improvement": dl.MinSlotImprovement > 0,
+		"snapshots.download.adaptive_connections": dl.AdaptiveConnections.Enabled,
+		"snapshots.download.incremental_chain":    dl.IncrementalChain.Enabled,
+		"snapshots.download.switch_to_newer":      dl.SwitchToNewer.Enabled,
+		"snapshots.download.direct_io":            dl.DirectIO,
+		"snapshots.download.verify_ranges":        dl.VerifyRanges > 0,
+		"snapshots.download.disk_check":           dl.DiskCheck.Enabled,
+		"snapshots.age.remote.near_miss_factor":   c.Snapshots.Age.Remote.NearMissFactor > 0,
+		"snapshots.age.local.max_full_slots":      c.Snapshots.Age.Local.MaxFullSlots > 0,
+		"snapshots.epoch.defer_full_slots":        c.Snapshots.Epoch.DeferFullSlots > 0,
+		"snapshots.epoch.full_after_boundary":     c.Snapshots.Epoch.FullAfterBoundary,
+		"snapshots.leader_schedule":               c.Snapshots.LeaderSchedule.WindowSlots > 0,
+		"snapshots.tls.insecure_skip_verify":      c.Snapshots.TLS.InsecureSkipVerify,
+		"snapshots.ownership":                     c.Snapshots.Ownership.Parsed.Enabled(),
+		"snapshots.recompress":                    c.Snapshots.Recompress.Enabled,
+		"snapshots.unpack":                        c.Snapshots.Unpack.Enabled,
+		"snapshots.attestation":                   c.Snapshots.Attestation.Enabled(),
+		"snapshots.attestation.trusted_pubkeys":   c.Snapshots.Attestation.Signed(),
+		"snapshots.http":                          c.Snapshots.HTTP.Parsed != nil,
+		"incident.manual":                         c.Incident.Manual,
+		"incident.auto_detect":                    c.Incident.AutoDetect,
+		"metrics":                                 c.Metrics.Backend != "",
+		"issue_report":                            c.IssueReport.AfterFailures > 0,
+		"peers.listen_address":                    c.Peers.ListenAddress != "",
+		"peers.urls":                              len(c.Peers.URLs) > 0,
+		"lock.ttl":                                c.Lock.TTLDur > 0,
+		"schedule.cron":                           len(c.Schedule.Cron) > 0,
+		"schedule.jitter":                         c.Schedule.JitterDur > 0,
+		"schedule.follow.max_bandwidth_per_hour":  c.Schedule.Follow.MaxBandwidthPerHourBytes > 0,
+		"keeper.max_cycle_duration":               c.Keeper.MaxCycleDurationDur > 0,
+		"trace_http":                              c.TraceHTTP.Directory != "",
+	}
+}
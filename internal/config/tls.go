@@ -0,0 +1,65 @@
+package config
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+
+	"github.com/charmbracelet/log"
+)
+
+// TLS configures trust and client authentication for HTTPS snapshot sources.
+type TLS struct {
+	// CAFile is a PEM bundle of additional CAs trusted alongside the system roots
+	CAFile string `koanf:"ca_file"`
+	// CertFile and KeyFile are a PEM client certificate and key for mutual TLS
+	CertFile string `koanf:"cert_file"`
+	KeyFile  string `koanf:"key_file"`
+	// InsecureSkipVerify disables server certificate verification entirely
+	InsecureSkipVerify bool `koanf:"insecure_skip_verify"`
+	// Parsed is nil when no TLS options are set
+	Parsed *tls.Config `koanf:"-"`
+}
+
+func (t *TLS) Validate() error {
+	if t.CAFile == "" && t.CertFile == "" && t.KeyFile == "" && !t.InsecureSkipVerify {
+		return nil
+	}
+
+	tlsConfig := &tls.Config{
+		InsecureSkipVerify: t.InsecureSkipVerify,
+	}
+	if t.InsecureSkipVerify {
+		log.Warn("snapshots.tls.insecure_skip_verify is enabled - snapshot source certificates will not be verified")
+	}
+
+	if t.CAFile != "" {
+		pem, err := os.ReadFile(t.CAFile)
+		if err != nil {
+			return fmt.Errorf("snapshots.tls.ca_file: %w", err)
+		}
+		pool, err := x509.SystemCertPool()
+		if err != nil {
+			pool = x509.NewCertPool()
+		}
+		if !pool.AppendCertsFromPEM(pem) {
+			return fmt.Errorf("snapshots.tls.ca_file: no certificates found in %s", t.CAFile)
+		}
+		tlsConfig.RootCAs = pool
+	}
+
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("snapshots.tls.cert_file and snapshots.tls.key_file must be set together")
+	}
+	if t.CertFile != "" {
+		cert, err := tls.LoadX509KeyPair(t.CertFile, t.KeyFile)
+		if err != nil {
+			return fmt.Errorf("snapshots.tls: loading client certificate: %w", err)
+		}
+		tlsConfig.Certificates = []tls.Certificate{cert}
+	}
+
+	t.Parsed = tlsConfig
+	return nil
+}
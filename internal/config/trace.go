@@ -0,0 +1,9 @@
+package config
+
+// TraceHTTP enables HTTP transcript recording for debugging. It is set from
+// the --trace-http flags rather than the config file.
+type TraceHTTP struct {
+	Directory  string  // empty disables tracing
+	SampleRate float64 // fraction of requests recorded
+	RPCBodies  bool    // also record RPC request/response bodies
+}
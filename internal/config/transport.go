@@ -0,0 +1,83 @@
+package config
+
+import (
+	"fmt"
+	"math"
+	"time"
+)
+
+// Transport tunes the HTTP connections snapshot downloads are made over,
+// independently of probes. Empty durations and sizes keep Go's defaults.
+type Transport struct {
+	// MaxIdleConnsPerHost is how many connections to one source are kept
+	// open between requests (0 = snapshots.download.connections)
+	MaxIdleConnsPerHost int    `koanf:"max_idle_conns_per_host"`
+	IdleConnTimeout     string `koanf:"idle_conn_timeout"`
+	DialTimeout         string `koanf:"dial_timeout"`
+	// KeepAlive is the TCP keep-alive probe interval
+	KeepAlive           string `koanf:"keep_alive"`
+	TLSHandshakeTimeout string `koanf:"tls_handshake_timeout"`
+	// DisableHTTP2 gives each parallel request to an HTTPS source its own
+	// TCP connection, rather than multiplexing them over one with HTTP/2
+	DisableHTTP2 bool `koanf:"disable_http2"`
+	// SocketReadBuffer and SocketWriteBuffer size the TCP receive and send
+	// buffers, e.g. "4mb" (empty = OS default)
+	SocketReadBuffer  string `koanf:"socket_read_buffer"`
+	SocketWriteBuffer string `koanf:"socket_write_buffer"`
+	// Parsed
+	IdleConnTimeoutDur     time.Duration `koanf:"-"`
+	DialTimeoutDur         time.Duration `koanf:"-"`
+	KeepAliveDur           time.Duration `koanf:"-"`
+	TLSHandshakeTimeoutDur time.Duration `koanf:"-"`
+	SocketReadBufferBytes  int           `koanf:"-"`
+	SocketWriteBufferBytes int           `koanf:"-"`
+}
+
+func (t *Transport) validate(field string) error {
+	if t.MaxIdleConnsPerHost < 0 {
+		return fmt.Errorf("%s.max_idle_conns_per_host must be >= 0", field)
+	}
+	for _, d := range []struct {
+		name   string
+		value  string
+		parsed *time.Duration
+	}{
+		{"idle_conn_timeout", t.IdleConnTimeout, &t.IdleConnTimeoutDur},
+		{"dial_timeout", t.DialTimeout, &t.DialTimeoutDur},
+		{"keep_alive", t.KeepAlive, &t.KeepAliveDur},
+		{"tls_handshake_timeout", t.TLSHandshakeTimeout, &t.TLSHandshakeTimeoutDur},
+	} {
+		if d.value == "" {
+			continue
+		}
+		dur, err := time.ParseDuration(d.value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", field, d.name, err)
+		}
+		if dur <= 0 {
+			return fmt.Errorf("%s.%s must be > 0", field, d.name)
+		}
+		*d.parsed = dur
+	}
+	for _, s := range []struct {
+		name   string
+		value  string
+		parsed *int
+	}{
+		{"socket_read_buffer", t.SocketReadBuffer, &t.SocketReadBufferBytes},
+		{"socket_write_buffer", t.SocketWriteBuffer, &t.SocketWriteBufferBytes},
+	} {
+		if s.value == "" {
+			continue
+		}
+		bytes, err := ParseSize(s.value)
+		if err != nil {
+			return fmt.Errorf("%s.%s: %w", field, s.name, err)
+		}
+		if bytes < 1 || bytes > math.MaxInt32 {
+			return fmt.Errorf("%s.%s must be between 1 byte and 2gb", field, s.name)
+		}
+		*s.parsed = int(bytes)
+	}
+	return nil
+}
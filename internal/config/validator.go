@@ -1,18 +1,162 @@
 package config
 
-import "fmt"
+import (
+	"fmt"
+	"path/filepath"
+	"slices"
+	"time"
+)
+
+// Validator clients whose role and snapshot conventions the keeper knows.
+const (
+	ClientAgave      = "agave"
+	ClientFiredancer = "firedancer"
+)
 
 type Validator struct {
-	RPCURL              string `koanf:"rpc_url"`
+	// Client is the local validator client: "agave" (the default) or
+	// "firedancer"
+	Client               string `koanf:"client"`
+	RPCURL               string `koanf:"rpc_url"`
 	ActiveIdentityPubkey string `koanf:"active_identity_pubkey"`
+	// ActiveIdentityKeypairPath is a keypair file the active identity pubkey
+	// is read from instead, and re-read each cycle to follow key rotations
+	ActiveIdentityKeypairPath string `koanf:"active_identity_keypair_path"`
+	// ActiveIdentityPubkeys are further identities the validator counts as
+	// active with, for failover setups that swap among several
+	ActiveIdentityPubkeys []string     `koanf:"active_identity_pubkeys"`
+	Auth                  EndpointAuth `koanf:"auth"`
+	RoleMonitor           RoleMonitor  `koanf:"role_monitor"`
+	RoleFallback          RoleFallback `koanf:"role_fallback"`
+	// AdminRPCPath is Agave's admin RPC socket; when set, the identity is
+	// read over it instead of the RPC
+	AdminRPCPath string   `koanf:"admin_rpc_path"`
+	CaughtUp     CaughtUp `koanf:"caught_up"`
+	// LedgerDirectory is the validator's ledger directory; snapshot archives
+	// the validator wrote there itself count towards freshness (empty = not
+	// read). They are never pruned or downloaded against.
+	LedgerDirectory string `koanf:"ledger_directory"`
+}
+
+// CaughtUp configures when the local validator counts as caught up with the
+// cluster, comparing its getSlot against the cluster's.
+type CaughtUp struct {
+	// MaxSlotsBehind is how far the local slot may trail the cluster's for
+	// the validator to count as caught up
+	MaxSlotsBehind int `koanf:"max_slots_behind"`
+	// SkipDownloads skips downloading while the validator is caught up and
+	// a local full snapshot exists to restart from
+	SkipDownloads bool `koanf:"skip_downloads"`
+}
+
+// RoleFallback configures reading the validator's identity another way when
+// its RPC doesn't answer, e.g. on voting validators that run without one.
+type RoleFallback struct {
+	Enabled bool `koanf:"enabled"`
+}
+
+// AdminSocketPath returns the admin RPC socket to ask: AdminRPCPath, else
+// admin.rpc in LedgerDirectory, or empty for none.
+func (v *Validator) AdminSocketPath() string {
+	switch {
+	case v.Client != ClientAgave:
+		return ""
+	case v.AdminRPCPath != "":
+		return v.AdminRPCPath
+	case v.LedgerDirectory != "":
+		return filepath.Join(v.LedgerDirectory, "admin.rpc")
+	}
+	return ""
+}
+
+// What a download does when the validator becomes active mid-download.
+const (
+	OnActiveAbort = "abort"
+	OnActivePause = "pause"
+)
+
+// DefaultRoleMonitorInterval is how often the validator's identity is polled
+// during downloads when validator.role_monitor.interval is empty.
+const DefaultRoleMonitorInterval = 30 * time.Second
+
+// RoleMonitor configures how the validator's role is watched while a
+// download runs.
+type RoleMonitor struct {
+	// Interval is how often the validator's identity is polled
+	Interval string `koanf:"interval"`
+	// OnActive is "abort" to discard the download or "pause" to stop it,
+	// keeping the partial file, and resume once the validator is passive again
+	OnActive string `koanf:"on_active"`
+	// Parsed
+	IntervalDur time.Duration `koanf:"-"`
+}
+
+func (r *RoleMonitor) Validate() error {
+	if r.Interval == "" {
+		r.Interval = DefaultRoleMonitorInterval.String()
+	}
+	d, err := time.ParseDuration(r.Interval)
+	if err != nil {
+		return fmt.Errorf("validator.role_monitor.interval: %w", err)
+	}
+	if d < time.Second {
+		return fmt.Errorf("validator.role_monitor.interval must be >= 1s, got %s", r.Interval)
+	}
+	r.IntervalDur = d
+	if r.OnActive != OnActiveAbort && r.OnActive != OnActivePause {
+		return fmt.Errorf("validator.role_monitor.on_active must be %q or %q, got %q", OnActiveAbort, OnActivePause, r.OnActive)
+	}
+	return nil
 }
 
 func (v *Validator) Validate() error {
+	if v.Client == "" {
+		v.Client = ClientAgave
+	}
+	if v.Client != ClientAgave && v.Client != ClientFiredancer {
+		return fmt.Errorf("validator.client must be %q or %q, got %q", ClientAgave, ClientFiredancer, v.Client)
+	}
 	if v.RPCURL == "" {
 		return fmt.Errorf("validator.rpc_url is required")
 	}
-	if v.ActiveIdentityPubkey == "" {
+	if v.AdminRPCPath != "" && v.Client != ClientAgave {
+		return fmt.Errorf("validator.admin_rpc_path is only supported with client %q", ClientAgave)
+	}
+	if v.ActiveIdentityKeypairPath != "" {
+		pubkey, err := ReadKeypairPubkey(v.ActiveIdentityKeypairPath)
+		if err != nil {
+			return fmt.Errorf("validator.active_identity_keypair_path: %w", err)
+		}
+		if v.ActiveIdentityPubkey != "" && v.ActiveIdentityPubkey != pubkey {
+			return fmt.Errorf("validator.active_identity_pubkey %s doesn't match the keypair at %s (%s)", v.ActiveIdentityPubkey, v.ActiveIdentityKeypairPath, pubkey)
+		}
+		v.ActiveIdentityPubkey = pubkey
+	}
+	for i, pk := range v.ActiveIdentityPubkeys {
+		if pk == "" {
+			return fmt.Errorf("validator.active_identity_pubkeys[%d] is empty", i)
+		}
+	}
+	if v.ActiveIdentityPubkey == "" && len(v.ActiveIdentityPubkeys) == 0 {
 		return fmt.Errorf("validator.active_identity_pubkey is required")
 	}
-	return nil
+	if err := v.RoleMonitor.Validate(); err != nil {
+		return err
+	}
+	if v.CaughtUp.MaxSlotsBehind < 0 {
+		return fmt.Errorf("validator.caught_up.max_slots_behind must be >= 0, got %d", v.CaughtUp.MaxSlotsBehind)
+	}
+	return v.Auth.Validate("validator.auth")
+}
+
+// ActiveIdentities returns every identity the validator counts as active
+// with: active_identity_pubkey, then active_identity_pubkeys.
+func (v *Validator) ActiveIdentities() []string {
+	var ids []string
+	for _, pk := range append([]string{v.ActiveIdentityPubkey}, v.ActiveIdentityPubkeys...) {
+		if pk != "" && !slices.Contains(ids, pk) {
+			ids = append(ids, pk)
+		}
+	}
+	return ids
 }
@@ -0,0 +1,165 @@
+package config
+
+import (
+	"fmt"
+	"maps"
+	"path/filepath"
+)
+
+// ValidatorEntry is one of several validators one daemon keeps snapshots
+// for, e.g. a primary and its backup. What it sets replaces the top-level
+// setting for that validator; every other setting is shared.
+type ValidatorEntry struct {
+	// Name tells the validators apart in logs, metrics tags and --validator
+	Name                      string       `koanf:"name"`
+	Client                    string       `koanf:"client"`
+	RPCURL                    string       `koanf:"rpc_url"`
+	ActiveIdentityPubkey      string       `koanf:"active_identity_pubkey"`
+	ActiveIdentityKeypairPath string       `koanf:"active_identity_keypair_path"`
+	ActiveIdentityPubkeys     []string     `koanf:"active_identity_pubkeys"`
+	Auth                      EndpointAuth `koanf:"auth"`
+	LedgerDirectory           string       `koanf:"ledger_directory"`
+	// AdminRPCPath is the validator's admin RPC socket; no two validators
+	// may share it
+	AdminRPCPath string `koanf:"admin_rpc_path"`
+	// SnapshotsDirectory holds the validator's snapshots and its lock file;
+	// no two validators may share it
+	SnapshotsDirectory   string `koanf:"snapshots_directory"`
+	IncrementalDirectory string `koanf:"incremental_directory"`
+	TmpDirectory         string `koanf:"tmp_directory"`
+	// Schedule replaces schedule.cron for this validator
+	Schedule []string `koanf:"schedule"`
+	// StatusListenAddress and PeersListenAddress serve this validator's
+	// status endpoint and snapshots (empty = not served)
+	StatusListenAddress string `koanf:"status_listen_address"`
+	PeersListenAddress  string `koanf:"peers_listen_address"`
+}
+
+// PerValidator returns the config of each validator listed under validators,
+// or just c when none are. Valid after Validate.
+func (c *Config) PerValidator() []*Config {
+	if len(c.Validators) == 0 {
+		return []*Config{c}
+	}
+	return c.perValidator
+}
+
+// ForValidator returns the config of the validator with the given name.
+func (c *Config) ForValidator(name string) (*Config, error) {
+	var names []string
+	for _, vc := range c.perValidator {
+		if vc.Name == name {
+			return vc, nil
+		}
+		names = append(names, vc.Name)
+	}
+	if len(names) == 0 {
+		return nil, fmt.Errorf("no validators are configured")
+	}
+	return nil, fmt.Errorf("no validator named %q, must be one of: %v", name, names)
+}
+
+// expand returns c with e's settings in place of the top-level ones.
+func (c *Config) expand(e ValidatorEntry) *Config {
+	vc := *c
+	vc.Validators = nil
+	vc.perValidator = nil
+	vc.Name = e.Name
+
+	set := func(dst *string, v string) {
+		if v != "" {
+			*dst = v
+		}
+	}
+	set(&vc.Validator.Client, e.Client)
+	set(&vc.Validator.RPCURL, e.RPCURL)
+	// The entry's identity replaces the top-level one, whichever way it's set
+	if e.ActiveIdentityPubkey != "" || e.ActiveIdentityKeypairPath != "" || len(e.ActiveIdentityPubkeys) > 0 {
+		vc.Validator.ActiveIdentityPubkey = e.ActiveIdentityPubkey
+		vc.Validator.ActiveIdentityKeypairPath = e.ActiveIdentityKeypairPath
+		vc.Validator.ActiveIdentityPubkeys = e.ActiveIdentityPubkeys
+	}
+	set(&vc.Validator.LedgerDirectory, e.LedgerDirectory)
+	set(&vc.Validator.AdminRPCPath, e.AdminRPCPath)
+	if len(e.Auth.Headers) > 0 || e.Auth.BearerToken != "" {
+		vc.Validator.Auth = e.Auth
+	}
+	set(&vc.Snapshots.Directory, e.SnapshotsDirectory)
+	set(&vc.Snapshots.IncrementalDirectory, e.IncrementalDirectory)
+	set(&vc.Snapshots.Download.TmpDirectory, e.TmpDirectory)
+	if len(e.Schedule) > 0 {
+		vc.Schedule.Cron = e.Schedule
+	}
+	vc.Status.ListenAddress = e.StatusListenAddress
+	vc.Peers.ListenAddress = e.PeersListenAddress
+
+	vc.Metrics.Tags = maps.Clone(c.Metrics.Tags)
+	if vc.Metrics.Tags == nil {
+		vc.Metrics.Tags = map[string]string{}
+	}
+	vc.Metrics.Tags["validator"] = e.Name
+	return &vc
+}
+
+// validateValidators validates each validator's config, and that no two of
+// them share a name, a directory, an admin RPC socket or a listen address.
+func (c *Config) validateValidators() error {
+	if c.Status.ListenAddress != "" {
+		return fmt.Errorf("status.listen_address can't be set with validators, set status_listen_address per validator")
+	}
+	if c.Peers.ListenAddress != "" {
+		return fmt.Errorf("peers.listen_address can't be set with validators, set peers_listen_address per validator")
+	}
+
+	c.perValidator = nil
+	names := map[string]bool{}
+	dirs := map[string]string{}
+	sockets := map[string]string{}
+	addrs := map[string]string{}
+	claim := func(seen map[string]string, key, owner, what string) error {
+		if key == "" {
+			return nil
+		}
+		if other, ok := seen[key]; ok && other != owner {
+			return fmt.Errorf("validators %q and %q share the %s %s", other, owner, what, key)
+		}
+		seen[key] = owner
+		return nil
+	}
+
+	for i, e := range c.Validators {
+		if e.Name == "" {
+			return fmt.Errorf("validators[%d].name is required", i)
+		}
+		if names[e.Name] {
+			return fmt.Errorf("validators[%d].name %q is used more than once", i, e.Name)
+		}
+		names[e.Name] = true
+
+		vc := c.expand(e)
+		if err := vc.validate(); err != nil {
+			return fmt.Errorf("validators[%d] (%s): %w", i, e.Name, err)
+		}
+		for _, dir := range []string{vc.Snapshots.Directory, vc.Snapshots.IncrementalDirectory, vc.Snapshots.Download.TmpDirectory} {
+			if dir == "" {
+				continue
+			}
+			if err := claim(dirs, filepath.Clean(dir), e.Name, "directory"); err != nil {
+				return err
+			}
+		}
+		// Validators asking one socket would all report the same identity
+		if socket := vc.Validator.AdminSocketPath(); socket != "" {
+			if err := claim(sockets, filepath.Clean(socket), e.Name, "admin RPC socket"); err != nil {
+				return err
+			}
+		}
+		for _, addr := range []string{vc.Status.ListenAddress, vc.Peers.ListenAddress} {
+			if err := claim(addrs, addr, e.Name, "listen address"); err != nil {
+				return err
+			}
+		}
+		c.perValidator = append(c.perValidator, vc)
+	}
+	return nil
+}
@@ -1,5 +1,10 @@
 package constants
 
+import "time"
+
+// DefaultSlotTime is the nominal duration of a slot.
+const DefaultSlotTime = 400 * time.Millisecond
+
 const (
 	ClusterMainnetBeta = "mainnet-beta"
 	ClusterTestnet     = "testnet"
@@ -12,8 +17,25 @@ var (
 		ClusterMainnetBeta: "https://api.mainnet-beta.solana.com",
 		ClusterTestnet:     "https://api.testnet.solana.com",
 	}
+
+	// ClusterGenesisHashes identify each cluster by its genesis block
+	ClusterGenesisHashes = map[string]string{
+		ClusterMainnetBeta: "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d",
+		ClusterTestnet:     "4uhcVJyU9pJkvQyS88uRDiswHXSCkY3zQawwpjk2NsNY",
+	}
 )
 
+// ClusterForGenesisHash returns the name of the cluster with the given
+// genesis hash.
+func ClusterForGenesisHash(hash string) (string, bool) {
+	for name, h := range ClusterGenesisHashes {
+		if h == hash {
+			return name, true
+		}
+	}
+	return "", false
+}
+
 func IsValidCluster(name string) bool {
 	for _, c := range ValidClusters {
 		if c == name {
@@ -0,0 +1,215 @@
+// Package cyclereport writes a JSON file per keeper cycle recording what it
+// found, chose, downloaded and pruned, and reads them back so past cycles
+// can be reviewed with the report command.
+package cyclereport
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+const (
+	filePrefix = "cycle-"
+	fileSuffix = ".json"
+	// fileTime sorts lexically in time order
+	fileTime = "20060102T150405.000Z"
+)
+
+// ErrNoReports is returned by Last when the directory holds no reports.
+var ErrNoReports = errors.New("no cycle reports found")
+
+// Report is one cycle's record.
+type Report struct {
+	Started      time.Time `json:"started"`
+	Finished     time.Time `json:"finished"`
+	DurationMs   int64     `json:"duration_ms"`
+	Cluster      string    `json:"cluster"`
+	Validator    string    `json:"validator,omitempty"` // the validators entry, with several
+	Result       string    `json:"result"`              // success, skipped or failure
+	Reason       string    `json:"reason"`
+	Role         string    `json:"role,omitempty"`
+	Identity     string    `json:"identity,omitempty"`
+	Mode         string    `json:"mode,omitempty"` // full or incremental
+	CurrentSlot  uint64    `json:"current_slot,omitempty"`
+	LocalSlot    uint64    `json:"local_slot,omitempty"`
+	SnapshotSlot uint64    `json:"snapshot_slot,omitempty"`
+	Error        string    `json:"error,omitempty"`
+	ErrorKind    string    `json:"error_kind,omitempty"`
+	Discovery    Discovery `json:"discovery"`
+	// Candidate is the node the cycle's snapshot came from
+	Candidate *Candidate `json:"candidate,omitempty"`
+	Downloads []Download `json:"downloads,omitempty"`
+	Pruned    []Pruned   `json:"pruned,omitempty"`
+	// Phases is how long each step the cycle reached took, in order
+	Phases []Phase `json:"phases,omitempty"`
+}
+
+// Discovery is what the cycle's discovery passes saw.
+type Discovery struct {
+	ClusterNodes int `json:"cluster_nodes"` // gossip nodes left after trust and own-node filtering
+	Probed       int `json:"probed"`        // probes finished across the cycle's discovery passes
+	Suitable     int `json:"suitable"`      // of them, nodes serving a wanted snapshot
+	Attempted    int `json:"attempted"`     // candidates a download was tried from
+	// Rejections is why probed nodes were unsuitable, when no candidate was
+	// found
+	Rejections *discovery.RejectionSummary `json:"rejections,omitempty"`
+}
+
+// Candidate is the chosen snapshot node.
+type Candidate struct {
+	RPCURL    string `json:"rpc_url"`
+	Type      string `json:"type"`
+	Slot      uint64 `json:"slot"`
+	BaseSlot  uint64 `json:"base_slot,omitempty"`
+	Filename  string `json:"filename"`
+	LatencyMs int64  `json:"latency_ms"`
+	SlotAge   uint64 `json:"slot_age"`
+}
+
+// Download is one archive fetched during the cycle.
+type Download struct {
+	Path       string `json:"path"`
+	Source     string `json:"source"`
+	Bytes      int64  `json:"bytes"`
+	DurationMs int64  `json:"duration_ms"`
+	SpeedBps   int64  `json:"speed_bps"`
+	Existing   bool   `json:"existing,omitempty"` // already in the snapshot directory
+}
+
+// Pruned is a file pruning removed or quarantined during the cycle.
+type Pruned struct {
+	Path   string `json:"path"`
+	Action string `json:"action"` // delete or quarantine
+	Reason string `json:"reason"`
+}
+
+// Phase is one step of the cycle: role, freshness, download (discovery and
+// the downloads it feeds, which overlap while streaming), unpack, prune or
+// hooks.
+type Phase struct {
+	Name       string `json:"name"`
+	DurationMs int64  `json:"duration_ms"`
+}
+
+// Write saves r in dir, creating dir if needed, and returns the file's path.
+func Write(dir string, r Report) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", err
+	}
+	data, err := json.MarshalIndent(r, "", "  ")
+	if err != nil {
+		return "", err
+	}
+	name := filePrefix + r.Started.UTC().Format(fileTime)
+	if r.Validator != "" {
+		name += "-" + r.Validator
+	}
+	path := filepath.Join(dir, name+fileSuffix)
+	// Written aside and renamed so readers never see a partial report
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return "", err
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return "", err
+	}
+	return path, nil
+}
+
+// Paths returns validator's reports in dir, oldest first; "" is a config
+// running a single validator. A missing dir has none.
+func Paths(dir, validator string) ([]string, error) {
+	entries, err := os.ReadDir(dir)
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var paths []string
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if v, ok := validatorOf(e.Name()); ok && v == validator {
+			paths = append(paths, filepath.Join(dir, e.Name()))
+		}
+	}
+	slices.Sort(paths)
+	return paths, nil
+}
+
+// validatorOf returns the validator a report's file name records, or false
+// when name isn't a report.
+func validatorOf(name string) (string, bool) {
+	rest, ok := strings.CutPrefix(name, filePrefix)
+	if !ok {
+		return "", false
+	}
+	rest, ok = strings.CutSuffix(rest, fileSuffix)
+	if !ok || len(rest) < len(fileTime) {
+		return "", false
+	}
+	if _, err := time.Parse(fileTime, rest[:len(fileTime)]); err != nil {
+		return "", false
+	}
+	rest = rest[len(fileTime):]
+	if rest == "" {
+		return "", true
+	}
+	validator, ok := strings.CutPrefix(rest, "-")
+	return validator, ok && validator != ""
+}
+
+// Read loads the report at path.
+func Read(path string) (Report, error) {
+	var r Report
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return r, err
+	}
+	if err := json.Unmarshal(data, &r); err != nil {
+		return r, fmt.Errorf("parsing %s: %w", path, err)
+	}
+	return r, nil
+}
+
+// Last loads validator's newest report in dir.
+func Last(dir, validator string) (Report, error) {
+	paths, err := Paths(dir, validator)
+	if err != nil {
+		return Report{}, err
+	}
+	if len(paths) == 0 {
+		return Report{}, ErrNoReports
+	}
+	return Read(paths[len(paths)-1])
+}
+
+// Prune removes all but validator's newest keep reports in dir, leaving
+// other validators' alone; keep <= 0 keeps all.
+func Prune(dir, validator string, keep int) error {
+	if keep <= 0 {
+		return nil
+	}
+	paths, err := Paths(dir, validator)
+	if err != nil {
+		return err
+	}
+	for len(paths) > keep {
+		if err := os.Remove(paths[0]); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return err
+		}
+		paths = paths[1:]
+	}
+	return nil
+}
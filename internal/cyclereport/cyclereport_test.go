@@ -0,0 +1,88 @@
+package cyclereport
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestWriteLastPrune(t *testing.T) {
+	dir := filepath.Join(t.TempDir(), "reports")
+	if _, err := Last(dir, ""); !errors.Is(err, ErrNoReports) {
+		t.Fatalf("Last() on a missing directory = %v, want ErrNoReports", err)
+	}
+
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i := range 3 {
+		r := Report{
+			Started:      start.Add(time.Duration(i) * time.Hour),
+			Result:       "success",
+			SnapshotSlot: uint64(100 + i),
+			Pruned:       []Pruned{{Path: "/snapshots/snapshot-90-abc.tar.zst", Action: "delete", Reason: "superseded"}},
+		}
+		if _, err := Write(dir, r); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Stray files aren't reports
+	if err := os.WriteFile(filepath.Join(dir, "notes.txt"), nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	r, err := Last(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.SnapshotSlot != 102 || len(r.Pruned) != 1 || r.Pruned[0].Reason != "superseded" {
+		t.Errorf("Last() = %+v, want the newest report", r)
+	}
+
+	if err := Prune(dir, "", 2); err != nil {
+		t.Fatal(err)
+	}
+	paths, err := Paths(dir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(paths) != 2 {
+		t.Fatalf("kept %d reports, want 2", len(paths))
+	}
+	if oldest, err := Read(paths[0]); err != nil || oldest.SnapshotSlot != 101 {
+		t.Errorf("oldest kept report = %+v, %v, want slot 101", oldest, err)
+	}
+}
+
+func TestPaths_PerValidator(t *testing.T) {
+	dir := t.TempDir()
+	start := time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC)
+	for i, validator := range []string{"", "main", "backup", "main"} {
+		if _, err := Write(dir, Report{Started: start.Add(time.Duration(i) * time.Minute), Validator: validator, SnapshotSlot: uint64(100 + i)}); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for validator, want := range map[string]int{"": 1, "main": 2, "backup": 1, "other": 0} {
+		paths, err := Paths(dir, validator)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(paths) != want {
+			t.Errorf("Paths(%q) = %v, want %d reports", validator, paths, want)
+		}
+	}
+	if r, err := Last(dir, "main"); err != nil || r.SnapshotSlot != 103 {
+		t.Errorf("Last(main) = %+v, %v, want slot 103", r, err)
+	}
+
+	// Pruning one validator's reports leaves the others'
+	if err := Prune(dir, "main", 1); err != nil {
+		t.Fatal(err)
+	}
+	for validator, want := range map[string]int{"": 1, "main": 1, "backup": 1} {
+		if paths, _ := Paths(dir, validator); len(paths) != want {
+			t.Errorf("after pruning main, Paths(%q) = %v, want %d reports", validator, paths, want)
+		}
+	}
+}
@@ -0,0 +1,225 @@
+// Package delta implements rsync-style delta transfer of snapshot archives.
+//
+// A mirror publishes a signature next to each archive: per-block weak
+// (rolling) and strong checksums plus a whole-file SHA-256. A client scans an
+// older local archive (the basis) for blocks that still match and fetches
+// only the rest with HTTP range requests. Compressed archives only share
+// blocks when the mirror compresses with `zstd --rsyncable`, which resets the
+// compressor state at content-defined boundaries.
+package delta
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+)
+
+// SignatureSuffix is appended to an archive's URL or path to locate its
+// signature.
+const SignatureSuffix = ".delta.json"
+
+// DefaultBlockSize is the block size used by Sign when none is given.
+const DefaultBlockSize = 1 << 20
+
+// signatureVersion is the only signature format understood.
+const signatureVersion = 1
+
+// ErrNoSignature is returned when a source doesn't publish a signature.
+var ErrNoSignature = errors.New("no delta signature published")
+
+// strongLen is how many bytes of each block's SHA-256 are kept.
+const strongLen = 16
+
+// Block holds the checksums of one block of the target archive.
+type Block struct {
+	Weak   uint32 `json:"weak"`
+	Strong string `json:"strong"` // hex, truncated SHA-256
+}
+
+// Signature describes a target archive block by block.
+type Signature struct {
+	Version   int     `json:"version"`
+	Size      int64   `json:"size"`
+	BlockSize int     `json:"block_size"`
+	SHA256    string  `json:"sha256"` // hex, whole file
+	Blocks    []Block `json:"blocks"`
+}
+
+// Validate checks that the signature is well formed.
+func (s *Signature) Validate() error {
+	if s.Version != signatureVersion {
+		return fmt.Errorf("unsupported signature version %d", s.Version)
+	}
+	if s.BlockSize <= 0 {
+		return fmt.Errorf("invalid block size %d", s.BlockSize)
+	}
+	if s.Size < 0 {
+		return fmt.Errorf("invalid size %d", s.Size)
+	}
+	want := (s.Size + int64(s.BlockSize) - 1) / int64(s.BlockSize)
+	if int64(len(s.Blocks)) != want {
+		return fmt.Errorf("signature has %d blocks, expected %d for %d bytes", len(s.Blocks), want, s.Size)
+	}
+	if len(s.SHA256) != sha256.Size*2 {
+		return fmt.Errorf("invalid sha256 %q", s.SHA256)
+	}
+	return nil
+}
+
+// BlockLen returns the length of block i; only the last block may be short.
+func (s *Signature) BlockLen(i int) int {
+	if i == len(s.Blocks)-1 {
+		if rem := int(s.Size % int64(s.BlockSize)); rem != 0 {
+			return rem
+		}
+	}
+	return s.BlockSize
+}
+
+// Sign computes the signature of the archive read from r.
+func Sign(r io.Reader, blockSize int) (*Signature, error) {
+	if blockSize <= 0 {
+		blockSize = DefaultBlockSize
+	}
+	sig := &Signature{Version: signatureVersion, BlockSize: blockSize}
+	whole := sha256.New()
+	buf := make([]byte, blockSize)
+	for {
+		n, err := io.ReadFull(r, buf)
+		if n > 0 {
+			whole.Write(buf[:n])
+			sig.Size += int64(n)
+			sig.Blocks = append(sig.Blocks, Block{Weak: weakSum(buf[:n]), Strong: strongSum(buf[:n])})
+		}
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	sig.SHA256 = hex.EncodeToString(whole.Sum(nil))
+	return sig, nil
+}
+
+// Match scans basis for blocks of sig and returns, for each block, the
+// offset in basis holding identical content, or -1 if none does. Only
+// full-size blocks are matched; a short final block is always fetched.
+func Match(ctx context.Context, basis io.Reader, sig *Signature) ([]int64, error) {
+	matches := make([]int64, len(sig.Blocks))
+	byWeak := make(map[uint32][]int)
+	for i := range matches {
+		matches[i] = -1
+		if sig.BlockLen(i) == sig.BlockSize {
+			byWeak[sig.Blocks[i].Weak] = append(byWeak[sig.Blocks[i].Weak], i)
+		}
+	}
+	if len(byWeak) == 0 {
+		return matches, nil
+	}
+
+	bs := sig.BlockSize
+	r := bufio.NewReaderSize(basis, 256*1024)
+	window := make([]byte, bs) // ring buffer, oldest byte at head
+	var head int
+	var offset int64 // basis offset of the oldest byte in window
+
+	// fill reads a fresh window; false means the basis ended first
+	fill := func() (bool, error) {
+		head = 0
+		if _, err := io.ReadFull(r, window); err != nil {
+			if err == io.EOF || err == io.ErrUnexpectedEOF {
+				return false, nil
+			}
+			return false, err
+		}
+		return true, nil
+	}
+
+	ok, err := fill()
+	if !ok || err != nil {
+		return matches, err
+	}
+	rs := newRollingSum(window)
+	strong := sha256.New()
+
+	for scanned := 0; ; scanned++ {
+		if scanned%(1<<20) == 0 {
+			if err := ctx.Err(); err != nil {
+				return nil, err
+			}
+		}
+
+		if candidates, found := byWeak[rs.sum()]; found {
+			strong.Reset()
+			strong.Write(window[head:])
+			strong.Write(window[:head])
+			sum := hex.EncodeToString(strong.Sum(nil)[:strongLen])
+
+			matched := false
+			for _, idx := range candidates {
+				if matches[idx] == -1 && sig.Blocks[idx].Strong == sum {
+					matches[idx] = offset
+					matched = true
+				}
+			}
+			if matched {
+				offset += int64(bs)
+				if ok, err := fill(); !ok || err != nil {
+					return matches, err
+				}
+				rs = newRollingSum(window)
+				continue
+			}
+		}
+
+		c, err := r.ReadByte()
+		if err == io.EOF {
+			return matches, nil
+		}
+		if err != nil {
+			return nil, err
+		}
+		rs.roll(window[head], c)
+		window[head] = c
+		head = (head + 1) % bs
+		offset++
+	}
+}
+
+func strongSum(b []byte) string {
+	sum := sha256.Sum256(b)
+	return hex.EncodeToString(sum[:strongLen])
+}
+
+func weakSum(b []byte) uint32 {
+	return newRollingSum(b).sum()
+}
+
+// rollingSum is rsync's weak checksum, which can slide one byte at a time.
+type rollingSum struct {
+	a, b uint32
+	n    uint32
+}
+
+func newRollingSum(block []byte) rollingSum {
+	rs := rollingSum{n: uint32(len(block))}
+	for i, c := range block {
+		rs.a += uint32(c)
+		rs.b += uint32(len(block)-i) * uint32(c)
+	}
+	return rs
+}
+
+func (rs *rollingSum) roll(out, in byte) {
+	rs.a += uint32(in) - uint32(out)
+	rs.b += rs.a - rs.n*uint32(out)
+}
+
+func (rs rollingSum) sum() uint32 {
+	return rs.a&0xffff | rs.b<<16
+}
@@ -0,0 +1,91 @@
+package delta
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"testing"
+)
+
+func randomBytes(t *testing.T, n int) []byte {
+	t.Helper()
+	b := make([]byte, n)
+	if _, err := rand.Read(b); err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestSign(t *testing.T) {
+	data := randomBytes(t, 2500)
+	sig, err := Sign(bytes.NewReader(data), 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := sig.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	if sig.Size != 2500 || len(sig.Blocks) != 3 || sig.BlockLen(2) != 500 || sig.BlockLen(1) != 1000 {
+		t.Errorf("unexpected signature layout: size %d, %d blocks", sig.Size, len(sig.Blocks))
+	}
+
+	sig.Blocks = sig.Blocks[:2]
+	if err := sig.Validate(); err == nil {
+		t.Error("expected block count mismatch to be invalid")
+	}
+}
+
+func TestRollingSum(t *testing.T) {
+	data := randomBytes(t, 300)
+	const n = 64
+	rs := newRollingSum(data[:n])
+	for i := 1; i+n <= len(data); i++ {
+		rs.roll(data[i-1], data[i+n-1])
+		if want := weakSum(data[i : i+n]); rs.sum() != want {
+			t.Fatalf("rolled sum at %d = %08x, want %08x", i, rs.sum(), want)
+		}
+	}
+}
+
+func TestMatch(t *testing.T) {
+	const bs = 1024
+	shared := randomBytes(t, 4*bs)
+
+	// Target: shared content with a changed block and a short tail
+	target := append(append(append([]byte{}, shared[:2*bs]...), randomBytes(t, bs)...), shared[3*bs:]...)
+	target = append(target, randomBytes(t, 100)...)
+	sig, err := Sign(bytes.NewReader(target), bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Basis: the shared content shifted by an unaligned prefix
+	basis := append(randomBytes(t, 37), shared...)
+	matches, err := Match(context.Background(), bytes.NewReader(basis), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := []int64{37, 37 + bs, -1, 37 + 3*bs, -1}
+	if len(matches) != len(want) {
+		t.Fatalf("got %d matches, want %d", len(matches), len(want))
+	}
+	for i := range want {
+		if matches[i] != want[i] {
+			t.Errorf("block %d matched at %d, want %d", i, matches[i], want[i])
+		}
+	}
+}
+
+func TestMatch_BasisShorterThanBlock(t *testing.T) {
+	sig, _ := Sign(bytes.NewReader(randomBytes(t, 4096)), 1024)
+	matches, err := Match(context.Background(), bytes.NewReader([]byte("short")), sig)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i, m := range matches {
+		if m != -1 {
+			t.Errorf("block %d unexpectedly matched", i)
+		}
+	}
+}
@@ -0,0 +1,104 @@
+package discovery
+
+import (
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// IPv6 modes for Options.IPv6.
+const (
+	IPv6Allow   = "allow"   // probe every node, whatever its address family
+	IPv6Prefer  = "prefer"  // probe every node, and rank IPv6 candidates first
+	IPv6Require = "require" // only probe nodes with IPv6 addresses
+	IPv6Skip    = "skip"    // only probe nodes without IPv6 addresses
+)
+
+// Address families in RejectionSummary.Families.
+const (
+	familyIPv4 = "ipv4"
+	familyIPv6 = "ipv6"
+)
+
+// normalizeRPCAddress turns a gossip RPC address into a URL. IPv6 hosts are
+// bracketed (nodes sometimes publish them bare, e.g. "2001:db8::1:8899"),
+// written in canonical form, and IPv4-mapped ones are unmapped, so the same
+// node advertised both ways is only probed once.
+func normalizeRPCAddress(raw string) (string, bool) {
+	addr := raw
+	if !strings.Contains(addr, "://") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil || u.Host == "" {
+		// url.Parse rejects unbracketed IPv6 hosts; the port follows the last colon
+		scheme, hostPort, _ := strings.Cut(addr, "://")
+		i := strings.LastIndex(hostPort, ":")
+		if i < 0 {
+			return "", false
+		}
+		ip, err := netip.ParseAddr(hostPort[:i])
+		if err != nil || !ip.Is6() {
+			return "", false
+		}
+		u = &url.URL{Scheme: scheme, Host: net.JoinHostPort(ip.String(), hostPort[i+1:])}
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil {
+		host := ip.Unmap().String()
+		if port := u.Port(); port != "" {
+			u.Host = net.JoinHostPort(host, port)
+		} else if ip.Unmap().Is6() {
+			u.Host = "[" + host + "]"
+		} else {
+			u.Host = host
+		}
+	}
+	return u.String(), true
+}
+
+// addressFamily returns familyIPv6 for a URL with an IPv6 host, and
+// familyIPv4 for anything else, hostnames included.
+func addressFamily(addr string) string {
+	u, err := url.Parse(addr)
+	if err != nil {
+		return familyIPv4
+	}
+	if ip, err := netip.ParseAddr(u.Hostname()); err == nil && ip.Is6() {
+		return familyIPv6
+	}
+	return familyIPv4
+}
+
+// filterFamilies drops addresses the IPv6 mode excludes.
+func filterFamilies(addrs []string, mode string) []string {
+	if mode != IPv6Require && mode != IPv6Skip {
+		return addrs
+	}
+	var out []string
+	for _, a := range addrs {
+		if (addressFamily(a) == familyIPv6) == (mode == IPv6Require) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
+
+// candidateAddresses returns the RPC addresses of nodes to probe for
+// snapshots, peers first.
+func candidateAddresses(nodes []rpc.ClusterNode, opts Options) []string {
+	addrs := filterRegions(filterFamilies(extractRPCAddresses(nodes), opts.IPv6), opts.Geo)
+	if len(opts.Peers) == 0 {
+		return addrs
+	}
+	out := slices.Clone(opts.Peers)
+	for _, a := range addrs {
+		if !slices.Contains(opts.Peers, a) {
+			out = append(out, a)
+		}
+	}
+	return out
+}
@@ -0,0 +1,134 @@
+package discovery
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	// probeFDs is how many file descriptors a probe may hold: its own
+	// connection and an idle one kept from an earlier probe
+	probeFDs = 2
+	// probeFDReserve is left for downloads, RPC calls and log files
+	probeFDReserve = 256
+	// Auto-tuning adjusts the limit after each window of probes, never
+	// going below minAutoConcurrency
+	autoTuneWindow     = 32
+	minAutoConcurrency = 16
+	// A window whose median round trip is this many times the best
+	// window's is taken as probes queueing behind each other; within
+	// autoTuneSteady of it, the limit is raised again
+	autoTuneInflation = 2.0
+	autoTuneSteady    = 1.25
+)
+
+var fdWarning sync.Once
+
+// probeConcurrency caps the configured concurrency at what the open file
+// limit allows, warning (once) when it does.
+func probeConcurrency(configured int) int {
+	configured = max(configured, 1)
+	limit, ok := openFileLimit()
+	if !ok || limit == 0 {
+		return configured
+	}
+	allowed := max((int(min(limit, 1<<20))-probeFDReserve)/probeFDs, 1)
+	if configured <= allowed {
+		return configured
+	}
+	fdWarning.Do(func() {
+		logger().Warn(fmt.Sprintf("discovery.probe.concurrency %d is more than the open file limit of %d allows - probing at %d, raise the limit (e.g. LimitNOFILE= or ulimit -n) to probe faster", configured, limit, allowed))
+	})
+	return allowed
+}
+
+// probeLimiter bounds concurrent probes. With auto-tuning it lowers the
+// bound when probe round trips inflate, a sign that the host or its link
+// is saturated and latencies are skewed, and raises it back towards the
+// configured value while they stay near the best seen. It shrinks by
+// keeping released slots rather than waking waiters, so goroutines blocked
+// on sem are never woken needlessly.
+type probeLimiter struct {
+	sem      chan struct{}
+	autoTune bool
+
+	mu       sync.Mutex
+	held     int // slots kept back from sem to shrink the limit
+	debt     int // slots still to keep back as probes finish
+	window   []time.Duration
+	baseline time.Duration // best window median so far
+}
+
+func newProbeLimiter(concurrency int, autoTune bool) *probeLimiter {
+	return &probeLimiter{sem: make(chan struct{}, max(concurrency, 1)), autoTune: autoTune}
+}
+
+// limit returns the current bound on concurrent probes.
+func (l *probeLimiter) limit() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return cap(l.sem) - l.held - l.debt
+}
+
+// probeRTT is the round trip of a probe started at start for auto-tuning,
+// or 0 when the node sent no HTTP response. Most gossip nodes don't serve
+// RPC, and their refusals and timeouts say nothing about probes queueing.
+func probeRTT(start time.Time, err error) time.Duration {
+	var pe *probeError
+	if err != nil && (!errors.As(err, &pe) || pe.unanswered) {
+		return 0
+	}
+	return time.Since(start)
+}
+
+// release frees a probe's slot, recording its round trip (0 = it didn't
+// probe).
+func (l *probeLimiter) release(rtt time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if l.autoTune && rtt > 0 {
+		l.observe(rtt)
+	}
+	if l.debt > 0 {
+		l.debt--
+		l.held++
+		return
+	}
+	<-l.sem
+}
+
+// observe adds rtt to the window, and adjusts the limit once it's full.
+func (l *probeLimiter) observe(rtt time.Duration) {
+	l.window = append(l.window, rtt)
+	if len(l.window) < autoTuneWindow {
+		return
+	}
+	slices.Sort(l.window)
+	median := l.window[len(l.window)/2]
+	l.window = l.window[:0]
+	if l.baseline == 0 || median < l.baseline {
+		l.baseline = median
+	}
+
+	current := cap(l.sem) - l.held - l.debt
+	switch {
+	case float64(median) > autoTuneInflation*float64(l.baseline):
+		shrink := min(current/4, current-min(minAutoConcurrency, cap(l.sem)))
+		if shrink > 0 {
+			l.debt += shrink
+			logger().Debug("probe round trips inflating - lowering probe concurrency", "median", median, "baseline", l.baseline, "concurrency", current-shrink)
+		}
+	case float64(median) <= autoTuneSteady*float64(l.baseline) && (l.held > 0 || l.debt > 0):
+		grow := max(cap(l.sem)/10, 1)
+		fromDebt := min(grow, l.debt)
+		l.debt -= fromDebt
+		for range min(grow-fromDebt, l.held) {
+			<-l.sem
+			l.held--
+		}
+		logger().Debug("probe round trips steady - raising probe concurrency", "median", median, "baseline", l.baseline, "concurrency", cap(l.sem)-l.held-l.debt)
+	}
+}
@@ -0,0 +1,120 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestProbeConcurrency_OpenFileLimit(t *testing.T) {
+	if got := probeConcurrency(0); got != 1 {
+		t.Errorf("probeConcurrency(0) = %d, want 1", got)
+	}
+	if got := probeConcurrency(10); got != 10 {
+		t.Errorf("probeConcurrency(10) = %d, want 10", got)
+	}
+	limit, ok := openFileLimit()
+	if !ok || limit == 0 || limit >= 1<<20 {
+		t.Skip("no usable open file limit on this host")
+	}
+	if got, want := probeConcurrency(1<<21), max((int(limit)-probeFDReserve)/probeFDs, 1); got != want {
+		t.Errorf("probeConcurrency() = %d, want %d for an open file limit of %d", got, want, limit)
+	}
+}
+
+func TestProbeLimiter_AutoTune(t *testing.T) {
+	l := newProbeLimiter(100, true)
+	probe := func(rtt time.Duration) {
+		l.sem <- struct{}{}
+		l.release(rtt)
+	}
+	window := func(rtt time.Duration) {
+		for range autoTuneWindow {
+			probe(rtt)
+		}
+	}
+
+	window(10 * time.Millisecond)
+	if got := l.limit(); got != 100 {
+		t.Fatalf("limit after a steady window = %d, want 100", got)
+	}
+
+	// Inflated round trips shrink the limit by a quarter, but not below the minimum
+	window(50 * time.Millisecond)
+	if got := l.limit(); got != 75 {
+		t.Errorf("limit after an inflated window = %d, want 75", got)
+	}
+	for range 10 {
+		window(50 * time.Millisecond)
+	}
+	if got := l.limit(); got != minAutoConcurrency {
+		t.Errorf("limit after sustained inflation = %d, want %d", got, minAutoConcurrency)
+	}
+	if len(l.sem) != l.held {
+		t.Errorf("%d slots taken with nothing probing, want the %d held back", len(l.sem), l.held)
+	}
+
+	// Steady round trips raise it back to the configured concurrency
+	for range 20 {
+		window(11 * time.Millisecond)
+	}
+	if got := l.limit(); got != 100 {
+		t.Errorf("limit after settling = %d, want 100", got)
+	}
+	if len(l.sem) != 0 {
+		t.Errorf("%d slots still taken, want none", len(l.sem))
+	}
+}
+
+func TestProbeLimiter_Fixed(t *testing.T) {
+	l := newProbeLimiter(20, false)
+	for range 3 * autoTuneWindow {
+		l.sem <- struct{}{}
+		l.release(time.Duration(len(l.window)+1) * time.Second)
+	}
+	if got := l.limit(); got != 20 {
+		t.Errorf("limit without auto-tuning = %d, want 20", got)
+	}
+}
+
+func TestProbeLimiter_DeadNodes(t *testing.T) {
+	// Answering nodes set a fast baseline
+	answering := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer answering.Close()
+	// Dead nodes accept connections but never answer, so probes time out
+	hang := make(chan struct{})
+	dead := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-hang
+	}))
+	defer dead.Close()
+	defer close(hang)
+
+	l := newProbeLimiter(100, true)
+	opts := Options{MaxLatency: 20 * time.Millisecond}
+	probe := func(addr string) {
+		l.sem <- struct{}{}
+		start := time.Now()
+		_, err := probeNode(context.Background(), addr, "/snapshot.tar.bz2", 1000, SnapshotTypeFull, opts)
+		l.release(probeRTT(start, err))
+	}
+	batch := func(addr string) {
+		var wg sync.WaitGroup
+		for range autoTuneWindow {
+			wg.Go(func() { probe(addr) })
+		}
+		wg.Wait()
+	}
+
+	batch(answering.URL)
+	for range 3 {
+		batch(dead.URL)
+	}
+	if got := l.limit(); got != 100 {
+		t.Errorf("limit after windows of dead nodes = %d, want 100", got)
+	}
+}
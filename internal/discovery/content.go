@@ -0,0 +1,175 @@
+package discovery
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxManifestSize bounds the manifest documents read.
+const maxManifestSize = 1 << 20
+
+// ContentOptions locates snapshots by content address rather than by node:
+// manifests published by trusted operators list archives with their IPFS
+// CIDs, fetched through HTTP gateways, and web seeds. Experimental.
+type ContentOptions struct {
+	Manifests []string // manifest URLs
+	Gateways  []string // IPFS gateway base URLs, e.g. "https://ipfs.io"
+}
+
+// Enabled reports whether any manifests are configured.
+func (c ContentOptions) Enabled() bool {
+	return len(c.Manifests) > 0
+}
+
+// Manifest is the document served at a manifest URL.
+type Manifest struct {
+	Snapshots []ManifestEntry `json:"snapshots"`
+}
+
+// ManifestEntry is a published archive. SHA256 is required: gateways and
+// web seeds aren't trusted, so every download is checked against it.
+type ManifestEntry struct {
+	Filename string   `json:"filename"`
+	SHA256   string   `json:"sha256"`
+	CID      string   `json:"cid,omitempty"`
+	WebSeeds []string `json:"web_seeds,omitempty"`
+}
+
+// urls returns where the entry can be fetched: through each gateway, then
+// from its web seeds.
+func (e ManifestEntry) urls(gateways []string) []string {
+	var out []string
+	if e.CID != "" {
+		for _, g := range gateways {
+			out = append(out, strings.TrimSuffix(g, "/")+"/ipfs/"+url.PathEscape(e.CID)+"?filename="+url.QueryEscape(e.Filename))
+		}
+	}
+	return append(out, e.WebSeeds...)
+}
+
+// contentNodes returns the published archives of the given type that are
+// fresh enough and reachable, one node per gateway or web seed serving it.
+func contentNodes(ctx context.Context, currentSlot uint64, snapshotType SnapshotType, opts Options) []SnapshotNode {
+	if !opts.Content.Enabled() {
+		return nil
+	}
+	client := &http.Client{Transport: opts.Transport, Timeout: sourceProbeLatency}
+
+	var candidates []SnapshotNode
+	for _, manifestURL := range opts.Content.Manifests {
+		m, err := fetchManifest(ctx, client, manifestURL)
+		if err != nil {
+			opts.logger().Warn("could not fetch content manifest", "url", manifestURL, "error", err)
+			continue
+		}
+		for _, e := range m.Snapshots {
+			// The filename names the file written to the snapshot directory
+			if filepath.Base(e.Filename) != e.Filename {
+				opts.logger().Warn("skipping manifest entry with a path in its filename", "manifest", manifestURL, "file", e.Filename)
+				continue
+			}
+			node, err := parseSnapshotFilename(e.Filename, snapshotType)
+			if err != nil {
+				continue
+			}
+			if b, err := hex.DecodeString(e.SHA256); err != nil || len(b) != 32 {
+				opts.logger().Warn("skipping manifest entry without a valid sha256", "manifest", manifestURL, "file", e.Filename)
+				continue
+			}
+			if node.Slot > currentSlot {
+				continue
+			}
+			node.SlotAge = currentSlot - node.Slot
+			if opts.MaxSnapshotAgeSlots > 0 && node.SlotAge > uint64(opts.MaxSnapshotAgeSlots) {
+				continue
+			}
+			node.Filename = e.Filename
+			node.SHA256 = strings.ToLower(e.SHA256)
+			for _, u := range e.urls(opts.Content.Gateways) {
+				n := *node
+				n.SnapshotURL = u
+				candidates = append(candidates, n)
+			}
+		}
+	}
+
+	// Keep the copies that answer, timing each like a node probe
+	var (
+		mu        sync.Mutex
+		wg        sync.WaitGroup
+		reachable []SnapshotNode
+		sem       = make(chan struct{}, probeConcurrency(opts.ProbeConcurrency))
+	)
+	for _, n := range candidates {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			sem <- struct{}{}
+			defer func() { <-sem }()
+			latency, err := headContent(ctx, client, n.SnapshotURL)
+			if err != nil {
+				opts.logger().Debug("content source unreachable", "url", n.SnapshotURL, "error", err)
+				return
+			}
+			u, _ := url.Parse(n.SnapshotURL)
+			n.RPCURL = u.Scheme + "://" + u.Host
+			n.Latency = latency
+			mu.Lock()
+			reachable = append(reachable, n)
+			mu.Unlock()
+		}()
+	}
+	wg.Wait()
+
+	if len(candidates) > 0 {
+		opts.logger().Info(fmt.Sprintf("found %d of %d content-addressed %s snapshot copies reachable", len(reachable), len(candidates), snapshotType))
+	}
+	return reachable
+}
+
+func fetchManifest(ctx context.Context, client *http.Client, manifestURL string) (Manifest, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, manifestURL, nil)
+	if err != nil {
+		return Manifest{}, err
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return Manifest{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Manifest{}, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	var m Manifest
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxManifestSize)).Decode(&m); err != nil {
+		return Manifest{}, fmt.Errorf("decoding manifest: %w", err)
+	}
+	return m, nil
+}
+
+// headContent times a HEAD request to a gateway or web seed URL.
+func headContent(ctx context.Context, client *http.Client, u string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, u, nil)
+	if err != nil {
+		return 0, err
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return 0, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+	return time.Since(start), nil
+}
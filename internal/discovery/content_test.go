@@ -0,0 +1,93 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+const testSHA256 = "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"
+
+func TestContentNodes(t *testing.T) {
+	gateway := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/ipfs/bafyfull" || r.URL.Query().Get("filename") != "snapshot-135501000-HashA.tar.zst" {
+			http.NotFound(w, r)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer gateway.Close()
+	deadSeed := httptest.NewServer(http.NotFoundHandler())
+	defer deadSeed.Close()
+
+	manifest := Manifest{Snapshots: []ManifestEntry{
+		{Filename: "snapshot-135501000-HashA.tar.zst", SHA256: testSHA256, CID: "bafyfull", WebSeeds: []string{deadSeed.URL + "/snapshot-135501000-HashA.tar.zst"}},
+		{Filename: "snapshot-135501100-HashB.tar.zst", CID: "bafynohash"},
+		{Filename: "snapshot-135400000-HashC.tar.zst", SHA256: testSHA256, CID: "bafyold"},
+		{Filename: "incremental-snapshot-135501000-135501400-HashD.tar.zst", SHA256: testSHA256, CID: "bafyinc"},
+		{Filename: "../snapshot-135501200-HashE.tar.zst", SHA256: testSHA256, CID: "bafytraversal"},
+		{Filename: "x/snapshot-135501300-HashF.tar.zst", SHA256: testSHA256, CID: "bafynested"},
+	}}
+	manifestSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(manifest)
+	}))
+	defer manifestSrv.Close()
+
+	opts := Options{
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    4,
+		Content:             ContentOptions{Manifests: []string{manifestSrv.URL, "http://127.0.0.1:1/gone.json"}, Gateways: []string{gateway.URL + "/"}},
+	}
+	nodes := contentNodes(context.Background(), 135501500, SnapshotTypeFull, opts)
+	if len(nodes) != 1 {
+		t.Fatalf("expected the fresh, hashed full through the gateway, got %+v", nodes)
+	}
+	n := nodes[0]
+	if n.RPCURL != gateway.URL || !strings.HasPrefix(n.SnapshotURL, gateway.URL+"/ipfs/bafyfull") || n.SHA256 != testSHA256 || n.Slot != 135501000 || n.SlotAge != 500 {
+		t.Errorf("unexpected node %+v", n)
+	}
+}
+
+func TestDiscoverNodes_ContentRankedFirst(t *testing.T) {
+	node := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/snapshot-135501400-Hash.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer node.Close()
+	seed := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/manifest.json" {
+			json.NewEncoder(w).Encode(Manifest{Snapshots: []ManifestEntry{
+				{Filename: "snapshot-135501000-HashA.tar.zst", SHA256: testSHA256, WebSeeds: []string{"http://" + r.Host + "/snapshot-135501000-HashA.tar.zst"}},
+			}})
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer seed.Close()
+
+	addr := node.URL
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    10,
+		SortOrder:           "slot_age",
+		Content:             ContentOptions{Manifests: []string{seed.URL + "/manifest.json"}},
+	}
+	stream := StreamNodes(context.Background(), []rpc.ClusterNode{{Pubkey: "test", RPC: &addr}}, 135501500, SnapshotTypeFull, opts)
+	defer stream.Stop()
+
+	first, ok := stream.Next(context.Background())
+	if !ok || first.RPCURL != seed.URL || first.SHA256 == "" {
+		t.Fatalf("expected the web seed first, got %+v", first)
+	}
+	second, ok := stream.Next(context.Background())
+	if !ok || second.RPCURL != node.URL {
+		t.Errorf("expected the probed node as the fallback, got %+v", second)
+	}
+}
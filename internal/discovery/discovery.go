@@ -4,8 +4,10 @@ import (
 	"context"
 	"errors"
 	"fmt"
+	"math"
 	"net/http"
 	"regexp"
+	"slices"
 	"sort"
 	"strconv"
 	"strings"
@@ -15,11 +17,21 @@ import (
 
 	"github.com/charmbracelet/log"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/constants"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
 )
 
 func logger() *log.Logger { return log.Default().WithPrefix("discovery") }
 
+// logger tags the package's log lines with Validator, when set.
+func (o Options) logger() *log.Logger {
+	if o.Validator != "" {
+		return logger().With("validator", o.Validator)
+	}
+	return logger()
+}
+
 // SnapshotType indicates whether a snapshot is full or incremental.
 type SnapshotType string
 
@@ -38,6 +50,10 @@ type SnapshotNode struct {
 	Filename     string
 	Latency      time.Duration
 	SlotAge      uint64
+	Location     geoip.Location // zero unless Options.Geo locates nodes
+	// SHA256 is the hash a content manifest published for the archive; empty
+	// for nodes found by probing
+	SHA256 string
 }
 
 // Options configures the discovery process.
@@ -45,33 +61,78 @@ type Options struct {
 	MaxLatency          time.Duration
 	MaxSnapshotAgeSlots int
 	ProbeConcurrency    int
-	SortOrder           string // "latency" or "slot_age"
-	MinSuitable         int    // stop probing early once this many suitable nodes found (0 = probe all)
+	AutoTune            bool              // lower ProbeConcurrency while probe round trips inflate
+	SortOrder           string            // "latency", "slot_age", "score" or "availability"
+	MinSuitable         int               // stop probing early once this many suitable nodes found (0 = probe all)
+	Stream              bool              // hand out candidates as soon as they are found instead of after probing completes
+	ProbeSamples        int               // HEAD requests per node used to estimate latency (<= 1 = single request)
+	LatencyStat         string            // "min", "median" or "p90" - how samples are reduced to a single latency
+	Transport           http.RoundTripper // nil uses http.DefaultTransport
+	HealthCheck         bool              // reject nodes whose getHealth is not "ok"
+	MinVersion          string            // reject nodes whose getVersion is older than this (empty = any)
+	IPv6                string            // IPv6Allow (empty), IPv6Prefer, IPv6Require or IPv6Skip
+	MaxDuration         time.Duration     // stop probing after this long, keeping the suitable nodes found so far (0 = no limit)
+	SlotTime            time.Duration     // how long a slot takes, for logging slot ages (0 = 400ms)
+	Geo                 GeoOptions
+	// Remembered are RPC URLs of nodes that were suitable before. They are
+	// probed first, and when MinSuitable of them still are, the rest of the
+	// cluster isn't probed at all.
+	Remembered []string
+	// OnSuitable, if set, is called with each suitable node (the full
+	// snapshot of a pair) as soon as its probe succeeds
+	OnSuitable func(SnapshotNode)
+	// OnProbed, if set, is called as each probe finishes, with whether the
+	// node was suitable
+	OnProbed func(suitable bool)
+	// Validator tags log lines with the validators entry discovering, when
+	// the config runs several
+	Validator string
+	// Peers are the URLs of the operator's own keepers serving snapshots
+	// (see internal/peer). They are probed with the remembered candidates,
+	// skip region, family and RPC checks, and rank ahead of every other node.
+	Peers []string
+	// Content adds the archives published in content manifests, ranked after
+	// peers and ahead of every probed node
+	Content ContentOptions
+	// Score weighs the criteria of sort order "score"
+	Score ScoreWeights
+	// Reputation scores how reliably the source at an RPC URL served
+	// snapshots before, from 0 to 1 (nil scores every source 0.5)
+	Reputation func(rpcURL string) float64
+	// BackgroundConcurrency keeps a stream probing at this concurrency once
+	// MinSuitable is reached, so candidates found later are there to fall
+	// back on if the first ones fail (0 = stop probing at MinSuitable)
+	BackgroundConcurrency int
+	// BaseSlots, if set, records every suitable full and incremental paired
+	// probes find, whether or not the incremental matches its node's full
+	BaseSlots *BaseSlotIndex
 }
 
 var (
-	fullSnapshotRe        = regexp.MustCompile(`snapshot-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)`)
-	incrementalSnapshotRe = regexp.MustCompile(`incremental-snapshot-(\d+)-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)`)
+	fullSnapshotRe        = regexp.MustCompile(`^snapshot-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)$`)
+	incrementalSnapshotRe = regexp.MustCompile(`^incremental-snapshot-(\d+)-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)$`)
 )
 
-// DiscoverNodes probes cluster nodes for snapshot availability.
-// It returns nodes sorted by the configured sort order.
-func DiscoverNodes(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, snapshotType SnapshotType, opts Options) []SnapshotNode {
-	rpcAddresses := extractRPCAddresses(nodes)
-	logger().Info(fmt.Sprintf("probing %d nodes for %s snapshots 👉🍑😭...", len(rpcAddresses), snapshotType))
+// DiscoverNodes probes cluster nodes for snapshot availability. The report
+// lists the suitable nodes sorted by the configured sort order, and why the
+// rest were rejected.
+func DiscoverNodes(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, snapshotType SnapshotType, opts Options) DiscoveryReport {
+	rpcAddresses := candidateAddresses(nodes, opts)
+	opts.logger().Info(fmt.Sprintf("probing %d nodes for %s snapshots 👉🍑😭...", len(rpcAddresses), snapshotType))
 
 	start := time.Now()
-	results := probeNodes(ctx, rpcAddresses, currentSlot, snapshotType, opts)
+	results, summary, rejected := probeNodes(ctx, rpcAddresses, currentSlot, snapshotType, opts, nil)
+	results = append(results, contentNodes(ctx, currentSlot, snapshotType, opts)...)
 
-	sortNodes(results, opts.SortOrder)
+	opts.order(results)
 
-	logger().Info(fmt.Sprintf("probes complete in %s - found %d suitable nodes", time.Since(start), len(results)))
-	return results
+	opts.logger().Info(fmt.Sprintf("probes complete in %s - found %d suitable nodes", time.Since(start), len(results)))
+	return DiscoveryReport{Nodes: results, Rejections: summary, Rejected: rejected, Availability: availabilityOf(results)}
 }
 
 // DiscoverIncrementalForBase discovers incremental snapshots that match a specific base slot.
 func DiscoverIncrementalForBase(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, baseSlot uint64, opts Options) []SnapshotNode {
-	all := DiscoverNodes(ctx, nodes, currentSlot, SnapshotTypeIncremental, opts)
+	all := DiscoverNodes(ctx, nodes, currentSlot, SnapshotTypeIncremental, opts).Nodes
 
 	var matching []SnapshotNode
 	for _, n := range all {
@@ -80,18 +141,50 @@ func DiscoverIncrementalForBase(ctx context.Context, nodes []rpc.ClusterNode, cu
 		}
 	}
 
-	logger().Info(fmt.Sprintf("found %d of %d candidates with incremental snapshots for base slot %d", len(matching), len(all), baseSlot))
+	opts.logger().Info(fmt.Sprintf("found %d of %d candidates with incremental snapshots for base slot %d", len(matching), len(all), baseSlot))
 	return matching
 }
 
+// Reprobe probes nodes found earlier again for their current snapshot of the
+// given type, which moves on as they take new ones. Nodes found through
+// content manifests aren't probed. The suitable nodes are returned sorted by
+// the configured sort order.
+func Reprobe(ctx context.Context, nodes []SnapshotNode, currentSlot uint64, snapshotType SnapshotType, opts Options) []SnapshotNode {
+	var addresses []string
+	for _, n := range nodes {
+		if n.SHA256 == "" && !slices.Contains(addresses, n.RPCURL) {
+			addresses = append(addresses, n.RPCURL)
+		}
+	}
+	opts.MinSuitable = 0
+	results, _, _ := probeNodes(ctx, addresses, currentSlot, snapshotType, opts, nil)
+	opts.order(results)
+	return results
+}
+
+// snapshotEndpoint is the path nodes redirect to their latest snapshot of
+// the given type.
+func snapshotEndpoint(snapshotType SnapshotType) string {
+	if snapshotType == SnapshotTypeIncremental {
+		return "/incremental-snapshot.tar.bz2"
+	}
+	return "/snapshot.tar.bz2"
+}
+
 func extractRPCAddresses(nodes []rpc.ClusterNode) []string {
 	var addrs []string
+	seen := make(map[string]bool, len(nodes))
 	for _, n := range nodes {
-		if n.RPC != nil && *n.RPC != "" {
-			addr := *n.RPC
-			if !strings.Contains(addr, "://") {
-				addr = "http://" + addr
-			}
+		if n.RPC == nil || *n.RPC == "" {
+			continue
+		}
+		addr, ok := normalizeRPCAddress(*n.RPC)
+		if !ok {
+			logger().Debug("skipping node with unparseable RPC address", "pubkey", n.Pubkey, "rpc", *n.RPC)
+			continue
+		}
+		if !seen[addr] {
+			seen[addr] = true
 			addrs = append(addrs, addr)
 		}
 	}
@@ -106,6 +199,8 @@ const (
 	rejectStatusCode
 	rejectParseFail
 	rejectTooOld
+	rejectUnhealthy
+	rejectVersion
 )
 
 type probeError struct {
@@ -113,11 +208,35 @@ type probeError struct {
 	err        error
 	statusCode int    // populated for rejectStatusCode
 	slotAge    uint64 // populated for rejectTooOld
+	unanswered bool   // the node sent no HTTP response
 }
 
 func (e *probeError) Error() string { return e.err.Error() }
 func (e *probeError) Unwrap() error { return e.err }
 
+// RejectionSummary counts why probed nodes were found unsuitable.
+type RejectionSummary struct {
+	HTTPError   int64       `json:"http_error"`
+	Latency     int64       `json:"latency"`
+	StatusCode  int64       `json:"status_code"`
+	StatusCodes map[int]int `json:"status_codes,omitempty"` // actual HTTP status code counts
+	ParseFail   int64       `json:"parse_fail"`
+	TooOld      int64       `json:"too_old"`
+	Unhealthy   int64       `json:"unhealthy"`
+	Version     int64       `json:"version"`
+	// TooOldMinSlots and TooOldMaxSlots bound the slot age of nodes rejected as too old
+	TooOldMinSlots uint64 `json:"too_old_min_slots,omitempty"`
+	TooOldMaxSlots uint64 `json:"too_old_max_slots,omitempty"`
+	// Families counts probes by address family, "ipv4" or "ipv6"
+	Families map[string]FamilyProbes `json:"families,omitempty"`
+}
+
+// FamilyProbes counts the probes of one address family.
+type FamilyProbes struct {
+	Probed   int64 `json:"probed"`
+	Rejected int64 `json:"rejected"`
+}
+
 // rejectionCounters tracks why probe attempts fail, for summary logging.
 type rejectionCounters struct {
 	mu           sync.Mutex
@@ -127,13 +246,40 @@ type rejectionCounters struct {
 	statusCodes  map[int]int // actual HTTP status code counts
 	parseFail    atomic.Int64
 	tooOld       atomic.Int64
+	unhealthy    atomic.Int64
+	version      atomic.Int64
 	tooOldMinAge atomic.Uint64
 	tooOldMaxAge atomic.Uint64
+	families     map[string]FamilyProbes
+	rejected     []RejectedNode
+}
+
+func newRejectionCounters() *rejectionCounters {
+	return &rejectionCounters{statusCodes: make(map[int]int), families: make(map[string]FamilyProbes)}
 }
 
-func (r *rejectionCounters) record(err error) {
+// probed counts a probe of addr, rejected or not.
+func (r *rejectionCounters) probed(addr string, rejected bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	f := r.families[addressFamily(addr)]
+	f.Probed++
+	if rejected {
+		f.Rejected++
+	}
+	r.families[addressFamily(addr)] = f
+}
+
+func (r *rejectionCounters) record(addr string, err error) {
 	var pe *probeError
-	if !errors.As(err, &pe) {
+	reason := rejectHTTPError
+	if errors.As(err, &pe) {
+		reason = pe.reason
+	}
+	r.mu.Lock()
+	r.rejected = append(r.rejected, RejectedNode{Addr: addr, Reason: reason.String(), Error: err.Error()})
+	r.mu.Unlock()
+	if pe == nil {
 		r.httpError.Add(1)
 		return
 	}
@@ -170,31 +316,67 @@ func (r *rejectionCounters) record(err error) {
 				break
 			}
 		}
+	case rejectUnhealthy:
+		r.unhealthy.Add(1)
+	case rejectVersion:
+		r.version.Add(1)
 	}
 }
 
-func probeNodes(ctx context.Context, addresses []string, currentSlot uint64, snapshotType SnapshotType, opts Options) []SnapshotNode {
+func (r *rejectionCounters) summary() RejectionSummary {
+	r.mu.Lock()
+	codes := make(map[int]int, len(r.statusCodes))
+	for code, n := range r.statusCodes {
+		codes[code] = n
+	}
+	families := make(map[string]FamilyProbes, len(r.families))
+	for family, f := range r.families {
+		families[family] = f
+	}
+	r.mu.Unlock()
+
+	return RejectionSummary{
+		HTTPError:      r.httpError.Load(),
+		Latency:        r.latency.Load(),
+		StatusCode:     r.statusCode.Load(),
+		StatusCodes:    codes,
+		ParseFail:      r.parseFail.Load(),
+		TooOld:         r.tooOld.Load(),
+		Unhealthy:      r.unhealthy.Load(),
+		Version:        r.version.Load(),
+		TooOldMinSlots: r.tooOldMinAge.Load(),
+		TooOldMaxSlots: r.tooOldMaxAge.Load(),
+		Families:       families,
+	}
+}
+
+// probeNodes probes all addresses and returns the suitable nodes along with a
+// summary of why the rest were rejected, and each rejected node. If onFound is
+// non-nil it is called with each suitable node as soon as its probe succeeds.
+func probeNodes(ctx context.Context, addresses []string, currentSlot uint64, snapshotType SnapshotType, opts Options, onFound func(SnapshotNode)) ([]SnapshotNode, RejectionSummary, []RejectedNode) {
 	var (
 		mu         sync.Mutex
 		results    []SnapshotNode
-		sem        = make(chan struct{}, opts.ProbeConcurrency)
+		limiter    = newProbeLimiter(probeConcurrency(opts.ProbeConcurrency), opts.AutoTune)
+		background atomic.Bool // probing on past MinSuitable at reduced concurrency
+		bgSem      = make(chan struct{}, max(opts.BackgroundConcurrency, 1))
 		wg         sync.WaitGroup
 		probed     atomic.Int64
 		suitable   atomic.Int64
-		rejections = rejectionCounters{statusCodes: make(map[int]int)}
+		rejections = newRejectionCounters()
 		earlyOnce  sync.Once
 	)
 
-	endpoint := "/snapshot.tar.bz2"
-	if snapshotType == SnapshotTypeIncremental {
-		endpoint = "/incremental-snapshot.tar.bz2"
-	}
+	endpoint := snapshotEndpoint(snapshotType)
+	// Only a stream can hand out candidates found after MinSuitable
+	keepProbing := onFound != nil && opts.BackgroundConcurrency > 0
 
 	totalAddresses := len(addresses)
 
 	// Progress logging goroutine
 	probeCtx, probeCancel := context.WithCancel(ctx)
 	defer probeCancel()
+	defer limitProbeDuration(opts.MaxDuration, probeCancel, &probed, totalAddresses, &suitable)()
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
@@ -202,7 +384,7 @@ func probeNodes(ctx context.Context, addresses []string, currentSlot uint64, sna
 		for {
 			select {
 			case <-ticker.C:
-				logger().Info(fmt.Sprintf("probe progress (%d/%d, %.1f%%)", probed.Load(), totalAddresses, float64(probed.Load())/float64(totalAddresses)*100),
+				opts.logger().Info(fmt.Sprintf("probe progress (%d/%d, %.1f%%)", probed.Load(), totalAddresses, float64(probed.Load())/float64(totalAddresses)*100),
 					"suitable", suitable.Load(),
 					"elapsed_time", time.Since(start),
 				)
@@ -212,76 +394,181 @@ func probeNodes(ctx context.Context, addresses []string, currentSlot uint64, sna
 		}
 	}()
 
-	for addrIndex, addr := range addresses {
-		wg.Add(1)
-		go func(addr string) {
-			defer wg.Done()
-			defer probed.Add(1)
-
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-probeCtx.Done():
-				return
-			}
+	offset := 0
+	for pass, addrs := range probePasses(addresses, opts) {
+		if pass > 0 && !background.Load() && !sweepRest(probeCtx, suitable.Load(), opts) {
+			break
+		}
+		for i, addr := range addrs {
+			addrIndex := offset + i
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				defer probed.Add(1)
+
+				var rtt time.Duration
+				select {
+				case limiter.sem <- struct{}{}:
+					defer func() { limiter.release(rtt) }()
+				case <-probeCtx.Done():
+					return
+				}
+				if background.Load() {
+					select {
+					case bgSem <- struct{}{}:
+						defer func() { <-bgSem }()
+					case <-probeCtx.Done():
+						return
+					}
+				}
 
-			logger().Debug(fmt.Sprintf("probing node %d of %d", addrIndex+1, totalAddresses), "addr", addr, "endpoint", endpoint)
-			node, err := probeNode(probeCtx, addr, endpoint, currentSlot, snapshotType, opts)
-			if err != nil {
-				rejections.record(err)
-				logger().Debug(fmt.Sprintf("probing node %d of %d failed", addrIndex+1, totalAddresses), "addr", addr, "endpoint", endpoint, "error", err)
-				return
-			}
+				opts.logger().Debug(fmt.Sprintf("probing node %d of %d", addrIndex+1, totalAddresses), "addr", addr, "endpoint", endpoint)
+				start := time.Now()
+				node, err := probeNode(probeCtx, addr, endpoint, currentSlot, snapshotType, opts)
+				rtt = probeRTT(start, err)
+				if err == nil {
+					err = checkNodeRPC(probeCtx, addr, opts)
+				}
+				rejections.probed(addr, err != nil)
+				if opts.OnProbed != nil {
+					opts.OnProbed(err == nil)
+				}
+				if err != nil {
+					rejections.record(addr, err)
+					opts.logger().Debug(fmt.Sprintf("probing node %d of %d failed", addrIndex+1, totalAddresses), "addr", addr, "endpoint", endpoint, "error", err)
+					return
+				}
 
-			n := suitable.Add(1)
-			mu.Lock()
-			results = append(results, *node)
-			mu.Unlock()
+				n := suitable.Add(1)
+				mu.Lock()
+				results = append(results, *node)
+				mu.Unlock()
+				if onFound != nil {
+					onFound(*node)
+				}
+				if opts.OnSuitable != nil {
+					opts.OnSuitable(*node)
+				}
 
-			if opts.MinSuitable > 0 && int(n) >= opts.MinSuitable {
-				earlyOnce.Do(func() {
-					logger().Info(fmt.Sprintf("found at least %d (minimum) suitable nodes found - aborting further probes", opts.MinSuitable))
-					probeCancel()
-				})
-			}
-		}(addr)
+				if opts.MinSuitable > 0 && int(n) >= opts.MinSuitable {
+					earlyOnce.Do(func() {
+						if keepProbing {
+							opts.logger().Info(fmt.Sprintf("found at least %d (minimum) suitable nodes - probing the rest in the background at concurrency %d", opts.MinSuitable, opts.BackgroundConcurrency))
+							background.Store(true)
+							return
+						}
+						opts.logger().Info(fmt.Sprintf("found at least %d (minimum) suitable nodes found - aborting further probes", opts.MinSuitable))
+						probeCancel()
+					})
+				}
+			}(addr)
+		}
+		wg.Wait()
+		offset += len(addrs)
 	}
-
-	wg.Wait()
 	probeCancel()
+	if concurrency := limiter.limit(); concurrency < cap(limiter.sem) {
+		opts.logger().Info(fmt.Sprintf("probe concurrency auto-tuned down to %d of %d as round trips inflated", concurrency, cap(limiter.sem)))
+	}
+
+	summary := rejections.summary()
 
 	failed := int64(totalAddresses) - int64(len(results))
 	if failed > 0 {
 		args := []any{
-			"http_error", rejections.httpError.Load(),
-			"latency", rejections.latency.Load(),
-			"status_code", rejections.statusCode.Load(),
-			"parse_fail", rejections.parseFail.Load(),
-			"too_old", rejections.tooOld.Load(),
+			"http_error", summary.HTTPError,
+			"latency", summary.Latency,
+			"status_code", summary.StatusCode,
+			"parse_fail", summary.ParseFail,
+			"too_old", summary.TooOld,
 		}
-		if len(rejections.statusCodes) > 0 {
-			args = append(args, "status_codes", fmt.Sprint(rejections.statusCodes))
+		if opts.HealthCheck || opts.MinVersion != "" {
+			args = append(args, "unhealthy", summary.Unhealthy, "version", summary.Version)
 		}
-		if minAge := rejections.tooOldMinAge.Load(); minAge > 0 {
-			maxAge := rejections.tooOldMaxAge.Load()
+		if len(summary.StatusCodes) > 0 {
+			args = append(args, "status_codes", fmt.Sprint(summary.StatusCodes))
+		}
+		for _, family := range []string{familyIPv4, familyIPv6} {
+			if f, ok := summary.Families[family]; ok {
+				args = append(args, family+"_probed", f.Probed, family+"_rejected", f.Rejected)
+			}
+		}
+		if minAge := summary.TooOldMinSlots; minAge > 0 {
+			maxAge := summary.TooOldMaxSlots
 			args = append(args,
 				"too_old_min_slots", minAge,
 				"too_old_max_slots", maxAge,
-				"too_old_min_time", formatSlotDuration(minAge),
-				"too_old_max_time", formatSlotDuration(maxAge),
+				"too_old_min_time", formatSlotDuration(minAge, opts.SlotTime),
+				"too_old_max_time", formatSlotDuration(maxAge, opts.SlotTime),
 			)
 		}
-		logger().Debug("probe rejections", args...)
+		opts.logger().Debug("probe rejections", args...)
 	}
 
-	return results
+	return results, summary, rejections.rejected
+}
+
+// probePasses splits addresses into the peers and remembered ones, probed
+// first, and the rest. Without a minimum to stop at there is nothing to skip, so then
+// it is a single pass.
+func probePasses(addresses []string, opts Options) [][]string {
+	if opts.MinSuitable <= 0 || len(opts.Remembered)+len(opts.Peers) == 0 {
+		return [][]string{addresses}
+	}
+	remembered := make(map[string]bool, len(opts.Remembered)+len(opts.Peers))
+	for _, a := range slices.Concat(opts.Peers, opts.Remembered) {
+		remembered[a] = true
+	}
+	var first, rest []string
+	for _, a := range addresses {
+		if remembered[a] {
+			first = append(first, a)
+		} else {
+			rest = append(rest, a)
+		}
+	}
+	if len(first) == 0 {
+		return [][]string{addresses}
+	}
+	opts.logger().Info(fmt.Sprintf("probing %d peer and remembered candidates first", len(first)))
+	return [][]string{first, rest}
+}
+
+// sweepRest reports whether to go on to probe the rest of the cluster after
+// the remembered candidates: not when enough of them were suitable, or the
+// sweep was cut short.
+func sweepRest(ctx context.Context, suitable int64, opts Options) bool {
+	if int(suitable) >= opts.MinSuitable {
+		opts.logger().Info(fmt.Sprintf("%d peer and remembered candidates still suitable - skipping the cluster sweep", suitable))
+		return false
+	}
+	if ctx.Err() != nil {
+		return false
+	}
+	opts.logger().Info(fmt.Sprintf("%d of %d peer and remembered candidates still suitable - probing the rest of the cluster", suitable, opts.MinSuitable))
+	return true
+}
+
+// limitProbeDuration cancels a probe sweep once maxDuration has passed, so
+// discovery carries on with the suitable nodes found so far rather than
+// waiting on every slow node. The returned func stops the timer.
+func limitProbeDuration(maxDuration time.Duration, cancel context.CancelFunc, probed *atomic.Int64, total int, suitable *atomic.Int64) func() bool {
+	if maxDuration <= 0 {
+		return func() bool { return false }
+	}
+	t := time.AfterFunc(maxDuration, func() {
+		logger().Info(fmt.Sprintf("probe max duration of %s reached after %d of %d nodes - continuing with %d suitable nodes found so far", maxDuration, probed.Load(), total, suitable.Load()))
+		cancel()
+	})
+	return t.Stop
 }
 
 func probeNode(ctx context.Context, addr string, endpoint string, currentSlot uint64, snapshotType SnapshotType, opts Options) (*SnapshotNode, error) {
 	url := addr + endpoint
 
 	client := &http.Client{
-		Timeout: opts.MaxLatency * 3,
+		Transport: opts.Transport,
+		Timeout:   opts.MaxLatency * 3,
 		CheckRedirect: func(req *http.Request, via []*http.Request) error {
 			return http.ErrUseLastResponse // don't follow redirects
 		},
@@ -295,13 +582,13 @@ func probeNode(ctx context.Context, addr string, endpoint string, currentSlot ui
 
 	resp, err := client.Do(req)
 	if err != nil {
-		return nil, &probeError{reason: rejectHTTPError, err: fmt.Errorf("executing request: %w", err)}
+		return nil, &probeError{reason: rejectHTTPError, err: fmt.Errorf("executing request: %w", err), unanswered: true}
 	}
 	defer resp.Body.Close()
 
-	latency := time.Since(start)
-	if latency > opts.MaxLatency {
-		return nil, &probeError{reason: rejectLatency, err: fmt.Errorf("latency %s exceeds max %s", latency, opts.MaxLatency)}
+	firstLatency := time.Since(start)
+	if opts.ProbeSamples <= 1 && firstLatency > opts.MaxLatency {
+		return nil, &probeError{reason: rejectLatency, err: fmt.Errorf("latency %s exceeds max %s", firstLatency, opts.MaxLatency)}
 	}
 
 	// The snapshot URL comes from a redirect's Location header, or from the
@@ -313,7 +600,9 @@ func probeNode(ctx context.Context, addr string, endpoint string, currentSlot ui
 		if location == "" {
 			return nil, &probeError{reason: rejectStatusCode, err: fmt.Errorf("redirect with no Location header"), statusCode: resp.StatusCode}
 		}
-		// Location may be a full URL or just a path
+		// Location may be a full URL or just a path; only the filename is
+		// used, so the download stays on the probed node even when Location
+		// points at another host
 		parts := strings.Split(location, "/")
 		snapshotFilename = parts[len(parts)-1]
 	case http.StatusOK:
@@ -338,6 +627,23 @@ func probeNode(ctx context.Context, addr string, endpoint string, currentSlot ui
 		return nil, &probeError{reason: rejectTooOld, err: fmt.Errorf("slot age %d exceeds max %d", slotAge, opts.MaxSnapshotAgeSlots), slotAge: slotAge}
 	}
 
+	// Only spend extra samples on nodes that are otherwise suitable
+	latency := firstLatency
+	if opts.ProbeSamples > 1 {
+		samples := []time.Duration{firstLatency}
+		for len(samples) < opts.ProbeSamples {
+			d, err := sampleLatency(ctx, client, url)
+			if err != nil {
+				return nil, &probeError{reason: rejectHTTPError, err: fmt.Errorf("latency sample %d: %w", len(samples)+1, err)}
+			}
+			samples = append(samples, d)
+		}
+		latency = latencyStat(samples, opts.LatencyStat)
+		if latency > opts.MaxLatency {
+			return nil, &probeError{reason: rejectLatency, err: fmt.Errorf("%s latency %s over %d samples exceeds max %s", opts.LatencyStat, latency, len(samples), opts.MaxLatency)}
+		}
+	}
+
 	// Build the download URL from the redirect location
 	snapshotURL := addr + "/" + snapshotFilename
 
@@ -346,10 +652,51 @@ func probeNode(ctx context.Context, addr string, endpoint string, currentSlot ui
 	node.Latency = latency
 	node.SlotAge = slotAge
 	node.Filename = snapshotFilename
+	node.Location = opts.Geo.locate(addr)
 
 	return node, nil
 }
 
+// sampleLatency times a single HEAD request to url.
+func sampleLatency(ctx context.Context, client *http.Client, url string) (time.Duration, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return 0, fmt.Errorf("creating request: %w", err)
+	}
+	start := time.Now()
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, fmt.Errorf("executing request: %w", err)
+	}
+	resp.Body.Close()
+	return time.Since(start), nil
+}
+
+// latencyStat reduces latency samples to a single value. Unknown stats fall
+// back to the median.
+func latencyStat(samples []time.Duration, stat string) time.Duration {
+	if len(samples) == 0 {
+		return 0
+	}
+	sorted := append([]time.Duration(nil), samples...)
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i] < sorted[j] })
+
+	switch stat {
+	case "min":
+		return sorted[0]
+	case "p90":
+		// nearest-rank percentile
+		idx := int(math.Ceil(0.9*float64(len(sorted)))) - 1
+		return sorted[idx]
+	default:
+		mid := len(sorted) / 2
+		if len(sorted)%2 == 0 {
+			return (sorted[mid-1] + sorted[mid]) / 2
+		}
+		return sorted[mid]
+	}
+}
+
 func parseSnapshotFilename(filename string, snapshotType SnapshotType) (*SnapshotNode, error) {
 	if snapshotType == SnapshotTypeIncremental {
 		matches := incrementalSnapshotRe.FindStringSubmatch(filename)
@@ -376,15 +723,25 @@ func parseSnapshotFilename(filename string, snapshotType SnapshotType) (*Snapsho
 	}, nil
 }
 
-const slotDuration = 400 * time.Millisecond
-
-func formatSlotDuration(slots uint64) string {
-	d := (time.Duration(slots) * slotDuration).Round(time.Second)
+// formatSlotDuration formats how long slots take at slotTime, or the
+// nominal slot time when it is 0.
+func formatSlotDuration(slots uint64, slotTime time.Duration) string {
+	if slotTime <= 0 {
+		slotTime = constants.DefaultSlotTime
+	}
+	d := (time.Duration(slots) * slotTime).Round(time.Second)
 	return d.String()
 }
 
-func sortNodes(nodes []SnapshotNode, sortOrder string) {
+// sortNodes orders nodes best-first: by rank, then sortOrder. A nil rank
+// ranks every node the same.
+func sortNodes(nodes []SnapshotNode, sortOrder string, rank func(SnapshotNode) int) {
 	sort.Slice(nodes, func(i, j int) bool {
+		if rank != nil {
+			if ri, rj := rank(nodes[i]), rank(nodes[j]); ri != rj {
+				return ri < rj
+			}
+		}
 		if sortOrder == "slot_age" {
 			return nodes[i].SlotAge < nodes[j].SlotAge
 		}
@@ -403,15 +760,15 @@ type PairedSnapshotNode struct {
 // The full snapshot is not filtered by age — only the incremental must be fresh.
 // The incremental's base slot must match the full's slot.
 func DiscoverPairedNodes(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, opts Options) []PairedSnapshotNode {
-	rpcAddresses := extractRPCAddresses(nodes)
-	logger().Info("probing nodes for paired snapshots", "candidates", len(rpcAddresses))
+	rpcAddresses := candidateAddresses(nodes, opts)
+	opts.logger().Info("probing nodes for paired snapshots", "candidates", len(rpcAddresses))
 
 	start := time.Now()
 	results := probePairedNodes(ctx, rpcAddresses, currentSlot, opts)
 
-	sortPairedNodes(results, opts.SortOrder)
+	opts.orderPaired(results)
 
-	logger().Info("paired discovery complete", "suitable", len(results), "elapsed", time.Since(start))
+	opts.logger().Info("paired discovery complete", "suitable", len(results), "elapsed", time.Since(start))
 	return results
 }
 
@@ -421,6 +778,7 @@ const (
 	pairedRejectFullFailed pairedRejectReason = iota
 	pairedRejectIncrFailed
 	pairedRejectBaseSlotMismatch
+	pairedRejectRPCCheck
 )
 
 func probePairedNode(ctx context.Context, addr string, currentSlot uint64, opts Options) (*PairedSnapshotNode, pairedRejectReason, error) {
@@ -438,11 +796,22 @@ func probePairedNode(ctx context.Context, addr string, currentSlot uint64, opts
 		return nil, pairedRejectIncrFailed, fmt.Errorf("incremental probe: %w", err)
 	}
 
-	// Validate base slot matches
+	// Validate base slot matches. The incremental may still build on another
+	// node's full, so both are indexed if the node passes its RPC checks.
 	if incrNode.BaseSlot != fullNode.Slot {
+		if opts.BaseSlots != nil && checkNodeRPC(ctx, addr, opts) == nil {
+			opts.BaseSlots.addFull(*fullNode)
+			opts.BaseSlots.add(*incrNode)
+		}
 		return nil, pairedRejectBaseSlotMismatch, fmt.Errorf("base slot mismatch: incremental base %d != full slot %d", incrNode.BaseSlot, fullNode.Slot)
 	}
 
+	if err := checkNodeRPC(ctx, addr, opts); err != nil {
+		return nil, pairedRejectRPCCheck, fmt.Errorf("rpc check: %w", err)
+	}
+	opts.BaseSlots.addFull(*fullNode)
+	opts.BaseSlots.add(*incrNode)
+
 	return &PairedSnapshotNode{Full: *fullNode, Incremental: *incrNode}, 0, nil
 }
 
@@ -450,7 +819,7 @@ func probePairedNodes(ctx context.Context, addresses []string, currentSlot uint6
 	var (
 		mu       sync.Mutex
 		results  []PairedSnapshotNode
-		sem      = make(chan struct{}, opts.ProbeConcurrency)
+		sem      = make(chan struct{}, probeConcurrency(opts.ProbeConcurrency))
 		wg       sync.WaitGroup
 		probed   atomic.Int64
 		suitable atomic.Int64
@@ -458,6 +827,7 @@ func probePairedNodes(ctx context.Context, addresses []string, currentSlot uint6
 		fullFailed   atomic.Int64
 		incrFailed   atomic.Int64
 		baseMismatch atomic.Int64
+		rpcCheck     atomic.Int64
 		earlyOnce    sync.Once
 	)
 
@@ -465,6 +835,7 @@ func probePairedNodes(ctx context.Context, addresses []string, currentSlot uint6
 
 	probeCtx, probeCancel := context.WithCancel(ctx)
 	defer probeCancel()
+	defer limitProbeDuration(opts.MaxDuration, probeCancel, &probed, totalAddresses, &suitable)()
 	go func() {
 		ticker := time.NewTicker(5 * time.Second)
 		defer ticker.Stop()
@@ -472,7 +843,7 @@ func probePairedNodes(ctx context.Context, addresses []string, currentSlot uint6
 		for {
 			select {
 			case <-ticker.C:
-				logger().Info(fmt.Sprintf("paired probe progress (%d/%d, %.1f%%)", probed.Load(), totalAddresses, float64(probed.Load())/float64(totalAddresses)*100),
+				opts.logger().Info(fmt.Sprintf("paired probe progress (%d/%d, %.1f%%)", probed.Load(), totalAddresses, float64(probed.Load())/float64(totalAddresses)*100),
 					"suitable", suitable.Load(),
 					"elapsed_time", time.Since(start),
 				)
@@ -482,65 +853,86 @@ func probePairedNodes(ctx context.Context, addresses []string, currentSlot uint6
 		}
 	}()
 
-	for addrIndex, addr := range addresses {
-		wg.Add(1)
-		go func(addr string) {
-			defer wg.Done()
-			defer probed.Add(1)
-
-			select {
-			case sem <- struct{}{}:
-				defer func() { <-sem }()
-			case <-probeCtx.Done():
-				return
-			}
+	offset := 0
+	for pass, addrs := range probePasses(addresses, opts) {
+		if pass > 0 && !sweepRest(probeCtx, suitable.Load(), opts) {
+			break
+		}
+		for i, addr := range addrs {
+			addrIndex := offset + i
+			wg.Add(1)
+			go func(addr string) {
+				defer wg.Done()
+				defer probed.Add(1)
+
+				select {
+				case sem <- struct{}{}:
+					defer func() { <-sem }()
+				case <-probeCtx.Done():
+					return
+				}
 
-			logger().Debug(fmt.Sprintf("probing node %d of %d for paired snapshots", addrIndex+1, totalAddresses), "addr", addr)
-			pair, reason, err := probePairedNode(probeCtx, addr, currentSlot, opts)
-			if err != nil {
-				switch reason {
-				case pairedRejectFullFailed:
-					fullFailed.Add(1)
-				case pairedRejectIncrFailed:
-					incrFailed.Add(1)
-				case pairedRejectBaseSlotMismatch:
-					baseMismatch.Add(1)
+				opts.logger().Debug(fmt.Sprintf("probing node %d of %d for paired snapshots", addrIndex+1, totalAddresses), "addr", addr)
+				pair, reason, err := probePairedNode(probeCtx, addr, currentSlot, opts)
+				if opts.OnProbed != nil {
+					opts.OnProbed(err == nil)
+				}
+				if err != nil {
+					switch reason {
+					case pairedRejectFullFailed:
+						fullFailed.Add(1)
+					case pairedRejectIncrFailed:
+						incrFailed.Add(1)
+					case pairedRejectBaseSlotMismatch:
+						baseMismatch.Add(1)
+					case pairedRejectRPCCheck:
+						rpcCheck.Add(1)
+					}
+					opts.logger().Debug(fmt.Sprintf("paired probe node %d of %d failed", addrIndex+1, totalAddresses), "addr", addr, "error", err)
+					return
 				}
-				logger().Debug(fmt.Sprintf("paired probe node %d of %d failed", addrIndex+1, totalAddresses), "addr", addr, "error", err)
-				return
-			}
 
-			n := suitable.Add(1)
-			mu.Lock()
-			results = append(results, *pair)
-			mu.Unlock()
+				n := suitable.Add(1)
+				mu.Lock()
+				results = append(results, *pair)
+				mu.Unlock()
+				if opts.OnSuitable != nil {
+					opts.OnSuitable(pair.Full)
+				}
 
-			if opts.MinSuitable > 0 && int(n) >= opts.MinSuitable {
-				earlyOnce.Do(func() {
-					logger().Info("minimum suitable paired candidates found, stopping probes", "suitable", n, "min_suitable", opts.MinSuitable)
-					probeCancel()
-				})
-			}
-		}(addr)
+				if opts.MinSuitable > 0 && int(n) >= opts.MinSuitable {
+					earlyOnce.Do(func() {
+						opts.logger().Info("minimum suitable paired candidates found, stopping probes", "suitable", n, "min_suitable", opts.MinSuitable)
+						probeCancel()
+					})
+				}
+			}(addr)
+		}
+		wg.Wait()
+		offset += len(addrs)
 	}
-
-	wg.Wait()
 	probeCancel()
 
 	failed := int64(totalAddresses) - int64(len(results))
 	if failed > 0 {
-		logger().Info("paired probe rejections",
+		opts.logger().Info("paired probe rejections",
 			"full_failed", fullFailed.Load(),
 			"incremental_failed", incrFailed.Load(),
 			"base_slot_mismatch", baseMismatch.Load(),
+			"rpc_check_failed", rpcCheck.Load(),
 		)
 	}
 
 	return results
 }
 
-func sortPairedNodes(nodes []PairedSnapshotNode, sortOrder string) {
+func sortPairedNodes(nodes []PairedSnapshotNode, sortOrder string, rank func(SnapshotNode) int) {
 	sort.Slice(nodes, func(i, j int) bool {
+		if rank != nil {
+			if ri, rj := rank(nodes[i].Full), rank(nodes[j].Full); ri != rj {
+				return ri < rj
+			}
+		}
 		if sortOrder == "slot_age" {
 			return nodes[i].Incremental.SlotAge < nodes[j].Incremental.SlotAge
 		}
@@ -3,8 +3,11 @@ package discovery
 import (
 	"context"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
+	"reflect"
+	"sync/atomic"
 	"testing"
 	"time"
 
@@ -24,6 +27,8 @@ func TestParseSnapshotFilename_Full(t *testing.T) {
 		{"snapshot-999999999-Hash1234.tar.gz", 999999999, false},
 		{"invalid-filename.tar.zst", 0, true},
 		{"snapshot-135501350-AbCdEfGh.tar", 0, true}, // uncompressed
+		{"../snapshot-135501350-AbCdEfGh.tar.zst", 0, true},
+		{"snapshot-135501350-AbCdEfGh.tar.zst.part", 0, true},
 	}
 
 	for _, tt := range tests {
@@ -58,6 +63,7 @@ func TestParseSnapshotFilename_Incremental(t *testing.T) {
 		{"incremental-snapshot-135501350-135502000-XyZw.tar.zst", 135501350, 135502000, false},
 		{"incremental-snapshot-100-200-Hash.tar.bz2", 100, 200, false},
 		{"snapshot-100-Hash.tar.zst", 0, 0, true}, // full, not incremental
+		{"dir/incremental-snapshot-100-200-Hash.tar.zst", 0, 0, true},
 	}
 
 	for _, tt := range tests {
@@ -167,12 +173,189 @@ func TestDiscoverNodes_ConcurrentProbing(t *testing.T) {
 		SortOrder:           "latency",
 	}
 
-	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts).Nodes
 	if len(results) != 5 {
 		t.Errorf("expected 5 results, got %d", len(results))
 	}
 }
 
+func TestDiscoverNodes_MaxDuration(t *testing.T) {
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/snapshot-135501000-HashFast.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer fast.Close()
+	release := make(chan struct{})
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-release:
+		}
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	var clusterNodes []rpc.ClusterNode
+	fastAddr := fast.URL
+	clusterNodes = append(clusterNodes, rpc.ClusterNode{Pubkey: "fast", RPC: &fastAddr})
+	// Distinct paths so the slow node isn't deduplicated
+	for i := range 20 {
+		addr := fmt.Sprintf("%s/%d", slow.URL, i)
+		clusterNodes = append(clusterNodes, rpc.ClusterNode{Pubkey: "slow", RPC: &addr})
+	}
+
+	opts := Options{
+		MaxLatency:          10 * time.Second,
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    5,
+		SortOrder:           "latency",
+		MaxDuration:         200 * time.Millisecond,
+	}
+
+	start := time.Now()
+	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts).Nodes
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("discovery took %s, expected it to stop after max_duration", elapsed)
+	}
+	if len(results) != 1 || results[0].RPCURL != fast.URL {
+		t.Errorf("expected the fast node found before max_duration, got %+v", results)
+	}
+
+	paired := DiscoverPairedNodes(context.Background(), clusterNodes[1:], 135501500, opts)
+	if len(paired) != 0 {
+		t.Errorf("expected no paired nodes, got %d", len(paired))
+	}
+	if elapsed := time.Since(start); elapsed > 10*time.Second {
+		t.Errorf("paired discovery took %s, expected it to stop after max_duration", elapsed)
+	}
+}
+
+func TestDiscoverNodes_RememberedFirst(t *testing.T) {
+	var probes [3]atomic.Int64
+	servers := make([]*httptest.Server, len(probes))
+	var clusterNodes []rpc.ClusterNode
+	for i := range servers {
+		servers[i] = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			probes[i].Add(1)
+			w.Header().Set("Location", fmt.Sprintf("/snapshot-135501000-Hash%d.tar.zst", i))
+			w.WriteHeader(http.StatusFound)
+		}))
+		defer servers[i].Close()
+		addr := servers[i].URL
+		clusterNodes = append(clusterNodes, rpc.ClusterNode{Pubkey: "test", RPC: &addr})
+	}
+
+	var found atomic.Int64
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    10,
+		SortOrder:           "latency",
+		MinSuitable:         2,
+		Remembered:          []string{servers[2].URL, servers[1].URL, "http://gone:8899"},
+		OnSuitable:          func(SnapshotNode) { found.Add(1) },
+	}
+
+	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts).Nodes
+	if len(results) != 2 || found.Load() != 2 {
+		t.Errorf("expected the 2 remembered nodes, got %d (%d observed)", len(results), found.Load())
+	}
+	if probes[0].Load() != 0 {
+		t.Error("expected the cluster sweep to be skipped once the remembered nodes sufficed")
+	}
+
+	// Too few remembered nodes to stop at: the rest of the cluster is probed
+	opts.MinSuitable = 3
+	results = DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts).Nodes
+	if len(results) != 3 || probes[0].Load() != 1 {
+		t.Errorf("expected every node probed, got %d results", len(results))
+	}
+}
+
+func TestDiscoverNodes_PeersFirst(t *testing.T) {
+	newServer := func(slot int) *httptest.Server {
+		return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				// getHealth
+				w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":"ok"}`))
+				return
+			}
+			w.Header().Set("Location", fmt.Sprintf("/snapshot-%d-Hash.tar.zst", slot))
+			w.WriteHeader(http.StatusFound)
+		}))
+	}
+	node := newServer(135501400)
+	defer node.Close()
+	// Peers serve snapshots only, so would fail the health check
+	peerSrv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Location", "/snapshot-135501000-Peer.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer peerSrv.Close()
+
+	addr := node.URL
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    10,
+		SortOrder:           "slot_age",
+		HealthCheck:         true,
+		Peers:               []string{peerSrv.URL},
+	}
+	results := DiscoverNodes(context.Background(), []rpc.ClusterNode{{Pubkey: "test", RPC: &addr}}, 135501500, SnapshotTypeFull, opts).Nodes
+	if len(results) != 2 {
+		t.Fatalf("expected the peer and the cluster node, got %d", len(results))
+	}
+	if results[0].RPCURL != peerSrv.URL {
+		t.Errorf("expected the peer ranked first despite its older snapshot, got %s", results[0].RPCURL)
+	}
+}
+
+func TestDiscoverNodes_ReportsRejections(t *testing.T) {
+	handler := func(filename string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/"+filename)
+			w.WriteHeader(http.StatusFound)
+		})
+	}
+	fresh := httptest.NewServer(handler("snapshot-135501000-Fresh.tar.zst"))
+	defer fresh.Close()
+	old := httptest.NewServer(handler("snapshot-135000000-Old.tar.zst"))
+	defer old.Close()
+	missing := httptest.NewServer(http.NotFoundHandler())
+	defer missing.Close()
+
+	var clusterNodes []rpc.ClusterNode
+	for _, s := range []*httptest.Server{fresh, old, missing} {
+		addr := s.URL
+		clusterNodes = append(clusterNodes, rpc.ClusterNode{Pubkey: "test", RPC: &addr})
+	}
+	opts := Options{MaxLatency: 5 * time.Second, MaxSnapshotAgeSlots: 1000, ProbeConcurrency: 10, SortOrder: "latency"}
+	report := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+
+	if len(report.Nodes) != 1 || report.Nodes[0].RPCURL != fresh.URL {
+		t.Fatalf("expected only the fresh node to be suitable, got %+v", report.Nodes)
+	}
+	if report.Rejections.TooOld != 1 || report.Rejections.StatusCode != 1 {
+		t.Errorf("expected one too old and one status code rejection, got %+v", report.Rejections)
+	}
+	reasons := map[string]string{}
+	for _, n := range report.Rejected {
+		reasons[n.Addr] = n.Reason
+		if n.Error == "" {
+			t.Errorf("expected an error for rejected node %s", n.Addr)
+		}
+	}
+	want := map[string]string{old.URL: "too_old", missing.URL: "status_code"}
+	if fmt.Sprint(reasons) != fmt.Sprint(want) {
+		t.Errorf("rejected = %v, want %v", reasons, want)
+	}
+}
+
 func TestDiscoverNodes_SortBySlotAge(t *testing.T) {
 	slots := []int{135500000, 135501000, 135500500}
 	servers := make([]*httptest.Server, len(slots))
@@ -201,7 +384,7 @@ func TestDiscoverNodes_SortBySlotAge(t *testing.T) {
 		SortOrder:           "slot_age",
 	}
 
-	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+	results := DiscoverNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts).Nodes
 	if len(results) < 2 {
 		t.Fatal("expected at least 2 results")
 	}
@@ -382,6 +565,17 @@ func TestDiscoverPairedNodes_BaseSlotMismatch(t *testing.T) {
 	if len(results) != 0 {
 		t.Errorf("expected 0 results (base slot mismatch), got %d", len(results))
 	}
+
+	// With an index, the mismatched incremental is still recorded under its base
+	opts.BaseSlots = NewBaseSlotIndex()
+	DiscoverPairedNodes(context.Background(), nodes, 100600, opts)
+	incrs := opts.BaseSlots.Incrementals(99000)
+	if len(incrs) != 1 || incrs[0].Slot != 100500 {
+		t.Errorf("Incrementals(99000) = %+v, want the slot 100500 incremental", incrs)
+	}
+	if got := opts.BaseSlots.Incrementals(100000); len(got) != 0 {
+		t.Errorf("Incrementals(100000) = %+v, want none", got)
+	}
 }
 
 func TestProbeNode_NoAgeFilter(t *testing.T) {
@@ -431,3 +625,314 @@ func TestExtractRPCAddresses(t *testing.T) {
 		t.Errorf("expected unchanged, got %q", addrs[1])
 	}
 }
+
+func TestNormalizeRPCAddress(t *testing.T) {
+	tests := []struct {
+		raw  string
+		want string
+		ok   bool
+	}{
+		{"10.0.0.1:8899", "http://10.0.0.1:8899", true},
+		{"https://rpc.example.com", "https://rpc.example.com", true},
+		{"[2001:db8::1]:8899", "http://[2001:db8::1]:8899", true},
+		{"2001:db8::1:8899", "http://[2001:db8::1]:8899", true},
+		{"[2001:0db8:0000::0001]:8899", "http://[2001:db8::1]:8899", true},
+		{"[::ffff:10.0.0.1]:8899", "http://10.0.0.1:8899", true},
+		{"::ffff:10.0.0.1:8899", "http://10.0.0.1:8899", true},
+		{"not an address", "", false},
+	}
+	for _, tt := range tests {
+		got, ok := normalizeRPCAddress(tt.raw)
+		if got != tt.want || ok != tt.ok {
+			t.Errorf("normalizeRPCAddress(%q) = %q, %v, want %q, %v", tt.raw, got, ok, tt.want, tt.ok)
+		}
+	}
+}
+
+func TestExtractRPCAddresses_DualStackDuplicates(t *testing.T) {
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "a", RPC: strPtr("10.0.0.1:8899")},
+		{Pubkey: "a", RPC: strPtr("[::ffff:10.0.0.1]:8899")},
+		{Pubkey: "b", RPC: strPtr("2001:db8::2:8899")},
+	}
+	addrs := extractRPCAddresses(nodes)
+	want := []string{"http://10.0.0.1:8899", "http://[2001:db8::2]:8899"}
+	if fmt.Sprint(addrs) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", addrs, want)
+	}
+}
+
+func TestFilterFamilies(t *testing.T) {
+	addrs := []string{"http://10.0.0.1:8899", "http://[2001:db8::1]:8899", "https://rpc.example.com"}
+	tests := []struct {
+		mode string
+		want []string
+	}{
+		{IPv6Allow, addrs},
+		{IPv6Prefer, addrs},
+		{IPv6Require, []string{"http://[2001:db8::1]:8899"}},
+		{IPv6Skip, []string{"http://10.0.0.1:8899", "https://rpc.example.com"}},
+	}
+	for _, tt := range tests {
+		if got := filterFamilies(addrs, tt.mode); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+			t.Errorf("%s: got %v, want %v", tt.mode, got, tt.want)
+		}
+	}
+}
+
+func TestSortNodes_PreferIPv6(t *testing.T) {
+	nodes := []SnapshotNode{
+		{RPCURL: "http://10.0.0.1:8899", Latency: 10 * time.Millisecond},
+		{RPCURL: "http://[2001:db8::2]:8899", Latency: 30 * time.Millisecond},
+		{RPCURL: "http://[2001:db8::1]:8899", Latency: 20 * time.Millisecond},
+	}
+	sortNodes(nodes, "latency", Options{IPv6: IPv6Prefer}.rank)
+	var got []string
+	for _, n := range nodes {
+		got = append(got, n.RPCURL)
+	}
+	want := []string{"http://[2001:db8::1]:8899", "http://[2001:db8::2]:8899", "http://10.0.0.1:8899"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestProbeNodes_FamilyStats(t *testing.T) {
+	ln, err := net.Listen("tcp6", "[::1]:0")
+	if err != nil {
+		t.Skipf("IPv6 loopback unavailable: %v", err)
+	}
+	handler := func(filename string) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/"+filename)
+			w.WriteHeader(http.StatusFound)
+		})
+	}
+	v6 := httptest.NewUnstartedServer(handler("snapshot-135501000-Fresh.tar.zst"))
+	v6.Listener.Close()
+	v6.Listener = ln
+	v6.Start()
+	defer v6.Close()
+	v4 := httptest.NewServer(handler("snapshot-135000000-Old.tar.zst"))
+	defer v4.Close()
+
+	opts := Options{MaxLatency: 5 * time.Second, MaxSnapshotAgeSlots: 1000, ProbeConcurrency: 10}
+	results, summary, _ := probeNodes(context.Background(), []string{v4.URL, v6.URL}, 135501500, SnapshotTypeFull, opts, nil)
+	if len(results) != 1 || results[0].RPCURL != v6.URL {
+		t.Fatalf("expected only the IPv6 node to be suitable, got %+v", results)
+	}
+	want := map[string]FamilyProbes{
+		"ipv4": {Probed: 1, Rejected: 1},
+		"ipv6": {Probed: 1, Rejected: 0},
+	}
+	if fmt.Sprint(summary.Families) != fmt.Sprint(want) {
+		t.Errorf("families = %v, want %v", summary.Families, want)
+	}
+}
+
+func TestLatencyStat(t *testing.T) {
+	ms := func(n int) time.Duration { return time.Duration(n) * time.Millisecond }
+	samples := []time.Duration{ms(50), ms(10), ms(30), ms(90), ms(20), ms(40), ms(60), ms(70), ms(80), ms(100)}
+
+	tests := []struct {
+		stat     string
+		samples  []time.Duration
+		expected time.Duration
+	}{
+		{"min", samples, ms(10)},
+		{"median", samples, ms(55)},
+		{"median", []time.Duration{ms(30), ms(10), ms(20)}, ms(20)},
+		{"p90", samples, ms(90)},
+		{"p90", []time.Duration{ms(10)}, ms(10)},
+		{"", []time.Duration{ms(30), ms(10), ms(20)}, ms(20)},
+	}
+
+	for _, tt := range tests {
+		t.Run(fmt.Sprintf("%s/%d", tt.stat, len(tt.samples)), func(t *testing.T) {
+			if got := latencyStat(tt.samples, tt.stat); got != tt.expected {
+				t.Errorf("latencyStat(%q) = %s, want %s", tt.stat, got, tt.expected)
+			}
+		})
+	}
+}
+
+func TestProbeNode_MultipleSamples(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Location", "/snapshot-135501350-AbCdEfGh.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1300,
+		ProbeConcurrency:    10,
+		ProbeSamples:        3,
+		LatencyStat:         "p90",
+	}
+
+	if _, err := probeNode(context.Background(), server.URL, "/snapshot.tar.bz2", 135501400, SnapshotTypeFull, opts); err != nil {
+		t.Fatal(err)
+	}
+	if got := requests.Load(); got != 3 {
+		t.Errorf("expected 3 HEAD requests, got %d", got)
+	}
+}
+
+func TestProbeNode_MultipleSamples_SkippedWhenUnsuitable(t *testing.T) {
+	var requests atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		w.Header().Set("Location", "/snapshot-100000-AbCd.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1300,
+		ProbeConcurrency:    10,
+		ProbeSamples:        5,
+	}
+
+	if _, err := probeNode(context.Background(), server.URL, "/snapshot.tar.bz2", 200000, SnapshotTypeFull, opts); err == nil {
+		t.Fatal("expected error for too-old snapshot")
+	}
+	if got := requests.Load(); got != 1 {
+		t.Errorf("expected a single HEAD request for an unsuitable node, got %d", got)
+	}
+}
+
+func TestProbeNode_RedirectOffHost(t *testing.T) {
+	var offHost atomic.Int32
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		offHost.Add(1)
+	}))
+	defer other.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", other.URL+"/snapshots/snapshot-135501350-AbCdEfGh.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer server.Close()
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1300,
+		ProbeConcurrency:    10,
+	}
+
+	node, err := probeNode(context.Background(), server.URL, "/snapshot.tar.bz2", 135501400, SnapshotTypeFull, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expected := server.URL + "/snapshot-135501350-AbCdEfGh.tar.zst"
+	if node.SnapshotURL != expected {
+		t.Errorf("expected the download to stay on the probed node at %q, got %q", expected, node.SnapshotURL)
+	}
+	if offHost.Load() != 0 {
+		t.Error("expected the redirect target not to be contacted")
+	}
+	if node.Filename != "snapshot-135501350-AbCdEfGh.tar.zst" {
+		t.Errorf("unexpected filename %q", node.Filename)
+	}
+}
+
+func TestDiscoverPairedNodes_Availability(t *testing.T) {
+	serve := func(full, incr string) string {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch r.URL.Path {
+			case "/snapshot.tar.bz2":
+				w.Header().Set("Location", "/"+full)
+				w.WriteHeader(http.StatusFound)
+			case "/incremental-snapshot.tar.bz2":
+				w.Header().Set("Location", "/"+incr)
+				w.WriteHeader(http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+		}))
+		t.Cleanup(server.Close)
+		return server.URL
+	}
+	addrs := []string{
+		serve("snapshot-101000-HashNew.tar.zst", "incremental-snapshot-101000-101200-HashInc.tar.zst"),
+		serve("snapshot-100000-HashFull.tar.zst", "incremental-snapshot-100000-100500-HashInc.tar.zst"),
+		serve("snapshot-100000-HashFull.tar.zst", "incremental-snapshot-100000-100400-HashInc.tar.zst"),
+		// Serves the same full, but an incremental for an older one
+		serve("snapshot-100000-HashFull.tar.zst", "incremental-snapshot-99000-100550-HashInc.tar.zst"),
+	}
+	var nodes []rpc.ClusterNode
+	for i := range addrs {
+		nodes = append(nodes, rpc.ClusterNode{Pubkey: fmt.Sprintf("n%d", i), RPC: &addrs[i]})
+	}
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1300,
+		ProbeConcurrency:    10,
+		SortOrder:           "slot_age",
+		BaseSlots:           NewBaseSlotIndex(),
+	}
+	results := DiscoverPairedNodes(context.Background(), nodes, 101300, opts)
+	if len(results) != 3 || results[0].Full.Slot != 101000 {
+		t.Fatalf("expected 3 pairs, newest first, got %+v", results)
+	}
+
+	availability := opts.BaseSlots.Availability()
+	want := []FullAvailability{
+		{Slot: 100000, Sources: 3, NewestIncrementalSlot: 100500, IncrementalSources: 2},
+		{Slot: 101000, Sources: 1, NewestIncrementalSlot: 101200, IncrementalSources: 1},
+		{Slot: 99000, Sources: 0, NewestIncrementalSlot: 100550, IncrementalSources: 1},
+	}
+	if !reflect.DeepEqual(availability, want) {
+		t.Errorf("Availability() = %+v, want %+v", availability, want)
+	}
+
+	incrementalSlots := func() []uint64 {
+		var slots []uint64
+		for _, r := range results {
+			slots = append(slots, r.Incremental.Slot)
+		}
+		return slots
+	}
+	// Other sort orders are left alone
+	opts.OrderByAvailability(results, availability)
+	if slots := incrementalSlots(); !reflect.DeepEqual(slots, []uint64{101200, 100500, 100400}) {
+		t.Errorf("incremental slots with sort order slot_age = %v", slots)
+	}
+	// The widely served full's pairs come first, still in the previous order
+	opts.SortOrder = SortAvailability
+	opts.OrderByAvailability(results, availability)
+	if slots := incrementalSlots(); !reflect.DeepEqual(slots, []uint64{100500, 100400, 101200}) {
+		t.Errorf("incremental slots after ordering = %v", slots)
+	}
+}
+
+func TestOrderByAvailability_KeepsRank(t *testing.T) {
+	peer := "http://peer:8899"
+	nodes := []PairedSnapshotNode{
+		{Full: SnapshotNode{RPCURL: peer, Slot: 200}},
+		{Full: SnapshotNode{RPCURL: "http://a:8899", Slot: 100}},
+		{Full: SnapshotNode{RPCURL: "http://b:8899", Slot: 100}},
+	}
+	availability := []FullAvailability{{Slot: 100, Sources: 2}, {Slot: 200, Sources: 1}}
+	Options{SortOrder: SortAvailability, Peers: []string{peer}}.OrderByAvailability(nodes, availability)
+	if nodes[0].Full.RPCURL != peer {
+		t.Errorf("expected the peer to stay first, got %+v", nodes)
+	}
+}
+
+func TestDiscoverNodes_ReportsAvailability(t *testing.T) {
+	nodes := []SnapshotNode{
+		{SnapshotType: SnapshotTypeFull, Slot: 100},
+		{SnapshotType: SnapshotTypeFull, Slot: 100},
+		{SnapshotType: SnapshotTypeFull, Slot: 200},
+	}
+	want := []FullAvailability{{Slot: 100, Sources: 2}, {Slot: 200, Sources: 1}}
+	if got := availabilityOf(nodes); !reflect.DeepEqual(got, want) {
+		t.Errorf("availabilityOf() = %+v, want %+v", got, want)
+	}
+}
@@ -0,0 +1,108 @@
+package discovery
+
+import (
+	"fmt"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"strings"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// Exclusions are nodes never to download from: the local validator and
+// other machines the operator runs, e.g. behind the same NAT.
+type Exclusions struct {
+	Pubkeys  []string       // identity pubkeys
+	Prefixes []netip.Prefix // single addresses and ranges
+	// Hostnames are matched against RPC hosts as nodes publish them
+	Hostnames []string
+}
+
+// Enabled reports whether anything is excluded.
+func (e Exclusions) Enabled() bool {
+	return len(e.Pubkeys) > 0 || len(e.Prefixes) > 0 || len(e.Hostnames) > 0
+}
+
+// excludes reports whether n is one of the excluded nodes, by identity or by
+// the host of its gossip or RPC address.
+func (e Exclusions) excludes(n rpc.ClusterNode) bool {
+	if slices.Contains(e.Pubkeys, n.Pubkey) {
+		return true
+	}
+	hosts := []string{}
+	if host, _, err := net.SplitHostPort(n.Gossip); err == nil {
+		hosts = append(hosts, host)
+	}
+	if n.RPC != nil {
+		if addr, ok := normalizeRPCAddress(*n.RPC); ok {
+			if u, err := url.Parse(addr); err == nil {
+				hosts = append(hosts, u.Hostname())
+			}
+		}
+	}
+	for _, host := range hosts {
+		if slices.Contains(e.Hostnames, strings.ToLower(host)) {
+			return true
+		}
+		ip, err := netip.ParseAddr(host)
+		if err != nil {
+			continue
+		}
+		ip = ip.Unmap()
+		for _, p := range e.Prefixes {
+			if p.Contains(ip) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// ExcludeNodes returns the nodes e doesn't exclude.
+func ExcludeNodes(nodes []rpc.ClusterNode, e Exclusions) []rpc.ClusterNode {
+	if !e.Enabled() {
+		return nodes
+	}
+	var kept []rpc.ClusterNode
+	for _, n := range nodes {
+		if e.excludes(n) {
+			logger().Debug("excluding own node", "pubkey", n.Pubkey, "gossip", n.Gossip)
+			continue
+		}
+		kept = append(kept, n)
+	}
+	if excluded := len(nodes) - len(kept); excluded > 0 {
+		logger().Info(fmt.Sprintf("excluded %d own nodes from snapshot sources", excluded))
+	}
+	return kept
+}
+
+// LocalPrefixes returns this machine's interface addresses, each as a
+// single-address prefix. Loopback and link-local addresses are left out: a
+// node advertising one isn't necessarily this machine, e.g. on a local test
+// cluster.
+func LocalPrefixes() ([]netip.Prefix, error) {
+	addrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return nil, fmt.Errorf("listing interface addresses: %w", err)
+	}
+	var prefixes []netip.Prefix
+	for _, a := range addrs {
+		ipNet, ok := a.(*net.IPNet)
+		if !ok {
+			continue
+		}
+		ip, ok := netip.AddrFromSlice(ipNet.IP)
+		if !ok {
+			continue
+		}
+		ip = ip.Unmap()
+		if ip.IsLoopback() || ip.IsLinkLocalUnicast() {
+			continue
+		}
+		prefixes = append(prefixes, netip.PrefixFrom(ip, ip.BitLen()))
+	}
+	return prefixes, nil
+}
@@ -0,0 +1,58 @@
+package discovery
+
+import (
+	"net/netip"
+	"testing"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+func TestExcludeNodes(t *testing.T) {
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "self", Gossip: "203.0.113.1:8001", RPC: strPtr("203.0.113.1:8899")},
+		{Pubkey: "natted", Gossip: "198.51.100.7:8001", RPC: strPtr("10.0.0.5:8899")},
+		{Pubkey: "range", Gossip: "192.0.2.10:8001", RPC: strPtr("192.0.2.10:8899")},
+		{Pubkey: "v6", Gossip: "[2001:db8::1]:8001", RPC: strPtr("2001:db8::1:8899")},
+		{Pubkey: "named", Gossip: "192.0.2.200:8001", RPC: strPtr("http://RPC.Example.com:8899")},
+		{Pubkey: "other", Gossip: "192.0.2.200:8001", RPC: strPtr("192.0.2.200:8899")},
+	}
+
+	tests := []struct {
+		name string
+		ex   Exclusions
+		want []string
+	}{
+		{"disabled", Exclusions{}, []string{"self", "natted", "range", "v6", "named", "other"}},
+		{"pubkey", Exclusions{Pubkeys: []string{"self"}}, []string{"natted", "range", "v6", "named", "other"}},
+		{"gossip address", Exclusions{Prefixes: []netip.Prefix{netip.MustParsePrefix("198.51.100.7/32")}}, []string{"self", "range", "v6", "named", "other"}},
+		{"rpc address", Exclusions{Prefixes: []netip.Prefix{netip.MustParsePrefix("10.0.0.5/32")}}, []string{"self", "range", "v6", "named", "other"}},
+		{"range", Exclusions{Prefixes: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/28")}}, []string{"self", "natted", "v6", "named", "other"}},
+		{"bare ipv6", Exclusions{Prefixes: []netip.Prefix{netip.MustParsePrefix("2001:db8::1/128")}}, []string{"self", "natted", "range", "named", "other"}},
+		{"hostname", Exclusions{Hostnames: []string{"rpc.example.com"}}, []string{"self", "natted", "range", "v6", "other"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := ExcludeNodes(nodes, tt.ex)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %d nodes", tt.want, len(got))
+			}
+			for i, n := range got {
+				if n.Pubkey != tt.want[i] {
+					t.Errorf("node %d: expected %q, got %q", i, tt.want[i], n.Pubkey)
+				}
+			}
+		})
+	}
+}
+
+func TestLocalPrefixes(t *testing.T) {
+	prefixes, err := LocalPrefixes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range prefixes {
+		if !p.IsSingleIP() || p.Addr().IsLoopback() || p.Addr().Is4In6() {
+			t.Errorf("unexpected local prefix %s", p)
+		}
+	}
+}
@@ -0,0 +1,101 @@
+package discovery
+
+import (
+	"fmt"
+	"net/netip"
+	"net/url"
+	"slices"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
+)
+
+// GeoOptions filters and ranks candidates by where their IPs are. Codes are
+// uppercase, e.g. "EU" or "DE".
+type GeoOptions struct {
+	// Locate maps an IP to its location (nil disables geo enrichment)
+	Locate func(netip.Addr) geoip.Location
+	// Continents and Countries keep only nodes located in one of them, when
+	// either is set. Nodes that can't be located are dropped too.
+	Continents []string
+	Countries  []string
+	// ExcludeASNs drops nodes in these autonomous systems
+	ExcludeASNs []uint32
+	// PreferContinents and PreferCountries rank nodes located in them ahead
+	// of the rest
+	PreferContinents []string
+	PreferCountries  []string
+}
+
+func (g GeoOptions) enabled() bool { return g.Locate != nil }
+
+// locate returns the location of the node at addr, if its host is an IP.
+func (g GeoOptions) locate(addr string) geoip.Location {
+	if !g.enabled() {
+		return geoip.Location{}
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		return geoip.Location{}
+	}
+	ip, err := netip.ParseAddr(u.Hostname())
+	if err != nil {
+		return geoip.Location{}
+	}
+	return g.Locate(ip)
+}
+
+// allows reports whether a node at loc passes the region and ASN filters.
+func (g GeoOptions) allows(loc geoip.Location) bool {
+	if loc.ASN != 0 && slices.Contains(g.ExcludeASNs, loc.ASN) {
+		return false
+	}
+	if len(g.Continents) == 0 && len(g.Countries) == 0 {
+		return true
+	}
+	return loc.Continent != "" && slices.Contains(g.Continents, loc.Continent) ||
+		loc.Country != "" && slices.Contains(g.Countries, loc.Country)
+}
+
+// prefers reports whether a node at loc is in a preferred region.
+func (g GeoOptions) prefers(loc geoip.Location) bool {
+	return loc.Continent != "" && slices.Contains(g.PreferContinents, loc.Continent) ||
+		loc.Country != "" && slices.Contains(g.PreferCountries, loc.Country)
+}
+
+// filterRegions drops addresses the geo filters exclude.
+func filterRegions(addrs []string, geo GeoOptions) []string {
+	if !geo.enabled() {
+		return addrs
+	}
+	var out []string
+	for _, a := range addrs {
+		if geo.allows(geo.locate(a)) {
+			out = append(out, a)
+		}
+	}
+	if dropped := len(addrs) - len(out); dropped > 0 {
+		logger().Info(fmt.Sprintf("geo filters excluded %d of %d nodes", dropped, len(addrs)))
+	}
+	return out
+}
+
+// rank orders candidates before sort_order is applied: peers first, then
+// content-addressed copies, then nodes in a preferred region, then, with
+// IPv6Prefer, IPv6 nodes. Lower ranks come first. Sort order "score" weighs
+// region preference itself.
+func (o Options) rank(n SnapshotNode) int {
+	if slices.Contains(o.Peers, n.RPCURL) {
+		return -2
+	}
+	if n.SHA256 != "" {
+		return -1
+	}
+	r := 0
+	if o.SortOrder != SortScore && (len(o.Geo.PreferContinents) > 0 || len(o.Geo.PreferCountries) > 0) && !o.Geo.prefers(n.Location) {
+		r += 2
+	}
+	if o.IPv6 == IPv6Prefer && addressFamily(n.RPCURL) != familyIPv6 {
+		r++
+	}
+	return r
+}
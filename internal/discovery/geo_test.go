@@ -0,0 +1,73 @@
+package discovery
+
+import (
+	"fmt"
+	"net/netip"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
+)
+
+// fakeLocations locates a few documentation addresses.
+func fakeLocations(ip netip.Addr) geoip.Location {
+	return map[string]geoip.Location{
+		"192.0.2.1":    {Continent: "EU", Country: "DE", ASN: 24940},
+		"192.0.2.2":    {Continent: "EU", Country: "NL", ASN: 60781},
+		"198.51.100.1": {Continent: "NA", Country: "US", ASN: 16509},
+		"2001:db8::1":  {Continent: "AS", Country: "JP", ASN: 2516},
+	}[ip.String()]
+}
+
+func TestFilterRegions(t *testing.T) {
+	addrs := []string{
+		"http://192.0.2.1:8899",
+		"http://192.0.2.2:8899",
+		"http://198.51.100.1:8899",
+		"http://[2001:db8::1]:8899",
+		"http://203.0.113.9:8899", // not in the databases
+		"https://rpc.example.com", // not an IP
+	}
+	tests := []struct {
+		name string
+		geo  GeoOptions
+		want []string
+	}{
+		{"no filters", GeoOptions{Locate: fakeLocations}, addrs},
+		{"continent", GeoOptions{Locate: fakeLocations, Continents: []string{"EU"}}, addrs[:2]},
+		{"continent or country", GeoOptions{Locate: fakeLocations, Continents: []string{"AS"}, Countries: []string{"US"}}, addrs[2:4]},
+		{"excluded ASN", GeoOptions{Locate: fakeLocations, ExcludeASNs: []uint32{24940, 16509}}, []string{addrs[1], addrs[3], addrs[4], addrs[5]}},
+		{"disabled", GeoOptions{Continents: []string{"EU"}}, addrs},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := filterRegions(addrs, tt.geo); fmt.Sprint(got) != fmt.Sprint(tt.want) {
+				t.Errorf("got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestSortNodes_PreferRegion(t *testing.T) {
+	opts := Options{Geo: GeoOptions{Locate: fakeLocations, PreferContinents: []string{"EU"}}, IPv6: IPv6Prefer}
+	var nodes []SnapshotNode
+	for addr, latency := range map[string]int{
+		"http://198.51.100.1:8899":  5,
+		"http://192.0.2.2:8899":     40,
+		"http://192.0.2.1:8899":     30,
+		"http://[2001:db8::1]:8899": 10,
+	} {
+		nodes = append(nodes, SnapshotNode{RPCURL: addr, Latency: time.Duration(latency) * time.Millisecond, Location: opts.Geo.locate(addr)})
+	}
+	sortNodes(nodes, "latency", opts.rank)
+
+	var got []string
+	for _, n := range nodes {
+		got = append(got, n.RPCURL)
+	}
+	// Europe first, then IPv6, then the rest, each by latency
+	want := []string{"http://192.0.2.1:8899", "http://192.0.2.2:8899", "http://[2001:db8::1]:8899", "http://198.51.100.1:8899"}
+	if fmt.Sprint(got) != fmt.Sprint(want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
@@ -0,0 +1,90 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// rpcCheckTimeout bounds the getHealth/getVersion calls made to one candidate.
+const rpcCheckTimeout = 2 * time.Second
+
+// checkNodeRPC runs the optional getHealth and getVersion checks against a
+// candidate's RPC port, so snapshots aren't taken from nodes that are behind,
+// forked or running an unsupported version.
+func checkNodeRPC(ctx context.Context, addr string, opts Options) error {
+	// Peers are keepers, not RPC nodes
+	if (!opts.HealthCheck && opts.MinVersion == "") || slices.Contains(opts.Peers, addr) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, rpcCheckTimeout)
+	defer cancel()
+	client := rpc.NewClientWithOptions(addr, rpc.Options{Transport: opts.Transport})
+
+	if opts.HealthCheck {
+		if err := client.GetHealth(ctx); err != nil {
+			return &probeError{reason: rejectUnhealthy, err: err}
+		}
+	}
+
+	if opts.MinVersion != "" {
+		version, err := client.GetVersion(ctx)
+		if err != nil {
+			return &probeError{reason: rejectVersion, err: err}
+		}
+		older, err := versionLess(version, opts.MinVersion)
+		if err != nil {
+			return &probeError{reason: rejectVersion, err: err}
+		}
+		if older {
+			return &probeError{reason: rejectVersion, err: fmt.Errorf("version %s is below minimum %s", version, opts.MinVersion)}
+		}
+	}
+	return nil
+}
+
+// parseVersion parses "major.minor.patch" (missing parts are 0), ignoring a
+// leading "v" and any pre-release or build suffix.
+func parseVersion(s string) ([3]int, error) {
+	var v [3]int
+	trimmed := strings.TrimPrefix(strings.TrimSpace(s), "v")
+	if i := strings.IndexAny(trimmed, "-+ "); i >= 0 {
+		trimmed = trimmed[:i]
+	}
+	parts := strings.Split(trimmed, ".")
+	if trimmed == "" || len(parts) > 3 {
+		return v, fmt.Errorf("invalid version %q", s)
+	}
+	for i, p := range parts {
+		n, err := strconv.Atoi(p)
+		if err != nil || n < 0 {
+			return v, fmt.Errorf("invalid version %q", s)
+		}
+		v[i] = n
+	}
+	return v, nil
+}
+
+// versionLess reports whether version a is older than b.
+func versionLess(a, b string) (bool, error) {
+	va, err := parseVersion(a)
+	if err != nil {
+		return false, err
+	}
+	vb, err := parseVersion(b)
+	if err != nil {
+		return false, err
+	}
+	for i := range va {
+		if va[i] != vb[i] {
+			return va[i] < vb[i], nil
+		}
+	}
+	return false, nil
+}
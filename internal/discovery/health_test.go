@@ -0,0 +1,124 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// snapshotRPCServer serves a full snapshot redirect on HEAD and answers
+// getHealth/getVersion on POST.
+func snapshotRPCServer(t *testing.T, healthy bool, version string) *httptest.Server {
+	t.Helper()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Location", "/snapshot-100000-Hash.tar.zst")
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		var req struct {
+			ID     int    `json:"id"`
+			Method string `json:"method"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+		switch req.Method {
+		case "getHealth":
+			if healthy {
+				resp["result"] = "ok"
+			} else {
+				resp["error"] = map[string]any{"code": -32005, "message": "Node is behind by 500 slots"}
+			}
+		case "getVersion":
+			resp["result"] = map[string]any{"solana-core": version}
+		}
+		json.NewEncoder(w).Encode(resp)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestDiscoverNodes_HealthAndVersionChecks(t *testing.T) {
+	good := snapshotRPCServer(t, true, "2.1.14")
+	behind := snapshotRPCServer(t, false, "2.1.14")
+	old := snapshotRPCServer(t, true, "1.18.26")
+
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "good", RPC: strPtr(good.URL)},
+		{Pubkey: "behind", RPC: strPtr(behind.URL)},
+		{Pubkey: "old", RPC: strPtr(old.URL)},
+	}
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1300,
+		ProbeConcurrency:    10,
+		HealthCheck:         true,
+		MinVersion:          "2.0.0",
+	}
+
+	s := StreamNodes(context.Background(), nodes, 100500, SnapshotTypeFull, opts)
+	defer s.Stop()
+
+	var got []string
+	for {
+		n, ok := s.Next(context.Background())
+		if !ok {
+			break
+		}
+		got = append(got, n.RPCURL)
+	}
+	if len(got) != 1 || got[0] != good.URL {
+		t.Errorf("expected only the healthy, current node, got %v", got)
+	}
+
+	rejections := s.Rejections()
+	if rejections.Unhealthy != 1 || rejections.Version != 1 {
+		t.Errorf("expected 1 unhealthy and 1 version rejection, got %+v", rejections)
+	}
+}
+
+func TestDiscoverPairedNodes_HealthCheck(t *testing.T) {
+	behind := snapshotRPCServer(t, false, "2.1.14")
+	nodes := []rpc.ClusterNode{{Pubkey: "behind", RPC: strPtr(behind.URL)}}
+	opts := Options{
+		MaxLatency:       5 * time.Second,
+		ProbeConcurrency: 10,
+		HealthCheck:      true,
+	}
+
+	if results := DiscoverPairedNodes(context.Background(), nodes, 100500, opts); len(results) != 0 {
+		t.Errorf("expected unhealthy node to be rejected, got %d results", len(results))
+	}
+}
+
+func TestVersionLess(t *testing.T) {
+	tests := []struct {
+		a, b string
+		want bool
+	}{
+		{"2.1.14", "2.1.0", false},
+		{"2.0.9", "2.1.0", true},
+		{"1.18.26", "2", true},
+		{"2.1.0", "2.1.0", false},
+		{"v2.2.1-beta", "2.2.0", false},
+		{"2.10.0", "2.9.5", false},
+	}
+	for _, tt := range tests {
+		got, err := versionLess(tt.a, tt.b)
+		if err != nil {
+			t.Fatalf("versionLess(%q, %q): %v", tt.a, tt.b, err)
+		}
+		if got != tt.want {
+			t.Errorf("versionLess(%q, %q) = %v, want %v", tt.a, tt.b, got, tt.want)
+		}
+	}
+
+	if _, err := versionLess("unknown", "2.0.0"); err == nil {
+		t.Error("expected error for unparseable version")
+	}
+}
@@ -0,0 +1,149 @@
+package discovery
+
+import (
+	"sort"
+	"sync"
+)
+
+// BaseSlotIndex records the incrementals paired probes come across, by the
+// full snapshot slot they build on, along with the fulls they come across.
+// It spans nodes, so an incremental for a local full can be found on a node
+// that serves a different full, or whose pair was rejected because the two
+// don't match.
+type BaseSlotIndex struct {
+	mu     sync.Mutex
+	byBase map[uint64][]SnapshotNode
+	fulls  map[uint64][]SnapshotNode
+}
+
+// NewBaseSlotIndex returns an empty index.
+func NewBaseSlotIndex() *BaseSlotIndex {
+	return &BaseSlotIndex{byBase: map[uint64][]SnapshotNode{}, fulls: map[uint64][]SnapshotNode{}}
+}
+
+// addFull records a full served by a node that passed its checks.
+func (x *BaseSlotIndex) addFull(n SnapshotNode) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.fulls[n.Slot] = append(x.fulls[n.Slot], n)
+}
+
+// add records a suitable incremental. It is called from probe goroutines.
+func (x *BaseSlotIndex) add(n SnapshotNode) {
+	if x == nil {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	x.byBase[n.BaseSlot] = append(x.byBase[n.BaseSlot], n)
+}
+
+// Incrementals returns the incrementals for baseSlot, newest slot first,
+// then lowest latency.
+func (x *BaseSlotIndex) Incrementals(baseSlot uint64) []SnapshotNode {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	nodes := append([]SnapshotNode(nil), x.byBase[baseSlot]...)
+	x.mu.Unlock()
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if nodes[i].Slot != nodes[j].Slot {
+			return nodes[i].Slot > nodes[j].Slot
+		}
+		return nodes[i].Latency < nodes[j].Latency
+	})
+	return nodes
+}
+
+// FullAvailability is how widely one full snapshot slot is served.
+type FullAvailability struct {
+	Slot    uint64 `json:"slot"`
+	Sources int    `json:"sources"` // nodes serving the full
+	// NewestIncrementalSlot is the newest incremental building on the full
+	// from any node (0 = none found)
+	NewestIncrementalSlot uint64 `json:"newest_incremental_slot"`
+	IncrementalSources    int    `json:"incremental_sources"` // nodes serving an incremental for the full
+}
+
+// SortAvailability is the sort order that tries paired candidates whose
+// full is served by the most nodes first, then by latency, so a failed
+// download can fall back to another source for the same slot. Other
+// candidates are sorted by latency.
+const SortAvailability = "availability"
+
+// Availability summarizes the fulls and incrementals the index holds, most
+// redundantly served full first, then newest. Base slots only incrementals
+// were found for are listed with no full sources.
+func (x *BaseSlotIndex) Availability() []FullAvailability {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	out := make([]FullAvailability, 0, len(x.fulls))
+	for slot, fulls := range x.fulls {
+		out = append(out, x.availability(slot, len(fulls)))
+	}
+	for slot := range x.byBase {
+		if _, ok := x.fulls[slot]; !ok {
+			out = append(out, x.availability(slot, 0))
+		}
+	}
+	sort.Slice(out, func(i, j int) bool {
+		if out[i].Sources != out[j].Sources {
+			return out[i].Sources > out[j].Sources
+		}
+		return out[i].Slot > out[j].Slot
+	})
+	return out
+}
+
+// availability summarizes slot, served as a full by sources nodes. x.mu
+// must be held.
+func (x *BaseSlotIndex) availability(slot uint64, sources int) FullAvailability {
+	a := FullAvailability{Slot: slot, Sources: sources, IncrementalSources: len(x.byBase[slot])}
+	for _, n := range x.byBase[slot] {
+		a.NewestIncrementalSlot = max(a.NewestIncrementalSlot, n.Slot)
+	}
+	return a
+}
+
+// availabilityOf summarizes how widely nodes serve each full snapshot slot,
+// and each base slot of their incrementals.
+func availabilityOf(nodes []SnapshotNode) []FullAvailability {
+	x := NewBaseSlotIndex()
+	for _, n := range nodes {
+		switch n.SnapshotType {
+		case SnapshotTypeFull:
+			x.addFull(n)
+		case SnapshotTypeIncremental:
+			x.add(n)
+		}
+	}
+	return x.Availability()
+}
+
+// OrderByAvailability stably reorders paired nodes with sort order
+// SortAvailability: by rank, then pairs whose full is served by the most
+// nodes in availability first, keeping the existing latency order among
+// pairs with equally available fulls. Other sort orders are left as they
+// are.
+func (o Options) OrderByAvailability(nodes []PairedSnapshotNode, availability []FullAvailability) {
+	if o.SortOrder != SortAvailability {
+		return
+	}
+	sources := make(map[uint64]int, len(availability))
+	for _, a := range availability {
+		sources[a.Slot] = a.Sources
+	}
+	sort.SliceStable(nodes, func(i, j int) bool {
+		if ri, rj := o.rank(nodes[i].Full), o.rank(nodes[j].Full); ri != rj {
+			return ri < rj
+		}
+		return sources[nodes[i].Full.Slot] > sources[nodes[j].Full.Slot]
+	})
+}
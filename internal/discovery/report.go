@@ -0,0 +1,40 @@
+package discovery
+
+// DiscoveryReport is the outcome of a discovery pass: the suitable nodes
+// and why the rest were rejected.
+type DiscoveryReport struct {
+	Nodes      []SnapshotNode
+	Rejections RejectionSummary
+	// Rejected lists each rejected node, in the order its probe finished
+	Rejected []RejectedNode
+	// Availability is how many of the suitable nodes serve each full
+	// snapshot slot, or each base slot of their incrementals
+	Availability []FullAvailability
+}
+
+// RejectedNode records why one probed node was found unsuitable.
+type RejectedNode struct {
+	Addr   string `json:"addr"`
+	Reason string `json:"reason"` // a RejectionSummary counter, e.g. "too_old"
+	Error  string `json:"error"`
+}
+
+// String returns the reason's RejectionSummary key.
+func (r rejectReason) String() string {
+	switch r {
+	case rejectLatency:
+		return "latency"
+	case rejectStatusCode:
+		return "status_code"
+	case rejectParseFail:
+		return "parse_fail"
+	case rejectTooOld:
+		return "too_old"
+	case rejectUnhealthy:
+		return "unhealthy"
+	case rejectVersion:
+		return "version"
+	default:
+		return "http_error"
+	}
+}
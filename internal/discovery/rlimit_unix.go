@@ -0,0 +1,14 @@
+//go:build !windows
+
+package discovery
+
+import "syscall"
+
+// openFileLimit returns the process's soft limit on open files.
+func openFileLimit() (uint64, bool) {
+	var rl syscall.Rlimit
+	if err := syscall.Getrlimit(syscall.RLIMIT_NOFILE, &rl); err != nil {
+		return 0, false
+	}
+	return uint64(rl.Cur), true
+}
@@ -0,0 +1,7 @@
+package discovery
+
+// openFileLimit reports no limit: Windows doesn't cap sockets per process
+// the way RLIMIT_NOFILE does.
+func openFileLimit() (uint64, bool) {
+	return 0, false
+}
@@ -0,0 +1,126 @@
+package discovery
+
+import "sort"
+
+// SortScore is the sort order that ranks candidates by a weighted score of
+// slot age, latency, reputation and geo proximity.
+const SortScore = "score"
+
+// ScoreWeights weighs the criteria of sort order "score". Each criterion
+// scores a node from 0 (worst) to 1 (best); a weight of 0 ignores it.
+type ScoreWeights struct {
+	SlotAge    float64
+	Latency    float64
+	Reputation float64
+	Geo        float64
+}
+
+// NeutralReputation scores sources without a track record.
+const NeutralReputation = 0.5
+
+// order sorts nodes best-first with the options' rank and sort order.
+func (o Options) order(nodes []SnapshotNode) {
+	if o.SortOrder != SortScore {
+		sortNodes(nodes, o.SortOrder, o.rank)
+		return
+	}
+	scores := o.scores(nodes)
+	sort.Sort(byScore{nodes: nodes, scores: scores, rank: o.rank})
+}
+
+// orderPaired is order for paired nodes, scoring each pair by its combined
+// latency and the incremental's slot age.
+func (o Options) orderPaired(nodes []PairedSnapshotNode) {
+	if o.SortOrder != SortScore {
+		sortPairedNodes(nodes, o.SortOrder, o.rank)
+		return
+	}
+	views := make([]SnapshotNode, len(nodes))
+	for i, p := range nodes {
+		views[i] = p.Full
+		views[i].Latency = p.Full.Latency + p.Incremental.Latency
+		views[i].SlotAge = p.Incremental.SlotAge
+	}
+	scores := o.scores(views)
+	idx := make([]int, len(nodes))
+	for i := range idx {
+		idx[i] = i
+	}
+	sort.SliceStable(idx, func(a, b int) bool {
+		i, j := idx[a], idx[b]
+		if ri, rj := o.rank(views[i]), o.rank(views[j]); ri != rj {
+			return ri < rj
+		}
+		return scores[i] > scores[j]
+	})
+	sorted := make([]PairedSnapshotNode, len(nodes))
+	for i, j := range idx {
+		sorted[i] = nodes[j]
+	}
+	copy(nodes, sorted)
+}
+
+// scores returns each node's weighted score. Latency and slot age are
+// scored against the probe limits, or the worst node when there is none.
+func (o Options) scores(nodes []SnapshotNode) []float64 {
+	maxLatency := o.MaxLatency
+	maxAge := uint64(max(o.MaxSnapshotAgeSlots, 0))
+	for _, n := range nodes {
+		if o.MaxLatency <= 0 {
+			maxLatency = max(maxLatency, n.Latency)
+		}
+		if o.MaxSnapshotAgeSlots <= 0 {
+			maxAge = max(maxAge, n.SlotAge)
+		}
+	}
+
+	w := o.Score
+	scores := make([]float64, len(nodes))
+	for i, n := range nodes {
+		reputation := NeutralReputation
+		if o.Reputation != nil {
+			reputation = o.Reputation(n.RPCURL)
+		}
+		geo := 0.0
+		if o.Geo.prefers(n.Location) {
+			geo = 1
+		}
+		scores[i] = w.SlotAge*(1-ratio(float64(n.SlotAge), float64(maxAge))) +
+			w.Latency*(1-ratio(float64(n.Latency), float64(maxLatency))) +
+			w.Reputation*reputation +
+			w.Geo*geo
+	}
+	return scores
+}
+
+// ratio returns v/limit clamped to [0, 1], and 0 without a limit.
+func ratio(v, limit float64) float64 {
+	if limit <= 0 {
+		return 0
+	}
+	return min(v/limit, 1)
+}
+
+// byScore sorts nodes by rank, then highest score, then latency.
+type byScore struct {
+	nodes  []SnapshotNode
+	scores []float64
+	rank   func(SnapshotNode) int
+}
+
+func (s byScore) Len() int { return len(s.nodes) }
+
+func (s byScore) Less(i, j int) bool {
+	if ri, rj := s.rank(s.nodes[i]), s.rank(s.nodes[j]); ri != rj {
+		return ri < rj
+	}
+	if s.scores[i] != s.scores[j] {
+		return s.scores[i] > s.scores[j]
+	}
+	return s.nodes[i].Latency < s.nodes[j].Latency
+}
+
+func (s byScore) Swap(i, j int) {
+	s.nodes[i], s.nodes[j] = s.nodes[j], s.nodes[i]
+	s.scores[i], s.scores[j] = s.scores[j], s.scores[i]
+}
@@ -0,0 +1,106 @@
+package discovery
+
+import (
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
+)
+
+func TestOrder_Score(t *testing.T) {
+	nodes := func() []SnapshotNode {
+		return []SnapshotNode{
+			// fastest, but its snapshot is nearly too old
+			{RPCURL: "http://fast-stale", Latency: 10 * time.Millisecond, SlotAge: 1200},
+			// freshest, but slow
+			{RPCURL: "http://slow-fresh", Latency: 900 * time.Millisecond, SlotAge: 50},
+			// good on both
+			{RPCURL: "http://balanced", Latency: 100 * time.Millisecond, SlotAge: 200},
+		}
+	}
+	base := Options{MaxLatency: time.Second, MaxSnapshotAgeSlots: 1300, SortOrder: SortScore}
+
+	tests := []struct {
+		name  string
+		opts  func(Options) Options
+		first string
+	}{
+		{"balanced wins on equal weights", func(o Options) Options {
+			o.Score = ScoreWeights{SlotAge: 1, Latency: 1}
+			return o
+		}, "http://balanced"},
+		{"latency only", func(o Options) Options {
+			o.Score = ScoreWeights{Latency: 1}
+			return o
+		}, "http://fast-stale"},
+		{"slot age only", func(o Options) Options {
+			o.Score = ScoreWeights{SlotAge: 1}
+			return o
+		}, "http://slow-fresh"},
+		{"reputation outweighs", func(o Options) Options {
+			o.Score = ScoreWeights{SlotAge: 1, Latency: 1, Reputation: 2}
+			o.Reputation = func(rpcURL string) float64 {
+				if rpcURL == "http://slow-fresh" {
+					return 1
+				}
+				return 0
+			}
+			return o
+		}, "http://slow-fresh"},
+	}
+	for _, tt := range tests {
+		got := nodes()
+		tt.opts(base).order(got)
+		if got[0].RPCURL != tt.first {
+			t.Errorf("%s: expected %s first, got %s", tt.name, tt.first, got[0].RPCURL)
+		}
+	}
+}
+
+func TestOrder_ScoreWeighsGeo(t *testing.T) {
+	nodes := []SnapshotNode{
+		{RPCURL: "http://near", Latency: 300 * time.Millisecond, Location: geoip.Location{Continent: "EU"}},
+		{RPCURL: "http://far", Latency: 200 * time.Millisecond, Location: geoip.Location{Continent: "NA"}},
+	}
+	opts := Options{
+		MaxLatency: time.Second,
+		SortOrder:  SortScore,
+		Geo:        GeoOptions{PreferContinents: []string{"EU"}},
+	}
+
+	// With a small geo weight, the faster node still wins: region preference
+	// is part of the score rather than a rank ahead of it
+	opts.Score = ScoreWeights{Latency: 1, Geo: 0.05}
+	opts.order(nodes)
+	if nodes[0].RPCURL != "http://far" {
+		t.Errorf("expected the faster node first with a small geo weight, got %s", nodes[0].RPCURL)
+	}
+
+	opts.Score = ScoreWeights{Latency: 1, Geo: 1}
+	opts.order(nodes)
+	if nodes[0].RPCURL != "http://near" {
+		t.Errorf("expected the preferred region first with a large geo weight, got %s", nodes[0].RPCURL)
+	}
+}
+
+func TestOrderPaired_Score(t *testing.T) {
+	pair := func(name string, latency time.Duration, incAge uint64) PairedSnapshotNode {
+		return PairedSnapshotNode{
+			Full:        SnapshotNode{RPCURL: name, Latency: latency},
+			Incremental: SnapshotNode{RPCURL: name, Latency: latency, SlotAge: incAge},
+		}
+	}
+	nodes := []PairedSnapshotNode{
+		pair("http://slow", 400*time.Millisecond, 100),
+		pair("http://fast", 50*time.Millisecond, 100),
+		pair("http://stale", 50*time.Millisecond, 1200),
+	}
+	opts := Options{MaxLatency: time.Second, MaxSnapshotAgeSlots: 1300, SortOrder: SortScore, Score: ScoreWeights{SlotAge: 1, Latency: 1}}
+	opts.orderPaired(nodes)
+	want := []string{"http://fast", "http://slow", "http://stale"}
+	for i, n := range nodes {
+		if n.Full.RPCURL != want[i] || n.Incremental.RPCURL != want[i] {
+			t.Fatalf("order = %v, want %v", nodes, want)
+		}
+	}
+}
@@ -0,0 +1,67 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/url"
+	"path"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// sourceProbeLatency bounds the probe of an explicitly chosen source, which
+// isn't held to max_latency.
+const sourceProbeLatency = 10 * time.Second
+
+// ProbeSource resolves the snapshot of the given type served at addr (a
+// node's RPC URL or a mirror), without latency or slot age limits.
+func ProbeSource(ctx context.Context, addr string, snapshotType SnapshotType, opts Options) (SnapshotNode, error) {
+	opts.MaxLatency = sourceProbeLatency
+	opts.MaxSnapshotAgeSlots = 0
+	opts.ProbeSamples = 1
+	node, err := probeNode(ctx, addr, snapshotEndpoint(snapshotType), math.MaxUint64, snapshotType, opts)
+	if err != nil {
+		return SnapshotNode{}, err
+	}
+	// Age isn't known without the cluster slot
+	node.SlotAge = 0
+	return *node, nil
+}
+
+// SourceFromURL parses the URL of a snapshot archive. ok is false when the
+// URL doesn't end in a full or incremental snapshot archive name.
+func SourceFromURL(rawURL string) (node SnapshotNode, ok bool) {
+	u, err := url.Parse(rawURL)
+	if err != nil || u.Host == "" {
+		return SnapshotNode{}, false
+	}
+	filename := path.Base(u.Path)
+	parsed, err := parseSnapshotFilename(filename, SnapshotTypeIncremental)
+	if err != nil {
+		if parsed, err = parseSnapshotFilename(filename, SnapshotTypeFull); err != nil {
+			return SnapshotNode{}, false
+		}
+	}
+	parsed.RPCURL = u.Scheme + "://" + u.Host
+	parsed.SnapshotURL = rawURL
+	parsed.Filename = filename
+	return *parsed, true
+}
+
+// NodeAddress returns the RPC address of the cluster node with the given
+// identity pubkey.
+func NodeAddress(nodes []rpc.ClusterNode, pubkey string) (string, error) {
+	for _, n := range nodes {
+		if n.Pubkey != pubkey {
+			continue
+		}
+		addrs := extractRPCAddresses([]rpc.ClusterNode{n})
+		if len(addrs) == 0 {
+			return "", fmt.Errorf("node %s does not advertise an RPC address", pubkey)
+		}
+		return addrs[0], nil
+	}
+	return "", fmt.Errorf("node %s not found in cluster nodes", pubkey)
+}
@@ -0,0 +1,84 @@
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+func TestSourceFromURL(t *testing.T) {
+	tests := []struct {
+		url      string
+		wantOK   bool
+		wantType SnapshotType
+		wantSlot uint64
+		wantBase uint64
+		wantRPC  string
+	}{
+		{"https://mirror.example.com/mainnet/snapshot-100000-HashA.tar.zst", true, SnapshotTypeFull, 100000, 0, "https://mirror.example.com"},
+		{"http://10.0.0.1:8899/incremental-snapshot-100000-100500-HashB.tar.zst", true, SnapshotTypeIncremental, 100500, 100000, "http://10.0.0.1:8899"},
+		{"http://10.0.0.1:8899", false, "", 0, 0, ""},
+		{"http://10.0.0.1:8899/snapshot.tar.bz2", false, "", 0, 0, ""},
+		{"NodePubkey1111111111111111111111111111111111", false, "", 0, 0, ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.url, func(t *testing.T) {
+			node, ok := SourceFromURL(tt.url)
+			if ok != tt.wantOK {
+				t.Fatalf("expected ok=%v, got %v", tt.wantOK, ok)
+			}
+			if !ok {
+				return
+			}
+			if node.SnapshotType != tt.wantType || node.Slot != tt.wantSlot || node.BaseSlot != tt.wantBase || node.RPCURL != tt.wantRPC || node.SnapshotURL != tt.url {
+				t.Errorf("unexpected node %+v", node)
+			}
+		})
+	}
+}
+
+func TestNodeAddress(t *testing.T) {
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "a", RPC: strPtr("10.0.0.1:8899")},
+		{Pubkey: "b"},
+	}
+	if addr, err := NodeAddress(nodes, "a"); err != nil || addr != "http://10.0.0.1:8899" {
+		t.Errorf("expected http://10.0.0.1:8899, got %q (%v)", addr, err)
+	}
+	if _, err := NodeAddress(nodes, "b"); err == nil {
+		t.Error("expected error for node without RPC address")
+	}
+	if _, err := NodeAddress(nodes, "c"); err == nil {
+		t.Error("expected error for unknown node")
+	}
+}
+
+func TestProbeSource_IgnoresAgeAndLatencyLimits(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(20 * time.Millisecond)
+		if r.URL.Path == "/incremental-snapshot.tar.bz2" {
+			w.Header().Set("Location", "/incremental-snapshot-100-150-Hash.tar.zst")
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		w.WriteHeader(http.StatusNotFound)
+	}))
+	defer server.Close()
+
+	opts := Options{MaxLatency: time.Millisecond, MaxSnapshotAgeSlots: 1}
+	node, err := ProbeSource(context.Background(), server.URL, SnapshotTypeIncremental, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if node.Slot != 150 || node.BaseSlot != 100 || node.SnapshotURL != server.URL+"/incremental-snapshot-100-150-Hash.tar.zst" {
+		t.Errorf("unexpected node %+v", node)
+	}
+
+	if _, err := ProbeSource(context.Background(), server.URL, SnapshotTypeFull, opts); err == nil {
+		t.Error("expected error when the source serves no full snapshot")
+	}
+}
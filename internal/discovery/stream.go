@@ -0,0 +1,176 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// CandidateStream delivers suitable snapshot nodes to a consumer while probing
+// continues in the background. Candidates received so far are handed out
+// best-first according to the configured sort order.
+type CandidateStream struct {
+	found   <-chan SnapshotNode
+	cancel  context.CancelFunc
+	order   func([]SnapshotNode)
+	wait    bool // hold candidates back until probing completes (Options.Stream == false)
+	filter  func(SnapshotNode) bool
+	pending []SnapshotNode
+	closed  bool
+	// enough is closed once MinSuitable candidates were found while probing
+	// continues in the background; it ends wait mode
+	enough <-chan struct{}
+	// rejections is written before found is closed
+	rejections RejectionSummary
+}
+
+// StreamNodes starts probing cluster nodes in the background and returns a
+// stream of suitable nodes. Callers must call Stop once they are done with the
+// stream to cancel any outstanding probes.
+func StreamNodes(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, snapshotType SnapshotType, opts Options) *CandidateStream {
+	rpcAddresses := candidateAddresses(nodes, opts)
+	opts.logger().Info(fmt.Sprintf("probing %d nodes for %s snapshots 👉🍑😭...", len(rpcAddresses), snapshotType), "stream", opts.Stream)
+
+	content := contentNodes(ctx, currentSlot, snapshotType, opts)
+	streamCtx, cancel := context.WithCancel(ctx)
+	found := make(chan SnapshotNode, len(rpcAddresses)+len(content)) // never blocks probe goroutines
+	for _, n := range content {
+		found <- n
+	}
+
+	s := &CandidateStream{
+		found:  found,
+		cancel: cancel,
+		order:  opts.order,
+		wait:   !opts.Stream,
+	}
+
+	onFound := func(n SnapshotNode) { found <- n }
+	if opts.BackgroundConcurrency > 0 && opts.MinSuitable > 0 {
+		enough := make(chan struct{})
+		s.enough = enough
+		var suitable atomic.Int64
+		var once sync.Once
+		onFound = func(n SnapshotNode) {
+			found <- n
+			if suitable.Add(1) >= int64(opts.MinSuitable) {
+				once.Do(func() { close(enough) })
+			}
+		}
+	}
+
+	go func() {
+		defer close(found)
+		start := time.Now()
+		results, rejections, _ := probeNodes(streamCtx, rpcAddresses, currentSlot, snapshotType, opts, onFound)
+		s.rejections = rejections
+		opts.logger().Info(fmt.Sprintf("probes complete in %s - found %d suitable nodes", time.Since(start), len(results)))
+	}()
+
+	return s
+}
+
+// StreamIncrementalForBase is like StreamNodes for incremental snapshots, but
+// only yields nodes whose incremental builds on the given base slot.
+func StreamIncrementalForBase(ctx context.Context, nodes []rpc.ClusterNode, currentSlot uint64, baseSlot uint64, opts Options) *CandidateStream {
+	s := StreamNodes(ctx, nodes, currentSlot, SnapshotTypeIncremental, opts)
+	s.filter = func(n SnapshotNode) bool { return n.BaseSlot == baseSlot }
+	return s
+}
+
+// Next removes and returns the best candidate received so far, blocking until
+// one is available. It returns false once probing has finished and every
+// candidate has been handed out, or when ctx is cancelled.
+func (s *CandidateStream) Next(ctx context.Context) (SnapshotNode, bool) {
+	if !s.fill(ctx) {
+		return SnapshotNode{}, false
+	}
+	n := s.pending[0]
+	s.pending = s.pending[1:]
+	return n, true
+}
+
+// Peek is like Next but leaves the candidate in the stream.
+func (s *CandidateStream) Peek(ctx context.Context) (SnapshotNode, bool) {
+	if !s.fill(ctx) {
+		return SnapshotNode{}, false
+	}
+	return s.pending[0], true
+}
+
+// Pending returns the number of candidates received but not yet handed out.
+func (s *CandidateStream) Pending() int {
+	return len(s.pending)
+}
+
+// PendingNodes returns the candidates received but not yet handed out,
+// best first.
+func (s *CandidateStream) PendingNodes() []SnapshotNode {
+	return append([]SnapshotNode(nil), s.pending...)
+}
+
+// Rejections returns why probed nodes were unsuitable. It is only complete
+// once Next or Peek has returned false because probing finished.
+func (s *CandidateStream) Rejections() RejectionSummary {
+	if !s.closed {
+		return RejectionSummary{}
+	}
+	return s.rejections
+}
+
+// Stop cancels any probes still in flight.
+func (s *CandidateStream) Stop() {
+	s.cancel()
+}
+
+// fill blocks until at least one candidate is pending (or, in wait mode, until
+// probing completes or finds enough), then drains whatever else has arrived
+// and re-sorts. It returns false when no candidate is pending and none can
+// arrive.
+func (s *CandidateStream) fill(ctx context.Context) bool {
+	for !s.closed && (s.wait || len(s.pending) == 0) {
+		select {
+		case n, ok := <-s.found:
+			if !ok {
+				s.closed = true
+				continue
+			}
+			s.accept(n)
+		case <-s.enough:
+			// The rest trickle in from background probes as fallbacks
+			s.wait = false
+			s.enough = nil
+		case <-ctx.Done():
+			return false
+		}
+	}
+
+	// Pick up anything else that arrived meanwhile without blocking.
+	for !s.closed {
+		select {
+		case n, ok := <-s.found:
+			if !ok {
+				s.closed = true
+				continue
+			}
+			s.accept(n)
+			continue
+		default:
+		}
+		break
+	}
+
+	s.order(s.pending)
+	return len(s.pending) > 0
+}
+
+func (s *CandidateStream) accept(n SnapshotNode) {
+	if s.filter != nil && !s.filter(n) {
+		return
+	}
+	s.pending = append(s.pending, n)
+}
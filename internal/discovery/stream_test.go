@@ -0,0 +1,249 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+func TestStreamNodes_YieldsAllCandidates(t *testing.T) {
+	var clusterNodes []rpc.ClusterNode
+	for i := 0; i < 5; i++ {
+		filename := fmt.Sprintf("snapshot-%d-Hash%d.tar.zst", 135501000+i*100, i)
+		s := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Location", "/"+filename)
+			w.WriteHeader(http.StatusFound)
+		}))
+		defer s.Close()
+		addr := s.URL
+		clusterNodes = append(clusterNodes, rpc.ClusterNode{Pubkey: "test", RPC: &addr})
+	}
+
+	for _, stream := range []bool{false, true} {
+		t.Run(fmt.Sprintf("stream=%v", stream), func(t *testing.T) {
+			opts := Options{
+				MaxLatency:          5 * time.Second,
+				MaxSnapshotAgeSlots: 2000,
+				ProbeConcurrency:    10,
+				SortOrder:           "slot_age",
+				Stream:              stream,
+			}
+
+			s := StreamNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+			defer s.Stop()
+
+			var got []SnapshotNode
+			for {
+				n, ok := s.Next(context.Background())
+				if !ok {
+					break
+				}
+				got = append(got, n)
+			}
+			if len(got) != 5 {
+				t.Fatalf("expected 5 candidates, got %d", len(got))
+			}
+			if !stream {
+				// Wait mode hands out the fully sorted set
+				for i := 1; i < len(got); i++ {
+					if got[i].SlotAge < got[i-1].SlotAge {
+						t.Errorf("candidates not sorted by slot_age at index %d", i)
+					}
+				}
+			}
+		})
+	}
+}
+
+func TestStreamNodes_FirstCandidateBeforeProbingCompletes(t *testing.T) {
+	release := make(chan struct{})
+
+	fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/snapshot-135501000-Fast.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer fast.Close()
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+		w.Header().Set("Location", "/snapshot-135501000-Slow.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer slow.Close()
+	defer close(release)
+
+	fastAddr, slowAddr := fast.URL, slow.URL
+	clusterNodes := []rpc.ClusterNode{
+		{Pubkey: "slow", RPC: &slowAddr},
+		{Pubkey: "fast", RPC: &fastAddr},
+	}
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 2000,
+		ProbeConcurrency:    10,
+		SortOrder:           "latency",
+		Stream:              true,
+	}
+
+	s := StreamNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+	defer s.Stop()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+	defer cancel()
+
+	n, ok := s.Next(ctx)
+	if !ok {
+		t.Fatal("expected first candidate while slow probe still in flight")
+	}
+	if n.RPCURL != fastAddr {
+		t.Errorf("expected fast node first, got %s", n.RPCURL)
+	}
+}
+
+func TestStreamNodes_BackgroundProbingAfterMinSuitable(t *testing.T) {
+	for _, background := range []int{0, 1} {
+		t.Run(fmt.Sprintf("background=%d", background), func(t *testing.T) {
+			release := make(chan struct{})
+			fast := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Location", "/snapshot-135501000-Fast.tar.zst")
+				w.WriteHeader(http.StatusFound)
+			}))
+			defer fast.Close()
+			slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				select {
+				case <-release:
+				case <-r.Context().Done():
+					return
+				}
+				w.Header().Set("Location", "/snapshot-135501000-Slow.tar.zst")
+				w.WriteHeader(http.StatusFound)
+			}))
+			defer slow.Close()
+
+			fastAddr, slowAddr := fast.URL, slow.URL
+			clusterNodes := []rpc.ClusterNode{
+				{Pubkey: "slow", RPC: &slowAddr},
+				{Pubkey: "fast", RPC: &fastAddr},
+			}
+			// Wait mode: without background probing, reaching min_suitable
+			// ends probing; with it, it only releases the candidates found
+			opts := Options{
+				MaxLatency:            5 * time.Second,
+				MaxSnapshotAgeSlots:   2000,
+				ProbeConcurrency:      10,
+				SortOrder:             "latency",
+				MinSuitable:           1,
+				BackgroundConcurrency: background,
+			}
+
+			s := StreamNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+			defer s.Stop()
+
+			ctx, cancel := context.WithTimeout(context.Background(), 2*time.Second)
+			defer cancel()
+			n, ok := s.Next(ctx)
+			if !ok || n.RPCURL != fastAddr {
+				t.Fatalf("expected the fast node once min_suitable was reached, got %+v", n)
+			}
+
+			// The first candidate failed; is there another to fall back on?
+			close(release)
+			n, ok = s.Next(ctx)
+			if background == 0 {
+				if ok {
+					t.Errorf("expected probing to have stopped at min_suitable, got %s", n.RPCURL)
+				}
+				return
+			}
+			if !ok || n.RPCURL != slowAddr {
+				t.Errorf("expected the background probe to yield the slow node, got %+v", n)
+			}
+		})
+	}
+}
+
+func TestStreamIncrementalForBase_Filters(t *testing.T) {
+	s1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/incremental-snapshot-135501000-135501500-Hash1.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer s1.Close()
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/incremental-snapshot-135500000-135501200-Hash2.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer s2.Close()
+
+	addr1, addr2 := s1.URL, s2.URL
+	clusterNodes := []rpc.ClusterNode{
+		{Pubkey: "n1", RPC: &addr1},
+		{Pubkey: "n2", RPC: &addr2},
+	}
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 5000,
+		ProbeConcurrency:    10,
+		SortOrder:           "latency",
+		Stream:              true,
+	}
+
+	s := StreamIncrementalForBase(context.Background(), clusterNodes, 135501600, 135501000, opts)
+	defer s.Stop()
+
+	n, ok := s.Next(context.Background())
+	if !ok {
+		t.Fatal("expected a matching incremental")
+	}
+	if n.BaseSlot != 135501000 {
+		t.Errorf("expected base_slot 135501000, got %d", n.BaseSlot)
+	}
+	if _, ok := s.Next(context.Background()); ok {
+		t.Error("expected non-matching incremental to be filtered out")
+	}
+}
+
+func TestStreamNodes_Rejections(t *testing.T) {
+	s1 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/snapshot-135500000-Old1.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer s1.Close()
+	s2 := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Location", "/snapshot-135499000-Old2.tar.zst")
+		w.WriteHeader(http.StatusFound)
+	}))
+	defer s2.Close()
+
+	addr1, addr2 := s1.URL, s2.URL
+	clusterNodes := []rpc.ClusterNode{
+		{Pubkey: "n1", RPC: &addr1},
+		{Pubkey: "n2", RPC: &addr2},
+	}
+
+	opts := Options{
+		MaxLatency:          5 * time.Second,
+		MaxSnapshotAgeSlots: 1000,
+		ProbeConcurrency:    10,
+		SortOrder:           "latency",
+	}
+
+	s := StreamNodes(context.Background(), clusterNodes, 135501500, SnapshotTypeFull, opts)
+	defer s.Stop()
+
+	if _, ok := s.Next(context.Background()); ok {
+		t.Fatal("expected no suitable candidates")
+	}
+	r := s.Rejections()
+	if r.TooOld != 2 {
+		t.Errorf("expected 2 too-old rejections, got %d", r.TooOld)
+	}
+	if r.TooOldMinSlots != 1500 || r.TooOldMaxSlots != 2500 {
+		t.Errorf("expected too-old range 1500-2500, got %d-%d", r.TooOldMinSlots, r.TooOldMaxSlots)
+	}
+}
@@ -0,0 +1,65 @@
+package discovery
+
+import (
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// TrustFilter restricts snapshot sources to known validators and/or nodes
+// with enough activated stake, mirroring agave's --known-validator trust
+// model. A node is allowed if it matches either rule.
+type TrustFilter struct {
+	KnownValidators []string // identity pubkeys
+	MinStake        uint64   // lamports (0 = no stake rule)
+	// Stakes maps identity pubkey to activated stake, from getVoteAccounts.
+	// Only needed when MinStake > 0.
+	Stakes map[string]uint64
+}
+
+// Enabled reports whether the filter restricts anything.
+func (f TrustFilter) Enabled() bool {
+	return len(f.KnownValidators) > 0 || f.MinStake > 0
+}
+
+// StakesByNode sums activated stake per identity from current (non-delinquent)
+// vote accounts.
+func StakesByNode(accounts *rpc.VoteAccounts) map[string]uint64 {
+	stakes := make(map[string]uint64, len(accounts.Current))
+	for _, a := range accounts.Current {
+		stakes[a.NodePubkey] += a.ActivatedStake
+	}
+	return stakes
+}
+
+// FilterTrustedNodes returns the nodes allowed by f.
+func FilterTrustedNodes(nodes []rpc.ClusterNode, f TrustFilter) []rpc.ClusterNode {
+	if !f.Enabled() {
+		return nodes
+	}
+
+	known := make(map[string]bool, len(f.KnownValidators))
+	for _, pk := range f.KnownValidators {
+		known[pk] = true
+	}
+
+	var allowed []rpc.ClusterNode
+	var byKnown, byStake int
+	for _, n := range nodes {
+		switch {
+		case known[n.Pubkey]:
+			byKnown++
+		case f.MinStake > 0 && f.Stakes[n.Pubkey] >= f.MinStake:
+			byStake++
+		default:
+			continue
+		}
+		allowed = append(allowed, n)
+	}
+
+	logger().Info(fmt.Sprintf("trust filter allowed %d of %d cluster nodes as snapshot sources", len(allowed), len(nodes)),
+		"known_validators", byKnown,
+		"min_stake", byStake,
+	)
+	return allowed
+}
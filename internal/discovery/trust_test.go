@@ -0,0 +1,51 @@
+package discovery
+
+import (
+	"testing"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+func TestFilterTrustedNodes(t *testing.T) {
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "known", RPC: strPtr("10.0.0.1:8899")},
+		{Pubkey: "staked", RPC: strPtr("10.0.0.2:8899")},
+		{Pubkey: "small", RPC: strPtr("10.0.0.3:8899")},
+		{Pubkey: "unstaked", RPC: strPtr("10.0.0.4:8899")},
+	}
+	stakes := StakesByNode(&rpc.VoteAccounts{
+		Current: []rpc.VoteAccount{
+			{NodePubkey: "staked", ActivatedStake: 60_000},
+			{NodePubkey: "staked", ActivatedStake: 50_000},
+			{NodePubkey: "small", ActivatedStake: 10_000},
+		},
+		Delinquent: []rpc.VoteAccount{
+			{NodePubkey: "unstaked", ActivatedStake: 1_000_000},
+		},
+	})
+
+	tests := []struct {
+		name   string
+		filter TrustFilter
+		want   []string
+	}{
+		{"disabled", TrustFilter{}, []string{"known", "staked", "small", "unstaked"}},
+		{"known only", TrustFilter{KnownValidators: []string{"known"}}, []string{"known"}},
+		{"stake only", TrustFilter{MinStake: 100_000, Stakes: stakes}, []string{"staked"}},
+		{"known or staked", TrustFilter{KnownValidators: []string{"known"}, MinStake: 100_000, Stakes: stakes}, []string{"known", "staked"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := FilterTrustedNodes(nodes, tt.filter)
+			if len(got) != len(tt.want) {
+				t.Fatalf("expected %v, got %d nodes", tt.want, len(got))
+			}
+			for i, n := range got {
+				if n.Pubkey != tt.want[i] {
+					t.Errorf("node %d: expected %q, got %q", i, tt.want[i], n.Pubkey)
+				}
+			}
+		})
+	}
+}
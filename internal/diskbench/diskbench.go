@@ -0,0 +1,64 @@
+// Package diskbench measures how fast a directory's filesystem absorbs
+// sequential writes, the pattern a snapshot download produces. A disk that
+// can't keep up throttles every connection of a download, which looks like
+// slow sources.
+package diskbench
+
+import (
+	"context"
+	"crypto/rand"
+	"fmt"
+	"os"
+	"time"
+)
+
+// blockSize is the size of each write.
+const blockSize = 4 << 20
+
+// Result is one benchmark's measurement.
+type Result struct {
+	Bytes    int64
+	Duration time.Duration
+}
+
+// BytesPerSecond is the measured sequential write throughput.
+func (r Result) BytesPerSecond() int64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(r.Bytes) / r.Duration.Seconds())
+}
+
+// Sequential writes size bytes to a temporary file in dir, syncs it and
+// removes it. The sync is timed too, so the page cache doesn't flatter the
+// result, and the data is random so compressing filesystems can't either.
+func Sequential(ctx context.Context, dir string, size int64) (Result, error) {
+	if size <= 0 {
+		return Result{}, fmt.Errorf("benchmark size must be > 0")
+	}
+	f, err := os.CreateTemp(dir, ".disk-benchmark-*")
+	if err != nil {
+		return Result{}, err
+	}
+	defer os.Remove(f.Name())
+	defer f.Close()
+
+	block := make([]byte, blockSize)
+	rand.Read(block)
+
+	start := time.Now()
+	for written := int64(0); written < size; {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+		n, err := f.Write(block[:min(int64(len(block)), size-written)])
+		if err != nil {
+			return Result{}, fmt.Errorf("writing %s: %w", f.Name(), err)
+		}
+		written += int64(n)
+	}
+	if err := f.Sync(); err != nil {
+		return Result{}, fmt.Errorf("syncing %s: %w", f.Name(), err)
+	}
+	return Result{Bytes: size, Duration: time.Since(start)}, nil
+}
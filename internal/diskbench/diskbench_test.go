@@ -0,0 +1,38 @@
+package diskbench
+
+import (
+	"context"
+	"os"
+	"testing"
+	"time"
+)
+
+func TestSequential(t *testing.T) {
+	dir := t.TempDir()
+	result, err := Sequential(context.Background(), dir, 10<<20+123)
+	if err != nil {
+		t.Fatalf("Sequential: %v", err)
+	}
+	if result.Bytes != 10<<20+123 || result.Duration <= 0 || result.BytesPerSecond() <= 0 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	// The benchmark file is removed afterwards
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("expected an empty directory, found %d entries", len(entries))
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := Sequential(ctx, dir, 1<<20); err == nil {
+		t.Error("expected an error for a cancelled context")
+	}
+}
+
+func TestResult_BytesPerSecond(t *testing.T) {
+	if got := (Result{Bytes: 500 << 20, Duration: 2 * time.Second}).BytesPerSecond(); got != 250<<20 {
+		t.Errorf("BytesPerSecond = %d, want %d", got, 250<<20)
+	}
+	if got := (Result{}).BytesPerSecond(); got != 0 {
+		t.Errorf("BytesPerSecond of an empty result = %d, want 0", got)
+	}
+}
@@ -0,0 +1,161 @@
+package downloader
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// AdaptiveConnections tunes the number of parallel connections during a
+// download by measured throughput, instead of using DownloadConnections
+// throughout. DownloadConnections (capped by PerSource) becomes the maximum.
+type AdaptiveConnections struct {
+	Enabled bool
+	// Initial is how many connections the download starts with
+	Initial int
+	// Interval is how often throughput is measured and the count adjusted
+	Interval time.Duration
+	// MinGain is the fraction by which an added connection must raise
+	// throughput to be kept, e.g. 0.1 for 10%
+	MinGain float64
+}
+
+const (
+	// pieceSize is roughly how much an adaptive download fetches per
+	// request, so connections can be added and retired as it runs
+	pieceSize = 64 << 20
+	// piecesPerConnection keeps small downloads split finely enough for
+	// every connection to get work
+	piecesPerConnection = 4
+	// tunerHoldIntervals is how long the tuner keeps a count after backing
+	// off, before probing with another connection
+	tunerHoldIntervals = 6
+)
+
+// splitPieces divides size bytes into pieces small enough for an adaptive
+// download of up to maxConns connections to rebalance.
+func splitPieces(size int64, maxConns int) []segment {
+	n := max((size+pieceSize-1)/pieceSize, int64(maxConns*piecesPerConnection))
+	return splitSegments(size, int(min(n, size)))
+}
+
+// connPool hands segments to connections, and retires or adds connections
+// as its target changes. A retired connection finishes its segment first.
+type connPool struct {
+	mu       sync.Mutex
+	wg       *sync.WaitGroup // counts open connections
+	segments []segment
+	next     int // first segment not yet handed out
+	active   int
+	target   int
+	stopped  bool
+}
+
+// take returns the index of the next segment for a connection to fetch, or
+// false when the connection should close.
+func (p *connPool) take() (int, bool) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	for p.next < len(p.segments) && p.segments[p.next].done() {
+		p.next++
+	}
+	if p.stopped || p.active > p.target || p.next >= len(p.segments) {
+		p.active--
+		return 0, false
+	}
+	i := p.next
+	p.next++
+	return i, true
+}
+
+// fail closes a connection whose segment failed, and stops the pool from
+// adding connections.
+func (p *connPool) fail() {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.active--
+	p.stopped = true
+}
+
+// resize sets the target connection count and returns how many connections
+// to open to reach it, already added to wg. Nothing is opened once every
+// segment is handed out.
+func (p *connPool) resize(target int) int {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	p.target = target
+	if p.stopped || p.next >= len(p.segments) || p.active >= target {
+		return 0
+	}
+	open := target - p.active
+	p.active = target
+	// Under the lock, so wg can't drop to zero and be waited on meanwhile
+	p.wg.Add(open)
+	return open
+}
+
+// connTuner hill-climbs towards the connection count past which another
+// connection stops paying for itself: it adds one at a time while each
+// raises throughput by at least minGain, backs off when one doesn't, and
+// probes again after holding for a while, as the best count drifts.
+type connTuner struct {
+	min, max int
+	minGain  float64
+	conns    int
+	lastRate float64
+	probing  bool // the last change added a connection
+	hold     int  // intervals left before probing again
+}
+
+func newConnTuner(opts AdaptiveConnections, maxConns int) *connTuner {
+	initial := min(max(opts.Initial, 1), maxConns)
+	return &connTuner{min: 1, max: maxConns, minGain: opts.MinGain, conns: initial}
+}
+
+// next returns the connection count for the next interval, given the
+// throughput measured over the last one.
+func (t *connTuner) next(rate float64) int {
+	switch {
+	case t.probing && rate < t.lastRate*(1+t.minGain) && t.conns > t.min:
+		t.conns--
+		t.probing = false
+		t.hold = tunerHoldIntervals
+	case t.hold > 0:
+		t.hold--
+		t.probing = false
+	case t.conns < t.max:
+		t.conns++
+		t.probing = true
+	default:
+		t.probing = false
+	}
+	t.lastRate = rate
+	return t.conns
+}
+
+// tuneConnections adjusts pool's connection count every interval until ctx
+// is done, calling open to add connections.
+func tuneConnections(ctx context.Context, pool *connPool, tuner *connTuner, downloaded *atomic.Int64, interval time.Duration, open func(int)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	last := downloaded.Load()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		now := downloaded.Load()
+		rate := float64(now-last) / interval.Seconds()
+		last = now
+
+		prev := tuner.conns
+		if n := tuner.next(rate); n != prev {
+			logger().Debug(fmt.Sprintf("adjusting connections from %d to %d at %s/s", prev, n, formatBytes(int64(rate))))
+			open(pool.resize(n))
+		}
+	}
+}
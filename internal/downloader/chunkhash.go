@@ -0,0 +1,152 @@
+package downloader
+
+import (
+	"cmp"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"os"
+	"slices"
+	"sync"
+)
+
+// hashChunkSize is the most bytes one entry of a download's chunk-hash
+// manifest covers.
+const hashChunkSize = 64 << 20
+
+// errPartialChanged is returned when a paused download's temp file no longer
+// matches the chunk hashes recorded as it was written.
+var errPartialChanged = errors.New("paused download changed on disk")
+
+// chunkHash is the SHA-256 of one contiguous run of bytes a segmented
+// download wrote.
+type chunkHash struct {
+	Start  int64  `json:"start"`
+	End    int64  `json:"end"` // inclusive
+	SHA256 string `json:"sha256"`
+}
+
+// chunkManifest collects the chunk hashes of a segmented download. A chunk
+// ends at each hashChunkSize boundary, at the end of its segment, and
+// wherever a connection stopped writing, so the bytes before a resume point
+// are a chunk of their own.
+type chunkManifest struct {
+	mu     sync.Mutex
+	chunks []chunkHash
+}
+
+func (m *chunkManifest) add(c chunkHash) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.chunks = append(m.chunks, c)
+}
+
+// list returns the chunks in file order.
+func (m *chunkManifest) list() []chunkHash {
+	if m == nil {
+		return nil
+	}
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	chunks := slices.Clone(m.chunks)
+	slices.SortFunc(chunks, func(a, b chunkHash) int { return cmp.Compare(a.Start, b.Start) })
+	return chunks
+}
+
+// wrap returns w hashing the segment's bytes into the manifest as they are
+// written. A nil manifest hashes nothing.
+func (m *chunkManifest) wrap(w segmentWriter, seg *segment) segmentWriter {
+	if m == nil {
+		return w
+	}
+	return &hashingWriter{segmentWriter: w, manifest: m, start: seg.Next, pos: seg.Next, h: sha256.New()}
+}
+
+// hashingWriter hashes a segment's bytes on their way to the file.
+type hashingWriter struct {
+	segmentWriter
+	manifest   *chunkManifest
+	start, pos int64 // first byte of the chunk being hashed, next byte
+	h          hash.Hash
+}
+
+func (w *hashingWriter) Write(p []byte) (int, error) {
+	n, err := w.segmentWriter.Write(p)
+	for q := p[:n]; len(q) > 0; {
+		k := min(int64(len(q)), hashChunkSize-w.pos%hashChunkSize)
+		w.h.Write(q[:k])
+		w.pos += k
+		q = q[k:]
+		if w.pos%hashChunkSize == 0 {
+			w.finish()
+		}
+	}
+	return n, err
+}
+
+func (w *hashingWriter) Close() error {
+	err := w.segmentWriter.Close()
+	w.finish()
+	return err
+}
+
+// finish records the chunk hashed so far and starts the next one.
+func (w *hashingWriter) finish() {
+	if w.pos > w.start {
+		w.manifest.add(chunkHash{Start: w.start, End: w.pos - 1, SHA256: hex.EncodeToString(w.h.Sum(nil))})
+	}
+	w.start = w.pos
+	w.h.Reset()
+}
+
+// verifyResume checks a paused download before it resumes, possibly from
+// another source. The chunk ending at each segment's resume point must
+// still hash as recorded, and the source must serve the same bytes at its
+// end; otherwise bytes from two sources, or a tampered temp file, would be
+// stitched together unnoticed. It returns errPartialChanged or
+// ErrRangeMismatch for a mismatch.
+func verifyResume(ctx context.Context, url, tempPath string, segments []segment, chunks []chunkHash, opts Options) error {
+	f, err := os.Open(tempPath)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	byEnd := make(map[int64]chunkHash, len(chunks))
+	for _, c := range chunks {
+		byEnd[c.End] = c
+	}
+	checked := 0
+	for _, seg := range segments {
+		c, ok := byEnd[seg.Next-1]
+		if !ok {
+			continue
+		}
+		if err := checkChunk(f, c); err != nil {
+			return err
+		}
+		length := min(int64(spotCheckSize), c.End-c.Start+1)
+		if err := spotCheckRange(ctx, url, f, c.End+1-length, length, opts); err != nil {
+			return err
+		}
+		checked++
+	}
+	opts.logger().Debug(fmt.Sprintf("verified %d resume boundaries against the source", checked), "url", url)
+	return nil
+}
+
+// checkChunk re-hashes a chunk of f and compares it with the manifest.
+func checkChunk(f *os.File, c chunkHash) error {
+	h := sha256.New()
+	if _, err := io.Copy(h, io.NewSectionReader(f, c.Start, c.End-c.Start+1)); err != nil {
+		return fmt.Errorf("hashing bytes %d-%d: %w", c.Start, c.End, err)
+	}
+	if hex.EncodeToString(h.Sum(nil)) != c.SHA256 {
+		return fmt.Errorf("%w: bytes %d-%d no longer match their recorded hash", errPartialChanged, c.Start, c.End)
+	}
+	return nil
+}
@@ -0,0 +1,208 @@
+package downloader
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/delta"
+)
+
+// maxSignatureSize bounds the signature document; 1 MiB blocks of a 100 GB
+// archive are well under this.
+const maxSignatureSize = 64 << 20
+
+// DownloadDelta reconstructs the archive at url from blocks of the local
+// basis archive, fetching only the blocks it lacks with range requests. It
+// returns delta.ErrNoSignature if the source publishes no signature, and an
+// error when nothing can be reused so the caller can fall back to Download.
+func DownloadDelta(ctx context.Context, url string, destDir string, filename string, basisPath string, opts Options) (*Result, error) {
+	opts = opts.paced()
+	sig, err := fetchSignature(ctx, url+delta.SignatureSuffix, opts)
+	if err != nil {
+		return nil, err
+	}
+
+	basis, err := os.Open(basisPath)
+	if err != nil {
+		return nil, fmt.Errorf("opening basis: %w", err)
+	}
+	defer basis.Close()
+
+	start := time.Now()
+	matches, err := delta.Match(ctx, basis, sig)
+	if err != nil {
+		return nil, fmt.Errorf("matching basis: %w", err)
+	}
+	var reused int64
+	for i, off := range matches {
+		if off >= 0 {
+			reused += int64(sig.BlockLen(i))
+		}
+	}
+	if reused == 0 {
+		return nil, fmt.Errorf("no blocks in common with %s", filepath.Base(basisPath))
+	}
+	opts.logger().Info(fmt.Sprintf("delta download - reusing %s of %s from local basis", formatBytes(reused), formatBytes(sig.Size)),
+		"url", url,
+		"basis", filepath.Base(basisPath),
+	)
+
+	destPath := filepath.Join(destDir, filename)
+	tempPath := opts.tempPath(destDir, filename)
+	fetched, err := assemble(ctx, url, tempPath, basis, sig, matches, opts)
+	if err != nil {
+		os.Remove(tempPath)
+		return nil, err
+	}
+	if err := opts.verifyTemp(ctx, tempPath); err != nil {
+		return nil, err
+	}
+	if err := opts.moveIntoPlace(tempPath, destPath); err != nil {
+		os.Remove(tempPath)
+		return nil, fmt.Errorf("renaming temp file: %w", err)
+	}
+
+	duration := time.Since(start)
+	speedBps := float64(fetched) / duration.Seconds()
+	opts.logger().Info(fmt.Sprintf("delta downloaded snapshot - fetched %s, reused %s in %s", formatBytes(fetched), formatBytes(reused), duration),
+		"url", url,
+		"file", filename,
+	)
+
+	return &Result{
+		FilePath:     destPath,
+		Bytes:        fetched,
+		ReusedBytes:  reused,
+		DurationSecs: duration.Seconds(),
+		SpeedBps:     int64(speedBps),
+	}, nil
+}
+
+func fetchSignature(ctx context.Context, url string, opts Options) (*delta.Signature, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("creating signature request: %w", err)
+	}
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetching signature: %w", err)
+	}
+	defer resp.Body.Close()
+
+	switch resp.StatusCode {
+	case http.StatusOK:
+	case http.StatusNotFound:
+		return nil, delta.ErrNoSignature
+	default:
+		return nil, fmt.Errorf("fetching signature: unexpected status %d", resp.StatusCode)
+	}
+
+	var sig delta.Signature
+	if err := json.NewDecoder(io.LimitReader(resp.Body, maxSignatureSize)).Decode(&sig); err != nil {
+		return nil, fmt.Errorf("parsing signature: %w", err)
+	}
+	if err := sig.Validate(); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	return &sig, nil
+}
+
+// assemble writes the target archive to path in order, copying matched
+// blocks from basis and fetching each run of missing blocks with one range
+// request, then checks the result against the signature's SHA-256.
+func assemble(ctx context.Context, url string, path string, basis io.ReaderAt, sig *delta.Signature, matches []int64, opts Options) (int64, error) {
+	f, err := os.Create(path)
+	if err != nil {
+		return 0, fmt.Errorf("creating temp file: %w", err)
+	}
+	defer f.Close()
+
+	hash := sha256.New()
+	w := io.MultiWriter(f, hash)
+	limiter := newRateLimiter(opts.PerSource.MaxBytesPerSec)
+	blockSize := int64(sig.BlockSize)
+
+	var fetched int64
+	for i := 0; i < len(matches); {
+		if off := matches[i]; off >= 0 {
+			if _, err := io.Copy(w, io.NewSectionReader(basis, off, int64(sig.BlockLen(i)))); err != nil {
+				return fetched, fmt.Errorf("copying block %d from basis: %w", i, err)
+			}
+			i++
+			continue
+		}
+
+		end := i
+		for end < len(matches) && matches[end] < 0 {
+			end++
+		}
+		rangeStart := int64(i) * blockSize
+		rangeEnd := min(int64(end)*blockSize, sig.Size) - 1
+		n, err := fetchRange(ctx, url, rangeStart, rangeEnd, w, limiter, opts)
+		fetched += n
+		if err != nil {
+			return fetched, fmt.Errorf("fetching blocks %d-%d: %w", i, end-1, err)
+		}
+		i = end
+	}
+
+	if got := hex.EncodeToString(hash.Sum(nil)); got != sig.SHA256 {
+		return fetched, fmt.Errorf("checksum mismatch after delta download: got %s, want %s", got, sig.SHA256)
+	}
+	return fetched, nil
+}
+
+func fetchRange(ctx context.Context, url string, rangeStart, rangeEnd int64, w io.Writer, limiter *rateLimiter, opts Options) (int64, error) {
+	if err := opts.pace(ctx); err != nil {
+		return 0, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rangeStart, rangeEnd))
+
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusPartialContent {
+		return 0, fmt.Errorf("expected 206, got %d", resp.StatusCode)
+	}
+
+	want := rangeEnd - rangeStart + 1
+	buf := make([]byte, 256*1024)
+	var total int64
+	for total < want {
+		n, readErr := resp.Body.Read(buf[:min(int64(len(buf)), want-total)])
+		if n > 0 {
+			if _, err := w.Write(buf[:n]); err != nil {
+				return total, err
+			}
+			total += int64(n)
+			if err := limiter.wait(ctx, n); err != nil {
+				return total, err
+			}
+		}
+		if readErr == io.EOF {
+			break
+		}
+		if readErr != nil {
+			return total, readErr
+		}
+	}
+	if total != want {
+		return total, fmt.Errorf("short range response: got %d of %d bytes", total, want)
+	}
+	return total, nil
+}
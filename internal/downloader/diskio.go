@@ -0,0 +1,141 @@
+package downloader
+
+import (
+	"os"
+	"unsafe"
+)
+
+const (
+	// directAlign is the offset, length and memory alignment of direct I/O
+	// writes; 4 KiB covers both 512-byte and 4K-sector devices
+	directAlign = 4096
+	// directBufferSize is how much a direct I/O writer gathers per write
+	directBufferSize = 1 << 20
+)
+
+// segmentWriter writes a segment's bytes to the temp file in order,
+// advancing the segment as they reach the file.
+type segmentWriter interface {
+	Write(p []byte) (int, error)
+	// Close writes anything still buffered and releases the file
+	Close() error
+}
+
+func openSegmentWriter(filePath string, seg *segment, directIO bool) (segmentWriter, error) {
+	if directIO {
+		direct, err := openDirect(filePath)
+		if err != nil {
+			return nil, err
+		}
+		return &directWriter{direct: direct, path: filePath, seg: seg, buf: alignedBuffer(directBufferSize)}, nil
+	}
+	f, err := os.OpenFile(filePath, os.O_WRONLY, 0644)
+	if err != nil {
+		return nil, err
+	}
+	return &bufferedWriter{f: f, seg: seg}, nil
+}
+
+// bufferedWriter writes through the page cache.
+type bufferedWriter struct {
+	f   *os.File
+	seg *segment
+}
+
+func (w *bufferedWriter) Write(p []byte) (int, error) {
+	n, err := w.f.WriteAt(p, w.seg.Next)
+	w.seg.Next += int64(n)
+	return n, err
+}
+
+func (w *bufferedWriter) Close() error { return w.f.Close() }
+
+// directWriter bypasses the page cache so a large download doesn't evict
+// the validator's hot pages. Whole aligned blocks are gathered in an aligned
+// buffer and written with direct I/O; bytes before the segment's first block
+// boundary and after its last go through the page cache.
+type directWriter struct {
+	direct   *os.File
+	buffered *os.File // opened for unaligned heads and tails
+	path     string
+	seg      *segment
+	buf      []byte
+	n        int // bytes gathered in buf, to be written at seg.Next
+}
+
+func (w *directWriter) Write(p []byte) (int, error) {
+	written := len(p)
+	for len(p) > 0 {
+		if w.n == 0 && w.seg.Next%directAlign != 0 {
+			head := min(int(directAlign-w.seg.Next%directAlign), len(p))
+			if err := w.writeBuffered(p[:head]); err != nil {
+				return 0, err
+			}
+			p = p[head:]
+			continue
+		}
+		c := copy(w.buf[w.n:], p)
+		w.n += c
+		p = p[c:]
+		if w.n == len(w.buf) {
+			if err := w.flush(); err != nil {
+				return 0, err
+			}
+		}
+	}
+	return written, nil
+}
+
+// flush writes the gathered blocks, and any partial block after them.
+func (w *directWriter) flush() error {
+	aligned := w.n - w.n%directAlign
+	if aligned > 0 {
+		if _, err := w.direct.WriteAt(w.buf[:aligned], w.seg.Next); err != nil {
+			return err
+		}
+		w.seg.Next += int64(aligned)
+	}
+	rest := w.buf[aligned:w.n]
+	w.n = 0
+	if len(rest) > 0 {
+		return w.writeBuffered(rest)
+	}
+	return nil
+}
+
+func (w *directWriter) writeBuffered(p []byte) error {
+	if w.buffered == nil {
+		f, err := os.OpenFile(w.path, os.O_WRONLY, 0644)
+		if err != nil {
+			return err
+		}
+		w.buffered = f
+	}
+	n, err := w.buffered.WriteAt(p, w.seg.Next)
+	w.seg.Next += int64(n)
+	return err
+}
+
+func (w *directWriter) Close() error {
+	err := w.flush()
+	if cerr := w.direct.Close(); err == nil {
+		err = cerr
+	}
+	if w.buffered != nil {
+		if cerr := w.buffered.Close(); err == nil {
+			err = cerr
+		}
+	}
+	return err
+}
+
+// alignedBuffer returns a size-byte buffer whose address is a multiple of
+// directAlign, as direct I/O requires.
+func alignedBuffer(size int) []byte {
+	b := make([]byte, size+directAlign)
+	offset := 0
+	if rem := int(uintptr(unsafe.Pointer(&b[0])) % directAlign); rem != 0 {
+		offset = directAlign - rem
+	}
+	return b[offset : offset+size : offset+size]
+}
@@ -0,0 +1,24 @@
+package downloader
+
+import (
+	"os"
+	"syscall"
+
+	"golang.org/x/sys/unix"
+)
+
+// preallocate reserves size bytes for f with fallocate, so a large download
+// is laid out contiguously instead of growing a sparse file as parallel
+// writes land. Filesystems without fallocate get a sparse file.
+func preallocate(f *os.File, size int64) error {
+	if err := unix.Fallocate(int(f.Fd()), 0, 0, size); err == nil {
+		return nil
+	}
+	return f.Truncate(size)
+}
+
+// openDirect opens path for writing with O_DIRECT. Filesystems without
+// direct I/O, such as tmpfs, fail with EINVAL.
+func openDirect(path string) (*os.File, error) {
+	return os.OpenFile(path, os.O_WRONLY|syscall.O_DIRECT, 0644)
+}
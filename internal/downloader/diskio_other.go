@@ -0,0 +1,18 @@
+//go:build !linux
+
+package downloader
+
+import (
+	"errors"
+	"os"
+)
+
+// preallocate sizes f up front; only Linux reserves the blocks as well.
+func preallocate(f *os.File, size int64) error {
+	return f.Truncate(size)
+}
+
+// openDirect is only supported on Linux.
+func openDirect(string) (*os.File, error) {
+	return nil, errors.ErrUnsupported
+}
@@ -0,0 +1,61 @@
+package downloader
+
+import (
+	"bytes"
+	"crypto/rand"
+	"os"
+	"path/filepath"
+	"testing"
+	"unsafe"
+)
+
+func TestDirectWriter_UnalignedSegment(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot.tmp")
+	f, _ := os.Create(path)
+	if err := preallocate(f, 3*directBufferSize); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	if f, err := openDirect(path); err != nil {
+		t.Skipf("direct I/O not supported here: %v", err)
+	} else {
+		f.Close()
+	}
+
+	// A segment starting and ending mid-block, written in odd-sized pieces
+	// across more than one buffer
+	data := make([]byte, 3*directBufferSize)
+	rand.Read(data)
+	seg := &segment{Next: 1000, End: int64(len(data)) - 777}
+	w, err := openSegmentWriter(path, seg, true)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for off := seg.Next; off <= seg.End; {
+		n := min(int64(12345), seg.End-off+1)
+		if _, err := w.Write(data[off : off+n]); err != nil {
+			t.Fatal(err)
+		}
+		off += n
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+
+	if !seg.done() {
+		t.Errorf("expected segment to be complete, next=%d end=%d", seg.Next, seg.End)
+	}
+	got, _ := os.ReadFile(path)
+	if !bytes.Equal(got[1000:len(data)-776], data[1000:len(data)-776]) {
+		t.Error("file content mismatch")
+	}
+}
+
+func TestAlignedBuffer(t *testing.T) {
+	for range 10 {
+		b := alignedBuffer(directBufferSize)
+		if len(b) != directBufferSize || uintptr(unsafe.Pointer(&b[0]))%directAlign != 0 {
+			t.Fatalf("buffer not aligned: len %d addr %p", len(b), &b[0])
+		}
+	}
+}
@@ -2,6 +2,7 @@ package downloader
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -12,7 +13,6 @@ import (
 	"sync/atomic"
 	"time"
 
-	"github.com/charmbracelet/bubbles/progress"
 	"github.com/charmbracelet/log"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
 )
@@ -25,23 +25,103 @@ type Options struct {
 	MinSpeedCheckDelay    time.Duration
 	DownloadConnections   int
 	DownloadTimeout       time.Duration
+	Client                *http.Client // nil uses http.DefaultClient
+	PerSource             SourceLimits
+	Progress              ProgressReporter // nil reports nothing
+	// StallTimeout re-requests the rest of a parallel chunk whose connection
+	// goes this long without data, up to StallRetries times (0 = disabled)
+	StallTimeout time.Duration
+	StallRetries int
+	// ChunkRetries re-requests the rest of a parallel chunk that fails
+	// otherwise this many times, waiting ChunkRetryDelay before the first
+	// and doubling the wait each time (0 = fail the download)
+	ChunkRetries    int
+	ChunkRetryDelay time.Duration
+	// Mirrors serve the same file as the download URL, e.g. other sources of
+	// the same snapshot; a failed chunk is retried from the next one in turn
+	Mirrors  []string
+	Adaptive AdaptiveConnections
+	// Preallocate reserves a parallel download's full size on disk up front
+	Preallocate bool
+	// DirectIO writes parallel downloads with O_DIRECT, bypassing the page
+	// cache, where the platform and filesystem support it
+	DirectIO bool
+	// TempDir holds in-progress downloads (empty = the destination directory)
+	TempDir string
+	// VerifyRanges re-requests this many ranges of a finished parallel
+	// download and compares them with the file before it is renamed into
+	// place (0 = disabled)
+	VerifyRanges int
+	// Verify checks a finished download before it is renamed into place, and
+	// an archive already in the destination directory; a download it
+	// rejects is discarded (nil = no check)
+	Verify func(ctx context.Context, path string) error
+	// Activity is called as a finished download is copied from TempDir on
+	// another filesystem, which reports no Progress (nil = none)
+	Activity func()
+	// Validator tags log lines with the validators entry downloading, when
+	// the config runs several
+	Validator string
+
+	// pacer spaces requests by PerSource.RequestInterval, see paced
+	pacer *requestPacer
+	// manifest hashes a segmented download's chunks as they are written
+	manifest *chunkManifest
+}
+
+// logger tags the package's log lines with Validator, when set.
+func (o Options) logger() *log.Logger {
+	if o.Validator != "" {
+		return logger().With("validator", o.Validator)
+	}
+	return logger()
+}
+
+func (o Options) client() *http.Client {
+	if o.Client != nil {
+		return o.Client
+	}
+	return http.DefaultClient
+}
+
+func (o Options) verify(ctx context.Context, path string) error {
+	if o.Verify == nil {
+		return nil
+	}
+	return o.Verify(ctx, path)
+}
+
+// verifyTemp runs Verify on a finished temp file, discarding it when it is
+// rejected. A check cut short by ctx keeps the file for the next attempt.
+func (o Options) verifyTemp(ctx context.Context, tempPath string) error {
+	err := o.verify(ctx, tempPath)
+	if err != nil && ctx.Err() == nil {
+		os.Remove(tempPath)
+	}
+	return err
 }
 
 // Result contains information about a completed download.
 type Result struct {
 	FilePath     string
-	Bytes        int64
+	Bytes        int64 // bytes transferred
+	ReusedBytes  int64 // bytes copied from a local basis by DownloadDelta
 	DurationSecs float64
 	SpeedBps     int64 // bytes per second
+	// Existing is set when the archive was already in the destination
+	// directory with the size the source serves, so nothing was downloaded
+	Existing bool
 }
 
 // Download downloads a snapshot from the given URL to the destination directory.
 // It uses parallel segmented downloads when the server supports Range requests.
 // The download starts as a speed test — if speed is below threshold during the
 // measurement period, it returns an error so the caller can try the next candidate.
+// An archive already in destDir with the size the source serves is kept as is.
 func Download(ctx context.Context, url string, destDir string, filename string, opts Options) (*Result, error) {
+	opts = opts.paced()
 	destPath := filepath.Join(destDir, filename)
-	tempPath := destPath + ".tmp"
+	tempPath := opts.tempPath(destDir, filename)
 
 	// First, HEAD to check Content-Length and Accept-Ranges
 	headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
@@ -49,41 +129,81 @@ func Download(ctx context.Context, url string, destDir string, filename string,
 		return nil, fmt.Errorf("creating HEAD request: %w", err)
 	}
 
-	headResp, err := http.DefaultClient.Do(headReq)
+	headResp, err := opts.client().Do(headReq)
 	if err != nil {
 		return nil, fmt.Errorf("HEAD request: %w", err)
 	}
 	headResp.Body.Close()
 
 	contentLength := headResp.ContentLength
+	if info, err := os.Stat(destPath); err == nil && info.Mode().IsRegular() && contentLength > 0 && info.Size() == contentLength {
+		opts.logger().Info("snapshot already downloaded with the size the source serves - skipping the download", "url", url, "file", filename)
+		if err := opts.verify(ctx, destPath); err != nil {
+			return nil, err
+		}
+		return &Result{FilePath: destPath, Existing: true}, nil
+	}
 	supportsRange := headResp.Header.Get("Accept-Ranges") == "bytes" && contentLength > 0
 	snapshotType := discovery.SnapshotTypeFull
 	if strings.Contains(filename, "incremental") {
 		snapshotType = discovery.SnapshotTypeIncremental
 	}
 
-	logger().Info(fmt.Sprintf("downloading %s snapshot - %s", snapshotType, formatBytes(contentLength)),
+	connections := opts.PerSource.connections(opts.DownloadConnections)
+	limiter := newRateLimiter(opts.PerSource.MaxBytesPerSec)
+
+	opts.logger().Info(fmt.Sprintf("downloading %s snapshot - %s", snapshotType, formatBytes(contentLength)),
 		"url", url,
-		"parallel", supportsRange && opts.DownloadConnections > 1,
-		"connections", opts.DownloadConnections,
+		"parallel", supportsRange && connections > 1,
+		"connections", connections,
 	)
 
 	start := time.Now()
 	var totalBytes int64
-
-	if supportsRange && opts.DownloadConnections > 1 {
-		totalBytes, err = downloadParallel(ctx, url, tempPath, contentLength, opts)
-	} else {
-		totalBytes, err = downloadSingle(ctx, url, tempPath, opts)
+	var downloaded atomic.Int64
+
+	// Segmented downloads resume from a paused download's partial file
+	var segments []segment
+	resuming := false
+	if supportsRange && connections > 1 {
+		segments, resuming, err = resumeOrSplit(ctx, url, tempPath, contentLength, connections, &downloaded, &opts)
+		if err != nil {
+			return nil, err
+		}
+	}
+	stopProgress := trackProgress(opts.Progress, filename, contentLength, &downloaded)
+
+	if segments != nil {
+		totalBytes, err = downloadParallel(ctx, url, tempPath, contentLength, segments, resuming, limiter, &downloaded, opts)
+		if errors.Is(err, errRangeIgnored) {
+			// Nothing the chunks wrote can be trusted, so start over
+			opts.logger().Warn("source ignored a Range request despite advertising support - falling back to a single connection", "url", url)
+			segments = nil
+			downloaded.Store(0)
+			os.Remove(partialPath(tempPath))
+		}
 	}
+	if segments == nil {
+		totalBytes, err = downloadSingle(ctx, url, tempPath, limiter, &downloaded, opts)
+	}
+	stopProgress()
 
+	if err != nil && segments != nil && errors.Is(context.Cause(ctx), ErrPaused) {
+		if saveErr := savePartial(tempPath, contentLength, segments, opts.manifest.list()); saveErr == nil {
+			return nil, fmt.Errorf("%w - %s of %s kept", ErrPaused, formatBytes(downloaded.Load()), formatBytes(contentLength))
+		}
+	}
 	if err != nil {
 		os.Remove(tempPath)
 		return nil, err
 	}
 
+	if err := opts.verifyTemp(ctx, tempPath); err != nil {
+		return nil, err
+	}
+
 	// Atomic rename
-	if err := os.Rename(tempPath, destPath); err != nil {
+	if err := opts.moveIntoPlace(tempPath, destPath); err != nil {
 		os.Remove(tempPath)
 		return nil, fmt.Errorf("renaming temp file: %w", err)
 	}
@@ -91,7 +211,7 @@ func Download(ctx context.Context, url string, destDir string, filename string,
 	duration := time.Since(start)
 	speedBps := float64(totalBytes) / duration.Seconds()
 
-	logger().Info(fmt.Sprintf("downloaded %s snapshot - %s in %s at %s/s", snapshotType, formatBytes(totalBytes), duration, formatBytes(int64(speedBps))),
+	opts.logger().Info(fmt.Sprintf("downloaded %s snapshot - %s in %s at %s/s", snapshotType, formatBytes(totalBytes), duration, formatBytes(int64(speedBps))),
 		"url", url,
 		"file", filename,
 	)
@@ -104,27 +224,74 @@ func Download(ctx context.Context, url string, destDir string, filename string,
 	}, nil
 }
 
-func downloadParallel(ctx context.Context, url string, tempPath string, contentLength int64, opts Options) (int64, error) {
-	numConns := opts.DownloadConnections
-	chunkSize := contentLength / int64(numConns)
+// resumeOrSplit returns the segments a segmented download fetches: those a
+// paused download still misses when its resume boundaries check out, or the
+// whole file split for connections. The manifest hashing the download's
+// chunks is set on opts.
+func resumeOrSplit(ctx context.Context, url, tempPath string, size int64, connections int, downloaded *atomic.Int64, opts *Options) ([]segment, bool, error) {
+	segments, chunks, resumed := loadPartial(tempPath, size)
+	if segments != nil {
+		err := verifyResume(ctx, url, tempPath, segments, chunks, *opts)
+		switch {
+		case err == nil:
+			opts.logger().Info(fmt.Sprintf("resuming paused download - %s of %s already downloaded", formatBytes(resumed), formatBytes(size)), "file", filepath.Base(tempPath))
+			downloaded.Store(resumed)
+			opts.manifest = &chunkManifest{chunks: chunks}
+			return segments, true, nil
+		case errors.Is(err, errPartialChanged) || errors.Is(err, ErrRangeMismatch):
+			opts.logger().Warn("paused download doesn't match what the source serves - starting over", "file", filepath.Base(tempPath), "error", err)
+			os.Remove(tempPath)
+		default:
+			// Keep the partial download for a later attempt
+			if saveErr := savePartial(tempPath, size, segments, chunks); saveErr != nil {
+				os.Remove(tempPath)
+			}
+			return nil, false, fmt.Errorf("verifying paused download: %w", err)
+		}
+	}
 
-	// Create the output file with the full size
-	f, err := os.Create(tempPath)
-	if err != nil {
-		return 0, fmt.Errorf("creating temp file: %w", err)
+	opts.manifest = &chunkManifest{}
+	if opts.Adaptive.Enabled {
+		return splitPieces(size, connections), false, nil
 	}
-	if err := f.Truncate(contentLength); err != nil {
+	return splitSegments(size, connections), false, nil
+}
+
+// downloadParallel fetches segments of the file concurrently, advancing each
+// segment as its bytes are written. With resume the temp file already holds
+// the bytes before each segment's Next.
+func downloadParallel(ctx context.Context, url string, tempPath string, contentLength int64, segments []segment, resume bool, limiter *rateLimiter, totalDownloaded *atomic.Int64, opts Options) (int64, error) {
+	if !resume {
+		// Create the output file with the full size
+		f, err := os.Create(tempPath)
+		if err != nil {
+			return 0, fmt.Errorf("creating temp file: %w", err)
+		}
+		size := f.Truncate
+		if opts.Preallocate {
+			size = func(n int64) error { return preallocate(f, n) }
+		}
+		if err := size(contentLength); err != nil {
+			f.Close()
+			return 0, fmt.Errorf("sizing temp file: %w", err)
+		}
 		f.Close()
-		return 0, fmt.Errorf("truncating file: %w", err)
 	}
-	f.Close()
+	if opts.DirectIO {
+		if f, err := openDirect(tempPath); err != nil {
+			opts.logger().Warn("direct I/O not available for the snapshot directory, writing through the page cache", "error", err)
+			opts.DirectIO = false
+		} else {
+			f.Close()
+		}
+	}
+	resumed := totalDownloaded.Load()
 
 	var (
-		totalDownloaded atomic.Int64
-		downloadErr     error
-		errOnce         sync.Once
-		wg              sync.WaitGroup
-		speedChecked    atomic.Bool
+		downloadErr  error
+		errOnce      sync.Once
+		wg           sync.WaitGroup
+		speedChecked atomic.Bool
 	)
 
 	downloadCtx, cancel := context.WithCancel(ctx)
@@ -137,7 +304,7 @@ func downloadParallel(ctx context.Context, url string, tempPath string, contentL
 			defer timer.Stop()
 			select {
 			case <-timer.C:
-				downloaded := totalDownloaded.Load()
+				downloaded := totalDownloaded.Load() - resumed
 				elapsed := opts.MinSpeedCheckDelay.Seconds()
 				speedBps := float64(downloaded) / elapsed
 				speedChecked.Store(true)
@@ -147,125 +314,235 @@ func downloadParallel(ctx context.Context, url string, tempPath string, contentL
 					})
 					cancel()
 				} else {
-					logger().Info("speed check passed", "speed", fmt.Sprintf("%s/s", formatBytes(int64(speedBps))))
+					opts.logger().Info("speed check passed", "speed", fmt.Sprintf("%s/s", formatBytes(int64(speedBps))))
 				}
 			case <-downloadCtx.Done():
 			}
 		}()
 	}
 
-	// Progress bar goroutine
-	go func() {
-		bar := progress.New(progress.WithDefaultGradient(), progress.WithWidth(40))
-		ticker := time.NewTicker(500 * time.Millisecond)
-		defer ticker.Stop()
-		start := time.Now()
-		for {
-			select {
-			case <-ticker.C:
-				downloaded := totalDownloaded.Load()
-				elapsed := time.Since(start).Seconds()
-				speedBps := float64(downloaded) / elapsed
-				pct := float64(downloaded) / float64(contentLength)
-				eta := 0.0
-				if speedBps > 0 {
-					eta = float64(contentLength-downloaded) / speedBps
+	// Launch parallel chunk downloads, one connection per segment unless the
+	// connection count is adaptive
+	pool := &connPool{wg: &wg, segments: segments}
+	open := func(n int) {
+		for range n {
+			go func() {
+				defer wg.Done()
+				for {
+					index, ok := pool.take()
+					if !ok {
+						return
+					}
+					if err := downloadChunk(downloadCtx, url, tempPath, index, &segments[index], totalDownloaded, limiter, opts); err != nil {
+						pool.fail()
+						errOnce.Do(func() {
+							downloadErr = fmt.Errorf("chunk %d: %w", index, err)
+						})
+						cancel()
+						return
+					}
 				}
-				fmt.Fprintf(os.Stderr, "\r  %s %s/s  eta %s  ",
-					bar.ViewAs(pct),
-					formatBytes(int64(speedBps)),
-					time.Duration(eta)*time.Second,
-				)
-			case <-downloadCtx.Done():
-				fmt.Fprint(os.Stderr, "\r\033[2K") // clear the progress bar line
-				return
-			}
-		}
-	}()
-
-	// Launch parallel chunk downloads
-	for i := 0; i < numConns; i++ {
-		rangeStart := int64(i) * chunkSize
-		rangeEnd := rangeStart + chunkSize - 1
-		if i == numConns-1 {
-			rangeEnd = contentLength - 1
+			}()
 		}
+	}
 
-		wg.Add(1)
-		go func(index int, start, end int64) {
-			defer wg.Done()
-			if err := downloadChunk(downloadCtx, url, tempPath, start, end, &totalDownloaded); err != nil {
-				errOnce.Do(func() {
-					downloadErr = fmt.Errorf("chunk %d: %w", index, err)
-				})
-				cancel()
-			}
-		}(i, rangeStart, rangeEnd)
+	if opts.Adaptive.Enabled {
+		tuner := newConnTuner(opts.Adaptive, opts.PerSource.connections(opts.DownloadConnections))
+		open(pool.resize(tuner.conns))
+		tuneCtx, stopTuning := context.WithCancel(downloadCtx)
+		tuned := make(chan struct{})
+		go func() {
+			defer close(tuned)
+			tuneConnections(tuneCtx, pool, tuner, totalDownloaded, opts.Adaptive.Interval, open)
+		}()
+		defer func() {
+			stopTuning()
+			<-tuned
+		}()
+	} else {
+		open(pool.resize(len(segments)))
 	}
 
 	wg.Wait()
 
 	if downloadErr != nil {
-		return totalDownloaded.Load(), downloadErr
+		return totalDownloaded.Load() - resumed, downloadErr
+	}
+	if err := checkComplete(segments, totalDownloaded.Load(), contentLength); err != nil {
+		return totalDownloaded.Load() - resumed, err
+	}
+	if opts.VerifyRanges > 0 {
+		if err := spotCheckRanges(ctx, url, tempPath, contentLength, opts.VerifyRanges, opts); err != nil {
+			return totalDownloaded.Load() - resumed, err
+		}
 	}
 
-	return totalDownloaded.Load(), nil
+	return totalDownloaded.Load() - resumed, nil
 }
 
-func downloadChunk(ctx context.Context, url string, filePath string, rangeStart, rangeEnd int64, totalDownloaded *atomic.Int64) error {
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return err
+// downloadChunk fetches the rest of a segment, re-requesting it from where
+// it got to when the connection stalls.
+func downloadChunk(ctx context.Context, url string, filePath string, index int, seg *segment, totalDownloaded *atomic.Int64, limiter *rateLimiter, opts Options) error {
+	sources := append([]string{url}, opts.Mirrors...)
+	delay := opts.ChunkRetryDelay
+	for retry := 0; ; retry++ {
+		source := sources[retry%len(sources)]
+		err := fetchChunkWithStallRetries(ctx, source, filePath, index, seg, totalDownloaded, limiter, opts)
+		if source != url && errors.Is(err, errRangeIgnored) {
+			// Only the primary ignoring ranges makes the caller start over
+			err = fmt.Errorf("mirror %s ignored the Range header", source)
+		}
+		if err == nil || !chunkRetryable(ctx, err) {
+			return err
+		}
+		if retry >= opts.ChunkRetries {
+			if retry > 0 {
+				return fmt.Errorf("%w (%d retries failed)", err, retry)
+			}
+			return err
+		}
+		next := sources[(retry+1)%len(sources)]
+		opts.logger().Warn(fmt.Sprintf("chunk %d failed - retrying the remaining %s in %s", index, formatBytes(seg.End-seg.Next+1), delay),
+			"url", source,
+			"retry_url", next,
+			"attempt", retry+1,
+			"error", err,
+		)
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-time.After(delay):
+		}
+		delay *= 2
 	}
-	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", rangeStart, rangeEnd))
+}
 
-	resp, err := http.DefaultClient.Do(req)
-	if err != nil {
-		return err
+// chunkRetryable reports whether a failed chunk is worth requesting again.
+// A primary that ignores Range requests is left for the caller to fall back
+// to a single-stream download, and local write errors won't go away.
+func chunkRetryable(ctx context.Context, err error) bool {
+	var writeErr *chunkWriteError
+	return ctx.Err() == nil &&
+		!errors.As(err, &writeErr) &&
+		!errors.Is(err, errRangeIgnored)
+}
+
+// chunkWriteError is a chunk failing to be written locally.
+type chunkWriteError struct{ err error }
+
+func (e *chunkWriteError) Error() string { return e.err.Error() }
+func (e *chunkWriteError) Unwrap() error { return e.err }
+
+// fetchChunkWithStallRetries fetches the rest of a segment from url,
+// re-requesting it when the connection stalls.
+func fetchChunkWithStallRetries(ctx context.Context, url string, filePath string, index int, seg *segment, totalDownloaded *atomic.Int64, limiter *rateLimiter, opts Options) error {
+	for attempt := 1; ; attempt++ {
+		err := fetchChunkRange(ctx, url, filePath, seg, totalDownloaded, limiter, opts)
+		if !errors.Is(err, errStalled) {
+			return err
+		}
+		if attempt > opts.StallRetries {
+			return fmt.Errorf("%w for %s, %d re-requests failed", err, opts.StallTimeout, opts.StallRetries)
+		}
+		opts.logger().Warn(fmt.Sprintf("chunk %d stalled with no data for %s - re-requesting the remaining %s", index, opts.StallTimeout, formatBytes(seg.End-seg.Next+1)),
+			"url", url,
+			"attempt", attempt,
+		)
 	}
-	defer resp.Body.Close()
+}
 
-	if resp.StatusCode != http.StatusPartialContent {
-		return fmt.Errorf("expected 206, got %d", resp.StatusCode)
+// fetchChunkRange issues one Range request for the rest of a segment, advancing
+// it as bytes are written.
+func fetchChunkRange(ctx context.Context, url string, filePath string, seg *segment, totalDownloaded *atomic.Int64, limiter *rateLimiter, opts Options) error {
+	if seg.done() {
+		return nil
 	}
 
-	f, err := os.OpenFile(filePath, os.O_WRONLY, 0644)
-	if err != nil {
+	if err := opts.pace(ctx); err != nil {
 		return err
 	}
-	defer f.Close()
+	reqCtx, watchdog, cancel := watchStalls(ctx, opts.StallTimeout)
+	defer cancel()
+	err := func() (err error) {
+		req, err := http.NewRequestWithContext(reqCtx, http.MethodGet, url, nil)
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", seg.Next, seg.End))
 
-	buf := make([]byte, 256*1024) // 256KB buffer
-	offset := rangeStart
+		resp, err := opts.client().Do(req)
+		if err != nil {
+			return err
+		}
+		defer resp.Body.Close()
 
-	for {
-		n, readErr := resp.Body.Read(buf)
-		if n > 0 {
-			_, writeErr := f.WriteAt(buf[:n], offset)
-			if writeErr != nil {
-				return writeErr
-			}
-			offset += int64(n)
-			totalDownloaded.Add(int64(n))
+		switch resp.StatusCode {
+		case http.StatusPartialContent:
+		case http.StatusOK:
+			return errRangeIgnored
+		default:
+			return fmt.Errorf("expected 206, got %d", resp.StatusCode)
 		}
-		if readErr != nil {
-			if readErr == io.EOF {
-				break
+		if err := checkContentRange(resp, seg); err != nil {
+			return err
+		}
+
+		w, err := openSegmentWriter(filePath, seg, opts.DirectIO)
+		if err != nil {
+			return &chunkWriteError{err}
+		}
+		w = opts.manifest.wrap(w, seg)
+		// Buffered bytes are written even on failure, so seg records them
+		defer func() {
+			if closeErr := w.Close(); err == nil && closeErr != nil {
+				err = &chunkWriteError{closeErr}
+			}
+		}()
+
+		buf := make([]byte, 256*1024) // 256KB buffer
+
+		for {
+			n, readErr := resp.Body.Read(buf)
+			if remaining := seg.End - seg.Next + 1; int64(n) > remaining {
+				// Writing on would overwrite the next segment
+				return fmt.Errorf("%w: sent more than the %s requested", ErrRangeMismatch, formatBytes(remaining))
+			}
+			if n > 0 {
+				if _, writeErr := w.Write(buf[:n]); writeErr != nil {
+					return &chunkWriteError{writeErr}
+				}
+				totalDownloaded.Add(int64(n))
+				watchdog.hold()
+				if err := limiter.wait(reqCtx, n); err != nil {
+					return err
+				}
+				watchdog.feed()
+			}
+			if readErr != nil {
+				if readErr == io.EOF {
+					break
+				}
+				return readErr
 			}
-			return readErr
 		}
+		return nil
+	}()
+	if err != nil && ctx.Err() == nil && errors.Is(context.Cause(reqCtx), errStalled) {
+		return errStalled
 	}
-
-	return nil
+	return err
 }
 
-func downloadSingle(ctx context.Context, url string, tempPath string, opts Options) (int64, error) {
+func downloadSingle(ctx context.Context, url string, tempPath string, limiter *rateLimiter, totalDownloaded *atomic.Int64, opts Options) (int64, error) {
+	if err := opts.pace(ctx); err != nil {
+		return 0, err
+	}
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
 		return 0, fmt.Errorf("creating GET request: %w", err)
 	}
 
-	resp, err := http.DefaultClient.Do(req)
+	resp, err := opts.client().Do(req)
 	if err != nil {
 		return 0, fmt.Errorf("GET request: %w", err)
 	}
@@ -281,7 +558,6 @@ func downloadSingle(ctx context.Context, url string, tempPath string, opts Optio
 	}
 	defer f.Close()
 
-	var totalDownloaded atomic.Int64
 	start := time.Now()
 
 	// Speed check goroutine for single download
@@ -324,6 +600,9 @@ func downloadSingle(ctx context.Context, url string, tempPath string, opts Optio
 			}
 			total += int64(n)
 			totalDownloaded.Add(int64(n))
+			if err := limiter.wait(downloadCtx, n); err != nil {
+				continue // cancellation is reported at the top of the loop
+			}
 		}
 		if readErr != nil {
 			if readErr == io.EOF {
@@ -1,17 +1,26 @@
 package downloader
 
 import (
+	"bytes"
 	"context"
 	"crypto/rand"
+	"encoding/json"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"regexp"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
 	"testing"
 	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/delta"
 )
 
 func newRangeServer(t *testing.T, data []byte) *httptest.Server {
@@ -172,12 +181,543 @@ func TestDownload_ContextCancellation(t *testing.T) {
 	}
 
 	// Verify temp file was cleaned up
-	tempPath := filepath.Join(destDir, "test.tar.zst.tmp")
+	tempPath := filepath.Join(destDir, "test.tar.zst"+tempSuffix("test.tar.zst"))
 	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
 		t.Error("expected temp file to be cleaned up")
 	}
 }
 
+// pauseProgress cancels a download with ErrPaused once it has reached bytes.
+type pauseProgress struct {
+	bytes  int64
+	cancel context.CancelCauseFunc
+}
+
+func (p pauseProgress) Report(pr Progress) {
+	if pr.Downloaded >= p.bytes {
+		p.cancel(ErrPaused)
+	}
+}
+
+func (pauseProgress) Done(Progress) {}
+
+func TestDownload_PauseAndResume(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.Read(data)
+
+	// While stalling, each range is served halfway and then hangs
+	var stalling atomic.Bool
+	stalling.Store(true)
+	var served atomic.Int64
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		var start, end int64
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+		w.WriteHeader(http.StatusPartialContent)
+		if stalling.Load() {
+			half := start + (end-start+1)/2
+			w.Write(data[start:half])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		served.Add(end - start + 1)
+		w.Write(data[start : end+1])
+	}))
+	defer server.Close()
+
+	destDir := t.TempDir()
+	tempPath := filepath.Join(destDir, "snapshot-100-Hash.tar.zst"+tempSuffix("snapshot-100-Hash.tar.zst"))
+	ctx, cancel := context.WithCancelCause(context.Background())
+	opts := Options{DownloadConnections: 4, DownloadTimeout: time.Minute, Progress: pauseProgress{bytes: int64(len(data) / 2), cancel: cancel}}
+
+	_, err := Download(ctx, server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", opts)
+	if !errors.Is(err, ErrPaused) {
+		t.Fatalf("expected ErrPaused, got %v", err)
+	}
+	for _, path := range []string{tempPath, partialPath(tempPath)} {
+		if _, err := os.Stat(path); err != nil {
+			t.Fatalf("expected %s to be kept: %v", filepath.Base(path), err)
+		}
+	}
+
+	stalling.Store(false)
+	opts.Progress = nil
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Besides the missing half, the end of each segment's written half is
+	// re-requested to check the source still serves the same bytes
+	if boundaries := int64(4 * spotCheckSize); served.Load() != int64(len(data)/2)+boundaries || result.Bytes != int64(len(data)/2) {
+		t.Errorf("expected only the missing half to be fetched, served %d bytes, result %d", served.Load(), result.Bytes)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Error("resumed download doesn't match the source")
+	}
+	if _, err := os.Stat(partialPath(tempPath)); !os.IsNotExist(err) {
+		t.Error("expected the partial state to be removed after resuming")
+	}
+}
+
+func TestDownload_ResumeVerifiesBoundaries(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		other  bool // resume from a source serving different bytes
+		tamper bool // change a written byte of the temp file while paused
+	}{
+		{name: "other source", other: true},
+		{name: "tampered temp file", tamper: true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			data := make([]byte, 1024*1024)
+			rand.Read(data)
+
+			// Serves each range halfway and then hangs
+			paused := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("Accept-Ranges", "bytes")
+				if r.Method == http.MethodHead {
+					w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+					return
+				}
+				var start, end int64
+				fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+				w.Header().Set("Content-Length", strconv.FormatInt(end-start+1, 10))
+				w.WriteHeader(http.StatusPartialContent)
+				w.Write(data[start : start+(end-start+1)/2])
+				w.(http.Flusher).Flush()
+				<-r.Context().Done()
+			}))
+			defer paused.Close()
+
+			destDir := t.TempDir()
+			tempPath := filepath.Join(destDir, "snapshot-100-Hash.tar.zst"+tempSuffix("snapshot-100-Hash.tar.zst"))
+			ctx, cancel := context.WithCancelCause(context.Background())
+			opts := Options{DownloadConnections: 4, DownloadTimeout: time.Minute, Progress: pauseProgress{bytes: int64(len(data) / 2), cancel: cancel}}
+			if _, err := Download(ctx, paused.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", opts); !errors.Is(err, ErrPaused) {
+				t.Fatalf("expected ErrPaused, got %v", err)
+			}
+
+			want := data
+			if tt.other {
+				want = make([]byte, len(data))
+				rand.Read(want)
+			}
+			if tt.tamper {
+				f, err := os.OpenFile(tempPath, os.O_WRONLY, 0)
+				if err != nil {
+					t.Fatal(err)
+				}
+				f.WriteAt([]byte{^data[1000]}, 1000)
+				f.Close()
+			}
+			resume := newRangeServer(t, want)
+			defer resume.Close()
+
+			opts.Progress = nil
+			result, err := Download(context.Background(), resume.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", opts)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Bytes != int64(len(data)) {
+				t.Errorf("expected the download to start over, fetched %d of %d bytes", result.Bytes, len(data))
+			}
+			got, _ := os.ReadFile(result.FilePath)
+			if !bytes.Equal(got, want) {
+				t.Error("download doesn't match the source it resumed from")
+			}
+		})
+	}
+}
+
+func TestDownload_StalledChunkIsReRequested(t *testing.T) {
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+	ranges := newRangeServer(t, data)
+	defer ranges.Close()
+
+	// The first request for the first chunk sends a little, then hangs
+	var stalled atomic.Bool
+	var requests []string
+	var mu sync.Mutex
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		requests = append(requests, r.Header.Get("Range"))
+		mu.Unlock()
+		if strings.HasPrefix(r.Header.Get("Range"), "bytes=0-") && stalled.CompareAndSwap(false, true) {
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)/2))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[:1024])
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+			return
+		}
+		ranges.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	destDir := t.TempDir()
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-abc.tar.zst", Options{
+		DownloadConnections: 2,
+		DownloadTimeout:     10 * time.Second,
+		StallTimeout:        200 * time.Millisecond,
+		StallRetries:        1,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(requests, fmt.Sprintf("bytes=1024-%d", len(data)/2-1)) {
+		t.Errorf("expected the stalled chunk to be re-requested from where it stopped, got requests %v", requests)
+	}
+}
+
+func TestDownload_StalledChunkFailsAfterRetries(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", "4096")
+			return
+		}
+		w.WriteHeader(http.StatusPartialContent)
+		w.(http.Flusher).Flush()
+		<-r.Context().Done()
+	}))
+	defer server.Close()
+
+	_, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", Options{
+		DownloadConnections: 2,
+		StallTimeout:        50 * time.Millisecond,
+		StallRetries:        2,
+	})
+	if !errors.Is(err, errStalled) {
+		t.Fatalf("expected a stall error, got %v", err)
+	}
+}
+
+func TestDownload_FailedChunkIsRetried(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	ranges := newRangeServer(t, data)
+	defer ranges.Close()
+	secondChunk := fmt.Sprintf("bytes=%d-", len(data)/4)
+
+	tests := []struct {
+		name         string
+		failures     int // times the primary fails the second chunk
+		mirror       bool
+		wantErr      bool
+		wantRequests int // for the second chunk, to the primary
+	}{
+		{"transient failure", 1, false, false, 2},
+		{"persistent failure, fetched from the mirror", 100, true, false, 1},
+		{"persistent failure, no mirror", 100, false, true, 3},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var requests, mirrorRequests atomic.Int32
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if strings.HasPrefix(r.Header.Get("Range"), secondChunk) && int(requests.Add(1)) <= tt.failures {
+					w.WriteHeader(http.StatusInternalServerError)
+					return
+				}
+				ranges.Config.Handler.ServeHTTP(w, r)
+			}))
+			defer server.Close()
+			mirror := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				mirrorRequests.Add(1)
+				ranges.Config.Handler.ServeHTTP(w, r)
+			}))
+			defer mirror.Close()
+
+			opts := Options{DownloadConnections: 4, ChunkRetries: 2, ChunkRetryDelay: time.Millisecond}
+			if tt.mirror {
+				opts.Mirrors = []string{mirror.URL + "/snapshot.tar.zst"}
+			}
+			destDir := t.TempDir()
+			result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-abc.tar.zst", opts)
+			if got := int(requests.Load()); got != tt.wantRequests {
+				t.Errorf("expected %d requests for the chunk to the primary, got %d", tt.wantRequests, got)
+			}
+			if tt.mirror && mirrorRequests.Load() == 0 {
+				t.Error("expected the chunk to be fetched from the mirror")
+			}
+			if tt.wantErr {
+				if err == nil || !strings.Contains(err.Error(), "2 retries failed") {
+					t.Fatalf("expected the download to fail after 2 retries, got %v", err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("Download() error: %v", err)
+			}
+			got, _ := os.ReadFile(result.FilePath)
+			if !bytes.Equal(got, data) {
+				t.Error("downloaded data mismatch")
+			}
+		})
+	}
+}
+
+func TestDownload_MisservedRanges(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	ranges := newRangeServer(t, data)
+	defer ranges.Close()
+	secondChunk := fmt.Sprintf("bytes=%d-", len(data)/4)
+
+	tests := []struct {
+		name    string
+		serve   func(w http.ResponseWriter, r *http.Request) bool // true if handled
+		wantErr error
+	}{
+		{"chunk ends short", func(w http.ResponseWriter, r *http.Request) bool {
+			if !strings.HasPrefix(r.Header.Get("Range"), secondChunk) {
+				return false
+			}
+			// No Content-Length, so the body just ends early
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[len(data)/4 : len(data)/4+1024])
+			return true
+		}, nil},
+		{"wrong Content-Range", func(w http.ResponseWriter, r *http.Request) bool {
+			if !strings.HasPrefix(r.Header.Get("Range"), secondChunk) {
+				return false
+			}
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes 0-%d/%d", len(data)/4-1, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[:len(data)/4])
+			return true
+		}, ErrRangeMismatch},
+		{"more than requested", func(w http.ResponseWriter, r *http.Request) bool {
+			if !strings.HasPrefix(r.Header.Get("Range"), secondChunk) {
+				return false
+			}
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[len(data)/4:])
+			return true
+		}, ErrRangeMismatch},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.Method == http.MethodHead || !tt.serve(w, r) {
+					ranges.Config.Handler.ServeHTTP(w, r)
+				}
+			}))
+			defer server.Close()
+
+			destDir := t.TempDir()
+			_, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-abc.tar.zst", Options{DownloadConnections: 4})
+			if err == nil || (tt.wantErr != nil && !errors.Is(err, tt.wantErr)) {
+				t.Fatalf("expected the download to fail with %v, got %v", tt.wantErr, err)
+			}
+			if entries, _ := os.ReadDir(destDir); len(entries) != 0 {
+				t.Errorf("expected nothing left behind, found %d files", len(entries))
+			}
+		})
+	}
+}
+
+func TestDownload_RangeIgnoredFallsBackToSingle(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+
+	// Advertises ranges, but answers every GET with the whole file
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Accept-Ranges", "bytes")
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", Options{DownloadConnections: 4})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+	if result.Bytes != int64(len(data)) {
+		t.Errorf("expected %d bytes counted for the single-connection download, got %d", len(data), result.Bytes)
+	}
+	if n := gets.Load(); n < 2 || n > 5 {
+		t.Errorf("expected the ranged attempt to be abandoned for one more GET, got %d GETs", n)
+	}
+}
+
+func TestDownload_SpotCheckRanges(t *testing.T) {
+	data := make([]byte, 1<<20)
+	rand.Read(data)
+	ranges := newRangeServer(t, data)
+	defer ranges.Close()
+
+	// The download's request for the third chunk gets the wrong bytes under
+	// a correct Content-Range; later requests are served correctly
+	var misserved atomic.Bool
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start, end := len(data)/2, 3*len(data)/4-1
+		if r.Header.Get("Range") == fmt.Sprintf("bytes=%d-%d", start, end) && misserved.CompareAndSwap(false, true) {
+			w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, len(data)))
+			w.WriteHeader(http.StatusPartialContent)
+			w.Write(data[start-1 : end])
+			return
+		}
+		ranges.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	opts := Options{DownloadConnections: 4, VerifyRanges: 8}
+	if _, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", opts); !errors.Is(err, ErrRangeMismatch) {
+		t.Fatalf("expected the spot check to catch the mis-served chunk, got %v", err)
+	}
+
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", opts)
+	if err != nil {
+		t.Fatalf("expected a correctly served download to pass its spot checks: %v", err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Error("downloaded data mismatch")
+	}
+}
+
+func TestConnTuner(t *testing.T) {
+	// Throughput scales with connections up to 4, then flattens
+	rate := func(conns int) float64 { return float64(min(conns, 4)) * 100 }
+
+	tuner := newConnTuner(AdaptiveConnections{Initial: 1, MinGain: 0.1}, 16)
+	var counts []int
+	for conns := tuner.conns; len(counts) < 12; {
+		conns = tuner.next(rate(conns))
+		counts = append(counts, conns)
+	}
+	want := []int{2, 3, 4, 5, 4, 4, 4, 4, 4, 4, 4, 5}
+	if !slices.Equal(counts, want) {
+		t.Errorf("connection counts %v, want %v", counts, want)
+	}
+
+	// Never beyond the maximum
+	tuner = newConnTuner(AdaptiveConnections{Initial: 8, MinGain: 0}, 4)
+	for range 5 {
+		if n := tuner.next(1000); n > 4 {
+			t.Fatalf("tuner exceeded max connections: %d", n)
+		}
+	}
+}
+
+func TestDownload_AdaptiveConnections(t *testing.T) {
+	data := make([]byte, 1024*1024)
+	rand.Read(data)
+
+	var inFlight, peak atomic.Int32
+	ranges := newRangeServer(t, data)
+	defer ranges.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			n := inFlight.Add(1)
+			defer inFlight.Add(-1)
+			for p := peak.Load(); n > p && !peak.CompareAndSwap(p, n); p = peak.Load() {
+			}
+			time.Sleep(5 * time.Millisecond)
+		}
+		ranges.Config.Handler.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", Options{
+		DownloadConnections: 4,
+		DownloadTimeout:     10 * time.Second,
+		Adaptive:            AdaptiveConnections{Enabled: true, Initial: 1, Interval: 10 * time.Millisecond},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+	if p := peak.Load(); p > 4 {
+		t.Errorf("expected at most 4 concurrent requests, saw %d", p)
+	}
+}
+
+func TestDownload_PreallocatedDirectIO(t *testing.T) {
+	data := make([]byte, 3*1024*1024+123)
+	rand.Read(data)
+	server := newRangeServer(t, data)
+	defer server.Close()
+
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-abc.tar.zst", Options{
+		DownloadConnections: 3,
+		DownloadTimeout:     10 * time.Second,
+		Preallocate:         true,
+		DirectIO:            true, // falls back to the page cache where unsupported
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+}
+
+func TestDownload_TempDirOnOtherFilesystem(t *testing.T) {
+	// /dev/shm is a tmpfs on Linux, so renaming out of it fails with EXDEV
+	tempDir, err := os.MkdirTemp("/dev/shm", "snapshot-keeper-test")
+	if err != nil {
+		t.Skip("no /dev/shm")
+	}
+	defer os.RemoveAll(tempDir)
+
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+	server := newRangeServer(t, data)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-abc.tar.zst", Options{
+		DownloadConnections: 2,
+		DownloadTimeout:     10 * time.Second,
+		TempDir:             tempDir,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.FilePath != filepath.Join(destDir, "snapshot-100-abc.tar.zst") {
+		t.Errorf("unexpected file path %s", result.FilePath)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, data) {
+		t.Fatal("downloaded data mismatch")
+	}
+	for _, dir := range []string{tempDir, destDir} {
+		entries, _ := os.ReadDir(dir)
+		for _, e := range entries {
+			if strings.Contains(e.Name(), ".tmp") {
+				t.Errorf("temp file %s left in %s", e.Name(), dir)
+			}
+		}
+	}
+}
+
 func TestDownload_AtomicRename(t *testing.T) {
 	data := []byte("snapshot data")
 	server := newSimpleServer(t, data)
@@ -202,8 +742,217 @@ func TestDownload_AtomicRename(t *testing.T) {
 	}
 
 	// Temp file should not exist
-	tempPath := result.FilePath + ".tmp"
+	tempPath := result.FilePath + tempSuffix(filepath.Base(result.FilePath))
 	if _, err := os.Stat(tempPath); !os.IsNotExist(err) {
 		t.Error("temp file should not exist after completion")
 	}
 }
+
+func TestDownload_VerifyBeforeRename(t *testing.T) {
+	data := []byte("snapshot data")
+	server := newSimpleServer(t, data)
+	defer server.Close()
+
+	destDir := t.TempDir()
+	var checked string
+	opts := Options{
+		DownloadConnections: 1,
+		DownloadTimeout:     time.Minute,
+		Verify: func(ctx context.Context, path string) error {
+			checked = path
+			return errors.New("hash mismatch")
+		},
+	}
+
+	if _, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", opts); err == nil {
+		t.Fatal("expected the rejected download to fail")
+	}
+	if filepath.Base(checked) != "snapshot-100-Hash.tar.zst"+tempSuffix("snapshot-100-Hash.tar.zst") {
+		t.Errorf("expected the temp file to be verified, got %s", checked)
+	}
+	entries, _ := os.ReadDir(destDir)
+	if len(entries) != 0 {
+		t.Errorf("expected the rejected download to be discarded, found %v", entries)
+	}
+}
+
+func TestDownload_KeepsExistingArchive(t *testing.T) {
+	data := []byte("snapshot data")
+	var gets atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodGet {
+			gets.Add(1)
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+		if r.Method == http.MethodGet {
+			w.Write(data)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		name         string
+		existing     []byte
+		wantExisting bool
+	}{
+		{"same size", []byte("SNAPSHOT DATA"), true},
+		{"different size", []byte("partial"), false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			gets.Store(0)
+			destDir := t.TempDir()
+			path := filepath.Join(destDir, "snapshot-100-Hash.tar.zst")
+			os.WriteFile(path, tt.existing, 0644)
+
+			result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", destDir, "snapshot-100-Hash.tar.zst", Options{DownloadConnections: 1})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if result.Existing != tt.wantExisting {
+				t.Errorf("expected Existing %v, got %v", tt.wantExisting, result.Existing)
+			}
+			want := data
+			if tt.wantExisting {
+				want = tt.existing
+			}
+			if got, _ := os.ReadFile(path); !bytes.Equal(got, want) {
+				t.Errorf("expected %q on disk, got %q", want, got)
+			}
+			if tt.wantExisting && gets.Load() != 0 {
+				t.Errorf("expected no GET for an existing archive, got %d", gets.Load())
+			}
+		})
+	}
+}
+
+func TestDownload_PerSourceLimits(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+
+	server := newRangeServer(t, data)
+	defer server.Close()
+
+	opts := Options{
+		DownloadConnections: 8,
+		DownloadTimeout:     time.Minute,
+		PerSource:           SourceLimits{MaxConnections: 2, MaxBytesPerSec: 512 * 1024},
+	}
+
+	start := time.Now()
+	result, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-Hash.tar.zst", opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bytes != int64(len(data)) {
+		t.Errorf("expected %d bytes, got %d", len(data), result.Bytes)
+	}
+	// 256 KB at 512 KB/s takes ~500ms
+	if elapsed := time.Since(start); elapsed < 350*time.Millisecond {
+		t.Errorf("expected bandwidth cap to slow the download, finished in %s", elapsed)
+	}
+}
+
+func TestSample(t *testing.T) {
+	data := make([]byte, 64*1024)
+	rand.Read(data)
+	server := newRangeServer(t, data)
+	defer server.Close()
+
+	opts := Options{PerSource: SourceLimits{MaxBytesPerSec: 1024 * 1024}}
+	result, err := Sample(context.Background(), server.URL+"/snapshot.tar.zst", 4, 300*time.Millisecond, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Connections != 4 {
+		t.Errorf("expected 4 connections, got %d", result.Connections)
+	}
+	// Reads keep going past the end of the archive until time is up, held
+	// to the bandwidth cap: 1 MB/s for 300ms plus a second of burst
+	if result.Bytes <= int64(len(data)) || result.Bytes > 1400*1024 {
+		t.Errorf("expected more than the %d-byte archive within the cap, read %d", len(data), result.Bytes)
+	}
+	if result.BytesPerSecond() <= 0 {
+		t.Errorf("expected a throughput, got %d", result.BytesPerSecond())
+	}
+
+	simple := newSimpleServer(t, data)
+	defer simple.Close()
+	if _, err := Sample(context.Background(), simple.URL+"/snapshot.tar.zst", 4, 100*time.Millisecond, Options{}); err == nil {
+		t.Error("expected an error for a source without Range support")
+	}
+}
+
+func TestDownloadDelta(t *testing.T) {
+	const bs = 4096
+	basisData := make([]byte, 8*bs)
+	rand.Read(basisData)
+	target := append([]byte{}, basisData...)
+	rand.Read(target[3*bs : 4*bs]) // one changed block
+	target = append(target, []byte("tail")...)
+
+	sig, err := delta.Sign(bytes.NewReader(target), bs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	sigJSON, _ := json.Marshal(sig)
+
+	var rangeBytes atomic.Int64
+	archive := newRangeServer(t, target)
+	defer archive.Close()
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.HasSuffix(r.URL.Path, delta.SignatureSuffix) {
+			w.Write(sigJSON)
+			return
+		}
+		rec := httptest.NewRecorder()
+		archive.Config.Handler.ServeHTTP(rec, r)
+		rangeBytes.Add(int64(rec.Body.Len()))
+		w.WriteHeader(rec.Code)
+		w.Write(rec.Body.Bytes())
+	}))
+	defer server.Close()
+
+	dir := t.TempDir()
+	basisPath := filepath.Join(dir, "incremental-snapshot-100-200-A.tar.zst")
+	os.WriteFile(basisPath, basisData, 0644)
+
+	result, err := DownloadDelta(context.Background(), server.URL+"/incremental-snapshot-100-300-B.tar.zst", dir, "incremental-snapshot-100-300-B.tar.zst", basisPath, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := os.ReadFile(result.FilePath)
+	if !bytes.Equal(got, target) {
+		t.Fatal("reconstructed archive differs from target")
+	}
+	if result.Bytes != bs+4 || rangeBytes.Load() != bs+4 {
+		t.Errorf("expected only the changed block and tail to be fetched, got %d (%d served)", result.Bytes, rangeBytes.Load())
+	}
+	if result.ReusedBytes != 7*bs {
+		t.Errorf("expected %d reused bytes, got %d", 7*bs, result.ReusedBytes)
+	}
+}
+
+func TestDownloadDelta_NoSignature(t *testing.T) {
+	server := httptest.NewServer(http.NotFoundHandler())
+	defer server.Close()
+
+	_, err := DownloadDelta(context.Background(), server.URL+"/incremental.tar.zst", t.TempDir(), "incremental.tar.zst", "/nonexistent", Options{})
+	if !errors.Is(err, delta.ErrNoSignature) {
+		t.Errorf("expected ErrNoSignature, got %v", err)
+	}
+}
+
+func TestTempPath_Deterministic(t *testing.T) {
+	path := Options{}.tempPath("/snapshots", "snapshot-100-Hash.tar.zst")
+	want := regexp.MustCompile(`^snapshot-100-Hash\.tar\.zst\.tmp\.[0-9a-f]{8}$`)
+	if !want.MatchString(filepath.Base(path)) {
+		t.Errorf("unexpected temp name %s", filepath.Base(path))
+	}
+	if again := (Options{}).tempPath("/snapshots", "snapshot-100-Hash.tar.zst"); again != path {
+		t.Errorf("expected the same temp path for the same archive, got %s and %s", path, again)
+	}
+	if other := (Options{}).tempPath("/snapshots", "snapshot-200-Hash.tar.zst"); filepath.Ext(other) == filepath.Ext(path) {
+		t.Errorf("expected different archives to get different temp suffixes, got %s and %s", path, other)
+	}
+}
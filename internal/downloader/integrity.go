@@ -0,0 +1,116 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+)
+
+// ErrRangeMismatch is returned when a source mis-serves Range requests:
+// the bytes it sends don't line up with the range asked for, or a spot
+// check of the finished file disagrees with it.
+var ErrRangeMismatch = errors.New("source mis-served a range request")
+
+// errRangeIgnored is returned for a ranged GET answered with the whole file
+// (200 instead of 206), so a parallel download can fall back to a single
+// connection.
+var errRangeIgnored = errors.New("source ignored the Range header")
+
+// spotCheckSize is the most bytes a single spot check compares.
+const spotCheckSize = 64 << 10
+
+// checkContentRange checks that a 206 response starts where the segment's
+// request did. A missing header is accepted, as some servers omit it.
+func checkContentRange(resp *http.Response, seg *segment) error {
+	header := resp.Header.Get("Content-Range")
+	if header == "" {
+		return nil
+	}
+	spec, ok := strings.CutPrefix(header, "bytes ")
+	if !ok {
+		return fmt.Errorf("%w: malformed Content-Range %q", ErrRangeMismatch, header)
+	}
+	start, _, _ := strings.Cut(spec, "-")
+	if n, err := strconv.ParseInt(start, 10, 64); err != nil || n != seg.Next {
+		return fmt.Errorf("%w: asked for bytes from %d, got Content-Range %q", ErrRangeMismatch, seg.Next, header)
+	}
+	return nil
+}
+
+// checkComplete confirms a parallel download wrote every segment in full,
+// and that the bytes it counted add up to the HEAD Content-Length.
+func checkComplete(segments []segment, written, size int64) error {
+	for i, seg := range segments {
+		if !seg.done() {
+			return fmt.Errorf("chunk %d ended %s short of its range", i, formatBytes(seg.End-seg.Next+1))
+		}
+	}
+	if written != size {
+		return fmt.Errorf("wrote %d bytes, expected Content-Length %d", written, size)
+	}
+	return nil
+}
+
+// spotCheckRanges re-requests n ranges of the file from the source and
+// compares them with what was written to path. The file is split into n
+// strata with one range at a random offset in each, so every part of it is
+// sampled.
+func spotCheckRanges(ctx context.Context, url, path string, size int64, n int, opts Options) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	n = int(min(int64(n), size))
+	stratum := size / int64(n)
+	length := min(int64(spotCheckSize), stratum)
+	for i := range n {
+		offset := int64(i)*stratum + rand.Int64N(stratum-length+1)
+		if err := spotCheckRange(ctx, url, f, offset, length, opts); err != nil {
+			return err
+		}
+	}
+	opts.logger().Debug(fmt.Sprintf("spot-checked %d ranges against the source", n), "url", url)
+	return nil
+}
+
+func spotCheckRange(ctx context.Context, url string, f *os.File, offset, length int64, opts Options) error {
+	if err := opts.pace(ctx); err != nil {
+		return err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Range", fmt.Sprintf("bytes=%d-%d", offset, offset+length-1))
+
+	resp, err := opts.client().Do(req)
+	if err != nil {
+		return fmt.Errorf("spot check: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusPartialContent {
+		return fmt.Errorf("spot check: expected 206, got %d", resp.StatusCode)
+	}
+
+	want := make([]byte, length)
+	if _, err := io.ReadFull(resp.Body, want); err != nil {
+		return fmt.Errorf("spot check: reading bytes %d-%d: %w", offset, offset+length-1, err)
+	}
+	got := make([]byte, length)
+	if _, err := f.ReadAt(got, offset); err != nil {
+		return fmt.Errorf("spot check: %w", err)
+	}
+	if !bytes.Equal(got, want) {
+		return fmt.Errorf("%w: bytes %d-%d differ from the source", ErrRangeMismatch, offset, offset+length-1)
+	}
+	return nil
+}
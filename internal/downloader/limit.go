@@ -0,0 +1,125 @@
+package downloader
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+// SourceLimits caps how hard a single source is pulled from, so a helpful
+// community node isn't saturated by our transfer.
+type SourceLimits struct {
+	MaxConnections int   // 0 = no cap beyond DownloadConnections
+	MaxBytesPerSec int64 // 0 = unlimited
+	// RequestInterval spaces the starts of successive requests within a
+	// download, so its connections don't all open at once (0 = none)
+	RequestInterval time.Duration
+}
+
+// connections returns the number of parallel connections to open against one
+// source given the requested count.
+func (l SourceLimits) connections(requested int) int {
+	if l.MaxConnections > 0 && requested > l.MaxConnections {
+		return l.MaxConnections
+	}
+	return requested
+}
+
+// rateLimiter is a token bucket shared by all connections to one source.
+// A nil *rateLimiter never blocks.
+type rateLimiter struct {
+	mu     sync.Mutex
+	rate   float64 // bytes per second
+	tokens float64
+	last   time.Time
+}
+
+func newRateLimiter(bytesPerSec int64) *rateLimiter {
+	if bytesPerSec <= 0 {
+		return nil
+	}
+	return &rateLimiter{rate: float64(bytesPerSec), last: time.Now()}
+}
+
+// wait accounts for n transferred bytes and sleeps long enough to keep the
+// average rate at or below the limit.
+func (l *rateLimiter) wait(ctx context.Context, n int) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	l.tokens += now.Sub(l.last).Seconds() * l.rate
+	if l.tokens > l.rate { // allow at most one second of burst
+		l.tokens = l.rate
+	}
+	l.last = now
+	l.tokens -= float64(n)
+	deficit := -l.tokens
+	l.mu.Unlock()
+
+	if deficit <= 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(time.Duration(deficit / l.rate * float64(time.Second)))
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// paced returns o with a pacer spacing its requests by
+// PerSource.RequestInterval. Each call starts a new pacer, so one covers a
+// single download.
+func (o Options) paced() Options {
+	if o.PerSource.RequestInterval > 0 {
+		o.pacer = &requestPacer{interval: o.PerSource.RequestInterval}
+	}
+	return o
+}
+
+// pace waits for the next request's turn, if requests are paced. Callers
+// wait before starting a stall watchdog, so the spacing isn't a stall.
+func (o Options) pace(ctx context.Context) error {
+	if o.pacer == nil {
+		return nil
+	}
+	return o.pacer.wait(ctx)
+}
+
+// requestPacer hands out request start times at least interval apart.
+type requestPacer struct {
+	mu       sync.Mutex
+	interval time.Duration
+	next     time.Time
+}
+
+// wait blocks until the caller's turn to start a request.
+func (p *requestPacer) wait(ctx context.Context) error {
+	p.mu.Lock()
+	now := time.Now()
+	start := now
+	if p.next.After(now) {
+		start = p.next
+	}
+	p.next = start.Add(p.interval)
+	p.mu.Unlock()
+
+	delay := start.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
@@ -0,0 +1,81 @@
+package downloader
+
+import (
+	"context"
+	"testing"
+	"time"
+)
+
+func TestSourceLimits_Connections(t *testing.T) {
+	tests := []struct {
+		limits    SourceLimits
+		requested int
+		expected  int
+	}{
+		{SourceLimits{}, 8, 8},
+		{SourceLimits{MaxConnections: 4}, 8, 4},
+		{SourceLimits{MaxConnections: 16}, 8, 8},
+	}
+	for _, tt := range tests {
+		if got := tt.limits.connections(tt.requested); got != tt.expected {
+			t.Errorf("connections(%d) with max %d = %d, want %d", tt.requested, tt.limits.MaxConnections, got, tt.expected)
+		}
+	}
+}
+
+func TestRateLimiter_NilNeverBlocks(t *testing.T) {
+	var l *rateLimiter
+	if err := l.wait(context.Background(), 1<<30); err != nil {
+		t.Fatal(err)
+	}
+	if newRateLimiter(0) != nil {
+		t.Error("expected nil limiter for unlimited rate")
+	}
+}
+
+func TestRateLimiter_Throttles(t *testing.T) {
+	l := newRateLimiter(100 * 1024) // 100 KB/s
+	start := time.Now()
+	for i := 0; i < 4; i++ {
+		if err := l.wait(context.Background(), 10*1024); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// 40 KB at 100 KB/s with an empty bucket takes ~400ms
+	if elapsed := time.Since(start); elapsed < 300*time.Millisecond {
+		t.Errorf("expected throttling, finished in %s", elapsed)
+	}
+}
+
+func TestRateLimiter_ContextCancel(t *testing.T) {
+	l := newRateLimiter(1024)
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := l.wait(ctx, 1024*1024); err == nil {
+		t.Error("expected context error")
+	}
+}
+
+func TestRequestPacer_SpacesStarts(t *testing.T) {
+	opts := Options{PerSource: SourceLimits{RequestInterval: 50 * time.Millisecond}}.paced()
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if err := opts.pace(context.Background()); err != nil {
+			t.Fatal(err)
+		}
+	}
+	// the first request starts immediately, the next two 50ms apart
+	if elapsed := time.Since(start); elapsed < 90*time.Millisecond {
+		t.Errorf("expected paced starts, finished in %s", elapsed)
+	}
+}
+
+func TestRequestPacer_UnpacedNeverBlocks(t *testing.T) {
+	opts := Options{}.paced()
+	if opts.pacer != nil {
+		t.Fatal("expected no pacer without a request interval")
+	}
+	if err := opts.pace(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+}
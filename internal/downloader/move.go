@@ -0,0 +1,99 @@
+package downloader
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// tempSuffix names the temp file a download of filename is written to. It
+// is derived from the file name alone, so a restarted process finds and
+// resumes the download a previous one paused or was killed during.
+func tempSuffix(filename string) string {
+	sum := sha256.Sum256([]byte(filename))
+	return ".tmp." + hex.EncodeToString(sum[:4])
+}
+
+// tempPath returns where a download into destDir is written until it is
+// complete: Options.TempDir, or next to the destination.
+func (o Options) tempPath(destDir, filename string) string {
+	if o.TempDir != "" {
+		destDir = o.TempDir
+	}
+	return filepath.Join(destDir, filename+tempSuffix(filename))
+}
+
+// moveIntoPlace renames a finished temp file to destPath. A temp file on
+// another filesystem is copied next to destPath first, so the snapshot
+// still appears there atomically.
+func (o Options) moveIntoPlace(tempPath, destPath string) error {
+	err := os.Rename(tempPath, destPath)
+	if err == nil || !isCrossDevice(err) {
+		return err
+	}
+
+	o.logger().Info("copying download to the snapshot directory across filesystems", "from", tempPath, "to", destPath)
+	staged := destPath + tempSuffix(filepath.Base(destPath))
+	if err := copyFile(tempPath, staged, o.Activity); err != nil {
+		os.Remove(staged)
+		return fmt.Errorf("copying across filesystems: %w", err)
+	}
+	if err := os.Rename(staged, destPath); err != nil {
+		os.Remove(staged)
+		return err
+	}
+	os.Remove(tempPath)
+	return nil
+}
+
+// copyFile copies src to a new file at dst and syncs it, so the rename that
+// publishes it can't expose a partly written file after a crash. activity,
+// if set, is called as the copy progresses.
+func copyFile(src, dst string, activity func()) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.OpenFile(dst, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0644)
+	if err != nil {
+		return err
+	}
+	var w io.Writer = out
+	if activity != nil {
+		w = &activityWriter{w: out, activity: activity}
+	}
+	if _, err := io.Copy(w, in); err != nil {
+		out.Close()
+		return err
+	}
+	if err := out.Sync(); err != nil {
+		out.Close()
+		return err
+	}
+	return out.Close()
+}
+
+// activityInterval is how many bytes activityWriter writes between calls.
+const activityInterval = 64 << 20
+
+// activityWriter calls activity every activityInterval bytes written.
+type activityWriter struct {
+	w        io.Writer
+	activity func()
+	pending  int64
+}
+
+func (a *activityWriter) Write(p []byte) (int, error) {
+	n, err := a.w.Write(p)
+	a.pending += int64(n)
+	if a.pending >= activityInterval {
+		a.pending = 0
+		a.activity()
+	}
+	return n, err
+}
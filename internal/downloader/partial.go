@@ -0,0 +1,90 @@
+package downloader
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+)
+
+// ErrPaused, as the cause of a download's cancelled context, keeps a
+// segmented download's partial file so a later Download of the same file
+// resumes it instead of starting over.
+var ErrPaused = errors.New("download paused")
+
+// segment is the byte range one connection of a segmented download fetches.
+type segment struct {
+	Next int64 `json:"next"` // first byte not yet written
+	End  int64 `json:"end"`  // inclusive
+}
+
+func (s segment) done() bool { return s.Next > s.End }
+
+// partialState records the segments of a paused download still missing from
+// its temp file, and the hashes of the chunks already written.
+type partialState struct {
+	Size     int64       `json:"size"`
+	Segments []segment   `json:"segments"`
+	Chunks   []chunkHash `json:"chunks,omitempty"`
+}
+
+// partialPath is where a paused download's state is kept. The suffix makes
+// the pruner treat it as a temp file.
+func partialPath(tempPath string) string { return tempPath + ".partial" }
+
+// splitSegments divides size bytes into n contiguous segments.
+func splitSegments(size int64, n int) []segment {
+	chunkSize := size / int64(n)
+	segments := make([]segment, n)
+	for i := range segments {
+		start := int64(i) * chunkSize
+		end := start + chunkSize - 1
+		if i == n-1 {
+			end = size - 1
+		}
+		segments[i] = segment{Next: start, End: end}
+	}
+	return segments
+}
+
+// loadPartial returns the missing segments of a paused download of size
+// bytes at tempPath, the hashes of the chunks written, and how many bytes are
+// already on disk. It returns nil segments, discarding any leftovers, when
+// there is nothing to resume.
+func loadPartial(tempPath string, size int64) ([]segment, []chunkHash, int64) {
+	data, err := os.ReadFile(partialPath(tempPath))
+	if err != nil {
+		return nil, nil, 0
+	}
+	os.Remove(partialPath(tempPath))
+
+	var state partialState
+	info, statErr := os.Stat(tempPath)
+	if json.Unmarshal(data, &state) != nil || state.Size != size || statErr != nil || info.Size() != size {
+		logger().Warn("discarding partial download that doesn't match the source", "file", tempPath)
+		os.Remove(tempPath)
+		return nil, nil, 0
+	}
+
+	remaining := size
+	for _, s := range state.Segments {
+		remaining -= s.End - s.Next + 1
+	}
+	return state.Segments, state.Chunks, remaining
+}
+
+// savePartial records the segments of a paused download still to fetch,
+// along with the hashes of the chunks written.
+func savePartial(tempPath string, size int64, segments []segment, chunks []chunkHash) error {
+	state := partialState{Size: size, Chunks: chunks}
+	for _, s := range segments {
+		if !s.done() {
+			state.Segments = append(state.Segments, s)
+		}
+	}
+	data, err := json.Marshal(state)
+	if err != nil {
+		return fmt.Errorf("marshalling partial download state: %w", err)
+	}
+	return os.WriteFile(partialPath(tempPath), data, 0644)
+}
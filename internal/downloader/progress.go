@@ -0,0 +1,167 @@
+package downloader
+
+import (
+	"fmt"
+	"io"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/bubbles/progress"
+)
+
+// progressTick is how often a running download reports its progress.
+const progressTick = 500 * time.Millisecond
+
+// Progress is a point-in-time view of a running download.
+type Progress struct {
+	File       string
+	Downloaded int64
+	Total      int64 // <= 0 when the server sent no Content-Length
+	Resumed    int64 // bytes already on disk when a paused download resumed
+	Elapsed    time.Duration
+}
+
+// Fraction returns the completed fraction in [0, 1], or 0 if the total is unknown.
+func (p Progress) Fraction() float64 {
+	if p.Total <= 0 {
+		return 0
+	}
+	return min(float64(p.Downloaded)/float64(p.Total), 1)
+}
+
+// SpeedBps returns the average speed so far in bytes per second.
+func (p Progress) SpeedBps() float64 {
+	if p.Elapsed <= 0 {
+		return 0
+	}
+	return float64(p.Downloaded-p.Resumed) / p.Elapsed.Seconds()
+}
+
+// ETA returns the estimated time remaining, or 0 if it can't be estimated.
+func (p Progress) ETA() time.Duration {
+	speed := p.SpeedBps()
+	if speed <= 0 || p.Total <= 0 {
+		return 0
+	}
+	return time.Duration(float64(p.Total-p.Downloaded)/speed) * time.Second
+}
+
+// ProgressReporter receives periodic progress of running downloads. Report is
+// called every progressTick while a download runs and Done once when it ends,
+// successfully or not. Reports for concurrent downloads are told apart by File.
+type ProgressReporter interface {
+	Report(p Progress)
+	Done(p Progress)
+}
+
+// SilentProgress discards all progress.
+type SilentProgress struct{}
+
+func (SilentProgress) Report(Progress) {}
+func (SilentProgress) Done(Progress)   {}
+
+// TTYProgress redraws a progress bar in place. Only use it when w is a
+// terminal; the carriage returns and ANSI sequences corrupt captured logs.
+type TTYProgress struct {
+	mu  sync.Mutex
+	w   io.Writer
+	bar progress.Model
+}
+
+// NewTTYProgress returns a reporter drawing a progress bar on w.
+func NewTTYProgress(w io.Writer) *TTYProgress {
+	return &TTYProgress{w: w, bar: progress.New(progress.WithDefaultGradient(), progress.WithWidth(40))}
+}
+
+func (t *TTYProgress) Report(p Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprintf(t.w, "\r  %s %s/s  eta %s  ",
+		t.bar.ViewAs(p.Fraction()),
+		formatBytes(int64(p.SpeedBps())),
+		p.ETA(),
+	)
+}
+
+func (t *TTYProgress) Done(Progress) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	fmt.Fprint(t.w, "\r\033[2K") // clear the progress bar line
+}
+
+// LogProgress logs a plain progress line per download at most once per
+// interval, which reads cleanly in journald and structured log pipelines.
+type LogProgress struct {
+	interval time.Duration
+
+	mu     sync.Mutex
+	logged map[string]time.Duration // file -> elapsed at the last line
+}
+
+// NewLogProgress returns a reporter logging progress every interval.
+func NewLogProgress(interval time.Duration) *LogProgress {
+	return &LogProgress{interval: interval, logged: map[string]time.Duration{}}
+}
+
+func (l *LogProgress) Report(p Progress) {
+	l.mu.Lock()
+	due := p.Elapsed-l.logged[p.File] >= l.interval
+	if due {
+		l.logged[p.File] = p.Elapsed
+	}
+	l.mu.Unlock()
+	if !due {
+		return
+	}
+
+	msg := fmt.Sprintf("downloading - %s at %s/s", formatBytes(p.Downloaded), formatBytes(int64(p.SpeedBps())))
+	if p.Total > 0 {
+		msg = fmt.Sprintf("downloading - %.0f%% (%s of %s) at %s/s", p.Fraction()*100, formatBytes(p.Downloaded), formatBytes(p.Total), formatBytes(int64(p.SpeedBps())))
+	}
+	logger().Info(msg, "file", p.File, "eta", p.ETA())
+}
+
+func (l *LogProgress) Done(p Progress) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	delete(l.logged, p.File)
+}
+
+// trackProgress reports the progress of downloaded to r every progressTick
+// until the returned stop function is called.
+func trackProgress(r ProgressReporter, file string, total int64, downloaded *atomic.Int64) (stop func()) {
+	if r == nil {
+		r = SilentProgress{}
+	}
+	start := time.Now()
+	resumed := downloaded.Load()
+	snapshot := func() Progress {
+		return Progress{File: file, Downloaded: downloaded.Load(), Total: total, Resumed: resumed, Elapsed: time.Since(start)}
+	}
+
+	done := make(chan struct{})
+	finished := make(chan struct{})
+	go func() {
+		defer close(finished)
+		ticker := time.NewTicker(progressTick)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C:
+				r.Report(snapshot())
+			case <-done:
+				r.Done(snapshot())
+				return
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			close(done)
+			<-finished
+		})
+	}
+}
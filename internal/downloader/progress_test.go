@@ -0,0 +1,109 @@
+package downloader
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+type recordingProgress struct {
+	mu      sync.Mutex
+	reports []Progress
+	done    []Progress
+}
+
+func (r *recordingProgress) Report(p Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.reports = append(r.reports, p)
+}
+
+func (r *recordingProgress) Done(p Progress) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	r.done = append(r.done, p)
+}
+
+func TestDownload_ReportsProgress(t *testing.T) {
+	data := make([]byte, 256*1024)
+	rand.Read(data)
+
+	server := newRangeServer(t, data)
+	defer server.Close()
+
+	rec := &recordingProgress{}
+	opts := Options{DownloadConnections: 2, DownloadTimeout: time.Minute, Progress: rec}
+	if _, err := Download(context.Background(), server.URL+"/snapshot.tar.zst", t.TempDir(), "snapshot-100-Hash.tar.zst", opts); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(rec.done) != 1 {
+		t.Fatalf("expected Done once, got %d", len(rec.done))
+	}
+	final := rec.done[0]
+	if final.File != "snapshot-100-Hash.tar.zst" || final.Downloaded != int64(len(data)) || final.Total != int64(len(data)) {
+		t.Errorf("unexpected final progress %+v", final)
+	}
+}
+
+func TestProgress_Estimates(t *testing.T) {
+	p := Progress{Downloaded: 25, Total: 100, Elapsed: 5 * time.Second}
+	if p.Fraction() != 0.25 {
+		t.Errorf("Fraction() = %v, want 0.25", p.Fraction())
+	}
+	if p.SpeedBps() != 5 {
+		t.Errorf("SpeedBps() = %v, want 5", p.SpeedBps())
+	}
+	if p.ETA() != 15*time.Second {
+		t.Errorf("ETA() = %v, want 15s", p.ETA())
+	}
+
+	unknown := Progress{Downloaded: 25, Total: -1, Elapsed: 5 * time.Second}
+	if unknown.Fraction() != 0 || unknown.ETA() != 0 {
+		t.Errorf("expected no estimate without a total, got %+v", unknown)
+	}
+}
+
+func TestTTYProgress(t *testing.T) {
+	var buf bytes.Buffer
+	r := NewTTYProgress(&buf)
+	r.Report(Progress{Downloaded: 50, Total: 100, Elapsed: time.Second})
+	if !strings.HasPrefix(buf.String(), "\r") || !strings.Contains(buf.String(), "eta") {
+		t.Errorf("unexpected progress bar %q", buf.String())
+	}
+	buf.Reset()
+	r.Done(Progress{})
+	if buf.String() != "\r\033[2K" {
+		t.Errorf("expected Done to clear the line, got %q", buf.String())
+	}
+}
+
+func TestLogProgress_Throttled(t *testing.T) {
+	var buf bytes.Buffer
+	prev := log.Default()
+	log.SetDefault(log.NewWithOptions(&buf, log.Options{Formatter: log.JSONFormatter}))
+	defer log.SetDefault(prev)
+
+	r := NewLogProgress(10 * time.Second)
+	for _, elapsed := range []time.Duration{1, 5, 10, 12, 20} {
+		r.Report(Progress{File: "a.tar.zst", Downloaded: int64(elapsed), Total: 20, Elapsed: elapsed * time.Second})
+	}
+	r.Done(Progress{File: "a.tar.zst"})
+
+	lines := strings.Split(strings.TrimSpace(buf.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 progress lines, got %d: %q", len(lines), buf.String())
+	}
+	if !strings.Contains(lines[0], "50%") || !strings.Contains(lines[1], "100%") {
+		t.Errorf("unexpected progress lines %q", lines)
+	}
+	if strings.Contains(buf.String(), "\033") || strings.Contains(buf.String(), "\r") {
+		t.Errorf("log progress must not contain control characters: %q", buf.String())
+	}
+}
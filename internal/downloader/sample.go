@@ -0,0 +1,88 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// SampleResult is the throughput Sample measured.
+type SampleResult struct {
+	Connections int
+	Bytes       int64
+	Duration    time.Duration
+}
+
+// BytesPerSecond is the aggregate throughput over all connections.
+func (r SampleResult) BytesPerSecond() int64 {
+	if r.Duration <= 0 {
+		return 0
+	}
+	return int64(float64(r.Bytes) / r.Duration.Seconds())
+}
+
+// countingWriter discards what is written, counting the bytes.
+type countingWriter struct{ n *atomic.Int64 }
+
+func (w countingWriter) Write(p []byte) (int, error) {
+	w.n.Add(int64(len(p)))
+	return len(p), nil
+}
+
+// Sample downloads from url over connections parallel Range requests for
+// duration, discarding the bytes, to measure the throughput a download from
+// it would reach. Each connection reads its own part of the archive, starting
+// over when it reaches the end. Per-source bandwidth and request interval
+// limits apply as they do to downloads.
+func Sample(ctx context.Context, url string, connections int, duration time.Duration, opts Options) (SampleResult, error) {
+	headReq, err := http.NewRequestWithContext(ctx, http.MethodHead, url, nil)
+	if err != nil {
+		return SampleResult{}, fmt.Errorf("creating HEAD request: %w", err)
+	}
+	headResp, err := opts.client().Do(headReq)
+	if err != nil {
+		return SampleResult{}, fmt.Errorf("HEAD request: %w", err)
+	}
+	headResp.Body.Close()
+	size := headResp.ContentLength
+	if headResp.Header.Get("Accept-Ranges") != "bytes" || size <= 0 {
+		return SampleResult{}, fmt.Errorf("%s doesn't serve Range requests", url)
+	}
+
+	opts = opts.paced()
+	limiter := newRateLimiter(opts.PerSource.MaxBytesPerSec)
+	sampleCtx, cancel := context.WithTimeout(ctx, duration)
+	defer cancel()
+
+	var read atomic.Int64
+	var errOnce sync.Once
+	var sampleErr error
+	var wg sync.WaitGroup
+	connections = int(min(int64(max(connections, 1)), size))
+	start := time.Now()
+	for _, seg := range splitSegments(size, connections) {
+		wg.Go(func() {
+			for sampleCtx.Err() == nil {
+				_, err := fetchRange(sampleCtx, url, seg.Next, seg.End, countingWriter{&read}, limiter, opts)
+				if err != nil && sampleCtx.Err() == nil {
+					errOnce.Do(func() { sampleErr = err })
+					cancel()
+				}
+			}
+		})
+	}
+	wg.Wait()
+	elapsed := time.Since(start)
+
+	if sampleErr != nil {
+		return SampleResult{}, sampleErr
+	}
+	if err := ctx.Err(); err != nil && !errors.Is(err, context.DeadlineExceeded) {
+		return SampleResult{}, err
+	}
+	return SampleResult{Connections: connections, Bytes: read.Load(), Duration: elapsed}, nil
+}
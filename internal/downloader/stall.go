@@ -0,0 +1,51 @@
+package downloader
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+// errStalled is the cancellation cause of a chunk request that went
+// Options.StallTimeout without receiving a byte.
+var errStalled = errors.New("no data received")
+
+// stallWatchdog cancels a request once it goes too long without progress.
+// A nil watchdog (stall timeout disabled) does nothing.
+type stallWatchdog struct {
+	timeout time.Duration
+	timer   *time.Timer
+}
+
+// watchStalls returns a context that is cancelled with errStalled when the
+// watchdog isn't fed for timeout, and the watchdog feeding it.
+func watchStalls(ctx context.Context, timeout time.Duration) (context.Context, *stallWatchdog, context.CancelFunc) {
+	if timeout <= 0 {
+		ctx, cancel := context.WithCancel(ctx)
+		return ctx, nil, cancel
+	}
+	ctx, cancel := context.WithCancelCause(ctx)
+	w := &stallWatchdog{
+		timeout: timeout,
+		timer:   time.AfterFunc(timeout, func() { cancel(errStalled) }),
+	}
+	return ctx, w, func() {
+		w.timer.Stop()
+		cancel(nil)
+	}
+}
+
+// feed restarts the inactivity timeout after progress.
+func (w *stallWatchdog) feed() {
+	if w != nil {
+		w.timer.Reset(w.timeout)
+	}
+}
+
+// hold suspends the timeout while the download waits on purpose, e.g. for
+// the per-source rate limit; feed resumes it.
+func (w *stallWatchdog) hold() {
+	if w != nil {
+		w.timer.Stop()
+	}
+}
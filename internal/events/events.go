@@ -0,0 +1,128 @@
+// Package events is a typed event bus the keeper publishes its progress on,
+// so notification, metrics or storage integrations can follow cycles without
+// changes to the keeper itself.
+package events
+
+import (
+	"slices"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("events") }
+
+// Event is one of CycleStarted, CandidateSelected, DownloadProgress or
+// CycleFinished.
+type Event interface {
+	// Name identifies the event type, e.g. "cycle_started"
+	Name() string
+}
+
+// CycleStarted is published when a keeper cycle starts.
+type CycleStarted struct {
+	At      time.Time
+	Cluster string
+}
+
+// CandidateSelected is published when a download from a candidate starts.
+type CandidateSelected struct {
+	At          time.Time
+	Source      string // the node's RPC URL
+	SnapshotURL string
+	Type        string // "full" or "incremental"
+	Slot        uint64
+}
+
+// DownloadProgress is published periodically while a download runs.
+type DownloadProgress struct {
+	At             time.Time
+	File           string
+	Downloaded     int64
+	Total          int64 // <= 0 when the source sent no Content-Length
+	BytesPerSecond int64
+}
+
+// CycleFinished is published when a keeper cycle ends.
+type CycleFinished struct {
+	At           time.Time
+	Duration     time.Duration
+	Result       string // success, skipped or failure
+	Reason       string
+	SnapshotSlot uint64 // set when a snapshot was downloaded
+	Source       string
+	Bytes        int64
+	Err          error
+}
+
+func (CycleStarted) Name() string      { return "cycle_started" }
+func (CandidateSelected) Name() string { return "candidate_selected" }
+func (DownloadProgress) Name() string  { return "download_progress" }
+func (CycleFinished) Name() string     { return "cycle_finished" }
+
+// Handler receives events. Handlers run synchronously on the publishing
+// goroutine, which may be a download's, so they must return quickly and be
+// safe for concurrent use; hand slow work off to a goroutine.
+type Handler func(Event)
+
+// Bus delivers published events to its subscribers. The zero value is ready
+// to use, and publishing on a nil Bus does nothing.
+type Bus struct {
+	mu       sync.RWMutex
+	nextID   int
+	handlers []subscription
+}
+
+type subscription struct {
+	id int
+	h  Handler
+}
+
+// Subscribe registers h for every event and returns a func that removes it.
+func (b *Bus) Subscribe(h Handler) (unsubscribe func()) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	b.nextID++
+	id := b.nextID
+	b.handlers = append(b.handlers, subscription{id: id, h: h})
+	return func() {
+		b.mu.Lock()
+		defer b.mu.Unlock()
+		b.handlers = slices.DeleteFunc(b.handlers, func(s subscription) bool { return s.id == id })
+	}
+}
+
+// On registers fn for events of type T only, e.g.
+//
+//	events.On(bus, func(e events.CycleFinished) { ... })
+func On[T Event](b *Bus, fn func(T)) (unsubscribe func()) {
+	return b.Subscribe(func(e Event) {
+		if t, ok := e.(T); ok {
+			fn(t)
+		}
+	})
+}
+
+// Publish delivers e to every subscriber in the order they subscribed. A
+// handler that panics is logged and doesn't keep e from the others.
+func (b *Bus) Publish(e Event) {
+	if b == nil {
+		return
+	}
+	b.mu.RLock()
+	handlers := slices.Clone(b.handlers)
+	b.mu.RUnlock()
+	for _, s := range handlers {
+		deliver(s.h, e)
+	}
+}
+
+func deliver(h Handler, e Event) {
+	defer func() {
+		if r := recover(); r != nil {
+			logger().Error("event handler panicked", "event", e.Name(), "panic", r)
+		}
+	}()
+	h(e)
+}
@@ -0,0 +1,64 @@
+package events
+
+import (
+	"errors"
+	"slices"
+	"testing"
+)
+
+func TestBus(t *testing.T) {
+	var b Bus
+	var all []string
+	var finished []CycleFinished
+	unsubscribe := b.Subscribe(func(e Event) { all = append(all, e.Name()) })
+	On(&b, func(e CycleFinished) { finished = append(finished, e) })
+	b.Subscribe(func(Event) { panic("broken plugin") })
+
+	b.Publish(CycleStarted{Cluster: "testnet"})
+	b.Publish(CycleFinished{Result: "failure", Err: errors.New("boom")})
+
+	if !slices.Equal(all, []string{"cycle_started", "cycle_finished"}) {
+		t.Errorf("expected every event, got %v", all)
+	}
+	if len(finished) != 1 || finished[0].Result != "failure" {
+		t.Errorf("expected only cycle_finished for the typed handler, got %v", finished)
+	}
+
+	unsubscribe()
+	b.Publish(DownloadProgress{})
+	if len(all) != 2 {
+		t.Errorf("expected no events after unsubscribing, got %v", all)
+	}
+
+	var nilBus *Bus
+	nilBus.Publish(CycleStarted{}) // must not panic
+}
+
+func TestRegister(t *testing.T) {
+	t.Cleanup(func() {
+		pluginsMu.Lock()
+		plugins = map[string]Plugin{}
+		pluginsMu.Unlock()
+	})
+
+	var attached []string
+	Register("b-plugin", func(*Bus) { attached = append(attached, "b-plugin") })
+	Register("a-plugin", func(b *Bus) {
+		attached = append(attached, "a-plugin")
+		On(b, func(CycleStarted) { attached = append(attached, "a-plugin saw cycle_started") })
+	})
+
+	var b Bus
+	Attach(&b)
+	b.Publish(CycleStarted{})
+	if want := []string{"a-plugin", "b-plugin", "a-plugin saw cycle_started"}; !slices.Equal(attached, want) {
+		t.Errorf("attached = %v, want %v", attached, want)
+	}
+
+	defer func() {
+		if recover() == nil {
+			t.Error("expected registering a name twice to panic")
+		}
+	}()
+	Register("a-plugin", func(*Bus) {})
+}
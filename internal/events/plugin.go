@@ -0,0 +1,55 @@
+package events
+
+import (
+	"fmt"
+	"slices"
+	"sync"
+)
+
+// Plugin attaches an integration to a keeper's bus, typically by subscribing
+// handlers to it.
+type Plugin func(b *Bus)
+
+var (
+	pluginsMu sync.Mutex
+	plugins   = map[string]Plugin{}
+)
+
+// Register makes p attach to the bus of every keeper created afterwards. It
+// is meant to be called from the init function of a package compiled into a
+// custom build, as database/sql drivers register themselves. Registering the
+// same name twice panics.
+func Register(name string, p Plugin) {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	if p == nil {
+		panic("events: Register plugin is nil")
+	}
+	if _, dup := plugins[name]; dup {
+		panic(fmt.Sprintf("events: Register called twice for plugin %q", name))
+	}
+	plugins[name] = p
+}
+
+// Plugins returns the names of the registered plugins, sorted.
+func Plugins() []string {
+	pluginsMu.Lock()
+	defer pluginsMu.Unlock()
+	names := make([]string, 0, len(plugins))
+	for name := range plugins {
+		names = append(names, name)
+	}
+	slices.Sort(names)
+	return names
+}
+
+// Attach attaches every registered plugin to b, in name order.
+func Attach(b *Bus) {
+	for _, name := range Plugins() {
+		pluginsMu.Lock()
+		p := plugins[name]
+		pluginsMu.Unlock()
+		logger().Debug("attaching plugin", "name", name)
+		p(b)
+	}
+}
@@ -0,0 +1,95 @@
+package geoip
+
+import (
+	"fmt"
+	"net/netip"
+)
+
+// Location is where an IP address is, as far as the databases know. Fields
+// the databases don't cover are left empty.
+type Location struct {
+	Continent string // two-letter continent code, e.g. "EU"
+	Country   string // ISO 3166-1 alpha-2 country code, e.g. "DE"
+	ASN       uint32 // autonomous system number
+	ASOrg     string // autonomous system organisation
+}
+
+func (l Location) String() string {
+	s := "-"
+	if l.Country != "" {
+		s = l.Continent + "/" + l.Country
+	}
+	if l.ASN != 0 {
+		s += fmt.Sprintf(" AS%d", l.ASN)
+	}
+	return s
+}
+
+// Continents are the continent codes MaxMind databases use.
+var Continents = []string{"AF", "AN", "AS", "EU", "NA", "OC", "SA"}
+
+// Resolver locates IP addresses with local MaxMind databases: a GeoLite2 or
+// GeoIP2 Country or City database, and an ASN database. Either may be
+// missing.
+type Resolver struct {
+	country *DB
+	asn     *DB
+}
+
+// Open loads the databases at the given paths; an empty path skips one.
+func Open(countryPath, asnPath string) (*Resolver, error) {
+	r := &Resolver{}
+	var err error
+	if countryPath != "" {
+		if r.country, err = OpenDB(countryPath); err != nil {
+			return nil, err
+		}
+	}
+	if asnPath != "" {
+		if r.asn, err = OpenDB(asnPath); err != nil {
+			return nil, err
+		}
+	}
+	return r, nil
+}
+
+// Locate returns what the databases know about ip. A nil Resolver knows
+// nothing.
+func (r *Resolver) Locate(ip netip.Addr) Location {
+	var loc Location
+	if r == nil {
+		return loc
+	}
+	if r.country != nil {
+		if rec, ok, err := r.country.Lookup(ip); err == nil && ok {
+			loc.Continent = field(rec, "continent", "code")
+			loc.Country = field(rec, "country", "iso_code")
+			if loc.Country == "" {
+				// Anycast and some hosting ranges only carry a registered country
+				loc.Country = field(rec, "registered_country", "iso_code")
+			}
+		}
+	}
+	if r.asn != nil {
+		if rec, ok, err := r.asn.Lookup(ip); err == nil && ok {
+			if m, ok := rec.(map[string]any); ok {
+				loc.ASN = uint32(toUint(m["autonomous_system_number"]))
+				loc.ASOrg, _ = m["autonomous_system_organization"].(string)
+			}
+		}
+	}
+	return loc
+}
+
+// field returns the string at path in a decoded record, or "".
+func field(rec any, path ...string) string {
+	for _, key := range path {
+		m, ok := rec.(map[string]any)
+		if !ok {
+			return ""
+		}
+		rec = m[key]
+	}
+	s, _ := rec.(string)
+	return s
+}
@@ -0,0 +1,263 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net/netip"
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+)
+
+// mmdbWriter builds small MaxMind DB files for tests.
+type mmdbWriter struct {
+	ipVersion  int
+	recordSize int
+	nodes      [][2]int // >= 0 is a node, -1 is empty, < -1 is data offset -(off+2)
+	data       []byte
+}
+
+func newMMDBWriter(ipVersion, recordSize int) *mmdbWriter {
+	return &mmdbWriter{ipVersion: ipVersion, recordSize: recordSize, nodes: [][2]int{{-1, -1}}}
+}
+
+// insert maps prefix to an encoded record. Prefixes must not overlap.
+func (w *mmdbWriter) insert(prefix netip.Prefix, record []byte) {
+	off := len(w.data)
+	w.data = append(w.data, record...)
+
+	addr, bits := prefix.Addr(), prefix.Bits()
+	var b []byte
+	switch {
+	case w.ipVersion == 6 && addr.Is4():
+		// IPv4 lives under ::/96 in an IPv6 tree
+		a := addr.As4()
+		b = append(make([]byte, 12), a[:]...)
+		bits += 96
+	case w.ipVersion == 6:
+		a := addr.As16()
+		b = a[:]
+	default:
+		a := addr.As4()
+		b = a[:]
+	}
+	node := 0
+	for i := range bits {
+		bit := int(b[i/8]>>(7-i%8)) & 1
+		if i == bits-1 {
+			w.nodes[node][bit] = -(off + 2)
+			return
+		}
+		if w.nodes[node][bit] < 0 {
+			w.nodes = append(w.nodes, [2]int{-1, -1})
+			w.nodes[node][bit] = len(w.nodes) - 1
+		}
+		node = w.nodes[node][bit]
+	}
+}
+
+func (w *mmdbWriter) bytes() []byte {
+	n := len(w.nodes)
+	resolve := func(r int) uint32 {
+		switch {
+		case r >= 0:
+			return uint32(r)
+		case r == -1:
+			return uint32(n)
+		default:
+			return uint32(n + dataSeparator + (-r - 2))
+		}
+	}
+	var out []byte
+	for _, node := range w.nodes {
+		l, r := resolve(node[0]), resolve(node[1])
+		switch w.recordSize {
+		case 24:
+			out = append(out, byte(l>>16), byte(l>>8), byte(l), byte(r>>16), byte(r>>8), byte(r))
+		case 28:
+			out = append(out, byte(l>>16), byte(l>>8), byte(l), byte(l>>24<<4)|byte(r>>24&0x0F), byte(r>>16), byte(r>>8), byte(r))
+		default:
+			out = binary.BigEndian.AppendUint32(out, l)
+			out = binary.BigEndian.AppendUint32(out, r)
+		}
+	}
+	out = append(out, make([]byte, dataSeparator)...)
+	out = append(out, w.data...)
+	out = append(out, metadataMarker...)
+	out = append(out, encode(map[string]any{
+		"node_count":    uint32(n),
+		"record_size":   uint16(w.recordSize),
+		"ip_version":    uint16(w.ipVersion),
+		"database_type": "Test",
+	})...)
+	return out
+}
+
+func (w *mmdbWriter) write(t *testing.T) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "test.mmdb")
+	if err := os.WriteFile(path, w.bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func control(typ int, size int) []byte {
+	var extra []byte
+	if size >= 29 {
+		extra = []byte{byte(size - 29)}
+		size = 29
+	}
+	b := []byte{byte(typ<<5 | size)}
+	if typ > 7 {
+		b = []byte{byte(size), byte(typ - 7)}
+	}
+	return append(b, extra...)
+}
+
+// pointer encodes a pointer to offset in the data section (< 2048).
+func pointer(offset int) []byte {
+	return []byte{byte(typePointer<<5 | offset>>8), byte(offset)}
+}
+
+func encode(v any) []byte {
+	switch v := v.(type) {
+	case string:
+		return append(control(typeString, len(v)), v...)
+	case uint16:
+		return append(control(typeUint16, 2), byte(v>>8), byte(v))
+	case uint32:
+		return binary.BigEndian.AppendUint32(control(typeUint32, 4), v)
+	case []byte: // already encoded
+		return v
+	case map[string]any:
+		keys := make([]string, 0, len(v))
+		for k := range v {
+			keys = append(keys, k)
+		}
+		sort.Strings(keys)
+		out := control(typeMap, len(v))
+		for _, k := range keys {
+			out = append(out, encode(k)...)
+			out = append(out, encode(v[k])...)
+		}
+		return out
+	}
+	panic(fmt.Sprintf("can't encode %T", v))
+}
+
+func TestLookup(t *testing.T) {
+	for _, ipVersion := range []int{4, 6} {
+		for _, recordSize := range []int{24, 28, 32} {
+			t.Run(fmt.Sprintf("ipv%d/%d-bit", ipVersion, recordSize), func(t *testing.T) {
+				w := newMMDBWriter(ipVersion, recordSize)
+				// A shared value reached through a pointer, as real databases do
+				europe := len(w.data)
+				w.data = append(w.data, encode(map[string]any{"code": "EU"})...)
+				w.insert(netip.MustParsePrefix("10.0.0.0/8"), encode(map[string]any{
+					"continent": pointer(europe),
+					"country":   map[string]any{"iso_code": "DE"},
+				}))
+				w.insert(netip.MustParsePrefix("192.168.1.0/24"), encode(map[string]any{
+					"continent":          map[string]any{"code": "NA"},
+					"registered_country": map[string]any{"iso_code": "US"},
+				}))
+				if ipVersion == 6 {
+					w.insert(netip.MustParsePrefix("2001:db8::/32"), encode(map[string]any{
+						"continent": pointer(europe),
+						"country":   map[string]any{"iso_code": "NL"},
+					}))
+				}
+				db, err := OpenDB(w.write(t))
+				if err != nil {
+					t.Fatal(err)
+				}
+				r := &Resolver{country: db}
+
+				tests := []struct {
+					ip   string
+					want Location
+				}{
+					{"10.1.2.3", Location{Continent: "EU", Country: "DE"}},
+					{"::ffff:10.1.2.3", Location{Continent: "EU", Country: "DE"}},
+					{"192.168.1.200", Location{Continent: "NA", Country: "US"}},
+					{"192.168.2.1", Location{}},
+					{"8.8.8.8", Location{}},
+				}
+				if ipVersion == 6 {
+					tests = append(tests, struct {
+						ip   string
+						want Location
+					}{"2001:db8::1", Location{Continent: "EU", Country: "NL"}})
+				}
+				for _, tt := range tests {
+					if got := r.Locate(netip.MustParseAddr(tt.ip)); got != tt.want {
+						t.Errorf("Locate(%s) = %+v, want %+v", tt.ip, got, tt.want)
+					}
+				}
+			})
+		}
+	}
+}
+
+func TestResolver_ASN(t *testing.T) {
+	w := newMMDBWriter(6, 24)
+	w.insert(netip.MustParsePrefix("10.0.0.0/8"), encode(map[string]any{
+		"autonomous_system_number":       uint32(24940),
+		"autonomous_system_organization": "Hetzner Online GmbH",
+	}))
+	r, err := Open("", w.write(t))
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := r.Locate(netip.MustParseAddr("10.0.0.1"))
+	if want := (Location{ASN: 24940, ASOrg: "Hetzner Online GmbH"}); got != want {
+		t.Errorf("got %+v, want %+v", got, want)
+	}
+	if got.String() != "- AS24940" {
+		t.Errorf("String() = %q", got.String())
+	}
+}
+
+func TestOpenDB_Corrupt(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "bad.mmdb")
+	os.WriteFile(path, []byte("not a database"), 0644)
+	if _, err := OpenDB(path); err == nil {
+		t.Error("expected an error for a file without metadata")
+	}
+
+	w := newMMDBWriter(4, 24)
+	w.insert(netip.MustParsePrefix("10.0.0.0/8"), encode("x"))
+	truncated := w.bytes()
+	i := bytes.LastIndex(truncated, metadataMarker)
+	os.WriteFile(path, append(truncated[:3], truncated[i:]...), 0644)
+	if _, err := OpenDB(path); err == nil {
+		t.Error("expected an error for a search tree larger than the file")
+	}
+}
+
+func TestLookup_CorruptData(t *testing.T) {
+	for _, tt := range []struct {
+		name   string
+		record []byte
+	}{
+		{"pointer to itself", pointer(0)},
+		{"map larger than the data", control(typeMap, 200)},
+		{"array larger than the data", control(typeArray, 200)},
+		{"string past the end", control(typeString, 50)},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			w := newMMDBWriter(4, 24)
+			w.insert(netip.MustParsePrefix("10.0.0.0/8"), tt.record)
+			db, err := OpenDB(w.write(t))
+			if err != nil {
+				t.Fatal(err)
+			}
+			if _, _, err := db.Lookup(netip.MustParseAddr("10.1.2.3")); err == nil {
+				t.Error("expected an error decoding the record")
+			}
+		})
+	}
+}
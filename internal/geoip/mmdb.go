@@ -0,0 +1,363 @@
+package geoip
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math"
+	"net/netip"
+	"os"
+)
+
+// metadataMarker precedes the metadata map at the end of a MaxMind DB file.
+var metadataMarker = []byte("\xAB\xCD\xEFMaxMind.com")
+
+// dataSeparator is the gap of zero bytes between the search tree and the
+// data section.
+const dataSeparator = 16
+
+// maxDecodeDepth bounds how deeply maps, arrays and pointers may nest, so a
+// pointer cycle or runaway nesting in a corrupt file fails rather than
+// overflowing the stack.
+const maxDecodeDepth = 32
+
+// errCorrupt reports a database that doesn't follow the MaxMind DB format.
+var errCorrupt = errors.New("corrupt MaxMind database")
+
+// Data types of the MaxMind DB format.
+const (
+	typeExtended = iota
+	typePointer
+	typeString
+	typeDouble
+	typeBytes
+	typeUint16
+	typeUint32
+	typeMap
+	typeInt32
+	typeUint64
+	typeUint128
+	typeArray
+	typeContainer
+	typeEndMarker
+	typeBool
+	typeFloat
+)
+
+// DB is a MaxMind DB (.mmdb) file read into memory: a binary search tree
+// over IP address bits whose leaves point into a section of typed data.
+type DB struct {
+	buf        []byte
+	data       []byte // the data section
+	nodeCount  uint
+	recordSize uint
+	ipVersion  uint
+	ipv4Start  uint // node reached after the 96 zero bits of ::/96
+	Type       string
+}
+
+// OpenDB reads the MaxMind DB at path.
+func OpenDB(path string) (*DB, error) {
+	buf, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	db, err := newDB(buf)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return db, nil
+}
+
+func newDB(buf []byte) (*DB, error) {
+	i := bytes.LastIndex(buf, metadataMarker)
+	if i < 0 {
+		return nil, fmt.Errorf("%w: no metadata", errCorrupt)
+	}
+	meta, _, err := decoder{buf: buf[i+len(metadataMarker):]}.decode(0)
+	if err != nil {
+		return nil, fmt.Errorf("%w: metadata: %w", errCorrupt, err)
+	}
+	m, ok := meta.(map[string]any)
+	if !ok {
+		return nil, fmt.Errorf("%w: metadata is not a map", errCorrupt)
+	}
+	db := &DB{
+		buf:        buf,
+		nodeCount:  uint(toUint(m["node_count"])),
+		recordSize: uint(toUint(m["record_size"])),
+		ipVersion:  uint(toUint(m["ip_version"])),
+	}
+	db.Type, _ = m["database_type"].(string)
+	switch db.recordSize {
+	case 24, 28, 32:
+	default:
+		return nil, fmt.Errorf("%w: unsupported record size %d", errCorrupt, db.recordSize)
+	}
+	if db.nodeCount > uint(i) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", errCorrupt)
+	}
+	treeSize := db.nodeCount * db.recordSize / 4
+	if treeSize+dataSeparator > uint(i) {
+		return nil, fmt.Errorf("%w: search tree larger than the file", errCorrupt)
+	}
+	db.data = buf[treeSize+dataSeparator : i]
+
+	if db.ipVersion == 6 {
+		node := uint(0)
+		for range 96 {
+			if node >= db.nodeCount {
+				break
+			}
+			if node, err = db.record(node, 0); err != nil {
+				return nil, err
+			}
+		}
+		db.ipv4Start = node
+	}
+	return db, nil
+}
+
+// Lookup decodes the record for ip. ok is false when the database has none.
+func (db *DB) Lookup(ip netip.Addr) (record any, ok bool, err error) {
+	ip = ip.Unmap()
+	var bits []byte
+	node := uint(0)
+	switch {
+	case ip.Is4():
+		b := ip.As4()
+		bits = b[:]
+		if db.ipVersion == 6 {
+			node = db.ipv4Start
+		}
+	case db.ipVersion == 6:
+		b := ip.As16()
+		bits = b[:]
+	default:
+		return nil, false, nil // an IPv6 address in an IPv4-only database
+	}
+
+	for i := 0; i < len(bits)*8 && node < db.nodeCount; i++ {
+		bit := uint(bits[i/8]>>(7-i%8)) & 1
+		if node, err = db.record(node, bit); err != nil {
+			return nil, false, err
+		}
+	}
+	switch {
+	case node == db.nodeCount:
+		return nil, false, nil
+	case node < db.nodeCount:
+		return nil, false, fmt.Errorf("%w: search tree deeper than the address", errCorrupt)
+	}
+	offset := node - db.nodeCount - dataSeparator
+	if offset >= uint(len(db.data)) {
+		return nil, false, fmt.Errorf("%w: data pointer out of range", errCorrupt)
+	}
+	record, _, err = decoder{buf: db.data}.decode(offset)
+	return record, err == nil, err
+}
+
+// record returns the left (bit 0) or right (bit 1) record of a tree node.
+func (db *DB) record(node, bit uint) (uint, error) {
+	size := db.recordSize / 4 // bytes per node
+	off := node * size
+	if off+size > uint(len(db.buf)) {
+		return 0, fmt.Errorf("%w: node %d out of range", errCorrupt, node)
+	}
+	b := db.buf[off : off+size]
+	switch db.recordSize {
+	case 24:
+		b = b[bit*3:]
+		return uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+	case 28:
+		if bit == 0 {
+			return uint(b[3]&0xF0)<<20 | uint(b[0])<<16 | uint(b[1])<<8 | uint(b[2]), nil
+		}
+		return uint(b[3]&0x0F)<<24 | uint(b[4])<<16 | uint(b[5])<<8 | uint(b[6]), nil
+	default:
+		return uint(binary.BigEndian.Uint32(b[bit*4:])), nil
+	}
+}
+
+// decoder decodes values from a data section; pointers are offsets into buf.
+type decoder struct {
+	buf []byte
+}
+
+// decode returns the value at offset and the offset just past it.
+func (d decoder) decode(offset uint) (any, uint, error) {
+	return d.decodeAt(offset, 0)
+}
+
+// decodeAt is decode for a value nested depth maps, arrays and pointers
+// deep.
+func (d decoder) decodeAt(offset uint, depth int) (any, uint, error) {
+	if depth > maxDecodeDepth {
+		return nil, 0, fmt.Errorf("%w: data nested more than %d deep", errCorrupt, maxDecodeDepth)
+	}
+	typ, size, offset, err := d.control(offset)
+	if err != nil {
+		return nil, 0, err
+	}
+	if typ == typePointer {
+		v, _, err := d.decodeAt(size, depth+1)
+		return v, offset, err
+	}
+	return d.value(typ, size, offset, depth)
+}
+
+// control reads a field's control byte, returning its type and size, or,
+// for a pointer, the offset it points to.
+func (d decoder) control(offset uint) (typ int, size uint, next uint, err error) {
+	b, err := d.take(offset, 1)
+	if err != nil {
+		return 0, 0, 0, err
+	}
+	ctrl := b[0]
+	offset++
+	typ = int(ctrl >> 5)
+	if typ == typePointer {
+		n := uint(ctrl>>3&0x3) + 1
+		p, err := d.take(offset, n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		v := uint(ctrl & 0x7)
+		if n == 4 {
+			v = 0
+		}
+		for _, c := range p {
+			v = v<<8 | uint(c)
+		}
+		v += [...]uint{0, 2048, 526336, 0}[n-1]
+		return typePointer, v, offset + n, nil
+	}
+	if typ == typeExtended {
+		ext, err := d.take(offset, 1)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		typ = 7 + int(ext[0])
+		offset++
+	}
+	size = uint(ctrl & 0x1F)
+	if size >= 29 {
+		n := size - 28
+		s, err := d.take(offset, n)
+		if err != nil {
+			return 0, 0, 0, err
+		}
+		v := uint(0)
+		for _, c := range s {
+			v = v<<8 | uint(c)
+		}
+		size = [...]uint{29, 285, 65821}[n-1] + v
+		offset += n
+	}
+	return typ, size, offset, nil
+}
+
+func (d decoder) value(typ int, size, offset uint, depth int) (any, uint, error) {
+	switch typ {
+	case typeMap:
+		// Every key and value takes at least a byte
+		if size > d.remaining(offset)/2 {
+			return nil, 0, fmt.Errorf("%w: map of %d entries runs past the end of the data", errCorrupt, size)
+		}
+		m := make(map[string]any, size)
+		for range size {
+			k, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			key, ok := k.(string)
+			if !ok {
+				return nil, 0, fmt.Errorf("%w: map key is %T", errCorrupt, k)
+			}
+			v, next, err := d.decodeAt(next, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			m[key] = v
+			offset = next
+		}
+		return m, offset, nil
+	case typeArray:
+		if size > d.remaining(offset) {
+			return nil, 0, fmt.Errorf("%w: array of %d elements runs past the end of the data", errCorrupt, size)
+		}
+		a := make([]any, 0, size)
+		for range size {
+			v, next, err := d.decodeAt(offset, depth+1)
+			if err != nil {
+				return nil, 0, err
+			}
+			a = append(a, v)
+			offset = next
+		}
+		return a, offset, nil
+	case typeBool:
+		return size != 0, offset, nil
+	case typeContainer, typeEndMarker:
+		return nil, offset, nil
+	}
+
+	b, err := d.take(offset, size)
+	if err != nil {
+		return nil, 0, err
+	}
+	offset += size
+	switch typ {
+	case typeString:
+		return string(b), offset, nil
+	case typeBytes:
+		return bytes.Clone(b), offset, nil
+	case typeDouble:
+		if size != 8 {
+			return nil, 0, fmt.Errorf("%w: double of %d bytes", errCorrupt, size)
+		}
+		return math.Float64frombits(binary.BigEndian.Uint64(b)), offset, nil
+	case typeFloat:
+		if size != 4 {
+			return nil, 0, fmt.Errorf("%w: float of %d bytes", errCorrupt, size)
+		}
+		return math.Float32frombits(binary.BigEndian.Uint32(b)), offset, nil
+	case typeUint16, typeUint32, typeUint64:
+		v := uint64(0)
+		for _, c := range b {
+			v = v<<8 | uint64(c)
+		}
+		return v, offset, nil
+	case typeInt32:
+		v := uint32(0)
+		for _, c := range b {
+			v = v<<8 | uint32(c)
+		}
+		return int64(int32(v)), offset, nil
+	case typeUint128:
+		return bytes.Clone(b), offset, nil
+	}
+	return nil, 0, fmt.Errorf("%w: unknown data type %d", errCorrupt, typ)
+}
+
+func (d decoder) take(offset, n uint) ([]byte, error) {
+	if n > d.remaining(offset) {
+		return nil, fmt.Errorf("%w: field runs past the end of the data", errCorrupt)
+	}
+	return d.buf[offset : offset+n], nil
+}
+
+// remaining returns how many bytes of data follow offset.
+func (d decoder) remaining(offset uint) uint {
+	if offset >= uint(len(d.buf)) {
+		return 0
+	}
+	return uint(len(d.buf)) - offset
+}
+
+// toUint returns an unsigned integer field, or 0 for anything else.
+func toUint(v any) uint64 {
+	n, _ := v.(uint64)
+	return n
+}
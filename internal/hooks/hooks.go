@@ -3,8 +3,11 @@ package hooks
 import (
 	"bytes"
 	"context"
+	"encoding/json"
 	"fmt"
+	"net/http"
 	"os/exec"
+	"strings"
 	"text/template"
 
 	"github.com/charmbracelet/log"
@@ -15,42 +18,83 @@ import (
 func logger() *log.Logger { return log.Default().WithPrefix("hooks") }
 
 // TemplateData is the data available to hook command templates.
+// With stdin_json, it is also written to the hook's stdin as a JSON object.
 type TemplateData struct {
-	SnapshotSlot    string
-	SnapshotType    string // "full" or "incremental"
-	SourceNode      string
-	DownloadTimeSec int
-	DownloadSizeMB  int
-	SnapshotPath    string
-	ClusterName     string
-	ValidatorRole   string // "passive" or "unknown"
-	Error           string // only populated for on_failure hooks
+	Event            string `json:"event"` // success, failure, skip, incident_enter or incident_exit
+	SnapshotSlot     string `json:"snapshot_slot"`
+	SnapshotType     string `json:"snapshot_type"` // "full" or "incremental"
+	SourceNode       string `json:"source_node"`
+	DownloadTimeSec  int    `json:"download_time_sec"`
+	DownloadSizeMB   int    `json:"download_size_mb"`
+	SnapshotPath     string `json:"snapshot_path"`
+	ClusterName      string `json:"cluster_name"`
+	ValidatorRole    string `json:"validator_role"`     // "passive" or "unknown"
+	Error            string `json:"error"`              // only populated for on_failure hooks
+	ErrorKind        string `json:"error_kind"`         // on_failure only: no-candidates, all-downloads-failed, rpc-unavailable, disk-full, verification-failed or empty
+	IncidentReason   string `json:"incident_reason"`    // only populated for on_incident_enter/on_incident_exit hooks
+	SkipReason       string `json:"skip_reason"`        // only populated for on_skip hooks
+	LocalSlotsBehind string `json:"local_slots_behind"` // slots the local validator trails the cluster by, empty if unknown
+	Identity         string `json:"identity"`           // the validator's identity, the matched active one when skipped as active; empty if unknown
+	Validator        string `json:"validator"`          // the validators entry's name, when the config runs several; empty otherwise
+}
+
+// logger tags the package's log lines with the validator, when set.
+func (d TemplateData) logger() *log.Logger {
+	if d.Validator != "" {
+		return logger().With("validator", d.Validator)
+	}
+	return logger()
+}
+
+// Options configures how hooks run.
+type Options struct {
+	// Transport carries slack and telegram hooks' posts (nil =
+	// http.DefaultTransport)
+	Transport http.RoundTripper
 }
 
 // RunHooks executes a list of hook commands with the given template data.
 func RunHooks(ctx context.Context, hooks []config.HookCommand, data TemplateData) error {
+	return RunHooksWithOptions(ctx, hooks, data, Options{})
+}
+
+// RunHooksWithOptions is RunHooks, with opts.
+func RunHooksWithOptions(ctx context.Context, hooks []config.HookCommand, data TemplateData, opts Options) error {
 	for i, hook := range hooks {
 		if hook.Disabled {
-			logger().Debug("hook disabled, skipping", "name", hook.Name)
+			data.logger().Debug("hook disabled, skipping", "name", hook.Name)
 			continue
 		}
 
-		logger().Info("running hook", "name", hook.Name, "index", i)
+		run, err := conditionMet(hook.When, data)
+		if err == nil && !run {
+			data.logger().Debug("hook condition not met, skipping", "name", hook.Name, "when", hook.When)
+			continue
+		}
 
-		if err := runHook(ctx, hook, data); err != nil {
+		if err == nil {
+			data.logger().Info("running hook", "name", hook.Name, "index", i)
+			err = runHook(ctx, hook, data, opts)
+		}
+		if err != nil {
 			if hook.AllowFailure {
-				logger().Warn("hook failed (allow_failure=true)", "name", hook.Name, "error", err)
+				data.logger().Warn("hook failed (allow_failure=true)", "name", hook.Name, "error", err)
 				continue
 			}
 			return fmt.Errorf("hook %q failed: %w", hook.Name, err)
 		}
 
-		logger().Info("hook completed", "name", hook.Name)
+		data.logger().Info("hook completed", "name", hook.Name)
 	}
 	return nil
 }
 
-func runHook(ctx context.Context, hook config.HookCommand, data TemplateData) error {
+func runHook(ctx context.Context, hook config.HookCommand, data TemplateData, opts Options) error {
+	var sec secrets
+	if hook.Type == config.HookTypeSlack || hook.Type == config.HookTypeTelegram {
+		return notify(ctx, hook, data, &sec, opts)
+	}
+
 	cmd, err := renderTemplate(hook.Cmd, data)
 	if err != nil {
 		return fmt.Errorf("rendering cmd template: %w", err)
@@ -67,8 +111,16 @@ func runHook(ctx context.Context, hook config.HookCommand, data TemplateData) er
 
 	execCmd := exec.CommandContext(ctx, cmd, args...)
 
-	// Set environment variables
+	// Set environment variables, reading those that reference secrets
 	for k, v := range hook.Environment {
+		if _, _, ok := config.ParseSecretRef(v); ok {
+			secret, err := sec.resolve(ctx, v)
+			if err != nil {
+				return fmt.Errorf("env %q: %w", k, err)
+			}
+			execCmd.Env = append(execCmd.Env, fmt.Sprintf("%s=%s", k, secret))
+			continue
+		}
 		rendered, err := renderTemplate(v, data)
 		if err != nil {
 			return fmt.Errorf("rendering env %q template: %w", k, err)
@@ -76,23 +128,50 @@ func runHook(ctx context.Context, hook config.HookCommand, data TemplateData) er
 		execCmd.Env = append(execCmd.Env, fmt.Sprintf("%s=%s", k, rendered))
 	}
 
+	if hook.StdinJSON {
+		payload, err := json.Marshal(data)
+		if err != nil {
+			return fmt.Errorf("encoding stdin JSON: %w", err)
+		}
+		execCmd.Stdin = bytes.NewReader(append(payload, '\n'))
+	}
+
 	if hook.StreamOutput {
-		execCmd.Stdout = &logWriter{prefix: hook.Name, level: "info"}
-		execCmd.Stderr = &logWriter{prefix: hook.Name, level: "error"}
+		execCmd.Stdout = &logWriter{prefix: hook.Name, level: "info", secrets: &sec, logger: data.logger()}
+		execCmd.Stderr = &logWriter{prefix: hook.Name, level: "error", secrets: &sec, logger: data.logger()}
 		return execCmd.Run()
 	}
 
 	output, err := execCmd.CombinedOutput()
 	if err != nil {
-		logger().Error("hook output", "name", hook.Name, "output", string(output))
+		data.logger().Error("hook output", "name", hook.Name, "output", sec.redact(string(output)))
 		return err
 	}
 	if len(output) > 0 {
-		logger().Debug("hook output", "name", hook.Name, "output", string(output))
+		data.logger().Debug("hook output", "name", hook.Name, "output", sec.redact(string(output)))
 	}
 	return nil
 }
 
+// conditionMet reports whether a hook's when template renders "true"; an
+// empty condition is always met.
+func conditionMet(when string, data TemplateData) (bool, error) {
+	if when == "" {
+		return true, nil
+	}
+	rendered, err := renderTemplate(when, data)
+	if err != nil {
+		return false, fmt.Errorf("rendering when template: %w", err)
+	}
+	switch strings.TrimSpace(rendered) {
+	case "true":
+		return true, nil
+	case "false", "":
+		return false, nil
+	}
+	return false, fmt.Errorf("when must render true or false, got %q", rendered)
+}
+
 func renderTemplate(tmplStr string, data TemplateData) (string, error) {
 	tmpl, err := template.New("").Parse(tmplStr)
 	if err != nil {
@@ -107,19 +186,21 @@ func renderTemplate(tmplStr string, data TemplateData) (string, error) {
 
 // logWriter implements io.Writer and logs each line.
 type logWriter struct {
-	prefix string
-	level  string
+	prefix  string
+	level   string
+	secrets *secrets
+	logger  *log.Logger
 }
 
 func (w *logWriter) Write(p []byte) (n int, err error) {
-	msg := string(bytes.TrimSpace(p))
+	msg := w.secrets.redact(string(bytes.TrimSpace(p)))
 	if msg == "" {
 		return len(p), nil
 	}
 	if w.level == "error" {
-		logger().Error(msg, "hook", w.prefix)
+		w.logger.Error(msg, "hook", w.prefix)
 	} else {
-		logger().Info(msg, "hook", w.prefix)
+		w.logger.Info(msg, "hook", w.prefix)
 	}
 	return len(p), nil
 }
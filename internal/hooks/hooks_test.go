@@ -2,6 +2,13 @@ package hooks
 
 import (
 	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
 	"testing"
 
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
@@ -125,3 +132,210 @@ func TestRunHooks_StreamOutput(t *testing.T) {
 		t.Fatal(err)
 	}
 }
+
+func TestRunHooks_StdinJSON(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "stdin.json")
+	hooks := []config.HookCommand{
+		{
+			Name:      "stdin-json",
+			Cmd:       "sh",
+			Args:      []string{"-c", `cat > "$0"`, out},
+			StdinJSON: true,
+		},
+	}
+
+	data := TemplateData{SnapshotSlot: "135501350", SnapshotType: "full", DownloadSizeMB: 1024, ErrorKind: "disk-full"}
+	if err := RunHooks(context.Background(), hooks, data); err != nil {
+		t.Fatal(err)
+	}
+
+	raw, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got map[string]any
+	if err := json.Unmarshal(raw, &got); err != nil {
+		t.Fatalf("stdin isn't JSON: %v: %s", err, raw)
+	}
+	if got["snapshot_slot"] != "135501350" || got["snapshot_type"] != "full" || got["download_size_mb"] != float64(1024) || got["error_kind"] != "disk-full" {
+		t.Errorf("unexpected payload %v", got)
+	}
+	if _, ok := got["incident_reason"]; !ok {
+		t.Error("expected empty fields in the payload too")
+	}
+}
+
+func TestRunHooks_Notifiers(t *testing.T) {
+	var requests []map[string]string
+	var paths []string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var body map[string]string
+		if err := json.NewDecoder(r.Body).Decode(&body); err != nil {
+			t.Errorf("decoding request: %v", err)
+		}
+		requests = append(requests, body)
+		paths = append(paths, r.URL.Path)
+		if body["text"] == "fail me" {
+			http.Error(w, "invalid_payload", http.StatusBadRequest)
+		}
+	}))
+	defer srv.Close()
+	orig := telegramAPIURL
+	telegramAPIURL = srv.URL
+	defer func() { telegramAPIURL = orig }()
+
+	hooks := []config.HookCommand{
+		{Name: "slack", Type: config.HookTypeSlack, WebhookURL: srv.URL + "/services/T/B/X", Channel: "#ops"},
+		{Name: "telegram", Type: config.HookTypeTelegram, BotToken: "123:abc", ChatID: "-100", Message: "slot {{ .SnapshotSlot }} on {{ .ClusterName }}"},
+	}
+	data := TemplateData{Event: EventSuccess, SnapshotSlot: "135501350", SnapshotType: "full", ClusterName: "testnet", SourceNode: "http://1.2.3.4:8899"}
+	if err := RunHooks(context.Background(), hooks, data); err != nil {
+		t.Fatal(err)
+	}
+
+	if len(requests) != 2 {
+		t.Fatalf("expected 2 posted messages, got %d", len(requests))
+	}
+	if paths[0] != "/services/T/B/X" || requests[0]["channel"] != "#ops" || !strings.Contains(requests[0]["text"], "downloaded full snapshot at slot 135501350 from http://1.2.3.4:8899") {
+		t.Errorf("unexpected slack message %v to %s", requests[0], paths[0])
+	}
+	if paths[1] != "/bot123:abc/sendMessage" || requests[1]["chat_id"] != "-100" || requests[1]["text"] != "slot 135501350 on testnet" {
+		t.Errorf("unexpected telegram message %v to %s", requests[1], paths[1])
+	}
+
+	failing := []config.HookCommand{{Name: "slack", Type: config.HookTypeSlack, WebhookURL: srv.URL, Message: "fail me"}}
+	if err := RunHooks(context.Background(), failing, data); err == nil || !strings.Contains(err.Error(), "invalid_payload") {
+		t.Errorf("expected the service's error, got %v", err)
+	}
+}
+
+type recordingTransport struct{ hosts []string }
+
+func (rt *recordingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	rt.hosts = append(rt.hosts, req.URL.Host)
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestRunHooksWithOptions_Transport(t *testing.T) {
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer srv.Close()
+
+	rt := &recordingTransport{}
+	hooks := []config.HookCommand{{Name: "slack", Type: config.HookTypeSlack, WebhookURL: srv.URL}}
+	if err := RunHooksWithOptions(context.Background(), hooks, TemplateData{Event: EventSuccess}, Options{Transport: rt}); err != nil {
+		t.Fatal(err)
+	}
+	if len(rt.hosts) != 1 || rt.hosts[0] != strings.TrimPrefix(srv.URL, "http://") {
+		t.Errorf("expected the post to go through the given transport, got %v", rt.hosts)
+	}
+}
+
+func TestDefaultMessages(t *testing.T) {
+	for _, event := range []string{EventSuccess, EventFailure, EventSkip, EventIncidentEnter, EventIncidentExit} {
+		msg, err := renderTemplate(defaultMessages[event], TemplateData{Event: event, ClusterName: "testnet", SkipReason: "validator is active"})
+		if err != nil || !strings.HasPrefix(msg, "snapshot keeper (testnet): ") {
+			t.Errorf("%s: unexpected default message %q (%v)", event, msg, err)
+		}
+	}
+}
+
+func TestRunHooks_When(t *testing.T) {
+	out := filepath.Join(t.TempDir(), "ran.log")
+	record := func(name, when string) config.HookCommand {
+		return config.HookCommand{Name: name, Cmd: "sh", Args: []string{"-c", "echo " + name + " >> " + out}, When: when}
+	}
+	hooks := []config.HookCommand{
+		record("always", ""),
+		record("full-only", `{{ eq .SnapshotType "full" }}`),
+		record("slow-only", "{{ gt .DownloadTimeSec 1800 }}"),
+	}
+
+	if err := RunHooks(context.Background(), hooks, TemplateData{SnapshotType: "full", DownloadTimeSec: 60}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.Fields(string(data)); !slices.Equal(got, []string{"always", "full-only"}) {
+		t.Errorf("expected only the hooks whose condition holds to run, got %v", got)
+	}
+
+	bad := []config.HookCommand{record("bad", "{{ .SnapshotType }}")}
+	if err := RunHooks(context.Background(), bad, TemplateData{SnapshotType: "full"}); err == nil || !strings.Contains(err.Error(), "when must render true or false") {
+		t.Errorf("expected a non-boolean condition to fail the hook, got %v", err)
+	}
+	bad[0].AllowFailure = true
+	if err := RunHooks(context.Background(), bad, TemplateData{SnapshotType: "full"}); err != nil {
+		t.Errorf("expected allow_failure to cover a bad condition, got %v", err)
+	}
+}
+
+func TestSecrets(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(secretFile, []byte("from-file\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	emptyFile := filepath.Join(dir, "empty")
+	if err := os.WriteFile(emptyFile, nil, 0600); err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("HOOK_TEST_SECRET", "from-env")
+
+	tests := []struct {
+		ref     string
+		want    string
+		wantErr bool
+	}{
+		{"plain value", "plain value", false},
+		{"file://" + secretFile, "from-file", false},
+		{"env://HOOK_TEST_SECRET", "from-env", false},
+		{"cmd://echo from-cmd", "from-cmd", false},
+		{"file://" + filepath.Join(dir, "missing"), "", true},
+		{"file://" + emptyFile, "", true},
+		{"env://HOOK_TEST_UNSET_SECRET", "", true},
+		{"cmd://false", "", true},
+	}
+	var sec secrets
+	for _, tt := range tests {
+		got, err := sec.resolve(context.Background(), tt.ref)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("resolve(%q) = %q, %v, want %q (error %v)", tt.ref, got, err, tt.want, tt.wantErr)
+		}
+	}
+
+	if got := sec.redact("token from-file, from-env and from-cmd, plain value"); got != "token REDACTED, REDACTED and REDACTED, plain value" {
+		t.Errorf("redact = %q", got)
+	}
+}
+
+func TestRunHooks_SecretEnvironment(t *testing.T) {
+	dir := t.TempDir()
+	secretFile := filepath.Join(dir, "token")
+	if err := os.WriteFile(secretFile, []byte("s3cret\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	out := filepath.Join(dir, "out")
+	hooks := []config.HookCommand{{
+		Name:        "secret-env",
+		Cmd:         "sh",
+		Args:        []string{"-c", `echo "$TOKEN $SLOT" > "$0"`, out},
+		Environment: map[string]string{"TOKEN": "file://" + secretFile, "SLOT": "{{ .SnapshotSlot }}"},
+	}}
+	if err := RunHooks(context.Background(), hooks, TemplateData{SnapshotSlot: "100"}); err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "s3cret 100" {
+		t.Errorf("expected the secret and templated values in the environment, got %q", got)
+	}
+
+	hooks[0].Environment["TOKEN"] = "env://HOOK_TEST_UNSET_SECRET"
+	if err := RunHooks(context.Background(), hooks, TemplateData{}); err == nil {
+		t.Error("expected an unresolvable secret to fail the hook")
+	}
+}
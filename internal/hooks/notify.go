@@ -0,0 +1,107 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+)
+
+// Events hooks run on, as set in TemplateData.Event.
+const (
+	EventSuccess       = "success"
+	EventFailure       = "failure"
+	EventSkip          = "skip"
+	EventIncidentEnter = "incident_enter"
+	EventIncidentExit  = "incident_exit"
+)
+
+// defaultMessages are what slack and telegram hooks post without a message
+// template.
+var defaultMessages = map[string]string{
+	EventSuccess:       `snapshot keeper ({{ .ClusterName }}): downloaded {{ .SnapshotType }} snapshot at slot {{ .SnapshotSlot }} from {{ .SourceNode }} - {{ .DownloadSizeMB }} MB in {{ .DownloadTimeSec }}s`,
+	EventFailure:       `snapshot keeper ({{ .ClusterName }}): cycle failed{{ if .ErrorKind }} ({{ .ErrorKind }}){{ end }}: {{ .Error }}`,
+	EventSkip:          `snapshot keeper ({{ .ClusterName }}): cycle skipped - {{ .SkipReason }}`,
+	EventIncidentEnter: `snapshot keeper ({{ .ClusterName }}): incident mode entered, downloads and pruning frozen - {{ .IncidentReason }}`,
+	EventIncidentExit:  `snapshot keeper ({{ .ClusterName }}): incident mode exited (was: {{ .IncidentReason }})`,
+}
+
+// telegramAPIURL is the Telegram Bot API, replaced in tests.
+var telegramAPIURL = "https://api.telegram.org"
+
+// notifyTimeout bounds posting one message.
+const notifyTimeout = 10 * time.Second
+
+// notify posts the hook's message to Slack or Telegram.
+func notify(ctx context.Context, hook config.HookCommand, data TemplateData, sec *secrets, opts Options) error {
+	tmpl := hook.Message
+	if tmpl == "" {
+		tmpl = defaultMessages[data.Event]
+	}
+	msg, err := renderTemplate(tmpl, data)
+	if err != nil {
+		return fmt.Errorf("rendering message template: %w", err)
+	}
+
+	var endpoint string
+	var body any
+	switch hook.Type {
+	case config.HookTypeSlack:
+		if endpoint, err = sec.resolve(ctx, hook.WebhookURL); err != nil {
+			return fmt.Errorf("webhook_url: %w", err)
+		}
+		body = struct {
+			Text    string `json:"text"`
+			Channel string `json:"channel,omitempty"`
+		}{msg, hook.Channel}
+	case config.HookTypeTelegram:
+		token, err := sec.resolve(ctx, hook.BotToken)
+		if err != nil {
+			return fmt.Errorf("bot_token: %w", err)
+		}
+		endpoint = fmt.Sprintf("%s/bot%s/sendMessage", telegramAPIURL, token)
+		body = struct {
+			ChatID string `json:"chat_id"`
+			Text   string `json:"text"`
+		}{hook.ChatID, msg}
+	default:
+		return fmt.Errorf("unknown hook type %q", hook.Type)
+	}
+	return postJSON(ctx, &http.Client{Transport: opts.Transport}, endpoint, body, sec)
+}
+
+func postJSON(ctx context.Context, client *http.Client, endpoint string, body any, sec *secrets) error {
+	payload, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, notifyTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		// Drop the URL from the error, it carries the webhook's or bot's secret
+		var urlErr *url.Error
+		if errors.As(err, &urlErr) {
+			err = urlErr.Err
+		}
+		return fmt.Errorf("posting message: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		snippet, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("posting message: %s: %s", resp.Status, sec.redact(string(bytes.TrimSpace(snippet))))
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package hooks
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+)
+
+// redacted replaces resolved secrets in hook output and errors.
+const redacted = "REDACTED"
+
+// secrets resolves the secret references of one hook run, remembering the
+// values so they can be redacted from what is logged about it.
+type secrets struct {
+	values []string
+}
+
+// resolve returns v, or the secret it references. Secrets are read on every
+// run, so rotating one doesn't need a restart.
+func (s *secrets) resolve(ctx context.Context, v string) (string, error) {
+	scheme, target, ok := config.ParseSecretRef(v)
+	if !ok {
+		return v, nil
+	}
+
+	var value string
+	switch scheme {
+	case config.SecretSchemeFile:
+		data, err := os.ReadFile(target)
+		if err != nil {
+			return "", fmt.Errorf("reading secret: %w", err)
+		}
+		value = string(data)
+	case config.SecretSchemeEnv:
+		if value, ok = os.LookupEnv(target); !ok {
+			return "", fmt.Errorf("secret environment variable %s is not set", target)
+		}
+	case config.SecretSchemeCmd:
+		// Run without a shell: arguments are split on whitespace
+		args := strings.Fields(target)
+		if len(args) == 0 {
+			return "", fmt.Errorf("secret command is empty")
+		}
+		out, err := exec.CommandContext(ctx, args[0], args[1:]...).Output()
+		if err != nil {
+			return "", fmt.Errorf("running secret command %s: %w", args[0], err)
+		}
+		value = string(out)
+	}
+
+	value = strings.TrimRight(value, "\r\n")
+	if value == "" {
+		return "", fmt.Errorf("secret from %s is empty", strings.TrimSuffix(scheme, "://"))
+	}
+	s.values = append(s.values, value)
+	return value, nil
+}
+
+// redact replaces every resolved secret in text.
+func (s *secrets) redact(text string) string {
+	if s == nil {
+		return text
+	}
+	for _, v := range s.values {
+		text = strings.ReplaceAll(text, v, redacted)
+	}
+	return text
+}
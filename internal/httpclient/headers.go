@@ -0,0 +1,31 @@
+package httpclient
+
+import "net/http"
+
+// WithHeaders returns rt with headers added to every request that doesn't
+// already set them, so per-request headers such as Range or a peer's
+// Authorization win. Without headers it returns rt itself.
+func WithHeaders(rt http.RoundTripper, headers http.Header) http.RoundTripper {
+	if len(headers) == 0 {
+		return rt
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &headerTransport{next: rt, headers: headers}
+}
+
+type headerTransport struct {
+	next    http.RoundTripper
+	headers http.Header
+}
+
+func (t *headerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	for name, values := range t.headers {
+		if _, ok := req.Header[name]; !ok {
+			req.Header[name] = values
+		}
+	}
+	return t.next.RoundTrip(req)
+}
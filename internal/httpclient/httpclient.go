@@ -0,0 +1,83 @@
+package httpclient
+
+import (
+	"crypto/tls"
+	"net"
+	"net/http"
+	"net/url"
+	"syscall"
+	"time"
+)
+
+// Options configures the HTTP transport used for snapshot probes and downloads.
+// Zero values keep http.DefaultTransport's settings.
+type Options struct {
+	TLSConfig *tls.Config // nil uses Go's defaults (system roots, verification on)
+	ProxyURL  *url.URL    // nil honors HTTP_PROXY/HTTPS_PROXY/NO_PROXY
+
+	MaxIdleConnsPerHost int
+	IdleConnTimeout     time.Duration
+	DialTimeout         time.Duration
+	KeepAlive           time.Duration // TCP keep-alive probe interval
+	TLSHandshakeTimeout time.Duration
+	DisableHTTP2        bool
+	// SocketReadBuffer and SocketWriteBuffer set the TCP receive and send
+	// buffer sizes in bytes, before connecting so the receive window can
+	// scale to them (0 = OS default)
+	SocketReadBuffer  int
+	SocketWriteBuffer int
+}
+
+// NewTransport returns a transport based on http.DefaultTransport with opts applied.
+func NewTransport(opts Options) *http.Transport {
+	t := http.DefaultTransport.(*http.Transport).Clone()
+	if opts.TLSConfig != nil {
+		t.TLSClientConfig = opts.TLSConfig.Clone()
+	}
+	if opts.ProxyURL != nil {
+		t.Proxy = http.ProxyURL(opts.ProxyURL)
+	}
+	if opts.MaxIdleConnsPerHost > 0 {
+		t.MaxIdleConnsPerHost = opts.MaxIdleConnsPerHost
+		t.MaxIdleConns = max(t.MaxIdleConns, opts.MaxIdleConnsPerHost)
+	}
+	if opts.IdleConnTimeout > 0 {
+		t.IdleConnTimeout = opts.IdleConnTimeout
+	}
+	if opts.TLSHandshakeTimeout > 0 {
+		t.TLSHandshakeTimeout = opts.TLSHandshakeTimeout
+	}
+	if opts.DisableHTTP2 {
+		// A non-nil, empty TLSNextProto turns off HTTP/2 negotiation
+		t.ForceAttemptHTTP2 = false
+		t.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+	}
+	if opts.DialTimeout > 0 || opts.KeepAlive > 0 || opts.SocketReadBuffer > 0 || opts.SocketWriteBuffer > 0 {
+		t.DialContext = newDialer(opts).DialContext
+	}
+	return t
+}
+
+// newDialer returns a dialer like http.DefaultTransport's with opts applied.
+func newDialer(opts Options) *net.Dialer {
+	d := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	if opts.DialTimeout > 0 {
+		d.Timeout = opts.DialTimeout
+	}
+	if opts.KeepAlive > 0 {
+		d.KeepAlive = opts.KeepAlive
+	}
+	if opts.SocketReadBuffer > 0 || opts.SocketWriteBuffer > 0 {
+		d.Control = func(network, address string, c syscall.RawConn) error {
+			var sockErr error
+			err := c.Control(func(fd uintptr) {
+				sockErr = setSocketBuffers(fd, opts.SocketReadBuffer, opts.SocketWriteBuffer)
+			})
+			if err != nil {
+				return err
+			}
+			return sockErr
+		}
+	}
+	return d
+}
@@ -0,0 +1,169 @@
+package httpclient
+
+import (
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+)
+
+func TestNewTransport_DefaultRejectsSelfSigned(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	client := &http.Client{Transport: NewTransport(Options{})}
+	if _, err := client.Get(server.URL); err == nil {
+		t.Error("expected certificate verification error for self-signed server")
+	}
+}
+
+func TestNewTransport_CustomCA(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	caFile := filepath.Join(t.TempDir(), "ca.pem")
+	caPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	if err := os.WriteFile(caFile, caPEM, 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	tlsCfg := config.TLS{CAFile: caFile}
+	if err := tlsCfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: NewTransport(Options{TLSConfig: tlsCfg.Parsed})}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected custom CA to be trusted: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransport_InsecureSkipVerify(t *testing.T) {
+	server := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tlsCfg := config.TLS{InsecureSkipVerify: true}
+	if err := tlsCfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	client := &http.Client{Transport: NewTransport(Options{TLSConfig: tlsCfg.Parsed})}
+	resp, err := client.Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected insecure_skip_verify to accept self-signed cert: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestNewTransport_ProxyURL(t *testing.T) {
+	var proxied string
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxied = r.URL.String()
+	}))
+	defer proxy.Close()
+
+	proxyURL, _ := url.Parse(proxy.URL)
+	client := &http.Client{Transport: NewTransport(Options{ProxyURL: proxyURL})}
+	resp, err := client.Get("http://snapshots.example.invalid/snapshot.tar.bz2")
+	if err != nil {
+		t.Fatalf("expected request via proxy to succeed: %v", err)
+	}
+	resp.Body.Close()
+
+	if proxied != "http://snapshots.example.invalid/snapshot.tar.bz2" {
+		t.Errorf("expected proxy to receive absolute request URL, got %q", proxied)
+	}
+}
+
+func TestNewTransport_HTTP2Toggle(t *testing.T) {
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.EnableHTTP2 = true
+	server.StartTLS()
+	defer server.Close()
+
+	tlsCfg := config.TLS{InsecureSkipVerify: true}
+	if err := tlsCfg.Validate(); err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range []struct {
+		disable bool
+		want    string
+	}{
+		{false, "HTTP/2.0"},
+		{true, "HTTP/1.1"},
+	} {
+		client := &http.Client{Transport: NewTransport(Options{TLSConfig: tlsCfg.Parsed, DisableHTTP2: tt.disable})}
+		resp, err := client.Get(server.URL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		if resp.Proto != tt.want {
+			t.Errorf("DisableHTTP2=%v: expected %s, got %s", tt.disable, tt.want, resp.Proto)
+		}
+	}
+}
+
+func TestNewTransport_Tuning(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer server.Close()
+
+	tr := NewTransport(Options{
+		MaxIdleConnsPerHost: 16,
+		IdleConnTimeout:     time.Minute,
+		TLSHandshakeTimeout: 5 * time.Second,
+		DialTimeout:         5 * time.Second,
+		SocketReadBuffer:    4 << 20,
+		SocketWriteBuffer:   1 << 20,
+	})
+	if tr.MaxIdleConnsPerHost != 16 || tr.IdleConnTimeout != time.Minute || tr.TLSHandshakeTimeout != 5*time.Second {
+		t.Errorf("tuning not applied: %+v", tr)
+	}
+
+	// Socket buffers are set before connecting
+	resp, err := (&http.Client{Transport: tr}).Get(server.URL)
+	if err != nil {
+		t.Fatalf("expected request with tuned sockets to succeed: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestWithHeaders(t *testing.T) {
+	var got http.Header
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = r.Header.Clone()
+	}))
+	defer srv.Close()
+
+	h := config.SnapshotsHTTP{UserAgent: "keeper (validator Abc)", Headers: map[string]string{"x-validator-identity": "Abc", "Range": "bytes=0-"}}
+	if err := h.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	client := &http.Client{Transport: WithHeaders(nil, h.Parsed)}
+	req, _ := http.NewRequest(http.MethodGet, srv.URL, nil)
+	req.Header.Set("Range", "bytes=10-19")
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp.Body.Close()
+
+	if got.Get("User-Agent") != "keeper (validator Abc)" || got.Get("X-Validator-Identity") != "Abc" {
+		t.Errorf("expected identification headers, got %v", got)
+	}
+	if got.Get("Range") != "bytes=10-19" {
+		t.Errorf("expected the request's own Range to win, got %q", got.Get("Range"))
+	}
+	if req.Header.Get("User-Agent") != "" {
+		t.Error("expected the caller's request to be left unmodified")
+	}
+}
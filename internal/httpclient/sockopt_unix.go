@@ -0,0 +1,19 @@
+//go:build !windows
+
+package httpclient
+
+import "syscall"
+
+// setSocketBuffers sets a socket's receive and send buffer sizes, skipping
+// zero sizes.
+func setSocketBuffers(fd uintptr, read, write int) error {
+	if read > 0 {
+		if err := syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, read); err != nil {
+			return err
+		}
+	}
+	if write > 0 {
+		return syscall.SetsockoptInt(int(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF, write)
+	}
+	return nil
+}
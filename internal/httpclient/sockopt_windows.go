@@ -0,0 +1,17 @@
+package httpclient
+
+import "syscall"
+
+// setSocketBuffers sets a socket's receive and send buffer sizes, skipping
+// zero sizes.
+func setSocketBuffers(fd uintptr, read, write int) error {
+	if read > 0 {
+		if err := syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_RCVBUF, read); err != nil {
+			return err
+		}
+	}
+	if write > 0 {
+		return syscall.SetsockoptInt(syscall.Handle(fd), syscall.SOL_SOCKET, syscall.SO_SNDBUF, write)
+	}
+	return nil
+}
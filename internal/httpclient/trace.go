@@ -0,0 +1,203 @@
+package httpclient
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("httpclient") }
+
+// maxTraceBody caps how much of an RPC body is written to a trace file.
+const maxTraceBody = 64 * 1024
+
+// TraceOptions configures HTTP transcript recording.
+type TraceOptions struct {
+	Directory  string
+	SampleRate float64 // fraction of requests recorded, 0 < rate <= 1
+	// Bodies records request and response bodies for RPC traffic. Snapshot
+	// bodies are never recorded.
+	Bodies bool
+}
+
+// Tracer writes sampled request/response metadata to one JSON file per request.
+type Tracer struct {
+	opts TraceOptions
+	seq  atomic.Uint64
+}
+
+// NewTracer creates the trace directory and returns a Tracer.
+func NewTracer(opts TraceOptions) (*Tracer, error) {
+	if opts.SampleRate <= 0 || opts.SampleRate > 1 {
+		return nil, fmt.Errorf("trace sample rate must be > 0 and <= 1, got %g", opts.SampleRate)
+	}
+	if err := os.MkdirAll(opts.Directory, 0755); err != nil {
+		return nil, fmt.Errorf("creating trace directory: %w", err)
+	}
+	return &Tracer{opts: opts}, nil
+}
+
+// Wrap returns rt with tracing applied. category ("rpc", "probe" or
+// "download") is recorded in the transcript and file name. A nil Tracer
+// returns rt unchanged.
+func (t *Tracer) Wrap(rt http.RoundTripper, category string) http.RoundTripper {
+	if t == nil {
+		return rt
+	}
+	if rt == nil {
+		rt = http.DefaultTransport
+	}
+	return &tracingTransport{next: rt, tracer: t, category: category}
+}
+
+type transcript struct {
+	Category        string              `json:"category"`
+	StartedAt       time.Time           `json:"started_at"`
+	DurationMs      float64             `json:"duration_ms"`
+	Method          string              `json:"method"`
+	URL             string              `json:"url"`
+	RequestHeaders  map[string][]string `json:"request_headers"`
+	RequestBody     string              `json:"request_body,omitempty"`
+	Status          int                 `json:"status,omitempty"`
+	ResponseHeaders map[string][]string `json:"response_headers,omitempty"`
+	ResponseBody    string              `json:"response_body,omitempty"`
+	Error           string              `json:"error,omitempty"`
+}
+
+type tracingTransport struct {
+	next     http.RoundTripper
+	tracer   *Tracer
+	category string
+}
+
+func (t *tracingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if rand.Float64() >= t.tracer.opts.SampleRate {
+		return t.next.RoundTrip(req)
+	}
+
+	bodies := t.tracer.opts.Bodies && t.category == "rpc"
+	rec := transcript{
+		Category:       t.category,
+		StartedAt:      time.Now().UTC(),
+		Method:         req.Method,
+		URL:            redactURL(req.URL),
+		RequestHeaders: redactHeaders(req.Header),
+	}
+
+	if bodies && req.Body != nil && req.GetBody != nil {
+		if body, err := req.GetBody(); err == nil {
+			rec.RequestBody = readTraceBody(body)
+			body.Close()
+		}
+	}
+
+	resp, err := t.next.RoundTrip(req)
+	rec.DurationMs = float64(time.Since(rec.StartedAt)) / float64(time.Millisecond)
+	if err != nil {
+		rec.Error = err.Error()
+		t.tracer.write(rec)
+		return resp, err
+	}
+
+	rec.Status = resp.StatusCode
+	rec.ResponseHeaders = redactHeaders(resp.Header)
+	if bodies {
+		data, readErr := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		resp.Body = io.NopCloser(bytes.NewReader(data))
+		if readErr != nil {
+			rec.Error = readErr.Error()
+		}
+		rec.ResponseBody = truncateTraceBody(data)
+	}
+	t.tracer.write(rec)
+	return resp, nil
+}
+
+func (t *Tracer) write(rec transcript) {
+	name := fmt.Sprintf("%s-%06d-%s.json", rec.StartedAt.Format("20060102T150405.000"), t.seq.Add(1), rec.Category)
+	data, err := json.MarshalIndent(rec, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(filepath.Join(t.opts.Directory, name), data, 0644); err != nil {
+		logger().Debug("failed to write http trace", "error", err)
+	}
+}
+
+func readTraceBody(r io.Reader) string {
+	data, _ := io.ReadAll(io.LimitReader(r, maxTraceBody+1))
+	return truncateTraceBody(data)
+}
+
+func truncateTraceBody(data []byte) string {
+	if len(data) > maxTraceBody {
+		return string(data[:maxTraceBody]) + "...(truncated)"
+	}
+	return string(data)
+}
+
+// secretSegment matches URL path segments that are likely credentials: an
+// API key or webhook secret, or a Telegram bot<id>:<token>. Snapshot file
+// names have dots and aren't matched.
+var secretSegment = regexp.MustCompile(`^(bot\d+:.+|[A-Za-z0-9_-]{20,})$`)
+
+// redactURL renders u without its userinfo, query values or secret-looking
+// path segments, keeping enough to tell requests apart.
+func redactURL(u *url.URL) string {
+	r := *u
+	r.User = nil
+	r.RawPath = ""
+	r.Fragment = ""
+	segments := strings.Split(u.Path, "/")
+	for i, seg := range segments {
+		if secretSegment.MatchString(seg) {
+			segments[i] = "REDACTED"
+		}
+	}
+	r.Path = strings.Join(segments, "/")
+	if u.RawQuery != "" {
+		q := u.Query()
+		for key := range q {
+			q[key] = []string{"REDACTED"}
+		}
+		r.RawQuery = q.Encode()
+	}
+	return r.String()
+}
+
+// redactHeaders copies h with credential-bearing values replaced.
+func redactHeaders(h http.Header) map[string][]string {
+	out := make(map[string][]string, len(h))
+	for name, values := range h {
+		lower := strings.ToLower(name)
+		if strings.Contains(lower, "auth") || strings.Contains(lower, "key") ||
+			strings.Contains(lower, "token") || strings.Contains(lower, "secret") || strings.Contains(lower, "cookie") {
+			out[name] = []string{"REDACTED"}
+			continue
+		}
+		if lower == "location" {
+			for _, v := range values {
+				if u, err := url.Parse(v); err == nil {
+					v = redactURL(u)
+				}
+				out[name] = append(out[name], v)
+			}
+			continue
+		}
+		out[name] = append([]string(nil), values...)
+	}
+	return out
+}
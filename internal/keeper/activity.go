@@ -0,0 +1,99 @@
+package keeper
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/events"
+)
+
+// LastActivity returns when the keeper last made progress: a cycle starting,
+// a download attempt starting or receiving bytes, a discovery probe
+// finishing, or a step that works through an archive (see busy) running. A
+// wedged cycle stops advancing it, which lets the manager stop refreshing
+// its lock.
+func (k *Keeper) LastActivity() time.Time {
+	k.activityMu.Lock()
+	defer k.activityMu.Unlock()
+	return k.activity
+}
+
+func (k *Keeper) touch() {
+	k.activityMu.Lock()
+	defer k.activityMu.Unlock()
+	k.activity = k.clock.Now()
+}
+
+// busyInterval is how often busy records activity, well within the
+// shortest lock heartbeat interval (lock.ttl of 1m / 3).
+const busyInterval = 10 * time.Second
+
+// busy records activity every busyInterval until the returned func is
+// called or ctx ends. It covers steps that work through a whole archive
+// without reporting progress: hashing, verifying, recompressing and
+// unpacking it.
+func (k *Keeper) busy(ctx context.Context) (done func()) {
+	k.touch()
+	stop := make(chan struct{})
+	stopped := make(chan struct{})
+	go func() {
+		defer close(stopped)
+		ticker := k.clock.NewTicker(busyInterval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ticker.C():
+				k.touch()
+			case <-stop:
+				return
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+	return func() {
+		close(stop)
+		<-stopped
+	}
+}
+
+// activityReporter records activity whenever a download's byte count moves
+// and publishes the progress, then passes it on to the configured reporter.
+type activityReporter struct {
+	downloader.ProgressReporter
+	k *Keeper
+
+	mu         sync.Mutex
+	downloaded map[string]int64
+}
+
+func newActivityReporter(k *Keeper, r downloader.ProgressReporter) *activityReporter {
+	return &activityReporter{ProgressReporter: r, k: k, downloaded: map[string]int64{}}
+}
+
+func (a *activityReporter) Report(p downloader.Progress) {
+	a.mu.Lock()
+	moved := p.Downloaded > a.downloaded[p.File]
+	a.downloaded[p.File] = p.Downloaded
+	a.mu.Unlock()
+	if moved {
+		a.k.touch()
+	}
+	a.k.events.Publish(events.DownloadProgress{
+		At:             a.k.clock.Now(),
+		File:           p.File,
+		Downloaded:     p.Downloaded,
+		Total:          p.Total,
+		BytesPerSecond: int64(p.SpeedBps()),
+	})
+	a.ProgressReporter.Report(p)
+}
+
+func (a *activityReporter) Done(p downloader.Progress) {
+	a.mu.Lock()
+	delete(a.downloaded, p.File)
+	a.mu.Unlock()
+	a.ProgressReporter.Done(p)
+}
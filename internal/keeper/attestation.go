@@ -0,0 +1,131 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// errNotAttested is returned with snapshots.attestation.required when the
+// trust endpoint has no hash for a downloaded archive.
+var errNotAttested = errors.New("trust endpoint has no hash for the archive")
+
+// verifyAttestation checks a downloaded archive against the SHA-256 the
+// trust endpoint publishes for it, with snapshots.attestation. It runs
+// before the archive is renamed into place; one that fails verification is
+// removed so the validator can't load it.
+func (k *Keeper) verifyAttestation(ctx context.Context, node discovery.SnapshotNode, path string) error {
+	if k.attestation == nil {
+		return nil
+	}
+	required := k.cfg.Snapshots.Attestation.Required
+
+	found, err := k.attestation.Verify(ctx, path, node.Filename, node.Slot)
+	switch {
+	case errors.Is(err, attestation.ErrMismatch):
+		k.metrics.Count("download.attestation_mismatch", 1, map[string]string{"cluster": k.cfg.Cluster.Name, "type": string(node.SnapshotType)})
+		k.logger().Error("SNAPSHOT HASH MISMATCH - the source served an archive that differs from the trust endpoint's, removing it",
+			"node", node.RPCURL,
+			"file", path,
+			"error", err,
+		)
+	case errors.Is(err, attestation.ErrUnavailable) && !required:
+		k.logger().Warn("could not check snapshot hash with the trust endpoint - keeping unverified archive", "file", node.Filename, "error", err)
+		return nil
+	case err != nil && ctx.Err() != nil:
+		// Interrupted, not rejected - the archive is checked again next time
+		return fmt.Errorf("verifying snapshot hash: %w", err)
+	case err != nil:
+		err = fmt.Errorf("verifying snapshot hash: %w", err)
+	case !found && !required:
+		k.logger().Warn("trust endpoint has no hash for snapshot - keeping unverified archive", "file", node.Filename)
+		return nil
+	case !found:
+		err = fmt.Errorf("%w: %s", errNotAttested, node.Filename)
+	default:
+		k.logger().Info("snapshot hash matches the trust endpoint", "file", node.Filename)
+		return nil
+	}
+
+	k.removeRejected(path, "failed trust endpoint verification")
+	return err
+}
+
+// verifyContentHash checks an archive fetched from a content-addressed
+// source against the SHA-256 its manifest published, removing it on a
+// mismatch. Archives from probed nodes have no published hash.
+func (k *Keeper) verifyContentHash(ctx context.Context, node discovery.SnapshotNode, path string) error {
+	if node.SHA256 == "" {
+		return nil
+	}
+	got, err := attestation.FileSHA256(ctx, path)
+	if err != nil {
+		return fmt.Errorf("hashing snapshot: %w", err)
+	}
+	if got != node.SHA256 {
+		k.logger().Error("SNAPSHOT HASH MISMATCH - the content source served an archive that differs from its manifest, removing it",
+			"source", node.SnapshotURL,
+			"file", path,
+		)
+		k.removeRejected(path, "sha256 differs from its content manifest")
+		return fmt.Errorf("%s has sha256 %s, manifest published %s", node.Filename, got, node.SHA256)
+	}
+	k.logger().Info("snapshot hash matches its content manifest", "file", node.Filename)
+	return nil
+}
+
+// signedEntry returns the source's signed manifest entry for the archive,
+// with snapshots.attestation.trusted_pubkeys, so an archive no trusted key
+// attests to isn't downloaded at all. It returns nil when signed manifests
+// aren't checked.
+func (k *Keeper) signedEntry(ctx context.Context, node discovery.SnapshotNode) (*attestation.SignedEntry, error) {
+	if k.signed == nil {
+		return nil, nil
+	}
+	entry, err := k.signed.Entry(ctx, node.RPCURL, node.Filename, node.Slot)
+	if err != nil {
+		k.logger().Warn("skipping source - no trusted signed manifest lists the snapshot", "node", node.RPCURL, "file", node.Filename, "error", err)
+		return nil, err
+	}
+	return &entry, nil
+}
+
+// verifySigned checks a downloaded archive against the size and SHA-256 its
+// source's signed manifest lists before it is renamed into place, removing
+// it on a mismatch.
+func (k *Keeper) verifySigned(ctx context.Context, node discovery.SnapshotNode, entry *attestation.SignedEntry, path string) error {
+	if entry == nil {
+		return nil
+	}
+	err := entry.VerifyFile(ctx, path)
+	switch {
+	case errors.Is(err, attestation.ErrMismatch):
+		k.metrics.Count("download.attestation_mismatch", 1, map[string]string{"cluster": k.cfg.Cluster.Name, "type": string(node.SnapshotType)})
+		k.logger().Error("SNAPSHOT HASH MISMATCH - the source served an archive that differs from its signed manifest, removing it",
+			"node", node.RPCURL,
+			"file", path,
+			"error", err,
+		)
+	case err != nil && ctx.Err() != nil:
+		return fmt.Errorf("verifying signed manifest: %w", err)
+	case err != nil:
+		err = fmt.Errorf("verifying signed manifest: %w", err)
+	default:
+		k.logger().Info("snapshot matches its signed manifest", "file", node.Filename)
+		return nil
+	}
+	k.removeRejected(path, "differs from its signed manifest")
+	return err
+}
+
+// removeRejected removes an archive that failed verification.
+func (k *Keeper) removeRejected(path, reason string) {
+	if err := os.Remove(path); err == nil {
+		k.auditLog.Record(audit.Event{Action: audit.ActionDelete, Path: path, Reason: reason})
+	}
+}
@@ -0,0 +1,108 @@
+package keeper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// candidatesFilename records the best suitable candidates of the last
+// discovery, so the next cycle (including a separate `run` invocation) probes
+// them first.
+const candidatesFilename = "solana-validator-snapshot-keeper.candidates.json"
+
+// rememberedCandidate is a node that was suitable, with its probe latency.
+type rememberedCandidate struct {
+	RPCURL  string        `json:"rpc_url"`
+	Latency time.Duration `json:"latency"`
+}
+
+// candidateMemory keeps the lowest-latency suitable candidates across
+// cycles. Nodes found suitable during a cycle replace the remembered ones
+// when it saves.
+type candidateMemory struct {
+	mu         sync.Mutex
+	path       string
+	remembered []rememberedCandidate
+	found      map[string]time.Duration
+}
+
+func loadCandidateMemory(dir string) *candidateMemory {
+	m := &candidateMemory{path: filepath.Join(dir, candidatesFilename), found: map[string]time.Duration{}}
+	data, err := os.ReadFile(m.path)
+	if err != nil {
+		return m
+	}
+	if err := json.Unmarshal(data, &m.remembered); err != nil {
+		logger().Warn("ignoring unreadable remembered candidates", "path", m.path, "error", err)
+		m.remembered = nil
+	}
+	return m
+}
+
+// addresses returns the RPC URLs of the remembered candidates, best first.
+func (m *candidateMemory) addresses() []string {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	addrs := make([]string, 0, len(m.remembered))
+	for _, c := range m.remembered {
+		addrs = append(addrs, c.RPCURL)
+	}
+	return addrs
+}
+
+// observe records a node found suitable this cycle. It is called from probe
+// goroutines.
+func (m *candidateMemory) observe(n discovery.SnapshotNode) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if l, ok := m.found[n.RPCURL]; !ok || n.Latency < l {
+		m.found[n.RPCURL] = n.Latency
+	}
+}
+
+// save remembers the size lowest-latency nodes found since the last save.
+// When none were found, the remembered candidates are kept as they are.
+func (m *candidateMemory) save(size int) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if len(m.found) == 0 {
+		return
+	}
+	remembered := make([]rememberedCandidate, 0, len(m.found))
+	for addr, latency := range m.found {
+		remembered = append(remembered, rememberedCandidate{RPCURL: addr, Latency: latency})
+	}
+	sort.Slice(remembered, func(i, j int) bool {
+		if remembered[i].Latency != remembered[j].Latency {
+			return remembered[i].Latency < remembered[j].Latency
+		}
+		return remembered[i].RPCURL < remembered[j].RPCURL
+	})
+	if len(remembered) > size {
+		remembered = remembered[:size]
+	}
+	m.remembered = remembered
+	m.found = map[string]time.Duration{}
+
+	data, err := json.MarshalIndent(m.remembered, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(m.path, data, 0644); err != nil {
+		logger().Error("failed to write remembered candidates", "path", m.path, "error", err)
+	}
+}
+
+// rememberCandidates saves the best candidates found by this cycle's
+// discovery, when snapshots.discovery.candidates.remember is set.
+func (k *Keeper) rememberCandidates() {
+	if n := k.cfg.Snapshots.Discovery.Candidates.Remember; n > 0 {
+		k.candidates.save(n)
+	}
+}
@@ -0,0 +1,53 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// chainIncrementals applies snapshots.download.incremental_chain. After a
+// download leaves the newest local snapshot at newestSlot, it keeps fetching
+// newer incrementals for the full at baseSlot until the local state is
+// within max_slots_behind of the cluster, nothing newer is served, or
+// max_downloads is reached. It returns the last incremental downloaded, if
+// any.
+func (k *Keeper) chainIncrementals(ctx context.Context, clusterNodes []rpc.ClusterNode, baseSlot, newestSlot uint64, opts discovery.Options, dlOpts downloader.Options) (discovery.SnapshotNode, *downloader.Result, bool) {
+	chain := &k.cfg.Snapshots.Download.IncrementalChain
+	var last discovery.SnapshotNode
+	var lastResult *downloader.Result
+	if !chain.Enabled {
+		return last, nil, false
+	}
+
+	for downloads := 0; downloads < chain.MaxDownloads; downloads++ {
+		currentSlot, err := k.slots.GetSlot(ctx)
+		if err != nil {
+			k.logger().Warn("incremental chain stopped - getting current slot failed", "error", err)
+			break
+		}
+		if currentSlot <= newestSlot || currentSlot-newestSlot <= uint64(k.slotSetting(&chain.MaxSlotsBehind)) {
+			k.logger().Debug("incremental chain complete - local state within max_slots_behind", "newest_slot", newestSlot, "current_slot", currentSlot)
+			break
+		}
+		behind := currentSlot - newestSlot
+		k.logger().Info(fmt.Sprintf("local snapshot %d slots (%s) behind network after download - looking for a newer incremental", behind, k.slotsToTime(behind)),
+			"base_slot", baseSlot,
+			"newest_slot", newestSlot,
+		)
+
+		candidates := discovery.StreamIncrementalForBase(ctx, clusterNodes, currentSlot, baseSlot, opts)
+		result, node, _, _ := k.downloadFromCandidates(ctx, candidates, newestSlot+1, dlOpts)
+		candidates.Stop()
+		if result == nil {
+			k.logger().Info("no newer incremental downloaded - incremental chain complete", "newest_slot", newestSlot)
+			break
+		}
+		k.logger().Info("chained incremental snapshot downloaded", "slot", node.Slot, "base_slot", node.BaseSlot)
+		last, lastResult, newestSlot = node, result, node.Slot
+	}
+	return last, lastResult, lastResult != nil
+}
@@ -0,0 +1,110 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// validatorClient abstracts the local validator client: how the identity it
+// is running with is read for role detection, and where it keeps snapshot
+// archives.
+type validatorClient interface {
+	Name() string
+	// Identity returns the identity pubkey the validator is running with
+	Identity(ctx context.Context) (string, error)
+	// Slot returns the slot the validator has processed up to
+	Slot(ctx context.Context) (uint64, error)
+	// SnapshotDirs returns the directories full and incremental archives are
+	// loaded from; they may be the same
+	SnapshotDirs() (full, incremental string)
+}
+
+func newValidatorClient(cfg *config.Config, localRPC *rpc.Client) validatorClient {
+	dirs := snapshotDirs{full: cfg.Snapshots.Directory, incremental: cfg.Snapshots.IncrementalDir()}
+	if cfg.Validator.Client == config.ClientFiredancer {
+		return firedancerClient{rpc: localRPC, snapshotDirs: dirs}
+	}
+	c := agaveClient{rpc: localRPC, snapshotDirs: dirs}
+	if path := cfg.Validator.AdminRPCPath; path != "" {
+		c.admin = rpc.NewAdminClient(path)
+	}
+	return c
+}
+
+type snapshotDirs struct {
+	full, incremental string
+}
+
+func (d snapshotDirs) SnapshotDirs() (string, string) {
+	return d.full, d.incremental
+}
+
+// agaveClient reads the identity over the validator's JSON-RPC, or its admin
+// RPC socket when validator.admin_rpc_path is set. Archives go to
+// --snapshots, and incrementals to --incremental-snapshot-archive-path when
+// set.
+type agaveClient struct {
+	rpc *rpc.Client
+	// admin is nil unless validator.admin_rpc_path is set
+	admin *rpc.AdminClient
+	snapshotDirs
+}
+
+func (agaveClient) Name() string { return config.ClientAgave }
+
+func (c agaveClient) Identity(ctx context.Context) (string, error) {
+	if c.admin != nil {
+		identity, err := c.admin.GetIdentity(ctx)
+		if err != nil {
+			return "", fmt.Errorf("admin RPC %s: %w", c.admin.Path(), err)
+		}
+		return identity, nil
+	}
+	return c.rpc.GetIdentity(ctx)
+}
+
+func (c agaveClient) Slot(ctx context.Context) (uint64, error) {
+	return c.rpc.GetSlot(ctx)
+}
+
+// firedancerClient reads the identity over Firedancer's JSON-RPC, which is
+// only served when [rpc] port is set in its config. Archives go to
+// [snapshots] path, and incrementals to [snapshots] incremental_path when set.
+type firedancerClient struct {
+	rpc *rpc.Client
+	snapshotDirs
+}
+
+func (firedancerClient) Name() string { return config.ClientFiredancer }
+
+func (c firedancerClient) Identity(ctx context.Context) (string, error) {
+	identity, err := c.rpc.GetIdentity(ctx)
+	if err != nil {
+		return "", fmt.Errorf("%w (is [rpc] port set in the Firedancer config?)", err)
+	}
+	return identity, nil
+}
+
+func (c firedancerClient) Slot(ctx context.Context) (uint64, error) {
+	return c.rpc.GetSlot(ctx)
+}
+
+// localSnapshots returns the archives in the validator's snapshot directories.
+func (k *Keeper) localSnapshots() ([]pruner.SnapshotFile, error) {
+	full, incremental := k.client.SnapshotDirs()
+	return pruner.GetLocalSnapshots(full, incremental)
+}
+
+// destDir returns the directory node's archive is downloaded to.
+func (k *Keeper) destDir(node discovery.SnapshotNode) string {
+	full, incremental := k.client.SnapshotDirs()
+	if node.SnapshotType == discovery.SnapshotTypeIncremental {
+		return incremental
+	}
+	return full
+}
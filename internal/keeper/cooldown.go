@@ -0,0 +1,74 @@
+package keeper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+)
+
+// cooldownsFilename records sources that failed mid-download, so the next
+// cycle (including a separate `run` invocation) doesn't pick them again.
+const cooldownsFilename = "solana-validator-snapshot-keeper.cooldowns.json"
+
+// sourceCooldowns maps a source's RPC URL to when it may be used again.
+type sourceCooldowns struct {
+	mu    sync.Mutex
+	path  string
+	until map[string]time.Time
+}
+
+func loadSourceCooldowns(dir string) *sourceCooldowns {
+	c := &sourceCooldowns{path: filepath.Join(dir, cooldownsFilename), until: map[string]time.Time{}}
+	data, err := os.ReadFile(c.path)
+	if err != nil {
+		return c
+	}
+	if err := json.Unmarshal(data, &c.until); err != nil {
+		logger().Warn("ignoring unreadable source cooldowns", "path", c.path, "error", err)
+		c.until = map[string]time.Time{}
+	}
+	return c
+}
+
+// coolingDown returns when source may be used again, if that is after now.
+func (c *sourceCooldowns) coolingDown(source string, now time.Time) (time.Time, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	until, ok := c.until[source]
+	return until, ok && now.Before(until)
+}
+
+// record puts source on cooldown until now+d, dropping expired entries.
+func (c *sourceCooldowns) record(source string, now time.Time, d time.Duration) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	for s, until := range c.until {
+		if !now.Before(until) {
+			delete(c.until, s)
+		}
+	}
+	c.until[source] = now.Add(d)
+
+	data, err := json.MarshalIndent(c.until, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(c.path, data, 0644); err != nil {
+		logger().Error("failed to write source cooldowns", "path", c.path, "error", err)
+	}
+}
+
+// skipCoolingDown reports whether a candidate should be skipped because it
+// recently failed mid-download.
+func (k *Keeper) skipCoolingDown(source string) bool {
+	if k.cfg.Snapshots.Download.FailureCooldownDur <= 0 {
+		return false
+	}
+	until, ok := k.cooldowns.coolingDown(source, k.clock.Now())
+	if ok {
+		k.logger().Info("skipping source cooling down after a failed download", "node", source, "until", until.Format(time.RFC3339))
+	}
+	return ok
+}
@@ -0,0 +1,112 @@
+package keeper
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/cyclereport"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// probeCounts tallies the cycle's discovery probes, which finish on the
+// probing goroutines.
+type probeCounts struct {
+	probed   atomic.Int64
+	suitable atomic.Int64
+}
+
+// probed records a finished discovery probe: progress for LastActivity, and
+// a count for the cycle's report.
+func (k *Keeper) probed(suitable bool) {
+	k.touch()
+	k.probes.probed.Add(1)
+	if suitable {
+		k.probes.suitable.Add(1)
+	}
+}
+
+// phase starts timing a step of the cycle for its report. Calling the
+// returned func ends it; calls after the first do nothing, so it can also be
+// deferred to cover early returns.
+func (k *Keeper) phase(name string) (done func()) {
+	start := k.clock.Now()
+	ended := false
+	return func() {
+		if ended {
+			return
+		}
+		ended = true
+		k.report.Phases = append(k.report.Phases, cyclereport.Phase{Name: name, DurationMs: k.clock.Now().Sub(start).Milliseconds()})
+	}
+}
+
+// reportDownload records a fetched archive in the cycle's report.
+func (k *Keeper) reportDownload(node discovery.SnapshotNode, result *downloader.Result) {
+	k.report.Downloads = append(k.report.Downloads, cyclereport.Download{
+		Path:       result.FilePath,
+		Source:     node.SnapshotURL,
+		Bytes:      result.Bytes,
+		DurationMs: time.Duration(result.DurationSecs * float64(time.Second)).Milliseconds(),
+		SpeedBps:   result.SpeedBps,
+		Existing:   result.Existing,
+	})
+}
+
+// reportCandidate records the node the cycle's snapshot came from.
+func (k *Keeper) reportCandidate(node discovery.SnapshotNode) {
+	k.report.Candidate = &cyclereport.Candidate{
+		RPCURL:    node.RPCURL,
+		Type:      string(node.SnapshotType),
+		Slot:      node.Slot,
+		BaseSlot:  node.BaseSlot,
+		Filename:  node.Filename,
+		LatencyMs: node.Latency.Milliseconds(),
+		SlotAge:   node.SlotAge,
+	}
+}
+
+// reportPruned records a file pruning removed or quarantined.
+func (k *Keeper) reportPruned(path, action, reason string) {
+	k.report.Pruned = append(k.report.Pruned, cyclereport.Pruned{Path: path, Action: action, Reason: reason})
+}
+
+// writeCycleReport completes the cycle's report from its published decision
+// and writes it to reports.directory, then drops reports beyond
+// reports.keep. Failing to is logged: the cycle itself is done.
+func (k *Keeper) writeCycleReport(start, finished time.Time) {
+	dir := k.cfg.Reports.Directory
+	if dir == "" {
+		return
+	}
+	d, _ := k.LastDecision()
+	r := k.report
+	r.Started = start.UTC()
+	r.Finished = finished.UTC()
+	r.DurationMs = finished.Sub(start).Milliseconds()
+	r.Cluster = k.cfg.Cluster.Name
+	r.Validator = k.cfg.Name
+	r.Result = d.Result
+	r.Reason = d.Reason
+	r.Role = d.Role
+	r.Identity = d.Identity
+	r.Mode = d.Mode
+	r.CurrentSlot = d.CurrentSlot
+	r.LocalSlot = d.LocalSlot
+	r.SnapshotSlot = d.SnapshotSlot
+	r.Error = d.Error
+	r.ErrorKind = d.ErrorKind
+	r.Discovery.Rejections = d.Rejections
+	r.Discovery.Probed = int(k.probes.probed.Load())
+	r.Discovery.Suitable = int(k.probes.suitable.Load())
+
+	path, err := cyclereport.Write(dir, r)
+	if err != nil {
+		k.logger().Error("failed to write cycle report", "dir", dir, "error", err)
+		return
+	}
+	k.logger().Debug("cycle report written", "file", path)
+	if err := cyclereport.Prune(dir, k.cfg.Name, k.cfg.Reports.Keep); err != nil {
+		k.logger().Error("failed to prune cycle reports", "dir", dir, "error", err)
+	}
+}
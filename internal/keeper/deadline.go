@@ -0,0 +1,30 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+)
+
+// errCycleDeadline cancels a cycle that ran longer than
+// keeper.max_cycle_duration.
+var errCycleDeadline = errors.New("cycle exceeded keeper.max_cycle_duration")
+
+// withCycleDeadline bounds a cycle by keeper.max_cycle_duration, measured on
+// the keeper's clock. The returned context is cancelled with
+// errCycleDeadline when the budget runs out.
+func (k *Keeper) withCycleDeadline(ctx context.Context) (context.Context, context.CancelCauseFunc) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	budget := k.cfg.Keeper.MaxCycleDurationDur
+	if budget <= 0 {
+		return ctx, cancel
+	}
+	go func() {
+		select {
+		case <-k.clock.After(budget):
+			k.logger().Error("cycle ran past keeper.max_cycle_duration, stopping it", "max_cycle_duration", budget)
+			cancel(errCycleDeadline)
+		case <-ctx.Done():
+		}
+	}()
+	return ctx, cancel
+}
@@ -0,0 +1,51 @@
+package keeper
+
+import (
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// Decision records what a cycle decided and why, for the status endpoint.
+type Decision struct {
+	At           time.Time `json:"at"`
+	Result       string    `json:"result"` // success, skipped or failure
+	Reason       string    `json:"reason"`
+	Role         string    `json:"role,omitempty"`
+	Identity     string    `json:"identity,omitempty"` // the validator's identity, if it answered
+	Mode         string    `json:"mode,omitempty"`     // full or incremental
+	CurrentSlot  uint64    `json:"current_slot,omitempty"`
+	LocalSlot    uint64    `json:"local_slot,omitempty"` // the local validator's slot, if it answered
+	SnapshotSlot uint64    `json:"snapshot_slot,omitempty"`
+	Source       string    `json:"source,omitempty"`
+	Bytes        int64     `json:"bytes,omitempty"` // downloaded over the network
+	Error        string    `json:"error,omitempty"`
+	ErrorKind    string    `json:"error_kind,omitempty"` // see ErrorKind
+	// Rejections is why probed nodes were unsuitable, when discovery found
+	// no candidate to download
+	Rejections *discovery.RejectionSummary `json:"rejections,omitempty"`
+}
+
+// LastDecision returns the decision of the most recent cycle, if any.
+func (k *Keeper) LastDecision() (Decision, bool) {
+	k.decisionMu.Lock()
+	defer k.decisionMu.Unlock()
+	return k.lastDecision, !k.lastDecision.At.IsZero()
+}
+
+// publishDecision completes the cycle's decision and makes it the last one.
+func (k *Keeper) publishDecision(start time.Time, result cycleResult, err error) {
+	d := k.decision
+	d.At = start.UTC()
+	d.Result = string(result)
+	if err != nil {
+		d.Error = err.Error()
+		d.ErrorKind = string(KindOf(err))
+		if d.Reason == "" {
+			d.Reason = "cycle failed"
+		}
+	}
+	k.decisionMu.Lock()
+	k.lastDecision = d
+	k.decisionMu.Unlock()
+}
@@ -0,0 +1,38 @@
+package keeper
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// skipHaveSlot reports whether node's snapshot is for a slot a local
+// archive of the same type already covers, under another name, so a
+// download would only replace it. An archive under node's own name isn't
+// skipped: the download keeps it when its size matches the source.
+func (k *Keeper) skipHaveSlot(node discovery.SnapshotNode) bool {
+	snapshots, err := k.localSnapshots()
+	if err != nil {
+		return false
+	}
+	incremental := node.SnapshotType == discovery.SnapshotTypeIncremental
+	for _, s := range snapshots {
+		if s.IsFull == incremental || s.Slot != node.Slot || (incremental && s.BaseSlot != node.BaseSlot) {
+			continue
+		}
+		if filepath.Base(s.Path) == node.Filename {
+			return false
+		}
+		k.logger().Info("skipping candidate - a local snapshot already has its slot", "node", node.RPCURL, "slot", node.Slot, "local", filepath.Base(s.Path))
+		return true
+	}
+	return false
+}
+
+// haveArchive reports whether node's archive is already in its destination
+// directory.
+func (k *Keeper) haveArchive(node discovery.SnapshotNode) bool {
+	info, err := os.Stat(filepath.Join(k.destDir(node), node.Filename))
+	return err == nil && info.Mode().IsRegular()
+}
@@ -0,0 +1,40 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/diskbench"
+)
+
+// checkDiskThroughput benchmarks the download directory before the first
+// download with snapshots.download.disk_check, and warns if it can't absorb
+// min_speed on every connection. A disk that slow throttles downloads, and
+// the sources get the blame.
+func (k *Keeper) checkDiskThroughput(ctx context.Context) {
+	check := k.cfg.Snapshots.Download.DiskCheck
+	if !check.Enabled || k.diskChecked {
+		return
+	}
+	k.diskChecked = true
+
+	dir := k.cfg.Snapshots.DownloadDir()
+	result, err := diskbench.Sequential(ctx, dir, check.SizeBytes)
+	if err != nil {
+		k.logger().Warn("could not benchmark the download directory", "dir", dir, "error", err)
+		return
+	}
+	speed := result.BytesPerSecond()
+	k.metrics.Gauge("disk.write_speed_bps", float64(speed), map[string]string{"cluster": k.cfg.Cluster.Name})
+
+	dl := k.cfg.Snapshots.Download
+	if required := dl.RequiredWriteSpeed(); speed < required {
+		k.logger().Warn(fmt.Sprintf("download directory writes at %s/s, below the %s/s that min_speed on %d connections needs - downloads will be limited by the disk",
+			config.FormatSize(speed), config.FormatSize(required), dl.Connections),
+			"dir", dir,
+		)
+		return
+	}
+	k.logger().Info(fmt.Sprintf("download directory writes at %s/s", config.FormatSize(speed)), "dir", dir)
+}
@@ -0,0 +1,54 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// epochInfo fetches the current epoch when epoch-aware scheduling is
+// configured. A failure only disables that scheduling for the cycle.
+func (k *Keeper) epochInfo(ctx context.Context) *rpc.EpochInfo {
+	if k.slotSetting(&k.cfg.Snapshots.Epoch.DeferFullSlots) == 0 && !k.cfg.Snapshots.Epoch.FullAfterBoundary {
+		return nil
+	}
+	info, err := k.clusterRPC.GetEpochInfo(ctx)
+	if err != nil {
+		k.logger().Warn("could not get epoch info, ignoring epoch scheduling this cycle", "error", err)
+		return nil
+	}
+	return info
+}
+
+// deferFullForEpoch reports whether a full download should wait until after
+// the epoch boundary, when nodes regenerate their fulls. Without a local full
+// to fall back on the download always goes ahead.
+func (k *Keeper) deferFullForEpoch(epoch *rpc.EpochInfo) bool {
+	limit := uint64(k.slotSetting(&k.cfg.Snapshots.Epoch.DeferFullSlots))
+	if epoch == nil || limit == 0 {
+		return false
+	}
+	remaining := epoch.SlotsRemaining()
+	if remaining >= limit {
+		return false
+	}
+	snapshots, err := k.localSnapshots()
+	if err != nil || pruner.NewestFullSnapshot(snapshots) == nil {
+		k.logger().Info(fmt.Sprintf("epoch %d ends in %d slots (%s) but there is no local full snapshot - downloading anyway", epoch.Epoch, remaining, k.slotsToTime(remaining)))
+		return false
+	}
+	k.logger().Info(fmt.Sprintf("epoch %d ends in %d slots (%s) - deferring full download until after the boundary", epoch.Epoch, remaining, k.slotsToTime(remaining)))
+	return true
+}
+
+// fullAfterBoundary reports whether the local full predates the current
+// epoch and should be replaced by a full taken since the boundary, rather
+// than topped up with incrementals.
+func (k *Keeper) fullAfterBoundary(epoch *rpc.EpochInfo, localFullSlot uint64) bool {
+	if epoch == nil || !k.cfg.Snapshots.Epoch.FullAfterBoundary || localFullSlot == 0 {
+		return false
+	}
+	return localFullSlot < epoch.FirstSlot()
+}
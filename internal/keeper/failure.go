@@ -0,0 +1,82 @@
+package keeper
+
+import (
+	"errors"
+	"syscall"
+)
+
+// ErrorKind classifies why a cycle failed, so hooks and the process exit
+// code let alerting route each kind of failure differently.
+type ErrorKind string
+
+const (
+	ErrorNoCandidates       ErrorKind = "no-candidates"
+	ErrorAllDownloadsFailed ErrorKind = "all-downloads-failed"
+	ErrorRPCUnavailable     ErrorKind = "rpc-unavailable"
+	ErrorDiskFull           ErrorKind = "disk-full"
+	ErrorVerificationFailed ErrorKind = "verification-failed"
+)
+
+// ExitCode returns the process exit code for a failure of this kind. An
+// unclassified failure exits 1.
+func (k ErrorKind) ExitCode() int {
+	switch k {
+	case ErrorNoCandidates:
+		return 3
+	case ErrorAllDownloadsFailed:
+		return 4
+	case ErrorRPCUnavailable:
+		return 5
+	case ErrorDiskFull:
+		return 6
+	case ErrorVerificationFailed:
+		return 7
+	default:
+		return 1
+	}
+}
+
+// classifiedError tags an error with the kind of failure it is.
+type classifiedError struct {
+	kind ErrorKind
+	err  error
+}
+
+func (e *classifiedError) Error() string { return e.err.Error() }
+func (e *classifiedError) Unwrap() error { return e.err }
+
+// classify tags err as a failure of the given kind.
+func classify(kind ErrorKind, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{kind: kind, err: err}
+}
+
+// KindOf returns the kind of failure err is, or "" if it isn't classified.
+// Running out of disk space is recognised wherever it happened.
+func KindOf(err error) ErrorKind {
+	if err == nil {
+		return ""
+	}
+	if errors.Is(err, syscall.ENOSPC) {
+		return ErrorDiskFull
+	}
+	var ce *classifiedError
+	if errors.As(err, &ce) {
+		return ce.kind
+	}
+	return ""
+}
+
+// candidatesFailed classifies a cycle in which every candidate failed. When
+// the last candidate failed for lack of disk space or verification, the
+// next source is unlikely to fare better, so that is the kind reported.
+func candidatesFailed(last, err error) error {
+	switch kind := KindOf(last); kind {
+	case ErrorDiskFull, ErrorVerificationFailed:
+		return classify(kind, err)
+	default:
+		return classify(ErrorAllDownloadsFailed, err)
+	}
+}
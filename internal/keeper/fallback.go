@@ -0,0 +1,206 @@
+package keeper
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+// How validatorIdentity read the identity.
+const (
+	identityViaRPC      = "rpc"
+	identityViaAdminRPC = "admin_rpc"
+	identityViaProcess  = "process"
+)
+
+// validatorIdentity returns the identity the validator runs with, read over
+// its RPC or, with validator.role_fallback, another way when that fails.
+// It also returns how it was read.
+func (k *Keeper) validatorIdentity(ctx context.Context) (string, string, error) {
+	identity, err := k.client.Identity(ctx)
+	if err == nil || !k.cfg.Validator.RoleFallback.Enabled {
+		return identity, identityViaRPC, err
+	}
+	identity, method, fallbackErr := k.fallbackIdentity(ctx)
+	if fallbackErr != nil {
+		return "", "", fmt.Errorf("%w; role fallback: %w", err, fallbackErr)
+	}
+	return identity, method, nil
+}
+
+// fallbackIdentity reads the identity the validator runs with when its RPC
+// doesn't answer: from Agave's admin RPC socket, which follows set-identity,
+// else from the validator process's command line, which only knows the
+// identity it was started with. It returns how the identity was read.
+func (k *Keeper) fallbackIdentity(ctx context.Context) (string, string, error) {
+	v := k.cfg.Validator
+	var errs []error
+	// With admin_rpc_path the client already asked the socket
+	if socket := v.AdminSocketPath(); socket != "" && v.AdminRPCPath == "" {
+		identity, err := rpc.NewAdminClient(socket).GetIdentity(ctx)
+		if err == nil {
+			return identity, identityViaAdminRPC, nil
+		}
+		errs = append(errs, fmt.Errorf("admin RPC: %w", err))
+	}
+	identity, err := processIdentity(v.Client, v.LedgerDirectory)
+	if err == nil {
+		return identity, identityViaProcess, nil
+	}
+	errs = append(errs, fmt.Errorf("process: %w", err))
+	return "", "", errors.Join(errs...)
+}
+
+// process is a running process's command line and working directory.
+type process struct {
+	args []string
+	cwd  string // empty when it can't be read
+}
+
+// processIdentity finds the running validator process of the given client
+// whose ledger is ledgerDir, and reads the pubkey of the identity keypair
+// its command line names. Without ledgerDir there must be only one such
+// process, since another validator's identity would be mistaken for ours.
+func processIdentity(client, ledgerDir string) (string, error) {
+	procs, err := processes()
+	if err != nil {
+		return "", err
+	}
+	var identities []string
+	for _, p := range procs {
+		identity, ledger, ok := validatorPaths(client, p)
+		if !ok || (ledgerDir != "" && !samePath(ledger, ledgerDir)) {
+			continue
+		}
+		identities = append(identities, identity)
+	}
+	switch {
+	case len(identities) == 0 && ledgerDir != "":
+		return "", fmt.Errorf("no running %s process with ledger %s found", client, ledgerDir)
+	case len(identities) == 0:
+		return "", fmt.Errorf("no running %s process found", client)
+	case len(identities) > 1 && ledgerDir == "":
+		return "", fmt.Errorf("%d running %s processes found, set validator.ledger_directory to pick ours", len(identities), client)
+	}
+	return config.ReadKeypairPubkey(identities[0])
+}
+
+// validatorPaths returns the identity keypair and ledger directory a
+// validator process names: Agave's --identity and --ledger, or
+// identity_path under [consensus] and path under [ledger] in the
+// Firedancer config passed with --config. Relative paths are resolved
+// against the process's working directory.
+func validatorPaths(client string, p process) (identity, ledger string, ok bool) {
+	if len(p.args) == 0 {
+		return "", "", false
+	}
+	args := p.args[1:]
+	switch bin := filepath.Base(p.args[0]); {
+	case client == config.ClientAgave && (bin == "agave-validator" || bin == "solana-validator"):
+		identity, _ = flagValue(args, "--identity", "-i")
+		ledger, _ = flagValue(args, "--ledger", "-l")
+	case client == config.ClientFiredancer && (bin == "fdctl" || bin == "firedancer"):
+		if !slices.Contains(args, "run") {
+			return "", "", false
+		}
+		cfgPath, found := flagValue(args, "--config")
+		if !found {
+			return "", "", false
+		}
+		if cfgPath, found = p.resolve(cfgPath); !found {
+			return "", "", false
+		}
+		identity, _ = tomlValue(cfgPath, "consensus", "identity_path")
+		ledger, _ = tomlValue(cfgPath, "ledger", "path")
+	default:
+		return "", "", false
+	}
+	if identity, ok = p.resolve(identity); !ok {
+		return "", "", false
+	}
+	// A ledger that can't be resolved just won't match ledger_directory
+	ledger, _ = p.resolve(ledger)
+	return identity, ledger, true
+}
+
+// resolve makes a non-empty path absolute against p's working directory.
+func (p process) resolve(path string) (string, bool) {
+	switch {
+	case path == "":
+		return "", false
+	case filepath.IsAbs(path):
+		return path, true
+	case p.cwd == "":
+		return "", false
+	}
+	return filepath.Join(p.cwd, path), true
+}
+
+// samePath reports whether a and b name the same directory, following
+// symlinks where they can be resolved.
+func samePath(a, b string) bool {
+	if a == "" || b == "" {
+		return false
+	}
+	if filepath.Clean(a) == filepath.Clean(b) {
+		return true
+	}
+	ra, errA := filepath.EvalSymlinks(a)
+	rb, errB := filepath.EvalSymlinks(b)
+	return errA == nil && errB == nil && ra == rb
+}
+
+// flagValue returns the value of the first of names in args, given as
+// "--name value" or "--name=value".
+func flagValue(args []string, names ...string) (string, bool) {
+	for i, arg := range args {
+		for _, name := range names {
+			if arg == name && i+1 < len(args) {
+				return args[i+1], true
+			}
+			if v, ok := strings.CutPrefix(arg, name+"="); ok {
+				return v, true
+			}
+		}
+	}
+	return "", false
+}
+
+// tomlValue reads the string key in table from a TOML file such as a
+// Firedancer config.
+func tomlValue(cfgPath, table, key string) (string, bool) {
+	f, err := os.Open(cfgPath)
+	if err != nil {
+		return "", false
+	}
+	defer f.Close()
+	current := ""
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = strings.TrimSpace(line[:i])
+		}
+		if strings.HasPrefix(line, "[") {
+			current = strings.Trim(line, "[] ")
+			continue
+		}
+		name, value, ok := strings.Cut(line, "=")
+		if !ok || current != table || strings.TrimSpace(name) != key {
+			continue
+		}
+		if v, err := strconv.Unquote(strings.TrimSpace(value)); err == nil && v != "" {
+			return v, true
+		}
+	}
+	return "", false
+}
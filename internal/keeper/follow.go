@@ -0,0 +1,49 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+// SetFollow makes schedule.follow.max_slots_behind, when set, override
+// snapshots.age.local.max_incremental_slots, for `run --follow`.
+func (k *Keeper) SetFollow(follow bool) {
+	k.follow = follow
+}
+
+// maxIncrementalSlots is how far behind the cluster the newest local snapshot
+// may be before a cycle downloads a newer one. It is read each time, so a new
+// slot time estimate applies straight away.
+func (k *Keeper) maxIncrementalSlots() uint64 {
+	if slots := k.slotSetting(&k.cfg.Schedule.Follow.MaxSlotsBehind); k.follow && slots > 0 {
+		return uint64(slots)
+	}
+	return uint64(k.slotSetting(&k.cfg.Snapshots.Age.Local.MaxIncrementalSlots))
+}
+
+// MaxSlotsBehind is how far behind the cluster the newest local snapshot may
+// be before a cycle downloads a newer one.
+func (k *Keeper) MaxSlotsBehind() uint64 {
+	return k.maxIncrementalSlots()
+}
+
+// SlotsBehind returns how many slots the newest local snapshot, including the
+// validator's own in its ledger directory, is behind the cluster. With no
+// local snapshots it is the current slot.
+func (k *Keeper) SlotsBehind(ctx context.Context) (uint64, error) {
+	currentSlot, err := k.slots.GetSlot(ctx)
+	if err != nil {
+		return 0, fmt.Errorf("getting current slot: %w", err)
+	}
+	snapshots, err := k.localSnapshots()
+	if err != nil {
+		return 0, err
+	}
+	snapshots = append(snapshots, k.ledgerSnapshots()...)
+	if len(snapshots) == 0 {
+		return currentSlot, nil
+	}
+	return currentSlot - min(pruner.NewestSlot(snapshots), currentSlot), nil
+}
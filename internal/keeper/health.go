@@ -0,0 +1,92 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+// slotHealth is the local validator's slot against the cluster's.
+type slotHealth struct {
+	cluster uint64
+	local   uint64 // 0 when the local validator's slot couldn't be read
+}
+
+// behind returns how many slots the local validator trails the cluster by,
+// and false when its slot is unknown.
+func (h slotHealth) behind() (uint64, bool) {
+	if h.local == 0 {
+		return 0, false
+	}
+	return h.cluster - min(h.local, h.cluster), true
+}
+
+// checkSlots reads the cluster's and the local validator's slots
+// concurrently. Only failing to read the cluster's is an error; the local
+// validator may well be down.
+func (k *Keeper) checkSlots(ctx context.Context) (slotHealth, error) {
+	var (
+		h        slotHealth
+		localErr error
+		wg       sync.WaitGroup
+	)
+	wg.Add(1)
+	go func() {
+		defer wg.Done()
+		h.local, localErr = k.client.Slot(ctx)
+	}()
+	cluster, err := k.slots.GetSlot(ctx)
+	wg.Wait()
+	if err != nil {
+		return h, err
+	}
+	h.cluster = cluster
+	if localErr != nil {
+		h.local = 0
+		k.logger().Debug("could not get the local validator's slot", "error", localErr)
+		return h, nil
+	}
+
+	behind, _ := h.behind()
+	k.metrics.Gauge("validator.slots_behind", float64(behind), map[string]string{"cluster": k.cfg.Cluster.Name})
+	if k.caughtUp(h) {
+		k.logger().Info("validator is caught up with the cluster", "local_slot", h.local, "cluster_slot", h.cluster, "slots_behind", behind)
+	} else {
+		k.logger().Info(fmt.Sprintf("validator is %d slots (%s) behind the cluster", behind, k.slotsToTime(behind)), "local_slot", h.local, "cluster_slot", h.cluster)
+	}
+	return h, nil
+}
+
+// caughtUp reports whether the local validator is within
+// validator.caught_up.max_slots_behind of the cluster.
+func (k *Keeper) caughtUp(h slotHealth) bool {
+	behind, ok := h.behind()
+	return ok && behind <= uint64(k.slotSetting(&k.cfg.Validator.CaughtUp.MaxSlotsBehind))
+}
+
+// skipWhileCaughtUp reports whether validator.caught_up.skip_downloads
+// applies: the validator is caught up and has a local full snapshot, its own
+// included, to restart from should it need to.
+func (k *Keeper) skipWhileCaughtUp(h slotHealth) bool {
+	if !k.cfg.Validator.CaughtUp.SkipDownloads || !k.caughtUp(h) {
+		return false
+	}
+	snapshots, err := k.localSnapshots()
+	if err != nil {
+		return false
+	}
+	snapshots = append(snapshots, k.ledgerSnapshots()...)
+	return pruner.NewestFullSnapshot(snapshots) != nil
+}
+
+// localSlotsBehind formats the decision's local slot lag for hooks, empty
+// when the local slot is unknown.
+func (d Decision) localSlotsBehind() string {
+	if d.LocalSlot == 0 {
+		return ""
+	}
+	return strconv.FormatUint(d.CurrentSlot-min(d.LocalSlot, d.CurrentSlot), 10)
+}
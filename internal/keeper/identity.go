@@ -0,0 +1,56 @@
+package keeper
+
+import (
+	"slices"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+)
+
+// activeIdentity returns the pubkey the validator votes with when active.
+func (k *Keeper) activeIdentity() string {
+	k.identityMu.Lock()
+	defer k.identityMu.Unlock()
+	if k.identity == "" {
+		return k.cfg.Validator.ActiveIdentityPubkey
+	}
+	return k.identity
+}
+
+// activeIdentities returns every identity the validator counts as active
+// with, see config.Validator.ActiveIdentities.
+func (k *Keeper) activeIdentities() []string {
+	v := k.cfg.Validator
+	v.ActiveIdentityPubkey = k.activeIdentity()
+	return v.ActiveIdentities()
+}
+
+// isActiveIdentity reports whether identity is one the validator votes with
+// when active.
+func (k *Keeper) isActiveIdentity(identity string) bool {
+	return identity != "" && slices.Contains(k.activeIdentities(), identity)
+}
+
+// refreshActiveIdentity re-reads validator.active_identity_keypair_path, so
+// a rotated key is followed from the next cycle on. A keypair that can't be
+// read keeps the last pubkey.
+func (k *Keeper) refreshActiveIdentity() {
+	path := k.cfg.Validator.ActiveIdentityKeypairPath
+	if path == "" {
+		return
+	}
+	pubkey, err := config.ReadKeypairPubkey(path)
+	if err != nil {
+		k.logger().Warn("re-reading the active identity keypair failed - keeping the last pubkey", "path", path, "error", err)
+		return
+	}
+	if prev := k.activeIdentity(); pubkey != prev {
+		k.logger().Info("active identity keypair changed", "path", path, "from", prev, "to", pubkey)
+		// The cached leader schedule is the old identity's
+		k.leaderMu.Lock()
+		k.leaders = nil
+		k.leaderMu.Unlock()
+	}
+	k.identityMu.Lock()
+	k.identity = pubkey
+	k.identityMu.Unlock()
+}
@@ -0,0 +1,162 @@
+package keeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"math"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/hooks"
+)
+
+// incidentMarkerFilename puts the keeper into incident mode while it exists
+// in the snapshots directory, so operators can freeze it without a restart.
+const incidentMarkerFilename = "solana-validator-snapshot-keeper.incident"
+
+// incidentStateFilename records an active incident so that enter and exit
+// hooks fire once per incident, including across `run` invocations.
+const incidentStateFilename = "solana-validator-snapshot-keeper.incident-state.json"
+
+type incidentState struct {
+	Reason string `json:"reason"`
+	Since  string `json:"since"`
+}
+
+// updateIncident decides whether incident mode is active, running the enter
+// and exit hooks on transitions. While it returns true, the cycle must not
+// download or prune so the local known-good snapshots are preserved.
+func (k *Keeper) updateIncident(ctx context.Context, role string, currentSlot uint64) bool {
+	reason := k.detectIncident(ctx, currentSlot)
+	prev, wasActive := k.loadIncidentState()
+
+	active := 0.0
+	if reason != "" {
+		active = 1
+	}
+	k.metrics.Gauge("incident.active", active, map[string]string{"cluster": k.cfg.Cluster.Name})
+
+	hookData := hooks.TemplateData{
+		Event:          hooks.EventIncidentEnter,
+		ClusterName:    k.cfg.Cluster.Name,
+		Validator:      k.cfg.Name,
+		ValidatorRole:  role,
+		IncidentReason: reason,
+	}
+
+	switch {
+	case reason != "" && !wasActive:
+		k.logger().Warn("entering incident mode - downloads and pruning frozen until the cluster stabilizes", "reason", reason)
+		k.saveIncidentState(incidentState{Reason: reason, Since: k.clock.Now().UTC().Format(time.RFC3339)})
+		if err := k.runHooks(ctx, k.cfg.Hooks.OnIncidentEnter, hookData); err != nil {
+			k.logger().Error("incident enter hooks failed", "error", err)
+		}
+	case reason == "" && wasActive:
+		k.logger().Info("exiting incident mode", "previous_reason", prev.Reason, "since", prev.Since)
+		k.clearIncidentState()
+		hookData.Event = hooks.EventIncidentExit
+		hookData.IncidentReason = prev.Reason
+		if err := k.runHooks(ctx, k.cfg.Hooks.OnIncidentExit, hookData); err != nil {
+			k.logger().Error("incident exit hooks failed", "error", err)
+		}
+	case reason != "":
+		k.logger().Warn("incident mode active - downloads and pruning frozen", "reason", reason, "since", prev.Since)
+	}
+
+	return reason != ""
+}
+
+// detectIncident returns why incident mode should be active, or "" if not.
+// Auto-detection samples the cluster slot again after incident.sample_window
+// and compares progression against the nominal slot rate.
+func (k *Keeper) detectIncident(ctx context.Context, startSlot uint64) string {
+	cfg := k.cfg.Incident
+	if reason := k.manualIncident(); reason != "" {
+		return reason
+	}
+	if !cfg.AutoDetect {
+		return ""
+	}
+
+	window := cfg.SampleWindowDur
+	select {
+	case <-k.clock.After(window):
+	case <-ctx.Done():
+		return ""
+	}
+
+	endSlot, err := k.slots.GetSlot(ctx)
+	if err != nil {
+		k.logger().Warn("could not sample cluster slot progression for incident detection", "error", err)
+		return ""
+	}
+
+	var advanced uint64
+	if endSlot > startSlot {
+		advanced = endSlot - startSlot
+	}
+	minSlots := uint64(math.Ceil(float64(window) / float64(k.slotTime()) * cfg.MinSlotRate))
+	k.logger().Debug("sampled cluster slot progression", "advanced", advanced, "window", window, "min_slots", minSlots)
+	if advanced < minSlots {
+		return fmt.Sprintf("cluster slot progression stalled: %d slots in %s, expected at least %d", advanced, window, minSlots)
+	}
+	return ""
+}
+
+// manualIncident returns why incident mode was turned on by hand, or "".
+func (k *Keeper) manualIncident() string {
+	if k.cfg.Incident.Manual {
+		return "manual (incident.manual)"
+	}
+	if _, err := os.Stat(filepath.Join(k.cfg.Snapshots.Directory, incidentMarkerFilename)); err == nil {
+		return fmt.Sprintf("manual (%s present)", incidentMarkerFilename)
+	}
+	return ""
+}
+
+// IncidentActive reports whether incident mode is in force, without sampling
+// slot progression: turned on by hand, or left active by the last cycle.
+func (k *Keeper) IncidentActive() (string, bool) {
+	if reason := k.manualIncident(); reason != "" {
+		return reason, true
+	}
+	if state, ok := k.loadIncidentState(); ok {
+		return state.Reason, true
+	}
+	return "", false
+}
+
+func (k *Keeper) incidentStatePath() string {
+	return filepath.Join(k.cfg.Snapshots.Directory, incidentStateFilename)
+}
+
+func (k *Keeper) loadIncidentState() (incidentState, bool) {
+	var state incidentState
+	data, err := os.ReadFile(k.incidentStatePath())
+	if err != nil {
+		return state, false
+	}
+	if err := json.Unmarshal(data, &state); err != nil {
+		k.logger().Warn("ignoring unreadable incident state", "path", k.incidentStatePath(), "error", err)
+		return state, false
+	}
+	return state, true
+}
+
+func (k *Keeper) saveIncidentState(state incidentState) {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(k.incidentStatePath(), data, 0644); err != nil {
+		k.logger().Error("failed to write incident state", "path", k.incidentStatePath(), "error", err)
+	}
+}
+
+func (k *Keeper) clearIncidentState() {
+	if err := os.Remove(k.incidentStatePath()); err != nil && !os.IsNotExist(err) {
+		k.logger().Error("failed to remove incident state", "path", k.incidentStatePath(), "error", err)
+	}
+}
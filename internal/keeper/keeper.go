@@ -2,26 +2,54 @@ package keeper
 
 import (
 	"context"
+	"errors"
 	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
 	"path/filepath"
+	"slices"
+	"sync"
 	"time"
 
 	"github.com/charmbracelet/log"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/clock"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/cyclereport"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/delta"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/events"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/geoip"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/hooks"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/httpclient"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/metrics"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/ownership"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/peer"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/recompress"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
 )
 
 func logger() *log.Logger { return log.Default().WithPrefix("keeper") }
 
-const slotDuration = 400 * time.Millisecond
+// validatorLogger tags the keeper's logs with the validator's name, when the
+// config runs several.
+func validatorLogger(cfg *config.Config) *log.Logger {
+	if cfg != nil && cfg.Name != "" {
+		return logger().With("validator", cfg.Name)
+	}
+	return logger()
+}
 
-func slotsToTime(slots uint64) string {
-	d := (time.Duration(slots) * slotDuration).Round(time.Second)
+func (k *Keeper) logger() *log.Logger { return validatorLogger(k.cfg) }
+
+// slotsToTime formats how long slots take.
+func (k *Keeper) slotsToTime(slots uint64) string {
+	d := (time.Duration(slots) * k.slotTime()).Round(time.Second)
 	return d.String()
 }
 
@@ -34,96 +62,416 @@ const (
 	modeFull        downloadMode = "full"
 )
 
+// cycleResult summarises how a cycle ended, for metrics.
+type cycleResult string
+
+const (
+	resultSuccess cycleResult = "success"
+	resultSkipped cycleResult = "skipped"
+	resultFailure cycleResult = "failure"
+)
+
 // Keeper orchestrates the snapshot keeping process.
 type Keeper struct {
 	cfg        *config.Config
 	localRPC   *rpc.Client
 	clusterRPC *rpc.Client
+	metrics    metrics.Sink
+	// probeTransport and downloadTransport carry snapshot traffic; they differ
+	// only when separate proxies are configured. hookTransport carries slack
+	// and telegram hooks' posts, like clusterRPC via cluster.proxy_url
+	probeTransport    http.RoundTripper
+	downloadTransport http.RoundTripper
+	hookTransport     http.RoundTripper
+	clock             clock.Clock
+	slots             SlotSource
+	cooldowns         *sourceCooldowns
+	candidates        *candidateMemory
+	reputations       *sourceReputations
+	// auditLog is nil unless audit.file is set
+	auditLog  *audit.Log
+	freeSpace func(dir string) (int64, error)
+	client    validatorClient
+	progress  downloader.ProgressReporter
+	leaders   *leaderSchedule
+	leaderMu  sync.Mutex
+	// attestation is nil unless snapshots.attestation.url is set
+	attestation *attestation.Client
+	// signed is nil unless snapshots.attestation.trusted_pubkeys is set
+	signed *attestation.SignedClient
+	// geo is nil unless snapshots.discovery.geo names a database
+	geo *geoip.Resolver
+	// pause holds downloads back while the validator is active, with
+	// validator.role_monitor.on_active "pause"; nil outside downloads
+	pause *rolePause
+	// activity is when the keeper last made progress, see LastActivity
+	activity   time.Time
+	activityMu sync.Mutex
+	// decision is filled in during a cycle; lastDecision is the published
+	// record of the previous one
+	decision     Decision
+	decisionMu   sync.Mutex
+	lastDecision Decision
+	// follow applies schedule.follow.max_slots_behind, see SetFollow
+	follow bool
+	// lastCandidateErr is why the cycle's most recent candidate failed
+	lastCandidateErr error
+	// diskChecked is set once the download directory was benchmarked
+	diskChecked bool
+	// events carries the keeper's progress to registered plugins and
+	// subscribers, see Events
+	events *events.Bus
+	// identity is the active identity pubkey last read from
+	// validator.active_identity_keypair_path, see activeIdentity
+	identity   string
+	identityMu sync.Mutex
+	// slotTimeEstimate is the slot time estimated with cluster.slot_time
+	// "auto" (0 = none yet), see slotTime
+	slotTimeEstimate  time.Duration
+	slotTimeSampledAt time.Time
+	slotTimeMu        sync.Mutex
+	// report collects what the cycle found, downloaded and pruned, written
+	// out with reports.directory
+	report cyclereport.Report
+	probes probeCounts
+}
+
+// SlotSource provides the cluster's current slot.
+type SlotSource interface {
+	GetSlot(ctx context.Context) (uint64, error)
+}
+
+// Options overrides Keeper dependencies so tests and simulations can drive
+// time and slot advancement deterministically.
+type Options struct {
+	Clock clock.Clock // nil uses the wall clock
+	Slots SlotSource  // nil uses the cluster RPC
+	// FreeSpace returns the bytes free on a directory's filesystem (nil =
+	// pruner.Free)
+	FreeSpace func(dir string) (int64, error)
 }
 
 // New creates a new Keeper.
 func New(cfg *config.Config) *Keeper {
-	return &Keeper{
-		cfg:        cfg,
-		localRPC:   rpc.NewClient(cfg.Validator.RPCURL),
-		clusterRPC: rpc.NewClient(cfg.Cluster.EffectiveRPCURL()),
+	return NewWithOptions(cfg, Options{})
+}
+
+// NewWithOptions creates a new Keeper with overridden dependencies.
+func NewWithOptions(cfg *config.Config, opts Options) *Keeper {
+	sink, err := metrics.New(metrics.Options{
+		Backend: cfg.Metrics.Backend,
+		Address: cfg.Metrics.Address,
+		Prefix:  cfg.Metrics.Prefix,
+		Tags:    cfg.Metrics.Tags,
+	})
+	if err != nil {
+		validatorLogger(cfg).Warn("metrics disabled", "error", err)
+		sink = metrics.Noop{}
+	}
+
+	var tracer *httpclient.Tracer
+	if cfg.TraceHTTP.Directory != "" {
+		tracer, err = httpclient.NewTracer(httpclient.TraceOptions{
+			Directory:  cfg.TraceHTTP.Directory,
+			SampleRate: cfg.TraceHTTP.SampleRate,
+			Bodies:     cfg.TraceHTTP.RPCBodies,
+		})
+		if err != nil {
+			validatorLogger(cfg).Warn("http tracing disabled", "error", err)
+		} else {
+			validatorLogger(cfg).Info("tracing http requests", "directory", cfg.TraceHTTP.Directory, "sample_rate", cfg.TraceHTTP.SampleRate)
+		}
+	}
+
+	clusterURLs := cfg.Cluster.EffectiveRPCURLs()
+	if len(clusterURLs) == 0 {
+		clusterURLs = []string{""}
+	}
+
+	k := &Keeper{
+		cfg: cfg,
+		localRPC: rpc.NewClientWithOptions(cfg.Validator.RPCURL, rpc.Options{
+			Transport: tracer.Wrap(nil, "rpc"),
+			Headers:   cfg.Validator.Auth.Parsed,
+		}),
+		clusterRPC: rpc.NewClientWithOptions(clusterURLs[0], rpc.Options{
+			Transport:    tracer.Wrap(httpclient.NewTransport(httpclient.Options{ProxyURL: cfg.Cluster.ProxyURLParsed}), "rpc"),
+			Headers:      cfg.Cluster.Auth.Parsed,
+			FallbackURLs: clusterURLs[1:],
+			Retry: rpc.RetryPolicy{
+				Attempts:  cfg.Cluster.Retry.Attempts,
+				BaseDelay: cfg.Cluster.Retry.BaseDelayDur,
+				MaxDelay:  cfg.Cluster.Retry.MaxDelayDur,
+			},
+		}),
+		metrics:           sink,
+		probeTransport:    tracer.Wrap(snapshotTransport(cfg, httpclient.NewTransport(probeTransportOptions(cfg))), "probe"),
+		downloadTransport: tracer.Wrap(snapshotTransport(cfg, httpclient.NewTransport(downloadTransportOptions(cfg))), "download"),
+		hookTransport:     tracer.Wrap(httpclient.NewTransport(httpclient.Options{ProxyURL: cfg.Cluster.ProxyURLParsed}), "hooks"),
+		clock:             opts.Clock,
+		slots:             opts.Slots,
+		freeSpace:         opts.FreeSpace,
+		cooldowns:         loadSourceCooldowns(cfg.Snapshots.Directory),
+		candidates:        loadCandidateMemory(cfg.Snapshots.Directory),
+		reputations:       loadSourceReputations(cfg.Snapshots.Directory),
+	}
+	if k.clock == nil {
+		k.clock = clock.Real{}
+	}
+	if k.slots == nil {
+		k.slots = k.clusterRPC
+	}
+	if k.freeSpace == nil {
+		k.freeSpace = pruner.Free
+	}
+	k.auditLog = audit.Open(cfg.Audit.File, k.clock.Now)
+	if a := cfg.Snapshots.Attestation; a.Enabled() {
+		k.attestation = attestation.New(a.URL, attestation.Options{
+			Headers:   a.Auth.Parsed,
+			Transport: tracer.Wrap(snapshotTransport(cfg, httpclient.NewTransport(probeTransportOptions(cfg))), "attestation"),
+			Timeout:   a.TimeoutDur,
+		})
+	}
+	if a := cfg.Snapshots.Attestation; a.Signed() {
+		k.signed = attestation.NewSigned(a.ManifestPath, a.TrustedKeys, attestation.Options{
+			Transport: tracer.Wrap(snapshotTransport(cfg, httpclient.NewTransport(probeTransportOptions(cfg))), "signed-manifest"),
+			Timeout:   a.TimeoutDur,
+		})
+	}
+	if g := cfg.Snapshots.Discovery.Geo; g.Enabled() {
+		if k.geo, err = geoip.Open(g.CountryDatabase, g.ASNDatabase); err != nil {
+			validatorLogger(cfg).Error("geo enrichment disabled - candidates won't be filtered or ranked by region", "error", err)
+		}
+	}
+	k.client = newValidatorClient(cfg, k.localRPC)
+	k.progress = newActivityReporter(k, newProgressReporter(cfg.Log))
+	k.events = &events.Bus{}
+	events.Attach(k.events)
+	return k
+}
+
+// Close releases the keeper's metrics connection. The keeper mustn't be used
+// afterwards.
+func (k *Keeper) Close() error {
+	return k.metrics.Close()
+}
+
+// Events returns the bus the keeper publishes cycle and download events on.
+func (k *Keeper) Events() *events.Bus {
+	return k.events
+}
+
+// snapshotTransport adds what every snapshot request carries to base:
+// snapshots.http's identification, and the token for requests to peers.
+func snapshotTransport(cfg *config.Config, base http.RoundTripper) http.RoundTripper {
+	return peer.Transport(httpclient.WithHeaders(base, cfg.Snapshots.HTTP.Parsed), cfg.Peers.URLs, cfg.Peers.Token)
+}
+
+// probeTransportOptions applies snapshots.tls and the probe proxy. Probes,
+// signed manifests and the trust endpoint are all small requests made
+// alongside discovery, so they share these.
+func probeTransportOptions(cfg *config.Config) httpclient.Options {
+	return httpclient.Options{
+		TLSConfig: cfg.Snapshots.TLS.Parsed,
+		ProxyURL:  cfg.Snapshots.Discovery.Probe.ProxyURLParsed,
+	}
+}
+
+// downloadTransportOptions applies snapshots.download.transport. Idle
+// connections default to one per download connection, so parallel requests
+// to a source reuse their connections.
+func downloadTransportOptions(cfg *config.Config) httpclient.Options {
+	dl := cfg.Snapshots.Download
+	t := dl.Transport
+	idle := t.MaxIdleConnsPerHost
+	if idle == 0 {
+		idle = dl.Connections
+	}
+	return httpclient.Options{
+		TLSConfig:           cfg.Snapshots.TLS.Parsed,
+		ProxyURL:            dl.ProxyURLParsed,
+		MaxIdleConnsPerHost: idle,
+		IdleConnTimeout:     t.IdleConnTimeoutDur,
+		DialTimeout:         t.DialTimeoutDur,
+		KeepAlive:           t.KeepAliveDur,
+		TLSHandshakeTimeout: t.TLSHandshakeTimeoutDur,
+		DisableHTTP2:        t.DisableHTTP2,
+		SocketReadBuffer:    t.SocketReadBufferBytes,
+		SocketWriteBuffer:   t.SocketWriteBufferBytes,
 	}
 }
 
 // Run executes one cycle of the snapshot keeper.
 func (k *Keeper) Run(ctx context.Context) error {
+	start := k.clock.Now()
+	k.touch()
+	k.decision = Decision{}
+	k.report = cyclereport.Report{}
+	k.probes.probed.Store(0)
+	k.probes.suitable.Store(0)
+	k.lastCandidateErr = nil
+	k.events.Publish(events.CycleStarted{At: start, Cluster: k.cfg.Cluster.Name})
+
+	cycleCtx, cancel := k.withCycleDeadline(ctx)
+	result, err := k.runCycle(cycleCtx)
+	overran := errors.Is(context.Cause(cycleCtx), errCycleDeadline)
+	cancel(nil)
+	if overran && err != nil {
+		// The cycle's own failure hooks were skipped as the deadline had
+		// passed; run them once, outside the expired budget
+		result = resultFailure
+		err = k.runFailureHooks(ctx, k.decision.Role, fmt.Errorf("%w (%s)", errCycleDeadline, k.cfg.Keeper.MaxCycleDurationDur))
+	}
+	if result == resultSkipped {
+		k.runSkipHooks(ctx)
+	}
+	k.publishDecision(start, result, err)
+	k.writeCycleReport(start, k.clock.Now())
+	k.events.Publish(events.CycleFinished{
+		At:           k.clock.Now(),
+		Duration:     k.clock.Now().Sub(start),
+		Result:       string(result),
+		Reason:       k.decision.Reason,
+		SnapshotSlot: k.decision.SnapshotSlot,
+		Source:       k.decision.Source,
+		Bytes:        k.decision.Bytes,
+		Err:          err,
+	})
+
+	tags := map[string]string{"cluster": k.cfg.Cluster.Name, "result": string(result)}
+	k.metrics.Count("cycle.total", 1, tags)
+	k.metrics.Timing("cycle.duration", k.clock.Now().Sub(start), tags)
+	k.metrics.Flush()
+
+	return err
+}
+
+func (k *Keeper) runCycle(ctx context.Context) (cycleResult, error) {
 	// Step 1: Check identity
+	endRole := k.phase("role")
+	k.refreshActiveIdentity()
+	k.refreshSlotTime(ctx)
 	role, identity, err := k.checkRole(ctx)
+	endRole()
 	if err != nil {
-		return fmt.Errorf("checking role: %w", err)
+		return resultFailure, classify(ErrorRPCUnavailable, fmt.Errorf("checking role: %w", err))
 	}
+	k.decision.Role = role
+	k.decision.Identity = identity
 	if role == "active" {
-		logger().Info("validator is active, skipping snapshot download", "identity", identity)
-		return nil
+		k.logger().Info("validator is active, skipping snapshot download", "identity", identity)
+		k.decision.Reason = "validator is active"
+		return resultSkipped, nil
 	}
 	if identity != "" {
-		logger().Info(fmt.Sprintf("validator is %s", role), "identity", identity)
+		k.logger().Info(fmt.Sprintf("validator is %s", role), "identity", identity)
 	} else {
-		logger().Info("validator is %s", role)
+		k.logger().Info("validator is %s", role)
 	}
 
 	// Step 2: Assess local snapshot freshness
-	currentSlot, err := k.clusterRPC.GetSlot(ctx)
+	endFreshness := k.phase("freshness")
+	defer endFreshness()
+	health, err := k.checkSlots(ctx)
 	if err != nil {
-		return fmt.Errorf("getting current slot: %w", err)
+		return resultFailure, classify(ErrorRPCUnavailable, fmt.Errorf("getting current slot: %w", err))
+	}
+	currentSlot := health.cluster
+
+	k.decision.CurrentSlot = currentSlot
+	k.decision.LocalSlot = health.local
+
+	if k.updateIncident(ctx, role, currentSlot) {
+		k.decision.Reason = "incident mode active"
+		return resultSkipped, nil
 	}
 
 	mode, localFullSlot, err := k.assessFreshness(currentSlot)
 	if err != nil {
-		return fmt.Errorf("assessing freshness: %w", err)
+		return resultFailure, fmt.Errorf("assessing freshness: %w", err)
 	}
 
 	if mode == modeSkip {
-		logger().Info("local snapshots within configured freshness thresholds - nothing to do")
-		return nil
+		k.logger().Info("local snapshots within configured freshness thresholds - nothing to do")
+		k.decision.Reason = "local snapshots within freshness thresholds"
+		return resultSkipped, nil
+	}
+	if k.skipWhileCaughtUp(health) {
+		k.logger().Info("validator is caught up with the cluster and has a local full snapshot - skipping download")
+		k.decision.Reason = "validator caught up with the cluster"
+		return resultSkipped, nil
 	}
+	// Only max_full_slots returns a full download alongside a local full
+	forcedFull := mode == modeFull && localFullSlot > 0
 
-	logger().Debug(fmt.Sprintf("%s download mode determined", mode), "current_slot", currentSlot)
+	k.logger().Debug(fmt.Sprintf("%s download mode determined", mode), "current_slot", currentSlot)
+	endFreshness()
 
 	// Step 3: Discover nodes
+	endDownload := k.phase("download")
+	defer endDownload()
 	clusterNodes, err := k.clusterRPC.GetClusterNodes(ctx)
 	if err != nil {
-		return k.runFailureHooks(ctx, role, fmt.Errorf("getting cluster nodes: %w", err))
+		return resultFailure, k.runFailureHooks(ctx, role, classify(ErrorRPCUnavailable, fmt.Errorf("getting cluster nodes: %w", err)))
 	}
 
-	baseOpts := discovery.Options{
-		MaxLatency:          k.cfg.Snapshots.Discovery.Probe.MaxLatencyDuration,
-		MaxSnapshotAgeSlots: k.cfg.Snapshots.Age.Remote.MaxSlots,
-		ProbeConcurrency:    k.cfg.Snapshots.Discovery.Probe.Concurrency,
-		SortOrder:           k.cfg.Snapshots.Discovery.Candidates.SortOrder,
+	clusterNodes, err = k.trustedNodes(ctx, clusterNodes)
+	if err != nil {
+		return resultFailure, k.runFailureHooks(ctx, role, err)
 	}
+	clusterNodes = k.excludeOwnNodes(ctx, clusterNodes, identity)
+	k.report.Discovery.ClusterNodes = len(clusterNodes)
+	defer k.rememberCandidates()
 
-	var candidates []discovery.SnapshotNode
+	baseOpts := k.discoveryOptions()
+
+	var candidates *discovery.CandidateStream
+
+	epoch := k.epochInfo(ctx)
+	boundaryFull := mode == modeIncremental && k.fullAfterBoundary(epoch, localFullSlot)
+	if boundaryFull {
+		k.logger().Info(fmt.Sprintf("local full snapshot (slot %d) predates epoch %d - looking for a full snapshot from the new epoch", localFullSlot, epoch.Epoch))
+		mode = modeFull
+	}
 
+	// chainBroken is set when a full is only wanted because no incremental
+	// for the local full turned up
+	chainBroken := false
 	if mode == modeIncremental {
 		incOpts := baseOpts
 		incOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableIncremental
-		candidates = discovery.DiscoverIncrementalForBase(ctx, clusterNodes, currentSlot, localFullSlot, incOpts)
-		if len(candidates) == 0 {
-			logger().Info("no matching incrementals found, falling back to full download")
+		candidates = discovery.StreamIncrementalForBase(ctx, clusterNodes, currentSlot, localFullSlot, incOpts)
+		defer candidates.Stop()
+		if _, ok := candidates.Peek(ctx); !ok {
+			k.logger().Info("no matching incrementals found, falling back to full download")
 			mode = modeFull
+			chainBroken = true
 		}
 	}
 
-	// Step 4: Download with speed testing
-	dlOpts := downloader.Options{
-		MinDownloadSpeedBytes: k.cfg.Snapshots.Download.MinSpeedBytes,
-		MinSpeedCheckDelay:    k.cfg.Snapshots.Download.MinSpeedCheckDelayDur,
-		DownloadConnections:   k.cfg.Snapshots.Download.Connections,
-		DownloadTimeout:       k.cfg.Snapshots.Download.TimeoutDur,
+	if mode == modeFull && !boundaryFull && k.deferFullForEpoch(epoch) {
+		k.decision.Mode = string(mode)
+		k.decision.Reason = "full download deferred until after the epoch boundary"
+		return resultSkipped, nil
 	}
 
+	floor := k.improvementFloor(forcedFull || boundaryFull)
+
+	// Step 4: Download with speed testing
+	k.enforceRetention()
+	k.checkDiskThroughput(ctx)
+	dlOpts := k.downloadOptions()
+
 	// Create a cancellable context for mid-download identity monitoring
 	downloadCtx, cancelDownload := context.WithCancel(ctx)
 	defer cancelDownload()
+	if k.cfg.Validator.RoleMonitor.OnActive == config.OnActivePause {
+		k.pause = newRolePause()
+		defer func() { k.pause = nil }()
+	}
 
 	// Monitor identity during download
-	go k.monitorIdentity(downloadCtx, cancelDownload)
+	go k.monitorIdentity(downloadCtx, cancelDownload, k.pause)
 
 	var result *downloader.Result
 	var selectedNode discovery.SnapshotNode
@@ -131,51 +479,83 @@ func (k *Keeper) Run(ctx context.Context) error {
 
 	if mode == modeFull {
 		// Try paired discovery first (full + incremental from same node)
-		pairedResult, pairedNode, pairedErr := k.tryPairedFullDownload(downloadCtx, clusterNodes, currentSlot, localFullSlot, baseOpts, dlOpts)
+		// After an epoch boundary only fulls taken since the boundary are wanted
+		pairedFloor := localFullSlot
+		if boundaryFull {
+			pairedFloor = epoch.FirstSlot() - 1
+		}
+		pairedOpts := baseOpts
+		if chainBroken {
+			pairedOpts.BaseSlots = discovery.NewBaseSlotIndex()
+		}
+		pairedResult, pairedNode, pairedErr := k.tryPairedFullDownload(downloadCtx, clusterNodes, currentSlot, pairedFloor, floor, pairedOpts, dlOpts)
 		if pairedErr == nil {
 			result = pairedResult
 			selectedNode = pairedNode
 			pairedDone = true
+			if pairedNode.SnapshotType == discovery.SnapshotTypeIncremental {
+				mode = modeIncremental
+			}
+		} else if boundaryFull {
+			k.logger().Info("no full snapshot from the new epoch available yet, continuing with incremental download", "error", pairedErr)
+			incOpts := baseOpts
+			incOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableIncremental
+			candidates = discovery.StreamIncrementalForBase(ctx, clusterNodes, currentSlot, localFullSlot, incOpts)
+			defer candidates.Stop()
+			if _, ok := candidates.Peek(ctx); ok {
+				mode = modeIncremental
+				floor = k.improvementFloor(false)
+			}
 		} else {
-			logger().Info("paired discovery failed, falling back to full-only discovery", "error", pairedErr)
+			k.logger().Info("paired discovery failed, falling back to full-only discovery", "error", pairedErr)
 		}
 	}
 
 	if !pairedDone {
+		var fullOpts discovery.Options
 		if mode == modeFull {
-			fullOpts := baseOpts
+			fullOpts = baseOpts
 			fullOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableFull
-			candidates = discovery.DiscoverNodes(ctx, clusterNodes, currentSlot, discovery.SnapshotTypeFull, fullOpts)
+			candidates = discovery.StreamNodes(ctx, clusterNodes, currentSlot, discovery.SnapshotTypeFull, fullOpts)
+			defer candidates.Stop()
 		}
 
-		if len(candidates) == 0 {
-			return k.runFailureHooks(ctx, role, fmt.Errorf("no suitable snapshot nodes found"))
+		var attempted, belowFloor int
+		result, selectedNode, attempted, belowFloor = k.downloadFromCandidates(downloadCtx, candidates, floor, dlOpts)
+		if attempted == 0 {
+			k.recordRejections(mode, candidates.Rejections())
 		}
 
-		for i, candidate := range candidates {
-			logger().Info(fmt.Sprintf("attempting candidate %d of %d", i+1, len(candidates)),
-				"rpc_url", candidate.RPCURL,
-				"slot", candidate.Slot,
-				"latency", candidate.Latency,
-			)
-
-			result, err = downloader.Download(downloadCtx, candidate.SnapshotURL, k.cfg.Snapshots.Directory, candidate.Filename, dlOpts)
-			if err != nil {
-				logger().Warn("candidate failed", "node", candidate.RPCURL, "error", err)
-				continue
+		if attempted == 0 && mode == modeFull {
+			if relaxedOpts, ok := k.nearMissRetryOptions(candidates.Rejections(), fullOpts); ok {
+				if freshSlot, err := k.slots.GetSlot(ctx); err == nil {
+					currentSlot = freshSlot
+				}
+				candidates = discovery.StreamNodes(ctx, clusterNodes, currentSlot, discovery.SnapshotTypeFull, relaxedOpts)
+				defer candidates.Stop()
+				result, selectedNode, attempted, belowFloor = k.downloadFromCandidates(downloadCtx, candidates, floor, dlOpts)
+				if attempted == 0 {
+					k.recordRejections(mode, candidates.Rejections())
+				}
 			}
-
-			selectedNode = candidate
-			break
 		}
 
+		if attempted == 0 && belowFloor > 0 {
+			k.logger().Info(fmt.Sprintf("no snapshot improves on local state by at least %d slots - nothing to do", k.cfg.Snapshots.Download.MinSlotImprovement), "candidates", belowFloor)
+			k.decision.Mode = string(mode)
+			k.decision.Reason = "no snapshot improves on local state by min_slot_improvement"
+			return resultSkipped, nil
+		}
+		if attempted == 0 {
+			return resultFailure, k.runFailureHooks(ctx, role, classify(ErrorNoCandidates, fmt.Errorf("no suitable snapshot nodes found")))
+		}
 		if result == nil {
-			return k.runFailureHooks(ctx, role, fmt.Errorf("all %d candidates failed", len(candidates)))
+			return resultFailure, k.runFailureHooks(ctx, role, candidatesFailed(k.lastCandidateErr, fmt.Errorf("all %d candidates failed", attempted)))
 		}
 	}
 
-	logger().Info(fmt.Sprintf("%s snapshot downloaded successfully", mode),
-		"file", filepath.Join(k.cfg.Snapshots.Directory, selectedNode.Filename),
+	k.logger().Info(fmt.Sprintf("%s snapshot downloaded successfully", mode),
+		"file", filepath.Join(k.destDir(selectedNode), selectedNode.Filename),
 	)
 
 	// Step 5: If we downloaded a full (non-paired), try to get a matching incremental
@@ -185,59 +565,118 @@ func (k *Keeper) Run(ctx context.Context) error {
 		k.tryDownloadIncremental(ctx, clusterNodes, currentSlot, selectedNode.Slot, incOpts, dlOpts)
 	}
 
+	// Step 5b: Chain newer incrementals while the download left us behind
+	if k.cfg.Snapshots.Download.IncrementalChain.Enabled {
+		baseSlot := localFullSlot
+		if mode == modeFull {
+			baseSlot = selectedNode.Slot
+		}
+		newestSlot := selectedNode.Slot
+		if localSnaps, err := k.localSnapshots(); err == nil && len(localSnaps) > 0 {
+			newestSlot = max(newestSlot, pruner.NewestSlot(localSnaps))
+		}
+		incOpts := baseOpts
+		incOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableIncremental
+		// A full cycle keeps reporting (and unpacking) the full
+		if node, chained, ok := k.chainIncrementals(downloadCtx, clusterNodes, baseSlot, newestSlot, incOpts, dlOpts); ok && mode == modeIncremental {
+			selectedNode, result = node, chained
+		}
+	}
+
+	endDownload()
+
+	endUnpack := k.phase("unpack")
+	err = k.unpackFull(ctx, role, selectedNode, result.FilePath)
+	endUnpack()
+	if err != nil {
+		return resultFailure, k.runFailureHooks(ctx, role, err)
+	}
+
 	// Log freshness after all downloads
-	if localSnaps, err := pruner.GetLocalSnapshots(k.cfg.Snapshots.Directory); err == nil && len(localSnaps) > 0 {
+	if localSnaps, err := k.localSnapshots(); err == nil && len(localSnaps) > 0 {
 		newestSlot := pruner.NewestSlot(localSnaps)
 		if currentSlot > newestSlot {
 			behindSlots := currentSlot - newestSlot
-			logger().Info(fmt.Sprintf("latest snapshot behind network by %d slots (%s), target is %d slots (%s)", behindSlots, slotsToTime(behindSlots), uint64(k.cfg.Snapshots.Age.Local.MaxIncrementalSlots), slotsToTime(uint64(k.cfg.Snapshots.Age.Local.MaxIncrementalSlots))))
+			k.metrics.Gauge("snapshot.slots_behind", float64(behindSlots), map[string]string{"cluster": k.cfg.Cluster.Name})
+			k.logger().Info(fmt.Sprintf("latest snapshot behind network by %d slots (%s), target is %d slots (%s)", behindSlots, k.slotsToTime(behindSlots), k.maxIncrementalSlots(), k.slotsToTime(k.maxIncrementalSlots())))
 		}
 	}
 
 	// Step 6: Prune old snapshots
-	if err := pruner.Prune(k.cfg.Snapshots.Directory); err != nil {
-		logger().Error("pruning failed", "error", err)
+	endPrune := k.phase("prune")
+	if err := k.Prune(); err != nil {
+		k.logger().Error("pruning failed", "error", err)
 	}
+	k.enforceRetention()
+	endPrune()
+
+	k.decision.Mode = string(mode)
+	k.decision.Reason = fmt.Sprintf("downloaded %s snapshot", mode)
+	if result.Existing {
+		k.decision.Reason = fmt.Sprintf("%s snapshot already downloaded", mode)
+	}
+	k.decision.SnapshotSlot = selectedNode.Slot
+	k.decision.Source = selectedNode.RPCURL
+	k.reportCandidate(selectedNode)
 
 	// Step 7: Run success hooks
 	hookData := hooks.TemplateData{
-		SnapshotSlot:    fmt.Sprintf("%d", selectedNode.Slot),
-		SnapshotType:    string(mode),
-		SourceNode:      selectedNode.RPCURL,
-		DownloadTimeSec: int(result.DurationSecs),
-		DownloadSizeMB:  int(result.Bytes / (1024 * 1024)),
-		SnapshotPath:    result.FilePath,
-		ClusterName:     k.cfg.Cluster.Name,
-		ValidatorRole:   role,
+		Event:            hooks.EventSuccess,
+		SnapshotSlot:     fmt.Sprintf("%d", selectedNode.Slot),
+		SnapshotType:     string(mode),
+		SourceNode:       selectedNode.RPCURL,
+		DownloadTimeSec:  int(result.DurationSecs),
+		DownloadSizeMB:   int(result.Bytes / (1024 * 1024)),
+		SnapshotPath:     result.FilePath,
+		ClusterName:      k.cfg.Cluster.Name,
+		Validator:        k.cfg.Name,
+		ValidatorRole:    role,
+		LocalSlotsBehind: k.decision.localSlotsBehind(),
+		Identity:         k.decision.Identity,
 	}
 
-	if err := hooks.RunHooks(ctx, k.cfg.Hooks.OnSuccess, hookData); err != nil {
-		logger().Error("success hooks failed", "error", err)
+	endHooks := k.phase("hooks")
+	if err := k.runHooks(ctx, k.cfg.Hooks.OnSuccess, hookData); err != nil {
+		k.logger().Error("success hooks failed", "error", err)
 	}
+	endHooks()
 
-	return nil
+	return resultSuccess, nil
 }
 
 func (k *Keeper) checkRole(ctx context.Context) (string, string, error) {
-	identity, err := k.localRPC.GetIdentity(ctx)
+	identity, method, err := k.validatorIdentity(ctx)
 	if err != nil {
-		logger().Warn("local RPC unreachable, assuming validator is down", "error", err)
+		k.logger().Warn("local RPC unreachable, assuming validator is down", "client", k.client.Name(), "error", err)
 		return "unknown", "", nil
 	}
-	if identity == k.cfg.Validator.ActiveIdentityPubkey {
+	if method != identityViaRPC {
+		k.logger().Info("local RPC unreachable - identity read via "+method, "client", k.client.Name(), "identity", identity)
+	}
+	if k.isActiveIdentity(identity) {
 		return "active", identity, nil
 	}
+	// The command line misses a set-identity since the validator started, so
+	// it can't tell a promoted validator from a passive one
+	if method == identityViaProcess {
+		k.logger().Warn("identity read from the validator's command line may predate a set-identity - role unknown", "identity", identity)
+		return "unknown", identity, nil
+	}
 	return "passive", identity, nil
 }
 
 func (k *Keeper) assessFreshness(currentSlot uint64) (downloadMode, uint64, error) {
-	snapshots, err := pruner.GetLocalSnapshots(k.cfg.Snapshots.Directory)
+	if k.ledgerFresh(currentSlot) {
+		return modeSkip, 0, nil
+	}
+
+	snapshots, err := k.localSnapshots()
 	if err != nil {
 		return modeFull, 0, nil // if we can't read, just do a full download
 	}
 
 	if len(snapshots) == 0 {
-		logger().Info("no local snapshots found")
+		k.logger().Info("no local snapshots found")
 		return modeFull, 0, nil
 	}
 
@@ -245,13 +684,21 @@ func (k *Keeper) assessFreshness(currentSlot uint64) (downloadMode, uint64, erro
 	newestFull := pruner.NewestFullSnapshot(snapshots)
 
 	if newestSlot >= currentSlot {
-		logger().Info("local snapshot is at or ahead of current slot", "local", newestSlot, "current", currentSlot)
+		k.logger().Info("local snapshot is at or ahead of current slot", "local", newestSlot, "current", currentSlot)
 		return modeSkip, 0, nil
 	}
 
+	// An ancient full makes for a long incremental chain, however fresh
+	if maxFull := uint64(k.slotSetting(&k.cfg.Snapshots.Age.Local.MaxFullSlots)); maxFull > 0 && newestFull != nil && currentSlot > newestFull.Slot {
+		if fullAge := currentSlot - newestFull.Slot; fullAge > maxFull {
+			k.logger().Info(fmt.Sprintf("local full snapshot behind network by %d slots (%s), max is %d slots (%s) - downloading a new full", fullAge, k.slotsToTime(fullAge), maxFull, k.slotsToTime(maxFull)))
+			return modeFull, newestFull.Slot, nil
+		}
+	}
+
 	age := currentSlot - newestSlot
-	skipThreshold := uint64(k.cfg.Snapshots.Age.Local.MaxIncrementalSlots)
-	logger().Info(fmt.Sprintf("local snapshot behind network by %d slots (%s), target is %d slots (%s)", age, slotsToTime(age), skipThreshold, slotsToTime(skipThreshold)))
+	skipThreshold := k.maxIncrementalSlots()
+	k.logger().Info(fmt.Sprintf("local snapshot behind network by %d slots (%s), target is %d slots (%s)", age, k.slotsToTime(age), skipThreshold, k.slotsToTime(skipThreshold)))
 
 	if age <= skipThreshold {
 		return modeSkip, 0, nil
@@ -260,77 +707,152 @@ func (k *Keeper) assessFreshness(currentSlot uint64) (downloadMode, uint64, erro
 	// If we have a local full, try incremental first — Run() handles fallback to paired/full
 	if newestFull != nil {
 		fullAge := currentSlot - newestFull.Slot
-		logger().Info(fmt.Sprintf("local full snapshot behind network by %d slots (%s) - attempting incremental download", fullAge, slotsToTime(fullAge)))
+		k.logger().Info(fmt.Sprintf("local full snapshot behind network by %d slots (%s) - attempting incremental download", fullAge, k.slotsToTime(fullAge)))
 		return modeIncremental, newestFull.Slot, nil
 	}
 
 	return modeFull, 0, nil
 }
 
-func (k *Keeper) monitorIdentity(ctx context.Context, cancel context.CancelFunc) {
-	ticker := time.NewTicker(30 * time.Second)
-	defer ticker.Stop()
+// trustedNodes applies snapshots.discovery.trust, fetching vote account
+// stakes when a minimum stake is configured. Without stakes the filter can't
+// be enforced, so that is an error rather than falling back to every node.
+func (k *Keeper) trustedNodes(ctx context.Context, nodes []rpc.ClusterNode) ([]rpc.ClusterNode, error) {
+	trust := k.cfg.Snapshots.Discovery.Trust
+	filter := discovery.TrustFilter{
+		KnownValidators: trust.KnownValidators,
+		MinStake:        trust.MinStakeLamports,
+	}
+	if filter.MinStake > 0 {
+		accounts, err := k.clusterRPC.GetVoteAccounts(ctx)
+		if err != nil {
+			return nil, fmt.Errorf("getting vote accounts for stake filter: %w", err)
+		}
+		filter.Stakes = discovery.StakesByNode(accounts)
+	}
+	return discovery.FilterTrustedNodes(nodes, filter), nil
+}
 
-	for {
-		select {
-		case <-ctx.Done():
-			return
-		case <-ticker.C:
-			identity, err := k.localRPC.GetIdentity(ctx)
-			if err != nil {
-				continue // RPC might be temporarily unavailable
-			}
-			if identity == k.cfg.Validator.ActiveIdentityPubkey {
-				logger().Warn("validator became active during download, aborting")
-				cancel()
-				return
+// excludeOwnNodes applies snapshots.discovery.exclude: with self, the
+// active identity, the validator's current identity and this machine's
+// interface addresses; and the configured hosts, with hostnames resolved
+// each cycle. A lookup that fails only loses that part of the exclusion.
+func (k *Keeper) excludeOwnNodes(ctx context.Context, nodes []rpc.ClusterNode, identity string) []rpc.ClusterNode {
+	ex := k.cfg.Snapshots.Discovery.Exclude
+	e := discovery.Exclusions{
+		Prefixes:  slices.Clone(ex.Prefixes),
+		Hostnames: ex.Hostnames,
+	}
+	for _, host := range ex.Hostnames {
+		addrs, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			k.logger().Warn("resolving excluded host failed - matching it by name only", "host", host, "error", err)
+			continue
+		}
+		for _, a := range addrs {
+			a = a.Unmap()
+			e.Prefixes = append(e.Prefixes, netip.PrefixFrom(a, a.BitLen()))
+		}
+	}
+	if ex.Self {
+		for _, pk := range append(k.activeIdentities(), identity) {
+			if pk != "" && !slices.Contains(e.Pubkeys, pk) {
+				e.Pubkeys = append(e.Pubkeys, pk)
 			}
 		}
+		local, err := discovery.LocalPrefixes()
+		if err != nil {
+			k.logger().Warn("excluding own nodes by address failed", "error", err)
+		}
+		e.Prefixes = append(e.Prefixes, local...)
 	}
+	return discovery.ExcludeNodes(nodes, e)
 }
 
-func (k *Keeper) tryPairedFullDownload(ctx context.Context, clusterNodes []rpc.ClusterNode, currentSlot uint64, localFullSlot uint64, opts discovery.Options, dlOpts downloader.Options) (*downloader.Result, discovery.SnapshotNode, error) {
+// tryPairedFullDownload downloads a full and its incremental from the same
+// node. With opts.BaseSlots set, an incremental for localFullSlot that the
+// paired probes found is tried first, and returned instead if it downloads.
+func (k *Keeper) tryPairedFullDownload(ctx context.Context, clusterNodes []rpc.ClusterNode, currentSlot uint64, localFullSlot uint64, floor uint64, opts discovery.Options, dlOpts downloader.Options) (*downloader.Result, discovery.SnapshotNode, error) {
 	pairedOpts := opts
 	pairedOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableFull
+	if pairedOpts.BaseSlots == nil {
+		// Always index, for sort order availability
+		pairedOpts.BaseSlots = discovery.NewBaseSlotIndex()
+	}
 
 	paired := discovery.DiscoverPairedNodes(ctx, clusterNodes, currentSlot, pairedOpts)
+	if result, node, ok := k.downloadIndexedIncremental(ctx, opts.BaseSlots, localFullSlot, floor, dlOpts); ok {
+		return result, node, nil
+	}
 	if len(paired) == 0 {
 		return nil, discovery.SnapshotNode{}, fmt.Errorf("no paired snapshot nodes found")
 	}
 
+	availability := pairedOpts.BaseSlots.Availability()
+	pairedOpts.OrderByAvailability(paired, availability)
+	if pairedOpts.SortOrder == discovery.SortAvailability && len(availability) > 1 {
+		top := availability[0]
+		k.logger().Info("targeting the most widely served full snapshot",
+			"full_slot", top.Slot,
+			"sources", top.Sources,
+			"newest_incremental_slot", top.NewestIncrementalSlot,
+			"full_slots_seen", len(availability),
+		)
+	}
+
 	for i, candidate := range paired {
 		// Skip candidates whose full is older than or equal to what we already have locally
 		candidateString := fmt.Sprintf("paired candidate %d of %d", i+1, len(paired))
 		if localFullSlot > 0 && candidate.Full.Slot <= localFullSlot {
-			logger().Info(fmt.Sprintf("skipping %s - local slot %d (us) >= remote slot %d (them)", candidateString, localFullSlot, candidate.Full.Slot))
+			k.logger().Info(fmt.Sprintf("skipping %s - local slot %d (us) >= remote slot %d (them)", candidateString, localFullSlot, candidate.Full.Slot))
+			continue
+		}
+		if candidate.Incremental.Slot < floor {
+			k.logger().Info(fmt.Sprintf("skipping %s - slot %d gains less than %d slots over local state", candidateString, candidate.Incremental.Slot, k.cfg.Snapshots.Download.MinSlotImprovement))
+			continue
+		}
+		if k.skipCoolingDown(candidate.Full.RPCURL) {
 			continue
 		}
 
-		logger().Info(fmt.Sprintf("trying %s", candidateString),
+		k.logger().Info(fmt.Sprintf("trying %s", candidateString),
 			"rpc_url", candidate.Full.RPCURL,
 			"full_slot", candidate.Full.Slot,
 			"incremental_slot", candidate.Incremental.Slot, "latency", candidate.Full.Latency,
 		)
 
 		// Download full snapshot
-		fullResult, err := downloader.Download(ctx, candidate.Full.SnapshotURL, k.cfg.Snapshots.Directory, candidate.Full.Filename, dlOpts)
+		var rivals []discovery.SnapshotNode
+		for _, p := range paired[i+1:] {
+			rivals = append(rivals, p.Full)
+		}
+		fullResult, full, err := k.downloadFull(ctx, candidate.Full, rivals, dlOpts)
 		if err != nil {
-			logger().Warn(fmt.Sprintf("%s full download failed", candidateString), "error", err)
+			k.logger().Warn(fmt.Sprintf("%s full download failed", candidateString), "error", err)
 			continue
 		}
 
-		logger().Info(fmt.Sprintf("%s full snapshot downloaded", candidateString),
-			"slot", candidate.Full.Slot,
+		k.logger().Info(fmt.Sprintf("%s full snapshot downloaded", candidateString),
+			"slot", full.Slot,
 			"size", formatBytes(fullResult.Bytes),
 		)
 
+		// Switched to a newer full, which this node's incremental doesn't build on
+		if full.Slot != candidate.Full.Slot {
+			incOpts := opts
+			incOpts.MinSuitable = k.cfg.Snapshots.Discovery.Candidates.MinSuitableIncremental
+			incOpts.BaseSlots = nil
+			k.tryDownloadIncremental(ctx, clusterNodes, currentSlot, full.Slot, incOpts, dlOpts)
+			return fullResult, full, nil
+		}
+
 		// Download incremental snapshot from the same node
-		_, incrErr := downloader.Download(ctx, candidate.Incremental.SnapshotURL, k.cfg.Snapshots.Directory, candidate.Incremental.Filename, dlOpts)
+		_, incrErr := k.download(ctx, candidate.Incremental, dlOpts)
 		if incrErr != nil {
-			logger().Warn(fmt.Sprintf("%s incremental download failed, full snapshot still usable", candidateString),
+			k.logger().Warn(fmt.Sprintf("%s incremental download failed, full snapshot still usable", candidateString),
 				"rpc_url", candidate.Incremental.RPCURL, "error", incrErr)
 		} else {
-			logger().Info(fmt.Sprintf("%s incremental snapshot downloaded", candidateString),
+			k.logger().Info(fmt.Sprintf("%s incremental snapshot downloaded", candidateString),
 				"slot", candidate.Incremental.Slot,
 				"base_slot", candidate.Incremental.BaseSlot,
 			)
@@ -342,50 +864,370 @@ func (k *Keeper) tryPairedFullDownload(ctx context.Context, clusterNodes []rpc.C
 	return nil, discovery.SnapshotNode{}, fmt.Errorf("all %d paired candidates failed", len(paired))
 }
 
+// downloadIndexedIncremental downloads an incremental for the local full
+// that paired probes found on any node, sparing a full download when no
+// matching incremental turned up before. It returns false if there is none,
+// or every one failed.
+func (k *Keeper) downloadIndexedIncremental(ctx context.Context, index *discovery.BaseSlotIndex, baseSlot uint64, floor uint64, dlOpts downloader.Options) (*downloader.Result, discovery.SnapshotNode, bool) {
+	nodes := index.Incrementals(baseSlot)
+	if baseSlot == 0 || len(nodes) == 0 {
+		return nil, discovery.SnapshotNode{}, false
+	}
+	k.logger().Info(fmt.Sprintf("paired probes found %d incrementals for local full slot %d - trying them before a full download", len(nodes), baseSlot))
+
+	maxCandidates := 3 // a full download remains the fallback
+	for attempted, i := 0, 0; i < len(nodes) && attempted < maxCandidates; i++ {
+		candidate := nodes[i]
+		if candidate.Slot < floor || k.skipCoolingDown(candidate.RPCURL) || k.skipHaveSlot(candidate) {
+			continue
+		}
+		attempted++
+		result, err := k.download(ctx, candidate, k.withMirrors(dlOpts, candidate, nodes))
+		if err != nil {
+			k.logger().Warn("indexed incremental download failed", "rpc_url", candidate.RPCURL, "error", err)
+			continue
+		}
+		return result, candidate, true
+	}
+	return nil, discovery.SnapshotNode{}, false
+}
+
 func (k *Keeper) tryDownloadIncremental(ctx context.Context, clusterNodes []rpc.ClusterNode, currentSlot uint64, baseSlot uint64, discoveryOpts discovery.Options, dlOpts downloader.Options) {
-	logger().Info("looking for incremental snapshot", "base_slot", baseSlot)
+	k.logger().Info("looking for incremental snapshot", "base_slot", baseSlot)
 
-	candidates := discovery.DiscoverIncrementalForBase(ctx, clusterNodes, currentSlot, baseSlot, discoveryOpts)
-	if len(candidates) == 0 {
-		logger().Info("no matching incremental snapshots available")
+	candidates := discovery.StreamIncrementalForBase(ctx, clusterNodes, currentSlot, baseSlot, discoveryOpts)
+	defer candidates.Stop()
+	if _, ok := candidates.Peek(ctx); !ok {
+		k.logger().Info("no matching incremental snapshots available")
 		return
 	}
 
 	maxCandidates := 3 // don't try too many for the optional incremental
-	if maxCandidates > len(candidates) {
-		maxCandidates = len(candidates)
-	}
 
-	for i := 0; i < maxCandidates; i++ {
-		candidate := candidates[i]
-		_, err := downloader.Download(ctx, candidate.SnapshotURL, k.cfg.Snapshots.Directory, candidate.Filename, dlOpts)
+	for attempted := 0; attempted < maxCandidates; {
+		candidate, ok := candidates.Next(ctx)
+		if !ok {
+			break
+		}
+		if k.skipCoolingDown(candidate.RPCURL) || k.skipHaveSlot(candidate) {
+			continue
+		}
+		attempted++
+		_, err := k.download(ctx, candidate, k.withMirrors(dlOpts, candidate, candidates.PendingNodes()))
 		if err != nil {
-			logger().Warn("incremental download failed", "node", candidate.RPCURL, "error", err)
+			k.logger().Warn("incremental download failed", "node", candidate.RPCURL, "error", err)
 			continue
 		}
-		logger().Info("incremental snapshot downloaded", "slot", candidate.Slot, "base_slot", candidate.BaseSlot)
+		k.logger().Info("incremental snapshot downloaded", "slot", candidate.Slot, "base_slot", candidate.BaseSlot)
 		return
 	}
 
-	logger().Info("could not download incremental snapshot, full snapshot is still available")
+	k.logger().Info("could not download incremental snapshot, full snapshot is still available")
+}
+
+// downloadFromCandidates tries candidates from the stream in order until one
+// downloads successfully. It returns the number of candidates attempted.
+func (k *Keeper) downloadFromCandidates(ctx context.Context, candidates *discovery.CandidateStream, floor uint64, dlOpts downloader.Options) (*downloader.Result, discovery.SnapshotNode, int, int) {
+	attempted, belowFloor := 0, 0
+	for {
+		candidate, ok := candidates.Next(ctx)
+		if !ok {
+			return nil, discovery.SnapshotNode{}, attempted, belowFloor
+		}
+		if candidate.Slot < floor {
+			k.logger().Debug("skipping candidate below min_slot_improvement", "node", candidate.RPCURL, "slot", candidate.Slot, "min_slot", floor)
+			belowFloor++
+			continue
+		}
+		if k.skipCoolingDown(candidate.RPCURL) || k.skipHaveSlot(candidate) {
+			continue
+		}
+		attempted++
+		k.touch()
+
+		k.logger().Info(fmt.Sprintf("attempting candidate %d", attempted),
+			"rpc_url", candidate.RPCURL,
+			"slot", candidate.Slot,
+			"latency", candidate.Latency,
+			"pending", candidates.Pending(),
+		)
+
+		result, node, err := k.downloadFull(ctx, candidate, candidates.PendingNodes(), dlOpts)
+		if err != nil {
+			k.logger().Warn("candidate failed", "node", node.RPCURL, "error", err)
+			k.lastCandidateErr = err
+			continue
+		}
+		// Background probes were only needed in case this candidate failed
+		candidates.Stop()
+		return result, node, attempted, belowFloor
+	}
+}
+
+// improvementFloor returns the lowest snapshot slot worth downloading: the
+// newest local slot plus snapshots.download.min_slot_improvement. Replacing
+// an outdated local full (forced) is worthwhile regardless.
+func (k *Keeper) improvementFloor(forced bool) uint64 {
+	minGain := k.cfg.Snapshots.Download.MinSlotImprovement
+	if minGain <= 0 || forced {
+		return 0
+	}
+	snapshots, err := k.localSnapshots()
+	if err != nil || len(snapshots) == 0 {
+		return 0
+	}
+	return pruner.NewestSlot(snapshots) + uint64(minGain)
+}
+
+// nearMissRetryOptions decides whether a discovery pass that found nothing
+// should be retried with a relaxed max slot age. That is only the case when
+// every suitable-looking node was rejected as too old, and the freshest of
+// them would pass with snapshots.age.remote.near_miss_factor applied.
+func (k *Keeper) nearMissRetryOptions(rejections discovery.RejectionSummary, opts discovery.Options) (discovery.Options, bool) {
+	factor := k.cfg.Snapshots.Age.Remote.NearMissFactor
+	if factor <= 1 || rejections.TooOld == 0 || rejections.TooOldMinSlots == 0 {
+		return opts, false
+	}
+
+	relaxed := int(float64(opts.MaxSnapshotAgeSlots) * factor)
+	if rejections.TooOldMinSlots > uint64(relaxed) {
+		k.logger().Info(fmt.Sprintf("freshest rejected snapshot is %d slots old (%s), beyond relaxed max of %d slots - not retrying", rejections.TooOldMinSlots, k.slotsToTime(rejections.TooOldMinSlots), relaxed))
+		return opts, false
+	}
+
+	k.logger().Warn(fmt.Sprintf("all candidates were near misses - retrying once with max slot age relaxed from %d to %d slots (%s)", opts.MaxSnapshotAgeSlots, relaxed, k.slotsToTime(uint64(relaxed))),
+		"freshest_rejected_slots", rejections.TooOldMinSlots,
+		"too_old", rejections.TooOld,
+		"factor", factor,
+	)
+
+	opts.MaxSnapshotAgeSlots = relaxed
+	return opts, true
+}
+
+// recordRejections records why a discovery pass that yielded no candidate
+// rejected the probed nodes, in the cycle's decision and metrics.
+func (k *Keeper) recordRejections(mode downloadMode, rejections discovery.RejectionSummary) {
+	k.decision.Rejections = &rejections
+	k.countRejections(discovery.SnapshotType(mode), rejections)
+}
+
+// countRejections emits the discovery.rejected metric, tagged by reason.
+func (k *Keeper) countRejections(snapshotType discovery.SnapshotType, rejections discovery.RejectionSummary) {
+	for reason, n := range map[string]int64{
+		"http_error":  rejections.HTTPError,
+		"latency":     rejections.Latency,
+		"status_code": rejections.StatusCode,
+		"parse_fail":  rejections.ParseFail,
+		"too_old":     rejections.TooOld,
+		"unhealthy":   rejections.Unhealthy,
+		"version":     rejections.Version,
+	} {
+		if n > 0 {
+			k.metrics.Count("discovery.rejected", n, map[string]string{"cluster": k.cfg.Cluster.Name, "type": string(snapshotType), "reason": reason})
+		}
+	}
+}
+
+// download fetches a candidate's snapshot into the snapshots directory and
+// records download metrics.
+func (k *Keeper) download(ctx context.Context, node discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, error) {
+	tags := map[string]string{"cluster": k.cfg.Cluster.Name, "type": string(node.SnapshotType)}
+	k.events.Publish(events.CandidateSelected{
+		At:          k.clock.Now(),
+		Source:      node.RPCURL,
+		SnapshotURL: node.SnapshotURL,
+		Type:        string(node.SnapshotType),
+		Slot:        node.Slot,
+	})
+
+	k.report.Discovery.Attempted++
+
+	signed, err := k.signedEntry(ctx, node)
+	if err != nil {
+		return nil, classify(ErrorVerificationFailed, err)
+	}
+
+	// Archives are checked before they are renamed into the snapshot
+	// directory, so the validator never sees one that fails
+	dlOpts.Verify = func(ctx context.Context, path string) error {
+		defer k.busy(ctx)()
+		err := k.verifyContentHash(ctx, node, path)
+		if err == nil {
+			err = k.verifyAttestation(ctx, node, path)
+		}
+		if err == nil {
+			err = k.verifySigned(ctx, node, signed, path)
+		}
+		return classify(ErrorVerificationFailed, err)
+	}
+	result, err := k.fetchAroundLeaderSlots(ctx, node, dlOpts)
+	if err != nil {
+		k.metrics.Count("download.failed", 1, tags)
+		if cooldown := k.cfg.Snapshots.Download.FailureCooldownDur; cooldown > 0 && sourceAtFault(ctx, err) {
+			k.cooldowns.record(node.RPCURL, k.clock.Now(), cooldown)
+			k.logger().Info(fmt.Sprintf("source on cooldown for %s after failed download", cooldown), "node", node.RPCURL)
+		}
+		if sourceAtFault(ctx, err) {
+			k.recordReputation(node.RPCURL, false)
+		}
+		return nil, err
+	}
+	k.recordReputation(node.RPCURL, true)
+	if result.Existing {
+		k.metrics.Count("download.deduplicated", 1, tags)
+		k.reportDownload(node, result)
+		return result, nil
+	}
+	k.auditLog.Record(audit.Event{
+		Action: audit.ActionDownload,
+		Path:   result.FilePath,
+		Source: node.SnapshotURL,
+		Bytes:  result.Bytes,
+		Reason: fmt.Sprintf("%s snapshot for slot %d", node.SnapshotType, node.Slot),
+	})
+
+	// Like ownership, the original archive is still usable if this fails
+	if rc := k.cfg.Snapshots.Recompress; rc.Enabled && recompress.Needed(result.FilePath) {
+		done := k.busy(ctx)
+		path, err := recompress.ToZstd(ctx, result.FilePath, rc.Level)
+		done()
+		if err != nil {
+			k.logger().Error("failed to recompress snapshot to zstd, keeping the original archive", "file", result.FilePath, "error", err)
+		} else {
+			k.auditLog.Record(audit.Event{Action: audit.ActionRename, Path: path, From: result.FilePath, Reason: "recompressed to zstd"})
+			result.FilePath = path
+		}
+	}
+
+	// The snapshot is usable by the keeper either way, so don't fail the download
+	if opts := k.cfg.Snapshots.Ownership.Parsed; opts.Enabled() {
+		if err := ownership.Apply(result.FilePath, opts); err != nil {
+			k.logger().Error("failed to set snapshot ownership - the validator may not be able to read it", "file", result.FilePath, "error", err)
+		}
+	}
+
+	k.decision.Bytes += result.Bytes
+	k.reportDownload(node, result)
+	k.metrics.Count("download.completed", 1, tags)
+	k.metrics.Gauge("download.bytes", float64(result.Bytes), tags)
+	k.metrics.Gauge("download.speed_bps", float64(result.SpeedBps), tags)
+	k.metrics.Timing("download.duration", time.Duration(result.DurationSecs*float64(time.Second)), tags)
+	if result.ReusedBytes > 0 {
+		k.metrics.Gauge("download.delta_reused_bytes", float64(result.ReusedBytes), tags)
+	}
+	return result, nil
+}
+
+// sourceAtFault reports whether a failed download counts against its source.
+// A cancelled context (shutdown, validator became active), leader windows,
+// trust endpoint problems and missing signed manifests aren't the source's
+// fault.
+func sourceAtFault(ctx context.Context, err error) bool {
+	return ctx.Err() == nil &&
+		!errors.Is(err, errLeaderWindow) &&
+		!errors.Is(err, attestation.ErrUnavailable) &&
+		!errors.Is(err, attestation.ErrUnsigned) &&
+		!errors.Is(err, errNotAttested)
+}
+
+// fetch downloads a candidate's snapshot, as a delta when possible.
+func (k *Keeper) fetch(ctx context.Context, node discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, error) {
+	var result *downloader.Result
+	var err error
+	// Download keeps an archive that is already there
+	if !k.haveArchive(node) {
+		result, err = k.downloadDelta(ctx, node, dlOpts)
+	}
+	if result == nil && err == nil {
+		result, err = downloader.Download(ctx, node.SnapshotURL, k.destDir(node), node.Filename, dlOpts)
+	}
+	return result, err
+}
+
+// downloadDelta tries a delta download of an incremental against the newest
+// local incremental for the same full snapshot. A nil result means the
+// caller should download the whole archive.
+func (k *Keeper) downloadDelta(ctx context.Context, node discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, error) {
+	if !k.cfg.Snapshots.Download.Delta || node.SnapshotType != discovery.SnapshotTypeIncremental {
+		return nil, nil
+	}
+	snapshots, err := k.localSnapshots()
+	if err != nil {
+		return nil, nil
+	}
+	var basis *pruner.SnapshotFile
+	for i, s := range snapshots {
+		if !s.IsFull && s.BaseSlot == node.BaseSlot && s.Slot < node.Slot && (basis == nil || s.Slot > basis.Slot) {
+			basis = &snapshots[i]
+		}
+	}
+	if basis == nil {
+		return nil, nil
+	}
+
+	result, err := downloader.DownloadDelta(ctx, node.SnapshotURL, k.destDir(node), node.Filename, basis.Path, dlOpts)
+	switch {
+	case err == nil:
+		return result, nil
+	case errors.Is(err, delta.ErrNoSignature):
+		k.logger().Debug("source publishes no delta signature, downloading whole archive", "url", node.SnapshotURL)
+	case ctx.Err() != nil:
+		return nil, err
+	default:
+		k.logger().Warn("delta download failed, downloading whole archive", "url", node.SnapshotURL, "error", err)
+	}
+	return nil, nil
 }
 
 func (k *Keeper) runFailureHooks(ctx context.Context, role string, originalErr error) error {
-	logger().Error("snapshot cycle failed", "error", originalErr)
+	if errors.Is(context.Cause(ctx), errCycleDeadline) {
+		return originalErr // Run reports the overrun instead
+	}
+	k.logger().Error("snapshot cycle failed", "error", originalErr)
 
 	hookData := hooks.TemplateData{
-		ClusterName:   k.cfg.Cluster.Name,
-		ValidatorRole: role,
-		Error:         originalErr.Error(),
+		Event:            hooks.EventFailure,
+		ClusterName:      k.cfg.Cluster.Name,
+		Validator:        k.cfg.Name,
+		ValidatorRole:    role,
+		Error:            originalErr.Error(),
+		ErrorKind:        string(KindOf(originalErr)),
+		LocalSlotsBehind: k.decision.localSlotsBehind(),
+		Identity:         k.decision.Identity,
 	}
 
-	if err := hooks.RunHooks(ctx, k.cfg.Hooks.OnFailure, hookData); err != nil {
-		logger().Error("failure hooks failed", "error", err)
+	if err := k.runHooks(ctx, k.cfg.Hooks.OnFailure, hookData); err != nil {
+		k.logger().Error("failure hooks failed", "error", err)
 	}
 
 	return originalErr
 }
 
+// runSkipHooks runs on_skip hooks for a cycle that had nothing to do.
+func (k *Keeper) runSkipHooks(ctx context.Context) {
+	if len(k.cfg.Hooks.OnSkip) == 0 {
+		return
+	}
+	hookData := hooks.TemplateData{
+		Event:            hooks.EventSkip,
+		ClusterName:      k.cfg.Cluster.Name,
+		Validator:        k.cfg.Name,
+		ValidatorRole:    k.decision.Role,
+		SkipReason:       k.decision.Reason,
+		LocalSlotsBehind: k.decision.localSlotsBehind(),
+		Identity:         k.decision.Identity,
+	}
+	if err := k.runHooks(ctx, k.cfg.Hooks.OnSkip, hookData); err != nil {
+		k.logger().Error("skip hooks failed", "error", err)
+	}
+}
+
+// runHooks runs cmds with hook notifications posted over k.hookTransport.
+func (k *Keeper) runHooks(ctx context.Context, cmds []config.HookCommand, data hooks.TemplateData) error {
+	return hooks.RunHooksWithOptions(ctx, cmds, data, hooks.Options{Transport: k.hookTransport})
+}
+
 func formatBytes(b int64) string {
 	switch {
 	case b >= 1024*1024*1024:
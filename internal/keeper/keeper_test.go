@@ -2,18 +2,37 @@ package keeper
 
 import (
 	"context"
+	"crypto/ed25519"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
 	"encoding/json"
+	"errors"
 	"fmt"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"os"
 	"path/filepath"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
+	"sync/atomic"
+	"syscall"
 	"testing"
 	"time"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/attestation"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/clock"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/cyclereport"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/events"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
 )
 
 // rpcServer creates a test JSON-RPC server with configurable responses.
@@ -75,6 +94,7 @@ func TestRun_ActiveValidator_Skips(t *testing.T) {
 	defer localRPC.Close()
 
 	snapshotDir := t.TempDir()
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
 	cfg := &config.Config{
 		Validator: config.Validator{
 			RPCURL:              localRPC.URL,
@@ -82,6 +102,11 @@ func TestRun_ActiveValidator_Skips(t *testing.T) {
 		},
 		Cluster:  config.Cluster{Name: "testnet", RPCURL: localRPC.URL},
 		Snapshots: config.Snapshots{Directory: snapshotDir},
+		Hooks: config.Hooks{OnSkip: []config.HookCommand{{
+			Name: "skip",
+			Cmd:  "sh",
+			Args: []string{"-c", "echo '{{ .Event }}: {{ .SkipReason }}' >> " + hookLog},
+		}}},
 	}
 
 	k := New(cfg)
@@ -90,6 +115,146 @@ func TestRun_ActiveValidator_Skips(t *testing.T) {
 		t.Fatal(err)
 	}
 	// Should have returned early without error (skipping)
+	d, ok := k.LastDecision()
+	if !ok || d.Result != "skipped" || d.Reason != "validator is active" || d.Role != "active" {
+		t.Errorf("unexpected decision: %+v", d)
+	}
+	data, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "skip: validator is active" {
+		t.Errorf("expected the on_skip hook to see the skip reason, got %q", got)
+	}
+}
+
+func TestRun_ActiveIdentitySet_Skips(t *testing.T) {
+	localRPC := rpcServer(t, "BackupPubkey", 100000, nil)
+	defer localRPC.Close()
+
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+	cfg := &config.Config{
+		Validator: config.Validator{
+			RPCURL:                localRPC.URL,
+			ActiveIdentityPubkey:  "PrimaryPubkey",
+			ActiveIdentityPubkeys: []string{"BackupPubkey"},
+		},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: localRPC.URL},
+		Snapshots: config.Snapshots{Directory: t.TempDir()},
+		Hooks: config.Hooks{OnSkip: []config.HookCommand{{
+			Name: "skip",
+			Cmd:  "sh",
+			Args: []string{"-c", "echo '{{ .ValidatorRole }} as {{ .Identity }}' >> " + hookLog},
+		}}},
+	}
+
+	k := New(cfg)
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	d, ok := k.LastDecision()
+	if !ok || d.Role != "active" || d.Identity != "BackupPubkey" {
+		t.Errorf("expected the validator to count as active with BackupPubkey, got %+v", d)
+	}
+	data, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != "active as BackupPubkey" {
+		t.Errorf("expected the on_skip hook to see the matched identity, got %q", got)
+	}
+}
+
+func TestRun_RoleFallback_AdminRPC(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "admin.rpc")
+	ln, err := net.Listen("unix", socket)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	defer ln.Close()
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var req struct {
+				Method string `json:"method"`
+			}
+			json.NewDecoder(conn).Decode(&req)
+			if req.Method == "contactInfo" {
+				conn.Write([]byte(`{"jsonrpc":"2.0","result":{"id":"ActivePubkey","gossip":"127.0.0.1:8001"},"id":1}` + "\n"))
+			}
+			conn.Close()
+		}
+	}()
+
+	cfg := &config.Config{
+		Validator: config.Validator{
+			Client:               config.ClientAgave,
+			RPCURL:               "http://127.0.0.1:1", // RPC disabled
+			ActiveIdentityPubkey: "ActivePubkey",
+			RoleFallback:         config.RoleFallback{Enabled: true},
+			LedgerDirectory:      filepath.Dir(socket),
+		},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: "http://127.0.0.1:1"},
+		Snapshots: config.Snapshots{Directory: t.TempDir()},
+	}
+	k := New(cfg)
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if d, _ := k.LastDecision(); d.Role != "active" || d.Identity != "ActivePubkey" {
+		t.Errorf("expected the admin RPC identity to make the validator active, got %+v", d)
+	}
+}
+
+func TestValidatorPaths(t *testing.T) {
+	fdDir := t.TempDir()
+	fdConfig := filepath.Join(fdDir, "config.toml")
+	os.WriteFile(fdConfig, []byte("[log]\n  path = \"/var/log/fd\"\n[ledger]\n  path = \"/mnt/fd/ledger\"\n[consensus]\n  # the staked identity\n  identity_path = \"/home/fd/identity.json\"\n"), 0644)
+
+	tests := []struct {
+		name         string
+		client       string
+		proc         process
+		wantIdentity string
+		wantLedger   string
+	}{
+		{"agave", config.ClientAgave, process{args: []string{"/usr/bin/agave-validator", "--ledger", "/mnt/ledger", "--identity", "/home/sol/id.json"}}, "/home/sol/id.json", "/mnt/ledger"},
+		{"agave equals", config.ClientAgave, process{args: []string{"agave-validator", "--identity=/home/sol/id.json"}}, "/home/sol/id.json", ""},
+		{"solana short flags", config.ClientAgave, process{args: []string{"solana-validator", "-l", "/mnt/ledger", "-i", "/home/sol/id.json"}}, "/home/sol/id.json", "/mnt/ledger"},
+		{"relative to the process's cwd", config.ClientAgave, process{args: []string{"agave-validator", "--ledger", "ledger", "--identity", "id.json"}, cwd: "/home/sol"}, "/home/sol/id.json", "/home/sol/ledger"},
+		{"relative with unknown cwd", config.ClientAgave, process{args: []string{"agave-validator", "--identity", "id.json"}}, "", ""},
+		{"agave subcommand", config.ClientAgave, process{args: []string{"agave-validator", "--ledger", "/mnt/ledger", "set-identity", "/home/sol/other.json"}}, "", ""},
+		{"firedancer", config.ClientFiredancer, process{args: []string{"fdctl", "run", "--config", fdConfig}}, "/home/fd/identity.json", "/mnt/fd/ledger"},
+		{"firedancer relative config", config.ClientFiredancer, process{args: []string{"fdctl", "run", "--config", "config.toml"}, cwd: fdDir}, "/home/fd/identity.json", "/mnt/fd/ledger"},
+		{"firedancer not running", config.ClientFiredancer, process{args: []string{"fdctl", "configure", "init", "all", "--config", fdConfig}}, "", ""},
+		{"other client", config.ClientFiredancer, process{args: []string{"agave-validator", "--identity", "/home/sol/id.json"}}, "", ""},
+		{"other process", config.ClientAgave, process{args: []string{"bash", "--identity", "/home/sol/id.json"}}, "", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			identity, ledger, ok := validatorPaths(tt.client, tt.proc)
+			if identity != tt.wantIdentity || ledger != tt.wantLedger || ok != (tt.wantIdentity != "") {
+				t.Errorf("validatorPaths() = %q, %q, %v, want %q, %q", identity, ledger, ok, tt.wantIdentity, tt.wantLedger)
+			}
+		})
+	}
+}
+
+func TestSamePath(t *testing.T) {
+	dir := t.TempDir()
+	link := filepath.Join(t.TempDir(), "ledger")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Skipf("symlinks unavailable: %v", err)
+	}
+	if !samePath(dir+"/", dir) || !samePath(link, dir) {
+		t.Error("expected a trailing slash and a symlink to name the same directory")
+	}
+	if samePath(dir, t.TempDir()) || samePath("", dir) {
+		t.Error("expected different or unknown directories not to match")
+	}
 }
 
 func TestRun_FreshSnapshots_Skips(t *testing.T) {
@@ -169,6 +334,92 @@ func TestRun_RPCUnreachable_Proceeds(t *testing.T) {
 	}
 }
 
+func TestRun_NoCandidates_ClassifiesFailure(t *testing.T) {
+	clusterRPC := rpcServer(t, "", 100000, nil)
+	defer clusterRPC.Close()
+
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+	cfg := &config.Config{
+		Validator: config.Validator{RPCURL: "http://127.0.0.1:1", ActiveIdentityPubkey: "ActivePubkey"},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory: t.TempDir(),
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{MinSpeedCheckDelay: "0s", Connections: 1},
+			Age: config.SnapshotsAge{
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		},
+		Hooks: config.Hooks{OnFailure: []config.HookCommand{{
+			Name: "failure",
+			Cmd:  "sh",
+			Args: []string{"-c", "echo '{{ .ErrorKind }}' >> " + hookLog},
+		}}},
+	}
+
+	k := New(cfg)
+	err := k.Run(context.Background())
+	if kind := KindOf(err); kind != ErrorNoCandidates {
+		t.Fatalf("expected a %s failure, got %q (%v)", ErrorNoCandidates, kind, err)
+	}
+	data, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := strings.TrimSpace(string(data)); got != string(ErrorNoCandidates) {
+		t.Errorf("expected the hook to see ErrorKind %s, got %q", ErrorNoCandidates, got)
+	}
+	d, _ := k.LastDecision()
+	if d.ErrorKind != string(ErrorNoCandidates) {
+		t.Errorf("expected the decision to record the error kind, got %+v", d)
+	}
+	if d.Rejections == nil {
+		t.Error("expected the decision to record why probed nodes were rejected")
+	}
+}
+
+func TestKindOf(t *testing.T) {
+	diskFull := &os.PathError{Op: "write", Path: "/snapshots/x.tmp", Err: syscall.ENOSPC}
+	tests := []struct {
+		name string
+		err  error
+		want ErrorKind
+	}{
+		{"nil", nil, ""},
+		{"unclassified", errors.New("boom"), ""},
+		{"classified", classify(ErrorRPCUnavailable, errors.New("refused")), ErrorRPCUnavailable},
+		{"wrapped", fmt.Errorf("cycle: %w", classify(ErrorVerificationFailed, errors.New("mismatch"))), ErrorVerificationFailed},
+		{"disk full", fmt.Errorf("downloading: %w", diskFull), ErrorDiskFull},
+		{"last candidate out of disk", candidatesFailed(diskFull, errors.New("all 2 candidates failed")), ErrorDiskFull},
+		{"last candidate unverified", candidatesFailed(classify(ErrorVerificationFailed, errors.New("mismatch")), errors.New("all 2 candidates failed")), ErrorVerificationFailed},
+		{"last candidate timed out", candidatesFailed(errors.New("timeout"), errors.New("all 2 candidates failed")), ErrorAllDownloadsFailed},
+	}
+	for _, tt := range tests {
+		if got := KindOf(tt.err); got != tt.want {
+			t.Errorf("%s: KindOf() = %q, want %q", tt.name, got, tt.want)
+		}
+	}
+
+	codes := map[int]ErrorKind{}
+	for _, kind := range []ErrorKind{ErrorNoCandidates, ErrorAllDownloadsFailed, ErrorRPCUnavailable, ErrorDiskFull, ErrorVerificationFailed} {
+		code := kind.ExitCode()
+		if code <= 1 {
+			t.Errorf("%s: exit code %d collides with the generic failure code", kind, code)
+		}
+		if other, ok := codes[code]; ok {
+			t.Errorf("%s and %s share exit code %d", kind, other, code)
+		}
+		codes[code] = kind
+	}
+	if code := ErrorKind("").ExitCode(); code != 1 {
+		t.Errorf("unclassified exit code = %d, want 1", code)
+	}
+}
+
 func TestAssessFreshness(t *testing.T) {
 	tests := []struct {
 		name          string
@@ -176,6 +427,8 @@ func TestAssessFreshness(t *testing.T) {
 		currentSlot   uint64
 		maxIncAge     int
 		maxFullAge    int
+		maxLocalFull  int
+		ledgerFiles   []string
 		expectedMode  downloadMode
 	}{
 		{
@@ -225,6 +478,59 @@ func TestAssessFreshness(t *testing.T) {
 			maxFullAge:   5000,
 			expectedMode: modeSkip,
 		},
+		{
+			name:         "ancient full with fresh incremental — needs full",
+			files:        []string{"snapshot-50000-Hash.tar.zst", "incremental-snapshot-50000-99500-Inc.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			maxLocalFull: 25000,
+			expectedMode: modeFull,
+		},
+		{
+			name:         "ancient full with stale incremental — needs full",
+			files:        []string{"snapshot-50000-Hash.tar.zst", "incremental-snapshot-50000-90000-Inc.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			maxLocalFull: 25000,
+			expectedMode: modeFull,
+		},
+		{
+			name:         "full within max_full_slots — incremental extends range",
+			files:        []string{"snapshot-80000-Hash.tar.zst", "incremental-snapshot-80000-99500-Inc.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			maxLocalFull: 25000,
+			expectedMode: modeSkip,
+		},
+		{
+			name:         "validator's own fresh snapshots in the ledger — skip",
+			files:        []string{"snapshot-90000-Hash.tar.zst"},
+			ledgerFiles:  []string{"snapshot-95000-Own.tar.zst", "incremental-snapshot-95000-99500-OwnInc.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			expectedMode: modeSkip,
+		},
+		{
+			name:         "stale ledger snapshot — managed snapshots decide",
+			files:        []string{"snapshot-97000-Hash.tar.zst"},
+			ledgerFiles:  []string{"snapshot-90000-Own.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			expectedMode: modeIncremental,
+		},
+		{
+			name:         "ledger incremental without its full doesn't count",
+			ledgerFiles:  []string{"incremental-snapshot-95000-99500-OwnInc.tar.zst"},
+			currentSlot:  100000,
+			maxIncAge:    1300,
+			maxFullAge:   5000,
+			expectedMode: modeFull,
+		},
 	}
 
 	for _, tt := range tests {
@@ -233,19 +539,28 @@ func TestAssessFreshness(t *testing.T) {
 			for _, f := range tt.files {
 				os.WriteFile(filepath.Join(dir, f), []byte("data"), 0644)
 			}
+			var ledgerDir string
+			if tt.ledgerFiles != nil {
+				ledgerDir = t.TempDir()
+				for _, f := range tt.ledgerFiles {
+					os.WriteFile(filepath.Join(ledgerDir, f), []byte("data"), 0644)
+				}
+			}
 
 			cfg := &config.Config{
+				Validator: config.Validator{LedgerDirectory: ledgerDir},
 				Snapshots: config.Snapshots{
 					Directory: dir,
 					Age: config.SnapshotsAge{
 						Remote: config.SnapshotsRemoteAge{MaxSlots: tt.maxFullAge},
 						Local: config.SnapshotsLocalAge{
 							MaxIncrementalSlots: tt.maxIncAge,
+							MaxFullSlots:        tt.maxLocalFull,
 						},
 					},
 				},
 			}
-			k := &Keeper{cfg: cfg}
+			k := &Keeper{cfg: cfg, client: newValidatorClient(cfg, nil)}
 
 			mode, _, err := k.assessFreshness(tt.currentSlot)
 			if err != nil {
@@ -258,6 +573,44 @@ func TestAssessFreshness(t *testing.T) {
 	}
 }
 
+func TestRun_EnforcesSizeBudgetBeforeDownloading(t *testing.T) {
+	clusterRPC := rpcServer(t, "", 100000, nil)
+	defer clusterRPC.Close()
+
+	snapshotDir, quarantineDir := t.TempDir(), t.TempDir()
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-1000-HashA.tar.zst"), make([]byte, 100), 0644)
+	quarantined := filepath.Join(quarantineDir, "snapshot-500-HashB.tar.zst")
+	os.WriteFile(quarantined, make([]byte, 100), 0644)
+	cfg := &config.Config{
+		Validator: config.Validator{RPCURL: "http://127.0.0.1:1", ActiveIdentityPubkey: "ActivePubkey"},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{MinSuitableFull: 1, SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download:  config.SnapshotsDownload{MinSpeedCheckDelay: "0s", Connections: 1},
+			Retention: config.Retention{QuarantineDir: quarantineDir, QuarantineTTLDur: 24 * time.Hour, MaxTotalSizeBytes: 150},
+			Age: config.SnapshotsAge{
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		},
+	}
+
+	// The cycle finds nothing to download, but made room for it first
+	if err := New(cfg).Run(context.Background()); KindOf(err) != ErrorNoCandidates {
+		t.Fatalf("expected a %s failure, got %v", ErrorNoCandidates, err)
+	}
+	if _, err := os.Stat(quarantined); !os.IsNotExist(err) {
+		t.Error("quarantined snapshot should be evicted to fit max_total_size before downloading")
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "snapshot-1000-HashA.tar.zst")); err != nil {
+		t.Error("newest full should be kept")
+	}
+}
+
 func TestRun_FullDownload_EndToEnd(t *testing.T) {
 	snapshotData := []byte("fake snapshot data for testing purposes")
 	snapshotFilename := "snapshot-100000-HashA.tar.zst"
@@ -275,6 +628,7 @@ func TestRun_FullDownload_EndToEnd(t *testing.T) {
 	defer clusterRPC.Close()
 
 	snapshotDir := t.TempDir()
+	reportsDir := t.TempDir()
 	cfg := &config.Config{
 		Validator: config.Validator{
 			RPCURL:              localRPC.URL,
@@ -297,14 +651,42 @@ func TestRun_FullDownload_EndToEnd(t *testing.T) {
 				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
 			},
 		},
+		Reports: config.Reports{Directory: reportsDir, Keep: 10},
 	}
 
 	k := New(cfg)
+	var mu sync.Mutex
+	var published []events.Event
+	k.Events().Subscribe(func(e events.Event) {
+		mu.Lock()
+		defer mu.Unlock()
+		published = append(published, e)
+	})
 	err := k.Run(context.Background())
 	if err != nil {
 		t.Fatal(err)
 	}
 
+	// The cycle's events frame the selected candidate
+	mu.Lock()
+	if len(published) < 3 {
+		t.Fatalf("expected at least 3 events, got %v", published)
+	}
+	if _, ok := published[0].(events.CycleStarted); !ok {
+		t.Errorf("expected cycle_started first, got %s", published[0].Name())
+	}
+	if f, ok := published[len(published)-1].(events.CycleFinished); !ok || f.Result != "success" || f.SnapshotSlot != 100000 || f.Err != nil {
+		t.Errorf("expected a successful cycle_finished last, got %+v", published[len(published)-1])
+	}
+	selected := slices.IndexFunc(published, func(e events.Event) bool {
+		c, ok := e.(events.CandidateSelected)
+		return ok && c.Source == snapAddr && c.Slot == 100000 && c.Type == "full"
+	})
+	if selected < 0 {
+		t.Errorf("expected candidate_selected for the source, got %v", published)
+	}
+	mu.Unlock()
+
 	// Verify the snapshot was downloaded
 	downloadedPath := filepath.Join(snapshotDir, snapshotFilename)
 	data, err := os.ReadFile(downloadedPath)
@@ -315,61 +697,59 @@ func TestRun_FullDownload_EndToEnd(t *testing.T) {
 		t.Errorf("snapshot content mismatch")
 	}
 
-	// suppress unused
-	_ = fmt.Sprintf
-}
+	d, ok := k.LastDecision()
+	if !ok || d.Result != "success" || d.Mode != "full" || d.SnapshotSlot != 100000 || d.Source != snapAddr {
+		t.Errorf("unexpected decision: %+v", d)
+	}
 
-// pairedSnapshotServer serves both full and incremental snapshot HEAD redirects and GET data.
-func pairedSnapshotServer(t *testing.T, fullFilename, incrFilename string, fullData, incrData []byte) *httptest.Server {
-	t.Helper()
-	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		if r.Method == http.MethodHead {
-			switch {
-			case strings.HasSuffix(r.URL.Path, "snapshot.tar.bz2") && !strings.Contains(r.URL.Path, "incremental"):
-				w.Header().Set("Location", "/"+fullFilename)
-				w.WriteHeader(http.StatusFound)
-			case strings.Contains(r.URL.Path, "incremental-snapshot.tar.bz2"):
-				w.Header().Set("Location", "/"+incrFilename)
-				w.WriteHeader(http.StatusFound)
-			default:
-				w.WriteHeader(http.StatusNotFound)
-			}
-			return
-		}
+	// The cycle's report records the candidate and what was downloaded
+	r, err := cyclereport.Last(reportsDir, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if r.Result != "success" || r.Cluster != "testnet" || r.SnapshotSlot != 100000 || r.Discovery.ClusterNodes != 1 || r.Discovery.Attempted != 1 {
+		t.Errorf("unexpected report: %+v", r)
+	}
+	if r.Discovery.Probed == 0 || r.Discovery.Suitable == 0 {
+		t.Errorf("report discovery = %+v, want the probes counted", r.Discovery)
+	}
+	var phases []string
+	for _, p := range r.Phases {
+		phases = append(phases, p.Name)
+	}
+	if want := []string{"role", "freshness", "download", "unpack", "prune", "hooks"}; !slices.Equal(phases, want) {
+		t.Errorf("report phases = %v, want %v", phases, want)
+	}
+	if r.Candidate == nil || r.Candidate.RPCURL != snapAddr || r.Candidate.Filename != snapshotFilename {
+		t.Errorf("report candidate = %+v", r.Candidate)
+	}
+	if len(r.Downloads) != 1 || r.Downloads[0].Path != downloadedPath || r.Downloads[0].Bytes != int64(len(snapshotData)) {
+		t.Errorf("report downloads = %+v", r.Downloads)
+	}
 
-		// GET — serve snapshot data based on filename
-		switch {
-		case strings.Contains(r.URL.Path, "incremental"):
-			w.Header().Set("Content-Length", strconv.Itoa(len(incrData)))
-			w.WriteHeader(http.StatusOK)
-			w.Write(incrData)
-		default:
-			w.Header().Set("Content-Length", strconv.Itoa(len(fullData)))
-			w.WriteHeader(http.StatusOK)
-			w.Write(fullData)
-		}
-	}))
+	// suppress unused
+	_ = fmt.Sprintf
 }
 
-func TestRun_PairedDownload_EndToEnd(t *testing.T) {
-	fullData := []byte("fake full snapshot data")
+func TestRun_IncrementalDownload_Streaming(t *testing.T) {
 	incrData := []byte("fake incremental snapshot data")
-	fullFilename := "snapshot-100000-HashFull.tar.zst"
 	incrFilename := "incremental-snapshot-100000-100500-HashInc.tar.zst"
 
-	snapServer := pairedSnapshotServer(t, fullFilename, incrFilename, fullData, incrData)
+	snapServer := pairedSnapshotServer(t, "snapshot-100000-HashFull.tar.zst", incrFilename, nil, incrData)
 	defer snapServer.Close()
 
 	snapAddr := snapServer.URL
-	localRPC := rpcServer(t, "PassivePubkey", 100600, nil)
+	localRPC := rpcServer(t, "PassivePubkey", 102000, nil)
 	defer localRPC.Close()
 
-	clusterRPC := rpcServer(t, "", 100600, []map[string]any{
+	clusterRPC := rpcServer(t, "", 102000, []map[string]any{
 		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapAddr},
 	})
 	defer clusterRPC.Close()
 
 	snapshotDir := t.TempDir()
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-100000-HashFull.tar.zst"), []byte("data"), 0644)
+
 	cfg := &config.Config{
 		Validator: config.Validator{
 			RPCURL:               localRPC.URL,
@@ -381,6 +761,7 @@ func TestRun_PairedDownload_EndToEnd(t *testing.T) {
 			Discovery: config.Discovery{
 				Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
 				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+				Stream:     true,
 			},
 			Download: config.SnapshotsDownload{
 				MinSpeedCheckDelay: "0s",
@@ -388,30 +769,18 @@ func TestRun_PairedDownload_EndToEnd(t *testing.T) {
 				Timeout:            "1m",
 			},
 			Age: config.SnapshotsAge{
-				Remote: config.SnapshotsRemoteAge{MaxSlots: 1300},
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 1600},
 				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
 			},
 		},
 	}
 
 	k := New(cfg)
-	err := k.Run(context.Background())
-	if err != nil {
+	if err := k.Run(context.Background()); err != nil {
 		t.Fatal(err)
 	}
 
-	// Verify both snapshots were downloaded
-	fullPath := filepath.Join(snapshotDir, fullFilename)
-	data, err := os.ReadFile(fullPath)
-	if err != nil {
-		t.Fatalf("full snapshot file not found: %v", err)
-	}
-	if string(data) != string(fullData) {
-		t.Errorf("full snapshot content mismatch")
-	}
-
-	incrPath := filepath.Join(snapshotDir, incrFilename)
-	data, err = os.ReadFile(incrPath)
+	data, err := os.ReadFile(filepath.Join(snapshotDir, incrFilename))
 	if err != nil {
 		t.Fatalf("incremental snapshot file not found: %v", err)
 	}
@@ -419,3 +788,1814 @@ func TestRun_PairedDownload_EndToEnd(t *testing.T) {
 		t.Errorf("incremental snapshot content mismatch")
 	}
 }
+
+func TestRun_Firedancer_SeparateIncrementalDirectory(t *testing.T) {
+	incrData := []byte("fake incremental snapshot data")
+	incrFilename := "incremental-snapshot-100000-100500-HashInc.tar.zst"
+
+	snapServer := pairedSnapshotServer(t, "snapshot-100000-HashFull.tar.zst", incrFilename, nil, incrData)
+	defer snapServer.Close()
+
+	localRPC := rpcServer(t, "PassivePubkey", 102000, nil)
+	defer localRPC.Close()
+
+	clusterRPC := rpcServer(t, "", 102000, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapServer.URL},
+	})
+	defer clusterRPC.Close()
+
+	snapshotDir := t.TempDir()
+	incrementalDir := t.TempDir()
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-100000-HashFull.tar.zst"), []byte("data"), 0644)
+	os.WriteFile(filepath.Join(incrementalDir, "incremental-snapshot-99000-99500-HashOld.tar.zst"), []byte("orphan"), 0644)
+
+	cfg := &config.Config{
+		Validator: config.Validator{
+			Client:               config.ClientFiredancer,
+			RPCURL:               localRPC.URL,
+			ActiveIdentityPubkey: "ActivePubkey",
+		},
+		Cluster: config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory:            snapshotDir,
+			IncrementalDirectory: incrementalDir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{
+				MinSpeedCheckDelay: "0s",
+				Connections:        1,
+				Timeout:            "1m",
+			},
+			Age: config.SnapshotsAge{
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 1600},
+				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		},
+	}
+
+	k := New(cfg)
+	if k.client.Name() != config.ClientFiredancer {
+		t.Fatalf("expected firedancer client, got %s", k.client.Name())
+	}
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(filepath.Join(incrementalDir, incrFilename))
+	if err != nil {
+		t.Fatalf("incremental snapshot not in incremental directory: %v", err)
+	}
+	if string(data) != string(incrData) {
+		t.Errorf("incremental snapshot content mismatch")
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, incrFilename)); err == nil {
+		t.Error("incremental snapshot should not be written to the full snapshot directory")
+	}
+	if _, err := os.Stat(filepath.Join(incrementalDir, "incremental-snapshot-99000-99500-HashOld.tar.zst")); err == nil {
+		t.Error("orphaned incremental in incremental directory should be pruned")
+	}
+}
+
+func TestRun_NearMissRetry(t *testing.T) {
+	snapshotData := []byte("fake snapshot data")
+	snapshotFilename := "snapshot-100000-HashA.tar.zst"
+
+	snapServer := snapshotServer(t, snapshotFilename, snapshotData)
+	defer snapServer.Close()
+
+	snapAddr := snapServer.URL
+	localRPC := rpcServer(t, "PassivePubkey", 101500, nil)
+	defer localRPC.Close()
+
+	// Snapshot is 1500 slots old - just over max_slots of 1300
+	clusterRPC := rpcServer(t, "", 101500, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapAddr},
+	})
+	defer clusterRPC.Close()
+
+	newConfig := func(factor float64) *config.Config {
+		return &config.Config{
+			Validator: config.Validator{
+				RPCURL:               localRPC.URL,
+				ActiveIdentityPubkey: "ActivePubkey",
+			},
+			Cluster: config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+			Snapshots: config.Snapshots{
+				Directory: t.TempDir(),
+				Discovery: config.Discovery{
+					Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+					Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+				},
+				Download: config.SnapshotsDownload{
+					MinSpeedCheckDelay: "0s",
+					Connections:        1,
+					Timeout:            "1m",
+				},
+				Age: config.SnapshotsAge{
+					Remote: config.SnapshotsRemoteAge{MaxSlots: 1300, NearMissFactor: factor},
+					Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+				},
+			},
+		}
+	}
+
+	if err := New(newConfig(0)).Run(context.Background()); err == nil {
+		t.Error("expected failure without near-miss retry")
+	}
+
+	cfg := newConfig(1.5)
+	if err := New(cfg).Run(context.Background()); err != nil {
+		t.Fatalf("expected near-miss retry to succeed: %v", err)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Snapshots.Directory, snapshotFilename)); err != nil {
+		t.Errorf("snapshot file not found: %v", err)
+	}
+
+	// Factor too small to cover the 1500 slot age
+	if err := New(newConfig(1.1)).Run(context.Background()); err == nil {
+		t.Error("expected failure when relaxed threshold still excludes all nodes")
+	}
+}
+
+// pairedSnapshotServer serves both full and incremental snapshot HEAD redirects and GET data.
+func pairedSnapshotServer(t *testing.T, fullFilename, incrFilename string, fullData, incrData []byte) *httptest.Server {
+	t.Helper()
+	return httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			switch {
+			case strings.HasSuffix(r.URL.Path, "snapshot.tar.bz2") && !strings.Contains(r.URL.Path, "incremental"):
+				w.Header().Set("Location", "/"+fullFilename)
+				w.WriteHeader(http.StatusFound)
+			case strings.Contains(r.URL.Path, "incremental-snapshot.tar.bz2"):
+				w.Header().Set("Location", "/"+incrFilename)
+				w.WriteHeader(http.StatusFound)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+			}
+			return
+		}
+
+		// GET — serve snapshot data based on filename
+		switch {
+		case strings.Contains(r.URL.Path, "incremental"):
+			w.Header().Set("Content-Length", strconv.Itoa(len(incrData)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(incrData)
+		default:
+			w.Header().Set("Content-Length", strconv.Itoa(len(fullData)))
+			w.WriteHeader(http.StatusOK)
+			w.Write(fullData)
+		}
+	}))
+}
+
+func TestRun_PairedDownload_EndToEnd(t *testing.T) {
+	fullData := []byte("fake full snapshot data")
+	incrData := []byte("fake incremental snapshot data")
+	fullFilename := "snapshot-100000-HashFull.tar.zst"
+	incrFilename := "incremental-snapshot-100000-100500-HashInc.tar.zst"
+
+	snapServer := pairedSnapshotServer(t, fullFilename, incrFilename, fullData, incrData)
+	defer snapServer.Close()
+
+	snapAddr := snapServer.URL
+	localRPC := rpcServer(t, "PassivePubkey", 100600, nil)
+	defer localRPC.Close()
+
+	clusterRPC := rpcServer(t, "", 100600, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapAddr},
+	})
+	defer clusterRPC.Close()
+
+	snapshotDir := t.TempDir()
+	cfg := &config.Config{
+		Validator: config.Validator{
+			RPCURL:               localRPC.URL,
+			ActiveIdentityPubkey: "ActivePubkey",
+		},
+		Cluster: config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{
+				MinSpeedCheckDelay: "0s",
+				Connections:        1,
+				Timeout:            "1m",
+			},
+			Age: config.SnapshotsAge{
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 1300},
+				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		},
+	}
+
+	k := New(cfg)
+	err := k.Run(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Verify both snapshots were downloaded
+	fullPath := filepath.Join(snapshotDir, fullFilename)
+	data, err := os.ReadFile(fullPath)
+	if err != nil {
+		t.Fatalf("full snapshot file not found: %v", err)
+	}
+	if string(data) != string(fullData) {
+		t.Errorf("full snapshot content mismatch")
+	}
+
+	incrPath := filepath.Join(snapshotDir, incrFilename)
+	data, err = os.ReadFile(incrPath)
+	if err != nil {
+		t.Fatalf("incremental snapshot file not found: %v", err)
+	}
+	if string(data) != string(incrData) {
+		t.Errorf("incremental snapshot content mismatch")
+	}
+}
+
+func TestTrustedNodes_StakeFilterRequiresVoteAccounts(t *testing.T) {
+	// rpcServer doesn't answer getVoteAccounts
+	clusterRPC := rpcServer(t, "", 100000, nil)
+	defer clusterRPC.Close()
+
+	cfg := &config.Config{
+		Cluster: config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Discovery: config.Discovery{
+				Trust: config.DiscoveryTrust{MinStakeLamports: 1},
+			},
+		},
+	}
+	k := New(cfg)
+
+	nodes := []rpc.ClusterNode{{Pubkey: "node1"}}
+	if _, err := k.trustedNodes(context.Background(), nodes); err == nil {
+		t.Error("expected error when stakes can't be fetched, not an unfiltered node list")
+	}
+
+	cfg.Snapshots.Discovery.Trust = config.DiscoveryTrust{KnownValidators: []string{"node1"}}
+	got, err := k.trustedNodes(context.Background(), append(nodes, rpc.ClusterNode{Pubkey: "node2"}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(got) != 1 || got[0].Pubkey != "node1" {
+		t.Errorf("expected only the known validator, got %+v", got)
+	}
+}
+
+func TestExcludeOwnNodes(t *testing.T) {
+	cfg := &config.Config{
+		Validator: config.Validator{ActiveIdentityPubkey: "active"},
+		Snapshots: config.Snapshots{
+			Discovery: config.Discovery{
+				Exclude: config.DiscoveryExclude{Hosts: []string{"198.51.100.0/24"}},
+			},
+		},
+	}
+	if err := cfg.Snapshots.Discovery.Exclude.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	k := New(cfg)
+
+	nodes := []rpc.ClusterNode{
+		{Pubkey: "active", Gossip: "203.0.113.1:8001"},
+		{Pubkey: "passive", Gossip: "203.0.113.2:8001"},
+		{Pubkey: "natted", Gossip: "198.51.100.9:8001"},
+		{Pubkey: "other", Gossip: "203.0.113.3:8001"},
+	}
+	pubkeys := func(nodes []rpc.ClusterNode) []string {
+		var out []string
+		for _, n := range nodes {
+			out = append(out, n.Pubkey)
+		}
+		return out
+	}
+
+	got := pubkeys(k.excludeOwnNodes(context.Background(), nodes, "passive"))
+	if want := []string{"active", "passive", "other"}; !slices.Equal(got, want) {
+		t.Errorf("without self: got %v, want %v", got, want)
+	}
+
+	cfg.Snapshots.Discovery.Exclude.Self = true
+	got = pubkeys(k.excludeOwnNodes(context.Background(), nodes, "passive"))
+	if want := []string{"other"}; !slices.Equal(got, want) {
+		t.Errorf("with self: got %v, want %v", got, want)
+	}
+}
+
+func TestPeerURLs_SkipsThisMachine(t *testing.T) {
+	cfg := &config.Config{Peers: config.Peers{
+		URLs:  []string{"http://127.0.0.1:8901", "http://localhost:8901", "http://[::1]:8901", "http://203.0.113.7:8901"},
+		Token: "secret",
+	}}
+	got := New(cfg).discoveryOptions().Peers
+	if want := []string{"http://203.0.113.7:8901"}; !slices.Equal(got, want) {
+		t.Errorf("got %v, want %v", got, want)
+	}
+}
+
+func TestSkipHaveSlot(t *testing.T) {
+	snapshotDir := t.TempDir()
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "incremental-snapshot-100-200-HashB.tar.zst"} {
+		if err := os.WriteFile(filepath.Join(snapshotDir, name), []byte("test"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	k := New(&config.Config{Snapshots: config.Snapshots{Directory: snapshotDir}})
+
+	tests := []struct {
+		name string
+		node discovery.SnapshotNode
+		want bool
+	}{
+		{"same full", discovery.SnapshotNode{SnapshotType: discovery.SnapshotTypeFull, Slot: 100, Filename: "snapshot-100-HashA.tar.zst"}, false},
+		{"other full for the slot", discovery.SnapshotNode{SnapshotType: discovery.SnapshotTypeFull, Slot: 100, Filename: "snapshot-100-HashX.tar.zst"}, true},
+		{"newer full", discovery.SnapshotNode{SnapshotType: discovery.SnapshotTypeFull, Slot: 200, Filename: "snapshot-200-HashX.tar.zst"}, false},
+		{"other incremental for the slot", discovery.SnapshotNode{SnapshotType: discovery.SnapshotTypeIncremental, Slot: 200, BaseSlot: 100, Filename: "incremental-snapshot-100-200-HashX.tar.zst"}, true},
+		{"incremental on another base", discovery.SnapshotNode{SnapshotType: discovery.SnapshotTypeIncremental, Slot: 200, BaseSlot: 150, Filename: "incremental-snapshot-150-200-HashX.tar.zst"}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := k.skipHaveSlot(tt.node); got != tt.want {
+				t.Errorf("skipHaveSlot() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestRefreshActiveIdentity(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "identity.json")
+	writeKey := func() string {
+		pub, key, _ := ed25519.GenerateKey(rand.Reader)
+		nums := make([]int, len(key))
+		for i, b := range key {
+			nums[i] = int(b)
+		}
+		data, _ := json.Marshal(nums)
+		if err := os.WriteFile(path, data, 0600); err != nil {
+			t.Fatal(err)
+		}
+		return attestation.FormatPubkey(pub)
+	}
+
+	first := writeKey()
+	k := New(&config.Config{Validator: config.Validator{ActiveIdentityPubkey: first, ActiveIdentityKeypairPath: path}})
+	k.leaders = &leaderSchedule{epoch: 1}
+
+	rotated := writeKey()
+	k.refreshActiveIdentity()
+	if got := k.activeIdentity(); got != rotated {
+		t.Errorf("expected the rotated pubkey %s, got %s", rotated, got)
+	}
+	if k.leaders != nil {
+		t.Error("expected the old identity's leader schedule to be dropped")
+	}
+
+	// An unreadable keypair keeps the last pubkey
+	os.WriteFile(path, []byte("garbage"), 0600)
+	k.refreshActiveIdentity()
+	if got := k.activeIdentity(); got != rotated {
+		t.Errorf("expected %s to be kept, got %s", rotated, got)
+	}
+}
+
+func TestRefreshSlotTime(t *testing.T) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		// 240 slots over two minutes: 500ms slots
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"slot":2240,"numSlots":110,"samplePeriodSecs":60},{"slot":2130,"numSlots":130,"samplePeriodSecs":60}]}`))
+	}))
+	defer server.Close()
+
+	fc := clock.NewFake(time.Now())
+	cfg := &config.Config{Cluster: config.Cluster{Name: "testnet", RPCURL: server.URL, SlotTime: "auto", SlotTimeAuto: true}}
+	k := NewWithOptions(cfg, Options{Clock: fc})
+	if got := k.slotTime(); got != 400*time.Millisecond {
+		t.Fatalf("slot time before sampling = %s, want 400ms", got)
+	}
+
+	k.refreshSlotTime(context.Background())
+	if got := k.slotTime(); got != 500*time.Millisecond {
+		t.Errorf("estimated slot time = %s, want 500ms", got)
+	}
+
+	// Estimates are reused until they are due for a refresh
+	k.refreshSlotTime(context.Background())
+	fc.Advance(slotTimeRefresh)
+	k.refreshSlotTime(context.Background())
+	if got := calls.Load(); got != 2 {
+		t.Errorf("sampled %d times, want 2", got)
+	}
+}
+
+func TestRefreshSlotTime_ConcurrentReads(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"result":[{"slot":2240,"numSlots":120,"samplePeriodSecs":60}]}`))
+	}))
+	defer server.Close()
+
+	cfgFile := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf("cluster:\n  rpc_url: %s\n  slot_time: auto\nsnapshots:\n  directory: %s\n  age:\n    local:\n      max_incremental_slots: 10m\n", server.URL, t.TempDir())
+	if err := os.WriteFile(cfgFile, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	cfg := config.New()
+	if err := cfg.LoadFromFile(cfgFile); err != nil {
+		t.Fatal(err)
+	}
+	if err := cfg.Cluster.Validate(); err != nil {
+		t.Fatal(err)
+	}
+	k := NewWithOptions(cfg, Options{Clock: clock.NewFake(time.Now())})
+
+	// The status server reads the threshold while cycles convert it
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for range 1000 {
+			k.MaxSlotsBehind()
+		}
+	}()
+	k.refreshSlotTime(context.Background())
+	<-done
+
+	if got := k.MaxSlotsBehind(); got != 1200 {
+		t.Errorf("MaxSlotsBehind() = %d, want 1200 at 500ms slots", got)
+	}
+}
+
+func TestEstimateSlotTime(t *testing.T) {
+	tests := []struct {
+		name    string
+		samples []rpc.PerformanceSample
+		want    time.Duration
+		ok      bool
+	}{
+		{"nominal", []rpc.PerformanceSample{{NumSlots: 150, SamplePeriodSecs: 60}}, 400 * time.Millisecond, true},
+		{"empty samples skipped", []rpc.PerformanceSample{{NumSlots: 0, SamplePeriodSecs: 60}, {NumSlots: 100, SamplePeriodSecs: 60}}, 600 * time.Millisecond, true},
+		{"no samples", nil, 0, false},
+		{"implausible", []rpc.PerformanceSample{{NumSlots: 1, SamplePeriodSecs: 60}}, 0, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := estimateSlotTime(tt.samples)
+			if got != tt.want || ok != tt.ok {
+				t.Errorf("estimateSlotTime() = %s, %v, want %s, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+}
+
+func TestVerifyContentHash(t *testing.T) {
+	k := New(&config.Config{})
+	path := filepath.Join(t.TempDir(), "snapshot-100-Hash.tar.zst")
+	if err := os.WriteFile(path, []byte("test"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	node := discovery.SnapshotNode{Filename: "snapshot-100-Hash.tar.zst", SHA256: "9f86d081884c7d659a2feaa0c55ad015a3bf4f1b2b0b822cd15d6c15b0f00a08"}
+	if err := k.verifyContentHash(context.Background(), node, path); err != nil {
+		t.Fatalf("expected the published hash to match, got %v", err)
+	}
+
+	node.SHA256 = strings.Repeat("0", 64)
+	if err := k.verifyContentHash(context.Background(), node, path); err == nil {
+		t.Fatal("expected a mismatch")
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("expected the mismatched archive to be removed")
+	}
+}
+
+type fixedSlots struct{ slot uint64 }
+
+func (f *fixedSlots) GetSlot(context.Context) (uint64, error) { return f.slot, nil }
+
+func TestUpdateIncident_MarkerFileRunsEnterAndExitHooks(t *testing.T) {
+	snapshotDir := t.TempDir()
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+	logHook := func(event string) []config.HookCommand {
+		return []config.HookCommand{{
+			Name: event,
+			Cmd:  "sh",
+			Args: []string{"-c", "echo '" + event + " {{ .IncidentReason }}' >> " + hookLog},
+		}}
+	}
+	cfg := &config.Config{
+		Cluster:   config.Cluster{Name: "testnet"},
+		Snapshots: config.Snapshots{Directory: snapshotDir},
+		Hooks: config.Hooks{
+			OnIncidentEnter: logHook("enter"),
+			OnIncidentExit:  logHook("exit"),
+		},
+	}
+	k := New(cfg)
+	ctx := context.Background()
+
+	if k.updateIncident(ctx, "passive", 100) {
+		t.Fatal("expected no incident without a marker file")
+	}
+
+	marker := filepath.Join(snapshotDir, incidentMarkerFilename)
+	os.WriteFile(marker, nil, 0644)
+	if !k.updateIncident(ctx, "passive", 100) || !k.updateIncident(ctx, "passive", 100) {
+		t.Fatal("expected incident mode while the marker file exists")
+	}
+
+	// A fresh keeper (as with `run`) must remember the incident and fire exit once
+	os.Remove(marker)
+	k = New(cfg)
+	if k.updateIncident(ctx, "passive", 100) || k.updateIncident(ctx, "passive", 100) {
+		t.Fatal("expected incident mode to end once the marker file is removed")
+	}
+
+	data, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.HasPrefix(lines[0], "enter manual") || !strings.HasPrefix(lines[1], "exit manual") {
+		t.Errorf("expected one enter and one exit hook with the reason, got %q", lines)
+	}
+}
+
+func TestDetectIncident_SlotStall(t *testing.T) {
+	tests := []struct {
+		name      string
+		advanced  uint64
+		wantStall bool
+	}{
+		// 10s window at 400ms/slot is 25 slots, 0.25 rate needs 7
+		{"healthy", 25, false},
+		{"slow but above rate", 7, false},
+		{"stalled", 6, true},
+		{"halted", 0, true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fc := clock.NewFake(time.Now())
+			slots := &fixedSlots{slot: 1000 + tt.advanced}
+			cfg := &config.Config{
+				Snapshots: config.Snapshots{Directory: t.TempDir()},
+				Incident:  config.Incident{AutoDetect: true, SampleWindowDur: 10 * time.Second, MinSlotRate: 0.25},
+			}
+			k := NewWithOptions(cfg, Options{Clock: fc, Slots: slots})
+
+			done := make(chan string)
+			go func() { done <- k.detectIncident(context.Background(), 1000) }()
+			fc.BlockUntil(1)
+			fc.Advance(10 * time.Second)
+
+			if reason := <-done; (reason != "") != tt.wantStall {
+				t.Errorf("got reason %q, want stall=%v", reason, tt.wantStall)
+			}
+		})
+	}
+}
+
+func TestEpochScheduling(t *testing.T) {
+	// Epoch 10 spans slots 4_320_000-4_751_999
+	epoch := &rpc.EpochInfo{Epoch: 10, SlotsInEpoch: 432_000}
+	at := func(slotIndex uint64) *rpc.EpochInfo {
+		e := *epoch
+		e.SlotIndex = slotIndex
+		e.AbsoluteSlot = 4_320_000 + slotIndex
+		return &e
+	}
+
+	snapshotDir := t.TempDir()
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Epoch:     config.SnapshotsEpoch{DeferFullSlots: 10_000, FullAfterBoundary: true},
+		},
+	}
+	k := New(cfg)
+
+	if k.deferFullForEpoch(at(431_000)) {
+		t.Error("expected full download near the boundary to proceed without a local full")
+	}
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-4300000-HashA.tar.zst"), []byte("data"), 0644)
+	if !k.deferFullForEpoch(at(431_000)) {
+		t.Error("expected full download to be deferred near the boundary")
+	}
+	if k.deferFullForEpoch(at(100_000)) {
+		t.Error("expected full download mid-epoch to proceed")
+	}
+	if k.deferFullForEpoch(nil) {
+		t.Error("expected no deferral without epoch info")
+	}
+
+	if !k.fullAfterBoundary(at(500), 4_300_000) {
+		t.Error("expected a full predating the epoch to be replaced")
+	}
+	if k.fullAfterBoundary(at(500), 4_320_000) {
+		t.Error("expected a full from the current epoch to be kept")
+	}
+
+	cfg.Snapshots.Epoch = config.SnapshotsEpoch{}
+	if k.deferFullForEpoch(at(431_000)) || k.fullAfterBoundary(at(500), 4_300_000) {
+		t.Error("expected epoch scheduling to be disabled by default")
+	}
+}
+
+func TestDownloadFailure_CoolsDownSource(t *testing.T) {
+	failing := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusInternalServerError)
+	}))
+	defer failing.Close()
+
+	fc := clock.NewFake(time.Now())
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: t.TempDir(),
+			Download:  config.SnapshotsDownload{FailureCooldownDur: 10 * time.Minute},
+		},
+	}
+	k := NewWithOptions(cfg, Options{Clock: fc})
+
+	node := discovery.SnapshotNode{
+		RPCURL:       failing.URL,
+		SnapshotURL:  failing.URL + "/snapshot-100-Hash.tar.zst",
+		SnapshotType: discovery.SnapshotTypeFull,
+		Filename:     "snapshot-100-Hash.tar.zst",
+	}
+	if _, err := k.download(context.Background(), node, downloader.Options{}); err == nil {
+		t.Fatal("expected download to fail")
+	}
+	if !k.skipCoolingDown(failing.URL) {
+		t.Error("expected failed source to be cooling down")
+	}
+
+	// The cooldown outlives the keeper, e.g. across `run` invocations
+	k = NewWithOptions(cfg, Options{Clock: fc})
+	if !k.skipCoolingDown(failing.URL) {
+		t.Error("expected cooldown to be persisted")
+	}
+	if k.skipCoolingDown("http://other:8899") {
+		t.Error("expected other sources to be unaffected")
+	}
+
+	fc.Advance(10 * time.Minute)
+	if k.skipCoolingDown(failing.URL) {
+		t.Error("expected cooldown to expire")
+	}
+}
+
+func TestRememberCandidates(t *testing.T) {
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: t.TempDir(),
+			Discovery: config.Discovery{Candidates: config.DiscoveryCandidates{Remember: 2}},
+		},
+	}
+	k := New(cfg)
+	opts := k.discoveryOptions()
+	if len(opts.Remembered) != 0 || opts.OnSuitable == nil {
+		t.Fatalf("expected no remembered candidates yet and suitable nodes observed, got %+v", opts.Remembered)
+	}
+	for _, n := range []discovery.SnapshotNode{
+		{RPCURL: "http://a:8899", Latency: 30 * time.Millisecond},
+		{RPCURL: "http://b:8899", Latency: 10 * time.Millisecond},
+		{RPCURL: "http://c:8899", Latency: 40 * time.Millisecond},
+		// Seen again as part of a pair, faster this time
+		{RPCURL: "http://c:8899", Latency: 20 * time.Millisecond},
+	} {
+		opts.OnSuitable(n)
+	}
+	k.rememberCandidates()
+
+	// The candidates outlive the keeper, e.g. across `run` invocations
+	k = New(cfg)
+	want := []string{"http://b:8899", "http://c:8899"}
+	if got := k.discoveryOptions().Remembered; !slices.Equal(got, want) {
+		t.Errorf("expected the two fastest candidates %v, got %v", want, got)
+	}
+
+	// A cycle that found nothing keeps them
+	k.rememberCandidates()
+	if got := New(cfg).discoveryOptions().Remembered; !slices.Equal(got, want) {
+		t.Errorf("expected remembered candidates to be kept, got %v", got)
+	}
+
+	cfg.Snapshots.Discovery.Candidates.Remember = 0
+	if opts := k.discoveryOptions(); opts.Remembered != nil || opts.OnSuitable != nil {
+		t.Error("expected candidates not to be remembered when disabled")
+	}
+}
+
+func TestSourceReputations(t *testing.T) {
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: t.TempDir(),
+			Discovery: config.Discovery{Candidates: config.DiscoveryCandidates{SortOrder: "score"}},
+		},
+	}
+	k := New(cfg)
+	score := k.discoveryOptions().Reputation
+	if got := score("http://a:8899"); got != discovery.NeutralReputation {
+		t.Fatalf("expected an unknown source to score %v, got %v", discovery.NeutralReputation, got)
+	}
+	k.recordReputation("http://a:8899", true)
+	k.recordReputation("http://b:8899", false)
+	k.recordReputation("http://b:8899", false)
+
+	// Reputations outlive the keeper, e.g. across `run` invocations
+	score = New(cfg).discoveryOptions().Reputation
+	good, bad := score("http://a:8899"), score("http://b:8899")
+	if good <= discovery.NeutralReputation || bad >= discovery.NeutralReputation {
+		t.Errorf("expected a successful source above and a failing one below %v, got %v and %v", discovery.NeutralReputation, good, bad)
+	}
+	if bad >= discovery.NeutralReputation-reputationWeight*discovery.NeutralReputation {
+		t.Errorf("expected repeated failures to keep lowering the reputation, got %v", bad)
+	}
+
+	// Only tracked while sort_order is score
+	cfg.Snapshots.Discovery.Candidates.SortOrder = "latency"
+	k = New(cfg)
+	k.recordReputation("http://c:8899", false)
+	if got := New(cfg).discoveryOptions().Reputation("http://c:8899"); got != discovery.NeutralReputation {
+		t.Errorf("expected no reputation tracked with sort_order latency, got %v", got)
+	}
+	// Reputations expire once a source goes unused for long enough
+	cfg.Snapshots.Discovery.Candidates.SortOrder = "score"
+	fc := clock.NewFake(time.Now())
+	if got := NewWithOptions(cfg, Options{Clock: fc}).reputation("http://a:8899"); got == discovery.NeutralReputation {
+		t.Fatal("expected the source's reputation before it expires")
+	}
+	fc.Advance(reputationExpiry)
+	k = NewWithOptions(cfg, Options{Clock: fc})
+	if got := k.reputation("http://a:8899"); got != discovery.NeutralReputation {
+		t.Errorf("expected an expired reputation to be neutral, got %v", got)
+	}
+	k.recordReputation("http://d:8899", true)
+	data, err := os.ReadFile(filepath.Join(cfg.Snapshots.Directory, reputationFilename))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "http://a:8899") || !strings.Contains(string(data), "http://d:8899") {
+		t.Errorf("expected expired reputations dropped when saving, got %s", data)
+	}
+}
+
+func TestRun_MinSlotImprovement(t *testing.T) {
+	incrFilename := "incremental-snapshot-100000-100500-HashInc.tar.zst"
+	snapServer := pairedSnapshotServer(t, "snapshot-100000-HashFull.tar.zst", incrFilename, nil, []byte("incremental"))
+	defer snapServer.Close()
+
+	localRPC := rpcServer(t, "PassivePubkey", 102000, nil)
+	defer localRPC.Close()
+	clusterRPC := rpcServer(t, "", 102000, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapServer.URL},
+	})
+	defer clusterRPC.Close()
+
+	for _, tt := range []struct {
+		minImprovement int
+		wantDownload   bool
+	}{
+		{200, false}, // remote incremental gains only 100 slots
+		{50, true},
+	} {
+		t.Run(fmt.Sprintf("min_%d", tt.minImprovement), func(t *testing.T) {
+			snapshotDir := t.TempDir()
+			os.WriteFile(filepath.Join(snapshotDir, "snapshot-100000-HashFull.tar.zst"), []byte("data"), 0644)
+			os.WriteFile(filepath.Join(snapshotDir, "incremental-snapshot-100000-100400-HashOld.tar.zst"), []byte("data"), 0644)
+
+			cfg := &config.Config{
+				Validator: config.Validator{RPCURL: localRPC.URL, ActiveIdentityPubkey: "ActivePubkey"},
+				Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+				Snapshots: config.Snapshots{
+					Directory: snapshotDir,
+					Discovery: config.Discovery{
+						Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+						Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+					},
+					Download: config.SnapshotsDownload{Connections: 1, MinSlotImprovement: tt.minImprovement},
+					Age: config.SnapshotsAge{
+						Remote: config.SnapshotsRemoteAge{MaxSlots: 1600},
+						Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+					},
+				},
+			}
+
+			if err := New(cfg).Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			_, err := os.Stat(filepath.Join(snapshotDir, incrFilename))
+			if downloaded := err == nil; downloaded != tt.wantDownload {
+				t.Errorf("downloaded = %v, want %v", downloaded, tt.wantDownload)
+			}
+		})
+	}
+}
+
+func TestRun_IncrementalChain(t *testing.T) {
+	// The source publishes a newer incremental each time one is downloaded
+	incrementals := []string{
+		"incremental-snapshot-100000-100500-HashA.tar.zst",
+		"incremental-snapshot-100000-101500-HashB.tar.zst",
+		"incremental-snapshot-100000-102000-HashC.tar.zst",
+		"incremental-snapshot-100000-102050-HashD.tar.zst",
+	}
+	var served atomic.Int64
+	snapServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := incrementals[min(int(served.Load()), len(incrementals)-1)]
+		if r.Method == http.MethodHead {
+			if strings.Contains(r.URL.Path, "incremental-snapshot.tar.bz2") {
+				w.Header().Set("Location", "/"+current)
+				w.WriteHeader(http.StatusFound)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Length", "4")
+		w.Write([]byte("data"))
+		served.Add(1)
+	}))
+	defer snapServer.Close()
+
+	localRPC := rpcServer(t, "PassivePubkey", 102100, nil)
+	defer localRPC.Close()
+	clusterRPC := rpcServer(t, "", 102100, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapServer.URL},
+	})
+	defer clusterRPC.Close()
+
+	for _, tt := range []struct {
+		name   string
+		chain  config.SnapshotsDownloadChain
+		want   []string
+		newest string
+		slot   uint64
+	}{
+		{"disabled", config.SnapshotsDownloadChain{}, incrementals[:1], incrementals[0], 100500},
+		{"until within max_slots_behind", config.SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: 300, MaxDownloads: 5}, incrementals[:3], incrementals[2], 102000},
+		{"max_downloads", config.SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: 300, MaxDownloads: 1}, incrementals[:2], incrementals[1], 101500},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			served.Store(0)
+			snapshotDir := t.TempDir()
+			os.WriteFile(filepath.Join(snapshotDir, "snapshot-100000-HashFull.tar.zst"), []byte("data"), 0644)
+
+			cfg := &config.Config{
+				Validator: config.Validator{RPCURL: localRPC.URL, ActiveIdentityPubkey: "ActivePubkey"},
+				Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+				Snapshots: config.Snapshots{
+					Directory: snapshotDir,
+					Discovery: config.Discovery{
+						Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+						Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+					},
+					Download: config.SnapshotsDownload{Connections: 1, IncrementalChain: tt.chain},
+					Age: config.SnapshotsAge{
+						Remote: config.SnapshotsRemoteAge{MaxSlots: 1600},
+						Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+					},
+				},
+			}
+			k := NewWithOptions(cfg, Options{Slots: &fixedSlots{slot: 102100}})
+			if err := k.Run(context.Background()); err != nil {
+				t.Fatal(err)
+			}
+			if got := int(served.Load()); got != len(tt.want) {
+				t.Errorf("downloaded %d incrementals, want %d", got, len(tt.want))
+			}
+			// Pruning keeps only the newest incremental
+			if _, err := os.Stat(filepath.Join(snapshotDir, tt.newest)); err != nil {
+				t.Errorf("expected %s to be kept: %v", tt.newest, err)
+			}
+			if k.decision.SnapshotSlot != tt.slot {
+				t.Errorf("decision slot = %d, want the newest incremental %d", k.decision.SnapshotSlot, tt.slot)
+			}
+		})
+	}
+}
+
+func TestRun_IncrementalChain_FullMode(t *testing.T) {
+	fullFilename := "snapshot-100000-HashFull.tar.zst"
+	incrementals := []string{
+		"incremental-snapshot-100000-100500-HashA.tar.zst",
+		"incremental-snapshot-100000-101500-HashB.tar.zst",
+		"incremental-snapshot-100000-102000-HashC.tar.zst",
+	}
+	var served atomic.Int64
+	snapServer := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		current := incrementals[min(int(served.Load()), len(incrementals)-1)]
+		if r.Method == http.MethodHead {
+			switch {
+			case strings.Contains(r.URL.Path, "incremental-snapshot.tar.bz2"):
+				w.Header().Set("Location", "/"+current)
+			case strings.Contains(r.URL.Path, "snapshot.tar.bz2"):
+				w.Header().Set("Location", "/"+fullFilename)
+			default:
+				w.WriteHeader(http.StatusNotFound)
+				return
+			}
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		w.Header().Set("Content-Length", "4")
+		w.Write([]byte("data"))
+		if strings.Contains(r.URL.Path, "incremental") {
+			served.Add(1)
+		}
+	}))
+	defer snapServer.Close()
+
+	localRPC := rpcServer(t, "PassivePubkey", 102100, nil)
+	defer localRPC.Close()
+	clusterRPC := rpcServer(t, "", 102100, []map[string]any{
+		{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": snapServer.URL},
+	})
+	defer clusterRPC.Close()
+
+	snapshotDir := t.TempDir()
+	cfg := &config.Config{
+		Validator: config.Validator{RPCURL: localRPC.URL, ActiveIdentityPubkey: "ActivePubkey"},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{MinSuitableFull: 3, MinSuitableIncremental: 5, SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatency: "5s", MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{
+				Connections:      1,
+				IncrementalChain: config.SnapshotsDownloadChain{Enabled: true, MaxSlotsBehind: 300, MaxDownloads: 5},
+			},
+			Age: config.SnapshotsAge{
+				Remote: config.SnapshotsRemoteAge{MaxSlots: 25000},
+				Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+			},
+		},
+	}
+	k := NewWithOptions(cfg, Options{Slots: &fixedSlots{slot: 102100}})
+	if err := k.Run(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if got := served.Load(); got != int64(len(incrementals)) {
+		t.Errorf("downloaded %d incrementals, want %d", got, len(incrementals))
+	}
+	for _, name := range []string{fullFilename, incrementals[2]} {
+		if _, err := os.Stat(filepath.Join(snapshotDir, name)); err != nil {
+			t.Errorf("expected %s to be kept: %v", name, err)
+		}
+	}
+	// The chain doesn't change what a full cycle reports
+	if k.decision.Mode != string(modeFull) || k.decision.SnapshotSlot != 100000 {
+		t.Errorf("decision = %s at slot %d, want the full at 100000", k.decision.Mode, k.decision.SnapshotSlot)
+	}
+}
+
+func TestNextLeaderWindow(t *testing.T) {
+	// Leader slots come in groups of four
+	leaders := []uint64{1100, 1101, 1102, 1103, 1200, 1201, 1202, 1203, 2000, 2001, 2002, 2003}
+	tests := []struct {
+		name   string
+		slot   uint64
+		margin uint64
+		want   leaderWindow
+		ok     bool
+	}{
+		{"before first window", 1000, 20, leaderWindow{1080, 1123}, true},
+		{"inside window", 1090, 20, leaderWindow{1080, 1123}, true},
+		{"overlapping windows merge", 1090, 50, leaderWindow{1050, 1253}, true},
+		{"after a window", 1130, 20, leaderWindow{1180, 1223}, true},
+		{"last window", 1500, 20, leaderWindow{1980, 2023}, true},
+		{"no more windows", 2024, 20, leaderWindow{}, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, ok := nextLeaderWindow(leaders, tt.slot, tt.margin)
+			if ok != tt.ok || got != tt.want {
+				t.Errorf("nextLeaderWindow() = %+v, %v, want %+v, %v", got, ok, tt.want, tt.ok)
+			}
+		})
+	}
+	if _, ok := nextLeaderWindow(nil, 1000, 20); ok {
+		t.Error("expected no window without leader slots")
+	}
+}
+
+func TestWaitForLeaderWindow(t *testing.T) {
+	var slot, scheduleCalls atomic.Uint64
+	slot.Store(1090)
+	clusterRPC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Method string `json:"method"`
+			ID     int    `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		var result any
+		switch req.Method {
+		case "getEpochInfo":
+			s := slot.Load()
+			result = rpc.EpochInfo{AbsoluteSlot: s, Epoch: 1, SlotIndex: s - 1000, SlotsInEpoch: 432_000}
+		case "getLeaderSchedule":
+			scheduleCalls.Add(1)
+			result = map[string][]uint64{"ActiveKey": {100, 101, 102, 103}}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resultJSON, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": json.RawMessage(resultJSON)})
+	}))
+	defer clusterRPC.Close()
+
+	fc := clock.NewFake(time.Now())
+	cfg := &config.Config{
+		Validator: config.Validator{ActiveIdentityPubkey: "ActiveKey"},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{
+			Directory:      t.TempDir(),
+			LeaderSchedule: config.LeaderSchedule{WindowSlots: 20},
+		},
+	}
+	k := NewWithOptions(cfg, Options{Clock: fc})
+
+	done := make(chan error, 1)
+	go func() { done <- k.waitForLeaderWindow(context.Background()) }()
+
+	// Slot 1090 is inside the window 1080-1123, 34 slots from its end
+	fc.BlockUntil(1)
+	select {
+	case err := <-done:
+		t.Fatalf("expected to wait inside the leader window, returned %v", err)
+	default:
+	}
+	slot.Store(1124)
+	fc.Advance(34 * k.slotTime())
+
+	select {
+	case err := <-done:
+		if err != nil {
+			t.Fatal(err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the wait to end after the window")
+	}
+	if got := scheduleCalls.Load(); got != 1 {
+		t.Errorf("expected the epoch's leader schedule to be fetched once, got %d", got)
+	}
+}
+
+func TestMonitorIdentity_PausesAndResumesDownload(t *testing.T) {
+	var identity atomic.Value
+	identity.Store("PassiveKey")
+	localRPC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			ID int `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": map[string]string{"identity": identity.Load().(string)}})
+	}))
+	defer localRPC.Close()
+
+	data := make([]byte, 512*1024)
+	for i := range data {
+		data[i] = byte(i % 251)
+	}
+	// While stalling, each range is served halfway and then hangs
+	var stalling atomic.Bool
+	stalling.Store(true)
+	stalled := make(chan struct{}, 2)
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			w.Header().Set("Accept-Ranges", "bytes")
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			return
+		}
+		var start, end int
+		fmt.Sscanf(r.Header.Get("Range"), "bytes=%d-%d", &start, &end)
+		w.Header().Set("Content-Length", strconv.Itoa(end-start+1))
+		w.WriteHeader(http.StatusPartialContent)
+		if stalling.Load() {
+			w.Write(data[start : start+(end-start+1)/2])
+			w.(http.Flusher).Flush()
+			stalled <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Write(data[start : end+1])
+	}))
+	defer source.Close()
+
+	fc := clock.NewFake(time.Now())
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Validator: config.Validator{
+			Client:               config.ClientAgave,
+			RPCURL:               localRPC.URL,
+			ActiveIdentityPubkey: "ActiveKey",
+			RoleMonitor:          config.RoleMonitor{IntervalDur: 10 * time.Second, OnActive: config.OnActivePause},
+		},
+		Snapshots: config.Snapshots{Directory: dir},
+	}
+	k := NewWithOptions(cfg, Options{Clock: fc})
+	k.pause = newRolePause()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	go k.monitorIdentity(ctx, cancel, k.pause)
+
+	node := discovery.SnapshotNode{
+		RPCURL:       source.URL,
+		SnapshotURL:  source.URL + "/snapshot-100-Hash.tar.zst",
+		SnapshotType: discovery.SnapshotTypeFull,
+		Filename:     "snapshot-100-Hash.tar.zst",
+	}
+	type fetched struct {
+		result *downloader.Result
+		err    error
+	}
+	done := make(chan fetched, 1)
+	go func() {
+		result, err := k.fetchPausable(ctx, node, downloader.Options{DownloadConnections: 2})
+		done <- fetched{result, err}
+	}()
+	<-stalled
+	<-stalled
+
+	// The validator becomes active: the download pauses, keeping its partial file
+	identity.Store("ActiveKey")
+	fc.BlockUntil(1)
+	fc.Advance(10 * time.Second)
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if partials, _ := filepath.Glob(filepath.Join(dir, "snapshot-100-Hash.tar.zst.tmp.*.partial")); len(partials) == 1 {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the paused download's partial state to be kept")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	select {
+	case f := <-done:
+		t.Fatalf("expected the download to wait while the validator is active, returned %v", f.err)
+	default:
+	}
+
+	// Passive again: the download resumes and completes
+	stalling.Store(false)
+	identity.Store("PassiveKey")
+	fc.Advance(10 * time.Second)
+	select {
+	case f := <-done:
+		if f.err != nil {
+			t.Fatal(f.err)
+		}
+		got, _ := os.ReadFile(f.result.FilePath)
+		if string(got) != string(data) {
+			t.Error("resumed download doesn't match the source")
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the download to resume once the validator is passive")
+	}
+}
+
+func TestRun_MaxCycleDuration_FailsWithHook(t *testing.T) {
+	// The validator RPC hangs, wedging the cycle at its first step
+	localRPC := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-r.Context().Done()
+	}))
+	defer localRPC.Close()
+	clusterRPC := rpcServer(t, "", 1000, nil)
+	defer clusterRPC.Close()
+
+	hookLog := filepath.Join(t.TempDir(), "hooks.log")
+	fc := clock.NewFake(time.Now())
+	cfg := &config.Config{
+		Validator: config.Validator{Client: config.ClientAgave, RPCURL: localRPC.URL, ActiveIdentityPubkey: "ActiveKey"},
+		Cluster:   config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+		Snapshots: config.Snapshots{Directory: t.TempDir()},
+		Hooks: config.Hooks{OnFailure: []config.HookCommand{{
+			Name: "failure",
+			Cmd:  "sh",
+			Args: []string{"-c", "echo '{{ .Error }}' >> " + hookLog},
+		}}},
+		Keeper: config.Keeper{MaxCycleDurationDur: 10 * time.Minute},
+	}
+	k := NewWithOptions(cfg, Options{Clock: fc})
+
+	done := make(chan error, 1)
+	go func() { done <- k.Run(context.Background()) }()
+	fc.BlockUntil(1)
+	fc.Advance(10 * time.Minute)
+
+	select {
+	case err := <-done:
+		if !errors.Is(err, errCycleDeadline) {
+			t.Fatalf("expected the cycle deadline error, got %v", err)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected the cycle to stop at its deadline")
+	}
+
+	data, err := os.ReadFile(hookLog)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], "max_cycle_duration") {
+		t.Errorf("expected the failure hook to run once with the overrun, got %q", lines)
+	}
+	if d, _ := k.LastDecision(); d.Result != string(resultFailure) {
+		t.Errorf("expected a failed cycle, got %+v", d)
+	}
+}
+
+func TestRun_CaughtUpValidator_SkipsDownload(t *testing.T) {
+	clusterRPC := rpcServer(t, "", 100000, nil)
+	defer clusterRPC.Close()
+
+	for _, tt := range []struct {
+		name      string
+		localSlot uint64
+		wantSkip  bool
+	}{
+		{"caught up", 99990, true},
+		{"lagging", 99000, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			localRPC := rpcServer(t, "PassivePubkey", tt.localSlot, nil)
+			defer localRPC.Close()
+
+			snapshotDir := t.TempDir()
+			// Too old by max_incremental_slots, so a download is due
+			os.WriteFile(filepath.Join(snapshotDir, "snapshot-90000-HashA.tar.zst"), []byte("data"), 0644)
+
+			cfg := &config.Config{
+				Validator: config.Validator{
+					RPCURL:               localRPC.URL,
+					ActiveIdentityPubkey: "ActivePubkey",
+					CaughtUp:             config.CaughtUp{MaxSlotsBehind: 50, SkipDownloads: true},
+				},
+				Cluster: config.Cluster{Name: "testnet", RPCURL: clusterRPC.URL},
+				Snapshots: config.Snapshots{
+					Directory: snapshotDir,
+					Age: config.SnapshotsAge{
+						Remote: config.SnapshotsRemoteAge{MaxSlots: 1300},
+						Local:  config.SnapshotsLocalAge{MaxIncrementalSlots: 1300},
+					},
+				},
+			}
+
+			k := New(cfg)
+			err := k.Run(context.Background())
+			d, _ := k.LastDecision()
+			if d.LocalSlot != tt.localSlot || d.localSlotsBehind() != strconv.FormatUint(100000-tt.localSlot, 10) {
+				t.Errorf("expected the local slot in the decision, got %+v", d)
+			}
+			skipped := err == nil && d.Result == "skipped" && d.Reason == "validator caught up with the cluster"
+			if skipped != tt.wantSkip {
+				t.Errorf("skipped = %v, want %v (decision %+v, error %v)", skipped, tt.wantSkip, d, err)
+			}
+		})
+	}
+}
+
+func TestDownload_Attestation(t *testing.T) {
+	data := []byte("fake snapshot data for testing purposes")
+	filename := "snapshot-100-Hash.tar.zst"
+	snapServer := snapshotServer(t, filename, data)
+	defer snapServer.Close()
+	sum := sha256.Sum256(data)
+
+	for _, tt := range []struct {
+		name         string
+		manifest     string // "" = endpoint down
+		required     bool
+		wantErr      bool
+		wantKept     bool
+		wantCooldown bool
+	}{
+		{"match", `{"100": "` + hex.EncodeToString(sum[:]) + `"}`, true, false, true, false},
+		{"mismatch", `{"100": "` + strings.Repeat("0", 64) + `"}`, false, true, false, true},
+		{"not listed", `{}`, false, false, true, false},
+		{"not listed, required", `{}`, true, true, false, false},
+		{"endpoint down", "", false, false, true, false},
+		{"endpoint down, required", "", true, true, false, false},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			trust := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if tt.manifest == "" {
+					w.WriteHeader(http.StatusServiceUnavailable)
+					return
+				}
+				w.Write([]byte(tt.manifest))
+			}))
+			defer trust.Close()
+
+			dir := t.TempDir()
+			cfg := &config.Config{
+				Snapshots: config.Snapshots{
+					Directory:   dir,
+					Download:    config.SnapshotsDownload{FailureCooldownDur: 10 * time.Minute},
+					Attestation: config.Attestation{URL: trust.URL, Required: tt.required},
+				},
+			}
+			k := NewWithOptions(cfg, Options{Clock: clock.NewFake(time.Now())})
+			node := discovery.SnapshotNode{
+				RPCURL:       snapServer.URL,
+				SnapshotURL:  snapServer.URL + "/" + filename,
+				SnapshotType: discovery.SnapshotTypeFull,
+				Slot:         100,
+				Filename:     filename,
+			}
+			_, err := k.download(context.Background(), node, downloader.Options{DownloadConnections: 1, DownloadTimeout: time.Minute})
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("download error = %v, wantErr %v", err, tt.wantErr)
+			}
+			if _, err := os.Stat(filepath.Join(dir, filename)); (err == nil) != tt.wantKept {
+				t.Errorf("archive kept = %v, want %v", err == nil, tt.wantKept)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(dir, filename+"*")); !tt.wantKept && len(matches) != 0 {
+				t.Errorf("expected the rejected download to be discarded, found %v", matches)
+			}
+			if k.skipCoolingDown(snapServer.URL) != tt.wantCooldown {
+				t.Errorf("source cooling down = %v, want %v", !tt.wantCooldown, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestPlanPrune_TmpDirectory(t *testing.T) {
+	snapshotDir, tmpDir := t.TempDir(), t.TempDir()
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst"} {
+		os.WriteFile(filepath.Join(snapshotDir, name), nil, 0644)
+	}
+	// A crashed download's temp file, another process's download in
+	// progress, and an unrelated file
+	fc := clock.NewFake(time.Now())
+	stale := filepath.Join(tmpDir, "snapshot-300-HashC.tar.zst.tmp.100.aa11")
+	os.WriteFile(stale, nil, 0644)
+	os.Chtimes(stale, fc.Now().Add(-2*time.Hour), fc.Now().Add(-2*time.Hour))
+	os.WriteFile(filepath.Join(tmpDir, "snapshot-400-HashE.tar.zst.tmp.200.bb22"), nil, 0644)
+	os.WriteFile(filepath.Join(tmpDir, "snapshot-50-HashD.tar.zst"), nil, 0644)
+
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Download:  config.SnapshotsDownload{TmpDirectory: tmpDir, StaleTmpAgeDur: time.Hour},
+		},
+	}
+	// The newest full is current, so it needs no incremental
+	plan, err := NewWithOptions(cfg, Options{Clock: fc, Slots: &fixedSlots{slot: 200}}).PlanPrune()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removed []string
+	for _, r := range plan.Remove {
+		removed = append(removed, filepath.Base(r.Path))
+	}
+	slices.Sort(removed)
+	if want := []string{"snapshot-100-HashA.tar.zst", "snapshot-300-HashC.tar.zst.tmp.100.aa11"}; !slices.Equal(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+}
+
+func TestPrune_RecordsAuditLog(t *testing.T) {
+	snapshotDir := t.TempDir()
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst"} {
+		os.WriteFile(filepath.Join(snapshotDir, name), nil, 0644)
+	}
+	auditFile := filepath.Join(t.TempDir(), "audit.jsonl")
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{Directory: snapshotDir},
+		Audit:     config.Audit{File: auditFile},
+	}
+	if err := NewWithOptions(cfg, Options{Slots: &fixedSlots{slot: 200}}).Prune(); err != nil {
+		t.Fatal(err)
+	}
+
+	data, err := os.ReadFile(auditFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 {
+		t.Fatalf("got %d audit events, want 1: %s", len(lines), data)
+	}
+	var e audit.Event
+	if err := json.Unmarshal([]byte(lines[0]), &e); err != nil {
+		t.Fatal(err)
+	}
+	if e.Action != audit.ActionDelete || e.Path != filepath.Join(snapshotDir, "snapshot-100-HashA.tar.zst") || !strings.HasPrefix(e.Reason, "pruned: old full snapshot") {
+		t.Errorf("unexpected audit event: %+v", e)
+	}
+}
+
+func TestEnsureFreeSpace(t *testing.T) {
+	snapshotDir := t.TempDir()
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst", "snapshot-300-HashC.tar.zst"} {
+		os.WriteFile(filepath.Join(snapshotDir, name), make([]byte, 10), 0644)
+	}
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Retention: config.Retention{MinFreeSpaceBytes: 15},
+		},
+	}
+	// 10 bytes free, plus whatever has been pruned
+	free := func(string) (int64, error) {
+		var used int64
+		entries, _ := os.ReadDir(snapshotDir)
+		for _, e := range entries {
+			info, _ := e.Info()
+			used += info.Size()
+		}
+		return 40 - used, nil
+	}
+	k := NewWithOptions(cfg, Options{FreeSpace: free})
+
+	if !k.FreeSpaceLow() {
+		t.Fatal("free space should be low")
+	}
+	if err := k.EnsureFreeSpace(); err != nil {
+		t.Fatal(err)
+	}
+	if k.FreeSpaceLow() {
+		t.Error("free space should be restored")
+	}
+	entries, _ := os.ReadDir(snapshotDir)
+	if len(entries) != 2 {
+		t.Errorf("got %d files, want 2 - only one needed deleting", len(entries))
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "snapshot-300-HashC.tar.zst")); err != nil {
+		t.Error("newest full should never be deleted")
+	}
+}
+
+func TestEnforceSizeBudget(t *testing.T) {
+	snapshotDir, quarantineDir := t.TempDir(), t.TempDir()
+	now := time.Now()
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-300-HashC.tar.zst"), make([]byte, 100), 0644)
+	for i, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst"} {
+		path := filepath.Join(quarantineDir, name)
+		os.WriteFile(path, make([]byte, 100), 0644)
+		at := now.Add(time.Duration(i-2) * time.Hour)
+		os.Chtimes(path, at, at)
+	}
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Retention: config.Retention{QuarantineDir: quarantineDir, QuarantineTTLDur: 24 * time.Hour, MaxTotalSizeBytes: 250},
+		},
+	}
+	if err := New(cfg).EnforceSizeBudget(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(quarantineDir, "snapshot-100-HashA.tar.zst")); !os.IsNotExist(err) {
+		t.Error("oldest quarantined snapshot should be evicted")
+	}
+	if _, err := os.Stat(filepath.Join(quarantineDir, "snapshot-200-HashB.tar.zst")); err != nil {
+		t.Error("newer quarantined snapshot fits the budget and should be kept")
+	}
+
+	// A budget below the newest full keeps it regardless
+	cfg.Snapshots.Retention.MaxTotalSizeBytes = 50
+	if err := New(cfg).EnforceSizeBudget(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "snapshot-300-HashC.tar.zst")); err != nil {
+		t.Error("newest full should never be evicted")
+	}
+}
+
+func TestPrune_HoldsPreviousFullUntilNewPairCompletes(t *testing.T) {
+	snapshotDir := t.TempDir()
+	for _, name := range []string{"snapshot-1000-HashA.tar.zst", "incremental-snapshot-1000-1500-HashB.tar.zst", "snapshot-5000-HashC.tar.zst"} {
+		os.WriteFile(filepath.Join(snapshotDir, name), nil, 0644)
+	}
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Age:       config.SnapshotsAge{Local: config.SnapshotsLocalAge{MaxIncrementalSlots: 1300}},
+		},
+	}
+	slots := &fixedSlots{slot: 8000}
+	k := NewWithOptions(cfg, Options{Slots: slots})
+	exists := func(name string) bool {
+		_, err := os.Stat(filepath.Join(snapshotDir, name))
+		return err == nil
+	}
+	// The new full is too old to use alone and its incremental failed
+	if err := k.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("snapshot-1000-HashA.tar.zst") || !exists("incremental-snapshot-1000-1500-HashB.tar.zst") {
+		t.Fatal("previous pair should be kept until the new full has an incremental")
+	}
+
+	// A fresh keeper, as after a restart, still holds it
+	if err := NewWithOptions(cfg, Options{Slots: slots}).Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if !exists("snapshot-1000-HashA.tar.zst") {
+		t.Fatal("previous full should be kept across restarts")
+	}
+
+	// The next cycle completes the new pair
+	os.WriteFile(filepath.Join(snapshotDir, "incremental-snapshot-5000-7900-HashD.tar.zst"), nil, 0644)
+	if err := k.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if exists("snapshot-1000-HashA.tar.zst") || exists("incremental-snapshot-1000-1500-HashB.tar.zst") {
+		t.Error("previous pair should be pruned once the new pair is complete")
+	}
+
+	// A new full fresh enough on its own replaces the pair straight away
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-7950-HashE.tar.zst"), nil, 0644)
+	if err := k.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if exists("snapshot-5000-HashC.tar.zst") {
+		t.Error("previous full should be pruned when the new full is usable alone")
+	}
+}
+
+func TestDownloadIndexedIncremental(t *testing.T) {
+	incrData := []byte("incremental building on the local full")
+	// The node's incremental doesn't match its own full, so it isn't a pair,
+	// but it builds on the local full at slot 99000
+	snapServer := pairedSnapshotServer(t, "snapshot-100000-HashFull.tar.zst", "incremental-snapshot-99000-100500-HashInc.tar.zst", []byte("full"), incrData)
+	defer snapServer.Close()
+	addr := snapServer.URL
+
+	snapshotDir := t.TempDir()
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: snapshotDir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{MinSpeedCheckDelay: "0s", Connections: 1, Timeout: "1m"},
+			Age:      config.SnapshotsAge{Remote: config.SnapshotsRemoteAge{MaxSlots: 1300}},
+		},
+	}
+	k := New(cfg)
+	opts := k.discoveryOptions()
+	opts.BaseSlots = discovery.NewBaseSlotIndex()
+
+	if paired := discovery.DiscoverPairedNodes(context.Background(), []rpc.ClusterNode{{Pubkey: "node1", RPC: &addr}}, 100600, opts); len(paired) != 0 {
+		t.Fatalf("expected no pairs, got %d", len(paired))
+	}
+	_, node, ok := k.downloadIndexedIncremental(context.Background(), opts.BaseSlots, 99000, 0, k.downloadOptions())
+	if !ok || node.BaseSlot != 99000 || node.Slot != 100500 {
+		t.Fatalf("got %+v, %v; want the incremental for base 99000", node, ok)
+	}
+	data, err := os.ReadFile(filepath.Join(snapshotDir, "incremental-snapshot-99000-100500-HashInc.tar.zst"))
+	if err != nil || string(data) != string(incrData) {
+		t.Errorf("incremental not downloaded: %v", err)
+	}
+
+	if _, _, ok := k.downloadIndexedIncremental(context.Background(), opts.BaseSlots, 98000, 0, k.downloadOptions()); ok {
+		t.Error("no incremental was indexed for base 98000")
+	}
+}
+
+func TestDownloadFull_SwitchesToNewerFull(t *testing.T) {
+	var current atomic.Value
+	current.Store("snapshot-100000-Old.tar.zst")
+	stalled := make(chan struct{}, 1)
+	newData := []byte("the newer full snapshot")
+	source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodHead {
+			if strings.Contains(r.URL.Path, "snapshot.tar.bz2") {
+				w.Header().Set("Location", "/"+current.Load().(string))
+				w.WriteHeader(http.StatusFound)
+				return
+			}
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Path == "/snapshot-100000-Old.tar.zst" {
+			// A transfer that barely gets going
+			w.Header().Set("Content-Length", "1000000")
+			w.WriteHeader(http.StatusOK)
+			w.Write([]byte("old"))
+			w.(http.Flusher).Flush()
+			stalled <- struct{}{}
+			<-r.Context().Done()
+			return
+		}
+		w.Header().Set("Content-Length", strconv.Itoa(len(newData)))
+		w.WriteHeader(http.StatusOK)
+		w.Write(newData)
+	}))
+	defer source.Close()
+
+	fc := clock.NewFake(time.Now())
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: dir,
+			Discovery: config.Discovery{
+				Candidates: config.DiscoveryCandidates{SortOrder: "latency"},
+				Probe:      config.DiscoveryProbe{MaxLatencyDuration: 5 * time.Second, Concurrency: 10},
+			},
+			Download: config.SnapshotsDownload{
+				SwitchToNewer: config.SnapshotsDownloadSwitch{Enabled: true, CheckIntervalDur: 5 * time.Minute, MinSlotDelta: 20000, MaxProgress: 0.25},
+			},
+		},
+	}
+	k := NewWithOptions(cfg, Options{Clock: fc, Slots: &fixedSlots{125100}})
+
+	node := discovery.SnapshotNode{
+		RPCURL:       source.URL,
+		SnapshotURL:  source.URL + "/snapshot-100000-Old.tar.zst",
+		SnapshotType: discovery.SnapshotTypeFull,
+		Slot:         100000,
+		Filename:     "snapshot-100000-Old.tar.zst",
+	}
+	type fetched struct {
+		result *downloader.Result
+		node   discovery.SnapshotNode
+		err    error
+	}
+	done := make(chan fetched, 1)
+	go func() {
+		result, got, err := k.downloadFull(context.Background(), node, nil, downloader.Options{DownloadConnections: 1})
+		done <- fetched{result, got, err}
+	}()
+	<-stalled
+
+	// The source moves on to a full 25000 slots newer while the old one trickles in
+	current.Store("snapshot-125000-New.tar.zst")
+	fc.BlockUntil(1)
+	fc.Advance(5 * time.Minute)
+
+	select {
+	case f := <-done:
+		if f.err != nil {
+			t.Fatal(f.err)
+		}
+		if f.node.Slot != 125000 {
+			t.Fatalf("downloaded slot %d, want the newer full at 125000", f.node.Slot)
+		}
+		got, _ := os.ReadFile(f.result.FilePath)
+		if string(got) != string(newData) {
+			t.Errorf("downloaded %q, want the newer full's data", got)
+		}
+	case <-time.After(10 * time.Second):
+		t.Fatal("download didn't switch to the newer full")
+	}
+}
+
+func TestDownload_SignedManifest(t *testing.T) {
+	data := []byte("fake snapshot data for testing purposes")
+	filename := "snapshot-100-Hash.tar.zst"
+	sum := sha256.Sum256(data)
+	trustedPub, trustedKey, _ := ed25519.GenerateKey(rand.Reader)
+	_, otherKey, _ := ed25519.GenerateKey(rand.Reader)
+
+	for _, tt := range []struct {
+		name         string
+		key          ed25519.PrivateKey
+		sha256       string
+		wantErr      error
+		wantFetched  bool
+		wantKept     bool
+		wantCooldown bool
+	}{
+		{"signed by trusted key", trustedKey, hex.EncodeToString(sum[:]), nil, true, true, false},
+		{"signed by other key", otherKey, hex.EncodeToString(sum[:]), attestation.ErrUnsigned, false, false, false},
+		{"archive differs", trustedKey, strings.Repeat("0", 64), attestation.ErrMismatch, true, false, true},
+	} {
+		t.Run(tt.name, func(t *testing.T) {
+			payload, _ := json.Marshal(attestation.SignedManifest{Snapshots: []attestation.SignedEntry{
+				{Filename: filename, Slot: 100, SHA256: tt.sha256, Size: int64(len(data))},
+			}})
+			envelope, _ := json.Marshal(attestation.SignedEnvelope{
+				Manifest: base64.StdEncoding.EncodeToString(payload),
+				Signatures: []attestation.Signature{{
+					Pubkey:    attestation.FormatPubkey(tt.key.Public().(ed25519.PublicKey)),
+					Signature: base64.StdEncoding.EncodeToString(ed25519.Sign(tt.key, payload)),
+				}},
+			})
+			var fetched atomic.Bool
+			source := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				switch {
+				case r.URL.Path == attestation.DefaultManifestPath:
+					if ua := r.Header.Get("User-Agent"); ua != "test-keeper" {
+						t.Errorf("expected the signed manifest request to carry snapshots.http's User-Agent, got %q", ua)
+					}
+					w.Write(envelope)
+				case r.Method == http.MethodGet:
+					fetched.Store(true)
+					w.Write(data)
+				}
+			}))
+			defer source.Close()
+
+			dir := t.TempDir()
+			cfg := &config.Config{
+				Snapshots: config.Snapshots{
+					Directory: dir,
+					Download:  config.SnapshotsDownload{FailureCooldownDur: 10 * time.Minute},
+					Attestation: config.Attestation{
+						TrustedPubkeys: []string{attestation.FormatPubkey(trustedPub)},
+						TrustedKeys:    []ed25519.PublicKey{trustedPub},
+					},
+					HTTP: config.SnapshotsHTTP{Parsed: http.Header{"User-Agent": {"test-keeper"}}},
+				},
+			}
+			k := NewWithOptions(cfg, Options{Clock: clock.NewFake(time.Now())})
+			node := discovery.SnapshotNode{
+				RPCURL:       source.URL,
+				SnapshotURL:  source.URL + "/" + filename,
+				SnapshotType: discovery.SnapshotTypeFull,
+				Slot:         100,
+				Filename:     filename,
+			}
+			_, err := k.download(context.Background(), node, downloader.Options{DownloadConnections: 1, DownloadTimeout: time.Minute})
+			if !errors.Is(err, tt.wantErr) || (err != nil) != (tt.wantErr != nil) {
+				t.Fatalf("download error = %v, want %v", err, tt.wantErr)
+			}
+			if fetched.Load() != tt.wantFetched {
+				t.Errorf("archive fetched = %v, want %v", fetched.Load(), tt.wantFetched)
+			}
+			if _, err := os.Stat(filepath.Join(dir, filename)); (err == nil) != tt.wantKept {
+				t.Errorf("archive kept = %v, want %v", err == nil, tt.wantKept)
+			}
+			if matches, _ := filepath.Glob(filepath.Join(dir, filename+"*")); !tt.wantKept && len(matches) != 0 {
+				t.Errorf("expected the rejected download to be discarded, found %v", matches)
+			}
+			if k.skipCoolingDown(source.URL) != tt.wantCooldown {
+				t.Errorf("source cooling down = %v, want %v", !tt.wantCooldown, tt.wantCooldown)
+			}
+		})
+	}
+}
+
+func TestCheckDiskThroughput(t *testing.T) {
+	dir := t.TempDir()
+	cfg := &config.Config{
+		Snapshots: config.Snapshots{
+			Directory: dir,
+			Download: config.SnapshotsDownload{
+				MinSpeedBytes: 60 << 20,
+				Connections:   8,
+				DiskCheck:     config.SnapshotsDownloadDiskCheck{Enabled: true, SizeBytes: 1 << 20},
+			},
+		},
+	}
+	k := NewWithOptions(cfg, Options{Clock: clock.NewFake(time.Now())})
+	k.checkDiskThroughput(context.Background())
+	if !k.diskChecked {
+		t.Fatal("expected the download directory to be benchmarked")
+	}
+	if entries, _ := os.ReadDir(dir); len(entries) != 0 {
+		t.Errorf("benchmark left %d files behind", len(entries))
+	}
+}
+
+func TestBusyRecordsActivity(t *testing.T) {
+	fc := clock.NewFake(time.Now())
+	k := NewWithOptions(&config.Config{}, Options{Clock: fc})
+	start := fc.Now()
+
+	done := k.busy(context.Background())
+	if !k.LastActivity().Equal(start) {
+		t.Errorf("expected activity when the step starts, got %s", k.LastActivity())
+	}
+	fc.BlockUntil(1)
+	fc.Advance(busyInterval)
+	deadline := time.Now().Add(time.Second)
+	for !k.LastActivity().Equal(start.Add(busyInterval)) {
+		if time.Now().After(deadline) {
+			t.Fatalf("expected activity after %s, last activity %s", busyInterval, k.LastActivity())
+		}
+		time.Sleep(time.Millisecond)
+	}
+
+	done()
+	fc.Advance(busyInterval)
+	if !k.LastActivity().Equal(start.Add(busyInterval)) {
+		t.Errorf("expected no activity once the step finished, got %s", k.LastActivity())
+	}
+}
+
+func TestDownloadTransportOptions_HTTP2ByDefault(t *testing.T) {
+	cfg := &config.Config{Snapshots: config.Snapshots{Download: config.SnapshotsDownload{Connections: 4}}}
+	if opts := downloadTransportOptions(cfg); opts.DisableHTTP2 || opts.MaxIdleConnsPerHost != 4 {
+		t.Errorf("zero-value transport gave %+v, want HTTP/2 and one idle connection per download connection", opts)
+	}
+	cfg.Snapshots.Download.Transport.DisableHTTP2 = true
+	if !downloadTransportOptions(cfg).DisableHTTP2 {
+		t.Error("transport.disable_http2 should disable HTTP/2")
+	}
+}
@@ -0,0 +1,156 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// errLeaderWindow cancels a download when one of the active identities'
+// leader windows starts.
+var errLeaderWindow = errors.New("leader window started")
+
+// maxLeaderInterruptions bounds how often one download is restarted for
+// leader windows before the candidate is given up on.
+const maxLeaderInterruptions = 3
+
+// leaderSchedule caches the active identities' absolute leader slots for one
+// epoch; the schedule is fixed for the epoch.
+type leaderSchedule struct {
+	epoch uint64
+	slots []uint64
+}
+
+// leaderWindow is a span of slots around one or more adjacent leader slots.
+type leaderWindow struct {
+	start, end uint64 // inclusive
+}
+
+// nextLeaderWindow returns the first window of margin slots either side of
+// the sorted leader slots that hasn't ended by slot. Overlapping windows are
+// merged.
+func nextLeaderWindow(leaderSlots []uint64, slot, margin uint64) (leaderWindow, bool) {
+	var w leaderWindow
+	found := false
+	for _, l := range leaderSlots {
+		start := l - min(l, margin)
+		end := l + margin
+		if !found {
+			if end < slot {
+				continue
+			}
+			w, found = leaderWindow{start, end}, true
+			continue
+		}
+		if start > w.end+1 {
+			break
+		}
+		w.end = end
+	}
+	return w, found
+}
+
+// upcomingLeaderWindow returns the current slot and the next leader window of
+// the active identities. ok is false when there is none this epoch or it can't
+// be determined; leader awareness then doesn't hold anything up.
+func (k *Keeper) upcomingLeaderWindow(ctx context.Context) (slot uint64, w leaderWindow, ok bool) {
+	epoch, err := k.clusterRPC.GetEpochInfo(ctx)
+	if err != nil {
+		k.logger().Warn("could not get epoch info, ignoring leader schedule", "error", err)
+		return 0, w, false
+	}
+
+	k.leaderMu.Lock()
+	cached := k.leaders
+	k.leaderMu.Unlock()
+	if cached == nil || cached.epoch != epoch.Epoch {
+		// Any of the active identities may be the one voting
+		var slots []uint64
+		for _, identity := range k.activeIdentities() {
+			indexes, err := k.clusterRPC.GetLeaderSchedule(ctx, identity)
+			if err != nil {
+				k.logger().Warn("could not get leader schedule, ignoring it", "identity", identity, "error", err)
+				return 0, w, false
+			}
+			for _, idx := range indexes {
+				slots = append(slots, epoch.FirstSlot()+idx)
+			}
+		}
+		slices.Sort(slots)
+		cached = &leaderSchedule{epoch: epoch.Epoch, slots: slots}
+		k.leaderMu.Lock()
+		k.leaders = cached
+		k.leaderMu.Unlock()
+	}
+
+	margin := uint64(k.slotSetting(&k.cfg.Snapshots.LeaderSchedule.WindowSlots))
+	w, ok = nextLeaderWindow(cached.slots, epoch.AbsoluteSlot, margin)
+	return epoch.AbsoluteSlot, w, ok
+}
+
+// waitForLeaderWindow returns once the current slot is outside the active
+// identity's leader windows.
+func (k *Keeper) waitForLeaderWindow(ctx context.Context) error {
+	for {
+		slot, w, ok := k.upcomingLeaderWindow(ctx)
+		if !ok || w.start > slot {
+			return nil
+		}
+		remaining := w.end - slot + 1
+		k.logger().Info(fmt.Sprintf("within a leader window of an active identity - waiting %s before downloading", k.slotsToTime(remaining)),
+			"slot", slot,
+			"window_end", w.end,
+		)
+		select {
+		case <-k.clock.After(time.Duration(remaining) * k.slotTime()):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// fetchAroundLeaderSlots fetches a snapshot outside the active identities'
+// leader windows. It waits for a current window to pass and, with
+// abort_downloads, cancels the download when the next one starts and retries
+// it afterwards.
+func (k *Keeper) fetchAroundLeaderSlots(ctx context.Context, node discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, error) {
+	if k.slotSetting(&k.cfg.Snapshots.LeaderSchedule.WindowSlots) <= 0 {
+		return k.fetchPausable(ctx, node, dlOpts)
+	}
+
+	for interruptions := 0; ; interruptions++ {
+		if err := k.waitForLeaderWindow(ctx); err != nil {
+			return nil, err
+		}
+		if !k.cfg.Snapshots.LeaderSchedule.AbortDownloads {
+			return k.fetchPausable(ctx, node, dlOpts)
+		}
+
+		fetchCtx, cancel := context.WithCancelCause(ctx)
+		if slot, w, ok := k.upcomingLeaderWindow(ctx); ok {
+			go func() {
+				select {
+				case <-k.clock.After(time.Duration(w.start-slot) * k.slotTime()):
+					cancel(errLeaderWindow)
+				case <-fetchCtx.Done():
+				}
+			}()
+		}
+		result, err := k.fetchPausable(fetchCtx, node, dlOpts)
+		interrupted := errors.Is(context.Cause(fetchCtx), errLeaderWindow)
+		cancel(nil)
+		if !interrupted {
+			return result, err
+		}
+
+		if interruptions+1 >= maxLeaderInterruptions {
+			return nil, fmt.Errorf("download interrupted by %d leader windows: %w", maxLeaderInterruptions, errLeaderWindow)
+		}
+		k.logger().Info("leader window of an active identity starting - download aborted, retrying after it", "node", node.RPCURL)
+	}
+}
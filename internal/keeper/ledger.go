@@ -0,0 +1,47 @@
+package keeper
+
+import (
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+// ledgerSnapshots returns the archives the validator wrote to its ledger
+// directory itself (validator.ledger_directory). A running passive validator
+// may produce fresher snapshots than any source serves.
+func (k *Keeper) ledgerSnapshots() []pruner.SnapshotFile {
+	dir := k.cfg.Validator.LedgerDirectory
+	if dir == "" {
+		return nil
+	}
+	snapshots, err := pruner.GetLocalSnapshots(dir)
+	if err != nil {
+		k.logger().Warn("could not read snapshots in the validator's ledger directory", "directory", dir, "error", err)
+		return nil
+	}
+	return snapshots
+}
+
+// ledgerFresh reports whether the validator's own snapshots are within the
+// local freshness thresholds, so nothing needs downloading.
+func (k *Keeper) ledgerFresh(currentSlot uint64) bool {
+	snapshots := k.ledgerSnapshots()
+	if len(snapshots) == 0 {
+		return false
+	}
+	newestSlot := pruner.NewestSlot(snapshots)
+	newestFull := pruner.NewestFullSnapshot(snapshots)
+	if newestFull == nil {
+		return false
+	}
+	if maxFull := uint64(k.slotSetting(&k.cfg.Snapshots.Age.Local.MaxFullSlots)); maxFull > 0 && currentSlot > newestFull.Slot && currentSlot-newestFull.Slot > maxFull {
+		return false
+	}
+
+	age := currentSlot - min(newestSlot, currentSlot)
+	if age > k.maxIncrementalSlots() {
+		return false
+	}
+	k.logger().Info(fmt.Sprintf("validator's own snapshot behind network by %d slots (%s), within target", age, k.slotsToTime(age)), "slot", newestSlot, "directory", k.cfg.Validator.LedgerDirectory)
+	return true
+}
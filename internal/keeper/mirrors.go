@@ -0,0 +1,27 @@
+package keeper
+
+import (
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// withMirrors returns dlOpts with the other candidates serving the same
+// snapshot as node as mirrors, so a failed chunk of node's download can be
+// fetched from them. Snapshot file names carry the slot and hash, so a
+// matching name is the same file.
+func (k *Keeper) withMirrors(dlOpts downloader.Options, node discovery.SnapshotNode, others []discovery.SnapshotNode) downloader.Options {
+	if k.cfg.Snapshots.Download.ChunkRetries == 0 {
+		return dlOpts
+	}
+	dlOpts.Mirrors = nil
+	for _, other := range others {
+		if other.Filename != node.Filename || other.SnapshotURL == node.SnapshotURL {
+			continue
+		}
+		if _, cooling := k.cooldowns.coolingDown(other.RPCURL, k.clock.Now()); cooling {
+			continue
+		}
+		dlOpts.Mirrors = append(dlOpts.Mirrors, other.SnapshotURL)
+	}
+	return dlOpts
+}
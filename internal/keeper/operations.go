@@ -0,0 +1,253 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+// Discover probes the cluster for snapshots of the given type using the
+// configured trust, probe and remote age rules. Unlike a cycle it doesn't stop
+// at min_suitable; every suitable node is returned, sorted by sort_order.
+func (k *Keeper) Discover(ctx context.Context, snapshotType discovery.SnapshotType) ([]discovery.SnapshotNode, error) {
+	report, err := k.DiscoverReport(ctx, snapshotType)
+	return report.Nodes, err
+}
+
+// DiscoverReport is like Discover, but also reports why each rejected node
+// was found unsuitable.
+func (k *Keeper) DiscoverReport(ctx context.Context, snapshotType discovery.SnapshotType) (discovery.DiscoveryReport, error) {
+	currentSlot, err := k.slots.GetSlot(ctx)
+	if err != nil {
+		return discovery.DiscoveryReport{}, fmt.Errorf("getting current slot: %w", err)
+	}
+	clusterNodes, err := k.clusterRPC.GetClusterNodes(ctx)
+	if err != nil {
+		return discovery.DiscoveryReport{}, fmt.Errorf("getting cluster nodes: %w", err)
+	}
+	clusterNodes, err = k.trustedNodes(ctx, clusterNodes)
+	if err != nil {
+		return discovery.DiscoveryReport{}, err
+	}
+	clusterNodes = k.excludeOwnNodes(ctx, clusterNodes, "")
+
+	opts := k.discoveryOptions()
+	opts.Stream = false
+	report := discovery.DiscoverNodes(ctx, clusterNodes, currentSlot, snapshotType, opts)
+	k.countRejections(snapshotType, report.Rejections)
+	k.rememberCandidates()
+	return report, nil
+}
+
+// Download fetches node's snapshot into the validator's snapshot directory
+// with the configured speed checks, limits, delta reuse and ownership. It
+// doesn't check the validator's role or local freshness.
+func (k *Keeper) Download(ctx context.Context, node discovery.SnapshotNode) (*downloader.Result, error) {
+	return k.download(ctx, node, k.downloadOptions())
+}
+
+// SampleThroughput measures the throughput a download of node's snapshot
+// reaches over connections parallel requests within duration, without
+// writing anything. Per-source limits apply.
+func (k *Keeper) SampleThroughput(ctx context.Context, node discovery.SnapshotNode, connections int, duration time.Duration) (downloader.SampleResult, error) {
+	return downloader.Sample(ctx, node.SnapshotURL, connections, duration, k.downloadOptions())
+}
+
+// ResolveSource resolves an explicitly chosen source to the snapshot it
+// serves: a snapshot archive URL as is, otherwise the latest full snapshot of
+// the node with that RPC URL or identity pubkey. Latency and age limits don't
+// apply.
+func (k *Keeper) ResolveSource(ctx context.Context, source string) (discovery.SnapshotNode, error) {
+	if node, ok := discovery.SourceFromURL(source); ok {
+		return node, nil
+	}
+	addr := source
+	if !strings.Contains(source, "://") {
+		nodes, err := k.clusterRPC.GetClusterNodes(ctx)
+		if err != nil {
+			return discovery.SnapshotNode{}, fmt.Errorf("getting cluster nodes: %w", err)
+		}
+		if addr, err = discovery.NodeAddress(nodes, source); err != nil {
+			return discovery.SnapshotNode{}, err
+		}
+	}
+	node, err := discovery.ProbeSource(ctx, addr, discovery.SnapshotTypeFull, k.discoveryOptions())
+	if err != nil {
+		return discovery.SnapshotNode{}, fmt.Errorf("probing %s: %w", addr, err)
+	}
+	return node, nil
+}
+
+// ResolveIncremental finds the incremental that full's source serves on top
+// of it.
+func (k *Keeper) ResolveIncremental(ctx context.Context, full discovery.SnapshotNode) (discovery.SnapshotNode, error) {
+	inc, err := discovery.ProbeSource(ctx, full.RPCURL, discovery.SnapshotTypeIncremental, k.discoveryOptions())
+	if err != nil {
+		return discovery.SnapshotNode{}, fmt.Errorf("probing %s: %w", full.RPCURL, err)
+	}
+	if inc.BaseSlot != full.Slot {
+		return discovery.SnapshotNode{}, fmt.Errorf("%s serves an incremental for base slot %d, not %d", full.RPCURL, inc.BaseSlot, full.Slot)
+	}
+	return inc, nil
+}
+
+// Prune removes superseded snapshots and temp files from the validator's
+// snapshot directories, and temp files from snapshots.download.tmp_directory.
+func (k *Keeper) Prune() error {
+	plan, err := k.PlanPrune()
+	if err != nil {
+		return err
+	}
+	k.ApplyPrune(plan)
+	return nil
+}
+
+// ApplyPrune removes plan's files, or with snapshots.retention.quarantine_dir
+// moves superseded snapshots there, recording each in the audit log.
+func (k *Keeper) ApplyPrune(plan pruner.Plan) {
+	dir := k.cfg.Snapshots.Retention.QuarantineDir
+	if dir == "" {
+		for _, r := range plan.Apply() {
+			k.auditLog.Record(audit.Event{Action: audit.ActionDelete, Path: r.Path, Reason: "pruned: " + r.Reason})
+			k.reportPruned(r.Path, "delete", r.Reason)
+		}
+		return
+	}
+	for _, r := range plan.Quarantine(dir, k.clock.Now()) {
+		if r.Quarantine {
+			k.auditLog.Record(audit.Event{Action: audit.ActionRename, Path: pruner.QuarantinePath(dir, r.Path), From: r.Path, Reason: "quarantined: " + r.Reason})
+			k.reportPruned(r.Path, "quarantine", r.Reason)
+		} else {
+			k.auditLog.Record(audit.Event{Action: audit.ActionDelete, Path: r.Path, Reason: "pruned: " + r.Reason})
+			k.reportPruned(r.Path, "delete", r.Reason)
+		}
+	}
+}
+
+// AuditLog returns where changes to the snapshot directory are recorded; it
+// is nil unless audit.file is set.
+func (k *Keeper) AuditLog() *audit.Log {
+	return k.auditLog
+}
+
+// PlanPrune works out what Prune would remove, and why.
+func (k *Keeper) PlanPrune() (pruner.Plan, error) {
+	full, incremental := k.client.SnapshotDirs()
+	opts := pruner.Options{TempMinAge: k.cfg.Snapshots.Download.StaleTmpAgeDur, Now: k.clock.Now(), FullUsableAlone: k.fullUsableAlone}
+	plan, err := pruner.PlanPruneWithOptions(opts, full, incremental)
+	if err != nil {
+		return pruner.Plan{}, err
+	}
+	if tmp := k.cfg.Snapshots.Download.TmpDirectory; tmp != "" && tmp != full && tmp != incremental {
+		temps, err := pruner.PlanTempFiles(tmp, opts)
+		if err != nil {
+			return pruner.Plan{}, err
+		}
+		plan.Remove = append(plan.Remove, temps...)
+	}
+	if r := k.cfg.Snapshots.Retention; r.QuarantineDir != "" {
+		expired, err := pruner.PlanQuarantineExpiry(r.QuarantineDir, r.QuarantineTTLDur, k.clock.Now())
+		if err != nil {
+			return pruner.Plan{}, err
+		}
+		plan.Remove = append(plan.Remove, expired...)
+	}
+	return plan, nil
+}
+
+func (k *Keeper) discoveryOptions() discovery.Options {
+	d := k.cfg.Snapshots.Discovery
+	opts := discovery.Options{
+		MaxLatency:          d.Probe.MaxLatencyDuration,
+		MaxSnapshotAgeSlots: k.slotSetting(&k.cfg.Snapshots.Age.Remote.MaxSlots),
+		ProbeConcurrency:    d.Probe.Concurrency,
+		AutoTune:            d.Probe.AutoTune,
+		SortOrder:           d.Candidates.SortOrder,
+		Stream:              d.Stream,
+		ProbeSamples:        d.Probe.Samples,
+		LatencyStat:         d.Probe.LatencyStat,
+		Transport:           k.probeTransport,
+		HealthCheck:         d.Probe.HealthCheck,
+		MinVersion:          d.Probe.MinVersion,
+		IPv6:                d.Candidates.IPv6,
+		MaxDuration:         d.Probe.MaxDurationDur,
+		SlotTime:            k.slotTime(),
+		Geo:                 k.geoOptions(),
+		Peers:               k.peerURLs(),
+		Content: discovery.ContentOptions{
+			Manifests: d.Content.Manifests,
+			Gateways:  d.Content.IPFSGateways,
+		},
+		Score: discovery.ScoreWeights{
+			SlotAge:    d.Candidates.ScoreWeights.SlotAge,
+			Latency:    d.Candidates.ScoreWeights.Latency,
+			Reputation: d.Candidates.ScoreWeights.Reputation,
+			Geo:        d.Candidates.ScoreWeights.Geo,
+		},
+		Reputation:            k.reputation,
+		BackgroundConcurrency: d.Probe.BackgroundConcurrency,
+		OnProbed:              k.probed,
+		Validator:             k.cfg.Name,
+	}
+	if d.Candidates.Remember > 0 {
+		opts.Remembered = k.candidates.addresses()
+		opts.OnSuitable = k.candidates.observe
+	}
+	return opts
+}
+
+// geoOptions applies snapshots.discovery.geo, when its databases loaded.
+func (k *Keeper) geoOptions() discovery.GeoOptions {
+	if k.geo == nil {
+		return discovery.GeoOptions{}
+	}
+	g := k.cfg.Snapshots.Discovery.Geo
+	return discovery.GeoOptions{
+		Locate:           k.geo.Locate,
+		Continents:       g.Continents,
+		Countries:        g.Countries,
+		ExcludeASNs:      g.ExcludeASNs,
+		PreferContinents: g.PreferContinents,
+		PreferCountries:  g.PreferCountries,
+	}
+}
+
+func (k *Keeper) downloadOptions() downloader.Options {
+	dl := k.cfg.Snapshots.Download
+	return downloader.Options{
+		MinDownloadSpeedBytes: dl.MinSpeedBytes,
+		MinSpeedCheckDelay:    dl.MinSpeedCheckDelayDur,
+		DownloadConnections:   dl.Connections,
+		DownloadTimeout:       dl.TimeoutDur,
+		Client:                &http.Client{Transport: k.downloadTransport},
+		PerSource: downloader.SourceLimits{
+			MaxConnections:  dl.PerSource.MaxConnections,
+			MaxBytesPerSec:  dl.PerSource.MaxBandwidthBytes,
+			RequestInterval: dl.PerSource.RequestIntervalDur,
+		},
+		Progress:        k.progress,
+		StallTimeout:    dl.StallTimeoutDur,
+		StallRetries:    dl.StallRetries,
+		ChunkRetries:    dl.ChunkRetries,
+		ChunkRetryDelay: dl.ChunkRetryDelayDur,
+		Adaptive: downloader.AdaptiveConnections{
+			Enabled:  dl.AdaptiveConnections.Enabled,
+			Initial:  dl.AdaptiveConnections.InitialConnections,
+			Interval: dl.AdaptiveConnections.IntervalDur,
+			MinGain:  dl.AdaptiveConnections.MinGain,
+		},
+		Preallocate:  dl.Preallocate,
+		DirectIO:     dl.DirectIO,
+		TempDir:      dl.TmpDirectory,
+		VerifyRanges: dl.VerifyRanges,
+		Activity:     k.touch,
+		Validator:    k.cfg.Name,
+	}
+}
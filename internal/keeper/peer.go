@@ -0,0 +1,75 @@
+package keeper
+
+import (
+	"context"
+	"net"
+	"net/netip"
+	"net/url"
+	"slices"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// peerLookupTimeout bounds resolving a peer's hostname.
+const peerLookupTimeout = 5 * time.Second
+
+// peerURLs returns peers.urls without the ones pointing at this machine, so
+// a fleet can share one list without a keeper downloading from itself.
+func (k *Keeper) peerURLs() []string {
+	urls := k.cfg.Peers.URLs
+	if len(urls) == 0 {
+		return nil
+	}
+	local, err := discovery.LocalPrefixes()
+	if err != nil {
+		k.logger().Warn("could not list local addresses - peers on this machine won't be skipped", "error", err)
+	}
+	var out []string
+	for _, raw := range urls {
+		u, err := url.Parse(raw)
+		if err != nil {
+			continue
+		}
+		if isLocalHost(u.Hostname(), local) {
+			k.logger().Debug("skipping peer on this machine", "url", raw)
+			continue
+		}
+		out = append(out, raw)
+	}
+	return out
+}
+
+// isLocalHost reports whether host is a loopback address or resolves to one
+// of this machine's addresses.
+func isLocalHost(host string, local []netip.Prefix) bool {
+	if host == "localhost" {
+		return true
+	}
+	addrs := []netip.Addr{}
+	if ip, err := netip.ParseAddr(host); err == nil {
+		addrs = append(addrs, ip)
+	} else {
+		ctx, cancel := context.WithTimeout(context.Background(), peerLookupTimeout)
+		defer cancel()
+		resolved, err := net.DefaultResolver.LookupNetIP(ctx, "ip", host)
+		if err != nil {
+			logger().Warn("resolving peer failed", "host", host, "error", err)
+			return false
+		}
+		addrs = resolved
+	}
+	for _, a := range addrs {
+		a = a.Unmap()
+		if a.IsLoopback() || slices.ContainsFunc(local, func(p netip.Prefix) bool { return p.Contains(a) }) {
+			return true
+		}
+	}
+	return false
+}
+
+// SnapshotDirs returns the directories full and incremental archives are
+// kept in, e.g. for serving them to peers.
+func (k *Keeper) SnapshotDirs() (full, incremental string) {
+	return k.client.SnapshotDirs()
+}
@@ -0,0 +1,36 @@
+package keeper
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strconv"
+)
+
+// processes returns the command line and working directory of every
+// process, from /proc/<pid>/cmdline and /proc/<pid>/cwd. Processes that exit
+// meanwhile are skipped.
+func processes() ([]process, error) {
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		return nil, err
+	}
+	var out []process
+	for _, p := range procs {
+		if _, err := strconv.Atoi(p.Name()); err != nil {
+			continue
+		}
+		data, err := os.ReadFile(filepath.Join("/proc", p.Name(), "cmdline"))
+		if err != nil || len(data) == 0 {
+			continue
+		}
+		var proc process
+		for _, arg := range bytes.Split(bytes.TrimRight(data, "\x00"), []byte{0}) {
+			proc.args = append(proc.args, string(arg))
+		}
+		// Unreadable for other users' processes without privileges
+		proc.cwd, _ = os.Readlink(filepath.Join("/proc", p.Name(), "cwd"))
+		out = append(out, proc)
+	}
+	return out, nil
+}
@@ -0,0 +1,10 @@
+//go:build !linux
+
+package keeper
+
+import "errors"
+
+// processes is only implemented on Linux.
+func processes() ([]process, error) {
+	return nil, errors.New("reading process command lines is only supported on Linux")
+}
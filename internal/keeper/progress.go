@@ -0,0 +1,35 @@
+package keeper
+
+import (
+	"os"
+	"time"
+
+	"github.com/mattn/go-isatty"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// progressLogInterval is how often download progress is logged in log mode.
+const progressLogInterval = 30 * time.Second
+
+// newProgressReporter picks how download progress is shown. In auto mode the
+// progress bar is only drawn when stderr is a terminal and logs are text;
+// under journald or a JSON pipeline it would corrupt the output.
+func newProgressReporter(cfg config.Log) downloader.ProgressReporter {
+	mode := cfg.Progress
+	if mode == config.ProgressAuto || mode == "" {
+		mode = config.ProgressLog
+		if cfg.Format == "text" && isatty.IsTerminal(os.Stderr.Fd()) {
+			mode = config.ProgressTTY
+		}
+	}
+	switch mode {
+	case config.ProgressTTY:
+		return downloader.NewTTYProgress(os.Stderr)
+	case config.ProgressNone:
+		return downloader.SilentProgress{}
+	default:
+		return downloader.NewLogProgress(progressLogInterval)
+	}
+}
@@ -0,0 +1,103 @@
+package keeper
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+)
+
+// reputationFilename records how reliably sources served snapshots, so
+// sort_order "score" can favour the dependable ones across runs.
+const reputationFilename = "solana-validator-snapshot-keeper.reputation.json"
+
+// reputationWeight is how much the latest download moves a source's
+// reputation, so it follows a source whose reliability changes.
+const reputationWeight = 0.3
+
+// reputationExpiry is how long a source's reputation is kept without a
+// download from it, so sources that left the cluster don't pile up and one
+// that returns after a long absence starts afresh.
+const reputationExpiry = 30 * 24 * time.Hour
+
+// reputation is a source's track record, from 0 (every recent download
+// failed) to 1 (every one succeeded), and when a download last added to it.
+type reputation struct {
+	Score float64   `json:"score"`
+	Seen  time.Time `json:"seen"`
+}
+
+// sourceReputations maps a source's RPC URL to its reputation.
+type sourceReputations struct {
+	mu     sync.Mutex
+	path   string
+	scores map[string]reputation
+}
+
+func loadSourceReputations(dir string) *sourceReputations {
+	r := &sourceReputations{path: filepath.Join(dir, reputationFilename), scores: map[string]reputation{}}
+	data, err := os.ReadFile(r.path)
+	if err != nil {
+		return r
+	}
+	if err := json.Unmarshal(data, &r.scores); err != nil {
+		logger().Warn("ignoring unreadable source reputations", "path", r.path, "error", err)
+		r.scores = map[string]reputation{}
+	}
+	return r
+}
+
+// score returns source's reputation at now; an expired one is neutral. It
+// is called from probe goroutines.
+func (r *sourceReputations) score(source string, now time.Time) float64 {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if s, ok := r.scores[source]; ok && now.Sub(s.Seen) < reputationExpiry {
+		return s.Score
+	}
+	return discovery.NeutralReputation
+}
+
+// record moves source's reputation towards the outcome of a download,
+// dropping expired entries.
+func (r *sourceReputations) record(source string, ok bool, now time.Time) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	for s, rep := range r.scores {
+		if now.Sub(rep.Seen) >= reputationExpiry {
+			delete(r.scores, s)
+		}
+	}
+	s, known := r.scores[source]
+	if !known {
+		s.Score = discovery.NeutralReputation
+	}
+	outcome := 0.0
+	if ok {
+		outcome = 1
+	}
+	r.scores[source] = reputation{Score: s.Score + reputationWeight*(outcome-s.Score), Seen: now}
+
+	data, err := json.MarshalIndent(r.scores, "", "  ")
+	if err != nil {
+		return
+	}
+	if err := os.WriteFile(r.path, data, 0644); err != nil {
+		logger().Error("failed to write source reputations", "path", r.path, "error", err)
+	}
+}
+
+// recordReputation tracks a download's outcome for sort_order "score".
+func (k *Keeper) recordReputation(source string, ok bool) {
+	if k.cfg.Snapshots.Discovery.Candidates.SortOrder == "score" {
+		k.reputations.record(source, ok, k.clock.Now())
+	}
+}
+
+// reputation returns source's reputation for sort_order "score".
+func (k *Keeper) reputation(source string) float64 {
+	return k.reputations.score(source, k.clock.Now())
+}
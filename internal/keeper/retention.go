@@ -0,0 +1,149 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+// slotLookupTimeout bounds reading the cluster's slot while planning a prune.
+const slotLookupTimeout = 10 * time.Second
+
+// fullUsableAlone reports whether a full snapshot at slot is within
+// max_incremental_slots of the cluster, so it is no worse than the pair it
+// replaces without an incremental. Pruning keeps the previous pair until it
+// is, or an incremental for it lands. Without the cluster's slot it isn't.
+func (k *Keeper) fullUsableAlone(slot uint64) bool {
+	ctx, cancel := context.WithTimeout(context.Background(), slotLookupTimeout)
+	defer cancel()
+	current, err := k.slots.GetSlot(ctx)
+	if err != nil {
+		k.logger().Warn("could not get the cluster's slot - treating the newest full as needing an incremental", "error", err)
+		return false
+	}
+	return current >= slot && current-slot <= k.maxIncrementalSlots()
+}
+
+// FreeSpaceLow reports whether free space on the snapshots directory's
+// filesystem is below snapshots.retention.min_free_space.
+func (k *Keeper) FreeSpaceLow() bool {
+	minFree := k.cfg.Snapshots.Retention.MinFreeSpaceBytes
+	if minFree <= 0 {
+		return false
+	}
+	free, err := k.freeSpace(k.cfg.Snapshots.Directory)
+	if err != nil {
+		k.logger().Warn("could not check free space", "error", err)
+		return false
+	}
+	return free < minFree
+}
+
+// enforceRetention applies snapshots.retention.max_total_size and
+// min_free_space. A cycle does so before downloading, to make room with
+// anything pruning would remove and quarantined snapshots, and again after
+// pruning, when only quarantined snapshots are left to evict for what the
+// downloads took up.
+func (k *Keeper) enforceRetention() {
+	if err := k.EnforceSizeBudget(); err != nil {
+		k.logger().Error("enforcing max_total_size failed", "error", err)
+	}
+	if err := k.EnsureFreeSpace(); err != nil {
+		k.logger().Error("freeing disk space failed", "error", err)
+	}
+}
+
+// EnsureFreeSpace deletes files, oldest first, while free space on the
+// snapshots directory's filesystem is below
+// snapshots.retention.min_free_space.
+func (k *Keeper) EnsureFreeSpace() error {
+	minFree := k.cfg.Snapshots.Retention.MinFreeSpaceBytes
+	if minFree <= 0 {
+		return nil
+	}
+	dir := k.cfg.Snapshots.Directory
+	free, err := k.freeSpace(dir)
+	if err != nil {
+		return fmt.Errorf("checking free space: %w", err)
+	}
+	if free >= minFree {
+		return nil
+	}
+	k.logger().Warn("free space below min_free_space, deleting the oldest prunable files", "free", formatBytes(free), "min_free_space", formatBytes(minFree))
+
+	err = k.evictOldest("freeing space", func() (bool, error) {
+		free, err = k.freeSpace(dir)
+		return free >= minFree, err
+	})
+	if err != nil {
+		return fmt.Errorf("checking free space: %w", err)
+	}
+	if free < minFree {
+		k.logger().Error("free space still below min_free_space - nothing else may be deleted", "free", formatBytes(free), "min_free_space", formatBytes(minFree))
+	}
+	return nil
+}
+
+// EnforceSizeBudget deletes files, oldest first, while the snapshot
+// directories, and the quarantine directory, hold more than
+// snapshots.retention.max_total_size.
+func (k *Keeper) EnforceSizeBudget() error {
+	budget := k.cfg.Snapshots.Retention.MaxTotalSizeBytes
+	if budget <= 0 {
+		return nil
+	}
+	full, incremental := k.client.SnapshotDirs()
+	dirs := []string{full, incremental, k.cfg.Snapshots.Retention.QuarantineDir}
+	total, err := pruner.DirSize(dirs...)
+	if err != nil {
+		return fmt.Errorf("measuring snapshot directories: %w", err)
+	}
+	if total <= budget {
+		return nil
+	}
+	k.logger().Warn("snapshot directories exceed max_total_size, deleting the oldest prunable files", "total", formatBytes(total), "max_total_size", formatBytes(budget))
+
+	err = k.evictOldest("over size budget", func() (bool, error) {
+		total, err = pruner.DirSize(dirs...)
+		return total <= budget, err
+	})
+	if err != nil {
+		return fmt.Errorf("measuring snapshot directories: %w", err)
+	}
+	if total > budget {
+		k.logger().Error("snapshot directories still exceed max_total_size - the newest full snapshot and its incremental are kept regardless", "total", formatBytes(total), "max_total_size", formatBytes(budget))
+	}
+	return nil
+}
+
+// evictOldest deletes the files pruning would remove, then quarantined
+// snapshots, oldest first, until enough reports the target is met or fails.
+// Nothing is deleted while incident mode has pruning frozen.
+func (k *Keeper) evictOldest(why string, enough func() (bool, error)) error {
+	if reason, active := k.IncidentActive(); active {
+		k.logger().Warn(fmt.Sprintf("not deleting files %s - incident mode has pruning frozen", why), "incident", reason)
+		return nil
+	}
+	plan, err := k.PlanPrune()
+	if err != nil {
+		return err
+	}
+	candidates, err := pruner.PlanFreeSpace(plan, k.cfg.Snapshots.Retention.QuarantineDir)
+	if err != nil {
+		return err
+	}
+	var checkErr error
+	removed := pruner.FreeUntil(candidates, func() bool {
+		ok, err := enough()
+		checkErr = err
+		return ok || err != nil
+	})
+	for _, r := range removed {
+		k.auditLog.Record(audit.Event{Action: audit.ActionDelete, Path: r.Path, Reason: why + ": " + r.Reason})
+		k.reportPruned(r.Path, "delete", why+": "+r.Reason)
+	}
+	return checkErr
+}
@@ -0,0 +1,149 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"sync"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// rolePause holds downloads back while the validator is active. Pausing
+// cancels running downloads with downloader.ErrPaused, which keeps their
+// partial files for resuming.
+type rolePause struct {
+	mu      sync.Mutex
+	active  bool
+	passive chan struct{} // closed once the validator is passive again
+	running map[int]context.CancelCauseFunc
+	nextID  int
+}
+
+func newRolePause() *rolePause {
+	return &rolePause{running: map[int]context.CancelCauseFunc{}}
+}
+
+// setActive records the validator's role and reports whether it changed.
+func (p *rolePause) setActive(active bool) bool {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if active == p.active {
+		return false
+	}
+	p.active = active
+	if active {
+		p.passive = make(chan struct{})
+		for _, cancel := range p.running {
+			cancel(downloader.ErrPaused)
+		}
+	} else {
+		close(p.passive)
+	}
+	return true
+}
+
+// wait returns once the validator is passive.
+func (p *rolePause) wait(ctx context.Context) error {
+	p.mu.Lock()
+	passive := p.passive
+	active := p.active
+	p.mu.Unlock()
+	if !active {
+		return nil
+	}
+	select {
+	case <-passive:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+// track registers a running download to be paused with cancel. It is paused
+// straight away if the validator became active since wait returned.
+func (p *rolePause) track(cancel context.CancelCauseFunc) (untrack func()) {
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.active {
+		cancel(downloader.ErrPaused)
+	}
+	id := p.nextID
+	p.nextID++
+	p.running[id] = cancel
+	return func() {
+		p.mu.Lock()
+		defer p.mu.Unlock()
+		delete(p.running, id)
+	}
+}
+
+// fetchPausable is fetch, pausing the download while the validator is active
+// when validator.role_monitor.on_active is "pause".
+func (k *Keeper) fetchPausable(ctx context.Context, node discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, error) {
+	if k.pause == nil {
+		return k.fetch(ctx, node, dlOpts)
+	}
+	for {
+		if err := k.pause.wait(ctx); err != nil {
+			return nil, err
+		}
+		fetchCtx, cancel := context.WithCancelCause(ctx)
+		untrack := k.pause.track(cancel)
+		result, err := k.fetch(fetchCtx, node, dlOpts)
+		untrack()
+		paused := errors.Is(context.Cause(fetchCtx), downloader.ErrPaused)
+		cancel(nil)
+		if !paused {
+			return result, err
+		}
+		k.logger().Info("download paused until the validator is passive again", "node", node.RPCURL)
+	}
+}
+
+// monitorIdentity polls the validator's identity while downloads run. When
+// the validator becomes active it aborts them with cancel, or with a pause
+// gate pauses them until it is passive again.
+func (k *Keeper) monitorIdentity(ctx context.Context, cancel context.CancelFunc, pause *rolePause) {
+	interval := k.cfg.Validator.RoleMonitor.IntervalDur
+	if interval <= 0 {
+		interval = config.DefaultRoleMonitorInterval
+	}
+	ticker := k.clock.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C():
+			identity, _, err := k.validatorIdentity(ctx)
+			if err != nil {
+				continue // RPC might be temporarily unavailable
+			}
+			active := k.isActiveIdentity(identity)
+			if pause == nil {
+				if active {
+					k.logger().Warn("validator became active during download, aborting")
+					cancel()
+					return
+				}
+				continue
+			}
+
+			if pause.setActive(active) {
+				if active {
+					k.logger().Warn("validator became active during download, pausing")
+				} else {
+					k.logger().Info("validator is passive again, resuming download")
+				}
+			}
+			if active {
+				// Waiting out the active spell is progress as far as the
+				// lock heartbeat is concerned
+				k.touch()
+			}
+		}
+	}
+}
@@ -0,0 +1,100 @@
+package keeper
+
+import (
+	"context"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/rpc"
+)
+
+const (
+	// slotTimeRefresh is how often cluster.slot_time "auto" is re-estimated
+	slotTimeRefresh = 10 * time.Minute
+	// slotTimeSamples is how many of the cluster's per-minute performance
+	// samples an estimate averages over
+	slotTimeSamples = 30
+	// Estimates outside these bounds are taken to be bad samples
+	minSlotTime = 100 * time.Millisecond
+	maxSlotTime = 5 * time.Second
+)
+
+// slotTime is how long a slot takes: the latest estimate with
+// cluster.slot_time "auto", else cluster.slot_time.
+func (k *Keeper) slotTime() time.Duration {
+	k.slotTimeMu.Lock()
+	defer k.slotTimeMu.Unlock()
+	if k.slotTimeEstimate > 0 {
+		return k.slotTimeEstimate
+	}
+	return k.cfg.Cluster.SlotDuration()
+}
+
+// slotSetting reads field, one of the config's slot settings, which
+// refreshSlotTime may convert again while the status server reads them.
+func (k *Keeper) slotSetting(field *int) int {
+	k.slotTimeMu.Lock()
+	defer k.slotTimeMu.Unlock()
+	return *field
+}
+
+// refreshSlotTime re-estimates the slot time from the cluster's recent
+// performance samples with cluster.slot_time "auto", at most every
+// slotTimeRefresh, and converts slot settings given as durations with it.
+// The previous estimate (or 400ms) stays in use when sampling fails.
+func (k *Keeper) refreshSlotTime(ctx context.Context) {
+	if !k.cfg.Cluster.SlotTimeAuto {
+		return
+	}
+	now := k.clock.Now()
+	k.slotTimeMu.Lock()
+	due := k.slotTimeSampledAt.IsZero() || now.Sub(k.slotTimeSampledAt) >= slotTimeRefresh
+	if due {
+		k.slotTimeSampledAt = now
+	}
+	k.slotTimeMu.Unlock()
+	if !due {
+		return
+	}
+
+	samples, err := k.clusterRPC.GetRecentPerformanceSamples(ctx, slotTimeSamples)
+	if err != nil {
+		k.logger().Warn("could not sample the cluster's slot time, keeping the previous estimate", "slot_time", k.slotTime(), "error", err)
+		return
+	}
+	estimate, ok := estimateSlotTime(samples)
+	if !ok {
+		k.logger().Warn("cluster's performance samples gave no usable slot time, keeping the previous estimate", "slot_time", k.slotTime(), "samples", len(samples))
+		return
+	}
+
+	k.slotTimeMu.Lock()
+	k.slotTimeEstimate = estimate
+	converted := k.cfg.ApplySlotTime(estimate)
+	k.slotTimeMu.Unlock()
+	k.logger().Debug("estimated slot time", "slot_time", estimate, "samples", len(samples))
+	k.metrics.Gauge("cluster.slot_time_ms", float64(estimate.Milliseconds()), map[string]string{"cluster": k.cfg.Cluster.Name})
+	if converted {
+		k.logger().Info("slot settings given as durations converted with the estimated slot time", "slot_time", estimate)
+	}
+}
+
+// estimateSlotTime averages the slot time over samples, weighting each by
+// its length.
+func estimateSlotTime(samples []rpc.PerformanceSample) (time.Duration, bool) {
+	var slots, secs uint64
+	for _, s := range samples {
+		if s.NumSlots == 0 || s.SamplePeriodSecs == 0 {
+			continue
+		}
+		slots += s.NumSlots
+		secs += s.SamplePeriodSecs
+	}
+	if slots == 0 {
+		return 0, false
+	}
+	estimate := time.Duration(secs) * time.Second / time.Duration(slots)
+	if estimate < minSlotTime || estimate > maxSlotTime {
+		return 0, false
+	}
+	return estimate, true
+}
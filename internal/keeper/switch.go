@@ -0,0 +1,126 @@
+package keeper
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math"
+	"sync/atomic"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+)
+
+// errNewerFull cancels a full download that is still early when a
+// substantially newer full appears.
+var errNewerFull = errors.New("substantially newer full snapshot available")
+
+// maxRivals is how many of the other top candidates are re-probed alongside
+// the node a full is downloading from.
+const maxRivals = 4
+
+// maxSwitches bounds how often one full download moves to a newer full, so
+// a fast-moving cluster can't keep it from ever finishing.
+const maxSwitches = 2
+
+// fractionReporter remembers the completed fraction of the download it
+// watches, then passes progress on to the configured reporter.
+type fractionReporter struct {
+	downloader.ProgressReporter
+	fraction atomic.Uint64 // math.Float64bits
+}
+
+func (f *fractionReporter) Report(p downloader.Progress) {
+	f.fraction.Store(math.Float64bits(p.Fraction()))
+	if f.ProgressReporter != nil {
+		f.ProgressReporter.Report(p)
+	}
+}
+
+func (f *fractionReporter) Done(p downloader.Progress) {
+	if f.ProgressReporter != nil {
+		f.ProgressReporter.Done(p)
+	}
+}
+
+func (f *fractionReporter) done() float64 {
+	return math.Float64frombits(f.fraction.Load())
+}
+
+// downloadFull downloads a full snapshot. With switch_to_newer, node and its
+// rivals are re-probed every check_interval while the download is early; if
+// one now serves a full at least min_slot_delta slots newer, the download is
+// abandoned for it. It returns the node whose snapshot was downloaded.
+func (k *Keeper) downloadFull(ctx context.Context, node discovery.SnapshotNode, rivals []discovery.SnapshotNode, dlOpts downloader.Options) (*downloader.Result, discovery.SnapshotNode, error) {
+	if !k.cfg.Snapshots.Download.SwitchToNewer.Enabled || node.SnapshotType != discovery.SnapshotTypeFull {
+		result, err := k.download(ctx, node, k.withMirrors(dlOpts, node, rivals))
+		return result, node, err
+	}
+
+	for switches := 0; ; switches++ {
+		watched := append([]discovery.SnapshotNode{node}, rivals[:min(len(rivals), maxRivals)]...)
+		progress := &fractionReporter{ProgressReporter: dlOpts.Progress}
+		opts := k.withMirrors(dlOpts, node, rivals)
+		opts.Progress = progress
+
+		fetchCtx, cancel := context.WithCancelCause(ctx)
+		var newer discovery.SnapshotNode
+		watching := make(chan struct{})
+		if switches < maxSwitches {
+			go func() {
+				defer close(watching)
+				if n, ok := k.watchForNewerFull(fetchCtx, node, watched, progress); ok {
+					newer = n
+					cancel(errNewerFull)
+				}
+			}()
+		} else {
+			close(watching)
+		}
+		result, err := k.download(fetchCtx, node, opts)
+		switched := errors.Is(context.Cause(fetchCtx), errNewerFull)
+		cancel(nil)
+		<-watching
+		if err == nil || !switched {
+			return result, node, err
+		}
+
+		k.logger().Info(fmt.Sprintf("full snapshot %d slots newer appeared early in the download - switching to it", newer.Slot-node.Slot),
+			"from", node.RPCURL,
+			"from_slot", node.Slot,
+			"to", newer.RPCURL,
+			"to_slot", newer.Slot,
+		)
+		rivals = watched
+		node = newer
+	}
+}
+
+// watchForNewerFull re-probes watched every check_interval for a full at
+// least min_slot_delta slots newer than current's. It gives up once the
+// download is past max_progress or ctx ends.
+func (k *Keeper) watchForNewerFull(ctx context.Context, current discovery.SnapshotNode, watched []discovery.SnapshotNode, progress *fractionReporter) (discovery.SnapshotNode, bool) {
+	cfg := k.cfg.Snapshots.Download.SwitchToNewer
+	for {
+		select {
+		case <-k.clock.After(cfg.CheckIntervalDur):
+		case <-ctx.Done():
+			return discovery.SnapshotNode{}, false
+		}
+		if done := progress.done(); done > cfg.MaxProgress {
+			k.logger().Debug("full download too far along to switch to a newer one", "progress", done)
+			return discovery.SnapshotNode{}, false
+		}
+
+		slot, err := k.slots.GetSlot(ctx)
+		if err != nil {
+			k.logger().Warn("could not get current slot, skipping re-probe for newer fulls", "error", err)
+			continue
+		}
+		for _, n := range discovery.Reprobe(ctx, watched, slot, discovery.SnapshotTypeFull, k.discoveryOptions()) {
+			if n.Slot >= current.Slot+uint64(cfg.MinSlotDelta) && !k.skipCoolingDown(n.RPCURL) {
+				return n, true
+			}
+		}
+	}
+}
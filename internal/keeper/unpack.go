@@ -0,0 +1,37 @@
+package keeper
+
+import (
+	"context"
+	"fmt"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/verify"
+)
+
+// unpackFull verifies a newly downloaded full snapshot and unpacks it into
+// the ledger and accounts paths with snapshots.unpack. Unpacking replaces
+// the ledger's bank snapshot, so it only happens while the validator isn't
+// running.
+func (k *Keeper) unpackFull(ctx context.Context, role string, node discovery.SnapshotNode, path string) error {
+	cfg := k.cfg.Snapshots.Unpack
+	if !cfg.Enabled || node.SnapshotType != discovery.SnapshotTypeFull {
+		return nil
+	}
+	// An identity read any way means the validator is running
+	if role != "unknown" || k.decision.Identity != "" {
+		k.logger().Warn("validator is running - not unpacking the snapshot over its ledger", "role", role)
+		return nil
+	}
+
+	defer k.busy(ctx)()
+	if _, err := verify.Archive(ctx, path, node.Slot, verify.Options{}); err != nil {
+		return classify(ErrorVerificationFailed, fmt.Errorf("verifying snapshot before unpacking: %w", err))
+	}
+	k.logger().Info("unpacking snapshot for ledger bootstrap", "file", path, "ledger", cfg.LedgerPath, "accounts", cfg.AccountsDir())
+	if _, err := verify.Unpack(ctx, path, verify.UnpackOptions{LedgerDir: cfg.LedgerPath, AccountsDir: cfg.AccountsDir()}); err != nil {
+		return fmt.Errorf("unpacking snapshot: %w", err)
+	}
+	k.auditLog.Record(audit.Event{Action: audit.ActionUnpack, Path: path, Reason: "ledger " + cfg.LedgerPath + ", accounts " + cfg.AccountsDir()})
+	return nil
+}
@@ -0,0 +1,214 @@
+// Package lock provides an advisory, cross-process lock file.
+//
+// Exclusion comes from an OS file lock (flock on Unix, LockFileEx on
+// Windows), which the kernel drops when the holder exits, so a crashed
+// instance never leaves a lock behind and a reused PID can't keep one alive.
+// The file's contents describe the holder for operators and error messages,
+// and carry a heartbeat the holder refreshes while it makes progress. With a
+// TTL, a holder whose heartbeat has gone stale is considered wedged and its
+// lock is taken over by replacing the file.
+package lock
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("lock") }
+
+// ErrLocked is returned by Acquire when another process holds the lock.
+var ErrLocked = errors.New("another instance is running")
+
+// ErrLost is returned by Heartbeat when another process took the lock over.
+var ErrLost = errors.New("lock taken over by another instance")
+
+// errWouldBlock is returned by tryLock when the file is already locked.
+var errWouldBlock = errors.New("lock would block")
+
+// maxAttempts bounds retries when the lock file is replaced while acquiring.
+const maxAttempts = 5
+
+// Info describes the process holding the lock.
+type Info struct {
+	PID         int    `json:"pid"`
+	StartedAt   string `json:"started_at"`
+	HeartbeatAt string `json:"heartbeat_at"`
+}
+
+// stale reports whether the heartbeat is older than ttl. Holders that never
+// wrote a heartbeat are not considered stale.
+func (i Info) stale(now time.Time, ttl time.Duration) bool {
+	at, err := time.Parse(time.RFC3339, i.HeartbeatAt)
+	return err == nil && now.Sub(at) > ttl
+}
+
+// Options configures how a lock is acquired.
+type Options struct {
+	// TTL is how stale a holder's heartbeat may get before the lock is taken
+	// over (0 = never)
+	TTL time.Duration
+	// Now returns the current time (nil = time.Now)
+	Now func() time.Time
+}
+
+func (o Options) now() time.Time {
+	if o.Now != nil {
+		return o.Now()
+	}
+	return time.Now()
+}
+
+// Lock is a held lock; release it with Release.
+type Lock struct {
+	path string
+	f    *os.File
+	info Info
+	// takenFrom describes the wedged holder the lock was taken over from
+	takenFrom *Info
+}
+
+// Acquire takes the lock at path without blocking, creating the file if
+// needed. It returns an error wrapping ErrLocked if another process holds it.
+func Acquire(path string) (*Lock, error) {
+	return AcquireWithOptions(path, Options{})
+}
+
+// AcquireWithOptions is Acquire, also taking over a lock whose holder's
+// heartbeat is older than opts.TTL.
+func AcquireWithOptions(path string, opts Options) (*Lock, error) {
+	var takenFrom *Info
+	for range maxAttempts {
+		f, err := os.OpenFile(path, os.O_RDWR|os.O_CREATE, 0644)
+		if err != nil {
+			return nil, fmt.Errorf("opening lock file: %w", err)
+		}
+
+		if err := tryLock(f); err != nil {
+			f.Close()
+			if errors.Is(err, errWouldBlock) {
+				info, _ := ReadInfo(path)
+				if opts.TTL > 0 && info.stale(opts.now(), opts.TTL) {
+					logger().Warn("taking over lock from wedged holder", "path", path, "holder_pid", info.PID, "heartbeat_at", info.HeartbeatAt, "ttl", opts.TTL)
+					if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+						return nil, fmt.Errorf("removing stale lock file: %w", err)
+					}
+					takenFrom = &info
+					continue
+				}
+				return nil, fmt.Errorf("%w (PID: %d, started: %s, heartbeat: %s)", ErrLocked, info.PID, info.StartedAt, info.HeartbeatAt)
+			}
+			return nil, fmt.Errorf("locking %s: %w", path, err)
+		}
+
+		// A holder releasing the lock removes the file; if that happened
+		// between our open and lock we hold a lock on an unlinked file, so
+		// start over on whatever is at path now
+		if !samePath(f, path) {
+			f.Close()
+			continue
+		}
+
+		now := opts.now().UTC().Format(time.RFC3339)
+		l := &Lock{path: path, f: f, info: Info{PID: os.Getpid(), StartedAt: now, HeartbeatAt: now}, takenFrom: takenFrom}
+		if err := l.writeInfo(); err != nil {
+			l.Release()
+			return nil, err
+		}
+		logger().Debug("lock acquired", "path", path, "pid", os.Getpid())
+		return l, nil
+	}
+	return nil, fmt.Errorf("locking %s: lock file kept changing", path)
+}
+
+// writeInfo replaces the file's contents with this process's details. Left
+// over contents mean the previous holder exited without releasing.
+func (l *Lock) writeInfo() error {
+	var stale Info
+	if err := json.NewDecoder(l.f).Decode(&stale); err == nil {
+		logger().Warn("stale lock file found, overwriting", "stale_pid", stale.PID)
+	}
+	return l.write()
+}
+
+func (l *Lock) write() error {
+	data, err := json.MarshalIndent(l.info, "", "  ")
+	if err != nil {
+		return fmt.Errorf("marshalling lock info: %w", err)
+	}
+	if err := l.f.Truncate(0); err != nil {
+		return fmt.Errorf("writing lock file: %w", err)
+	}
+	if _, err := l.f.WriteAt(data, 0); err != nil {
+		return fmt.Errorf("writing lock file: %w", err)
+	}
+	return nil
+}
+
+// Heartbeat records that the holder is still making progress. It returns
+// ErrLost if the lock was taken over, after which the holder must stop.
+func (l *Lock) Heartbeat(now time.Time) error {
+	if l.Lost() {
+		return ErrLost
+	}
+	l.info.HeartbeatAt = now.UTC().Format(time.RFC3339)
+	return l.write()
+}
+
+// TakenFrom returns the wedged holder this lock was taken over from, if it
+// was.
+func (l *Lock) TakenFrom() (Info, bool) {
+	if l.takenFrom == nil {
+		return Info{}, false
+	}
+	return *l.takenFrom, true
+}
+
+// Lost reports whether another process replaced the lock file, which happens
+// when it took the lock over from this one.
+func (l *Lock) Lost() bool {
+	return !samePath(l.f, l.path)
+}
+
+// Release removes the lock file and drops the lock. A lock that was taken
+// over leaves the new holder's file alone.
+func (l *Lock) Release() error {
+	if l == nil || l.f == nil {
+		return nil
+	}
+	err := release(l.f, l.path)
+	l.f = nil
+	if err != nil {
+		return err
+	}
+	logger().Debug("lock released", "path", l.path)
+	return nil
+}
+
+// ReadInfo reads the holder details from the lock file at path.
+func ReadInfo(path string) (Info, error) {
+	var info Info
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return info, err
+	}
+	err = json.Unmarshal(data, &info)
+	return info, err
+}
+
+// samePath reports whether f is still the file at path.
+func samePath(f *os.File, path string) bool {
+	held, err := f.Stat()
+	if err != nil {
+		return false
+	}
+	current, err := os.Stat(path)
+	if err != nil {
+		return false
+	}
+	return os.SameFile(held, current)
+}
@@ -0,0 +1,187 @@
+package lock
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestAcquire_WritesHolderInfo(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	info, err := ReadInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != os.Getpid() || info.StartedAt == "" {
+		t.Errorf("unexpected holder info %+v", info)
+	}
+}
+
+func TestAcquire_Contention(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := Acquire(path); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked while held, got %v", err)
+	}
+
+	if err := l.Release(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(path); !os.IsNotExist(err) {
+		t.Error("lock file should be removed after release")
+	}
+
+	l2, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("expected lock to be free after release, got %v", err)
+	}
+	l2.Release()
+}
+
+func TestAcquire_StaleFile(t *testing.T) {
+	// A holder that crashed leaves its file behind but no OS lock
+	path := filepath.Join(t.TempDir(), "test.lock")
+	if err := os.WriteFile(path, []byte(`{"pid": 1, "started_at": "2025-01-01T00:00:00Z"}`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("should take over stale lock file, got %v", err)
+	}
+	defer l.Release()
+
+	info, err := ReadInfo(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.PID != os.Getpid() {
+		t.Errorf("expected stale info to be replaced, got PID %d", info.PID)
+	}
+}
+
+// TestHelperHoldLock is run as a child process by TestAcquire_HolderCrash.
+func TestHelperHoldLock(t *testing.T) {
+	path := os.Getenv("LOCK_TEST_HOLD")
+	if path == "" {
+		t.Skip("helper process")
+	}
+	if _, err := Acquire(path); err != nil {
+		os.Exit(1)
+	}
+	os.Stdout.WriteString("locked\n")
+	select {} // hold until killed
+}
+
+func TestAcquire_HolderCrash(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestHelperHoldLock$")
+	cmd.Env = append(os.Environ(), "LOCK_TEST_HOLD="+path)
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := cmd.Start(); err != nil {
+		t.Fatal(err)
+	}
+	if line, _ := bufio.NewReader(stdout).ReadString('\n'); line != "locked\n" {
+		cmd.Process.Kill()
+		t.Fatalf("helper failed to take the lock: %q", line)
+	}
+
+	if _, err := Acquire(path); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked while the helper holds the lock, got %v", err)
+	}
+
+	// Killing the holder without a release leaves the file but frees the lock
+	cmd.Process.Kill()
+	cmd.Wait()
+	if _, err := os.Stat(path); err != nil {
+		t.Fatalf("expected lock file left behind by the killed holder: %v", err)
+	}
+
+	l, err := Acquire(path)
+	if err != nil {
+		t.Fatalf("expected to acquire after holder crash, got %v", err)
+	}
+	l.Release()
+}
+
+func TestAcquireWithOptions_TakeoverStaleHeartbeat(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	now := start
+	opts := Options{TTL: 10 * time.Minute, Now: func() time.Time { return now }}
+
+	wedged, err := AcquireWithOptions(path, opts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A fresh heartbeat keeps the lock
+	now = start.Add(8 * time.Minute)
+	if err := wedged.Heartbeat(now); err != nil {
+		t.Fatal(err)
+	}
+	now = start.Add(15 * time.Minute)
+	if _, err := AcquireWithOptions(path, opts); !errors.Is(err, ErrLocked) {
+		t.Fatalf("expected ErrLocked within the TTL of the last heartbeat, got %v", err)
+	}
+
+	// Once the heartbeat is older than the TTL the lock is taken over
+	now = start.Add(20 * time.Minute)
+	taker, err := AcquireWithOptions(path, opts)
+	if err != nil {
+		t.Fatalf("expected takeover of a stale holder, got %v", err)
+	}
+	defer taker.Release()
+
+	if !wedged.Lost() {
+		t.Error("expected the wedged holder to see its lock was lost")
+	}
+	if err := wedged.Heartbeat(now); !errors.Is(err, ErrLost) {
+		t.Errorf("expected ErrLost from the wedged holder's heartbeat, got %v", err)
+	}
+
+	// The wedged holder releasing late must not remove the new holder's file
+	if err := wedged.Release(); err != nil {
+		t.Fatal(err)
+	}
+	info, err := ReadInfo(path)
+	if err != nil {
+		t.Fatalf("expected the new holder's lock file to remain: %v", err)
+	}
+	if info.HeartbeatAt != now.Format(time.RFC3339) {
+		t.Errorf("unexpected lock info after late release %+v", info)
+	}
+}
+
+func TestAcquireWithOptions_NoTTLNeverTakesOver(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "test.lock")
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	l, err := AcquireWithOptions(path, Options{Now: func() time.Time { return start }})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Release()
+
+	later := Options{Now: func() time.Time { return start.Add(24 * time.Hour) }}
+	if _, err := AcquireWithOptions(path, later); !errors.Is(err, ErrLocked) {
+		t.Errorf("expected ErrLocked without a TTL, got %v", err)
+	}
+}
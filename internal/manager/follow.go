@@ -0,0 +1,99 @@
+package manager
+
+import (
+	"context"
+	"fmt"
+	"time"
+)
+
+// defaultFollowCheckInterval is used when schedule.follow.check_interval is
+// unset.
+const defaultFollowCheckInterval = 15 * time.Second
+
+// RunFollow stays resident and runs a cycle whenever the local snapshots drift
+// more than schedule.follow.max_slots_behind behind the cluster, so a standby
+// validator can restart near the tip at any moment.
+func (m *Manager) RunFollow() error {
+	return m.runFollow(context.Background())
+}
+
+// runFollow checks freshness every schedule.follow.check_interval until ctx
+// is cancelled. Cycles start at most once per min_interval, and not at all
+// while max_bandwidth_per_hour was downloaded in the last hour.
+func (m *Manager) runFollow(ctx context.Context) error {
+	f := &m.config.Schedule.Follow
+	// Before serve, as /readyz reads the threshold it selects
+	m.keeper.SetFollow(true)
+	stop, err := m.serve()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	interval := f.CheckIntervalDur
+	if interval <= 0 {
+		interval = defaultFollowCheckInterval
+	}
+	m.logger().Info("running snapshot keeper in follow mode", "max_slots_behind", m.keeper.MaxSlotsBehind(), "check_interval", interval, "min_interval", f.MinIntervalDur)
+
+	var lastCycle time.Time
+	var usage hourlyUsage
+	for {
+		now := m.clock.Now()
+		// Re-read each check, as a slot time estimate may convert it again
+		threshold := m.keeper.MaxSlotsBehind()
+		behind, err := m.keeper.SlotsBehind(ctx)
+		switch {
+		case err != nil:
+			m.logger().Warn("could not check snapshot freshness", "error", err)
+		case behind <= threshold:
+			m.logger().Debug("local snapshots within target", "slots_behind", behind, "target", threshold)
+		case !lastCycle.IsZero() && now.Sub(lastCycle) < f.MinIntervalDur:
+			m.logger().Debug("local snapshots behind target, waiting for min_interval", "slots_behind", behind, "next_cycle_in", (f.MinIntervalDur - now.Sub(lastCycle)).Round(time.Second))
+		case f.MaxBandwidthPerHourBytes > 0 && usage.total(now) >= f.MaxBandwidthPerHourBytes:
+			m.logger().Warn(fmt.Sprintf("local snapshots behind network by %d slots, but the hourly bandwidth cap is reached - holding downloads back", behind), "downloaded", usage.total(now), "cap", f.MaxBandwidthPerHourBytes)
+		default:
+			m.logger().Info(fmt.Sprintf("local snapshots behind network by %d slots, target is %d - running a cycle", behind, threshold))
+			lastCycle = now
+			m.scheduledCycle(ctx)
+			if d, ok := m.keeper.LastDecision(); ok && !d.At.Before(now) && d.Bytes > 0 {
+				usage.add(now, d.Bytes)
+			}
+		}
+
+		if err := m.wait(ctx, interval); err != nil {
+			return err
+		}
+	}
+}
+
+// hourlyUsage records bytes downloaded by recent cycles.
+type hourlyUsage struct {
+	downloads []download
+}
+
+type download struct {
+	at    time.Time
+	bytes int64
+}
+
+func (u *hourlyUsage) add(at time.Time, bytes int64) {
+	u.downloads = append(u.downloads, download{at: at, bytes: bytes})
+}
+
+// total returns the bytes downloaded in the hour before now, forgetting
+// older downloads.
+func (u *hourlyUsage) total(now time.Time) int64 {
+	cutoff := now.Add(-time.Hour)
+	kept := u.downloads[:0]
+	var total int64
+	for _, d := range u.downloads {
+		if !d.at.After(cutoff) {
+			continue
+		}
+		kept = append(kept, d)
+		total += d.bytes
+	}
+	u.downloads = kept
+	return total
+}
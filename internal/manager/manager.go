@@ -2,127 +2,438 @@ package manager
 
 import (
 	"context"
-	"encoding/json"
+	"errors"
 	"fmt"
-	"os"
+	"math/rand/v2"
+	"net/http"
 	"path/filepath"
-	"syscall"
 	"time"
 
 	"github.com/charmbracelet/log"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/audit"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/clock"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/lock"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/peer"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/report"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/schedule"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/status"
 )
 
 func logger() *log.Logger { return log.Default().WithPrefix("manager") }
 
+// logger tags the manager's logs with its validator's name when one daemon
+// keeps several.
+func (m *Manager) logger() *log.Logger {
+	if m.config != nil && m.config.Name != "" {
+		return logger().With("validator", m.config.Name)
+	}
+	return logger()
+}
+
 const lockFilename = "solana-validator-snapshot-keeper.lock"
 
-type lockInfo struct {
-	PID       int    `json:"pid"`
-	StartedAt string `json:"started_at"`
-}
+// maxReportedFailures is how many recent errors an issue report includes.
+const maxReportedFailures = 5
 
 type Manager struct {
 	config *config.Config
 	keeper *keeper.Keeper
+	clock  clock.Clock
+	lock   *lock.Lock
+	// jitter returns a random delay in [0, max) added to each scheduled run
+	jitter func(max time.Duration) time.Duration
+	// consecutiveFailures counts failed cycles since the last success;
+	// recentFailures keeps the latest of them for issue reports
+	consecutiveFailures int
+	recentFailures      []report.Failure
 }
 
 func New(cfg *config.Config) *Manager {
+	return NewWithOptions(cfg, keeper.Options{})
+}
+
+// NewWithOptions creates a Manager whose keeper (and interval scheduling)
+// use the given clock and slot source.
+func NewWithOptions(cfg *config.Config, opts keeper.Options) *Manager {
+	c := opts.Clock
+	if c == nil {
+		c = clock.Real{}
+	}
 	return &Manager{
 		config: cfg,
-		keeper: keeper.New(cfg),
+		keeper: keeper.NewWithOptions(cfg, opts),
+		clock:  c,
+		jitter: rand.N[time.Duration],
 	}
 }
 
 func (m *Manager) RunOnce() error {
-	logger().Info("running snapshot keeper (once)")
+	return m.RunOnceContext(context.Background())
+}
+
+// RunOnceContext is RunOnce, stopping the cycle when ctx is cancelled.
+func (m *Manager) RunOnceContext(ctx context.Context) error {
+	m.logger().Info("running snapshot keeper (once)")
 
 	if err := m.acquireLock(); err != nil {
 		return err
 	}
 	defer m.releaseLock()
 
-	return m.keeper.Run(context.Background())
+	err := m.runCycle(ctx)
+	m.recordResult(ctx, err)
+	return err
 }
 
-func (m *Manager) RunOnInterval(interval time.Duration) error {
-	logger().Info("running snapshot keeper on interval", "interval", interval)
+// Keeper returns the keeper the manager runs cycles with.
+func (m *Manager) Keeper() *keeper.Keeper {
+	return m.keeper
+}
 
-	for {
-		next := calculateNextBoundary(time.Now(), interval)
-		sleepDuration := time.Until(next)
-		logger().Info(fmt.Sprintf("next run in %s at %s", sleepDuration.Round(time.Second), next.UTC().Format("2006-01-02T15:04:05.000Z")))
+// Close releases the keeper's resources, see keeper.Keeper.Close.
+func (m *Manager) Close() error {
+	return m.keeper.Close()
+}
 
-		time.Sleep(sleepDuration)
+// LoopOptions tunes running on an interval or schedule.
+type LoopOptions struct {
+	// RunImmediately runs a cycle at startup before waiting for the first
+	// scheduled time
+	RunImmediately bool
+}
 
-		if err := m.acquireLock(); err != nil {
-			logger().Warn("skipping cycle, lock held by another process", "error", err)
-			continue
+func (m *Manager) RunOnInterval(interval time.Duration, opts LoopOptions) error {
+	return m.runOnInterval(context.Background(), interval, opts)
+}
+
+// RunOnSchedule runs a cycle at every time s yields, e.g. from cron
+// expressions.
+func (m *Manager) RunOnSchedule(s schedule.Schedule, opts LoopOptions) error {
+	m.logger().Info("running snapshot keeper on schedule", "schedule", s)
+	return m.runOnSchedule(context.Background(), s, opts)
+}
+
+// intervalSchedule runs at interval boundaries aligned to midnight.
+type intervalSchedule time.Duration
+
+func (i intervalSchedule) Next(after time.Time) time.Time {
+	return calculateNextBoundary(after, time.Duration(i))
+}
+
+// runOnInterval runs a cycle at every interval boundary until ctx is cancelled.
+func (m *Manager) runOnInterval(ctx context.Context, interval time.Duration, opts LoopOptions) error {
+	m.logger().Info("running snapshot keeper on interval", "interval", interval)
+	return m.runOnSchedule(ctx, intervalSchedule(interval), opts)
+}
+
+// runOnSchedule runs a cycle at every time s yields, each delayed by up to
+// schedule.jitter, until ctx is cancelled.
+func (m *Manager) runOnSchedule(ctx context.Context, s schedule.Schedule, opts LoopOptions) error {
+	stop, err := m.serve()
+	if err != nil {
+		return err
+	}
+	defer stop()
+
+	if opts.RunImmediately {
+		m.logger().Info("running immediately before the first scheduled run")
+		m.scheduledCycle(ctx)
+	}
+
+	for {
+		now := m.clock.Now()
+		next := s.Next(now)
+		if next.IsZero() {
+			return fmt.Errorf("schedule %v has no upcoming run", s)
+		}
+		if jitter := m.config.Schedule.JitterDur; jitter > 0 {
+			next = next.Add(m.jitter(jitter))
 		}
+		sleepDuration := next.Sub(now)
+		m.logger().Info(fmt.Sprintf("next run in %s at %s", sleepDuration.Round(time.Second), next.UTC().Format("2006-01-02T15:04:05.000Z")))
 
-		if err := m.keeper.Run(context.Background()); err != nil {
-			logger().Error("run failed", "error", err)
+		if err := m.wait(ctx, sleepDuration); err != nil {
+			return err
 		}
 
-		m.releaseLock()
+		m.scheduledCycle(ctx)
 	}
 }
 
-func (m *Manager) lockPath() string {
-	return filepath.Join(m.config.Snapshots.Directory, lockFilename)
+// freeSpaceCheckInterval is how often free space is checked against
+// snapshots.retention.min_free_space between cycles.
+const freeSpaceCheckInterval = time.Minute
+
+// wait waits for d, or until ctx is cancelled, keeping
+// snapshots.retention.min_free_space in the meantime.
+func (m *Manager) wait(ctx context.Context, d time.Duration) error {
+	deadline := m.clock.Now().Add(d)
+	for {
+		step := deadline.Sub(m.clock.Now())
+		if step <= 0 {
+			return nil
+		}
+		if m.config.Snapshots.Retention.MinFreeSpaceBytes > 0 {
+			step = min(step, freeSpaceCheckInterval)
+		}
+		select {
+		case <-m.clock.After(step):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+		m.maintainFreeSpace()
+	}
 }
 
-func (m *Manager) acquireLock() error {
-	lockPath := m.lockPath()
+// maintainFreeSpace prunes between cycles once free space drops below
+// snapshots.retention.min_free_space. The lock is only taken when space is
+// low, so the audit log isn't filled with lock events.
+func (m *Manager) maintainFreeSpace() {
+	if !m.keeper.FreeSpaceLow() {
+		return
+	}
+	if err := m.acquireLock(); err != nil {
+		m.logger().Warn("free space is low, but the lock is held by another process", "error", err)
+		return
+	}
+	defer m.releaseLock()
+	if err := m.keeper.EnsureFreeSpace(); err != nil {
+		m.logger().Error("freeing disk space failed", "error", err)
+	}
+}
 
-	data, err := os.ReadFile(lockPath)
-	if err == nil {
-		// Lock file exists — check if the process is still alive
-		var info lockInfo
-		if err := json.Unmarshal(data, &info); err == nil {
-			if isProcessAlive(info.PID) {
-				return fmt.Errorf("another instance is running (PID: %d, started: %s)", info.PID, info.StartedAt)
-			}
-			logger().Warn("stale lock file found, overwriting", "stale_pid", info.PID)
+// serve starts the status endpoint and the peer server, for those of
+// status.listen_address and peers.listen_address that are set; the returned
+// func stops them.
+func (m *Manager) serve() (func(), error) {
+	var servers []*http.Server
+	stop := func() {
+		for _, srv := range servers {
+			srv.Close()
+		}
+	}
+	if addr := m.config.Status.ListenAddress; addr != "" {
+		srv, err := status.Serve(addr, status.Options{
+			Config:         m.config,
+			Version:        report.Version,
+			StartedAt:      m.clock.Now(),
+			LastDecision:   m.keeper.LastDecision,
+			LastActivity:   m.keeper.LastActivity,
+			SlotsBehind:    m.keeper.SlotsBehind,
+			MaxSlotsBehind: m.keeper.MaxSlotsBehind,
+		})
+		if err != nil {
+			return nil, fmt.Errorf("starting status server: %w", err)
+		}
+		servers = append(servers, srv)
+	}
+	if addr := m.config.Peers.ListenAddress; addr != "" {
+		full, incremental := m.keeper.SnapshotDirs()
+		srv, err := peer.Serve(addr, peer.Options{
+			Token:       m.config.Peers.Token,
+			Directories: []string{full, incremental},
+		})
+		if err != nil {
+			stop()
+			return nil, fmt.Errorf("starting peer server: %w", err)
 		}
+		servers = append(servers, srv)
 	}
+	return stop, nil
+}
 
-	info := lockInfo{
-		PID:       os.Getpid(),
-		StartedAt: time.Now().UTC().Format(time.RFC3339),
+// scheduledCycle runs one cycle of interval mode, skipping it if another
+// instance holds the lock.
+func (m *Manager) scheduledCycle(ctx context.Context) {
+	if err := m.acquireLock(); err != nil {
+		m.logger().Warn("skipping cycle, lock held by another process", "error", err)
+		return
 	}
-	lockData, err := json.MarshalIndent(info, "", "  ")
+	defer m.releaseLock()
+
+	err := m.runCycle(ctx)
 	if err != nil {
-		return fmt.Errorf("marshalling lock info: %w", err)
+		m.logger().Error("run failed", "error", err)
 	}
+	m.recordResult(ctx, err)
+}
 
-	if err := os.WriteFile(lockPath, lockData, 0644); err != nil {
-		return fmt.Errorf("writing lock file: %w", err)
+// runCycle runs one keeper cycle under the held lock. With lock.ttl set it
+// refreshes the lock's heartbeat while the keeper makes progress, and stops
+// the cycle if another instance took the lock over.
+func (m *Manager) runCycle(ctx context.Context) error {
+	ttl := m.config.Lock.TTLDur
+	if ttl <= 0 {
+		return m.keeper.Run(ctx)
 	}
 
-	logger().Debug("lock acquired", "path", lockPath, "pid", info.PID)
-	return nil
+	ctx, cancel := context.WithCancelCause(ctx)
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.heartbeat(ctx, cancel, ttl/3)
+	}()
+
+	err := m.keeper.Run(ctx)
+	lost := errors.Is(context.Cause(ctx), lock.ErrLost)
+	cancel(nil)
+	<-done
+	if lost {
+		return fmt.Errorf("cycle stopped: %w", lock.ErrLost)
+	}
+	return err
 }
 
-func (m *Manager) releaseLock() {
-	lockPath := m.lockPath()
-	if err := os.Remove(lockPath); err != nil && !os.IsNotExist(err) {
-		logger().Error("failed to remove lock file", "path", lockPath, "error", err)
+// heartbeat refreshes the lock every interval the keeper made progress in,
+// so a wedged cycle lets its heartbeat go stale and can be taken over.
+func (m *Manager) heartbeat(ctx context.Context, cancel context.CancelCauseFunc, interval time.Duration) {
+	last := m.clock.Now()
+	for {
+		select {
+		case <-m.clock.After(interval):
+		case <-ctx.Done():
+			return
+		}
+
+		if m.lock.Lost() {
+			m.logger().Error("lock taken over by another instance, stopping cycle", "path", m.lockPath())
+			m.audit(audit.Event{Action: audit.ActionLockLost, Path: m.lockPath(), Reason: "taken over by another instance"})
+			cancel(lock.ErrLost)
+			return
+		}
+		activity := m.keeper.LastActivity()
+		if !activity.After(last) {
+			m.logger().Warn("no progress since last lock heartbeat", "last_activity", activity.UTC().Format(time.RFC3339))
+			continue
+		}
+		last = m.clock.Now()
+		if err := m.lock.Heartbeat(last); err != nil {
+			m.logger().Warn("failed to refresh lock heartbeat", "error", err)
+		}
+	}
+}
+
+// Prune prunes the snapshot directories outside a cycle and returns what was
+// (or, with dryRun, would be) removed. It holds the lock so an in-flight
+// download's temp files aren't removed, and, unless force is set, refuses
+// while incident mode has pruning frozen.
+func (m *Manager) Prune(dryRun, force bool) (pruner.Plan, error) {
+	if reason, active := m.keeper.IncidentActive(); active && !force {
+		return pruner.Plan{}, fmt.Errorf("incident mode active (%s) - pruning is frozen", reason)
+	}
+	if dryRun {
+		return m.keeper.PlanPrune()
+	}
+
+	if err := m.acquireLock(); err != nil {
+		return pruner.Plan{}, err
+	}
+	defer m.releaseLock()
+
+	plan, err := m.keeper.PlanPrune()
+	if err != nil {
+		return pruner.Plan{}, err
+	}
+	m.keeper.ApplyPrune(plan)
+	return plan, nil
+}
+
+// recordResult tracks consecutive failed cycles and generates an issue report
+// once issue_report.after_failures is reached. One report is generated per
+// run of failures; a successful cycle re-arms it.
+func (m *Manager) recordResult(ctx context.Context, err error) {
+	if err == nil {
+		m.consecutiveFailures = 0
+		m.recentFailures = nil
+		return
+	}
+
+	m.consecutiveFailures++
+	m.recentFailures = append(m.recentFailures, report.Failure{At: m.clock.Now(), Error: report.RedactText(err.Error())})
+	if len(m.recentFailures) > maxReportedFailures {
+		m.recentFailures = m.recentFailures[len(m.recentFailures)-maxReportedFailures:]
+	}
+
+	threshold := m.config.IssueReport.AfterFailures
+	if threshold <= 0 || m.consecutiveFailures != threshold {
+		return
+	}
+
+	r := report.Build(report.Options{
+		ConfigFile:          m.config.File,
+		Cluster:             m.config.Cluster.Name,
+		ConsecutiveFailures: m.consecutiveFailures,
+		Failures:            m.recentFailures,
+		Now:                 m.clock.Now(),
+	})
+
+	dir := m.config.IssueReport.Directory
+	if dir == "" {
+		dir = m.config.Snapshots.Directory
+	}
+	path, writeErr := report.Write(dir, r)
+	if writeErr != nil {
+		m.logger().Error("failed to write issue report", "error", writeErr)
 	} else {
-		logger().Debug("lock released", "path", lockPath)
+		m.logger().Warn(fmt.Sprintf("%d consecutive cycles failed - issue report written, please review and attach it when reporting a bug", m.consecutiveFailures), "path", path)
+	}
+
+	if m.config.IssueReport.PostURL != "" {
+		if err := report.Post(ctx, m.config.IssueReport.PostURL, m.config.IssueReport.Auth.Parsed, r); err != nil {
+			m.logger().Error("failed to post issue report", "error", err)
+		} else {
+			m.logger().Info("issue report posted")
+		}
 	}
 }
 
-func isProcessAlive(pid int) bool {
-	process, err := os.FindProcess(pid)
+func (m *Manager) lockPath() string {
+	return filepath.Join(m.config.Snapshots.Directory, lockFilename)
+}
+
+func (m *Manager) acquireLock() error {
+	l, err := lock.AcquireWithOptions(m.lockPath(), lock.Options{TTL: m.config.Lock.TTLDur, Now: m.now})
 	if err != nil {
-		return false
+		return err
+	}
+	m.lock = l
+	if from, ok := l.TakenFrom(); ok {
+		m.audit(audit.Event{
+			Action: audit.ActionLockTakeover,
+			Path:   m.lockPath(),
+			Reason: fmt.Sprintf("holder PID %d heartbeat stale since %s", from.PID, from.HeartbeatAt),
+		})
+	} else {
+		m.audit(audit.Event{Action: audit.ActionLockAcquire, Path: m.lockPath()})
+	}
+	return nil
+}
+
+// audit records a lock event in the keeper's audit log.
+func (m *Manager) audit(e audit.Event) {
+	if m.keeper != nil {
+		m.keeper.AuditLog().Record(e)
+	}
+}
+
+func (m *Manager) now() time.Time {
+	if m.clock == nil {
+		return time.Now()
+	}
+	return m.clock.Now()
+}
+
+func (m *Manager) releaseLock() {
+	if err := m.lock.Release(); err != nil {
+		m.logger().Error("failed to remove lock file", "path", m.lockPath(), "error", err)
+	} else {
+		m.audit(audit.Event{Action: audit.ActionLockRelease, Path: m.lockPath()})
 	}
-	// Signal 0 checks if the process exists without actually sending a signal
-	err = process.Signal(syscall.Signal(0))
-	return err == nil
+	m.lock = nil
 }
 
 func calculateNextBoundary(now time.Time, interval time.Duration) time.Time {
@@ -1,13 +1,21 @@
 package manager
 
 import (
+	"context"
 	"encoding/json"
+	"errors"
 	"os"
 	"path/filepath"
+	"strings"
+	"sync/atomic"
 	"testing"
 	"time"
 
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/clock"
 	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/lock"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/schedule"
 )
 
 func testConfig(t *testing.T) *config.Config {
@@ -55,7 +63,7 @@ func TestAcquireLock_NewLock(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	var info lockInfo
+	var info lock.Info
 	if err := json.Unmarshal(data, &info); err != nil {
 		t.Fatal(err)
 	}
@@ -73,11 +81,11 @@ func TestAcquireLock_AlreadyLocked(t *testing.T) {
 	}
 	defer m.releaseLock()
 
-	// Second acquire should fail (same PID = still alive)
+	// Second acquire should fail while the first holds the lock
 	m2 := &Manager{config: cfg}
 	err := m2.acquireLock()
-	if err == nil {
-		t.Error("expected error for duplicate lock")
+	if !errors.Is(err, lock.ErrLocked) {
+		t.Errorf("expected ErrLocked for duplicate lock, got %v", err)
 	}
 }
 
@@ -86,7 +94,7 @@ func TestAcquireLock_StaleLock(t *testing.T) {
 
 	// Write a lock file with a dead PID
 	lockPath := filepath.Join(cfg.Snapshots.Directory, lockFilename)
-	info := lockInfo{
+	info := lock.Info{
 		PID:       999999999, // almost certainly not running
 		StartedAt: time.Now().UTC().Format(time.RFC3339),
 	}
@@ -160,3 +168,303 @@ func TestNew(t *testing.T) {
 		t.Fatal("expected non-nil manager")
 	}
 }
+
+// fakeSlots advances the cluster slot on every call, as if time had passed.
+type fakeSlots struct {
+	slot  atomic.Uint64
+	calls atomic.Int64
+}
+
+func (f *fakeSlots) GetSlot(ctx context.Context) (uint64, error) {
+	f.calls.Add(1)
+	return f.slot.Add(9000), nil
+}
+
+func TestRunOnInterval_Simulated(t *testing.T) {
+	cfg := testConfig(t)
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	slots := &fakeSlots{}
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc, Slots: slots})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.runOnInterval(ctx, time.Hour, LoopOptions{}) }()
+
+	// A day of hourly cycles, each waiting for the loop to be asleep first
+	for i := 0; i < 24; i++ {
+		fc.BlockUntil(1)
+		fc.Advance(time.Hour)
+	}
+	fc.BlockUntil(1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := slots.calls.Load(); got != 24 {
+		t.Errorf("expected 24 cycles, got %d", got)
+	}
+	if _, err := os.Stat(filepath.Join(cfg.Snapshots.Directory, lockFilename)); !os.IsNotExist(err) {
+		t.Error("expected lock to be released between cycles")
+	}
+}
+
+func TestRunOnSchedule_Cron(t *testing.T) {
+	cfg := testConfig(t)
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	slots := &fakeSlots{}
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc, Slots: slots})
+
+	s, err := schedule.ParseCrons([]string{"30 */6 * * *"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.runOnSchedule(ctx, s, LoopOptions{}) }()
+
+	// A day in 30 minute steps runs at 00:30, 06:30, 12:30 and 18:30
+	for i := 0; i < 48; i++ {
+		fc.BlockUntil(1)
+		fc.Advance(30 * time.Minute)
+	}
+	fc.BlockUntil(1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := slots.calls.Load(); got != 4 {
+		t.Errorf("expected 4 cycles, got %d", got)
+	}
+}
+
+func TestRunOnInterval_RunImmediatelyWithJitter(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Schedule.JitterDur = 10 * time.Minute
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	slots := &fakeSlots{}
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc, Slots: slots})
+	m.jitter = func(max time.Duration) time.Duration { return max / 2 }
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.runOnInterval(ctx, time.Hour, LoopOptions{RunImmediately: true}) }()
+
+	// The immediate cycle runs before the loop first sleeps
+	fc.BlockUntil(1)
+	if got := slots.calls.Load(); got != 1 {
+		t.Fatalf("expected an immediate cycle, got %d", got)
+	}
+
+	// The 01:00 boundary is delayed by the jitter to 01:05
+	fc.Advance(time.Hour)
+	fc.BlockUntil(1)
+	if got := slots.calls.Load(); got != 1 {
+		t.Fatalf("expected the run to wait for its jitter, got %d cycles", got)
+	}
+	fc.Advance(5 * time.Minute)
+	fc.BlockUntil(1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	if got := slots.calls.Load(); got != 2 {
+		t.Errorf("expected 2 cycles, got %d", got)
+	}
+}
+
+func TestRunFollow_Debounced(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Schedule.Follow = config.Follow{MaxSlotsBehind: 500, CheckIntervalDur: 15 * time.Second, MinIntervalDur: 2 * time.Minute}
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	slots := &fakeSlots{}
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc, Slots: slots})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- m.runFollow(ctx) }()
+
+	// With no local snapshots every check is behind, but cycles start at
+	// most every 2m: at 0, 2, 4, 6, 8 and 10 minutes
+	for i := 0; i < 40; i++ {
+		fc.BlockUntil(1)
+		fc.Advance(15 * time.Second)
+	}
+	fc.BlockUntil(1)
+	cancel()
+
+	if err := <-done; err != context.Canceled {
+		t.Errorf("expected context.Canceled, got %v", err)
+	}
+	// Each check and each cycle reads the slot once
+	if got := slots.calls.Load(); got != 41+6 {
+		t.Errorf("expected 41 checks and 6 cycles, got %d slot reads", got)
+	}
+}
+
+func TestHourlyUsage(t *testing.T) {
+	start := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	var u hourlyUsage
+	u.add(start, 10)
+	u.add(start.Add(30*time.Minute), 20)
+	if got := u.total(start.Add(45 * time.Minute)); got != 30 {
+		t.Errorf("expected 30 bytes in the last hour, got %d", got)
+	}
+	if got := u.total(start.Add(time.Hour)); got != 20 {
+		t.Errorf("expected the first download to age out, got %d", got)
+	}
+	if got := u.total(start.Add(2 * time.Hour)); got != 0 {
+		t.Errorf("expected nothing in the last hour, got %d", got)
+	}
+}
+
+func TestHeartbeat_StopsCycleWhenLockTakenOver(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.Lock.TTLDur = 30 * time.Minute
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc})
+
+	if err := m.acquireLock(); err != nil {
+		t.Fatal(err)
+	}
+	defer m.releaseLock()
+
+	ctx, cancel := context.WithCancelCause(context.Background())
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		m.heartbeat(ctx, cancel, 10*time.Minute)
+	}()
+
+	// With no keeper progress the heartbeat goes stale and another instance
+	// takes the lock over
+	fc.BlockUntil(1)
+	fc.Advance(10 * time.Minute)
+	fc.BlockUntil(1)
+	m2 := NewWithOptions(cfg, keeper.Options{Clock: clock.NewFake(fc.Now().Add(time.Hour))})
+	if err := m2.acquireLock(); err != nil {
+		t.Fatalf("expected takeover of the stale lock, got %v", err)
+	}
+	defer m2.releaseLock()
+
+	fc.Advance(10 * time.Minute)
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("heartbeat didn't stop after the lock was taken over")
+	}
+	if !errors.Is(context.Cause(ctx), lock.ErrLost) {
+		t.Errorf("expected the cycle to be cancelled with ErrLost, got %v", context.Cause(ctx))
+	}
+}
+
+func TestRecordResult_IssueReportAfterFailures(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.IssueReport.AfterFailures = 2
+	fc := clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))
+	m := NewWithOptions(cfg, keeper.Options{Clock: fc})
+	ctx := context.Background()
+
+	reports := func() []string {
+		matches, _ := filepath.Glob(filepath.Join(cfg.Snapshots.Directory, "issue-report-*.md"))
+		return matches
+	}
+
+	m.recordResult(ctx, errors.New("first"))
+	if len(reports()) != 0 {
+		t.Fatal("expected no report after one failure")
+	}
+
+	fc.Advance(time.Hour)
+	m.recordResult(ctx, errors.New("second"))
+	if len(reports()) != 1 {
+		t.Fatalf("expected a report after two failures, got %d", len(reports()))
+	}
+
+	// Further failures in the same run don't generate more reports
+	fc.Advance(time.Hour)
+	m.recordResult(ctx, errors.New("third"))
+	if len(reports()) != 1 {
+		t.Errorf("expected one report per run of failures, got %d", len(reports()))
+	}
+
+	// A success re-arms reporting
+	m.recordResult(ctx, nil)
+	fc.Advance(time.Hour)
+	m.recordResult(ctx, errors.New("fourth"))
+	fc.Advance(time.Hour)
+	m.recordResult(ctx, errors.New("fifth"))
+	if len(reports()) != 2 {
+		t.Errorf("expected a second report after success and two more failures, got %d", len(reports()))
+	}
+}
+
+func TestRecordResult_IssueReportRedactsErrors(t *testing.T) {
+	cfg := testConfig(t)
+	cfg.IssueReport.AfterFailures = 1
+	m := NewWithOptions(cfg, keeper.Options{Clock: clock.NewFake(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC))})
+
+	m.recordResult(context.Background(), errors.New(`getting slot: https://rpc.example.com/?api-key=abc123: Post "https://rpc.example.com/?api-key=abc123": EOF`))
+	matches, _ := filepath.Glob(filepath.Join(cfg.Snapshots.Directory, "issue-report-*.md"))
+	if len(matches) != 1 {
+		t.Fatalf("expected a report, got %d", len(matches))
+	}
+	data, err := os.ReadFile(matches[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(data), "abc123") {
+		t.Errorf("expected the api key to be redacted from the report:\n%s", data)
+	}
+	if !strings.Contains(string(data), "https://rpc.example.com/REDACTED") {
+		t.Errorf("expected the endpoint's host to be kept:\n%s", data)
+	}
+}
+
+func TestPrune_DryRunAndIncidentFreeze(t *testing.T) {
+	cfg := testConfig(t)
+	dir := cfg.Snapshots.Directory
+	// The newest full has its incremental, so the older one isn't held
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst", "incremental-snapshot-200-250-HashC.tar.zst"} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte("data"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	m := New(cfg)
+	old := filepath.Join(dir, "snapshot-100-HashA.tar.zst")
+
+	plan, err := m.Prune(true, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(plan.Remove) != 1 || plan.Remove[0].Path != old {
+		t.Fatalf("expected older full planned for removal, got %+v", plan.Remove)
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatal("dry run should not remove files")
+	}
+
+	marker := filepath.Join(dir, "solana-validator-snapshot-keeper.incident")
+	if err := os.WriteFile(marker, nil, 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := m.Prune(false, false); err == nil {
+		t.Fatal("expected prune to be refused during incident mode")
+	}
+	if _, err := os.Stat(old); err != nil {
+		t.Fatal("refused prune should not remove files")
+	}
+
+	if _, err := m.Prune(false, true); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(old); err == nil {
+		t.Error("forced prune should remove the older full")
+	}
+	if _, err := os.Stat(m.lockPath()); err == nil {
+		t.Error("lock should be released after pruning")
+	}
+}
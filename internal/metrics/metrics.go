@@ -0,0 +1,239 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("metrics") }
+
+const (
+	BackendNone     = ""
+	BackendStatsd   = "statsd"
+	BackendInfluxDB = "influxdb"
+)
+
+// Sink receives cycle and download metrics. Implementations must be safe for
+// concurrent use. Errors are logged rather than returned so that a broken
+// metrics endpoint never fails a snapshot cycle.
+type Sink interface {
+	Gauge(name string, value float64, tags map[string]string)
+	Count(name string, value int64, tags map[string]string)
+	Timing(name string, d time.Duration, tags map[string]string)
+	// Flush sends any buffered metrics.
+	Flush()
+	Close() error
+}
+
+// Options configures a metrics sink.
+type Options struct {
+	Backend string
+	// Address is host:port for statsd, or a udp:// or http(s):// URL for influxdb
+	Address string
+	Prefix  string
+	Tags    map[string]string // added to every metric
+}
+
+// New creates a Sink for the configured backend. An empty backend returns a
+// no-op sink.
+func New(opts Options) (Sink, error) {
+	switch opts.Backend {
+	case BackendNone:
+		return Noop{}, nil
+	case BackendStatsd:
+		conn, err := net.Dial("udp", opts.Address)
+		if err != nil {
+			return nil, fmt.Errorf("dialing statsd %s: %w", opts.Address, err)
+		}
+		return &lineSink{opts: opts, format: formatStatsd, conn: conn}, nil
+	case BackendInfluxDB:
+		u, err := url.Parse(opts.Address)
+		if err != nil {
+			return nil, fmt.Errorf("parsing influxdb address: %w", err)
+		}
+		switch u.Scheme {
+		case "udp":
+			conn, err := net.Dial("udp", u.Host)
+			if err != nil {
+				return nil, fmt.Errorf("dialing influxdb %s: %w", u.Host, err)
+			}
+			return &lineSink{opts: opts, format: formatInflux, conn: conn}, nil
+		case "http", "https":
+			return &lineSink{opts: opts, format: formatInflux, httpURL: opts.Address, httpClient: &http.Client{Timeout: 10 * time.Second}}, nil
+		default:
+			return nil, fmt.Errorf("influxdb address must be a udp://, http:// or https:// URL, got %q", opts.Address)
+		}
+	default:
+		return nil, fmt.Errorf("unknown metrics backend %q", opts.Backend)
+	}
+}
+
+// Noop discards all metrics.
+type Noop struct{}
+
+func (Noop) Gauge(string, float64, map[string]string)        {}
+func (Noop) Count(string, int64, map[string]string)          {}
+func (Noop) Timing(string, time.Duration, map[string]string) {}
+func (Noop) Flush()                                          {}
+func (Noop) Close() error                                    { return nil }
+
+type metricKind int
+
+const (
+	kindGauge metricKind = iota
+	kindCount
+	kindTiming
+)
+
+type formatFunc func(name string, kind metricKind, value string, tags map[string]string, ts time.Time) string
+
+// lineSink writes one text line per metric, either as UDP datagrams or
+// batched into an HTTP POST on Flush.
+type lineSink struct {
+	opts   Options
+	format formatFunc
+
+	conn net.Conn
+
+	httpURL    string
+	httpClient *http.Client
+	mu         sync.Mutex
+	buf        bytes.Buffer
+}
+
+func (s *lineSink) Gauge(name string, value float64, tags map[string]string) {
+	s.emit(name, kindGauge, strconv.FormatFloat(value, 'f', -1, 64), tags)
+}
+
+func (s *lineSink) Count(name string, value int64, tags map[string]string) {
+	s.emit(name, kindCount, strconv.FormatInt(value, 10), tags)
+}
+
+func (s *lineSink) Timing(name string, d time.Duration, tags map[string]string) {
+	ms := float64(d) / float64(time.Millisecond)
+	s.emit(name, kindTiming, strconv.FormatFloat(ms, 'f', 3, 64), tags)
+}
+
+func (s *lineSink) emit(name string, kind metricKind, value string, tags map[string]string) {
+	if s.opts.Prefix != "" {
+		name = s.opts.Prefix + "." + name
+	}
+	line := s.format(name, kind, value, mergeTags(s.opts.Tags, tags), time.Now())
+
+	if s.conn != nil {
+		if _, err := s.conn.Write([]byte(line)); err != nil {
+			logger().Debug("failed to send metric", "name", name, "error", err)
+		}
+		return
+	}
+
+	s.mu.Lock()
+	s.buf.WriteString(line)
+	s.buf.WriteByte('\n')
+	s.mu.Unlock()
+}
+
+func (s *lineSink) Flush() {
+	if s.httpURL == "" {
+		return
+	}
+
+	s.mu.Lock()
+	body := append([]byte(nil), s.buf.Bytes()...)
+	s.buf.Reset()
+	s.mu.Unlock()
+
+	if len(body) == 0 {
+		return
+	}
+
+	resp, err := s.httpClient.Post(s.httpURL, "text/plain; charset=utf-8", bytes.NewReader(body))
+	if err != nil {
+		logger().Warn("failed to write metrics", "address", s.httpURL, "error", err)
+		return
+	}
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		logger().Warn("failed to write metrics", "address", s.httpURL, "status", resp.StatusCode)
+	}
+}
+
+func (s *lineSink) Close() error {
+	s.Flush()
+	if s.conn != nil {
+		return s.conn.Close()
+	}
+	return nil
+}
+
+// formatStatsd renders a DogStatsD-style line: name:value|type|#k:v,k:v
+func formatStatsd(name string, kind metricKind, value string, tags map[string]string, _ time.Time) string {
+	typ := "g"
+	switch kind {
+	case kindCount:
+		typ = "c"
+	case kindTiming:
+		typ = "ms"
+	}
+	line := fmt.Sprintf("%s:%s|%s", name, value, typ)
+	if len(tags) > 0 {
+		var pairs []string
+		for _, k := range sortedKeys(tags) {
+			pairs = append(pairs, k+":"+tags[k])
+		}
+		line += "|#" + strings.Join(pairs, ",")
+	}
+	return line
+}
+
+// formatInflux renders an InfluxDB line protocol point with a single "value"
+// field. Dots in the name become underscores in the measurement.
+func formatInflux(name string, kind metricKind, value string, tags map[string]string, ts time.Time) string {
+	var b strings.Builder
+	b.WriteString(influxEscape(strings.ReplaceAll(name, ".", "_")))
+	for _, k := range sortedKeys(tags) {
+		b.WriteString("," + influxEscape(k) + "=" + influxEscape(tags[k]))
+	}
+	if kind == kindCount {
+		value += "i"
+	}
+	fmt.Fprintf(&b, " value=%s %d", value, ts.UnixNano())
+	return b.String()
+}
+
+var influxEscaper = strings.NewReplacer(",", `\,`, " ", `\ `, "=", `\=`)
+
+func influxEscape(s string) string { return influxEscaper.Replace(s) }
+
+func mergeTags(base, extra map[string]string) map[string]string {
+	if len(base) == 0 {
+		return extra
+	}
+	merged := make(map[string]string, len(base)+len(extra))
+	for k, v := range base {
+		merged[k] = v
+	}
+	for k, v := range extra {
+		merged[k] = v
+	}
+	return merged
+}
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
@@ -0,0 +1,109 @@
+package metrics
+
+import (
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestNew_NoBackendIsNoop(t *testing.T) {
+	s, err := New(Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := s.(Noop); !ok {
+		t.Errorf("expected Noop sink, got %T", s)
+	}
+}
+
+func TestNew_InvalidBackend(t *testing.T) {
+	if _, err := New(Options{Backend: "prometheus"}); err == nil {
+		t.Error("expected error for unknown backend")
+	}
+	if _, err := New(Options{Backend: BackendInfluxDB, Address: "tcp://127.0.0.1:8089"}); err == nil {
+		t.Error("expected error for unsupported influxdb scheme")
+	}
+}
+
+func TestStatsdSink(t *testing.T) {
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer pc.Close()
+
+	s, err := New(Options{
+		Backend: BackendStatsd,
+		Address: pc.LocalAddr().String(),
+		Prefix:  "snapshot_keeper",
+		Tags:    map[string]string{"host": "val1"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer s.Close()
+
+	s.Count("cycle.total", 1, map[string]string{"result": "success"})
+
+	buf := make([]byte, 1024)
+	pc.SetReadDeadline(time.Now().Add(2 * time.Second))
+	n, _, err := pc.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := "snapshot_keeper.cycle.total:1|c|#host:val1,result:success"
+	if got := string(buf[:n]); got != expected {
+		t.Errorf("got %q, want %q", got, expected)
+	}
+}
+
+func TestInfluxHTTPSink_BatchesUntilFlush(t *testing.T) {
+	bodies := make(chan string, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		b, _ := io.ReadAll(r.Body)
+		bodies <- string(b)
+		w.WriteHeader(http.StatusNoContent)
+	}))
+	defer server.Close()
+
+	s, err := New(Options{Backend: BackendInfluxDB, Address: server.URL + "/write?db=validators", Prefix: "snapshot_keeper"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	s.Gauge("download.bytes", 1024, map[string]string{"type": "full"})
+	s.Count("download.completed", 1, map[string]string{"type": "full"})
+
+	select {
+	case <-bodies:
+		t.Fatal("metrics should not be sent before Flush")
+	default:
+	}
+
+	s.Flush()
+
+	body := <-bodies
+	lines := strings.Split(strings.TrimSpace(body), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("expected 2 lines, got %d: %q", len(lines), body)
+	}
+	if !strings.HasPrefix(lines[0], "snapshot_keeper_download_bytes,type=full value=1024 ") {
+		t.Errorf("unexpected gauge line %q", lines[0])
+	}
+	if !strings.HasPrefix(lines[1], "snapshot_keeper_download_completed,type=full value=1i ") {
+		t.Errorf("unexpected count line %q", lines[1])
+	}
+}
+
+func TestFormatInflux_Escaping(t *testing.T) {
+	line := formatInflux("cycle.duration", kindTiming, "1.500", map[string]string{"cluster name": "a,b"}, time.Unix(0, 42))
+	expected := `cycle_duration,cluster\ name=a\,b value=1.500 42`
+	if line != expected {
+		t.Errorf("got %q, want %q", line, expected)
+	}
+}
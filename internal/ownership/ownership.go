@@ -0,0 +1,44 @@
+// Package ownership sets the owner, permissions and SELinux label of
+// downloaded snapshot files, so a validator running as another user or under
+// a confined SELinux policy can read them without manual relabeling.
+package ownership
+
+import (
+	"fmt"
+	"os"
+)
+
+// Options describes the attributes applied to a file. Zero values leave the
+// corresponding attribute unchanged.
+type Options struct {
+	Chown          bool        // apply UID and GID
+	UID            int         // -1 = unchanged
+	GID            int         // -1 = unchanged
+	Mode           os.FileMode // 0 = unchanged
+	SELinuxContext string      // e.g. "system_u:object_r:solana_data_t:s0"
+}
+
+// Enabled reports whether any attribute is set.
+func (o Options) Enabled() bool {
+	return o.Chown || o.Mode != 0 || o.SELinuxContext != ""
+}
+
+// Apply sets the configured attributes on path.
+func Apply(path string, opts Options) error {
+	if opts.Chown {
+		if err := os.Chown(path, opts.UID, opts.GID); err != nil {
+			return fmt.Errorf("chown: %w", err)
+		}
+	}
+	if opts.Mode != 0 {
+		if err := os.Chmod(path, opts.Mode); err != nil {
+			return fmt.Errorf("chmod: %w", err)
+		}
+	}
+	if opts.SELinuxContext != "" {
+		if err := setSELinuxContext(path, opts.SELinuxContext); err != nil {
+			return fmt.Errorf("setting SELinux context: %w", err)
+		}
+	}
+	return nil
+}
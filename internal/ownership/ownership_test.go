@@ -0,0 +1,35 @@
+package ownership
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestApply(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot-100-Hash.tar.zst")
+	os.WriteFile(path, []byte("data"), 0600)
+
+	// Chowning to ourselves is always permitted
+	opts := Options{Chown: true, UID: os.Getuid(), GID: -1, Mode: 0640}
+	if !opts.Enabled() {
+		t.Fatal("expected options to be enabled")
+	}
+	if err := Apply(path, opts); err != nil {
+		t.Fatal(err)
+	}
+	info, _ := os.Stat(path)
+	if info.Mode().Perm() != 0640 {
+		t.Errorf("expected mode 0640, got %o", info.Mode().Perm())
+	}
+}
+
+func TestApply_Disabled(t *testing.T) {
+	var opts Options
+	if opts.Enabled() {
+		t.Error("expected no attributes to be enabled")
+	}
+	if err := Apply("/nonexistent", opts); err != nil {
+		t.Errorf("expected no-op, got %v", err)
+	}
+}
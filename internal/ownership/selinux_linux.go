@@ -0,0 +1,11 @@
+package ownership
+
+import "syscall"
+
+// selinuxXattr is the extended attribute holding a file's SELinux context.
+const selinuxXattr = "security.selinux"
+
+func setSELinuxContext(path, context string) error {
+	// The kernel expects the context NUL-terminated, as setfilecon(3) sends it
+	return syscall.Setxattr(path, selinuxXattr, append([]byte(context), 0), 0)
+}
@@ -0,0 +1,9 @@
+//go:build !linux
+
+package ownership
+
+import "errors"
+
+func setSELinuxContext(path, context string) error {
+	return errors.New("SELinux labeling is only supported on Linux")
+}
@@ -0,0 +1,192 @@
+// Package peer lets the operator's own keepers share snapshots. Each serves
+// its local archives to the others over HTTP, authenticated by a shared
+// bearer token, at the paths Solana RPC nodes use, so discovery probes and
+// downloads from a peer like from any other source.
+package peer
+
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/pruner"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("peer") }
+
+// ListPath is where a peer advertises its local snapshots.
+const ListPath = "/snapshots"
+
+// Options describes what a peer serves.
+type Options struct {
+	// Token must be sent as "Authorization: Bearer <token>"
+	Token string
+	// Directories hold the archives to serve
+	Directories []string
+}
+
+// Snapshot is a local archive as advertised at ListPath.
+type Snapshot struct {
+	Filename string `json:"filename"`
+	Type     string `json:"type"` // full or incremental
+	Slot     uint64 `json:"slot"`
+	BaseSlot uint64 `json:"base_slot,omitempty"`
+	Size     int64  `json:"size"`
+}
+
+// Handler serves the snapshots in opts.Directories to authenticated peers:
+// the list at ListPath, redirects to the newest full and incremental at
+// /snapshot.tar.bz2 and /incremental-snapshot.tar.bz2, and the archives
+// themselves with Range support.
+func Handler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+ListPath, func(w http.ResponseWriter, r *http.Request) {
+		snapshots, err := list(opts.Directories)
+		if err != nil {
+			logger().Error("failed to list snapshots", "error", err)
+			http.Error(w, "listing snapshots failed", http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(snapshots); err != nil {
+			logger().Error("failed to write snapshot list", "error", err)
+		}
+	})
+	mux.HandleFunc("GET /snapshot.tar.bz2", func(w http.ResponseWriter, r *http.Request) {
+		redirectToNewest(w, r, opts.Directories, true)
+	})
+	mux.HandleFunc("GET /incremental-snapshot.tar.bz2", func(w http.ResponseWriter, r *http.Request) {
+		redirectToNewest(w, r, opts.Directories, false)
+	})
+	mux.HandleFunc("GET /{filename}", func(w http.ResponseWriter, r *http.Request) {
+		serveArchive(w, r, opts.Directories, r.PathValue("filename"))
+	})
+	return authenticate(mux, opts.Token)
+}
+
+// authenticate rejects requests without the bearer token.
+func authenticate(next http.Handler, token string) http.Handler {
+	want := []byte("Bearer " + token)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got := []byte(r.Header.Get("Authorization"))
+		if subtle.ConstantTimeCompare(got, want) != 1 {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// list returns the archives in dirs, newest first.
+func list(dirs []string) ([]Snapshot, error) {
+	files, err := pruner.GetLocalSnapshots(dirs...)
+	if err != nil {
+		return nil, err
+	}
+	snapshots := make([]Snapshot, 0, len(files))
+	for _, f := range files {
+		info, err := os.Stat(f.Path)
+		if err != nil {
+			continue
+		}
+		s := Snapshot{
+			Filename: filepath.Base(f.Path),
+			Type:     "incremental",
+			Slot:     f.Slot,
+			BaseSlot: f.BaseSlot,
+			Size:     info.Size(),
+		}
+		if f.IsFull {
+			s.Type = "full"
+		}
+		snapshots = append(snapshots, s)
+	}
+	slices.SortFunc(snapshots, func(a, b Snapshot) int {
+		switch {
+		case a.Slot > b.Slot:
+			return -1
+		case a.Slot < b.Slot:
+			return 1
+		}
+		return 0
+	})
+	return snapshots, nil
+}
+
+// redirectToNewest redirects to the newest full or incremental archive, as
+// RPC nodes do.
+func redirectToNewest(w http.ResponseWriter, r *http.Request, dirs []string, full bool) {
+	files, err := pruner.GetLocalSnapshots(dirs...)
+	if err != nil {
+		logger().Error("failed to list snapshots", "error", err)
+		http.Error(w, "listing snapshots failed", http.StatusInternalServerError)
+		return
+	}
+	var newest *pruner.SnapshotFile
+	for i, f := range files {
+		if f.IsFull == full && (newest == nil || f.Slot > newest.Slot) {
+			newest = &files[i]
+		}
+	}
+	if newest == nil {
+		http.NotFound(w, r)
+		return
+	}
+	http.Redirect(w, r, "/"+filepath.Base(newest.Path), http.StatusSeeOther)
+}
+
+// serveArchive serves the named archive if it is one of the local
+// snapshots; nothing else in the directories is reachable.
+func serveArchive(w http.ResponseWriter, r *http.Request, dirs []string, filename string) {
+	files, err := pruner.GetLocalSnapshots(dirs...)
+	if err != nil {
+		logger().Error("failed to list snapshots", "error", err)
+		http.Error(w, "listing snapshots failed", http.StatusInternalServerError)
+		return
+	}
+	for _, f := range files {
+		if filepath.Base(f.Path) != filename {
+			continue
+		}
+		file, err := os.Open(f.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer file.Close()
+		info, err := file.Stat()
+		if err != nil {
+			http.Error(w, "reading snapshot failed", http.StatusInternalServerError)
+			return
+		}
+		logger().Debug("serving snapshot to peer", "file", filename, "remote", r.RemoteAddr, "range", r.Header.Get("Range"))
+		http.ServeContent(w, r, filename, info.ModTime(), file)
+		return
+	}
+	http.NotFound(w, r)
+}
+
+// Serve listens on addr and serves snapshots to peers in the background.
+// Listen errors are returned; the caller closes the server when done.
+func Serve(addr string, opts Options) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: Handler(opts), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger().Error("peer server stopped", "error", err)
+		}
+	}()
+	logger().Info("serving snapshots to peers", "address", ln.Addr().String(), "directories", opts.Directories)
+	return srv, nil
+}
@@ -0,0 +1,123 @@
+package peer
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func testServer(t *testing.T) (*httptest.Server, string) {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range map[string]string{
+		"snapshot-100-HashA.tar.zst":                      "full-100",
+		"snapshot-200-HashB.tar.zst":                      "full-200",
+		"incremental-snapshot-200-250-HashC.tar.zst":      "incremental",
+		"snapshot-300-HashD.tar.zst.tmp":                  "partial",
+		"solana-validator-snapshot-keeper.cooldowns.json": "{}",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	srv := httptest.NewServer(Handler(Options{Token: "secret", Directories: []string{dir}}))
+	t.Cleanup(srv.Close)
+	return srv, dir
+}
+
+func get(t *testing.T, client *http.Client, url string, header http.Header) *http.Response {
+	t.Helper()
+	req, err := http.NewRequest(http.MethodGet, url, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for k, v := range header {
+		req.Header[k] = v
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { resp.Body.Close() })
+	return resp
+}
+
+func TestHandler_RequiresToken(t *testing.T) {
+	srv, _ := testServer(t)
+	for _, auth := range []string{"", "Bearer wrong", "secret"} {
+		resp := get(t, srv.Client(), srv.URL+ListPath, http.Header{"Authorization": {auth}})
+		if resp.StatusCode != http.StatusUnauthorized {
+			t.Errorf("Authorization %q: expected 401, got %d", auth, resp.StatusCode)
+		}
+	}
+}
+
+func TestHandler_ListAndRedirects(t *testing.T) {
+	srv, _ := testServer(t)
+	client := &http.Client{
+		Transport: Transport(nil, []string{srv.URL}, "secret"),
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	resp := get(t, client, srv.URL+ListPath, nil)
+	var snapshots []Snapshot
+	if err := json.NewDecoder(resp.Body).Decode(&snapshots); err != nil {
+		t.Fatal(err)
+	}
+	if len(snapshots) != 3 || snapshots[0].Type != "incremental" || snapshots[0].BaseSlot != 200 || snapshots[0].Size != int64(len("incremental")) {
+		t.Errorf("expected 3 archives, newest first, got %+v", snapshots)
+	}
+
+	for path, want := range map[string]string{
+		"/snapshot.tar.bz2":             "/snapshot-200-HashB.tar.zst",
+		"/incremental-snapshot.tar.bz2": "/incremental-snapshot-200-250-HashC.tar.zst",
+	} {
+		resp := get(t, client, srv.URL+path, nil)
+		if resp.StatusCode != http.StatusSeeOther || resp.Header.Get("Location") != want {
+			t.Errorf("%s: expected redirect to %s, got %d %q", path, want, resp.StatusCode, resp.Header.Get("Location"))
+		}
+	}
+}
+
+func TestHandler_ServesArchivesOnly(t *testing.T) {
+	srv, _ := testServer(t)
+	client := &http.Client{Transport: Transport(nil, []string{srv.URL}, "secret")}
+
+	resp := get(t, client, srv.URL+"/snapshot-200-HashB.tar.zst", http.Header{"Range": {"bytes=5-"}})
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusPartialContent || string(body) != "200" {
+		t.Errorf("expected the ranged archive, got %d %q", resp.StatusCode, body)
+	}
+
+	for _, name := range []string{"snapshot-300-HashD.tar.zst.tmp", "solana-validator-snapshot-keeper.cooldowns.json", "..%2Fpasswd"} {
+		resp := get(t, client, srv.URL+"/"+name, nil)
+		if resp.StatusCode != http.StatusNotFound {
+			t.Errorf("%s: expected 404, got %d", name, resp.StatusCode)
+		}
+	}
+}
+
+func TestTransport_OnlyAuthenticatesPeers(t *testing.T) {
+	var got []string
+	record := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer record.Close()
+	other := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		got = append(got, r.Header.Get("Authorization"))
+	}))
+	defer other.Close()
+
+	client := &http.Client{Transport: Transport(nil, []string{record.URL}, "secret")}
+	get(t, client, record.URL, nil)
+	get(t, client, other.URL, nil)
+	if len(got) != 2 || got[0] != "Bearer secret" || got[1] != "" {
+		t.Errorf("expected only the peer to get the token, got %q", got)
+	}
+}
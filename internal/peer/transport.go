@@ -0,0 +1,40 @@
+package peer
+
+import (
+	"net/http"
+	"net/url"
+)
+
+// Transport returns base with the bearer token added to requests sent to
+// the peers at urls; other requests pass through unchanged. Without peers it
+// returns base itself.
+func Transport(base http.RoundTripper, urls []string, token string) http.RoundTripper {
+	if len(urls) == 0 {
+		return base
+	}
+	if base == nil {
+		base = http.DefaultTransport
+	}
+	hosts := make(map[string]bool, len(urls))
+	for _, raw := range urls {
+		if u, err := url.Parse(raw); err == nil {
+			hosts[u.Host] = true
+		}
+	}
+	return &authTransport{next: base, hosts: hosts, header: "Bearer " + token}
+}
+
+type authTransport struct {
+	next   http.RoundTripper
+	hosts  map[string]bool
+	header string
+}
+
+func (t *authTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if !t.hosts[req.URL.Host] {
+		return t.next.RoundTrip(req)
+	}
+	req = req.Clone(req.Context())
+	req.Header.Set("Authorization", t.header)
+	return t.next.RoundTrip(req)
+}
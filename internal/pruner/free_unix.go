@@ -0,0 +1,15 @@
+//go:build !windows
+
+package pruner
+
+import "golang.org/x/sys/unix"
+
+// Free returns the bytes available to unprivileged users on dir's
+// filesystem.
+func Free(dir string) (int64, error) {
+	var st unix.Statfs_t
+	if err := unix.Statfs(dir, &st); err != nil {
+		return 0, err
+	}
+	return int64(st.Bavail) * int64(st.Bsize), nil
+}
@@ -0,0 +1,86 @@
+package pruner
+
+import (
+	"os"
+	"sort"
+)
+
+// PlanFreeSpace lists the files that may be deleted to free disk space,
+// oldest first: what plan removes, then the snapshots in quarantineDir, if
+// set. Nothing the plan keeps is included.
+func PlanFreeSpace(plan Plan, quarantineDir string) ([]Removal, error) {
+	var files []datedRemoval
+	seen := map[string]bool{}
+	for _, r := range plan.Remove {
+		info, err := os.Stat(r.Path)
+		if err != nil || seen[r.Path] {
+			continue
+		}
+		seen[r.Path] = true
+		files = append(files, datedRemoval{Removal: r, modTime: info.ModTime()})
+	}
+	if quarantineDir != "" {
+		quarantined, err := quarantinedSnapshots(quarantineDir)
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range quarantined {
+			if !seen[f.Path] {
+				files = append(files, f)
+			}
+		}
+	}
+	sort.SliceStable(files, func(i, j int) bool { return files[i].modTime.Before(files[j].modTime) })
+
+	// Moving a file aside frees nothing, so all of them are deleted
+	removals := make([]Removal, len(files))
+	for i, f := range files {
+		removals[i] = f.Removal
+		removals[i].Quarantine = false
+	}
+	return removals, nil
+}
+
+// FreeUntil deletes candidates in order until enough reports there is enough
+// free space, and returns those it deleted. As with Apply, files open in
+// another process are kept.
+func FreeUntil(candidates []Removal, enough func() bool) []Removal {
+	var removed []Removal
+	var open map[string]int
+	for i, r := range candidates {
+		if enough() {
+			break
+		}
+		if i == 0 {
+			open = openRemovals(candidates)
+		}
+		if removeFile(r, open) {
+			removed = append(removed, r)
+		}
+	}
+	return removed
+}
+
+// DirSize returns the total size of the files directly in dirs; each
+// distinct directory is counted once, and a missing one counts as empty.
+func DirSize(dirs ...string) (int64, error) {
+	var total int64
+	for _, dir := range uniqueDirs(dirs) {
+		entries, err := os.ReadDir(dir)
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return 0, err
+		}
+		for _, e := range entries {
+			if !e.Type().IsRegular() {
+				continue
+			}
+			if info, err := e.Info(); err == nil {
+				total += info.Size()
+			}
+		}
+	}
+	return total, nil
+}
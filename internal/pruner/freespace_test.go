@@ -0,0 +1,42 @@
+package pruner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFreeUntil_DeletesOldestFirst(t *testing.T) {
+	dir, quarantine := t.TempDir(), t.TempDir()
+	now := time.Now()
+	for i, name := range []string{"snapshot-100-HashA.tar.zst", "snapshot-200-HashB.tar.zst", "snapshot-300-HashC.tar.zst"} {
+		createFile(t, dir, name)
+		at := now.Add(time.Duration(i-3) * time.Hour)
+		os.Chtimes(filepath.Join(dir, name), at, at)
+	}
+	// Quarantined before snapshot-100 was downloaded, so the oldest
+	createFile(t, quarantine, "snapshot-50-HashQ.tar.zst")
+	os.Chtimes(filepath.Join(quarantine, "snapshot-50-HashQ.tar.zst"), now.Add(-5*time.Hour), now.Add(-5*time.Hour))
+
+	plan, err := PlanPrune(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	candidates, err := PlanFreeSpace(plan, quarantine)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(candidates) != 3 || candidates[0].Path != filepath.Join(quarantine, "snapshot-50-HashQ.tar.zst") || candidates[1].Path != filepath.Join(dir, "snapshot-100-HashA.tar.zst") {
+		t.Fatalf("candidates = %+v, want oldest first and the newest full excluded", candidates)
+	}
+
+	// Enough space once two files are gone
+	removed := FreeUntil(candidates, func() bool { return !fileExists(dir, "snapshot-100-HashA.tar.zst") })
+	if len(removed) != 2 {
+		t.Errorf("removed %d files, want 2", len(removed))
+	}
+	if !fileExists(dir, "snapshot-200-HashB.tar.zst") || !fileExists(dir, "snapshot-300-HashC.tar.zst") {
+		t.Error("files should be kept once there is enough free space")
+	}
+}
@@ -0,0 +1,77 @@
+package pruner
+
+import (
+	"os"
+	"path/filepath"
+	"strconv"
+	"syscall"
+)
+
+// fileID identifies a file regardless of the path it is reached by.
+type fileID struct {
+	dev, ino uint64
+}
+
+// openFiles returns which of paths other processes have open, each mapped
+// to a PID holding it. A descriptor in /proc/<pid>/fd matches a path when it
+// refers to the same device and inode, so paths reached through symlinks or
+// bind mounts are caught too. Scanning stops once every path is found open.
+// Processes whose descriptors can't be read, such as another user's without
+// CAP_SYS_PTRACE, are skipped.
+func openFiles(paths []string) map[string]int {
+	want := map[fileID]string{}
+	for _, path := range paths {
+		if id, ok := statID(path); ok {
+			want[id] = path
+		}
+	}
+	if len(want) == 0 {
+		return nil
+	}
+	procs, err := os.ReadDir("/proc")
+	if err != nil {
+		logger().Warn("can't list processes - not checking whether pruned files are open", "error", err)
+		return nil
+	}
+	self := os.Getpid()
+	open := map[string]int{}
+	for _, p := range procs {
+		pid, err := strconv.Atoi(p.Name())
+		if err != nil || pid == self {
+			continue
+		}
+		fdDir := filepath.Join("/proc", p.Name(), "fd")
+		fds, err := os.ReadDir(fdDir)
+		if err != nil {
+			continue
+		}
+		for _, fd := range fds {
+			id, ok := statID(filepath.Join(fdDir, fd.Name()))
+			if !ok {
+				continue
+			}
+			if path, ok := want[id]; ok {
+				open[path] = pid
+				delete(want, id)
+				if len(want) == 0 {
+					return open
+				}
+			}
+		}
+	}
+	return open
+}
+
+// statID returns the device and inode of the file at path, following
+// symlinks such as /proc's descriptor links.
+func statID(path string) (fileID, bool) {
+	info, err := os.Stat(path)
+	if err != nil {
+		return fileID{}, false
+	}
+	st, ok := info.Sys().(*syscall.Stat_t)
+	if !ok || !info.Mode().IsRegular() {
+		return fileID{}, false
+	}
+	return fileID{dev: uint64(st.Dev), ino: st.Ino}, true
+}
@@ -0,0 +1,86 @@
+package pruner
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestPrune_KeepsFilesOpenInAnotherProcess(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	dir := t.TempDir()
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "snapshot-200-HashB.tar.zst")
+	createFile(t, dir, "snapshot-300-HashC.tar.zst")
+
+	// A validator loading the old snapshot-100
+	f, err := os.Open(filepath.Join(dir, "snapshot-100-HashA.tar.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	loader := exec.Command("sleep", "30")
+	loader.Stdin = f
+	if err := loader.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		loader.Process.Kill()
+		loader.Wait()
+	}()
+
+	if err := Prune(dir); err != nil {
+		t.Fatal(err)
+	}
+
+	if !fileExists(dir, "snapshot-100-HashA.tar.zst") {
+		t.Error("snapshot open in another process should be kept")
+	}
+	if fileExists(dir, "snapshot-200-HashB.tar.zst") {
+		t.Error("unopened old snapshot should be removed")
+	}
+	if !fileExists(dir, "snapshot-300-HashC.tar.zst") {
+		t.Error("newest full should be kept")
+	}
+}
+
+func TestOpenFiles_MatchesByInode(t *testing.T) {
+	if _, err := exec.LookPath("sleep"); err != nil {
+		t.Skip("sleep not available")
+	}
+	dir := t.TempDir()
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "snapshot-200-HashB.tar.zst")
+	link := filepath.Join(t.TempDir(), "snapshots")
+	if err := os.Symlink(dir, link); err != nil {
+		t.Fatal(err)
+	}
+
+	// Opened through the symlinked directory
+	f, err := os.Open(filepath.Join(link, "snapshot-100-HashA.tar.zst"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	loader := exec.Command("sleep", "30")
+	loader.Stdin = f
+	if err := loader.Start(); err != nil {
+		t.Fatal(err)
+	}
+	defer func() {
+		loader.Process.Kill()
+		loader.Wait()
+	}()
+
+	held := filepath.Join(dir, "snapshot-100-HashA.tar.zst")
+	open := openFiles([]string{held, filepath.Join(dir, "snapshot-200-HashB.tar.zst"), filepath.Join(dir, "missing.tar.zst")})
+	if pid, ok := open[held]; !ok || pid != loader.Process.Pid {
+		t.Errorf("openFiles() = %v, want %s held by %d", open, held, loader.Process.Pid)
+	}
+	if len(open) != 1 {
+		t.Errorf("openFiles() = %v, want only the held file", open)
+	}
+}
@@ -7,6 +7,7 @@ import (
 	"regexp"
 	"sort"
 	"strconv"
+	"time"
 
 	"github.com/charmbracelet/log"
 )
@@ -16,9 +17,69 @@ func logger() *log.Logger { return log.Default().WithPrefix("pruner") }
 var (
 	fullSnapshotRe        = regexp.MustCompile(`^snapshot-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)$`)
 	incrementalSnapshotRe = regexp.MustCompile(`^incremental-snapshot-(\d+)-(\d+)-[A-Za-z0-9]+\.tar\.(zst|bz2|gz)$`)
-	tempFileRe            = regexp.MustCompile(`\.(tmp|partial)$`)
+	// Matches legacy ".tmp" and ".tmp.<pid>.<rand>" names as well as the
+	// downloader's ".tmp.<hash>" ones, and their ".partial" state files
+	tempFileRe = regexp.MustCompile(`\.(tmp|partial)$|\.tmp\.(\d+\.)?[0-9a-f]+(\.partial)?$`)
 )
 
+// Options tunes pruning.
+type Options struct {
+	// TempMinAge keeps temp files modified more recently than this, as
+	// another process may still be writing them (0 = remove all)
+	TempMinAge time.Duration
+	// Now is the time temp file ages are measured from (zero = time.Now)
+	Now time.Time
+	// FullUsableAlone reports whether a full at slot is fresh enough to load
+	// without an incremental. While the newest full has no incremental and
+	// isn't, the previous full and its newest incremental are kept alongside
+	// it (nil = never keep them)
+	FullUsableAlone func(slot uint64) bool
+}
+
+// heldFull returns the slot of the full kept alongside the newest, fulls[0],
+// or 0 for none: the previous one, while the newest has no incremental and
+// isn't usable alone. fulls is sorted newest first.
+func (o Options) heldFull(fulls, incrementals []SnapshotFile) uint64 {
+	if o.FullUsableAlone == nil || len(fulls) < 2 {
+		return 0
+	}
+	newest := fulls[0].Slot
+	for _, inc := range incrementals {
+		if inc.BaseSlot == newest {
+			return 0
+		}
+	}
+	if o.FullUsableAlone(newest) {
+		return 0
+	}
+	logger().Warn("newest full snapshot has no matching incremental yet - keeping the previous full and its incremental", "full_slot", newest, "previous_full_slot", fulls[1].Slot)
+	return fulls[1].Slot
+}
+
+// isStaleTemp reports whether e, in dir, is a temp file old enough to
+// remove.
+func (o Options) isStaleTemp(dir string, e os.DirEntry) bool {
+	if !tempFileRe.MatchString(e.Name()) {
+		return false
+	}
+	if o.TempMinAge <= 0 {
+		return true
+	}
+	info, err := e.Info()
+	if err != nil {
+		return false
+	}
+	now := o.Now
+	if now.IsZero() {
+		now = time.Now()
+	}
+	if age := now.Sub(info.ModTime()); age < o.TempMinAge {
+		logger().Debug("keeping recent temp file", "file", filepath.Join(dir, e.Name()), "age", age.Round(time.Second))
+		return false
+	}
+	return true
+}
+
 // SnapshotFile represents a parsed snapshot file on disk.
 type SnapshotFile struct {
 	Path     string
@@ -27,59 +88,123 @@ type SnapshotFile struct {
 	IsFull   bool
 }
 
+// Removal is a file pruning would delete, and why.
+type Removal struct {
+	Path   string
+	Reason string
+	// Quarantine is set for superseded snapshots, which are moved to a
+	// quarantine directory, when there is one, instead of removed
+	Quarantine bool
+}
+
+// Plan describes what pruning keeps and removes.
+type Plan struct {
+	Keep   []SnapshotFile
+	Remove []Removal
+}
+
 // Prune removes old snapshots, keeping only the most recent full snapshot
 // and incrementals that match its base slot. It also removes temp files.
-func Prune(snapshotDir string) error {
-	entries, err := os.ReadDir(snapshotDir)
+// Fulls and incrementals may be kept in separate directories; each distinct
+// directory is scanned once.
+func Prune(snapshotDirs ...string) error {
+	plan, err := PlanPrune(snapshotDirs...)
 	if err != nil {
 		return err
 	}
+	plan.Apply()
+	return nil
+}
 
-	var fulls []SnapshotFile
-	var incrementals []SnapshotFile
-	var tempFiles []string
-
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
+// Apply removes the plan's files and returns those it removed.
+func (p Plan) Apply() []Removal {
+	var removed []Removal
+	open := p.openFiles()
+	for _, r := range p.Remove {
+		if removeFile(r, open) {
+			removed = append(removed, r)
 		}
-		name := e.Name()
+	}
+	return removed
+}
 
-		if tempFileRe.MatchString(name) {
-			tempFiles = append(tempFiles, filepath.Join(snapshotDir, name))
-			continue
-		}
+// openFiles returns which of the plan's files other processes have open.
+func (p Plan) openFiles() map[string]int {
+	return openRemovals(p.Remove)
+}
 
-		if matches := fullSnapshotRe.FindStringSubmatch(name); matches != nil {
-			slot, _ := strconv.ParseUint(matches[1], 10, 64)
-			fulls = append(fulls, SnapshotFile{
-				Path:   filepath.Join(snapshotDir, name),
-				Slot:   slot,
-				IsFull: true,
-			})
-			continue
-		}
+// openRemovals returns which of removals' files other processes have open.
+func openRemovals(removals []Removal) map[string]int {
+	if len(removals) == 0 {
+		return nil
+	}
+	paths := make([]string, len(removals))
+	for i, r := range removals {
+		paths[i] = r.Path
+	}
+	return openFiles(paths)
+}
 
-		if matches := incrementalSnapshotRe.FindStringSubmatch(name); matches != nil {
-			baseSlot, _ := strconv.ParseUint(matches[1], 10, 64)
-			slot, _ := strconv.ParseUint(matches[2], 10, 64)
-			incrementals = append(incrementals, SnapshotFile{
-				Path:     filepath.Join(snapshotDir, name),
-				Slot:     slot,
-				BaseSlot: baseSlot,
-			})
-			continue
+// removeFile removes r's file, reporting whether it did. A file another
+// process has open, such as a snapshot a booting validator is loading, is
+// kept.
+func removeFile(r Removal, open map[string]int) bool {
+	if pid, ok := open[r.Path]; ok {
+		logger().Warn("not pruning file open in another process", "file", r.Path, "pid", pid, "reason", r.Reason)
+		return false
+	}
+	logger().Warn(fmt.Sprintf("pruning %s", r.Reason), "file", r.Path)
+	if err := os.Remove(r.Path); err != nil {
+		if !os.IsNotExist(err) {
+			logger().Error("failed to remove file", "file", r.Path, "error", err)
 		}
+		return false
 	}
+	return true
+}
+
+// PlanPrune works out what Prune would remove, without removing anything.
+func PlanPrune(snapshotDirs ...string) (Plan, error) {
+	return PlanPruneWithOptions(Options{}, snapshotDirs...)
+}
+
+// PlanPruneWithOptions is PlanPrune, tuned by opts.
+func PlanPruneWithOptions(opts Options, snapshotDirs ...string) (Plan, error) {
+	var plan Plan
+	var fulls []SnapshotFile
+	var incrementals []SnapshotFile
 
-	// Remove temp files
-	for _, f := range tempFiles {
-		logger().Warn("removing temp file", "file", filepath.Base(f))
-		os.Remove(f)
+	for _, dir := range uniqueDirs(snapshotDirs) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return Plan{}, err
+		}
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			name := e.Name()
+
+			if tempFileRe.MatchString(name) {
+				if opts.isStaleTemp(dir, e) {
+					plan.Remove = append(plan.Remove, Removal{Path: filepath.Join(dir, name), Reason: "temp file"})
+				}
+				continue
+			}
+			if f, ok := parseSnapshotFile(dir, name); ok {
+				if f.IsFull {
+					fulls = append(fulls, f)
+				} else {
+					incrementals = append(incrementals, f)
+				}
+			}
+		}
 	}
 
 	if len(fulls) == 0 {
-		return nil
+		// Without a full there's nothing to judge incrementals against
+		plan.Keep = incrementals
+		return plan, nil
 	}
 
 	// Sort fulls by slot descending, keep the newest
@@ -88,11 +213,16 @@ func Prune(snapshotDir string) error {
 	})
 
 	newestFull := fulls[0]
+	plan.Keep = append(plan.Keep, newestFull)
+	keepFull := opts.heldFull(fulls, incrementals)
 
 	// Remove older full snapshots
 	for _, f := range fulls[1:] {
-		logger().Warn(fmt.Sprintf("pruning old full snapshot %s", f.Path))
-		os.Remove(f.Path)
+		if keepFull != 0 && f.Slot == keepFull {
+			plan.Keep = append(plan.Keep, f)
+			continue
+		}
+		plan.Remove = append(plan.Remove, Removal{Path: f.Path, Reason: fmt.Sprintf("old full snapshot - newest full is slot %d", newestFull.Slot), Quarantine: true})
 	}
 
 	// Among incrementals matching the newest full, keep only the newest one.
@@ -101,57 +231,100 @@ func Prune(snapshotDir string) error {
 		return incrementals[i].Slot > incrementals[j].Slot
 	})
 
-	keptIncremental := false
+	keptFor := map[uint64]bool{}
 	for _, inc := range incrementals {
-		if inc.BaseSlot != newestFull.Slot {
-			logger().Warn(fmt.Sprintf("pruning orphaned incremental snapshot - base slot %d != newest full slot %d", inc.BaseSlot, newestFull.Slot), "file", inc.Path)
-			os.Remove(inc.Path)
-		} else if keptIncremental {
-			logger().Warn("pruning older incremental snapshot", "file", inc.Path)
-			os.Remove(inc.Path)
+		kept := inc.BaseSlot == newestFull.Slot || (keepFull != 0 && inc.BaseSlot == keepFull)
+		if !kept {
+			plan.Remove = append(plan.Remove, Removal{Path: inc.Path, Reason: fmt.Sprintf("orphaned incremental snapshot - base slot %d != newest full slot %d", inc.BaseSlot, newestFull.Slot), Quarantine: true})
+		} else if keptFor[inc.BaseSlot] {
+			plan.Remove = append(plan.Remove, Removal{Path: inc.Path, Reason: "older incremental snapshot", Quarantine: true})
 		} else {
-			keptIncremental = true
+			plan.Keep = append(plan.Keep, inc)
+			keptFor[inc.BaseSlot] = true
 		}
 	}
 
-	return nil
+	return plan, nil
 }
 
-// GetLocalSnapshots returns parsed snapshot files from the given directory.
-func GetLocalSnapshots(snapshotDir string) ([]SnapshotFile, error) {
-	entries, err := os.ReadDir(snapshotDir)
+// PlanTempFiles lists leftover download temp files in dir, for a temp
+// directory kept apart from the snapshot directories. Nothing else there is
+// touched.
+func PlanTempFiles(dir string, opts Options) ([]Removal, error) {
+	entries, err := os.ReadDir(dir)
 	if err != nil {
 		return nil, err
 	}
+	var removals []Removal
+	for _, e := range entries {
+		if !e.IsDir() && opts.isStaleTemp(dir, e) {
+			removals = append(removals, Removal{Path: filepath.Join(dir, e.Name()), Reason: "temp file"})
+		}
+	}
+	return removals, nil
+}
 
+// GetLocalSnapshots returns parsed snapshot files from the given directories.
+func GetLocalSnapshots(snapshotDirs ...string) ([]SnapshotFile, error) {
 	var snapshots []SnapshotFile
-	for _, e := range entries {
-		if e.IsDir() {
-			continue
+	for _, dir := range uniqueDirs(snapshotDirs) {
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			return nil, err
 		}
-		name := e.Name()
-
-		if matches := fullSnapshotRe.FindStringSubmatch(name); matches != nil {
-			slot, _ := strconv.ParseUint(matches[1], 10, 64)
-			snapshots = append(snapshots, SnapshotFile{
-				Path:   filepath.Join(snapshotDir, name),
-				Slot:   slot,
-				IsFull: true,
-			})
-		} else if matches := incrementalSnapshotRe.FindStringSubmatch(name); matches != nil {
-			baseSlot, _ := strconv.ParseUint(matches[1], 10, 64)
-			slot, _ := strconv.ParseUint(matches[2], 10, 64)
-			snapshots = append(snapshots, SnapshotFile{
-				Path:     filepath.Join(snapshotDir, name),
-				Slot:     slot,
-				BaseSlot: baseSlot,
-			})
+		for _, e := range entries {
+			if e.IsDir() {
+				continue
+			}
+			if f, ok := parseSnapshotFile(dir, e.Name()); ok {
+				snapshots = append(snapshots, f)
+			}
 		}
 	}
 
 	return snapshots, nil
 }
 
+// parseSnapshotFile parses a full or incremental snapshot archive name.
+func parseSnapshotFile(dir, name string) (SnapshotFile, bool) {
+	if matches := fullSnapshotRe.FindStringSubmatch(name); matches != nil {
+		slot, _ := strconv.ParseUint(matches[1], 10, 64)
+		return SnapshotFile{
+			Path:   filepath.Join(dir, name),
+			Slot:   slot,
+			IsFull: true,
+		}, true
+	}
+	if matches := incrementalSnapshotRe.FindStringSubmatch(name); matches != nil {
+		baseSlot, _ := strconv.ParseUint(matches[1], 10, 64)
+		slot, _ := strconv.ParseUint(matches[2], 10, 64)
+		return SnapshotFile{
+			Path:     filepath.Join(dir, name),
+			Slot:     slot,
+			BaseSlot: baseSlot,
+		}, true
+	}
+	return SnapshotFile{}, false
+}
+
+// uniqueDirs drops empty and repeated directories, keeping order.
+func uniqueDirs(dirs []string) []string {
+	seen := make(map[string]bool, len(dirs))
+	var out []string
+	for _, d := range dirs {
+		if d == "" {
+			continue
+		}
+		clean := filepath.Clean(d)
+		if seen[clean] {
+			continue
+		}
+		seen[clean] = true
+		out = append(out, d)
+	}
+	return out
+}
+
 // NewestSlot returns the highest slot number across all local snapshots.
 // Returns 0 if no snapshots exist.
 func NewestSlot(snapshots []SnapshotFile) uint64 {
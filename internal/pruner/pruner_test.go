@@ -3,7 +3,10 @@ package pruner
 import (
 	"os"
 	"path/filepath"
+	"reflect"
+	"sort"
 	"testing"
+	"time"
 )
 
 func createFile(t *testing.T, dir, name string) {
@@ -78,6 +81,51 @@ func TestPrune_RemovesTempFiles(t *testing.T) {
 	}
 }
 
+func TestPlanTempFiles(t *testing.T) {
+	dir := t.TempDir()
+	createFile(t, dir, "snapshot-200-HashB.tar.zst.tmp")
+	createFile(t, dir, "snapshot-200-HashB.tar.zst.tmp.4242.9f3a01bc")
+	createFile(t, dir, "snapshot-200-HashB.tar.zst.tmp.4242.9f3a01bc.partial")
+	createFile(t, dir, "snapshot-300-HashC.tar.zst.tmp.5d41402a")
+	createFile(t, dir, "snapshot-300-HashC.tar.zst.tmp.5d41402a.partial")
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "scratch.dat")
+
+	removals, err := PlanTempFiles(dir, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(removals) != 5 {
+		t.Fatalf("expected only the 5 temp files, got %+v", removals)
+	}
+}
+
+func TestPlanPrune_TempMinAge(t *testing.T) {
+	dir := t.TempDir()
+	now := time.Now()
+	createFile(t, dir, "snapshot-300-HashC.tar.zst")
+	// A crashed run's leftovers, and another process's download in progress
+	for _, name := range []string{"snapshot-200-HashB.tar.zst.tmp.100.aa11", "snapshot-200-HashB.tar.zst.tmp.100.aa11.partial"} {
+		createFile(t, dir, name)
+		os.Chtimes(filepath.Join(dir, name), now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	}
+	createFile(t, dir, "snapshot-400-HashD.tar.zst.tmp.200.bb22")
+
+	plan, err := PlanPruneWithOptions(Options{TempMinAge: time.Hour, Now: now}, dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var removed []string
+	for _, r := range plan.Remove {
+		removed = append(removed, filepath.Base(r.Path))
+	}
+	sort.Strings(removed)
+	want := []string{"snapshot-200-HashB.tar.zst.tmp.100.aa11", "snapshot-200-HashB.tar.zst.tmp.100.aa11.partial"}
+	if !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want only the stale temp files %v", removed, want)
+	}
+}
+
 func TestGetLocalSnapshots(t *testing.T) {
 	dir := t.TempDir()
 	createFile(t, dir, "snapshot-100-HashA.tar.zst")
@@ -138,3 +186,129 @@ func TestNewestFullSnapshot_NoFulls(t *testing.T) {
 		t.Error("expected nil when no full snapshots")
 	}
 }
+
+func TestPrune_SeparateIncrementalDirectory(t *testing.T) {
+	fullDir := t.TempDir()
+	incDir := t.TempDir()
+	createFile(t, fullDir, "snapshot-100-HashA.tar.zst")
+	createFile(t, fullDir, "snapshot-300-HashC.tar.zst")
+	createFile(t, incDir, "incremental-snapshot-300-350-HashD.tar.zst")
+	createFile(t, incDir, "incremental-snapshot-300-320-HashF.tar.zst")
+	createFile(t, incDir, "incremental-snapshot-100-150-HashE.tar.zst")
+
+	if err := Prune(fullDir, incDir); err != nil {
+		t.Fatal(err)
+	}
+
+	if fileExists(fullDir, "snapshot-100-HashA.tar.zst") {
+		t.Error("older full should be removed")
+	}
+	if !fileExists(incDir, "incremental-snapshot-300-350-HashD.tar.zst") {
+		t.Error("newest matching incremental should be kept")
+	}
+	if fileExists(incDir, "incremental-snapshot-300-320-HashF.tar.zst") {
+		t.Error("older matching incremental should be removed")
+	}
+	if fileExists(incDir, "incremental-snapshot-100-150-HashE.tar.zst") {
+		t.Error("orphaned incremental should be removed")
+	}
+
+	snaps, err := GetLocalSnapshots(fullDir, incDir, fullDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(snaps) != 2 {
+		t.Errorf("expected 2 snapshots across both directories, got %d", len(snaps))
+	}
+}
+
+func TestPlanPrune_DoesNotRemove(t *testing.T) {
+	dir := t.TempDir()
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "snapshot-300-HashC.tar.zst")
+	createFile(t, dir, "incremental-snapshot-300-350-HashD.tar.zst")
+	createFile(t, dir, "incremental-snapshot-300-320-HashF.tar.zst")
+	createFile(t, dir, "incremental-snapshot-100-150-HashE.tar.zst")
+	createFile(t, dir, "snapshot-400-HashG.tar.zst.tmp")
+
+	plan, err := PlanPrune(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reasons := map[string]string{}
+	for _, r := range plan.Remove {
+		reasons[filepath.Base(r.Path)] = r.Reason
+	}
+	want := map[string]string{
+		"snapshot-400-HashG.tar.zst.tmp":             "temp file",
+		"snapshot-100-HashA.tar.zst":                 "old full snapshot - newest full is slot 300",
+		"incremental-snapshot-300-320-HashF.tar.zst": "older incremental snapshot",
+		"incremental-snapshot-100-150-HashE.tar.zst": "orphaned incremental snapshot - base slot 100 != newest full slot 300",
+	}
+	if len(reasons) != len(want) {
+		t.Fatalf("expected %d removals, got %v", len(want), reasons)
+	}
+	for name, reason := range want {
+		if reasons[name] != reason {
+			t.Errorf("%s: expected reason %q, got %q", name, reason, reasons[name])
+		}
+	}
+	if len(plan.Keep) != 2 {
+		t.Errorf("expected newest full and incremental kept, got %+v", plan.Keep)
+	}
+
+	for name := range want {
+		if !fileExists(dir, name) {
+			t.Errorf("%s should not be removed by planning", name)
+		}
+	}
+}
+
+func TestPlanPrune_HoldsPreviousFull(t *testing.T) {
+	dir := t.TempDir()
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "incremental-snapshot-100-150-HashB.tar.zst")
+	createFile(t, dir, "snapshot-200-HashC.tar.zst")
+	createFile(t, dir, "incremental-snapshot-200-250-HashD.tar.zst")
+	createFile(t, dir, "incremental-snapshot-200-280-HashE.tar.zst")
+	createFile(t, dir, "snapshot-300-HashF.tar.zst")
+
+	planNames := func(opts Options) (kept, removed []string) {
+		t.Helper()
+		plan, err := PlanPruneWithOptions(opts, dir)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for _, f := range plan.Keep {
+			kept = append(kept, filepath.Base(f.Path))
+		}
+		for _, r := range plan.Remove {
+			removed = append(removed, filepath.Base(r.Path))
+		}
+		sort.Strings(kept)
+		sort.Strings(removed)
+		return kept, removed
+	}
+
+	// The newest full has no incremental and is too old to use alone
+	kept, removed := planNames(Options{FullUsableAlone: func(uint64) bool { return false }})
+	if want := []string{"incremental-snapshot-200-280-HashE.tar.zst", "snapshot-200-HashC.tar.zst", "snapshot-300-HashF.tar.zst"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %v, want %v", kept, want)
+	}
+	if want := []string{"incremental-snapshot-100-150-HashB.tar.zst", "incremental-snapshot-200-250-HashD.tar.zst", "snapshot-100-HashA.tar.zst"}; !reflect.DeepEqual(removed, want) {
+		t.Errorf("removed %v, want %v", removed, want)
+	}
+
+	// Fresh enough alone, nothing is held
+	if kept, _ := planNames(Options{FullUsableAlone: func(uint64) bool { return true }}); !reflect.DeepEqual(kept, []string{"snapshot-300-HashF.tar.zst"}) {
+		t.Errorf("kept %v with the newest full usable alone, want only it", kept)
+	}
+
+	// An incremental for the newest full completes its pair
+	createFile(t, dir, "incremental-snapshot-300-350-HashG.tar.zst")
+	kept, _ = planNames(Options{FullUsableAlone: func(uint64) bool { return false }})
+	if want := []string{"incremental-snapshot-300-350-HashG.tar.zst", "snapshot-300-HashF.tar.zst"}; !reflect.DeepEqual(kept, want) {
+		t.Errorf("kept %v with the new pair complete, want %v", kept, want)
+	}
+}
@@ -0,0 +1,93 @@
+package pruner
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// Quarantine applies the plan like Apply, except superseded snapshots are
+// moved into dir rather than removed, so a validator still loading one
+// isn't left without it. Moved files are stamped with now, which is what
+// PlanQuarantineExpiry measures their time in quarantine from. dir must be
+// on the same filesystem as the snapshots. It returns the files it moved or
+// removed.
+func (p Plan) Quarantine(dir string, now time.Time) []Removal {
+	var done []Removal
+	open := p.openFiles()
+	for _, r := range p.Remove {
+		if !r.Quarantine {
+			if removeFile(r, open) {
+				done = append(done, r)
+			}
+			continue
+		}
+		logger().Warn(fmt.Sprintf("quarantining %s", r.Reason), "file", r.Path, "quarantine_dir", dir)
+		dest := QuarantinePath(dir, r.Path)
+		if err := os.Rename(r.Path, dest); err != nil {
+			if !os.IsNotExist(err) {
+				logger().Error("failed to quarantine file, keeping it", "file", r.Path, "error", err)
+			}
+			continue
+		}
+		if err := os.Chtimes(dest, now, now); err != nil {
+			logger().Warn("failed to stamp quarantined file, it may expire early", "file", dest, "error", err)
+		}
+		done = append(done, r)
+	}
+	return done
+}
+
+// QuarantinePath returns where Quarantine moves path to in dir.
+func QuarantinePath(dir, path string) string {
+	return filepath.Join(dir, filepath.Base(path))
+}
+
+// PlanQuarantineExpiry lists snapshots that have been in the quarantine
+// directory for longer than ttl. A missing directory has nothing to expire.
+func PlanQuarantineExpiry(dir string, ttl time.Duration, now time.Time) ([]Removal, error) {
+	files, err := quarantinedSnapshots(dir)
+	if err != nil {
+		return nil, err
+	}
+	var removals []Removal
+	for _, f := range files {
+		if now.Sub(f.modTime) > ttl {
+			removals = append(removals, Removal{Path: f.Path, Reason: fmt.Sprintf("quarantined for over %s", ttl)})
+		}
+	}
+	return removals, nil
+}
+
+// datedRemoval is a removal with its file's modification time.
+type datedRemoval struct {
+	Removal
+	modTime time.Time
+}
+
+// quarantinedSnapshots lists the snapshots in the quarantine directory.
+func quarantinedSnapshots(dir string) ([]datedRemoval, error) {
+	entries, err := os.ReadDir(dir)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	var files []datedRemoval
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		if _, ok := parseSnapshotFile(dir, e.Name()); !ok {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil {
+			continue
+		}
+		files = append(files, datedRemoval{Removal: Removal{Path: filepath.Join(dir, e.Name()), Reason: "quarantined snapshot"}, modTime: info.ModTime()})
+	}
+	return files, nil
+}
@@ -0,0 +1,62 @@
+package pruner
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestQuarantine_MovesSupersededSnapshots(t *testing.T) {
+	dir, quarantine := t.TempDir(), t.TempDir()
+	createFile(t, dir, "snapshot-100-HashA.tar.zst")
+	createFile(t, dir, "snapshot-200-HashB.tar.zst")
+	createFile(t, dir, "incremental-snapshot-100-150-HashC.tar.zst")
+	createFile(t, dir, "snapshot-300-HashD.tar.zst.tmp.100.aa11")
+
+	plan, err := PlanPrune(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Now()
+	if done := plan.Quarantine(quarantine, now); len(done) != 3 {
+		t.Fatalf("quarantined or removed %d files, want 3", len(done))
+	}
+
+	if !fileExists(dir, "snapshot-200-HashB.tar.zst") {
+		t.Error("newest full should be kept")
+	}
+	for _, name := range []string{"snapshot-100-HashA.tar.zst", "incremental-snapshot-100-150-HashC.tar.zst"} {
+		if fileExists(dir, name) || !fileExists(quarantine, name) {
+			t.Errorf("%s should have moved to quarantine", name)
+		}
+	}
+	if fileExists(dir, "snapshot-300-HashD.tar.zst.tmp.100.aa11") || fileExists(quarantine, "snapshot-300-HashD.tar.zst.tmp.100.aa11") {
+		t.Error("temp file should be removed, not quarantined")
+	}
+
+	// Quarantined files expire ttl after they were moved, not after they
+	// were downloaded
+	expired, err := PlanQuarantineExpiry(quarantine, time.Hour, now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 0 {
+		t.Errorf("nothing should have expired yet, got %v", expired)
+	}
+	os.Chtimes(filepath.Join(quarantine, "snapshot-100-HashA.tar.zst"), now.Add(-2*time.Hour), now.Add(-2*time.Hour))
+	expired, err = PlanQuarantineExpiry(quarantine, time.Hour, now.Add(30*time.Minute))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(expired) != 1 || expired[0].Path != filepath.Join(quarantine, "snapshot-100-HashA.tar.zst") || expired[0].Quarantine {
+		t.Errorf("expired = %+v, want only snapshot-100", expired)
+	}
+}
+
+func TestPlanQuarantineExpiry_MissingDirectory(t *testing.T) {
+	expired, err := PlanQuarantineExpiry(filepath.Join(t.TempDir(), "missing"), time.Hour, time.Now())
+	if err != nil || len(expired) != 0 {
+		t.Errorf("got %v, %v; want nothing", expired, err)
+	}
+}
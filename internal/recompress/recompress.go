@@ -0,0 +1,85 @@
+// Package recompress converts bzip2 and gzip snapshot archives to zstd,
+// which Agave unpacks much faster. The old archive is decompressed in a
+// stream piped straight into the zstd binary, so nothing is unpacked to disk.
+package recompress
+
+import (
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("recompress") }
+
+// Needed reports whether path is an archive ToZstd converts.
+func Needed(path string) bool {
+	return strings.HasSuffix(path, ".tar.bz2") || strings.HasSuffix(path, ".tar.gz")
+}
+
+// ToZstd recompresses the .tar.bz2 or .tar.gz archive at path to a .tar.zst
+// next to it at the given zstd level, then removes the original. It returns
+// the new archive's path. Other archives are returned unchanged. The zstd
+// binary must be on PATH.
+func ToZstd(ctx context.Context, path string, level int) (string, error) {
+	if !Needed(path) {
+		return path, nil
+	}
+	base := strings.TrimSuffix(strings.TrimSuffix(path, ".bz2"), ".gz")
+	destPath := base + ".zst"
+	tempPath := destPath + ".tmp"
+
+	f, err := os.Open(path)
+	if err != nil {
+		return "", fmt.Errorf("opening archive: %w", err)
+	}
+	defer f.Close()
+
+	var r io.Reader
+	if strings.HasSuffix(path, ".bz2") {
+		r = bzip2.NewReader(f)
+	} else {
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			return "", fmt.Errorf("opening gzip stream: %w", err)
+		}
+		defer gz.Close()
+		r = gz
+	}
+
+	start := time.Now()
+	cmd := exec.CommandContext(ctx, "zstd", "-"+strconv.Itoa(level), "-T0", "-q", "-f", "-o", tempPath)
+	cmd.Stdin = r
+	var stderr strings.Builder
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		os.Remove(tempPath)
+		if errors.Is(err, exec.ErrNotFound) {
+			return "", fmt.Errorf("zstd is required to recompress archives: %w", err)
+		}
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return "", fmt.Errorf("zstd: %w: %s", err, msg)
+		}
+		return "", fmt.Errorf("recompressing: %w", err)
+	}
+
+	if err := os.Rename(tempPath, destPath); err != nil {
+		os.Remove(tempPath)
+		return "", fmt.Errorf("renaming recompressed archive: %w", err)
+	}
+	if err := os.Remove(path); err != nil {
+		logger().Warn("could not remove the original archive", "path", path, "error", err)
+	}
+
+	logger().Info(fmt.Sprintf("recompressed archive to zstd in %s", time.Since(start).Round(time.Second)), "from", path, "to", destPath, "level", level)
+	return destPath, nil
+}
@@ -0,0 +1,74 @@
+package recompress
+
+import (
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestToZstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+
+	payload := bytes.Repeat([]byte("snapshot archive contents "), 4096)
+	dir := t.TempDir()
+	src := filepath.Join(dir, "snapshot-100-Hash.tar.gz")
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(payload)
+	gz.Close()
+	os.WriteFile(src, buf.Bytes(), 0644)
+
+	dest, err := ToZstd(context.Background(), src, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if dest != filepath.Join(dir, "snapshot-100-Hash.tar.zst") {
+		t.Errorf("unexpected destination %s", dest)
+	}
+	if _, err := os.Stat(src); !os.IsNotExist(err) {
+		t.Error("expected the original archive to be removed")
+	}
+	out, err := exec.Command("zstd", "-d", "-c", dest).Output()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(out, payload) {
+		t.Error("recompressed archive doesn't hold the original contents")
+	}
+}
+
+func TestToZstd_CorruptArchiveKeepsOriginal(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+
+	dir := t.TempDir()
+	src := filepath.Join(dir, "snapshot-100-Hash.tar.bz2")
+	os.WriteFile(src, []byte("BZh9 not really bzip2"), 0644)
+
+	if _, err := ToZstd(context.Background(), src, 3); err == nil {
+		t.Fatal("expected a corrupt archive to fail")
+	}
+	if _, err := os.Stat(src); err != nil {
+		t.Error("expected the original archive to be kept")
+	}
+	for _, name := range []string{"snapshot-100-Hash.tar.zst", "snapshot-100-Hash.tar.zst.tmp"} {
+		if _, err := os.Stat(filepath.Join(dir, name)); !os.IsNotExist(err) {
+			t.Errorf("expected no %s to be left behind", name)
+		}
+	}
+}
+
+func TestToZstd_ZstdUnchanged(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot-100-Hash.tar.zst")
+	got, err := ToZstd(context.Background(), path, 3)
+	if err != nil || got != path {
+		t.Errorf("ToZstd() = %q, %v; want the path unchanged", got, err)
+	}
+}
@@ -0,0 +1,255 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/knadh/koanf/parsers/yaml"
+	"github.com/knadh/koanf/providers/file"
+	"github.com/knadh/koanf/v2"
+)
+
+// Version is the keeper version recorded in reports; set by cmd.
+var Version = "dev"
+
+const redacted = "REDACTED"
+
+// Failure is one failed cycle.
+type Failure struct {
+	At    time.Time `json:"at"`
+	Error string    `json:"error"`
+}
+
+// Report is a pre-filled diagnostic issue report.
+type Report struct {
+	Title               string            `json:"title"`
+	Body                string            `json:"body"` // markdown, ready to paste into an issue
+	GeneratedAt         time.Time         `json:"generated_at"`
+	ConsecutiveFailures int               `json:"consecutive_failures"`
+	Failures            []Failure         `json:"failures"`
+	Environment         map[string]string `json:"environment"`
+	Config              string            `json:"config"` // redacted YAML
+}
+
+// Options describes the failures being reported.
+type Options struct {
+	ConfigFile          string
+	Cluster             string
+	ConsecutiveFailures int
+	Failures            []Failure // most recent last
+	Now                 time.Time
+}
+
+// Build assembles a report. Secrets in the config file are redacted: auth
+// sections, token/secret/password keys, hook environment values and URL
+// paths, queries and credentials.
+func Build(opts Options) Report {
+	cfgYAML, err := RedactedConfig(opts.ConfigFile)
+	if err != nil {
+		cfgYAML = fmt.Sprintf("# could not read config: %v\n", err)
+	}
+
+	env := map[string]string{
+		"version": Version,
+		"go":      runtime.Version(),
+		"os_arch": runtime.GOOS + "/" + runtime.GOARCH,
+		"cpus":    fmt.Sprintf("%d", runtime.NumCPU()),
+		"cluster": opts.Cluster,
+	}
+
+	r := Report{
+		Title:               fmt.Sprintf("Snapshot keeper failed %d consecutive cycles on %s", opts.ConsecutiveFailures, opts.Cluster),
+		GeneratedAt:         opts.Now.UTC(),
+		ConsecutiveFailures: opts.ConsecutiveFailures,
+		Failures:            opts.Failures,
+		Environment:         env,
+		Config:              cfgYAML,
+	}
+	r.Body = r.markdown()
+	return r
+}
+
+func (r Report) markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "## Summary\n\nsolana-validator-snapshot-keeper failed %d consecutive cycles (generated %s).\n\n", r.ConsecutiveFailures, r.GeneratedAt.Format(time.RFC3339))
+
+	b.WriteString("## Recent errors\n\n")
+	for _, f := range r.Failures {
+		fmt.Fprintf(&b, "- `%s` %s\n", f.At.UTC().Format(time.RFC3339), f.Error)
+	}
+
+	b.WriteString("\n## Environment\n\n")
+	for _, key := range []string{"version", "go", "os_arch", "cpus", "cluster"} {
+		fmt.Fprintf(&b, "- %s: `%s`\n", key, r.Environment[key])
+	}
+
+	fmt.Fprintf(&b, "\n## Configuration (redacted)\n\n```yaml\n%s```\n", r.Config)
+	b.WriteString("\n_Review this report for anything sensitive before sharing it._\n")
+	return b.String()
+}
+
+// Write saves the report body as markdown in dir and returns its path.
+func Write(dir string, r Report) (string, error) {
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return "", fmt.Errorf("creating report directory: %w", err)
+	}
+	path := filepath.Join(dir, fmt.Sprintf("issue-report-%s.md", r.GeneratedAt.Format("20060102T150405Z")))
+	if err := os.WriteFile(path, []byte("# "+r.Title+"\n\n"+r.Body), 0644); err != nil {
+		return "", fmt.Errorf("writing report: %w", err)
+	}
+	return path, nil
+}
+
+// Post sends the report as JSON to url with headers added.
+func Post(ctx context.Context, url string, headers http.Header, r Report) error {
+	data, err := json.Marshal(r)
+	if err != nil {
+		return fmt.Errorf("marshalling report: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 30*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(data))
+	if err != nil {
+		return fmt.Errorf("creating request: %w", err)
+	}
+	for name, values := range headers {
+		req.Header[name] = values
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("posting report: %w", err)
+	}
+	resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		return fmt.Errorf("posting report: unexpected status %d", resp.StatusCode)
+	}
+	return nil
+}
+
+// RedactedConfig returns the config file at path as YAML with secrets removed.
+func RedactedConfig(path string) (string, error) {
+	k := koanf.New(".")
+	if err := k.Load(file.Provider(path), yaml.Parser()); err != nil {
+		return "", err
+	}
+	out, err := yaml.Parser().Marshal(redactMap(k.Raw()))
+	if err != nil {
+		return "", err
+	}
+	return string(out), nil
+}
+
+// Redact returns a copy of a nested config map with secrets removed, using
+// the same rules as RedactedConfig.
+func Redact(m map[string]any) map[string]any {
+	return redactMap(m)
+}
+
+func redactMap(m map[string]any) map[string]any {
+	out := make(map[string]any, len(m))
+	for key, v := range m {
+		out[key] = redactValue(key, v)
+	}
+	return out
+}
+
+func redactValue(key string, v any) any {
+	if isSecretKey(key) {
+		return redactAll(v)
+	}
+	switch val := v.(type) {
+	case map[string]any:
+		return redactMap(val)
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = redactValue(key, item)
+		}
+		return out
+	case string:
+		return redactURL(val)
+	default:
+		return v
+	}
+}
+
+// redactAll replaces every leaf value, keeping map keys so the shape of the
+// config is still visible.
+func redactAll(v any) any {
+	switch val := v.(type) {
+	case map[string]any:
+		out := make(map[string]any, len(val))
+		for key, item := range val {
+			out[key] = redactAll(item)
+		}
+		return out
+	case []any:
+		out := make([]any, len(val))
+		for i, item := range val {
+			out[i] = redactAll(item)
+		}
+		return out
+	case string:
+		if val == "" {
+			return val
+		}
+		return redacted
+	default:
+		return redacted
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	if key == "auth" || key == "environment" {
+		return true
+	}
+	for _, s := range []string{"token", "secret", "password"} {
+		if strings.Contains(key, s) {
+			return true
+		}
+	}
+	return false
+}
+
+// urlPattern matches URLs embedded in free text such as error messages.
+var urlPattern = regexp.MustCompile(`[a-zA-Z][a-zA-Z0-9+.-]*://[^\s"'<>]+`)
+
+// RedactText applies redactURL to every URL in s, e.g. the endpoints that
+// RPC and HTTP errors quote.
+func RedactText(s string) string {
+	return urlPattern.ReplaceAllStringFunc(s, func(u string) string {
+		trimmed := strings.TrimRight(u, ":,.;)")
+		return redactURL(trimmed) + u[len(trimmed):]
+	})
+}
+
+// redactURL keeps only the scheme and host of URLs, since RPC providers
+// commonly carry API keys in the path or query string.
+func redactURL(s string) string {
+	if !strings.Contains(s, "://") {
+		return s
+	}
+	u, err := url.Parse(s)
+	if err != nil || u.Host == "" {
+		return s
+	}
+	if u.User == nil && (u.Path == "" || u.Path == "/") && u.RawQuery == "" {
+		return s
+	}
+	return u.Scheme + "://" + u.Host + "/" + redacted
+}
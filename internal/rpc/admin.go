@@ -0,0 +1,96 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net"
+	"time"
+)
+
+// adminTimeout bounds one admin RPC exchange.
+const adminTimeout = 5 * time.Second
+
+// AdminClient talks JSON-RPC to Agave's admin RPC, served on the admin.rpc
+// unix socket in the ledger directory. It answers whether or not the
+// validator serves RPC over HTTP.
+type AdminClient struct {
+	path string
+}
+
+// NewAdminClient returns a client for the admin RPC socket at path.
+func NewAdminClient(path string) *AdminClient {
+	return &AdminClient{path: path}
+}
+
+// Path returns the socket the client connects to.
+func (c *AdminClient) Path() string { return c.path }
+
+// ContactInfo is the validator's contact info as the admin RPC reports it.
+type ContactInfo struct {
+	// ID is the identity pubkey the validator currently runs with
+	ID           string `json:"id"`
+	Gossip       string `json:"gossip"`
+	TPU          string `json:"tpu"`
+	RPC          string `json:"rpc"`
+	ShredVersion uint16 `json:"shred_version"`
+}
+
+// call sends one request over a fresh connection; the admin RPC frames
+// messages as bare JSON values.
+func (c *AdminClient) call(ctx context.Context, method string) (json.RawMessage, error) {
+	ctx, cancel := context.WithTimeout(ctx, adminTimeout)
+	defer cancel()
+	var d net.Dialer
+	conn, err := d.DialContext(ctx, "unix", c.path)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	req, err := json.Marshal(jsonRPCRequest{JSONRPC: "2.0", ID: 1, Method: method})
+	if err != nil {
+		return nil, err
+	}
+	if _, err := conn.Write(append(req, '\n')); err != nil {
+		return nil, err
+	}
+	var resp jsonRPCResponse
+	if err := json.NewDecoder(conn).Decode(&resp); err != nil {
+		return nil, fmt.Errorf("reading response: %w", err)
+	}
+	if resp.Error != nil {
+		return nil, &RPCError{Code: resp.Error.Code, Message: resp.Error.Message}
+	}
+	return resp.Result, nil
+}
+
+// ContactInfo returns the validator's contact info.
+func (c *AdminClient) ContactInfo(ctx context.Context) (*ContactInfo, error) {
+	result, err := c.call(ctx, "contactInfo")
+	if err != nil {
+		return nil, fmt.Errorf("contactInfo: %w", err)
+	}
+	var info ContactInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("parsing contactInfo result: %w", err)
+	}
+	return &info, nil
+}
+
+// GetIdentity returns the identity pubkey the validator currently runs
+// with, which follows set-identity.
+func (c *AdminClient) GetIdentity(ctx context.Context) (string, error) {
+	info, err := c.ContactInfo(ctx)
+	if err != nil {
+		return "", err
+	}
+	if info.ID == "" {
+		return "", fmt.Errorf("contactInfo returned no identity")
+	}
+	logger().Debug("got identity over admin RPC", "pubkey", info.ID)
+	return info.ID, nil
+}
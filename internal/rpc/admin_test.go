@@ -0,0 +1,76 @@
+package rpc
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"path/filepath"
+	"testing"
+)
+
+// newAdminSocket serves responses by method on a unix socket, like Agave's
+// admin RPC.
+func newAdminSocket(t *testing.T, responses map[string]any) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "admin.rpc")
+	ln, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("unix sockets unavailable: %v", err)
+	}
+	t.Cleanup(func() { ln.Close() })
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			var req jsonRPCRequest
+			if err := json.NewDecoder(conn).Decode(&req); err == nil {
+				resp := map[string]any{"jsonrpc": "2.0", "id": req.ID}
+				if result, ok := responses[req.Method]; ok {
+					resp["result"] = result
+				} else {
+					resp["error"] = map[string]any{"code": -32601, "message": "Method not found"}
+				}
+				json.NewEncoder(conn).Encode(resp)
+			}
+			conn.Close()
+		}
+	}()
+	return path
+}
+
+func TestAdminClient_ContactInfo(t *testing.T) {
+	path := newAdminSocket(t, map[string]any{
+		"contactInfo": map[string]any{"id": "TestValidatorPubkey123", "gossip": "10.0.0.1:8001", "rpc": "0.0.0.0:0", "shred_version": 50093},
+	})
+
+	client := NewAdminClient(path)
+	info, err := client.ContactInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.ID != "TestValidatorPubkey123" || info.Gossip != "10.0.0.1:8001" || info.ShredVersion != 50093 {
+		t.Errorf("unexpected contact info: %+v", info)
+	}
+	identity, err := client.GetIdentity(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if identity != "TestValidatorPubkey123" {
+		t.Errorf("expected TestValidatorPubkey123, got %s", identity)
+	}
+}
+
+func TestAdminClient_Errors(t *testing.T) {
+	var rpcErr *RPCError
+	_, err := NewAdminClient(newAdminSocket(t, nil)).GetIdentity(context.Background())
+	if !errors.As(err, &rpcErr) {
+		t.Errorf("expected an RPC error, got %v", err)
+	}
+
+	if _, err := NewAdminClient(filepath.Join(t.TempDir(), "missing.rpc")).GetIdentity(context.Background()); err == nil {
+		t.Error("expected an error for a missing socket")
+	}
+}
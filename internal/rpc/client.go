@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
@@ -15,15 +16,45 @@ import (
 func logger() *log.Logger { return log.Default().WithPrefix("rpc") }
 
 type Client struct {
-	url        string
+	endpoints  []*endpoint
+	headers    http.Header
 	httpClient *http.Client
+	retry      RetryPolicy
+}
+
+// Options configures the HTTP client used for RPC calls.
+type Options struct {
+	Transport http.RoundTripper // nil uses http.DefaultTransport
+	Headers   http.Header       // added to every request, e.g. Authorization
+	// FallbackURLs are tried in order when the primary URL fails
+	FallbackURLs []string
+	// FailoverCooldown is how long a failed endpoint is skipped before it is
+	// tried again (default 30s)
+	FailoverCooldown time.Duration
+	// Retry retries calls that failed transiently on every endpoint
+	Retry RetryPolicy
 }
 
 func NewClient(url string) *Client {
+	return NewClientWithOptions(url, Options{})
+}
+
+func NewClientWithOptions(url string, opts Options) *Client {
+	cooldown := opts.FailoverCooldown
+	if cooldown <= 0 {
+		cooldown = defaultFailoverCooldown
+	}
+	var endpoints []*endpoint
+	for _, u := range append([]string{url}, opts.FallbackURLs...) {
+		endpoints = append(endpoints, &endpoint{url: u, cooldown: cooldown})
+	}
 	return &Client{
-		url: url,
+		endpoints: endpoints,
+		headers:   opts.Headers,
+		retry:     opts.Retry,
 		httpClient: &http.Client{
-			Timeout: 30 * time.Second,
+			Timeout:   30 * time.Second,
+			Transport: opts.Transport,
 		},
 	}
 }
@@ -47,6 +78,16 @@ type jsonRPCError struct {
 	Message string `json:"message"`
 }
 
+// RPCError is a JSON-RPC error returned by a node that was reachable.
+type RPCError struct {
+	Code    int
+	Message string
+}
+
+func (e *RPCError) Error() string {
+	return fmt.Sprintf("RPC error %d: %s", e.Code, e.Message)
+}
+
 func (c *Client) call(ctx context.Context, method string, params []any) (json.RawMessage, error) {
 	req := jsonRPCRequest{
 		JSONRPC: "2.0",
@@ -60,10 +101,60 @@ func (c *Client) call(ctx context.Context, method string, params []any) (json.Ra
 		return nil, fmt.Errorf("marshalling request: %w", err)
 	}
 
-	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, c.url, bytes.NewReader(body))
+	for attempt := 1; ; attempt++ {
+		result, err := c.callEndpoints(ctx, method, body)
+		if err == nil {
+			return result, nil
+		}
+		delay, ok := c.retry.retryDelay(attempt, err)
+		if !ok || ctx.Err() != nil {
+			return nil, err
+		}
+		logger().Warn(fmt.Sprintf("RPC call failed, retrying in %s", delay.Round(time.Millisecond)),
+			"method", method, "attempt", attempt, "max_attempts", c.retry.Attempts, "error", err)
+		if err := sleepContext(ctx, delay); err != nil {
+			return nil, err
+		}
+	}
+}
+
+// callEndpoints sends one request, failing over through the endpoints.
+func (c *Client) callEndpoints(ctx context.Context, method string, body []byte) (json.RawMessage, error) {
+	var lastErr error
+	for i, ep := range c.orderedEndpoints() {
+		if i > 0 {
+			logger().Warn("failing over to next RPC endpoint", "method", method, "url", ep.url, "previous_error", lastErr)
+		}
+
+		result, err := c.callEndpoint(ctx, ep.url, body)
+		if err == nil {
+			ep.markHealthy()
+			return result, nil
+		}
+
+		var rpcErr *RPCError
+		if errors.As(err, &rpcErr) || ctx.Err() != nil {
+			// The endpoint answered (or we gave up) - another endpoint won't help
+			return nil, err
+		}
+		ep.markFailed()
+		lastErr = err
+		if len(c.endpoints) > 1 {
+			lastErr = fmt.Errorf("%s: %w", ep.url, err)
+		}
+	}
+	return nil, lastErr
+}
+
+// callEndpoint sends one JSON-RPC request to url.
+func (c *Client) callEndpoint(ctx context.Context, url string, body []byte) (json.RawMessage, error) {
+	httpReq, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
 	if err != nil {
 		return nil, fmt.Errorf("creating request: %w", err)
 	}
+	for name, values := range c.headers {
+		httpReq.Header[name] = values
+	}
 	httpReq.Header.Set("Content-Type", "application/json")
 
 	resp, err := c.httpClient.Do(httpReq)
@@ -78,7 +169,7 @@ func (c *Client) call(ctx context.Context, method string, params []any) (json.Ra
 	}
 
 	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status %d: %s", resp.StatusCode, string(respBody))
+		return nil, newStatusError(resp, respBody)
 	}
 
 	var rpcResp jsonRPCResponse
@@ -87,7 +178,7 @@ func (c *Client) call(ctx context.Context, method string, params []any) (json.Ra
 	}
 
 	if rpcResp.Error != nil {
-		return nil, fmt.Errorf("RPC error %d: %s", rpcResp.Error.Code, rpcResp.Error.Message)
+		return nil, &RPCError{Code: rpcResp.Error.Code, Message: rpcResp.Error.Message}
 	}
 
 	return rpcResp.Result, nil
@@ -150,3 +241,161 @@ func (c *Client) GetClusterNodes(ctx context.Context) ([]ClusterNode, error) {
 	logger().Debug("got cluster nodes", "count", len(nodes))
 	return nodes, nil
 }
+
+// GetHealth returns nil if the node reports itself healthy. An unhealthy node
+// (e.g. one that is behind) answers with an *RPCError.
+func (c *Client) GetHealth(ctx context.Context) error {
+	result, err := c.call(ctx, "getHealth", nil)
+	if err != nil {
+		return fmt.Errorf("getHealth: %w", err)
+	}
+
+	var status string
+	if err := json.Unmarshal(result, &status); err != nil {
+		return fmt.Errorf("parsing getHealth result: %w", err)
+	}
+	if status != "ok" {
+		return fmt.Errorf("getHealth: node reported %q", status)
+	}
+	return nil
+}
+
+// GetVersion returns the node's solana-core version, e.g. "2.1.14".
+func (c *Client) GetVersion(ctx context.Context) (string, error) {
+	result, err := c.call(ctx, "getVersion", nil)
+	if err != nil {
+		return "", fmt.Errorf("getVersion: %w", err)
+	}
+
+	var version struct {
+		SolanaCore string `json:"solana-core"`
+	}
+	if err := json.Unmarshal(result, &version); err != nil {
+		return "", fmt.Errorf("parsing getVersion result: %w", err)
+	}
+
+	logger().Debug("got version", "version", version.SolanaCore)
+	return version.SolanaCore, nil
+}
+
+// GetGenesisHash returns the hash of the cluster's genesis block, which
+// identifies the cluster the node belongs to.
+func (c *Client) GetGenesisHash(ctx context.Context) (string, error) {
+	result, err := c.call(ctx, "getGenesisHash", nil)
+	if err != nil {
+		return "", fmt.Errorf("getGenesisHash: %w", err)
+	}
+
+	var hash string
+	if err := json.Unmarshal(result, &hash); err != nil {
+		return "", fmt.Errorf("parsing getGenesisHash result: %w", err)
+	}
+	return hash, nil
+}
+
+// VoteAccount is a vote account as returned by getVoteAccounts.
+type VoteAccount struct {
+	VotePubkey     string `json:"votePubkey"`
+	NodePubkey     string `json:"nodePubkey"`
+	ActivatedStake uint64 `json:"activatedStake"` // lamports
+}
+
+// VoteAccounts holds the current and delinquent vote accounts.
+type VoteAccounts struct {
+	Current    []VoteAccount `json:"current"`
+	Delinquent []VoteAccount `json:"delinquent"`
+}
+
+// GetVoteAccounts returns the cluster's vote accounts and their stake.
+func (c *Client) GetVoteAccounts(ctx context.Context) (*VoteAccounts, error) {
+	result, err := c.call(ctx, "getVoteAccounts", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getVoteAccounts: %w", err)
+	}
+
+	var accounts VoteAccounts
+	if err := json.Unmarshal(result, &accounts); err != nil {
+		return nil, fmt.Errorf("parsing getVoteAccounts result: %w", err)
+	}
+
+	logger().Debug("got vote accounts", "current", len(accounts.Current), "delinquent", len(accounts.Delinquent))
+	return &accounts, nil
+}
+
+// EpochInfo describes the current epoch as returned by getEpochInfo.
+type EpochInfo struct {
+	AbsoluteSlot uint64 `json:"absoluteSlot"`
+	Epoch        uint64 `json:"epoch"`
+	SlotIndex    uint64 `json:"slotIndex"`
+	SlotsInEpoch uint64 `json:"slotsInEpoch"`
+}
+
+// FirstSlot returns the first slot of the epoch.
+func (e EpochInfo) FirstSlot() uint64 {
+	return e.AbsoluteSlot - e.SlotIndex
+}
+
+// SlotsRemaining returns how many slots are left before the next epoch.
+func (e EpochInfo) SlotsRemaining() uint64 {
+	return e.SlotsInEpoch - e.SlotIndex
+}
+
+// GetEpochInfo returns information about the current epoch.
+func (c *Client) GetEpochInfo(ctx context.Context) (*EpochInfo, error) {
+	result, err := c.call(ctx, "getEpochInfo", nil)
+	if err != nil {
+		return nil, fmt.Errorf("getEpochInfo: %w", err)
+	}
+
+	var info EpochInfo
+	if err := json.Unmarshal(result, &info); err != nil {
+		return nil, fmt.Errorf("parsing getEpochInfo result: %w", err)
+	}
+
+	logger().Debug("got epoch info", "epoch", info.Epoch, "slot_index", info.SlotIndex, "slots_in_epoch", info.SlotsInEpoch)
+	return &info, nil
+}
+
+// PerformanceSample is how many slots the cluster advanced over a sample
+// period, as returned by getRecentPerformanceSamples.
+type PerformanceSample struct {
+	Slot             uint64 `json:"slot"`
+	NumSlots         uint64 `json:"numSlots"`
+	SamplePeriodSecs uint64 `json:"samplePeriodSecs"`
+}
+
+// GetRecentPerformanceSamples returns up to limit of the cluster's most
+// recent performance samples, newest first. Nodes take one a minute.
+func (c *Client) GetRecentPerformanceSamples(ctx context.Context, limit int) ([]PerformanceSample, error) {
+	result, err := c.call(ctx, "getRecentPerformanceSamples", []any{limit})
+	if err != nil {
+		return nil, fmt.Errorf("getRecentPerformanceSamples: %w", err)
+	}
+
+	var samples []PerformanceSample
+	if err := json.Unmarshal(result, &samples); err != nil {
+		return nil, fmt.Errorf("parsing getRecentPerformanceSamples result: %w", err)
+	}
+
+	logger().Debug("got performance samples", "samples", len(samples))
+	return samples, nil
+}
+
+// GetLeaderSchedule returns the current epoch's leader slots for identity, as
+// slot indices relative to the first slot of the epoch.
+func (c *Client) GetLeaderSchedule(ctx context.Context, identity string) ([]uint64, error) {
+	result, err := c.call(ctx, "getLeaderSchedule", []any{nil, map[string]string{"identity": identity}})
+	if err != nil {
+		return nil, fmt.Errorf("getLeaderSchedule: %w", err)
+	}
+
+	// null when the epoch's schedule isn't known
+	var schedule map[string][]uint64
+	if err := json.Unmarshal(result, &schedule); err != nil {
+		return nil, fmt.Errorf("parsing getLeaderSchedule result: %w", err)
+	}
+
+	slots := schedule[identity]
+	logger().Debug("got leader schedule", "identity", identity, "leader_slots", len(slots))
+	return slots, nil
+}
@@ -3,6 +3,7 @@ package rpc
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"testing"
@@ -114,6 +115,131 @@ func TestGetClusterNodes(t *testing.T) {
 	}
 }
 
+func TestGetHealth(t *testing.T) {
+	healthy := newTestServer(t, rpcHandler(t, map[string]any{"getHealth": "ok"}))
+	if err := NewClient(healthy.URL).GetHealth(context.Background()); err != nil {
+		t.Errorf("expected healthy node, got %v", err)
+	}
+
+	behind := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"jsonrpc":"2.0","id":1,"error":{"code":-32005,"message":"Node is behind by 42 slots"}}`))
+	})
+	err := NewClient(behind.URL).GetHealth(context.Background())
+	var rpcErr *RPCError
+	if !errors.As(err, &rpcErr) || rpcErr.Code != -32005 {
+		t.Errorf("expected RPC error -32005 for node that is behind, got %v", err)
+	}
+}
+
+func TestGetVersion(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getVersion": map[string]any{"solana-core": "2.1.14", "feature-set": 1234},
+	}))
+
+	version, err := NewClient(server.URL).GetVersion(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if version != "2.1.14" {
+		t.Errorf("expected 2.1.14, got %q", version)
+	}
+}
+
+func TestGetGenesisHash(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getGenesisHash": "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d",
+	}))
+
+	hash, err := NewClient(server.URL).GetGenesisHash(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hash != "5eykt4UsFv8P8NJdTREpY1vzqKqZKvdpKuc147dw2N9d" {
+		t.Errorf("unexpected genesis hash %q", hash)
+	}
+}
+
+func TestGetVoteAccounts(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getVoteAccounts": map[string]any{
+			"current": []map[string]any{
+				{"votePubkey": "Vote1", "nodePubkey": "Node1", "activatedStake": uint64(5_000_000_000_000)},
+			},
+			"delinquent": []map[string]any{
+				{"votePubkey": "Vote2", "nodePubkey": "Node2", "activatedStake": 0},
+			},
+		},
+	}))
+
+	accounts, err := NewClient(server.URL).GetVoteAccounts(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(accounts.Current) != 1 || accounts.Current[0].NodePubkey != "Node1" || accounts.Current[0].ActivatedStake != 5_000_000_000_000 {
+		t.Errorf("unexpected current vote accounts %+v", accounts.Current)
+	}
+	if len(accounts.Delinquent) != 1 {
+		t.Errorf("expected 1 delinquent vote account, got %d", len(accounts.Delinquent))
+	}
+}
+
+func TestGetEpochInfo(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getEpochInfo": map[string]any{
+			"absoluteSlot":     uint64(432_100_000),
+			"blockHeight":      uint64(410_000_000),
+			"epoch":            uint64(1000),
+			"slotIndex":        uint64(100_000),
+			"slotsInEpoch":     uint64(432_000),
+			"transactionCount": uint64(1),
+		},
+	}))
+
+	info, err := NewClient(server.URL).GetEpochInfo(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Epoch != 1000 || info.FirstSlot() != 432_000_000 || info.SlotsRemaining() != 332_000 {
+		t.Errorf("unexpected epoch info %+v", info)
+	}
+}
+
+func TestGetLeaderSchedule(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getLeaderSchedule": map[string][]uint64{"Ident1": {0, 1, 2, 3, 400, 401, 402, 403}},
+	}))
+
+	slots, err := NewClient(server.URL).GetLeaderSchedule(context.Background(), "Ident1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(slots) != 8 || slots[4] != 400 {
+		t.Errorf("unexpected leader slots %v", slots)
+	}
+
+	slots, err = NewClient(server.URL).GetLeaderSchedule(context.Background(), "Unknown")
+	if err != nil || len(slots) != 0 {
+		t.Errorf("expected no leader slots for an unscheduled identity, got %v, %v", slots, err)
+	}
+}
+
+func TestGetRecentPerformanceSamples(t *testing.T) {
+	server := newTestServer(t, rpcHandler(t, map[string]any{
+		"getRecentPerformanceSamples": []map[string]any{
+			{"slot": uint64(1150), "numSlots": uint64(150), "numTransactions": uint64(9000), "samplePeriodSecs": uint64(60)},
+			{"slot": uint64(1000), "numSlots": uint64(140), "numTransactions": uint64(8000), "samplePeriodSecs": uint64(60)},
+		},
+	}))
+
+	samples, err := NewClient(server.URL).GetRecentPerformanceSamples(context.Background(), 2)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 2 || samples[0].Slot != 1150 || samples[0].NumSlots != 150 || samples[1].SamplePeriodSecs != 60 {
+		t.Errorf("unexpected samples %+v", samples)
+	}
+}
+
 func TestGetIdentity_RPCError(t *testing.T) {
 	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
 		resp := `{"jsonrpc":"2.0","id":1,"error":{"code":-32600,"message":"invalid request"}}`
@@ -134,3 +260,25 @@ func TestGetIdentity_ConnectionError(t *testing.T) {
 		t.Error("expected error for connection failure")
 	}
 }
+
+func TestClient_SendsHeaders(t *testing.T) {
+	handler := rpcHandler(t, map[string]any{"getSlot": 1})
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		handler(w, r)
+	})
+
+	if _, err := NewClient(server.URL).GetSlot(context.Background()); err == nil {
+		t.Error("expected anonymous request to be rejected")
+	}
+
+	client := NewClientWithOptions(server.URL, Options{
+		Headers: http.Header{"Authorization": []string{"Bearer secret"}},
+	})
+	if _, err := client.GetSlot(context.Background()); err != nil {
+		t.Fatalf("expected authenticated request to succeed: %v", err)
+	}
+}
@@ -0,0 +1,59 @@
+package rpc
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+const defaultFailoverCooldown = 30 * time.Second
+
+// endpoint tracks the health of one RPC URL. An endpoint that fails is
+// skipped for its cooldown, then tried again.
+type endpoint struct {
+	url      string
+	cooldown time.Duration
+
+	mu        sync.Mutex
+	downUntil time.Time
+}
+
+func (e *endpoint) markFailed() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.downUntil = time.Now().Add(e.cooldown)
+}
+
+func (e *endpoint) markHealthy() {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	e.downUntil = time.Time{}
+}
+
+func (e *endpoint) healthy(now time.Time) bool {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return !now.Before(e.downUntil)
+}
+
+func (e *endpoint) recoversAt() time.Time {
+	e.mu.Lock()
+	defer e.mu.Unlock()
+	return e.downUntil
+}
+
+// orderedEndpoints returns healthy endpoints in configured order, followed by
+// endpoints still in cooldown (soonest to recover first) as a last resort.
+func (c *Client) orderedEndpoints() []*endpoint {
+	now := time.Now()
+	var healthy, down []*endpoint
+	for _, ep := range c.endpoints {
+		if ep.healthy(now) {
+			healthy = append(healthy, ep)
+		} else {
+			down = append(down, ep)
+		}
+	}
+	sort.SliceStable(down, func(i, j int) bool { return down[i].recoversAt().Before(down[j].recoversAt()) })
+	return append(healthy, down...)
+}
@@ -0,0 +1,78 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestFailover_UsesNextEndpoint(t *testing.T) {
+	var downCalls atomic.Int64
+	down := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		downCalls.Add(1)
+		http.Error(w, "overloaded", http.StatusServiceUnavailable)
+	})
+	up := newTestServer(t, rpcHandler(t, map[string]any{"getSlot": 135501350}))
+
+	client := NewClientWithOptions(down.URL, Options{FallbackURLs: []string{up.URL}, FailoverCooldown: time.Minute})
+
+	for i := 0; i < 3; i++ {
+		slot, err := client.GetSlot(context.Background())
+		if err != nil {
+			t.Fatalf("expected failover to succeed: %v", err)
+		}
+		if slot != 135501350 {
+			t.Errorf("expected 135501350, got %d", slot)
+		}
+	}
+
+	// The failed endpoint is in cooldown after the first call
+	if got := downCalls.Load(); got != 1 {
+		t.Errorf("expected failed endpoint to be tried once, got %d", got)
+	}
+}
+
+func TestFailover_RPCErrorDoesNotFailOver(t *testing.T) {
+	var backupCalls atomic.Int64
+	primary := newTestServer(t, rpcHandler(t, map[string]any{}))
+	backup := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		backupCalls.Add(1)
+	})
+
+	client := NewClientWithOptions(primary.URL, Options{FallbackURLs: []string{backup.URL}})
+	if _, err := client.GetSlot(context.Background()); err == nil {
+		t.Fatal("expected RPC error")
+	}
+	if got := backupCalls.Load(); got != 0 {
+		t.Errorf("expected no failover on JSON-RPC error, backup called %d times", got)
+	}
+}
+
+func TestFailover_AllDown(t *testing.T) {
+	client := NewClientWithOptions("http://127.0.0.1:1", Options{FallbackURLs: []string{"http://127.0.0.1:2"}})
+	if _, err := client.GetSlot(context.Background()); err == nil {
+		t.Fatal("expected error when every endpoint is down")
+	}
+
+	// Endpoints in cooldown are still tried as a last resort
+	if got := len(client.orderedEndpoints()); got != 2 {
+		t.Errorf("expected 2 endpoints, got %d", got)
+	}
+}
+
+func TestOrderedEndpoints_HealthyFirst(t *testing.T) {
+	client := NewClientWithOptions("http://a", Options{FallbackURLs: []string{"http://b", "http://c"}})
+	client.endpoints[0].markFailed()
+
+	got := client.orderedEndpoints()
+	if got[0].url != "http://b" || got[1].url != "http://c" || got[2].url != "http://a" {
+		t.Errorf("unexpected order: %s, %s, %s", got[0].url, got[1].url, got[2].url)
+	}
+
+	client.endpoints[0].markHealthy()
+	if got := client.orderedEndpoints(); got[0].url != "http://a" {
+		t.Errorf("expected recovered primary first, got %s", got[0].url)
+	}
+}
@@ -0,0 +1,130 @@
+package rpc
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"time"
+)
+
+const (
+	defaultRetryBaseDelay = 500 * time.Millisecond
+	defaultRetryMaxDelay  = 10 * time.Second
+)
+
+// RetryPolicy retries calls that failed on every endpoint with a transient
+// error: HTTP 429, 5xx, timeouts and connection errors.
+type RetryPolicy struct {
+	// Attempts is the total number of tries per call (<= 1 disables retries)
+	Attempts int
+	// BaseDelay is the backoff before the first retry, doubled for each
+	// subsequent retry and jittered (default 500ms)
+	BaseDelay time.Duration
+	// MaxDelay caps the backoff. A Retry-After longer than this ends
+	// retrying instead of stalling the cycle (default 10s)
+	MaxDelay time.Duration
+}
+
+// StatusError is a non-200 HTTP response from an RPC endpoint.
+type StatusError struct {
+	StatusCode int
+	Body       string
+	// RetryAfter is parsed from the Retry-After header (0 if absent)
+	RetryAfter time.Duration
+}
+
+func (e *StatusError) Error() string {
+	return fmt.Sprintf("unexpected status %d: %s", e.StatusCode, e.Body)
+}
+
+func newStatusError(resp *http.Response, body []byte) *StatusError {
+	return &StatusError{
+		StatusCode: resp.StatusCode,
+		Body:       string(body),
+		RetryAfter: parseRetryAfter(resp.Header.Get("Retry-After"), time.Now()),
+	}
+}
+
+// parseRetryAfter accepts delay-seconds or an HTTP date.
+func parseRetryAfter(v string, now time.Time) time.Duration {
+	if v == "" {
+		return 0
+	}
+	if secs, err := strconv.Atoi(v); err == nil {
+		if secs < 0 {
+			return 0
+		}
+		return time.Duration(secs) * time.Second
+	}
+	if t, err := http.ParseTime(v); err == nil && t.After(now) {
+		return t.Sub(now)
+	}
+	return 0
+}
+
+// isRetryable reports whether err is transient and worth retrying.
+func isRetryable(err error) bool {
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) {
+		return statusErr.StatusCode == http.StatusTooManyRequests || statusErr.StatusCode >= 500
+	}
+	var urlErr *url.Error
+	var netErr net.Error
+	return errors.As(err, &urlErr) || errors.As(err, &netErr) || errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// backoff returns the jittered delay before retry n (1-based): half the
+// exponential delay plus a random share of the other half.
+func (p RetryPolicy) backoff(n int) time.Duration {
+	base, maxDelay := p.delays()
+	d := base << (n - 1)
+	if d <= 0 || d > maxDelay {
+		d = maxDelay
+	}
+	return d/2 + rand.N(d/2+1)
+}
+
+func (p RetryPolicy) delays() (time.Duration, time.Duration) {
+	base, maxDelay := p.BaseDelay, p.MaxDelay
+	if base <= 0 {
+		base = defaultRetryBaseDelay
+	}
+	if maxDelay <= 0 {
+		maxDelay = defaultRetryMaxDelay
+	}
+	return base, maxDelay
+}
+
+// retryDelay returns how long to wait before retry n after err, and false if
+// err should not be retried.
+func (p RetryPolicy) retryDelay(n int, err error) (time.Duration, bool) {
+	if n >= p.Attempts || !isRetryable(err) {
+		return 0, false
+	}
+	d := p.backoff(n)
+	var statusErr *StatusError
+	if errors.As(err, &statusErr) && statusErr.RetryAfter > d {
+		if _, maxDelay := p.delays(); statusErr.RetryAfter > maxDelay {
+			return 0, false
+		}
+		d = statusErr.RetryAfter
+	}
+	return d, true
+}
+
+func sleepContext(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+	select {
+	case <-t.C:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
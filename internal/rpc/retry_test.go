@@ -0,0 +1,134 @@
+package rpc
+
+import (
+	"context"
+	"net/http"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestRetry_RecoversFrom429(t *testing.T) {
+	var calls atomic.Int64
+	ok := rpcHandler(t, map[string]any{"getSlot": 135501350})
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) < 3 {
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		ok(w, r)
+	})
+
+	client := NewClientWithOptions(server.URL, Options{Retry: RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: 10 * time.Millisecond}})
+	slot, err := client.GetSlot(context.Background())
+	if err != nil {
+		t.Fatalf("expected retries to succeed: %v", err)
+	}
+	if slot != 135501350 {
+		t.Errorf("expected 135501350, got %d", slot)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("expected 3 calls, got %d", got)
+	}
+}
+
+func TestRetry_GivesUpAfterAttempts(t *testing.T) {
+	var calls atomic.Int64
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "bad gateway", http.StatusBadGateway)
+	})
+
+	client := NewClientWithOptions(server.URL, Options{Retry: RetryPolicy{Attempts: 2, BaseDelay: time.Millisecond}})
+	if _, err := client.GetSlot(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("expected 2 calls, got %d", got)
+	}
+}
+
+func TestRetry_DoesNotRetryClientErrors(t *testing.T) {
+	var calls atomic.Int64
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		http.Error(w, "unauthorized", http.StatusUnauthorized)
+	})
+
+	client := NewClientWithOptions(server.URL, Options{Retry: RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond}})
+	if _, err := client.GetSlot(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected no retries on 401, got %d calls", got)
+	}
+}
+
+func TestRetry_HonorsRetryAfter(t *testing.T) {
+	var calls atomic.Int64
+	var first time.Time
+	var retryGap time.Duration
+	ok := rpcHandler(t, map[string]any{"getSlot": 1})
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			first = time.Now()
+			w.Header().Set("Retry-After", "1")
+			http.Error(w, "rate limited", http.StatusTooManyRequests)
+			return
+		}
+		retryGap = time.Since(first)
+		ok(w, r)
+	})
+
+	client := NewClientWithOptions(server.URL, Options{Retry: RetryPolicy{Attempts: 2, BaseDelay: time.Millisecond, MaxDelay: 5 * time.Second}})
+	if _, err := client.GetSlot(context.Background()); err != nil {
+		t.Fatal(err)
+	}
+	if retryGap < time.Second {
+		t.Errorf("expected retry to wait for Retry-After (1s), waited %s", retryGap)
+	}
+}
+
+func TestRetry_RetryAfterBeyondMaxDelayGivesUp(t *testing.T) {
+	var calls atomic.Int64
+	server := newTestServer(t, func(w http.ResponseWriter, r *http.Request) {
+		calls.Add(1)
+		w.Header().Set("Retry-After", "120")
+		http.Error(w, "rate limited", http.StatusTooManyRequests)
+	})
+
+	client := NewClientWithOptions(server.URL, Options{Retry: RetryPolicy{Attempts: 3, BaseDelay: time.Millisecond, MaxDelay: time.Second}})
+	if _, err := client.GetSlot(context.Background()); err == nil {
+		t.Fatal("expected error")
+	}
+	if got := calls.Load(); got != 1 {
+		t.Errorf("expected no retry when Retry-After exceeds max delay, got %d calls", got)
+	}
+}
+
+func TestRetryPolicy_Backoff(t *testing.T) {
+	p := RetryPolicy{Attempts: 10, BaseDelay: 100 * time.Millisecond, MaxDelay: time.Second}
+	for n, want := range map[int]time.Duration{1: 100 * time.Millisecond, 2: 200 * time.Millisecond, 3: 400 * time.Millisecond, 8: time.Second} {
+		for i := 0; i < 20; i++ {
+			if d := p.backoff(n); d < want/2 || d > want {
+				t.Errorf("backoff(%d) = %s, want within [%s, %s]", n, d, want/2, want)
+			}
+		}
+	}
+}
+
+func TestParseRetryAfter(t *testing.T) {
+	now := time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := map[string]time.Duration{
+		"":                              0,
+		"5":                             5 * time.Second,
+		"-1":                            0,
+		"garbage":                       0,
+		"Wed, 01 Jan 2025 00:00:30 GMT": 30 * time.Second,
+	}
+	for input, want := range tests {
+		if got := parseRetryAfter(input, now); got != want {
+			t.Errorf("parseRetryAfter(%q) = %s, want %s", input, got, want)
+		}
+	}
+}
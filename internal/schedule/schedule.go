@@ -0,0 +1,242 @@
+// Package schedule computes when interval-mode cycles run, from standard
+// 5-field cron expressions.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Schedule reports the next run time strictly after a given time.
+type Schedule interface {
+	Next(after time.Time) time.Time
+}
+
+// maxSearch bounds how far ahead Next looks; an expression like "0 0 30 2 *"
+// never matches.
+const maxSearch = 5 * 366 * 24 * time.Hour
+
+var macros = map[string]string{
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+	"@monthly":  "0 0 1 * *",
+	"@weekly":   "0 0 * * 0",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@hourly":   "0 * * * *",
+}
+
+var monthNames = map[string]int{
+	"jan": 1, "feb": 2, "mar": 3, "apr": 4, "may": 5, "jun": 6,
+	"jul": 7, "aug": 8, "sep": 9, "oct": 10, "nov": 11, "dec": 12,
+}
+
+var dayNames = map[string]int{
+	"sun": 0, "mon": 1, "tue": 2, "wed": 3, "thu": 4, "fri": 5, "sat": 6,
+}
+
+// Cron is a parsed cron expression, evaluated in the location of the times
+// passed to Next.
+type Cron struct {
+	expr                          string
+	minute, hour, dom, month, dow uint64 // bit i set = value i matches
+	domRestricted, dowRestricted  bool
+}
+
+// ParseCron parses a 5-field cron expression (minute hour day-of-month month
+// day-of-week) or one of the @hourly, @daily, @weekly, @monthly and @yearly
+// macros. Fields accept *, lists, ranges, steps and month/day names.
+func ParseCron(expr string) (*Cron, error) {
+	spec := strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+	fields := strings.Fields(spec)
+	if len(fields) != 5 {
+		return nil, fmt.Errorf("cron %q: expected 5 fields (minute hour day-of-month month day-of-week), got %d", expr, len(fields))
+	}
+
+	c := &Cron{expr: expr}
+	var err error
+	if c.minute, err = parseField(fields[0], 0, 59, nil); err != nil {
+		return nil, fmt.Errorf("cron %q: minute: %w", expr, err)
+	}
+	if c.hour, err = parseField(fields[1], 0, 23, nil); err != nil {
+		return nil, fmt.Errorf("cron %q: hour: %w", expr, err)
+	}
+	if c.dom, err = parseField(fields[2], 1, 31, nil); err != nil {
+		return nil, fmt.Errorf("cron %q: day-of-month: %w", expr, err)
+	}
+	if c.month, err = parseField(fields[3], 1, 12, monthNames); err != nil {
+		return nil, fmt.Errorf("cron %q: month: %w", expr, err)
+	}
+	if c.dow, err = parseField(fields[4], 0, 7, dayNames); err != nil {
+		return nil, fmt.Errorf("cron %q: day-of-week: %w", expr, err)
+	}
+	// 7 is Sunday too
+	if c.dow&(1<<7) != 0 {
+		c.dow |= 1
+	}
+	// As in Vixie cron, a day field starting with * (including */n) doesn't
+	// count as restricted
+	c.domRestricted = !strings.HasPrefix(fields[2], "*") && fields[2] != "?"
+	c.dowRestricted = !strings.HasPrefix(fields[4], "*") && fields[4] != "?"
+	return c, nil
+}
+
+func (c *Cron) String() string { return c.expr }
+
+// Next returns the first matching minute after after.
+func (c *Cron) Next(after time.Time) time.Time {
+	loc := after.Location()
+	t := after.Truncate(time.Minute).Add(time.Minute)
+	limit := after.Add(maxSearch)
+
+	for t.Before(limit) {
+		if !has(c.month, int(t.Month())) {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !c.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, loc)
+			continue
+		}
+		if !has(c.hour, t.Hour()) {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, loc)
+			continue
+		}
+		if !has(c.minute, t.Minute()) {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// dayMatches applies cron's rule that when both day fields are restricted a
+// day matching either one runs.
+func (c *Cron) dayMatches(t time.Time) bool {
+	dom := has(c.dom, t.Day())
+	dow := has(c.dow, int(t.Weekday()))
+	if c.domRestricted && c.dowRestricted {
+		return dom || dow
+	}
+	return dom && dow
+}
+
+// Any runs at the earliest next time of any of its schedules, e.g. one
+// cadence on weekdays and another at weekends.
+type Any []Schedule
+
+func (a Any) Next(after time.Time) time.Time {
+	var next time.Time
+	for _, s := range a {
+		t := s.Next(after)
+		if !t.IsZero() && (next.IsZero() || t.Before(next)) {
+			next = t
+		}
+	}
+	return next
+}
+
+func (a Any) String() string {
+	parts := make([]string, len(a))
+	for i, s := range a {
+		parts[i] = fmt.Sprint(s)
+	}
+	return strings.Join(parts, "; ")
+}
+
+// ParseCrons parses each expression and combines them with Any.
+func ParseCrons(exprs []string) (Any, error) {
+	if len(exprs) == 0 {
+		return nil, fmt.Errorf("no cron expressions")
+	}
+	var a Any
+	for _, expr := range exprs {
+		c, err := ParseCron(expr)
+		if err != nil {
+			return nil, err
+		}
+		a = append(a, c)
+	}
+	return a, nil
+}
+
+func has(set uint64, v int) bool { return set&(1<<uint(v)) != 0 }
+
+func parseField(field string, lo, hi int, names map[string]int) (uint64, error) {
+	var set uint64
+	for _, part := range strings.Split(field, ",") {
+		bitsSet, err := parsePart(part, lo, hi, names)
+		if err != nil {
+			return 0, err
+		}
+		set |= bitsSet
+	}
+	if set == 0 {
+		return 0, fmt.Errorf("%q matches nothing", field)
+	}
+	return set, nil
+}
+
+func parsePart(part string, lo, hi int, names map[string]int) (uint64, error) {
+	rangePart, stepPart, hasStep := strings.Cut(part, "/")
+	step := 1
+	if hasStep {
+		n, err := strconv.Atoi(stepPart)
+		if err != nil || n < 1 {
+			return 0, fmt.Errorf("invalid step %q", stepPart)
+		}
+		step = n
+	}
+
+	start, end := lo, hi
+	switch {
+	case rangePart == "*" || rangePart == "?":
+	case strings.Contains(rangePart, "-"):
+		a, b, _ := strings.Cut(rangePart, "-")
+		var err error
+		if start, err = parseValue(a, lo, hi, names); err != nil {
+			return 0, err
+		}
+		if end, err = parseValue(b, lo, hi, names); err != nil {
+			return 0, err
+		}
+		if start > end {
+			return 0, fmt.Errorf("invalid range %q", rangePart)
+		}
+	default:
+		v, err := parseValue(rangePart, lo, hi, names)
+		if err != nil {
+			return 0, err
+		}
+		start = v
+		if !hasStep {
+			end = v
+		}
+	}
+
+	var set uint64
+	for v := start; v <= end; v += step {
+		set |= 1 << uint(v)
+	}
+	return set, nil
+}
+
+func parseValue(s string, lo, hi int, names map[string]int) (int, error) {
+	if v, ok := names[strings.ToLower(s)]; ok {
+		return v, nil
+	}
+	v, err := strconv.Atoi(s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid value %q", s)
+	}
+	if v < lo || v > hi {
+		return 0, fmt.Errorf("value %d out of range %d-%d", v, lo, hi)
+	}
+	return v, nil
+}
@@ -0,0 +1,86 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCron_Next(t *testing.T) {
+	// 2025-01-01 is a Wednesday
+	base := time.Date(2025, 1, 1, 10, 17, 30, 0, time.UTC)
+	tests := []struct {
+		expr string
+		want time.Time
+	}{
+		{"*/15 * * * *", time.Date(2025, 1, 1, 10, 30, 0, 0, time.UTC)},
+		{"5 */4 * * *", time.Date(2025, 1, 1, 12, 5, 0, 0, time.UTC)},
+		{"0 3 * * *", time.Date(2025, 1, 2, 3, 0, 0, 0, time.UTC)},
+		{"17 10 * * *", time.Date(2025, 1, 2, 10, 17, 0, 0, time.UTC)},
+		{"0 0 * * sat,sun", time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC)},
+		{"0 0 * * 7", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+		{"0 12 * * MON-FRI", time.Date(2025, 1, 1, 12, 0, 0, 0, time.UTC)},
+		{"0 0 1 mar *", time.Date(2025, 3, 1, 0, 0, 0, 0, time.UTC)},
+		{"0 0 29 2 *", time.Date(2028, 2, 29, 0, 0, 0, 0, time.UTC)},
+		// Both day fields restricted: either matching runs
+		{"0 0 15 * fri", time.Date(2025, 1, 3, 0, 0, 0, 0, time.UTC)},
+		{"@hourly", time.Date(2025, 1, 1, 11, 0, 0, 0, time.UTC)},
+		{"@weekly", time.Date(2025, 1, 5, 0, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		t.Run(tt.expr, func(t *testing.T) {
+			c, err := ParseCron(tt.expr)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := c.Next(base); !got.Equal(tt.want) {
+				t.Errorf("Next() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestCron_NextNeverMatches(t *testing.T) {
+	c, err := ParseCron("0 0 30 2 *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := c.Next(time.Date(2025, 1, 1, 0, 0, 0, 0, time.UTC)); !got.IsZero() {
+		t.Errorf("expected no next time, got %v", got)
+	}
+}
+
+func TestParseCron_Invalid(t *testing.T) {
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"x * * * *",
+		"@often",
+	} {
+		if _, err := ParseCron(expr); err == nil {
+			t.Errorf("ParseCron(%q): expected error", expr)
+		}
+	}
+}
+
+func TestAny_Next(t *testing.T) {
+	// Every 4h on weekdays, every 12h at weekends
+	a, err := ParseCrons([]string{"0 */4 * * 1-5", "0 */12 * * 0,6"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	friday := time.Date(2025, 1, 3, 21, 0, 0, 0, time.UTC)
+	if got, want := a.Next(friday), time.Date(2025, 1, 4, 0, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+	saturday := time.Date(2025, 1, 4, 1, 0, 0, 0, time.UTC)
+	if got, want := a.Next(saturday), time.Date(2025, 1, 4, 12, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Next() = %v, want %v", got, want)
+	}
+}
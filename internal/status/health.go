@@ -0,0 +1,95 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+// Health probe paths, for Kubernetes and load balancers.
+const (
+	// LivePath answers 200 while the process serves requests
+	LivePath = "/healthz"
+	// ReadyPath answers 200 while the last cycle didn't fail and the local
+	// snapshots are fresh, 503 otherwise
+	ReadyPath = "/readyz"
+)
+
+// freshnessTimeout bounds the cluster slot lookup of a readiness check.
+const freshnessTimeout = 5 * time.Second
+
+// Health is the document served at LivePath and ReadyPath.
+type Health struct {
+	Status       string     `json:"status"` // "ok" or "unavailable"
+	Reasons      []string   `json:"reasons,omitempty"`
+	StartedAt    time.Time  `json:"started_at"`
+	LastActivity *time.Time `json:"last_activity,omitempty"`
+	LastCycleAt  *time.Time `json:"last_cycle_at,omitempty"`
+	LastResult   string     `json:"last_result,omitempty"`
+	// SlotsBehind is how far the newest local snapshot trails the cluster,
+	// omitted if the cluster's slot couldn't be read
+	SlotsBehind    *uint64 `json:"slots_behind,omitempty"`
+	MaxSlotsBehind uint64  `json:"max_slots_behind,omitempty"`
+}
+
+// CheckLive reports process liveness and the last cycle, without judging it.
+func CheckLive(opts Options) Health {
+	h := Health{Status: "ok", StartedAt: opts.StartedAt.UTC()}
+	if opts.LastActivity != nil {
+		if at := opts.LastActivity(); !at.IsZero() {
+			at = at.UTC()
+			h.LastActivity = &at
+		}
+	}
+	if opts.LastDecision != nil {
+		if d, ok := opts.LastDecision(); ok {
+			h.LastCycleAt = &d.At
+			h.LastResult = d.Result
+		}
+	}
+	return h
+}
+
+// CheckReady is CheckLive plus the readiness verdict: a cycle has run and not
+// failed, and the newest local snapshot is within MaxSlotsBehind.
+func CheckReady(ctx context.Context, opts Options) Health {
+	h := CheckLive(opts)
+	switch {
+	case h.LastCycleAt == nil:
+		h.Reasons = append(h.Reasons, "no cycle has completed yet")
+	case h.LastResult == "failure":
+		h.Reasons = append(h.Reasons, "last cycle failed")
+	}
+	if opts.SlotsBehind != nil && opts.MaxSlotsBehind != nil {
+		h.MaxSlotsBehind = opts.MaxSlotsBehind()
+		ctx, cancel := context.WithTimeout(ctx, freshnessTimeout)
+		defer cancel()
+		behind, err := opts.SlotsBehind(ctx)
+		switch {
+		case err != nil:
+			h.Reasons = append(h.Reasons, fmt.Sprintf("snapshot freshness unknown: %v", err))
+		case behind > h.MaxSlotsBehind:
+			h.SlotsBehind = &behind
+			h.Reasons = append(h.Reasons, fmt.Sprintf("newest local snapshot is %d slots behind, over %d", behind, h.MaxSlotsBehind))
+		default:
+			h.SlotsBehind = &behind
+		}
+	}
+	if len(h.Reasons) > 0 {
+		h.Status = "unavailable"
+	}
+	return h
+}
+
+func writeHealth(w http.ResponseWriter, h Health) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if h.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	if err := json.NewEncoder(w).Encode(h); err != nil {
+		logger().Error("failed to write health", "error", err)
+	}
+}
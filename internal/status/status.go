@@ -0,0 +1,105 @@
+// Package status serves the keeper's effective policy and last decision over
+// HTTP so fleet tooling can audit what each host is running, along with
+// liveness and readiness probes.
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+
+	"github.com/charmbracelet/log"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/report"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("status") }
+
+// Path is where the status document is served.
+const Path = "/status"
+
+// Options describes what the status document reports.
+type Options struct {
+	Config    *config.Config
+	Version   string
+	StartedAt time.Time
+	// LastDecision returns the most recent cycle's decision, if any
+	LastDecision func() (keeper.Decision, bool)
+	// LastActivity returns when the keeper last made progress
+	LastActivity func() time.Time
+	// SlotsBehind and MaxSlotsBehind judge snapshot freshness for ReadyPath;
+	// readiness ignores freshness when either is nil
+	SlotsBehind    func(ctx context.Context) (uint64, error)
+	MaxSlotsBehind func() uint64
+}
+
+// Status is the document served at Path.
+type Status struct {
+	Version      string           `json:"version"`
+	StartedAt    time.Time        `json:"started_at"`
+	Cluster      string           `json:"cluster"`
+	ConfigFile   string           `json:"config_file"`
+	Features     map[string]bool  `json:"features"`
+	Config       map[string]any   `json:"config"` // effective config, secrets redacted
+	LastDecision *keeper.Decision `json:"last_decision"`
+}
+
+// Build assembles the current status document.
+func Build(opts Options) Status {
+	s := Status{
+		Version:    opts.Version,
+		StartedAt:  opts.StartedAt.UTC(),
+		Cluster:    opts.Config.Cluster.Name,
+		ConfigFile: opts.Config.File,
+		Features:   opts.Config.Features(),
+		Config:     report.Redact(opts.Config.Effective),
+	}
+	if opts.LastDecision != nil {
+		if d, ok := opts.LastDecision(); ok {
+			s.LastDecision = &d
+		}
+	}
+	return s
+}
+
+// Handler serves the status document and health probes as JSON.
+func Handler(opts Options) http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET "+Path, func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(Build(opts)); err != nil {
+			logger().Error("failed to write status", "error", err)
+		}
+	})
+	mux.HandleFunc("GET "+LivePath, func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, CheckLive(opts))
+	})
+	mux.HandleFunc("GET "+ReadyPath, func(w http.ResponseWriter, r *http.Request) {
+		writeHealth(w, CheckReady(r.Context(), opts))
+	})
+	return mux
+}
+
+// Serve listens on addr and serves the status document in the background.
+// Listen errors are returned; the caller closes the server when done.
+func Serve(addr string, opts Options) (*http.Server, error) {
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+	srv := &http.Server{Handler: Handler(opts), ReadHeaderTimeout: 10 * time.Second}
+	go func() {
+		if err := srv.Serve(ln); err != nil && !errors.Is(err, http.ErrServerClosed) {
+			logger().Error("status server stopped", "error", err)
+		}
+	}()
+	logger().Info("serving status", "address", ln.Addr().String(), "path", Path)
+	return srv, nil
+}
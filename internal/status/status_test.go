@@ -0,0 +1,128 @@
+package status
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+)
+
+func TestHandler(t *testing.T) {
+	cfg := &config.Config{
+		Cluster: config.Cluster{Name: "testnet"},
+		Effective: map[string]any{
+			"cluster": map[string]any{"name": "testnet"},
+			"hooks": map[string]any{
+				"on_success": []any{map[string]any{"name": "notify", "environment": map[string]any{"TOKEN": "s3cret"}}},
+			},
+		},
+	}
+	cfg.Snapshots.Download.Delta = true
+
+	decision := keeper.Decision{At: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Result: "skipped", Reason: "validator is active"}
+	h := Handler(Options{
+		Config:       cfg,
+		Version:      "v1.2.3",
+		LastDecision: func() (keeper.Decision, bool) { return decision, true },
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected 200, got %d", rec.Code)
+	}
+	if body := rec.Body.String(); strings.Contains(body, "s3cret") {
+		t.Errorf("expected hook environment to be redacted, got %s", body)
+	}
+
+	var got Status
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got.Version != "v1.2.3" || got.Cluster != "testnet" {
+		t.Errorf("unexpected version/cluster: %q %q", got.Version, got.Cluster)
+	}
+	if !got.Features["snapshots.download.delta"] || got.Features["snapshots.discovery.stream"] {
+		t.Errorf("unexpected features: %v", got.Features)
+	}
+	if got.LastDecision == nil || got.LastDecision.Reason != decision.Reason {
+		t.Errorf("expected last decision %+v, got %+v", decision, got.LastDecision)
+	}
+}
+
+func TestHandler_NoDecisionYet(t *testing.T) {
+	h := Handler(Options{
+		Config:       &config.Config{},
+		LastDecision: func() (keeper.Decision, bool) { return keeper.Decision{}, false },
+	})
+
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, Path, nil))
+
+	var got map[string]any
+	if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+		t.Fatal(err)
+	}
+	if got["last_decision"] != nil {
+		t.Errorf("expected null last_decision, got %v", got["last_decision"])
+	}
+}
+
+func TestHealthProbes(t *testing.T) {
+	failed := keeper.Decision{At: time.Date(2026, 1, 2, 3, 4, 5, 0, time.UTC), Result: "failure"}
+	succeeded := keeper.Decision{At: failed.At, Result: "success"}
+	tests := []struct {
+		name      string
+		decision  *keeper.Decision
+		behind    uint64
+		behindErr error
+		wantReady int
+	}{
+		{"no cycle yet", nil, 100, nil, http.StatusServiceUnavailable},
+		{"fresh after success", &succeeded, 100, nil, http.StatusOK},
+		{"last cycle failed", &failed, 100, nil, http.StatusServiceUnavailable},
+		{"stale", &succeeded, 5000, nil, http.StatusServiceUnavailable},
+		{"cluster unreachable", &succeeded, 0, errors.New("connection refused"), http.StatusServiceUnavailable},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			h := Handler(Options{
+				Config: &config.Config{},
+				LastDecision: func() (keeper.Decision, bool) {
+					if tt.decision == nil {
+						return keeper.Decision{}, false
+					}
+					return *tt.decision, true
+				},
+				SlotsBehind:    func(context.Context) (uint64, error) { return tt.behind, tt.behindErr },
+				MaxSlotsBehind: func() uint64 { return 1300 },
+			})
+
+			rec := httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, LivePath, nil))
+			if rec.Code != http.StatusOK {
+				t.Errorf("expected %s to answer 200 while serving, got %d", LivePath, rec.Code)
+			}
+
+			rec = httptest.NewRecorder()
+			h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ReadyPath, nil))
+			if rec.Code != tt.wantReady {
+				t.Errorf("expected %s to answer %d, got %d: %s", ReadyPath, tt.wantReady, rec.Code, rec.Body)
+			}
+			var got Health
+			if err := json.Unmarshal(rec.Body.Bytes(), &got); err != nil {
+				t.Fatal(err)
+			}
+			if ready := got.Status == "ok"; ready != (tt.wantReady == http.StatusOK) || ready != (len(got.Reasons) == 0) {
+				t.Errorf("unexpected readiness document: %+v", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,170 @@
+package verify
+
+import (
+	"archive/tar"
+	"context"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"time"
+)
+
+// stagingDirName is where Unpack extracts to before moving entries into place.
+const stagingDirName = ".unpacking"
+
+// UnpackOptions says where an archive is unpacked.
+type UnpackOptions struct {
+	// LedgerDir receives everything but the account storage files: the
+	// version file and the bank snapshot under snapshots/
+	LedgerDir string
+	// AccountsDir receives the account storage files from accounts/
+	AccountsDir string
+}
+
+// UnpackResult describes an unpacked archive.
+type UnpackResult struct {
+	Files    int
+	Bytes    int64
+	Duration time.Duration
+}
+
+// Unpack extracts the archive at path, account storage files into
+// opts.AccountsDir and everything else into opts.LedgerDir. Entries are
+// extracted to a staging directory inside each and fsynced, and only moved
+// into place, replacing entries of the same name, once the whole archive has
+// been written, so an interrupted unpack never leaves a half-written ledger.
+func Unpack(ctx context.Context, path string, opts UnpackOptions) (*UnpackResult, error) {
+	start := time.Now()
+	ledgerStage := filepath.Join(opts.LedgerDir, stagingDirName)
+	accountsStage := filepath.Join(opts.AccountsDir, stagingDirName)
+	for _, dir := range []string{ledgerStage, accountsStage} {
+		if err := os.RemoveAll(dir); err != nil {
+			return nil, fmt.Errorf("clearing staging directory: %w", err)
+		}
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			return nil, fmt.Errorf("creating staging directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+	}
+
+	stream, err := decompress(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	result := &UnpackResult{}
+	dirs := map[string]bool{ledgerStage: true, accountsStage: true}
+	tr := tar.NewReader(&contextReader{ctx: ctx, r: stream})
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading archive after %d files: %w", result.Files, err)
+		}
+		if hdr.Typeflag != tar.TypeReg {
+			continue
+		}
+		name := strings.TrimPrefix(hdr.Name, "./")
+		root := ledgerStage
+		if rest, ok := strings.CutPrefix(name, "accounts/"); ok {
+			root, name = accountsStage, rest
+		}
+
+		n, err := extractFileSynced(root, name, tr, dirs)
+		result.Bytes += n
+		if err != nil {
+			return nil, fmt.Errorf("unpacking %s: %w", name, err)
+		}
+		result.Files++
+	}
+	io.Copy(io.Discard, stream)
+	if err := stream.Wait(); err != nil {
+		return nil, fmt.Errorf("decompressing: %w", err)
+	}
+
+	for dir := range dirs {
+		if err := syncDir(dir); err != nil {
+			return nil, fmt.Errorf("syncing %s: %w", dir, err)
+		}
+	}
+	if err := moveIntoPlace(ledgerStage, opts.LedgerDir); err != nil {
+		return nil, err
+	}
+	if err := moveIntoPlace(accountsStage, opts.AccountsDir); err != nil {
+		return nil, err
+	}
+
+	result.Duration = time.Since(start)
+	logger().Info(fmt.Sprintf("unpacked %d files (%d bytes) in %s", result.Files, result.Bytes, result.Duration.Round(time.Second)),
+		"path", path, "ledger", opts.LedgerDir, "accounts", opts.AccountsDir)
+	return result, nil
+}
+
+// extractFileSynced writes one regular file under root and fsyncs it,
+// recording the directories it created in dirs.
+func extractFileSynced(root, name string, r io.Reader, dirs map[string]bool) (int64, error) {
+	if !filepath.IsLocal(name) {
+		return 0, fmt.Errorf("unsafe path in archive")
+	}
+	dest := filepath.Join(root, name)
+	for dir := filepath.Dir(dest); !dirs[dir]; dir = filepath.Dir(dir) {
+		dirs[dir] = true
+	}
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if err == nil {
+		err = f.Sync()
+	}
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// moveIntoPlace moves each top-level entry of stage into dest, replacing
+// entries of the same name.
+func moveIntoPlace(stage, dest string) error {
+	entries, err := os.ReadDir(stage)
+	if err != nil {
+		return fmt.Errorf("reading staging directory: %w", err)
+	}
+	for _, e := range entries {
+		target := filepath.Join(dest, e.Name())
+		if err := os.RemoveAll(target); err != nil {
+			return fmt.Errorf("replacing %s: %w", target, err)
+		}
+		if err := os.Rename(filepath.Join(stage, e.Name()), target); err != nil {
+			return fmt.Errorf("moving %s into place: %w", e.Name(), err)
+		}
+	}
+	if err := syncDir(dest); err != nil {
+		return fmt.Errorf("syncing %s: %w", dest, err)
+	}
+	return nil
+}
+
+// syncDir fsyncs a directory so the entries created in it are durable.
+// Windows can't sync directories; NTFS journals the metadata instead.
+func syncDir(dir string) error {
+	if runtime.GOOS == "windows" {
+		return nil
+	}
+	d, err := os.Open(dir)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+	return d.Sync()
+}
@@ -0,0 +1,67 @@
+package verify
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestUnpack_Layout(t *testing.T) {
+	path := writeTarGz(t, t.TempDir(), "snapshot-100-Hash.tar.gz", snapshotEntries("100"))
+	ledger := t.TempDir()
+	accounts := filepath.Join(t.TempDir(), "run")
+
+	// A previous bank snapshot is replaced; unrelated ledger files are kept
+	os.MkdirAll(filepath.Join(ledger, "snapshots", "50"), 0755)
+	os.WriteFile(filepath.Join(ledger, "rocksdb"), []byte("keep"), 0644)
+
+	result, err := Unpack(context.Background(), path, UnpackOptions{LedgerDir: ledger, AccountsDir: accounts})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Files != 5 {
+		t.Errorf("expected 5 files unpacked, got %d", result.Files)
+	}
+
+	for _, want := range []string{
+		filepath.Join(ledger, "version"),
+		filepath.Join(ledger, "snapshots", "status_cache"),
+		filepath.Join(ledger, "snapshots", "100", "100"),
+		filepath.Join(ledger, "rocksdb"),
+		filepath.Join(accounts, "100.1"),
+		filepath.Join(accounts, "100.2"),
+	} {
+		if _, err := os.Stat(want); err != nil {
+			t.Errorf("expected %s: %v", want, err)
+		}
+	}
+	for _, gone := range []string{
+		filepath.Join(ledger, "snapshots", "50"),
+		filepath.Join(ledger, "accounts"),
+		filepath.Join(ledger, stagingDirName),
+		filepath.Join(accounts, stagingDirName),
+	} {
+		if _, err := os.Stat(gone); !os.IsNotExist(err) {
+			t.Errorf("expected %s not to exist", gone)
+		}
+	}
+}
+
+func TestUnpack_CorruptArchiveLeavesLedgerAlone(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "snapshot-100-Hash.tar.gz")
+	os.WriteFile(path, []byte("not gzip"), 0644)
+	ledger := t.TempDir()
+	os.MkdirAll(filepath.Join(ledger, "snapshots", "50"), 0755)
+
+	if _, err := Unpack(context.Background(), path, UnpackOptions{LedgerDir: ledger, AccountsDir: filepath.Join(ledger, "accounts")}); err == nil {
+		t.Fatal("expected a corrupt archive to fail")
+	}
+	if _, err := os.Stat(filepath.Join(ledger, "snapshots", "50")); err != nil {
+		t.Error("expected the existing bank snapshot to be kept")
+	}
+	if _, err := os.Stat(filepath.Join(ledger, stagingDirName)); !os.IsNotExist(err) {
+		t.Error("expected the staging directory to be removed")
+	}
+}
@@ -0,0 +1,271 @@
+package verify
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/bzip2"
+	"compress/gzip"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/charmbracelet/log"
+)
+
+func logger() *log.Logger { return log.Default().WithPrefix("verify") }
+
+// maxVersionFileSize bounds how much of the archive's version file is read.
+const maxVersionFileSize = 64
+
+// Options configures how an archive is verified.
+type Options struct {
+	// ScratchDir receives the unpacked archive when Restore is set. A
+	// temporary directory is created inside it and removed afterwards.
+	ScratchDir string
+	// Restore unpacks the archive to disk (a restore dry run) rather than
+	// only reading it through
+	Restore bool
+	// MaxBytes caps how much is written to ScratchDir (0 = unlimited)
+	MaxBytes int64
+	// Timeout caps how long verification runs (0 = unlimited)
+	Timeout time.Duration
+}
+
+// Result describes what was found in an archive.
+type Result struct {
+	Path         string
+	Entries      int
+	Bytes        int64 // uncompressed bytes read
+	AccountFiles int
+	Version      string // snapshot archive format version
+	HasBank      bool   // snapshots/<slot>/<slot> is present
+	// BudgetExhausted is set when MaxBytes or Timeout stopped verification
+	// before the end of the archive
+	BudgetExhausted bool
+	Duration        time.Duration
+}
+
+// Archive verifies that the snapshot archive at path for slot decompresses
+// and contains the entries a validator needs to load it: the version file,
+// the bank snapshot for slot and account storage files. Running out of
+// budget is only an error if those entries weren't seen first.
+func Archive(ctx context.Context, path string, slot uint64, opts Options) (*Result, error) {
+	start := time.Now()
+	if opts.Timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, opts.Timeout)
+		defer cancel()
+	}
+
+	var scratch string
+	if opts.Restore {
+		dir, err := os.MkdirTemp(opts.ScratchDir, "snapshot-restore-dryrun-")
+		if err != nil {
+			return nil, fmt.Errorf("creating scratch directory: %w", err)
+		}
+		defer os.RemoveAll(dir)
+		scratch = dir
+	}
+
+	stream, err := decompress(ctx, path)
+	if err != nil {
+		return nil, err
+	}
+	defer stream.Close()
+
+	result := &Result{Path: path}
+	bankPath := fmt.Sprintf("snapshots/%d/%d", slot, slot)
+	tr := tar.NewReader(&contextReader{ctx: ctx, r: stream})
+
+	var written int64
+	for {
+		hdr, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				result.BudgetExhausted = true
+				break
+			}
+			return result, fmt.Errorf("reading archive after %d entries: %w", result.Entries, err)
+		}
+		if scratch != "" && opts.MaxBytes > 0 && written+hdr.Size > opts.MaxBytes {
+			result.BudgetExhausted = true
+			break
+		}
+		result.Entries++
+		name := strings.TrimPrefix(hdr.Name, "./")
+
+		var body io.Reader = tr
+		switch {
+		case name == "version":
+			data, _ := io.ReadAll(io.LimitReader(tr, maxVersionFileSize))
+			result.Version = strings.TrimSpace(string(data))
+			body = io.MultiReader(bytes.NewReader(data), tr)
+		case name == bankPath:
+			result.HasBank = true
+		case strings.HasPrefix(name, "accounts/") && hdr.Typeflag == tar.TypeReg:
+			result.AccountFiles++
+		}
+
+		if scratch != "" && hdr.Typeflag == tar.TypeReg {
+			n, err := extractFile(scratch, name, body)
+			written += n
+			result.Bytes += n
+			if err != nil {
+				if ctx.Err() == context.DeadlineExceeded {
+					result.BudgetExhausted = true
+					break
+				}
+				return result, fmt.Errorf("restoring %s: %w", name, err)
+			}
+			continue
+		}
+
+		n, err := io.Copy(io.Discard, body)
+		result.Bytes += n
+		if err != nil {
+			if ctx.Err() == context.DeadlineExceeded {
+				result.BudgetExhausted = true
+				break
+			}
+			return result, fmt.Errorf("reading %s: %w", name, err)
+		}
+	}
+	result.Duration = time.Since(start)
+	if result.BudgetExhausted {
+		logger().Warn(fmt.Sprintf("verification budget exhausted after %d entries (%d bytes) - archive only partially checked", result.Entries, result.Bytes), "path", path)
+	}
+
+	if !result.BudgetExhausted {
+		// Drain trailing padding so an external decompressor can exit
+		io.Copy(io.Discard, stream)
+		if err := stream.Wait(); err != nil {
+			return result, fmt.Errorf("decompressing: %w", err)
+		}
+	}
+
+	var missing []string
+	if result.Version == "" {
+		missing = append(missing, "version")
+	}
+	if !result.HasBank {
+		missing = append(missing, bankPath)
+	}
+	if result.AccountFiles == 0 {
+		missing = append(missing, "accounts/")
+	}
+	if len(missing) > 0 {
+		if result.BudgetExhausted {
+			return result, fmt.Errorf("budget exhausted after %d entries before finding %s", result.Entries, strings.Join(missing, ", "))
+		}
+		return result, fmt.Errorf("archive is missing %s", strings.Join(missing, ", "))
+	}
+	return result, nil
+}
+
+// extractFile writes one regular file from the archive under root.
+func extractFile(root, name string, r io.Reader) (int64, error) {
+	if !filepath.IsLocal(name) {
+		return 0, fmt.Errorf("unsafe path in archive")
+	}
+	dest := filepath.Join(root, name)
+	if err := os.MkdirAll(filepath.Dir(dest), 0755); err != nil {
+		return 0, err
+	}
+	f, err := os.Create(dest)
+	if err != nil {
+		return 0, err
+	}
+	n, err := io.Copy(f, r)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	return n, err
+}
+
+// decompressedStream is the uncompressed archive. Wait reports errors from
+// an external decompressor once the stream has been read.
+type decompressedStream struct {
+	io.Reader
+	file *os.File
+	cmd  *exec.Cmd
+}
+
+func (s *decompressedStream) Close() error {
+	if s.cmd != nil && s.cmd.Process != nil && s.cmd.ProcessState == nil {
+		s.cmd.Process.Kill()
+		s.cmd.Wait()
+	}
+	return s.file.Close()
+}
+
+func (s *decompressedStream) Wait() error {
+	if s.cmd == nil || s.cmd.ProcessState != nil {
+		return nil
+	}
+	return s.cmd.Wait()
+}
+
+// decompress opens path and decompresses it by extension. zstd archives are
+// piped through the zstd binary, which must be on PATH.
+func decompress(ctx context.Context, path string) (*decompressedStream, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening archive: %w", err)
+	}
+	s := &decompressedStream{file: f}
+
+	switch {
+	case strings.HasSuffix(path, ".tar.zst"):
+		cmd := exec.CommandContext(ctx, "zstd", "-d", "-c", "--long=31")
+		cmd.Stdin = f
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("starting zstd: %w", err)
+		}
+		if err := cmd.Start(); err != nil {
+			f.Close()
+			if errors.Is(err, exec.ErrNotFound) {
+				return nil, fmt.Errorf("zstd is required to verify .tar.zst archives: %w", err)
+			}
+			return nil, fmt.Errorf("starting zstd: %w", err)
+		}
+		s.Reader, s.cmd = out, cmd
+	case strings.HasSuffix(path, ".tar.bz2"):
+		s.Reader = bzip2.NewReader(f)
+	case strings.HasSuffix(path, ".tar.gz"):
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, fmt.Errorf("opening gzip stream: %w", err)
+		}
+		s.Reader = gz
+	default:
+		f.Close()
+		return nil, fmt.Errorf("unsupported archive type %q", filepath.Base(path))
+	}
+	return s, nil
+}
+
+// contextReader stops reading once ctx is done, so a time budget also
+// interrupts long copies.
+type contextReader struct {
+	ctx context.Context
+	r   io.Reader
+}
+
+func (c *contextReader) Read(p []byte) (int, error) {
+	if err := c.ctx.Err(); err != nil {
+		return 0, err
+	}
+	return c.r.Read(p)
+}
@@ -0,0 +1,156 @@
+package verify
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+type entry struct {
+	name string
+	data string
+}
+
+func snapshotEntries(slot string) []entry {
+	return []entry{
+		{"version", "1.2.0\n"},
+		{"snapshots/status_cache", "cache"},
+		{"snapshots/" + slot + "/" + slot, "bank"},
+		{"accounts/" + slot + ".1", strings.Repeat("a", 1024)},
+		{"accounts/" + slot + ".2", strings.Repeat("b", 1024)},
+	}
+}
+
+func writeTar(t *testing.T, entries []entry) []byte {
+	t.Helper()
+	var buf bytes.Buffer
+	tw := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err := tw.WriteHeader(&tar.Header{Name: e.name, Mode: 0644, Size: int64(len(e.data)), Typeflag: tar.TypeReg}); err != nil {
+			t.Fatal(err)
+		}
+		tw.Write([]byte(e.data))
+	}
+	tw.Close()
+	return buf.Bytes()
+}
+
+func writeTarGz(t *testing.T, dir, name string, entries []entry) string {
+	t.Helper()
+	var buf bytes.Buffer
+	gz := gzip.NewWriter(&buf)
+	gz.Write(writeTar(t, entries))
+	gz.Close()
+	path := filepath.Join(dir, name)
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func TestArchive_ReadThrough(t *testing.T) {
+	path := writeTarGz(t, t.TempDir(), "snapshot-100-Hash.tar.gz", snapshotEntries("100"))
+
+	result, err := Archive(context.Background(), path, 100, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Version != "1.2.0" || !result.HasBank || result.AccountFiles != 2 || result.Entries != 5 {
+		t.Errorf("unexpected result %+v", result)
+	}
+	if result.BudgetExhausted {
+		t.Error("expected complete verification")
+	}
+}
+
+func TestArchive_RestoreDryRunCleansUp(t *testing.T) {
+	path := writeTarGz(t, t.TempDir(), "snapshot-100-Hash.tar.gz", snapshotEntries("100"))
+	scratch := t.TempDir()
+
+	result, err := Archive(context.Background(), path, 100, Options{ScratchDir: scratch, Restore: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Bytes == 0 {
+		t.Error("expected bytes to be restored")
+	}
+	leftovers, _ := os.ReadDir(scratch)
+	if len(leftovers) != 0 {
+		t.Errorf("expected scratch directory to be cleaned up, found %d entries", len(leftovers))
+	}
+}
+
+func TestArchive_MissingBank(t *testing.T) {
+	path := writeTarGz(t, t.TempDir(), "snapshot-100-Hash.tar.gz", snapshotEntries("99"))
+
+	if _, err := Archive(context.Background(), path, 100, Options{}); err == nil || !strings.Contains(err.Error(), "snapshots/100/100") {
+		t.Errorf("expected missing bank error, got %v", err)
+	}
+}
+
+func TestArchive_DiskBudget(t *testing.T) {
+	path := writeTarGz(t, t.TempDir(), "snapshot-100-Hash.tar.gz", snapshotEntries("100"))
+
+	// Enough for the version, status cache, bank and first account file only
+	result, err := Archive(context.Background(), path, 100, Options{ScratchDir: t.TempDir(), Restore: true, MaxBytes: 1100})
+	if err != nil {
+		t.Fatalf("expected budget stop after required entries to pass: %v", err)
+	}
+	if !result.BudgetExhausted || result.AccountFiles != 1 {
+		t.Errorf("expected partial verification, got %+v", result)
+	}
+
+	// Too small to reach the bank snapshot
+	if _, err := Archive(context.Background(), path, 100, Options{ScratchDir: t.TempDir(), Restore: true, MaxBytes: 8}); err == nil {
+		t.Error("expected error when budget runs out before required entries")
+	}
+}
+
+func TestArchive_Truncated(t *testing.T) {
+	dir := t.TempDir()
+	path := writeTarGz(t, dir, "snapshot-100-Hash.tar.gz", snapshotEntries("100"))
+	data, _ := os.ReadFile(path)
+	os.WriteFile(path, data[:len(data)/2], 0644)
+
+	if _, err := Archive(context.Background(), path, 100, Options{}); err == nil {
+		t.Error("expected error for truncated archive")
+	}
+}
+
+func TestArchive_Zstd(t *testing.T) {
+	if _, err := exec.LookPath("zstd"); err != nil {
+		t.Skip("zstd not installed")
+	}
+	dir := t.TempDir()
+	tarPath := filepath.Join(dir, "snapshot.tar")
+	os.WriteFile(tarPath, writeTar(t, snapshotEntries("100")), 0644)
+	path := filepath.Join(dir, "snapshot-100-Hash.tar.zst")
+	if out, err := exec.Command("zstd", "-q", tarPath, "-o", path).CombinedOutput(); err != nil {
+		t.Fatalf("zstd: %v: %s", err, out)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	result, err := Archive(ctx, path, 100, Options{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.AccountFiles != 2 {
+		t.Errorf("expected 2 account files, got %d", result.AccountFiles)
+	}
+}
+
+func TestArchive_UnsupportedType(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "snapshot-100-Hash.tar")
+	os.WriteFile(path, nil, 0644)
+	if _, err := Archive(context.Background(), path, 100, Options{}); err == nil {
+		t.Error("expected error for unsupported archive type")
+	}
+}
@@ -0,0 +1,89 @@
+package main
+
+import (
+	"fmt"
+	"time"
+)
+
+// RotationConfig makes the snapshot server take new snapshots as the slot
+// advances, the way a real node does.
+type RotationConfig struct {
+	FullIntervalSlots        uint64 `yaml:"full_interval_slots"`        // 0 = always serve full_slot
+	IncrementalIntervalSlots uint64 `yaml:"incremental_interval_slots"` // 0 = always serve incremental_slot
+}
+
+// Archives a rotating node keeps, like the validator's
+// --maximum-full-snapshots-to-retain and
+// --maximum-incremental-snapshots-to-retain.
+const (
+	retainedFulls        = 2
+	retainedIncrementals = 4
+)
+
+// chain is the mock cluster's progress: its slot, which advances in real
+// time with slots_per_second, and the snapshots served at that slot.
+type chain struct {
+	start  time.Time
+	slot   uint64
+	perSec float64
+	cfg    SnapshotConfig
+}
+
+func newChain(cfg Config) *chain {
+	return &chain{start: time.Now(), slot: cfg.RPC.Slot, perSec: cfg.RPC.SlotsPerSecond, cfg: cfg.Snapshot}
+}
+
+func (c *chain) currentSlot() uint64 {
+	return c.slot + uint64(time.Since(c.start).Seconds()*c.perSec)
+}
+
+// latest returns the newest full and incremental (0 = none) slots.
+func (c *chain) latest() (full, inc uint64) {
+	slot := c.currentSlot()
+	full = c.cfg.FullSlot
+	if every := c.cfg.Rotation.FullIntervalSlots; every > 0 && slot > full {
+		full += (slot - full) / every * every
+	}
+	if every := c.cfg.Rotation.IncrementalIntervalSlots; every > 0 {
+		if slot > full && slot-full >= every {
+			inc = full + (slot-full)/every*every
+		}
+	} else if full == c.cfg.FullSlot {
+		inc = c.cfg.IncSlot
+	}
+	return full, inc
+}
+
+func (c *chain) fullFilename(full uint64) string {
+	return fmt.Sprintf("snapshot-%d-%s.tar.zst", full, c.cfg.FullHash)
+}
+
+func (c *chain) incFilename(full, inc uint64) string {
+	return fmt.Sprintf("incremental-snapshot-%d-%d-%s.tar.zst", full, inc, c.cfg.IncHash)
+}
+
+// retained returns the archives served now with their sizes: the newest
+// fulls and the newest incrementals on top of the latest full.
+func (c *chain) retained() map[string]int64 {
+	full, inc := c.latest()
+	fullSize := int64(c.cfg.FullSizeMB) * 1024 * 1024
+	incSize := int64(c.cfg.IncSizeMB) * 1024 * 1024
+
+	files := map[string]int64{c.fullFilename(full): fullSize}
+	if every := c.cfg.Rotation.FullIntervalSlots; every > 0 {
+		for i, f := 1, full; i < retainedFulls && f >= c.cfg.FullSlot+every; i++ {
+			f -= every
+			files[c.fullFilename(f)] = fullSize
+		}
+	}
+	if inc > 0 {
+		files[c.incFilename(full, inc)] = incSize
+		if every := c.cfg.Rotation.IncrementalIntervalSlots; every > 0 {
+			for i, s := 1, inc; i < retainedIncrementals && s > full+every; i++ {
+				s -= every
+				files[c.incFilename(full, s)] = incSize
+			}
+		}
+	}
+	return files
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// FaultConfig injects failures into the snapshot server so the keeper's
+// retry and fallback paths can be exercised end to end. Everything is off
+// by default.
+type FaultConfig struct {
+	ErrorRate          float64       `yaml:"error_rate"`           // fraction of requests answered with a random 5xx
+	DisconnectRate     float64       `yaml:"disconnect_rate"`      // fraction of downloads cut off mid-stream
+	BandwidthKBps      int64         `yaml:"bandwidth_kbps"`       // per-response throttle (0 = unlimited)
+	FirstByteDelay     time.Duration `yaml:"first_byte_delay"`     // wait before answering a download
+	IgnoreRange        bool          `yaml:"ignore_range"`         // answer Range requests with the whole file
+	WrongContentLength bool          `yaml:"wrong_content_length"` // advertise more bytes than are sent
+}
+
+// serverErrors are the statuses error_rate answers with.
+var serverErrors = []int{
+	http.StatusInternalServerError,
+	http.StatusBadGateway,
+	http.StatusServiceUnavailable,
+	http.StatusGatewayTimeout,
+}
+
+// withErrors answers a fraction of requests with a random 5xx instead of
+// passing them to next.
+func (f FaultConfig) withErrors(next http.Handler) http.Handler {
+	if f.ErrorRate <= 0 {
+		return next
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rand.Float64() < f.ErrorRate {
+			status := serverErrors[rand.IntN(len(serverErrors))]
+			log.Printf("Snapshot: %s %s → %d (injected)", r.Method, r.URL.Path, status)
+			w.WriteHeader(status)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+// cutoff returns how many of length bytes to send before disconnecting,
+// or length to send them all.
+func (f FaultConfig) cutoff(length int64) int64 {
+	if length <= 0 || rand.Float64() >= f.DisconnectRate {
+		return length
+	}
+	return rand.Int64N(length)
+}
+
+// advertised returns the Content-Length to send for a body of length bytes.
+func (f FaultConfig) advertised(length int64) int64 {
+	if f.WrongContentLength {
+		return length + 1024
+	}
+	return length
+}
+
+func (f FaultConfig) bytesPerSecond() int64 {
+	return f.BandwidthKBps * 1024
+}
+
+func (f FaultConfig) String() string {
+	return fmt.Sprintf("error_rate=%g disconnect_rate=%g bandwidth_kbps=%d first_byte_delay=%s ignore_range=%t wrong_content_length=%t",
+		f.ErrorRate, f.DisconnectRate, f.BandwidthKBps, f.FirstByteDelay, f.IgnoreRange, f.WrongContentLength)
+}
@@ -11,6 +11,7 @@ import (
 	"strconv"
 	"strings"
 	"sync"
+	"time"
 
 	"gopkg.in/yaml.v3"
 )
@@ -19,6 +20,7 @@ import (
 type Config struct {
 	RPC      RPCConfig      `yaml:"rpc"`
 	Snapshot SnapshotConfig `yaml:"snapshot"`
+	Simulate SimulateConfig `yaml:"simulate"`
 }
 
 type RPCConfig struct {
@@ -26,6 +28,8 @@ type RPCConfig struct {
 	Identity   string `yaml:"identity"`
 	Slot       uint64 `yaml:"slot"`
 	Nodes      []Node `yaml:"nodes"`
+
+	SlotsPerSecond float64 `yaml:"slots_per_second"` // advance the slot in real time (0 = fixed)
 }
 
 type Node struct {
@@ -43,6 +47,9 @@ type SnapshotConfig struct {
 	IncSlot    uint64 `yaml:"incremental_slot"`
 	IncHash    string `yaml:"incremental_hash"`
 	IncSizeMB  int    `yaml:"incremental_size_mb"`
+
+	Rotation RotationConfig `yaml:"rotation"`
+	Faults   FaultConfig    `yaml:"faults"`
 }
 
 func main() {
@@ -59,15 +66,20 @@ func main() {
 		log.Fatalf("parsing config: %v", err)
 	}
 
+	c := newChain(cfg)
+	simulated := simulatedNodes(cfg)
+	for _, n := range simulated {
+		cfg.RPC.Nodes = append(cfg.RPC.Nodes, n.Node)
+	}
 	var wg sync.WaitGroup
 
 	// Start RPC server
 	wg.Add(1)
 	go func() {
 		defer wg.Done()
-		log.Printf("RPC server listening on %s (identity=%s, slot=%d, nodes=%d)",
-			cfg.RPC.ListenAddr, cfg.RPC.Identity, cfg.RPC.Slot, len(cfg.RPC.Nodes))
-		if err := http.ListenAndServe(cfg.RPC.ListenAddr, rpcHandler(cfg.RPC)); err != nil {
+		log.Printf("RPC server listening on %s (identity=%s, slot=%d, slots_per_second=%g, nodes=%d)",
+			cfg.RPC.ListenAddr, cfg.RPC.Identity, cfg.RPC.Slot, cfg.RPC.SlotsPerSecond, len(cfg.RPC.Nodes))
+		if err := http.ListenAndServe(cfg.RPC.ListenAddr, rpcHandler(cfg.RPC, c)); err != nil {
 			log.Fatalf("RPC server: %v", err)
 		}
 	}()
@@ -79,15 +91,33 @@ func main() {
 		log.Printf("Snapshot server listening on %s (full_slot=%d size=%dMB, inc_slot=%d size=%dMB)",
 			cfg.Snapshot.ListenAddr, cfg.Snapshot.FullSlot, cfg.Snapshot.FullSizeMB,
 			cfg.Snapshot.IncSlot, cfg.Snapshot.IncSizeMB)
-		if err := http.ListenAndServe(cfg.Snapshot.ListenAddr, snapshotHandler(cfg.Snapshot)); err != nil {
+		if r := cfg.Snapshot.Rotation; r != (RotationConfig{}) {
+			log.Printf("Snapshot server rotating snapshots (full every %d slots, incremental every %d slots)",
+				r.FullIntervalSlots, r.IncrementalIntervalSlots)
+		}
+		if cfg.Snapshot.Faults != (FaultConfig{}) {
+			log.Printf("Snapshot server injecting faults: %s", cfg.Snapshot.Faults)
+		}
+		if err := http.ListenAndServe(cfg.Snapshot.ListenAddr, snapshotHandler(cfg.Snapshot, c)); err != nil {
 			log.Fatalf("Snapshot server: %v", err)
 		}
 	}()
 
+	// Start simulated snapshot-serving nodes
+	for _, n := range simulated {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := n.serve(cfg.Snapshot, c); err != nil {
+				log.Fatalf("Simulated node %s: %v", n.Pubkey, err)
+			}
+		}()
+	}
+
 	wg.Wait()
 }
 
-func rpcHandler(cfg RPCConfig) http.Handler {
+func rpcHandler(cfg RPCConfig, c *chain) http.Handler {
 	mux := http.NewServeMux()
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
 		var req struct {
@@ -105,7 +135,9 @@ func rpcHandler(cfg RPCConfig) http.Handler {
 		case "getIdentity":
 			result = map[string]string{"identity": cfg.Identity}
 		case "getSlot":
-			result = cfg.Slot
+			result = c.currentSlot()
+		case "getRecentPerformanceSamples":
+			result = []map[string]any{{"slot": c.currentSlot(), "numSlots": 150, "numTransactions": 0, "samplePeriodSecs": 60}}
 		case "getClusterNodes":
 			var nodes []map[string]any
 			for _, n := range cfg.Nodes {
@@ -148,22 +180,14 @@ func writeRPCError(w http.ResponseWriter, id int, code int, msg string) {
 	json.NewEncoder(w).Encode(resp)
 }
 
-func snapshotHandler(cfg SnapshotConfig) http.Handler {
-	fullFilename := fmt.Sprintf("snapshot-%d-%s.tar.zst", cfg.FullSlot, cfg.FullHash)
-	fullSize := int64(cfg.FullSizeMB) * 1024 * 1024
-
-	incFilename := ""
-	var incSize int64
-	if cfg.IncSlot > 0 {
-		incFilename = fmt.Sprintf("incremental-snapshot-%d-%d-%s.tar.zst", cfg.FullSlot, cfg.IncSlot, cfg.IncHash)
-		incSize = int64(cfg.IncSizeMB) * 1024 * 1024
-	}
-
+func snapshotHandler(cfg SnapshotConfig, c *chain) http.Handler {
 	mux := http.NewServeMux()
 
 	// HEAD /snapshot.tar.bz2 → 302 redirect
 	mux.HandleFunc("/snapshot.tar.bz2", func(w http.ResponseWriter, r *http.Request) {
 		if r.Method == http.MethodHead {
+			full, _ := c.latest()
+			fullFilename := c.fullFilename(full)
 			log.Printf("Snapshot: HEAD /snapshot.tar.bz2 → 302 %s", fullFilename)
 			w.Header().Set("Location", "/"+fullFilename)
 			w.WriteHeader(http.StatusFound)
@@ -173,48 +197,57 @@ func snapshotHandler(cfg SnapshotConfig) http.Handler {
 	})
 
 	// HEAD /incremental-snapshot.tar.bz2 → 302 redirect
-	if incFilename != "" {
-		mux.HandleFunc("/incremental-snapshot.tar.bz2", func(w http.ResponseWriter, r *http.Request) {
-			if r.Method == http.MethodHead {
-				log.Printf("Snapshot: HEAD /incremental-snapshot.tar.bz2 → 302 %s", incFilename)
-				w.Header().Set("Location", "/"+incFilename)
-				w.WriteHeader(http.StatusFound)
-				return
-			}
-			http.NotFound(w, r)
-		})
-	}
-
-	// GET/HEAD for actual snapshot files (with Range support)
-	mux.HandleFunc("/"+fullFilename, func(w http.ResponseWriter, r *http.Request) {
-		serveRandomData(w, r, fullFilename, fullSize)
+	mux.HandleFunc("/incremental-snapshot.tar.bz2", func(w http.ResponseWriter, r *http.Request) {
+		full, inc := c.latest()
+		if r.Method == http.MethodHead && inc > 0 {
+			incFilename := c.incFilename(full, inc)
+			log.Printf("Snapshot: HEAD /incremental-snapshot.tar.bz2 → 302 %s", incFilename)
+			w.Header().Set("Location", "/"+incFilename)
+			w.WriteHeader(http.StatusFound)
+			return
+		}
+		http.NotFound(w, r)
 	})
-	if incFilename != "" {
-		mux.HandleFunc("/"+incFilename, func(w http.ResponseWriter, r *http.Request) {
-			serveRandomData(w, r, incFilename, incSize)
-		})
-	}
 
-	// Catch-all
+	// GET/HEAD for actual snapshot files (with Range support); anything
+	// else is a 404
 	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		log.Printf("Snapshot: 404 %s %s", r.Method, r.URL.Path)
-		http.NotFound(w, r)
+		filename := strings.TrimPrefix(r.URL.Path, "/")
+		size, ok := c.retained()[filename]
+		if !ok {
+			log.Printf("Snapshot: 404 %s %s", r.Method, r.URL.Path)
+			http.NotFound(w, r)
+			return
+		}
+		serveRandomData(w, r, filename, size, cfg.Faults)
 	})
 
-	return mux
+	return cfg.Faults.withErrors(mux)
 }
 
-func serveRandomData(w http.ResponseWriter, r *http.Request, filename string, totalSize int64) {
+func serveRandomData(w http.ResponseWriter, r *http.Request, filename string, totalSize int64, faults FaultConfig) {
 	if r.Method == http.MethodHead {
-		w.Header().Set("Content-Length", strconv.FormatInt(totalSize, 10))
+		w.Header().Set("Content-Length", strconv.FormatInt(faults.advertised(totalSize), 10))
 		w.Header().Set("Accept-Ranges", "bytes")
 		w.WriteHeader(http.StatusOK)
 		log.Printf("Snapshot: HEAD /%s → 200 (size=%d)", filename, totalSize)
 		return
 	}
 
+	if faults.FirstByteDelay > 0 {
+		select {
+		case <-time.After(faults.FirstByteDelay):
+		case <-r.Context().Done():
+			return
+		}
+	}
+
 	// Handle Range requests
 	rangeHeader := r.Header.Get("Range")
+	if rangeHeader != "" && faults.IgnoreRange {
+		log.Printf("Snapshot: GET /%s Range=%s → ignoring range (injected)", filename, rangeHeader)
+		rangeHeader = ""
+	}
 	if rangeHeader != "" {
 		rangeHeader = strings.TrimPrefix(rangeHeader, "bytes=")
 		parts := strings.Split(rangeHeader, "-")
@@ -229,23 +262,34 @@ func serveRandomData(w http.ResponseWriter, r *http.Request, filename string, to
 		length := end - start + 1
 
 		w.Header().Set("Content-Range", fmt.Sprintf("bytes %d-%d/%d", start, end, totalSize))
-		w.Header().Set("Content-Length", strconv.FormatInt(length, 10))
+		w.Header().Set("Content-Length", strconv.FormatInt(faults.advertised(length), 10))
 		w.WriteHeader(http.StatusPartialContent)
 		log.Printf("Snapshot: GET /%s Range=%s → 206 (%d bytes)", filename, rangeHeader, length)
-		streamRandomBytes(w, length)
+		streamRandomBytes(w, length, faults)
 		return
 	}
 
 	// Full download
-	w.Header().Set("Content-Length", strconv.FormatInt(totalSize, 10))
+	w.Header().Set("Content-Length", strconv.FormatInt(faults.advertised(totalSize), 10))
 	w.Header().Set("Accept-Ranges", "bytes")
 	w.WriteHeader(http.StatusOK)
 	log.Printf("Snapshot: GET /%s → 200 (size=%d)", filename, totalSize)
-	streamRandomBytes(w, totalSize)
+	streamRandomBytes(w, totalSize, faults)
 }
 
-func streamRandomBytes(w http.ResponseWriter, total int64) {
+// streamRandomBytes writes total random bytes, or fewer if faults cut the
+// connection, throttled to faults' bandwidth.
+func streamRandomBytes(w http.ResponseWriter, total int64, faults FaultConfig) {
+	if cut := faults.cutoff(total); cut < total {
+		log.Printf("Snapshot: disconnecting after %d of %d bytes (injected)", cut, total)
+		total = cut
+	}
+	rate := faults.bytesPerSecond()
+	start := time.Now()
 	buf := make([]byte, 256*1024) // 256KB chunks
+	if rate > 0 {
+		buf = buf[:min(int64(len(buf)), max(rate/10, 1))]
+	}
 	var written int64
 	for written < total {
 		remaining := total - written
@@ -261,5 +305,8 @@ func streamRandomBytes(w http.ResponseWriter, total int64) {
 		if f, ok := w.(http.Flusher); ok {
 			f.Flush()
 		}
+		if rate > 0 {
+			time.Sleep(time.Until(start.Add(time.Duration(written * int64(time.Second) / rate))))
+		}
 	}
 }
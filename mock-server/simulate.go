@@ -0,0 +1,104 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// SimulateConfig starts extra snapshot-serving nodes in this process, each
+// with its own listener, latency and snapshot age, and lists them in
+// getClusterNodes so discovery, sorting and fallback can be tested black-box.
+type SimulateConfig struct {
+	Nodes                  int           `yaml:"nodes"`                    // how many (0 = off)
+	Host                   string        `yaml:"host"`                     // listen and advertise on this host
+	FirstPort              int           `yaml:"first_port"`               // nodes listen on consecutive ports from here
+	MaxLatency             time.Duration `yaml:"max_latency"`              // each node delays responses by up to this
+	MaxSlotsBehind         uint64        `yaml:"max_slots_behind"`         // each node's snapshots are up to this much older
+	MissingIncrementalRate float64       `yaml:"missing_incremental_rate"` // fraction of nodes serving no incremental
+	Seed                   uint64        `yaml:"seed"`                     // same seed, same nodes
+}
+
+// simulatedNode is one node SimulateConfig starts.
+type simulatedNode struct {
+	Node
+	listenAddr    string
+	latency       time.Duration
+	slotsBehind   uint64
+	noIncremental bool
+}
+
+// simulatedNodes derives the nodes to start from cfg.
+func simulatedNodes(cfg Config) []simulatedNode {
+	sim := cfg.Simulate
+	rng := rand.New(rand.NewPCG(sim.Seed, sim.Seed))
+	host := sim.Host
+	if host == "" {
+		host = "127.0.0.1"
+	}
+
+	nodes := make([]simulatedNode, sim.Nodes)
+	for i := range nodes {
+		addr := net.JoinHostPort(host, strconv.Itoa(sim.FirstPort+i))
+		name := fmt.Sprintf("SimNode%d", i+1)
+		n := simulatedNode{
+			Node: Node{
+				Pubkey:  name + strings.Repeat("1", 44-len(name)),
+				Gossip:  net.JoinHostPort(host, strconv.Itoa(sim.FirstPort+i+1000)),
+				RPC:     "http://" + addr,
+				Version: "2.2.4",
+			},
+			listenAddr:    addr,
+			noIncremental: rng.Float64() < sim.MissingIncrementalRate,
+		}
+		if sim.MaxLatency > 0 {
+			n.latency = time.Duration(rng.Int64N(int64(sim.MaxLatency)))
+		}
+		// Keep every node's full at a valid slot
+		if behind := min(sim.MaxSlotsBehind, max(cfg.Snapshot.FullSlot, 1)-1); behind > 0 {
+			n.slotsBehind = rng.Uint64N(behind + 1)
+		}
+		nodes[i] = n
+	}
+	return nodes
+}
+
+// chain returns the node's view of c: every snapshot slotsBehind older,
+// and no incrementals if it is missing them.
+func (n simulatedNode) chain(c *chain) *chain {
+	view := *c
+	view.slot -= min(view.slot, n.slotsBehind)
+	view.cfg.FullSlot -= n.slotsBehind
+	if view.cfg.IncSlot > 0 {
+		view.cfg.IncSlot -= n.slotsBehind
+	}
+	if n.noIncremental {
+		view.cfg.IncSlot = 0
+		view.cfg.Rotation.IncrementalIntervalSlots = 0
+	}
+	return &view
+}
+
+// serve runs the node's snapshot server until it fails.
+func (n simulatedNode) serve(cfg SnapshotConfig, c *chain) error {
+	log.Printf("Simulated node %s listening on %s (latency=%s, slots_behind=%d, incrementals=%t)",
+		n.Pubkey, n.listenAddr, n.latency, n.slotsBehind, !n.noIncremental)
+	handler := snapshotHandler(cfg, n.chain(c))
+	if n.latency > 0 {
+		next := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			select {
+			case <-time.After(n.latency):
+			case <-r.Context().Done():
+				return
+			}
+			next.ServeHTTP(w, r)
+		})
+	}
+	return http.ListenAndServe(n.listenAddr, handler)
+}
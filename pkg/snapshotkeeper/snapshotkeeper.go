@@ -0,0 +1,141 @@
+// Package snapshotkeeper embeds snapshot discovery, download and pruning in
+// other Go programs, with the same behaviour as the CLI.
+//
+// A Client is built from the same config the CLI reads:
+//
+//	cfg, err := snapshotkeeper.LoadConfig("/etc/snapshot-keeper/config.yml")
+//	if err != nil {
+//		return err
+//	}
+//	client, err := snapshotkeeper.New(cfg)
+//	if err != nil {
+//		return err
+//	}
+//	defer client.Close()
+//	decision, err := client.RunCycle(ctx)
+//
+// Logging goes through github.com/charmbracelet/log's default logger.
+package snapshotkeeper
+
+import (
+	"context"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/config"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/discovery"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/downloader"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/events"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/keeper"
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/manager"
+)
+
+type (
+	// Config is the keeper configuration, as read from config.yml.
+	Config = config.Config
+	// Node is a cluster node serving a snapshot.
+	Node = discovery.SnapshotNode
+	// SnapshotType is Full or Incremental.
+	SnapshotType = discovery.SnapshotType
+	// DownloadResult describes a completed download.
+	DownloadResult = downloader.Result
+	// Decision records what a cycle did and why.
+	Decision = keeper.Decision
+	// DiscoveryReport lists the suitable nodes, why the rest were rejected
+	// and how widely each snapshot slot is served.
+	DiscoveryReport = discovery.DiscoveryReport
+	// FullAvailability is how many nodes serve one full snapshot slot, and
+	// incrementals building on it.
+	FullAvailability = discovery.FullAvailability
+
+	// Event is one of CycleStarted, CandidateSelected, DownloadProgress or
+	// CycleFinished.
+	Event             = events.Event
+	CycleStarted      = events.CycleStarted
+	CandidateSelected = events.CandidateSelected
+	DownloadProgress  = events.DownloadProgress
+	CycleFinished     = events.CycleFinished
+	// EventBus delivers a keeper's events to its subscribers.
+	EventBus = events.Bus
+)
+
+const (
+	Full        = discovery.SnapshotTypeFull
+	Incremental = discovery.SnapshotTypeIncremental
+)
+
+// LoadConfig reads and validates a config file, applying the CLI's defaults.
+func LoadConfig(path string) (*Config, error) {
+	return config.NewFromConfigFile(path)
+}
+
+// LoadConfigWithProfile is LoadConfig with the settings under
+// profiles.<profile> merged over the rest of the file.
+func LoadConfigWithProfile(path, profile string) (*Config, error) {
+	return config.NewFromConfigFileWithProfile(path, profile)
+}
+
+// Client runs keeper operations against one validator's config.
+type Client struct {
+	manager *manager.Manager
+	keeper  *keeper.Keeper
+}
+
+// New validates cfg and creates a Client. Close it when done.
+func New(cfg *Config) (*Client, error) {
+	if err := cfg.Validate(); err != nil {
+		return nil, err
+	}
+	m := manager.New(cfg)
+	return &Client{manager: m, keeper: m.Keeper()}, nil
+}
+
+// Close releases the client's metrics connection.
+func (c *Client) Close() error {
+	return c.manager.Close()
+}
+
+// Discover returns every suitable node serving a snapshot of the given type,
+// after the configured trust, probe and age rules, sorted by sort_order.
+func (c *Client) Discover(ctx context.Context, snapshotType SnapshotType) ([]Node, error) {
+	return c.keeper.Discover(ctx, snapshotType)
+}
+
+// DiscoverReport is like Discover, but also reports why each rejected node
+// was found unsuitable.
+func (c *Client) DiscoverReport(ctx context.Context, snapshotType SnapshotType) (DiscoveryReport, error) {
+	return c.keeper.DiscoverReport(ctx, snapshotType)
+}
+
+// Download fetches node's snapshot into the snapshot directory, without
+// checking the validator's role or local freshness.
+func (c *Client) Download(ctx context.Context, node Node) (*DownloadResult, error) {
+	return c.keeper.Download(ctx, node)
+}
+
+// Prune removes superseded snapshots and temp files.
+func (c *Client) Prune() error {
+	return c.keeper.Prune()
+}
+
+// Subscribe calls h with every event the client's keeper publishes, until
+// the returned func is called. h runs on the publishing goroutine, so it
+// must return quickly and be safe for concurrent use.
+func (c *Client) Subscribe(h func(Event)) (unsubscribe func()) {
+	return c.keeper.Events().Subscribe(h)
+}
+
+// RegisterPlugin attaches p to the event bus of every keeper created
+// afterwards, including those `run` starts. Call it from an init function of
+// a package compiled into a custom build of the CLI to add an integration
+// without changing the keeper.
+func RegisterPlugin(name string, p func(*EventBus)) {
+	events.Register(name, p)
+}
+
+// RunCycle runs one full keeper cycle, as `run --once` does, including the
+// snapshot directory's lock, hooks and metrics, and returns its decision.
+// It fails while another process holds the lock.
+func (c *Client) RunCycle(ctx context.Context) (Decision, error) {
+	err := c.manager.RunOnceContext(ctx)
+	d, _ := c.keeper.LastDecision()
+	return d, err
+}
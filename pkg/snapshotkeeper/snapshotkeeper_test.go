@@ -0,0 +1,176 @@
+package snapshotkeeper
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/sol-strategies/solana-validator-snapshot-keeper/internal/lock"
+)
+
+// fakeCluster serves JSON-RPC for both the validator and the cluster, and
+// snapshot HEAD redirects and downloads for one full snapshot.
+func fakeCluster(t *testing.T, identity string, slot uint64, fullFilename string, data []byte) *httptest.Server {
+	t.Helper()
+	var srv *httptest.Server
+	srv = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodHead && strings.Contains(r.URL.Path, "snapshot.tar.bz2") && !strings.Contains(r.URL.Path, "incremental"):
+			w.Header().Set("Location", "/"+fullFilename)
+			w.WriteHeader(http.StatusFound)
+			return
+		case r.Method == http.MethodHead:
+			w.WriteHeader(http.StatusNotFound)
+			return
+		case r.Method == http.MethodGet:
+			w.Header().Set("Content-Length", strconv.Itoa(len(data)))
+			w.Write(data)
+			return
+		}
+
+		var req struct {
+			Method string `json:"method"`
+			ID     int    `json:"id"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		var result any
+		switch req.Method {
+		case "getIdentity":
+			result = map[string]string{"identity": identity}
+		case "getSlot":
+			result = slot
+		case "getClusterNodes":
+			result = []map[string]any{{"pubkey": "node1", "gossip": "10.0.0.1:8001", "rpc": srv.URL}}
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		resultJSON, _ := json.Marshal(result)
+		json.NewEncoder(w).Encode(map[string]any{"jsonrpc": "2.0", "id": req.ID, "result": json.RawMessage(resultJSON)})
+	}))
+	return srv
+}
+
+func writeConfig(t *testing.T, rpcURL, snapshotDir string) string {
+	t.Helper()
+	path := filepath.Join(t.TempDir(), "config.yml")
+	content := fmt.Sprintf(`
+validator:
+  rpc_url: %q
+  active_identity_pubkey: ActivePubkey
+cluster:
+  name: testnet
+  rpc_url: %q
+snapshots:
+  directory: %q
+  discovery:
+    probe:
+      max_latency: 5s
+  download:
+    min_speed_check_delay: 0s
+    connections: 1
+`, rpcURL, rpcURL, snapshotDir)
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+	return path
+}
+
+func newClient(t *testing.T, srv *httptest.Server, snapshotDir string) *Client {
+	t.Helper()
+	cfg, err := LoadConfig(writeConfig(t, srv.URL, snapshotDir))
+	if err != nil {
+		t.Fatal(err)
+	}
+	client, err := New(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() {
+		if err := client.Close(); err != nil {
+			t.Errorf("closing client: %v", err)
+		}
+	})
+	return client
+}
+
+func TestClient_DiscoverDownloadPrune(t *testing.T) {
+	data := []byte("fake snapshot data")
+	srv := fakeCluster(t, "PassivePubkey", 100100, "snapshot-100000-HashA.tar.zst", data)
+	defer srv.Close()
+
+	snapshotDir := t.TempDir()
+	os.WriteFile(filepath.Join(snapshotDir, "snapshot-90000-HashOld.tar.zst"), []byte("old"), 0644)
+	client := newClient(t, srv, snapshotDir)
+
+	nodes, err := client.Discover(context.Background(), Full)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(nodes) != 1 || nodes[0].Slot != 100000 {
+		t.Fatalf("expected one node with slot 100000, got %+v", nodes)
+	}
+
+	result, err := client.Download(context.Background(), nodes[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := os.ReadFile(result.FilePath)
+	if err != nil || string(got) != string(data) {
+		t.Fatalf("downloaded file mismatch: %q, %v", got, err)
+	}
+
+	if err := client.Prune(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "snapshot-90000-HashOld.tar.zst")); err == nil {
+		t.Error("older full snapshot should be pruned")
+	}
+}
+
+func TestClient_RunCycle_ActiveValidator(t *testing.T) {
+	srv := fakeCluster(t, "ActivePubkey", 100100, "snapshot-100000-HashA.tar.zst", nil)
+	defer srv.Close()
+
+	client := newClient(t, srv, t.TempDir())
+	var names []string
+	unsubscribe := client.Subscribe(func(e Event) { names = append(names, e.Name()) })
+	defer unsubscribe()
+	decision, err := client.RunCycle(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if decision.Result != "skipped" || decision.Role != "active" {
+		t.Errorf("expected skipped cycle for active validator, got %+v", decision)
+	}
+	if strings.Join(names, ",") != "cycle_started,cycle_finished" {
+		t.Errorf("expected the cycle's start and finish events, got %v", names)
+	}
+}
+
+func TestClient_RunCycle_Locked(t *testing.T) {
+	srv := fakeCluster(t, "PassivePubkey", 100100, "snapshot-100000-HashA.tar.zst", []byte("data"))
+	defer srv.Close()
+
+	snapshotDir := t.TempDir()
+	held, err := lock.Acquire(filepath.Join(snapshotDir, "solana-validator-snapshot-keeper.lock"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer held.Release()
+
+	client := newClient(t, srv, snapshotDir)
+	if _, err := client.RunCycle(context.Background()); err == nil {
+		t.Fatal("expected RunCycle to fail while another process holds the lock")
+	}
+	if _, err := os.Stat(filepath.Join(snapshotDir, "snapshot-100000-HashA.tar.zst")); err == nil {
+		t.Error("expected nothing to be downloaded without the lock")
+	}
+}